@@ -2,9 +2,12 @@ package appconfig
 
 import (
 	"bytes"
+	"crypto"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 )
@@ -13,21 +16,32 @@ type AppConfig struct {
 	AppID             string `json:"github_app_id"`
 	AppInstallationID int64  `json:"github_app_installation_id"`
 	AppPrivateKey     string `json:"github_app_private_key"`
+	// AppPrivateKeySigner signs the GitHub App JWTs in place of AppPrivateKey, e.g. with a key of a HSM
+	// the private key cannot be exported from.
+	AppPrivateKeySigner crypto.Signer `json:"-"`
+	// AppPrivateKeyFile is the file AppPrivateKey is read from, e.g. a key of a mounted Secret, so that the
+	// rotated key is picked up without encoding it into the config.
+	AppPrivateKeyFile string `json:"github_app_private_key_file,omitempty"`
 
 	Token string `json:"github_token"`
+	// TokenFile is the file Token is read from.
+	TokenFile string `json:"github_token_file,omitempty"`
 }
 
 func (c *AppConfig) tidy() *AppConfig {
-	if len(c.Token) > 0 {
+	if len(c.Token) > 0 || len(c.TokenFile) > 0 {
 		return &AppConfig{
-			Token: c.Token,
+			Token:     c.Token,
+			TokenFile: c.TokenFile,
 		}
 	}
 
 	return &AppConfig{
-		AppID:             c.AppID,
-		AppInstallationID: c.AppInstallationID,
-		AppPrivateKey:     c.AppPrivateKey,
+		AppID:               c.AppID,
+		AppInstallationID:   c.AppInstallationID,
+		AppPrivateKey:       c.AppPrivateKey,
+		AppPrivateKeySigner: c.AppPrivateKeySigner,
+		AppPrivateKeyFile:   c.AppPrivateKeyFile,
 	}
 }
 
@@ -35,7 +49,7 @@ func (c *AppConfig) Validate() error {
 	if c == nil {
 		return fmt.Errorf("missing app config")
 	}
-	hasToken := len(c.Token) > 0
+	hasToken := len(c.Token) > 0 || len(c.TokenFile) > 0
 	hasGitHubAppAuth := c.hasGitHubAppAuth()
 	if hasToken && hasGitHubAppAuth {
 		return fmt.Errorf("both PAT and GitHub App credentials provided. should only provide one")
@@ -48,7 +62,35 @@ func (c *AppConfig) Validate() error {
 }
 
 func (c *AppConfig) hasGitHubAppAuth() bool {
-	return len(c.AppID) > 0 && c.AppInstallationID > 0 && len(c.AppPrivateKey) > 0
+	return len(c.AppID) > 0 && c.AppInstallationID > 0 && (len(c.AppPrivateKey) > 0 || len(c.AppPrivateKeyFile) > 0 || c.AppPrivateKeySigner != nil)
+}
+
+// HasFiles reports whether the credentials are read from files.
+func (c *AppConfig) HasFiles() bool {
+	return len(c.AppPrivateKeyFile) > 0 || len(c.TokenFile) > 0
+}
+
+// ReadFiles returns a copy of the config with AppPrivateKey and Token read from their files, if set.
+// The files are read without their trailing newline.
+func (c *AppConfig) ReadFiles() (*AppConfig, error) {
+	appConfig := *c
+	for _, file := range []struct {
+		path string
+		into *string
+	}{
+		{c.AppPrivateKeyFile, &appConfig.AppPrivateKey},
+		{c.TokenFile, &appConfig.Token},
+	} {
+		if file.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return nil, err
+		}
+		*file.into = strings.TrimRight(string(data), "\r\n")
+	}
+	return &appConfig, nil
 }
 
 func FromSecret(secret *corev1.Secret) (*AppConfig, error) {
@@ -75,12 +117,20 @@ func FromSecret(secret *corev1.Secret) (*AppConfig, error) {
 	return cfg.tidy(), nil
 }
 
-func FromJSONString(v string) (*AppConfig, error) {
+// FromJSONString decodes the app config, calling the resolvers before validating it, e.g. to set the private key
+// resolved from a vault.
+func FromJSONString(v string, resolvers ...func(*AppConfig) error) (*AppConfig, error) {
 	var appConfig AppConfig
 	if err := json.NewDecoder(bytes.NewBufferString(v)).Decode(&appConfig); err != nil {
 		return nil, err
 	}
 
+	for _, resolve := range resolvers {
+		if err := resolve(&appConfig); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := appConfig.Validate(); err != nil {
 		return nil, fmt.Errorf("failed to validate app config decoded from string: %w", err)
 	}
@@ -1,7 +1,11 @@
 package appconfig
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -42,6 +46,9 @@ func TestAppConfigValidate_invalid(t *testing.T) {
 }
 
 func TestAppConfigValidate_valid(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
 	tt := map[string]*AppConfig{
 		"token": {
 			Token: "token",
@@ -51,6 +58,19 @@ func TestAppConfigValidate_valid(t *testing.T) {
 			AppInstallationID: 2,
 			AppPrivateKey:     "private key",
 		},
+		"app ID with signer": {
+			AppID:               "1",
+			AppInstallationID:   2,
+			AppPrivateKeySigner: key,
+		},
+		"token file": {
+			TokenFile: "/etc/gha-listener/github_token",
+		},
+		"app ID with private key file": {
+			AppID:             "1",
+			AppInstallationID: 2,
+			AppPrivateKeyFile: "/etc/gha-listener/github_app_private_key",
+		},
 	}
 
 	for name, cfg := range tt {
@@ -61,6 +81,26 @@ func TestAppConfigValidate_valid(t *testing.T) {
 	}
 }
 
+func TestAppConfigReadFiles(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "github_app_private_key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("private key\n"), 0o600))
+
+	cfg := &AppConfig{
+		AppID:             "1",
+		AppInstallationID: 2,
+		AppPrivateKeyFile: keyFile,
+	}
+	got, err := cfg.ReadFiles()
+	require.NoError(t, err)
+	assert.Equal(t, "private key", got.AppPrivateKey)
+	assert.Equal(t, keyFile, got.AppPrivateKeyFile, "the file is kept to be re-read")
+	assert.Empty(t, cfg.AppPrivateKey, "the config is not modified")
+
+	require.NoError(t, os.Remove(keyFile))
+	_, err = cfg.ReadFiles()
+	assert.Error(t, err)
+}
+
 func TestAppConfigFromSecret_invalid(t *testing.T) {
 	tt := map[string]map[string]string{
 		"empty": {},
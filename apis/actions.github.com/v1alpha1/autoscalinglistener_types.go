@@ -21,6 +21,11 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// AutoscalingListenerIdleTerminationMessage is the termination message of the listener container once
+// the listener exits after the scale set was idle. The controller does not recreate the listener pod
+// until the scale set has acquirable jobs again.
+const AutoscalingListenerIdleTerminationMessage = "idle"
+
 // AutoscalingListenerSpec defines the desired state of AutoscalingListener
 type AutoscalingListenerSpec struct {
 	// Required
@@ -49,6 +54,11 @@ type AutoscalingListenerSpec struct {
 	// +kubebuilder:validation:Minimum:=0
 	MinRunners int `json:"minRunners,omitempty"`
 
+	// MaxRunnerMinutesPerDay is the runner minutes budget per UTC day of the scale set. Zero means unlimited.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MaxRunnerMinutesPerDay int `json:"maxRunnerMinutesPerDay,omitempty"`
+
 	// Required
 	Image string `json:"image,omitempty"`
 
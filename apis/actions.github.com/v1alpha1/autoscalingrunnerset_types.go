@@ -63,6 +63,11 @@ type AutoscalingRunnerSetSpec struct {
 	// +optional
 	RunnerGroup string `json:"runnerGroup,omitempty"`
 
+	// SecondaryRunnerGroup is the runner group the runner scale set fails over to when the
+	// runner group is disabled or removed, and fails back from once the runner group is available again.
+	// +optional
+	SecondaryRunnerGroup string `json:"secondaryRunnerGroup,omitempty"`
+
 	// +optional
 	RunnerScaleSetName string `json:"runnerScaleSetName,omitempty"`
 
@@ -91,11 +96,49 @@ type AutoscalingRunnerSetSpec struct {
 	// +optional
 	// +kubebuilder:validation:Minimum:=0
 	MinRunners *int `json:"minRunners,omitempty"`
+
+	// MaxRunnerMinutesPerDay is the budget of runner minutes the runners of the scale set can run per UTC day.
+	// Once it is exhausted, the listener caps the scale-ups at the min runners until the next day.
+	// +optional
+	// +kubebuilder:validation:Minimum:=0
+	MaxRunnerMinutesPerDay *int `json:"maxRunnerMinutesPerDay,omitempty"`
+
+	// ScaleDownRate limits the idle runners deleted per interval on scale down. It applies to the
+	// ephemeral runner set in place, without recreating the runners.
+	// +optional
+	ScaleDownRate *ScaleDownRate `json:"scaleDownRate,omitempty"`
+
+	// JobPodAnnotations are set on the pod of every runner once it is assigned a job, e.g.
+	// cluster-autoscaler.kubernetes.io/safe-to-evict: "false", so that the node scale-down does not evict it mid-job.
+	// +optional
+	JobPodAnnotations map[string]string `json:"jobPodAnnotations,omitempty"`
 }
 
 type TLSConfig struct {
 	// Required
 	CertificateFrom *TLSCertificateSource `json:"certificateFrom,omitempty"`
+	// Revocation checks the certificates of the GitHub Enterprise Server are not revoked, by the controller
+	// and by the listener. If it is not set, the revocation is not checked.
+	// +optional
+	Revocation *CertificateRevocationConfig `json:"revocation,omitempty"`
+}
+
+// CertificateRevocationConfig configures the revocation checks of the certificates of a GitHub Enterprise Server.
+// The certificates are checked with OCSP first, then with the CRLs, until their revocation status is determined.
+type CertificateRevocationConfig struct {
+	// CRLDistributionPoints fetches the CRLs of the distribution points of the certificates.
+	// +optional
+	CRLDistributionPoints bool `json:"crlDistributionPoints,omitempty"`
+	// OCSP checks the certificates with the OCSP response stapled by the server, or with their OCSP responders.
+	// +optional
+	OCSP bool `json:"ocsp,omitempty"`
+	// SoftFail accepts the certificates whose revocation status cannot be determined, e.g. while the OCSP
+	// responder is unreachable. Otherwise, the connections to the GitHub server fail.
+	// +optional
+	SoftFail bool `json:"softFail,omitempty"`
+	// Timeout bounds the requests to the CRL distribution points and the OCSP responders. Defaults to 10s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
 }
 
 func (c *TLSConfig) ToCertPool(keyFetcher func(name, key string) ([]byte, error)) (*x509.CertPool, error) {
@@ -244,6 +287,9 @@ type VaultConfig struct {
 	Type vault.VaultType `json:"type,omitempty"`
 	// +optional
 	AzureKeyVault *AzureKeyVaultConfig `json:"azureKeyVault,omitempty"`
+	// Exec is the plugin the secrets are read from, for the exec vault type.
+	// +optional
+	Exec *ExecVaultConfig `json:"exec,omitempty"`
 	// +optional
 	Proxy *ProxyConfig `json:"proxy,omitempty"`
 }
@@ -257,6 +303,21 @@ type AzureKeyVaultConfig struct {
 	ClientID string `json:"clientId,omitempty"`
 	// +required
 	CertificatePath string `json:"certificatePath,omitempty"`
+	// CertificateName is the name of the certificate of the vault the private key of the GitHub App is read from,
+	// in place of the github_app_private_key of the GitHub config secret.
+	// +optional
+	CertificateName string `json:"certificateName,omitempty"`
+	// SigningKeyURL is the URL of the RSA key of a Key Vault or Managed HSM signing the GitHub App JWTs,
+	// so that the private key is never exported to the pods.
+	// +optional
+	SigningKeyURL string `json:"signingKeyUrl,omitempty"`
+}
+
+type ExecVaultConfig struct {
+	// Plugin is the name of the plugin, one of the plugins the controller is configured with
+	// by its --vault-exec-plugins flag.
+	// +required
+	Plugin string `json:"plugin,omitempty"`
 }
 
 // MetricsConfig holds configuration parameters for each metric type
@@ -267,22 +328,58 @@ type MetricsConfig struct {
 	Gauges map[string]*GaugeMetric `json:"gauges,omitempty"`
 	// +optional
 	Histograms map[string]*HistogramMetric `json:"histograms,omitempty"`
+	// +optional
+	Push *MetricsPushConfig `json:"push,omitempty"`
+}
+
+// MetricsPushConfig holds configuration for pushing the metrics, in addition to exposing them for scraping
+type MetricsPushConfig struct {
+	// PushgatewayURL is the URL of the Prometheus Pushgateway the metrics are pushed to
+	// +optional
+	PushgatewayURL string `json:"pushgatewayURL,omitempty"`
+	// RemoteWriteURL is the URL of the Prometheus remote write endpoint the metrics are written to
+	// +optional
+	RemoteWriteURL string `json:"remoteWriteURL,omitempty"`
+	// Interval is the time between two pushes. Defaults to 30s
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
 }
 
 // CounterMetric holds configuration of a single metric of type Counter
 type CounterMetric struct {
 	Labels []string `json:"labels"`
+	// MaxCardinality is the maximum number of label sets recorded for the metric.
+	// Label sets beyond it are recorded in a single overflow series. Zero means unlimited
+	// +optional
+	MaxCardinality int `json:"maxCardinality,omitempty"`
 }
 
 // GaugeMetric holds configuration of a single metric of type Gauge
 type GaugeMetric struct {
 	Labels []string `json:"labels"`
+	// MaxCardinality is the maximum number of label sets recorded for the metric.
+	// Label sets beyond it are recorded in a single overflow series. Zero means unlimited
+	// +optional
+	MaxCardinality int `json:"maxCardinality,omitempty"`
 }
 
 // HistogramMetric holds configuration of a single metric of type Histogram
 type HistogramMetric struct {
 	Labels  []string  `json:"labels"`
 	Buckets []float64 `json:"buckets,omitempty"`
+	// MaxCardinality is the maximum number of label sets recorded for the metric.
+	// Label sets beyond it are recorded in a single overflow series. Zero means unlimited
+	// +optional
+	MaxCardinality int `json:"maxCardinality,omitempty"`
+	// NativeHistogramBucketFactor enables the Prometheus native histogram of the metric, exposed alongside its buckets
+	// to the scrapers negotiating the protobuf format. Each bucket is wider than the previous one by at most this factor,
+	// e.g. 1.1. Zero disables the native histogram
+	// +optional
+	NativeHistogramBucketFactor float64 `json:"nativeHistogramBucketFactor,omitempty"`
+	// NativeHistogramMaxBuckets is the maximum number of buckets of the native histogram, beyond which its resolution
+	// is reduced. Zero means 160
+	// +optional
+	NativeHistogramMaxBuckets int `json:"nativeHistogramMaxBuckets,omitempty"`
 }
 
 // AutoscalingRunnerSetStatus defines the observed state of AutoscalingRunnerSet
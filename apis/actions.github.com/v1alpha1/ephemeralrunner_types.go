@@ -25,6 +25,17 @@ import (
 // It represents the name of the container running the self-hosted runner image.
 const EphemeralRunnerContainerName = "runner"
 
+// EphemeralRunnerGCPriorityAnnotationKey is set by the listener on an ephemeral runner
+// whose job has completed, but which still exists after a grace period.
+// Annotated runners are removed first when the ephemeral runner set scales down, once they are done
+// or idle like the other runners.
+const EphemeralRunnerGCPriorityAnnotationKey = "actions.github.com/gc-priority"
+
+// EphemeralRunnerTerminateAnnotationKey is set on an ephemeral runner to have it deregistered and deleted,
+// e.g. to rotate a suspicious runner. An idle runner is deleted right away, even if it is kept for the
+// min runners, and the ephemeral runner set replaces it. A runner running a job is deleted once the job completes.
+const EphemeralRunnerTerminateAnnotationKey = "actions.github.com/terminate"
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:JSONPath=".spec.githubConfigUrl",name="GitHub Config URL",type=string
@@ -55,6 +66,19 @@ func (er *EphemeralRunner) HasJob() bool {
 	return len(er.Status.JobID) > 0
 }
 
+// HasGCPriority reports whether the listener marked the runner as finished
+// so it can be reaped ahead of other runners.
+func (er *EphemeralRunner) HasGCPriority() bool {
+	_, ok := er.Annotations[EphemeralRunnerGCPriorityAnnotationKey]
+	return ok
+}
+
+// IsTerminationRequested reports whether the runner is annotated to be terminated.
+func (er *EphemeralRunner) IsTerminationRequested() bool {
+	_, ok := er.Annotations[EphemeralRunnerTerminateAnnotationKey]
+	return ok
+}
+
 func (er *EphemeralRunner) HasContainerHookConfigured() bool {
 	for i := range er.Spec.Spec.Containers {
 		if er.Spec.Spec.Containers[i].Name != EphemeralRunnerContainerName {
@@ -122,6 +146,11 @@ type EphemeralRunnerSpec struct {
 	// +optional
 	VaultConfig *VaultConfig `json:"vaultConfig,omitempty"`
 
+	// JobPodAnnotations are set on the pod of the runner once it is assigned a job, e.g.
+	// cluster-autoscaler.kubernetes.io/safe-to-evict: "false", so that the node scale-down does not evict it mid-job.
+	// +optional
+	JobPodAnnotations map[string]string `json:"jobPodAnnotations,omitempty"`
+
 	corev1.PodTemplateSpec `json:",inline"`
 }
 
@@ -17,9 +17,37 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// EphemeralRunnerSetRepositoryHintsAnnotationKey is set by the listener on the ephemeral runner set,
+// along with the scale patch, to the comma separated repositories of the jobs assigned to the scale set
+// which have not started yet, the repositories with the most jobs first.
+// The controller uses it to place new runners on nodes which recently ran jobs of the same repositories.
+const EphemeralRunnerSetRepositoryHintsAnnotationKey = "actions.github.com/repository-hints"
+
+// EphemeralRunnerSetMessageSessionAnnotationKey is set by the listener on the ephemeral runner set
+// to the JSON encoded ID, owner and creation time of the last message session it created,
+// to match the listener to the session known to GitHub.
+const EphemeralRunnerSetMessageSessionAnnotationKey = "actions.github.com/message-session"
+
+// EphemeralRunnerSetLastMessageIDAnnotationKey is set by the listener on the ephemeral runner set
+// to the ID of the last message it processed in the message session, so a restarted listener
+// can resume the session from that message instead of creating a new one.
+const EphemeralRunnerSetLastMessageIDAnnotationKey = "actions.github.com/last-message-id"
+
+// EphemeralRunnerSetCanaryRolledBackAnnotationKey is set by the listener on the canary ephemeral runner set
+// to the time it rolled the canary back, after its runners failed jobs at a higher rate than the others.
+// The listener does not scale the canary up again until the annotation is removed.
+const EphemeralRunnerSetCanaryRolledBackAnnotationKey = "actions.github.com/canary-rolled-back"
+
+// EphemeralRunnerSetScaleReasonAnnotationKey is set by the listener on the ephemeral runner set,
+// along with the scale patch, to the reason of the scale decision. The controller records it
+// in the status along with the replicas and patch ID of the decision.
+const EphemeralRunnerSetScaleReasonAnnotationKey = "actions.github.com/scale-reason"
+
 // EphemeralRunnerSetSpec defines the desired state of EphemeralRunnerSet
 type EphemeralRunnerSetSpec struct {
 	// Replicas is the number of desired EphemeralRunner resources in the k8s namespace.
@@ -28,6 +56,32 @@ type EphemeralRunnerSetSpec struct {
 	PatchID int `json:"patchID"`
 	// EphemeralRunnerSpec is the spec of the ephemeral runner
 	EphemeralRunnerSpec EphemeralRunnerSpec `json:"ephemeralRunnerSpec,omitempty"`
+	// ScaleDownRate limits the idle ephemeral runners deleted per interval on scale down.
+	// If it is not set, all the idle runners above the desired replicas are deleted at once.
+	// +optional
+	ScaleDownRate *ScaleDownRate `json:"scaleDownRate,omitempty"`
+}
+
+// ScaleDownRate limits the idle ephemeral runners deleted per interval, so that a drained queue
+// does not delete hundreds of pods at once. The deletions left are made over the next intervals.
+type ScaleDownRate struct {
+	// MaxDeletions is the maximum number of idle ephemeral runners deleted per interval.
+	// +kubebuilder:validation:Minimum:=1
+	MaxDeletions int `json:"maxDeletions"`
+	// Interval is the interval the deletions are limited over. Defaults to 30 seconds.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// DefaultScaleDownRateInterval is the interval of a ScaleDownRate without one.
+const DefaultScaleDownRateInterval = 30 * time.Second
+
+// IntervalOrDefault returns the interval of the scale down rate, or DefaultScaleDownRateInterval if not set.
+func (r *ScaleDownRate) IntervalOrDefault() time.Duration {
+	if r.Interval == nil || r.Interval.Duration <= 0 {
+		return DefaultScaleDownRateInterval
+	}
+	return r.Interval.Duration
 }
 
 // EphemeralRunnerSetStatus defines the observed state of EphemeralRunnerSet
@@ -40,6 +94,36 @@ type EphemeralRunnerSetStatus struct {
 	RunningEphemeralRunners int `json:"runningEphemeralRunners"`
 	// +optional
 	FailedEphemeralRunners int `json:"failedEphemeralRunners"`
+
+	// LastDesiredReplicas is the replica count of the last scale decision of the listener.
+	// +optional
+	LastDesiredReplicas int `json:"lastDesiredReplicas"`
+	// LastPatchID is the patch ID of the last scale decision of the listener.
+	// +optional
+	LastPatchID int `json:"lastPatchID"`
+	// LastScaleTime is the time the controller observed the last scale decision of the listener.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+	// LastScaleReason is the reason of the last scale decision of the listener, one of
+	// AssignedJobs, MinRunners, MaxRunners, ScaleStepLimited, Predicted, BudgetExhausted, Fallback or Forced.
+	// +optional
+	LastScaleReason string `json:"lastScaleReason,omitempty"`
+
+	// RunnerMinutes is the time the ephemeral runners ran in the current UTC day. The listener reads it
+	// to enforce its runner minutes budget, which thereby survives the restarts of the listener.
+	// +optional
+	RunnerMinutes *RunnerMinutesStatus `json:"runnerMinutes,omitempty"`
+}
+
+// RunnerMinutesStatus is the time the ephemeral runners ran in a UTC day. It is counted each time the number
+// of running ephemeral runners changes, the runners counted since CountedAt being RunningEphemeralRunners.
+type RunnerMinutesStatus struct {
+	// Day is the start of the UTC day the runner time is counted for.
+	Day metav1.Time `json:"day"`
+	// Seconds is the runner time of the day until CountedAt.
+	Seconds int64 `json:"seconds"`
+	// CountedAt is the time the runner time was last counted at.
+	CountedAt metav1.Time `json:"countedAt"`
 }
 
 // +kubebuilder:object:root=true
@@ -50,6 +134,8 @@ type EphemeralRunnerSetStatus struct {
 // +kubebuilder:printcolumn:JSONPath=".status.runningEphemeralRunners",name=Running Runners,type=integer
 // +kubebuilder:printcolumn:JSONPath=".status.finishedEphemeralRunners",name=Finished Runners,type=integer
 // +kubebuilder:printcolumn:JSONPath=".status.deletingEphemeralRunners",name=Deleting Runners,type=integer
+// +kubebuilder:printcolumn:JSONPath=".status.lastScaleReason",name=Last Scale Reason,type=string,priority=1
+// +kubebuilder:printcolumn:JSONPath=".status.lastScaleTime",name=Last Scale Time,type=date,priority=1
 
 // EphemeralRunnerSet is the Schema for the ephemeralrunnersets API
 type EphemeralRunnerSet struct {
@@ -243,6 +243,23 @@ func (in *AutoscalingRunnerSetSpec) DeepCopyInto(out *AutoscalingRunnerSetSpec)
 		*out = new(int)
 		**out = **in
 	}
+	if in.MaxRunnerMinutesPerDay != nil {
+		in, out := &in.MaxRunnerMinutesPerDay, &out.MaxRunnerMinutesPerDay
+		*out = new(int)
+		**out = **in
+	}
+	if in.ScaleDownRate != nil {
+		in, out := &in.ScaleDownRate, &out.ScaleDownRate
+		*out = new(ScaleDownRate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobPodAnnotations != nil {
+		in, out := &in.JobPodAnnotations, &out.JobPodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingRunnerSetSpec.
@@ -285,6 +302,26 @@ func (in *AzureKeyVaultConfig) DeepCopy() *AzureKeyVaultConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateRevocationConfig) DeepCopyInto(out *CertificateRevocationConfig) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateRevocationConfig.
+func (in *CertificateRevocationConfig) DeepCopy() *CertificateRevocationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateRevocationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CounterMetric) DeepCopyInto(out *CounterMetric) {
 	*out = *in
@@ -370,7 +407,7 @@ func (in *EphemeralRunnerSet) DeepCopyInto(out *EphemeralRunnerSet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSet.
@@ -427,6 +464,11 @@ func (in *EphemeralRunnerSetList) DeepCopyObject() runtime.Object {
 func (in *EphemeralRunnerSetSpec) DeepCopyInto(out *EphemeralRunnerSetSpec) {
 	*out = *in
 	in.EphemeralRunnerSpec.DeepCopyInto(&out.EphemeralRunnerSpec)
+	if in.ScaleDownRate != nil {
+		in, out := &in.ScaleDownRate, &out.ScaleDownRate
+		*out = new(ScaleDownRate)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSetSpec.
@@ -442,6 +484,15 @@ func (in *EphemeralRunnerSetSpec) DeepCopy() *EphemeralRunnerSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EphemeralRunnerSetStatus) DeepCopyInto(out *EphemeralRunnerSetStatus) {
 	*out = *in
+	if in.LastScaleTime != nil {
+		in, out := &in.LastScaleTime, &out.LastScaleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RunnerMinutes != nil {
+		in, out := &in.RunnerMinutes, &out.RunnerMinutes
+		*out = new(RunnerMinutesStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralRunnerSetStatus.
@@ -472,6 +523,13 @@ func (in *EphemeralRunnerSpec) DeepCopyInto(out *EphemeralRunnerSpec) {
 		*out = new(VaultConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.JobPodAnnotations != nil {
+		in, out := &in.JobPodAnnotations, &out.JobPodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	in.PodTemplateSpec.DeepCopyInto(&out.PodTemplateSpec)
 }
 
@@ -507,6 +565,21 @@ func (in *EphemeralRunnerStatus) DeepCopy() *EphemeralRunnerStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExecVaultConfig) DeepCopyInto(out *ExecVaultConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecVaultConfig.
+func (in *ExecVaultConfig) DeepCopy() *ExecVaultConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecVaultConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GaugeMetric) DeepCopyInto(out *GaugeMetric) {
 	*out = *in
@@ -603,6 +676,11 @@ func (in *MetricsConfig) DeepCopyInto(out *MetricsConfig) {
 			(*out)[key] = outVal
 		}
 	}
+	if in.Push != nil {
+		in, out := &in.Push, &out.Push
+		*out = new(MetricsPushConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsConfig.
@@ -615,6 +693,26 @@ func (in *MetricsConfig) DeepCopy() *MetricsConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsPushConfig) DeepCopyInto(out *MetricsPushConfig) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsPushConfig.
+func (in *MetricsPushConfig) DeepCopy() *MetricsPushConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsPushConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProxyConfig) DeepCopyInto(out *ProxyConfig) {
 	*out = *in
@@ -660,6 +758,43 @@ func (in *ProxyServerConfig) DeepCopy() *ProxyServerConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunnerMinutesStatus) DeepCopyInto(out *RunnerMinutesStatus) {
+	*out = *in
+	in.Day.DeepCopyInto(&out.Day)
+	in.CountedAt.DeepCopyInto(&out.CountedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunnerMinutesStatus.
+func (in *RunnerMinutesStatus) DeepCopy() *RunnerMinutesStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RunnerMinutesStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleDownRate) DeepCopyInto(out *ScaleDownRate) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleDownRate.
+func (in *ScaleDownRate) DeepCopy() *ScaleDownRate {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleDownRate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSCertificateSource) DeepCopyInto(out *TLSCertificateSource) {
 	*out = *in
@@ -688,6 +823,11 @@ func (in *TLSConfig) DeepCopyInto(out *TLSConfig) {
 		*out = new(TLSCertificateSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Revocation != nil {
+		in, out := &in.Revocation, &out.Revocation
+		*out = new(CertificateRevocationConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSConfig.
@@ -708,6 +848,11 @@ func (in *VaultConfig) DeepCopyInto(out *VaultConfig) {
 		*out = new(AzureKeyVaultConfig)
 		**out = **in
 	}
+	if in.Exec != nil {
+		in, out := &in.Exec, &out.Exec
+		*out = new(ExecVaultConfig)
+		**out = **in
+	}
 	if in.Proxy != nil {
 		in, out := &in.Proxy, &out.Proxy
 		*out = new(ProxyConfig)
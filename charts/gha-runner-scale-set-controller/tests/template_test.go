@@ -178,7 +178,7 @@ func TestTemplate_CreateManagerClusterRole(t *testing.T) {
 
 	assert.Empty(t, managerClusterRole.Namespace, "ClusterRole should not have a namespace")
 	assert.Equal(t, "test-arc-gha-rs-controller", managerClusterRole.Name)
-	assert.Equal(t, 16, len(managerClusterRole.Rules))
+	assert.Equal(t, 17, len(managerClusterRole.Rules))
 
 	_, err = helm.RenderTemplateE(t, options, helmChartPath, releaseName, []string{"templates/manager_single_namespace_controller_role.yaml"})
 	assert.ErrorContains(t, err, "could not find template templates/manager_single_namespace_controller_role.yaml in chart", "We should get an error because the template should be skipped")
@@ -927,7 +927,7 @@ func TestTemplate_CreateManagerSingleNamespaceRole(t *testing.T) {
 
 	assert.Equal(t, "test-arc-gha-rs-controller-single-namespace-watch", managerSingleNamespaceWatchRole.Name)
 	assert.Equal(t, "demo", managerSingleNamespaceWatchRole.Namespace)
-	assert.Equal(t, 14, len(managerSingleNamespaceWatchRole.Rules))
+	assert.Equal(t, 15, len(managerSingleNamespaceWatchRole.Rules))
 }
 
 func TestTemplate_ManagerSingleNamespaceRoleBinding(t *testing.T) {
@@ -1081,6 +1081,107 @@ func TestDeployment_excludeLabelPropagationPrefixes(t *testing.T) {
 	assert.Contains(t, container.Args, "--exclude-label-propagation-prefix=complete.io/label")
 }
 
+func TestDeployment_clusterAutoscalerFlags(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../gha-runner-scale-set-controller")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		Logger: logger.Discard,
+		SetValues: map[string]string{
+			"flags.preferEmptyNodesOnScaleDown": "true",
+			"flags.clusterAutoscalerHints":      "true",
+			"flags.volumeAwareScaling":          "true",
+			"flags.cacheLocalityHints":          "true",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/deployment.yaml"})
+
+	var deployment appsv1.Deployment
+	helm.UnmarshalK8SYaml(t, output, &deployment)
+
+	require.Len(t, deployment.Spec.Template.Spec.Containers, 1, "Expected one container")
+	container := deployment.Spec.Template.Spec.Containers[0]
+
+	assert.Contains(t, container.Args, "--prefer-empty-nodes-on-scale-down")
+	assert.Contains(t, container.Args, "--cluster-autoscaler-hints")
+	assert.Contains(t, container.Args, "--volume-aware-scaling")
+	assert.Contains(t, container.Args, "--cache-locality-hints")
+}
+
+func TestTemplate_VolumeAwareScalingRBAC(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../gha-runner-scale-set-controller")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		Logger: logger.Discard,
+		SetValues: map[string]string{
+			"flags.volumeAwareScaling": "true",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/manager_cluster_role.yaml"})
+
+	var managerClusterRole rbacv1.ClusterRole
+	helm.UnmarshalK8SYaml(t, output, &managerClusterRole)
+
+	assert.Equal(t, 20, len(managerClusterRole.Rules))
+	assert.Equal(t, []string{"persistentvolumeclaims", "resourcequotas"}, managerClusterRole.Rules[17].Resources)
+	assert.Equal(t, []string{"persistentvolumes"}, managerClusterRole.Rules[18].Resources)
+	assert.Equal(t, []string{"storageclasses"}, managerClusterRole.Rules[19].Resources)
+
+	options.SetValues["flags.watchSingleNamespace"] = "demo"
+	output = helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/manager_single_namespace_watch_role.yaml"})
+
+	var managerSingleNamespaceWatchRole rbacv1.Role
+	helm.UnmarshalK8SYaml(t, output, &managerSingleNamespaceWatchRole)
+
+	assert.Equal(t, 16, len(managerSingleNamespaceWatchRole.Rules))
+	assert.Equal(t, []string{"persistentvolumeclaims", "resourcequotas"}, managerSingleNamespaceWatchRole.Rules[15].Resources)
+}
+
+func TestTemplate_CacheLocalityHintsRBAC(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../gha-runner-scale-set-controller")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		Logger: logger.Discard,
+		SetValues: map[string]string{
+			"flags.cacheLocalityHints": "true",
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/manager_cluster_role.yaml"})
+
+	var managerClusterRole rbacv1.ClusterRole
+	helm.UnmarshalK8SYaml(t, output, &managerClusterRole)
+
+	assert.Equal(t, 18, len(managerClusterRole.Rules))
+	assert.Equal(t, []string{"nodes"}, managerClusterRole.Rules[17].Resources)
+	assert.Equal(t, []string{"get", "list", "watch", "patch"}, managerClusterRole.Rules[17].Verbs)
+}
+
 func TestNamespaceOverride(t *testing.T) {
 	t.Parallel()
 
@@ -1224,3 +1325,35 @@ func TestNamespaceOverride(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplate_ClusterAutoscalerPriorityExpander(t *testing.T) {
+	t.Parallel()
+
+	// Path to the helm chart we will test
+	helmChartPath, err := filepath.Abs("../../gha-runner-scale-set-controller")
+	require.NoError(t, err)
+
+	releaseName := "test-arc"
+	namespaceName := "test-" + strings.ToLower(random.UniqueId())
+
+	options := &helm.Options{
+		Logger: logger.Discard,
+		SetJsonValues: map[string]string{
+			"clusterAutoscalerPriorityExpander": `{"namespace":"kube-system","priorities":{"50":[".*-runners-.*"],"10":[".*"]}}`,
+		},
+		KubectlOptions: k8s.NewKubectlOptions("", "", namespaceName),
+	}
+
+	output := helm.RenderTemplate(t, options, helmChartPath, releaseName, []string{"templates/cluster_autoscaler_priority_expander.yaml"})
+
+	var configMap corev1.ConfigMap
+	helm.UnmarshalK8SYaml(t, output, &configMap)
+
+	assert.Equal(t, "cluster-autoscaler-priority-expander", configMap.Name)
+	assert.Equal(t, "kube-system", configMap.Namespace)
+	assert.Equal(t, "10:\n  - \".*\"\n50:\n  - \".*-runners-.*\"", configMap.Data["priorities"])
+
+	options.SetJsonValues["clusterAutoscalerPriorityExpander"] = `{"namespace":"kube-system","priorities":{"high":[".*"]}}`
+	_, err = helm.RenderTemplateE(t, options, helmChartPath, releaseName, []string{"templates/cluster_autoscaler_priority_expander.yaml"})
+	assert.ErrorContains(t, err, `clusterAutoscalerPriorityExpander.priorities key "high" has to be a non-negative integer`)
+}
@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ForceScaleRequest is the body of a force scale request.
+type ForceScaleRequest struct {
+	// Replicas is the runner count the scale set is forced to, at most the max runners.
+	Replicas int `json:"replicas"`
+	// Minutes is the time the runners are forced for, at most MaxForceScaleMinutes.
+	Minutes int `json:"minutes"`
+}
+
+// ForceScaleResponse is the body of the responses to the force scale requests.
+type ForceScaleResponse struct {
+	Replicas int       `json:"replicas"`
+	Until    time.Time `json:"until"`
+}
+
+func (s *Server) handleRefreshSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	s.logger.Info("Message session refresh requested", "remoteAddr", r.RemoteAddr)
+	s.refreshSession()
+	// The session is refreshed by the listener before it fetches the next message.
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleForceScale(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req ForceScaleRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid force scale: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := req.validate(); err != nil {
+			http.Error(w, fmt.Sprintf("invalid force scale: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		until := s.clock.Now().Add(time.Duration(req.Minutes) * time.Minute)
+		s.logger.Info("Force scale requested", "replicas", req.Replicas, "minutes", req.Minutes, "remoteAddr", r.RemoteAddr)
+		s.forceScale(req.Replicas, until)
+		s.respond(w, http.StatusCreated, &ForceScaleResponse{Replicas: req.Replicas, Until: until})
+	case http.MethodDelete:
+		s.logger.Info("Force scale cancellation requested", "remoteAddr", r.RemoteAddr)
+		s.cancelForceScale()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *ForceScaleRequest) validate() error {
+	if r.Replicas < 0 {
+		return fmt.Errorf("replicas %d cannot be negative", r.Replicas)
+	}
+	if r.Minutes <= 0 || r.Minutes > MaxForceScaleMinutes {
+		return fmt.Errorf("minutes %d must be between 1 and %d", r.Minutes, MaxForceScaleMinutes)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/logging"
+	"github.com/go-logr/logr"
+	"k8s.io/utils/clock"
+)
+
+// maxRecentErrors is the number of errors kept by the ErrorLog.
+const maxRecentErrors = 20
+
+// Error is an error logged by the listener.
+type Error struct {
+	Time    time.Time `json:"time"`
+	Logger  string    `json:"logger,omitempty"`
+	Message string    `json:"message"`
+	Error   string    `json:"error"`
+	Code    string    `json:"code,omitempty"`
+}
+
+// ErrorLog keeps the most recent errors logged through the loggers it wraps, for the admin API.
+// The messages are redacted, as the errors are recorded before the wrapped logger redacts them.
+type ErrorLog struct {
+	clock  clock.PassiveClock
+	mu     sync.Mutex
+	errors []Error
+}
+
+func NewErrorLog(clock clock.PassiveClock) *ErrorLog {
+	return &ErrorLog{clock: clock}
+}
+
+// Wrap returns the logger recording the errors it logs.
+func (l *ErrorLog) Wrap(logger logr.Logger) logr.Logger {
+	sink := logger.GetSink()
+	if sink == nil {
+		return logger
+	}
+	// The wrapped sink is one frame further away from the caller.
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(1)
+	}
+	return logr.New(&recordingSink{sink: sink, log: l})
+}
+
+// Errors returns the most recent errors, oldest first.
+func (l *ErrorLog) Errors() []Error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Error(nil), l.errors...)
+}
+
+func (l *ErrorLog) record(name string, err error, msg string) {
+	e := Error{
+		Time:    l.clock.Now(),
+		Logger:  name,
+		Message: logging.RedactString(msg),
+	}
+	if err != nil {
+		e.Error = logging.RedactString(err.Error())
+	}
+	if code, ok := errcode.Of(err); ok {
+		e.Code = code.String()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.errors) == maxRecentErrors {
+		copy(l.errors, l.errors[1:])
+		l.errors = l.errors[:maxRecentErrors-1]
+	}
+	l.errors = append(l.errors, e)
+}
+
+// recordingSink records the errors before passing them to the wrapped sink.
+type recordingSink struct {
+	sink logr.LogSink
+	log  *ErrorLog
+	name string
+}
+
+var _ logr.CallDepthLogSink = (*recordingSink)(nil)
+
+// Init does nothing, the wrapped sink was initialized by its own logger.
+func (s *recordingSink) Init(info logr.RuntimeInfo) {}
+
+func (s *recordingSink) Enabled(level int) bool {
+	return s.sink.Enabled(level)
+}
+
+func (s *recordingSink) Info(level int, msg string, keysAndValues ...any) {
+	s.sink.Info(level, msg, keysAndValues...)
+}
+
+func (s *recordingSink) Error(err error, msg string, keysAndValues ...any) {
+	s.log.record(s.name, err, msg)
+	s.sink.Error(err, msg, keysAndValues...)
+}
+
+func (s *recordingSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return &recordingSink{sink: s.sink.WithValues(keysAndValues...), log: s.log, name: s.name}
+}
+
+func (s *recordingSink) WithName(name string) logr.LogSink {
+	fullName := name
+	if s.name != "" {
+		fullName = s.name + "/" + name
+	}
+	return &recordingSink{sink: s.sink.WithName(name), log: s.log, name: fullName}
+}
+
+func (s *recordingSink) WithCallDepth(depth int) logr.LogSink {
+	sink, ok := s.sink.(logr.CallDepthLogSink)
+	if !ok {
+		return s
+	}
+	return &recordingSink{sink: sink.WithCallDepth(depth), log: s.log, name: s.name}
+}
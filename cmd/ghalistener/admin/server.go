@@ -0,0 +1,316 @@
+// Package admin serves the admin API of the listener, through which external systems, e.g. release
+// orchestrators, reserve runners ahead of a planned demand instead of waiting for the scale set to scale
+// up from the jobs assigned to it:
+//
+//	curl -H "Authorization: Bearer $TOKEN" -d '{"runners": 20, "minutes": 30}' http://<listener pod IP>:<port>/reservations
+//
+// The reserved runners raise the min runners of the scale set until the reservation expires. The server also
+// exports and imports the durable scaling state of the listener at StatePath for disaster recovery. The requests
+// must present the bearer token of the listener.
+//
+// The server serves a read-only status page of the listener at UIPath as well, for the operators without access
+// to the dashboards or to the cluster. They log in with the bearer token, and their browsers present a session
+// cookie from then on. The admin port is exposed to them like any web tool, e.g. with a Service of the listener
+// pods behind the ingress of the internal tools, and the server is served over TLS with a certificate and key.
+//
+// For break-glass operations, the server also refreshes the message session at RefreshSessionPath and forces
+// the runners to a count at ForceScalePath. The admin API can also be served on a Unix socket, whose requests
+// are not authenticated since the socket is only accessible to the listener user:
+//
+//	kubectl exec <listener pod> -- curl --unix-socket /tmp/admin.sock http://localhost/state
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"github.com/go-logr/logr"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/utils/clock"
+)
+
+// ReservationsPath is the path of the reservations endpoint.
+const ReservationsPath = "/reservations"
+
+// MaxReservationMinutes is the longest a reservation can last, so that a reservation left behind
+// by a failed pipeline does not keep the runners for good.
+const MaxReservationMinutes = 24 * 60
+
+// MaxForceScaleMinutes is the longest the runners can be forced to a count, so that a forced scale left behind
+// after an incident does not keep the scale set from scaling for good.
+const MaxForceScaleMinutes = 24 * 60
+
+// RefreshSessionPath and ForceScalePath are the paths of the break-glass endpoints.
+const (
+	RefreshSessionPath = "/refresh-session"
+	ForceScalePath     = "/force-scale"
+)
+
+// maxRequestBytes bounds the size of the request bodies.
+const maxRequestBytes = 1 << 10
+
+// ServerConfig configures the Server.
+type ServerConfig struct {
+	// Addr is the TCP address the admin API is served on, if set.
+	Addr string
+	// BearerToken is the token the requests on Addr must present. It is required with Addr.
+	BearerToken string
+	// TLSCertFile and TLSKeyFile are the certificate and key the admin API is served with over TLS on Addr, if set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// Socket is the name of the Unix socket in WorkDir the admin API is served on, if set.
+	Socket string
+	// WorkDir is the writable directory of the socket. It is required with Socket.
+	WorkDir *workdir.Dir
+	// Reserve keeps the runners available until the time.
+	Reserve func(runners int, until time.Time)
+	// Reserved returns the runners of the reservations which did not expire yet.
+	Reserved func() int
+	// Status returns the state shown by the status page. If it is not set, the status page is not served.
+	Status func() Status
+	// ExportState and ImportState export and import the durable scaling state.
+	// If they are not set, the state endpoint is not served.
+	ExportState func() worker.Snapshot
+	ImportState func(worker.Snapshot) error
+	// TargetRunners returns the runners of the last patch, exported by the state endpoint.
+	TargetRunners func() int
+	// Session returns the message session, nil if none is established, exported by the state endpoint.
+	Session func() *listener.SessionInfo
+	// Errors keeps the recent errors exported by the state endpoint, if set.
+	Errors *ErrorLog
+	// RefreshSession refreshes the message session before the next message is fetched.
+	// If it is not set, the refresh session endpoint is not served.
+	RefreshSession func()
+	// ForceScale pins the runners to a count until the time, and CancelForceScale cancels it.
+	// If they are not set, the force scale endpoint is not served.
+	ForceScale       func(replicas int, until time.Time)
+	CancelForceScale func()
+	// Clock is used to compute the expiry of the reservations. Defaults to the real clock.
+	Clock  clock.PassiveClock
+	Logger logr.Logger
+}
+
+// Server serves the admin API of the listener.
+type Server struct {
+	srv *http.Server
+	// socketSrv serves the admin API on the Unix socket, nil if no socket is configured.
+	socketSrv *http.Server
+	socket    string
+	workDir   *workdir.Dir
+
+	bearerToken      string
+	tlsCertFile      string
+	tlsKeyFile       string
+	reserve          func(runners int, until time.Time)
+	reserved         func() int
+	status           func() Status
+	exportState      func() worker.Snapshot
+	importState      func(worker.Snapshot) error
+	targetRunners    func() int
+	session          func() *listener.SessionInfo
+	errors           *ErrorLog
+	refreshSession   func()
+	forceScale       func(replicas int, until time.Time)
+	cancelForceScale func()
+	clock            clock.PassiveClock
+	logger           logr.Logger
+}
+
+// ReservationRequest is the body of a reservation request.
+type ReservationRequest struct {
+	// Runners is the number of runners to reserve.
+	Runners int `json:"runners"`
+	// Minutes is the time the runners are reserved for, at most MaxReservationMinutes.
+	Minutes int `json:"minutes"`
+}
+
+// ReservationsResponse is the body of the responses of the reservations endpoint.
+type ReservationsResponse struct {
+	// Until is the time the requested reservation expires at. It is not set on listings.
+	Until *time.Time `json:"until,omitempty"`
+	// Reserved is the runners of all the reservations which did not expire yet.
+	Reserved int `json:"reserved"`
+}
+
+func NewServer(config ServerConfig) *Server {
+	s := &Server{
+		socket:           config.Socket,
+		workDir:          config.WorkDir,
+		bearerToken:      config.BearerToken,
+		tlsCertFile:      config.TLSCertFile,
+		tlsKeyFile:       config.TLSKeyFile,
+		reserve:          config.Reserve,
+		reserved:         config.Reserved,
+		status:           config.Status,
+		exportState:      config.ExportState,
+		importState:      config.ImportState,
+		targetRunners:    config.TargetRunners,
+		session:          config.Session,
+		errors:           config.Errors,
+		refreshSession:   config.RefreshSession,
+		forceScale:       config.ForceScale,
+		cancelForceScale: config.CancelForceScale,
+		clock:            config.Clock,
+		logger:           config.Logger,
+	}
+	if s.clock == nil {
+		s.clock = clock.RealClock{}
+	}
+
+	s.srv = &http.Server{
+		Addr:              config.Addr,
+		Handler:           s.routes(s.authenticated, s.uiAuthenticated),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	if s.socket != "" {
+		s.socketSrv = &http.Server{
+			// The socket is only accessible to the listener user, so its requests are not authenticated.
+			Handler:           s.routes(unauthenticated, unauthenticated),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+	}
+
+	return s
+}
+
+// routes returns the handler of the admin API, wrapping the endpoints with the middleware,
+// and the status page with the uiMiddleware.
+func (s *Server) routes(middleware, uiMiddleware func(http.Handler) http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(ReservationsPath, middleware(http.HandlerFunc(s.handleReservations)))
+	if s.status != nil {
+		mux.Handle("GET "+UIPath+"{$}", uiMiddleware(http.HandlerFunc(s.handleUI)))
+		mux.HandleFunc("POST "+UILoginPath, s.handleUILogin)
+	}
+	if s.exportState != nil && s.importState != nil {
+		mux.Handle(StatePath, middleware(http.HandlerFunc(s.handleState)))
+	}
+	if s.refreshSession != nil {
+		mux.Handle(RefreshSessionPath, middleware(http.HandlerFunc(s.handleRefreshSession)))
+	}
+	if s.forceScale != nil && s.cancelForceScale != nil {
+		mux.Handle(ForceScalePath, middleware(http.HandlerFunc(s.handleForceScale)))
+	}
+	return mux
+}
+
+// authenticated rejects the requests which do not present the bearer token.
+func (s *Server) authenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !s.validToken(token) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func unauthenticated(next http.Handler) http.Handler {
+	return next
+}
+
+func (s *Server) validToken(token string) bool {
+	return s.bearerToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.bearerToken)) == 1
+}
+
+func (s *Server) handleReservations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.respond(w, http.StatusOK, &ReservationsResponse{Reserved: s.reserved()})
+	case http.MethodPost:
+		var req ReservationRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid reservation: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := req.validate(); err != nil {
+			http.Error(w, fmt.Sprintf("invalid reservation: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		until := s.clock.Now().Add(time.Duration(req.Minutes) * time.Minute)
+		s.logger.Info("Reservation requested", "runners", req.Runners, "minutes", req.Minutes, "remoteAddr", r.RemoteAddr)
+		s.reserve(req.Runners, until)
+		s.respond(w, http.StatusCreated, &ReservationsResponse{Until: &until, Reserved: s.reserved()})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *ReservationRequest) validate() error {
+	if r.Runners <= 0 {
+		return fmt.Errorf("runners %d must be positive", r.Runners)
+	}
+	if r.Minutes <= 0 || r.Minutes > MaxReservationMinutes {
+		return fmt.Errorf("minutes %d must be between 1 and %d", r.Minutes, MaxReservationMinutes)
+	}
+	return nil
+}
+
+func (s *Server) respond(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Error(err, "failed to write admin response")
+	}
+}
+
+// ListenAndServe serves the admin API on the address and on the socket until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	if s.srv.Addr != "" {
+		g.Go(func() error {
+			s.logger.Info("starting admin server", "addr", s.srv.Addr, "tls", s.tlsCertFile != "")
+			return s.serve(ctx, s.srv, func() (net.Listener, error) {
+				return net.Listen("tcp", s.srv.Addr)
+			})
+		})
+	}
+	if s.socketSrv != nil {
+		g.Go(func() error {
+			s.logger.Info("starting admin server", "socket", s.socket)
+			return s.serve(ctx, s.socketSrv, func() (net.Listener, error) {
+				return s.workDir.Listen(s.socket)
+			})
+		})
+	}
+	return g.Wait()
+}
+
+func (s *Server) serve(ctx context.Context, srv *http.Server, listen func() (net.Listener, error)) error {
+	ln, err := listen()
+	if err != nil {
+		return errcode.Wrap(errcode.AdminServer, err)
+	}
+	go func() {
+		<-ctx.Done()
+		s.logger.Info("stopping admin server", "err", ctx.Err())
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	serve := srv.Serve
+	if srv == s.srv && s.tlsCertFile != "" {
+		serve = func(ln net.Listener) error {
+			return srv.ServeTLS(ln, s.tlsCertFile, s.tlsKeyFile)
+		}
+	}
+	if err := serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errcode.Wrap(errcode.AdminServer, err)
+	}
+	return nil
+}
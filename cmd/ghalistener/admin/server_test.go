@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestServer_Reservations(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	var reserved int
+	var until time.Time
+	server := NewServer(ServerConfig{
+		BearerToken: "token",
+		Reserve: func(runners int, u time.Time) {
+			reserved += runners
+			until = u
+		},
+		Reserved: func() int { return reserved },
+		Clock:    fakeClock,
+		Logger:   logr.Discard(),
+	})
+
+	serve := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, ReservationsPath, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "", `{"runners": 5, "minutes": 30}`).Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "other", "").Code)
+		assert.Zero(t, reserved)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"runners": 0, "minutes": 30}`,
+			`{"runners": 5, "minutes": 0}`,
+			`{"runners": 5, "minutes": 1441}`,
+			`{"runners": "5"}`,
+		} {
+			assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "token", body).Code, body)
+		}
+		assert.Zero(t, reserved)
+	})
+
+	t.Run("Reserves", func(t *testing.T) {
+		rec := serve(http.MethodPost, "token", `{"runners": 5, "minutes": 30}`)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, 5, reserved)
+		assert.Equal(t, fakeClock.Now().Add(30*time.Minute), until)
+
+		var resp ReservationsResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, 5, resp.Reserved)
+		require.NotNil(t, resp.Until)
+		assert.True(t, until.Equal(*resp.Until))
+
+		rec = serve(http.MethodGet, "token", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		resp = ReservationsResponse{}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, 5, resp.Reserved)
+		assert.Nil(t, resp.Until)
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodDelete, "token", "").Code)
+	})
+}
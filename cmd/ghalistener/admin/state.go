@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+)
+
+// StatePath is the path of the state endpoint, which exports the durable scaling state of the listener
+// on GET and imports a state exported by another listener of the scale set on PUT, e.g. to move a scale set
+// to a standby cluster:
+//
+//	curl -H "Authorization: Bearer $TOKEN" http://<primary>:<port>/state > state.json
+//	curl -H "Authorization: Bearer $TOKEN" -X PUT --data-binary @state.json http://<standby>:<port>/state
+const StatePath = "/state"
+
+// maxStateBytes bounds the size of the imported states.
+const maxStateBytes = 64 << 10
+
+// StateResponse is the body of the responses of the state endpoint: the durable scaling state, which is
+// imported back as is, along with the live state of the listener, which is ignored by the imports.
+type StateResponse struct {
+	worker.Snapshot
+	// TargetRunners is the runners of the last patch, -1 before the first one.
+	TargetRunners int `json:"targetRunners"`
+	// Session is the message session of the listener, if one is established.
+	Session *listener.SessionInfo `json:"session,omitempty"`
+	// LastErrors are the most recent errors logged by the listener, oldest first.
+	LastErrors []Error `json:"lastErrors,omitempty"`
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.respond(w, http.StatusOK, s.stateResponse())
+	case http.MethodPut:
+		var snapshot worker.Snapshot
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxStateBytes)).Decode(&snapshot); err != nil {
+			http.Error(w, fmt.Sprintf("invalid state: %v", err), http.StatusBadRequest)
+			return
+		}
+		s.logger.Info("State import requested", "exportedAt", snapshot.ExportedAt, "remoteAddr", r.RemoteAddr)
+		if err := s.importState(snapshot); err != nil {
+			http.Error(w, fmt.Sprintf("invalid state: %v", err), http.StatusBadRequest)
+			return
+		}
+		s.respond(w, http.StatusOK, s.stateResponse())
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) stateResponse() *StateResponse {
+	resp := &StateResponse{
+		Snapshot:      s.exportState(),
+		TargetRunners: -1,
+	}
+	if s.targetRunners != nil {
+		resp.TargetRunners = s.targetRunners()
+	}
+	if s.session != nil {
+		resp.Session = s.session()
+	}
+	if s.errors != nil {
+		resp.LastErrors = s.errors.Errors()
+	}
+	return resp
+}
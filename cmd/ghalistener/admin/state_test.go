@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_State(t *testing.T) {
+	state := worker.Snapshot{Version: worker.SnapshotVersion, PatchSeq: 3}
+	server := NewServer(ServerConfig{
+		BearerToken: "token",
+		ExportState: func() worker.Snapshot { return state },
+		ImportState: func(s worker.Snapshot) error {
+			if s.Version != worker.SnapshotVersion {
+				return errors.New("unsupported version")
+			}
+			state.PatchSeq = max(state.PatchSeq, s.PatchSeq)
+			return nil
+		},
+		Logger: logr.Discard(),
+	})
+
+	serve := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, StatePath, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPut, "other", `{"version": 1, "patchSeq": 10}`).Code)
+		assert.Equal(t, 3, state.PatchSeq)
+	})
+
+	t.Run("Exports", func(t *testing.T) {
+		rec := serve(http.MethodGet, "token", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp worker.Snapshot
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, 3, resp.PatchSeq)
+	})
+
+	t.Run("Imports", func(t *testing.T) {
+		rec := serve(http.MethodPut, "token", `{"version": 1, "patchSeq": 10}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp worker.Snapshot
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, 10, resp.PatchSeq, "the merged state is returned")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"version": 2, "patchSeq": 20}`,
+			`{"patchSeq": "20"}`,
+		} {
+			assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, "token", body).Code, body)
+		}
+		assert.Equal(t, 10, state.PatchSeq)
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodPost, "token", "{}").Code)
+	})
+}
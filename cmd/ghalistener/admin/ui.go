@@ -0,0 +1,173 @@
+package admin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+)
+
+// UIPath is the path of the read-only status page of the listener, served to the requests presenting
+// the bearer token or the session cookie of a login. The other requests are shown the login form.
+const UIPath = "/"
+
+// UILoginPath is the path the login form of the status page posts the bearer token to.
+const UILoginPath = "/login"
+
+// uiSessionCookie is the cookie the browsers present in place of the bearer token once logged in, since
+// they cannot set the header on the page loads. It holds the expiry of the session and its signature with
+// the bearer token, so that rotating the token logs the browsers out.
+const uiSessionCookie = "listener-admin-session"
+
+// uiSessionDuration is the time a login lasts.
+const uiSessionDuration = 8 * time.Hour
+
+// uiContentSecurityPolicy only allows the inline styles of the pages and the login form.
+const uiContentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'"
+
+// Status is the state of the listener shown by the status page.
+type Status struct {
+	// ScaleSet is the namespace and the name of the scale set.
+	ScaleSet string
+	// Target is the resource the scaling decisions are applied to.
+	Target  string
+	Scaling worker.State
+	Jobs    []worker.Job
+	// Config summarizes the configuration of the listener, leaving out the credentials.
+	Config []Setting
+}
+
+// Setting is a setting of the configuration summary of the status page.
+type Setting struct {
+	Name  string
+	Value string
+}
+
+var (
+	//go:embed ui.html
+	uiHTML string
+	//go:embed login.html
+	loginHTML string
+)
+
+var loginTemplate = template.Must(template.New("login").Parse(loginHTML))
+
+var uiTemplate = template.Must(template.New("ui").Funcs(template.FuncMap{
+	"timestamp": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	},
+}).Parse(uiHTML))
+
+// uiPage is the data the status page is rendered from.
+type uiPage struct {
+	Status
+	Now time.Time
+	// Decisions are the recent scaling decisions, the most recent first.
+	Decisions []worker.Decision
+}
+
+func (s *Server) handleUI(w http.ResponseWriter, r *http.Request) {
+	status := s.status()
+	page := uiPage{
+		Status:    status,
+		Now:       s.clock.Now(),
+		Decisions: slices.Clone(status.Scaling.RecentDecisions),
+	}
+	slices.Reverse(page.Decisions)
+
+	s.render(w, http.StatusOK, uiTemplate, &page)
+}
+
+// uiAuthenticated shows the login form to the requests which present neither the bearer token
+// nor the cookie of a session.
+func (s *Server) uiAuthenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && s.validToken(token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cookie, err := r.Cookie(uiSessionCookie); err == nil && s.validSession(cookie.Value) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.render(w, http.StatusUnauthorized, loginTemplate, &loginPage{})
+	})
+}
+
+// loginPage is the data the login form is rendered from.
+type loginPage struct {
+	Failed bool
+}
+
+// handleUILogin starts a session for the browser posting the bearer token with the login form.
+func (s *Server) handleUILogin(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	if err := r.ParseForm(); err != nil || !s.validToken(r.PostForm.Get("token")) {
+		s.logger.Info("Status page login failed", "remoteAddr", r.RemoteAddr)
+		s.render(w, http.StatusUnauthorized, loginTemplate, &loginPage{Failed: true})
+		return
+	}
+
+	expiry := s.clock.Now().Add(uiSessionDuration)
+	http.SetCookie(w, &http.Cookie{
+		Name:     uiSessionCookie,
+		Value:    s.sessionCookie(expiry),
+		Path:     UIPath,
+		Expires:  expiry,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	s.logger.Info("Status page login", "remoteAddr", r.RemoteAddr)
+	http.Redirect(w, r, UIPath, http.StatusSeeOther)
+}
+
+// sessionCookie returns the value of the session cookie expiring at the time.
+func (s *Server) sessionCookie(expiry time.Time) string {
+	unix := strconv.FormatInt(expiry.Unix(), 10)
+	return unix + "." + s.sessionSignature(unix)
+}
+
+func (s *Server) sessionSignature(expiry string) string {
+	mac := hmac.New(sha256.New, []byte(s.bearerToken))
+	mac.Write([]byte(uiSessionCookie + ":" + expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validSession reports whether the cookie value was signed with the bearer token and is not expired.
+func (s *Server) validSession(value string) bool {
+	expiry, signature, ok := strings.Cut(value, ".")
+	if !ok || s.bearerToken == "" || !hmac.Equal([]byte(signature), []byte(s.sessionSignature(expiry))) {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	return err == nil && s.clock.Now().Before(time.Unix(unix, 0))
+}
+
+func (s *Server) render(w http.ResponseWriter, status int, tmpl *template.Template, data any) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		s.logger.Error(err, "failed to render the status page")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", uiContentSecurityPolicy)
+	w.WriteHeader(status)
+	w.Write(b.Bytes())
+}
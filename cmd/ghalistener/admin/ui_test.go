@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestServer_UI(t *testing.T) {
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	server := NewServer(ServerConfig{
+		BearerToken: "token",
+		Reserved:    func() int { return 0 },
+		Status: func() Status {
+			return Status{
+				ScaleSet: "arc-runners/arc-runner-set",
+				Target:   "ephemeralrunnersets arc-runners/arc-runner-set",
+				Scaling: worker.State{
+					MinRunners: 1,
+					MaxRunners: 10,
+					LastPatch:  3,
+					RecentDecisions: []worker.Decision{
+						{Time: now.Add(-time.Minute), Replicas: 2, Reason: worker.ScaleReasonAssignedJobs},
+						{Time: now, Replicas: 3, Reason: worker.ScaleReasonMaxRunners, Clamps: []string{worker.ClampMaxRunners}},
+					},
+				},
+				Jobs: []worker.Job{
+					{ID: "1", Name: "<script>build</script>", Repository: "octo-org/octo-repo", QueueTime: now},
+				},
+				Config: []Setting{{Name: "Min runners", Value: "1"}},
+			}
+		},
+		Clock:  clocktesting.NewFakeClock(now),
+		Logger: logr.Discard(),
+	})
+
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, UIPath, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<form method="post" action="login">`, "the login form is shown")
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/other", "token").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodPost, UIPath, "token").Code)
+
+	rec = serve(http.MethodGet, UIPath, "token")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "ephemeralrunnersets arc-runners/arc-runner-set")
+	assert.Contains(t, body, "<tr><th>Desired runners</th><td>3</td></tr>")
+	assert.Contains(t, body, "&lt;script&gt;build&lt;/script&gt;", "the job names are escaped")
+	assert.NotContains(t, body, "<script>")
+	assert.Less(t, strings.Index(body, worker.ScaleReasonMaxRunners), strings.Index(body, worker.ScaleReasonAssignedJobs), "the most recent decisions come first")
+	assert.Contains(t, body, "<tr><th>Min runners</th><td>1</td></tr>")
+}
+
+func TestServer_UILogin(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Now())
+	newServer := func(token string) *Server {
+		return NewServer(ServerConfig{
+			BearerToken: token,
+			Reserved:    func() int { return 0 },
+			Status:      func() Status { return Status{ScaleSet: "arc-runners/arc-runner-set"} },
+			Clock:       clock,
+			Logger:      logr.Discard(),
+		})
+	}
+	server := newServer("token")
+
+	login := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, UILoginPath, strings.NewReader(url.Values{"token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(server *Server, cookies ...*http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet, UIPath, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	rec := login("wrong")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "The token is invalid.")
+	assert.Empty(t, rec.Result().Cookies())
+
+	rec = login("token")
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, UIPath, rec.Header().Get("Location"))
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+
+	assert.Equal(t, http.StatusOK, get(server, cookies...), "the session cookie is accepted in place of the bearer token")
+	assert.Equal(t, http.StatusUnauthorized, get(server, &http.Cookie{Name: uiSessionCookie, Value: cookies[0].Value + "0"}))
+	assert.Equal(t, http.StatusUnauthorized, get(newServer("rotated"), cookies...), "rotating the token ends the sessions")
+
+	clock.Step(uiSessionDuration)
+	assert.Equal(t, http.StatusUnauthorized, get(server, cookies...), "the session expires")
+}
+
+func TestServer_UIDisabled(t *testing.T) {
+	server := NewServer(ServerConfig{BearerToken: "token", Logger: logr.Discard()})
+
+	req := httptest.NewRequest(http.MethodGet, UIPath, nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	server.srv.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -1,29 +1,116 @@
 package app
 
 import (
+	"cmp"
 	"context"
+	"crypto"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/admin"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/audit"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/debugserver"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/gops"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/health"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/kedascaler"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/notify"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/telemetry"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
 	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/actions/actions-runner-controller/vault"
 	"github.com/go-logr/logr"
 	"golang.org/x/sync/errgroup"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/utils/clock"
 )
 
+// errMaxUptimeReached is the cause of the listener being stopped once the maximum uptime elapsed.
+var errMaxUptimeReached = errors.New("maximum uptime reached")
+
+// errPreStopRequested is the cause of the listener being stopped by the pre-stop endpoint.
+var errPreStopRequested = errors.New("pre-stop requested")
+
 // App is responsible for initializing required components and running the app.
 type App struct {
 	// configured fields
 	config *config.Config
 	logger logr.Logger
+	clock  clock.WithTickerAndDelayedExecution
 
 	// initialized fields
 	listener Listener
 	worker   Worker
 	metrics  metrics.ServerExporter
+	health   *health.Server
+	gops     *gops.Agent
+	// debug serves the pprof profiles and the expvar variables, if configured.
+	debug *debugserver.Server
+	// kedaScaler serves the desired runner count to KEDA, if configured.
+	kedaScaler *kedascaler.Server
+	// admin serves the admin API, if configured.
+	admin *admin.Server
+	// errorLog keeps the recent errors for the admin API, if configured.
+	errorLog *admin.ErrorLog
+	// healthStatus is the status served by health, nil if the health server is disabled.
+	healthStatus *health.Status
+	// leaderElection is set when the listener runs as one of redundant replicas.
+	leaderElection *leaderelection.LeaderElectionConfig
+	client         *rotatingClient
+	// rateLimits delays the requests of the successive actions clients while GitHub rate limits them.
+	rateLimits *rateLimiter
+	vault      vault.Vault
+	// credentialsFiles is the AppConfig of the config without its credentials, whose files are re-read
+	// to rotate the credentials, if the credentials are read from files.
+	credentialsFiles *appconfig.AppConfig
+	workDir          *workdir.Dir
+	// messageExecutor runs the job started handlers of the listener, if set.
+	messageExecutor listener.Executor
+	// kubeConfig configures the Kubernetes clients in place of the in-cluster config, if set.
+	kubeConfig *rest.Config
+	// runnerLimits applies the min and max runners of a ConfigMap to the worker, if configured.
+	runnerLimits *runnerLimitsWatcher
+	// telemetry reports the anonymized usage statistics, if opted in.
+	telemetry *telemetry.Reporter
+	// capacityForecaster exports the capacity forecasts, if configured.
+	capacityForecaster *capacityForecaster
+	// auditSink posts the audit records to an endpoint, if configured.
+	auditSink *audit.HTTPSink
+	// notifier posts the completed jobs to a webhook, if configured.
+	notifier *notify.Notifier
+	// runnerCache watches the ephemeral runners the worker patches, if configured.
+	runnerCache *worker.RunnerCache
+	// watchPendingRunners checks the ephemeral runners stuck pending, if configured.
+	watchPendingRunners func(ctx context.Context) error
+	// stateExporter exports the durable scaling state of the worker, if configured.
+	stateExporter *stateExporter
+
+	// preStopRequested is closed once the pre-stop endpoint is called, to stop the listener.
+	preStopRequested chan struct{}
+	preStopOnce      sync.Once
+	// listenerStopped is closed once the listener returned.
+	listenerStopped chan struct{}
+	// terminationMessagePath is the file the idle termination message is written to, if set.
+	terminationMessagePath string
+
+	// credentialsDigest identifies the credentials the client was built with.
+	// The credentials themselves are scrubbed from the config once the client is built.
+	credentialsDigest [sha256.Size]byte
 }
 
 //go:generate mockery --name Listener --output ./mocks --outpkg mocks --case underscore
@@ -33,17 +120,59 @@ type Listener interface {
 
 //go:generate mockery --name Worker --output ./mocks --outpkg mocks --case underscore
 type Worker interface {
+	Backfill(ctx context.Context) error
+	HandleSessionCreated(ctx context.Context, session *actions.RunnerScaleSetSession, createdAt time.Time) error
+	HandleJobAssigned(ctx context.Context, jobInfo *actions.JobAssigned) error
 	HandleJobStarted(ctx context.Context, jobInfo *actions.JobStarted) error
+	HandleJobCompleted(ctx context.Context, jobInfo *actions.JobCompleted) error
 	HandleDesiredRunnerCount(ctx context.Context, count int, jobsCompleted int) (int, error)
+	State() worker.State
+}
+
+// Option configures the App.
+type Option func(*App)
+
+// WithLogger sets the logger of the app in place of the one of the log level and format of the config.
+func WithLogger(logger logr.Logger) Option {
+	return func(app *App) {
+		app.logger = logger
+	}
+}
+
+// WithMessageExecutor sets the executor the listener handles the job started messages on, e.g. a pool
+// shared by the listeners of a gateway.
+func WithMessageExecutor(executor listener.Executor) Option {
+	return func(app *App) {
+		app.messageExecutor = executor
+	}
+}
+
+// WithKubernetesConfig sets the config of the Kubernetes clients in place of the in-cluster config,
+// e.g. to run the listener out of the cluster in tests.
+func WithKubernetesConfig(conf *rest.Config) Option {
+	return func(app *App) {
+		app.kubeConfig = conf
+	}
 }
 
-func New(config config.Config) (*App, error) {
+func New(config config.Config, options ...Option) (*App, error) {
+	if err := config.LoadCredentialsFiles(); err != nil {
+		return nil, err
+	}
 	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("failed to validate config: %w", err)
+		return nil, errcode.Errorf(errcode.ConfigInvalid, "failed to validate config: %w", err)
 	}
 
 	app := &App{
-		config: &config,
+		config:           &config,
+		clock:            clock.RealClock{},
+		preStopRequested: make(chan struct{}),
+		listenerStopped:  make(chan struct{}),
+
+		terminationMessagePath: defaultTerminationMessagePath,
+	}
+	for _, option := range options {
+		option(app)
 	}
 
 	ghConfig, err := actions.ParseGitHubConfigFromURL(config.ConfigureUrl)
@@ -52,19 +181,71 @@ func New(config config.Config) (*App, error) {
 	}
 
 	{
-		logger, err := config.Logger()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create logger: %w", err)
+		logger := app.logger
+		if logger.GetSink() == nil {
+			logger, err = config.Logger()
+			if err != nil {
+				return nil, fmt.Errorf("failed to create logger: %w", err)
+			}
+		}
+		if config.AdminAddr != "" || config.AdminSocket != "" {
+			app.errorLog = admin.NewErrorLog(app.clock)
+			logger = app.errorLog.Wrap(logger)
+		}
+		if config.Telemetry != nil {
+			errorCounter := telemetry.NewErrorCounter()
+			logger = errorCounter.Wrap(logger)
+			app.telemetry = newTelemetryReporter(&config, ghConfig, errorCounter, logger.WithName("telemetry"))
 		}
 		app.logger = logger.WithName("listener-app")
 	}
 
-	actionsClient, err := config.ActionsClient(app.logger)
+	workDir, err := workdir.New(config.WorkDir)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.ConfigInvalid, "failed to configure work directory: %w", err)
+	}
+	app.workDir = workDir
+
+	proxyDecisions, err := config.ProxyDecisions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate proxy settings: %w", err)
+	}
+	for _, decision := range proxyDecisions {
+		proxy := decision.Proxy
+		if proxy == "" {
+			proxy = "direct"
+		}
+		app.logger.V(1).Info("Effective proxy for GitHub endpoint", "endpoint", decision.Endpoint, "proxy", proxy)
+	}
+
+	app.rateLimits = newRateLimiter(app.clock, app.logger.WithName("rate limiter"))
+	actionsClient, err := config.ActionsClient(app.logger, actions.WithTransport(app.rateLimits.wrap))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create actions client: %w", err)
 	}
+	app.client = newRotatingClient(actionsClient)
+	app.credentialsDigest = credentialsDigest(app.config.AppConfig)
+	if config.AppConfig != nil && config.AppConfig.HasFiles() {
+		app.credentialsFiles = &appconfig.AppConfig{
+			AppID:             config.AppConfig.AppID,
+			AppInstallationID: config.AppConfig.AppInstallationID,
+			AppPrivateKeyFile: config.AppConfig.AppPrivateKeyFile,
+			TokenFile:         config.AppConfig.TokenFile,
+		}
+	}
+	app.config.ScrubCredentials()
+
+	pushMetrics := config.Metrics != nil && config.Metrics.Push != nil
+	if config.MetricsAddr != "" || pushMetrics {
+		bearerToken, err := readCredentialsFile(config.MetricsBearerTokenFile)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.ConfigRead, "failed to read metrics bearer token: %w", err)
+		}
+		basicAuthPassword, err := readCredentialsFile(config.MetricsBasicAuthPasswordFile)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.ConfigRead, "failed to read metrics basic auth password: %w", err)
+		}
 
-	if config.MetricsAddr != "" {
 		app.metrics = metrics.NewExporter(metrics.ExporterConfig{
 			ScaleSetName:      config.EphemeralRunnerSetName,
 			ScaleSetNamespace: config.EphemeralRunnerSetNamespace,
@@ -75,36 +256,330 @@ func New(config config.Config) (*App, error) {
 			ServerEndpoint:    config.MetricsEndpoint,
 			Metrics:           config.Metrics,
 			Logger:            app.logger.WithName("metrics exporter"),
+			DisableServer:     config.MetricsAddr == "",
+			TLSCertFile:       config.MetricsTLSCertFile,
+			TLSKeyFile:        config.MetricsTLSKeyFile,
+			BearerToken:       bearerToken,
+			BasicAuthUsername: config.MetricsBasicAuthUsername,
+			BasicAuthPassword: basicAuthPassword,
+			ScaleSetID:        config.RunnerScaleSetId,
+			MinRunners:        config.MinRunners,
+			MaxRunners:        config.MaxRunners,
+			ConfigHash:        config.Hash(),
+		})
+		app.rateLimits.metrics = app.metrics
+	}
+
+	if config.GopsAddr != "" {
+		app.gops = gops.NewAgent(config.GopsAddr, app.logger.WithName("gops"))
+	}
+
+	if config.DebugAddr != "" {
+		app.debug = debugserver.NewServer(debugserver.ServerConfig{
+			Addr:   config.DebugAddr,
+			Logger: app.logger.WithName("debug server"),
 		})
 	}
 
-	worker, err := worker.New(
-		worker.Config{
-			EphemeralRunnerSetNamespace: config.EphemeralRunnerSetNamespace,
-			EphemeralRunnerSetName:      config.EphemeralRunnerSetName,
-			MaxRunners:                  config.MaxRunners,
-			MinRunners:                  config.MinRunners,
-		},
+	var healthStatus *health.Status
+	if config.HealthAddr != "" {
+		healthStatus = health.NewStatus(
+			app.clock,
+			health.WithReadinessThresholds(config.HealthReadinessFailureThreshold, config.HealthReadinessSuccessThreshold),
+		)
+		serverConfig := health.ServerConfig{
+			Addr:    config.HealthAddr,
+			Status:  healthStatus,
+			PreStop: app.preStop,
+			Logger:  app.logger.WithName("health server"),
+		}
+		if config.HealthStaleAfter != nil {
+			serverConfig.StaleAfter = config.HealthStaleAfter.Duration
+		}
+		app.health = health.NewServer(serverConfig)
+		app.healthStatus = healthStatus
+	}
+
+	config.ObserveVaultRequests(vault.ObserverFunc(app.observeVaultRequest))
+	if config.VaultRefreshInterval != nil {
+		// The vault is created once the metrics and the health status are, to observe its requests.
+		v, err := config.Vault(vault.ObserverFunc(app.observeVaultRequest))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vault client: %w", err)
+		}
+		app.vault = v
+	}
+
+	var clientset kubernetes.Interface
+	if config.LeaderElection != nil || config.RunnerLimitsConfigMap != nil || (config.StateExport != nil && config.StateExport.SecretName != "") {
+		clientset, err = newClientset(app.kubeConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.LeaderElection != nil {
+		leaderElection, err := leaderElectionConfig(clientset, config.LeaderElection, config.EphemeralRunnerSetNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure leader election: %w", err)
+		}
+		app.leaderElection = leaderElection
+	}
+
+	scheduledOverrides, err := workerScheduledOverrides(config.ScheduledOverrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduled overrides: %w", err)
+	}
+
+	workerConfig := worker.Config{
+		EphemeralRunnerSetNamespace: config.EphemeralRunnerSetNamespace,
+		EphemeralRunnerSetName:      config.EphemeralRunnerSetName,
+		MaxRunners:                  config.MaxRunners,
+		MinRunners:                  config.MinRunners,
+		MaxScaleUpStep:              config.MaxScaleUpStep,
+		MaxScaleDownStep:            config.MaxScaleDownStep,
+		ScheduledOverrides:          scheduledOverrides,
+		HardMinRunners:              config.HardMinRunners,
+		MaxRunnerMinutesPerDay:      config.MaxRunnerMinutesPerDay,
+		FallbackReplicas:            config.FallbackReplicas,
+		MissingRunnerPolicy:         worker.MissingRunnerPolicy(config.MissingRunnerPolicy),
+		JobPolicies:                 workerJobPolicies(config.JobPolicies),
+		PriorityRunners:             config.PriorityRunners,
+		PendingRunners:              workerPendingRunners(config.PendingRunners),
+	}
+	if config.StaleRunnerGracePeriod != nil {
+		workerConfig.StaleRunnerGracePeriod = config.StaleRunnerGracePeriod.Duration
+	}
+	if config.IdleTimeout != nil {
+		workerConfig.IdleTimeout = config.IdleTimeout.Duration
+	}
+	if config.DuplicatePatchTTL != nil {
+		workerConfig.DuplicatePatchTTL = config.DuplicatePatchTTL.Duration
+	}
+	if config.Migration != nil {
+		workerConfig.Migration = workerMigration(config.Migration)
+	}
+	if config.Canary != nil {
+		workerConfig.Canary = workerCanary(config.Canary)
+	}
+	if config.TopologySpread != nil {
+		workerConfig.TopologySpread = workerTopologySpread(config.TopologySpread, config.EphemeralRunnerSetName)
+	}
+
+	workerOptions := []worker.Option{
 		worker.WithLogger(app.logger.WithName("worker")),
-	)
+		worker.WithClock(app.clock),
+		worker.WithHealth(healthStatus),
+	}
+	if app.metrics != nil {
+		workerOptions = append(workerOptions, worker.WithMetrics(app.metrics))
+	}
+	if config.Predictor != nil {
+		predictor, err := newPredictor(config.Predictor, app.workDir, app.logger.WithName("predictor"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create predictor: %w", err)
+		}
+		workerOptions = append(workerOptions, worker.WithPredictor(predictor))
+	}
+	if config.ScaleTarget != nil {
+		target, err := newScaleTarget(config.ScaleTarget, config.EphemeralRunnerSetNamespace, config.RunnerScaleSetId, config.RunnerScaleSetName, app.kubeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create scale target: %w", err)
+		}
+		workerOptions = append(workerOptions, worker.WithScaleTarget(target))
+	} else {
+		// The ephemeral runners and the ephemeral runner set are left untouched with a scale target.
+		resources, err := discoverResources(app.kubeConfig)
+		if err != nil {
+			return nil, err
+		}
+		app.logger.Info("Discovered the ephemeral runner resources", "version", resources.EphemeralRunnerSets.Version)
+		workerOptions = append(workerOptions, worker.WithResources(resources))
+
+		if config.EphemeralRunnerCache {
+			app.runnerCache, err = newRunnerCache(app.kubeConfig, config.EphemeralRunnerSetNamespace, resources.EphemeralRunners)
+			if err != nil {
+				return nil, err
+			}
+			workerOptions = append(workerOptions, worker.WithRunnerCache(app.runnerCache))
+		}
+	}
+
+	if app.kubeConfig != nil {
+		client, err := dynamic.NewForConfig(app.kubeConfig)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.KubernetesClient, "failed to create kubernetes client: %w", err)
+		}
+		workerOptions = append(workerOptions, worker.WithClient(client))
+	}
+
+	worker, err := worker.New(workerConfig, workerOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new kubernetes worker: %w", err)
 	}
 	app.worker = worker
 
+	if config.KedaScalerAddr != "" {
+		app.kedaScaler = kedascaler.NewServer(kedascaler.ServerConfig{
+			Addr:       config.KedaScalerAddr,
+			TargetSize: config.KedaScalerTargetSize,
+			DesiredRunners: func() int {
+				return worker.State().LastPatch
+			},
+			Logger: app.logger.WithName("keda scaler"),
+		})
+	}
+
+	if config.PendingRunners != nil {
+		app.watchPendingRunners = worker.WatchPendingRunners
+	}
+
+	if config.CapacityForecast != nil {
+		app.capacityForecaster = newCapacityForecaster(
+			config.CapacityForecast,
+			worker.Forecast,
+			config.RunnerScaleSetId,
+			config.RunnerScaleSetName,
+			app.metrics,
+			app.clock,
+			app.logger.WithName("capacity forecast"),
+		)
+	}
+
+	if config.StateExport != nil {
+		app.stateExporter = newStateExporter(
+			config.StateExport,
+			app.workDir,
+			clientset,
+			config.EphemeralRunnerSetNamespace,
+			worker.Snapshot,
+			worker.Restore,
+			app.clock,
+			app.logger.WithName("state export"),
+		)
+	}
+
+	if config.RunnerLimitsConfigMap != nil {
+		app.runnerLimits = newRunnerLimitsWatcher(
+			clientset,
+			config.RunnerLimitsConfigMap,
+			config.EphemeralRunnerSetNamespace,
+			config.MinRunners,
+			config.MaxRunners,
+			worker.SetRunnerLimits,
+			app.logger.WithName("runner limits"),
+		)
+	}
+
+	var drainTimeout time.Duration
+	if config.DrainTimeout != nil {
+		drainTimeout = config.DrainTimeout.Duration
+	}
+
+	var fallbackAfter time.Duration
+	var fallback listener.Fallback
+	if config.FallbackAfter != nil {
+		fallbackAfter = config.FallbackAfter.Duration
+		fallback = worker
+	}
+
+	var sessionStore listener.SessionStore
+	if config.ResumeSession {
+		sessionStore = worker
+	}
+
+	var deadLetter io.Writer
+	if config.DeadLetterFile != "" {
+		deadLetter, err = app.workDir.AppendWriter(config.DeadLetterFile)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.ConfigInvalid, "failed to configure dead letter file: %w", err)
+		}
+	}
+
+	var publisher metrics.Publisher
+	if app.metrics != nil {
+		publisher = app.metrics
+	}
+
+	var auditLog *audit.Log
+	if config.Audit != nil {
+		auditLog, app.auditSink, err = newAuditLog(config.Audit, app.workDir, audit.ScaleSet{
+			ID:        config.RunnerScaleSetId,
+			Name:      config.RunnerScaleSetName,
+			Namespace: config.EphemeralRunnerSetNamespace,
+		}, ghConfig.ConfigURL, app.clock, app.logger.WithName("audit"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.JobNotifications != nil {
+		app.notifier = newNotifier(config.JobNotifications, ghConfig.ConfigURL, notify.ScaleSet{
+			Name:      config.RunnerScaleSetName,
+			Namespace: config.EphemeralRunnerSetNamespace,
+		}, app.logger.WithName("notifier"))
+	}
+
 	listener, err := listener.New(listener.Config{
-		Client:     actionsClient,
+		Client:     newBreakerClient(app.client, app.clock, publisher, app.logger.WithName("circuit breaker")),
 		ScaleSetID: app.config.RunnerScaleSetId,
 		MinRunners: app.config.MinRunners,
 		MaxRunners: app.config.MaxRunners,
 		Logger:     app.logger.WithName("listener"),
 		Metrics:    app.metrics,
+
+		MessageConcurrency: app.config.MessageConcurrency,
+		Clock:              app.clock,
+		Health:             healthStatus,
+		DrainTimeout:       drainTimeout,
+		SessionStore:       sessionStore,
+		DeadLetter:         deadLetter,
+		Executor:           app.messageExecutor,
+		FallbackAfter:      fallbackAfter,
+		Fallback:           fallback,
+		Audit:              auditLog,
+		Notifier:           app.notifier,
+		MisroutedJobPolicy: listener.MisroutedJobPolicy(config.MisroutedJobPolicy),
+		MisroutedJobs:      worker,
+
+		SessionStartupTimeout: app.sessionStartupTimeout(),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new listener: %w", err)
 	}
 	app.listener = listener
 
+	if config.AdminAddr != "" || config.AdminSocket != "" {
+		var bearerToken string
+		if config.AdminAddr != "" {
+			bearerToken, err = readCredentialsFile(config.AdminBearerTokenFile)
+			if err != nil {
+				return nil, errcode.Errorf(errcode.ConfigRead, "failed to read admin bearer token: %w", err)
+			}
+		}
+		app.admin = admin.NewServer(admin.ServerConfig{
+			Addr:             config.AdminAddr,
+			BearerToken:      bearerToken,
+			TLSCertFile:      config.AdminTLSCertFile,
+			TLSKeyFile:       config.AdminTLSKeyFile,
+			Socket:           config.AdminSocket,
+			WorkDir:          app.workDir,
+			Reserve:          worker.Reserve,
+			Reserved:         worker.Reserved,
+			Status:           adminStatus(&config, worker.State, worker.Jobs),
+			ExportState:      worker.Snapshot,
+			ImportState:      worker.Restore,
+			TargetRunners:    func() int { return worker.State().LastPatch },
+			Session:          listener.Session,
+			Errors:           app.errorLog,
+			RefreshSession:   listener.RequestSessionRefresh,
+			ForceScale:       worker.ForceScale,
+			CancelForceScale: worker.CancelForceScale,
+			Clock:            app.clock,
+			Logger:           app.logger.WithName("admin"),
+		})
+	}
+
 	app.logger.Info("app initialized")
 
 	return app, nil
@@ -125,9 +600,62 @@ func (app *App) Run(ctx context.Context) error {
 	g, ctx := errgroup.WithContext(ctx)
 	metricsCtx, cancelMetrics := context.WithCancelCause(ctx)
 
+	listenerCtx, cancelListener := context.WithCancelCause(ctx)
+	defer cancelListener(nil)
+
+	if app.config != nil && app.config.MaxUptime != nil {
+		maxUptime := app.config.MaxUptime.Duration
+		timer := app.clock.AfterFunc(maxUptime, func() {
+			app.logger.Info("Maximum uptime reached, stopping the listener", "maxUptime", maxUptime)
+			cancelListener(errMaxUptimeReached)
+		})
+		defer timer.Stop()
+	}
+
+	if app.config != nil && app.config.IdleExitAfter != nil {
+		go app.stopWhenIdle(listenerCtx, app.config.IdleExitAfter.Duration, cancelListener)
+	}
+
+	if app.preStopRequested != nil {
+		go func() {
+			select {
+			case <-app.preStopRequested:
+				app.logger.Info("Pre-stop requested, stopping the listener")
+				cancelListener(errPreStopRequested)
+			case <-listenerCtx.Done():
+			}
+		}()
+	}
+
 	g.Go(func() error {
-		app.logger.Info("Starting listener")
-		listnerErr := app.listener.Listen(ctx, app.worker)
+		listnerErr := app.supervise(listenerCtx, "listener", func(ctx context.Context) error {
+			return app.listen(ctx, cancelListener)
+		})
+		if listnerErr != nil {
+			switch cause := context.Cause(listenerCtx); {
+			case errors.Is(cause, errMaxUptimeReached):
+				// The listener deleted its message session on the way out, so the listener pod
+				// the controller creates as a replacement can take over right away.
+				app.logger.Info("Listener stopped after reaching the maximum uptime", "error", listnerErr.Error())
+				listnerErr = nil
+			case errors.Is(cause, errIdle):
+				// The runners are scaled down to zero and the message session is deleted,
+				// the listener pod is recreated by the controller once jobs are acquirable.
+				app.logger.Info("Listener stopped after the scale set was idle", "error", listnerErr.Error())
+				app.recordIdleExit()
+				listnerErr = nil
+			case errors.Is(cause, errPreStopRequested):
+				// The pod is being terminated, the listener drained the same way it does on SIGTERM.
+				app.logger.Info("Listener stopped by the pre-stop hook", "error", listnerErr.Error())
+				listnerErr = nil
+			case errors.Is(cause, errLeadershipLost):
+				// Another replica may hold the lease already, the pod is restarted to stand by.
+				listnerErr = errcode.Errorf(errcode.LeaderElection, "%w: %w", errLeadershipLost, listnerErr)
+			}
+		}
+		if app.listenerStopped != nil {
+			close(app.listenerStopped)
+		}
 		cancelMetrics(fmt.Errorf("Listener exited: %w", listnerErr))
 		return listnerErr
 	})
@@ -139,5 +667,402 @@ func (app *App) Run(ctx context.Context) error {
 		})
 	}
 
+	if app.health != nil {
+		g.Go(func() error {
+			app.logger.Info("Starting health server")
+			return app.health.ListenAndServe(metricsCtx)
+		})
+	}
+
+	if app.gops != nil {
+		g.Go(func() error {
+			app.logger.Info("Starting gops agent")
+			return app.gops.ListenAndServe(metricsCtx)
+		})
+	}
+
+	if app.debug != nil {
+		g.Go(func() error {
+			app.logger.Info("Starting debug server")
+			return app.debug.ListenAndServe(metricsCtx)
+		})
+	}
+
+	if app.kedaScaler != nil {
+		g.Go(func() error {
+			app.logger.Info("Starting KEDA external scaler")
+			return app.supervise(metricsCtx, "keda-scaler", app.kedaScaler.ListenAndServe)
+		})
+	}
+
+	if app.admin != nil {
+		g.Go(func() error {
+			app.logger.Info("Starting admin server")
+			return app.supervise(metricsCtx, "admin", app.admin.ListenAndServe)
+		})
+	}
+
+	g.Go(func() error {
+		app.dumpStateOnSignal(metricsCtx)
+		return nil
+	})
+
+	if app.vault != nil || app.credentialsFiles != nil {
+		g.Go(func() error {
+			app.logger.Info("Starting credentials rotation", "interval", app.credentialsRefreshInterval())
+			return app.supervise(metricsCtx, "credentials-rotation", func(ctx context.Context) error {
+				app.rotateCredentials(ctx)
+				return nil
+			})
+		})
+	}
+
+	if app.runnerLimits != nil {
+		g.Go(func() error {
+			app.logger.Info("Watching the runner limits ConfigMap", "namespace", app.runnerLimits.namespace, "name", app.runnerLimits.name)
+			return app.supervise(metricsCtx, "runner-limits", app.runnerLimits.run)
+		})
+	}
+
+	if app.runnerCache != nil {
+		g.Go(func() error {
+			return app.supervise(metricsCtx, "runner-cache", app.runnerCache.Run)
+		})
+	}
+
+	if app.watchPendingRunners != nil {
+		g.Go(func() error {
+			return app.supervise(metricsCtx, "pending-runners", app.watchPendingRunners)
+		})
+	}
+
+	if app.stateExporter != nil {
+		g.Go(func() error {
+			return app.supervise(metricsCtx, "state-export", app.stateExporter.run)
+		})
+	}
+
+	if app.capacityForecaster != nil {
+		g.Go(func() error {
+			return app.supervise(metricsCtx, "capacity-forecast", app.capacityForecaster.run)
+		})
+	}
+
+	if app.auditSink != nil {
+		g.Go(func() error {
+			return app.supervise(metricsCtx, "audit", func(ctx context.Context) error {
+				app.auditSink.Run(ctx)
+				return nil
+			})
+		})
+	}
+
+	if app.notifier != nil {
+		g.Go(func() error {
+			return app.supervise(metricsCtx, "job-notifications", func(ctx context.Context) error {
+				app.notifier.Run(ctx)
+				return nil
+			})
+		})
+	}
+
+	if app.telemetry != nil {
+		g.Go(func() error {
+			return app.supervise(metricsCtx, "telemetry", func(ctx context.Context) error {
+				app.telemetry.Run(ctx)
+				return nil
+			})
+		})
+	}
+
 	return g.Wait()
 }
+
+// listen runs the listener once the replica is the leader, if leader election is enabled.
+func (app *App) listen(ctx context.Context, cancel context.CancelCauseFunc) error {
+	if app.leaderElection != nil {
+		release, err := app.lead(ctx, cancel)
+		if err != nil {
+			return errcode.Wrap(errcode.LeaderElection, err)
+		}
+		defer release()
+	}
+
+	// The state is imported once leading as well, since the leader it replaces may have exported it.
+	if app.stateExporter != nil {
+		app.stateExporter.start(ctx)
+	}
+
+	// Without the state of the cluster, the worker scales the set as if no job was running.
+	// The state is read once leading, since the leader it replaces may have scaled the set.
+	if err := app.worker.Backfill(ctx); err != nil {
+		app.logger.Error(err, "Failed to backfill the scaling state from the cluster")
+	}
+
+	app.logger.Info("Starting listener")
+	return app.listener.Listen(ctx, app.worker)
+}
+
+// preStop stops the listener and blocks until it drained and returned, or the context is cancelled.
+// It is called by the pre-stop hook of the listener pod, so that terminations initiated by Kubernetes,
+// such as node drains and evictions, are as graceful as stopping the listener with SIGTERM.
+func (app *App) preStop(ctx context.Context) error {
+	app.preStopOnce.Do(func() {
+		close(app.preStopRequested)
+	})
+
+	select {
+	case <-app.listenerStopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// workerScheduledOverrides converts the configured scheduled overrides into the worker representation,
+// moving the window times into the configured time zone.
+func workerScheduledOverrides(scheduledOverrides []config.ScheduledOverride) ([]worker.ScheduledOverride, error) {
+	overrides := make([]worker.ScheduledOverride, 0, len(scheduledOverrides))
+	for i, o := range scheduledOverrides {
+		loc := o.StartTime.Location()
+		if o.TimeZone != "" {
+			var err error
+			loc, err = time.LoadLocation(o.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("ScheduledOverrides[%d] has invalid TimeZone %q: %w", i, o.TimeZone, err)
+			}
+		}
+
+		override := worker.ScheduledOverride{
+			StartTime:  o.StartTime.In(loc),
+			EndTime:    o.EndTime.In(loc),
+			Frequency:  o.Frequency,
+			MinRunners: o.MinRunners,
+			MaxRunners: o.MaxRunners,
+			Name:       o.Name,
+		}
+		if o.UntilTime != nil {
+			override.UntilTime = o.UntilTime.In(loc)
+		}
+		overrides = append(overrides, override)
+	}
+
+	return overrides, nil
+}
+
+// workerPendingRunners converts the configured pending runner checks into the worker representation, nil if not configured.
+func workerPendingRunners(pendingRunners *config.PendingRunners) *worker.PendingRunners {
+	if pendingRunners == nil {
+		return nil
+	}
+	p := &worker.PendingRunners{
+		Threshold:   config.DefaultPendingRunnersThreshold,
+		Interval:    config.DefaultPendingRunnersInterval,
+		CapScaleUps: pendingRunners.CapScaleUps,
+	}
+	if pendingRunners.Threshold != nil {
+		p.Threshold = pendingRunners.Threshold.Duration
+	}
+	if pendingRunners.Interval != nil {
+		p.Interval = pendingRunners.Interval.Duration
+	}
+	return p
+}
+
+// workerJobPolicies converts the configured job policies into the worker representation.
+func workerJobPolicies(jobPolicies []config.JobPolicy) []worker.JobPolicy {
+	policies := make([]worker.JobPolicy, 0, len(jobPolicies))
+	for _, p := range jobPolicies {
+		policies = append(policies, worker.JobPolicy{
+			Name:       p.Name,
+			Repository: p.Repository,
+			Workflow:   p.Workflow,
+			Labels:     p.Labels,
+			Weight:     p.Weight,
+			MaxRunners: p.MaxRunners,
+			Priority:   p.Priority,
+		})
+	}
+	return policies
+}
+
+// workerMigration converts the configured migration into the worker representation, defaulting the percentage.
+func workerMigration(m *config.Migration) *worker.Migration {
+	migration := &worker.Migration{
+		TargetEphemeralRunnerSetName: m.TargetEphemeralRunnerSetName,
+		StartTime:                    m.StartTime,
+		Percentage:                   config.DefaultMigrationPercentage,
+	}
+	if m.RampDuration != nil {
+		migration.Duration = m.RampDuration.Duration
+	}
+	if m.Percentage != 0 {
+		migration.Percentage = m.Percentage
+	}
+	return migration
+}
+
+// workerCanary converts the configured canary into the worker representation, defaulting the settings not set.
+func workerCanary(c *config.Canary) *worker.Canary {
+	canary := &worker.Canary{
+		EphemeralRunnerSetName: c.EphemeralRunnerSetName,
+		Percentage:             c.Percentage,
+		MinJobs:                config.DefaultCanaryMinJobs,
+		MaxFailureRateIncrease: config.DefaultCanaryMaxFailureRateIncrease,
+	}
+	if c.MinJobs != 0 {
+		canary.MinJobs = c.MinJobs
+	}
+	if c.MaxFailureRateIncrease != nil {
+		canary.MaxFailureRateIncrease = *c.MaxFailureRateIncrease
+	}
+	return canary
+}
+
+// workerTopologySpread converts the configured topology spread into the worker representation,
+// defaulting the zones to the ephemeral runner set and a weight of 1.
+func workerTopologySpread(c *config.TopologySpread, ephemeralRunnerSetName string) *worker.TopologySpread {
+	spread := &worker.TopologySpread{
+		TopologyKey: c.TopologyKey,
+		Zones:       make([]worker.TopologyZone, 0, len(c.Zones)),
+	}
+	for _, zone := range c.Zones {
+		spread.Zones = append(spread.Zones, worker.TopologyZone{
+			Name:                   zone.Name,
+			EphemeralRunnerSetName: cmp.Or(zone.EphemeralRunnerSetName, ephemeralRunnerSetName),
+			Weight:                 cmp.Or(zone.Weight, 1),
+		})
+	}
+	return spread
+}
+
+// rotateCredentials periodically re-reads the GitHub App configuration from the vault,
+// or from the credentials files.
+// When the configuration changed, it rebuilds the actions client and swaps it in,
+// keeping the message session of the listener intact.
+// Failures are logged and retried on the next tick so that a temporarily unavailable
+// vault, or a secret volume being updated, does not bring the listener down.
+func (app *App) rotateCredentials(ctx context.Context) {
+	ticker := app.clock.NewTicker(app.credentialsRefreshInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		if err := app.refreshCredentials(ctx); err != nil {
+			app.logger.Error(err, "Failed to rotate credentials, will retry on next interval", "errorCode", errcode.CredentialRotation)
+		}
+	}
+}
+
+// observeVaultRequest exports the metrics of the vault requests, and reports the vault not ready once
+// its requests fail or are throttled past the readiness failure threshold.
+func (app *App) observeVaultRequest(vaultType vault.VaultType, operation string, duration time.Duration, err error) {
+	if app.metrics != nil {
+		app.metrics.PublishVaultRequest(vaultType.String(), operation, vault.RequestResult(err), duration)
+	}
+	app.healthStatus.RecordVaultRequest(err == nil)
+}
+
+func (app *App) sessionStartupTimeout() time.Duration {
+	if app.config.SessionStartupTimeout != nil {
+		return app.config.SessionStartupTimeout.Duration
+	}
+	return config.DefaultSessionStartupTimeout
+}
+
+func (app *App) credentialsRefreshInterval() time.Duration {
+	if app.vault != nil {
+		return app.config.VaultRefreshInterval.Duration
+	}
+	if app.config.CredentialsRefreshInterval != nil {
+		return app.config.CredentialsRefreshInterval.Duration
+	}
+	return config.DefaultCredentialsRefreshInterval
+}
+
+func (app *App) fetchCredentials(ctx context.Context) (*appconfig.AppConfig, error) {
+	if app.vault != nil {
+		return config.FetchAppConfig(ctx, app.vault, app.config.VaultLookupKey)
+	}
+	appConfig, err := app.credentialsFiles.ReadFiles()
+	if err != nil {
+		return nil, errcode.Errorf(errcode.ConfigRead, "failed to read credentials file: %w", err)
+	}
+	return appConfig, nil
+}
+
+func (app *App) refreshCredentials(ctx context.Context) error {
+	appConfig, err := app.fetchCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	updated := *app.config
+	updated.AppConfig = appConfig
+	defer updated.ScrubCredentials()
+
+	digest := credentialsDigest(appConfig)
+	if digest == app.credentialsDigest {
+		app.logger.Info("Credentials did not change, skipping client rotation")
+		return nil
+	}
+
+	if err := updated.Validate(); err != nil {
+		return errcode.Errorf(errcode.ConfigInvalid, "rotated config validation failed: %w", err)
+	}
+
+	actionsClient, err := updated.ActionsClient(app.logger, actions.WithTransport(app.rateLimits.wrap))
+	if err != nil {
+		return fmt.Errorf("failed to create actions client: %w", err)
+	}
+
+	app.client.swap(actionsClient)
+	app.credentialsDigest = digest
+
+	app.logger.Info("Credentials rotated")
+	return nil
+}
+
+// readCredentialsFile returns the content of the file without the trailing newline, or an empty string if no path is set.
+func readCredentialsFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// credentialsDigest returns a digest of the credentials, so that changes can be detected
+// without keeping the credentials around.
+func credentialsDigest(appConfig *appconfig.AppConfig) [sha256.Size]byte {
+	if appConfig == nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256([]byte(strings.Join([]string{
+		appConfig.AppID,
+		strconv.FormatInt(appConfig.AppInstallationID, 10),
+		appConfig.AppPrivateKey,
+		appConfig.Token,
+		signerID(appConfig.AppPrivateKeySigner),
+	}, "\x00")))
+}
+
+// signerID identifies the signer of the GitHub App JWTs, e.g. the version of a vault key, so that the
+// client is rebuilt when the key is rotated.
+func signerID(signer crypto.Signer) string {
+	if signer == nil {
+		return ""
+	}
+	if s, ok := signer.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%p", signer)
+}
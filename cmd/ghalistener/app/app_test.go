@@ -2,15 +2,30 @@ package app
 
 import (
 	"context"
+	"crypto"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
 	appmocks "github.com/actions/actions-runner-controller/cmd/ghalistener/app/mocks"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/health"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	listenermocks "github.com/actions/actions-runner-controller/cmd/ghalistener/listener/mocks"
 	metricsMocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"github.com/actions/actions-runner-controller/vault"
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
 )
 
 func TestApp_Run(t *testing.T) {
@@ -31,6 +46,7 @@ func TestApp_Run(t *testing.T) {
 	t.Run("ExitsOnListenerError", func(t *testing.T) {
 		listener := appmocks.NewListener(t)
 		worker := appmocks.NewWorker(t)
+		worker.On("Backfill", mock.Anything).Return(nil).Once()
 
 		listener.On("Listen", mock.Anything, mock.Anything).Return(errors.New("listener error")).Once()
 
@@ -46,6 +62,7 @@ func TestApp_Run(t *testing.T) {
 	t.Run("ExitsOnListenerNil", func(t *testing.T) {
 		listener := appmocks.NewListener(t)
 		worker := appmocks.NewWorker(t)
+		worker.On("Backfill", mock.Anything).Return(errors.New("backfill error")).Once()
 
 		listener.On("Listen", mock.Anything, mock.Anything).Return(nil).Once()
 
@@ -61,6 +78,7 @@ func TestApp_Run(t *testing.T) {
 	t.Run("CancelListenerOnMetricsServerError", func(t *testing.T) {
 		listener := appmocks.NewListener(t)
 		worker := appmocks.NewWorker(t)
+		worker.On("Backfill", mock.Anything).Return(nil).Once()
 		metrics := metricsMocks.NewServerPublisher(t)
 		ctx := context.Background()
 
@@ -82,4 +100,469 @@ func TestApp_Run(t *testing.T) {
 		err := app.Run(ctx)
 		assert.Error(t, err)
 	})
+
+	t.Run("ExitsCleanlyAfterMaxUptime", func(t *testing.T) {
+		listenerMock := appmocks.NewListener(t)
+		worker := appmocks.NewWorker(t)
+		worker.On("Backfill", mock.Anything).Return(nil).Once()
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+
+		listenerMock.On("Listen", mock.Anything, mock.Anything).Return(func(ctx context.Context, _ listener.Handler) error {
+			<-ctx.Done()
+			return fmt.Errorf("failed to get message: %w", ctx.Err())
+		}).Once()
+
+		app := &App{
+			config: &config.Config{
+				MaxUptime: &metav1.Duration{Duration: time.Hour},
+			},
+			logger:   logr.Discard(),
+			clock:    fakeClock,
+			listener: listenerMock,
+			worker:   worker,
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- app.Run(context.Background())
+		}()
+
+		require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+		fakeClock.Step(time.Hour)
+
+		select {
+		case err := <-errCh:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("app did not stop after the maximum uptime")
+		}
+	})
+}
+
+func TestApp_stopWhenIdle(t *testing.T) {
+	t.Parallel()
+
+	listenerMock := appmocks.NewListener(t)
+	w := appmocks.NewWorker(t)
+	w.On("Backfill", mock.Anything).Return(nil).Once()
+	w.On("State").Return(worker.State{LastPatch: 2, LastAssigned: 2}).Once()
+	w.On("State").Return(worker.State{LastPatch: 0, LastAssigned: 0})
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+
+	listenerMock.On("Listen", mock.Anything, mock.Anything).Return(func(ctx context.Context, _ listener.Handler) error {
+		<-ctx.Done()
+		return fmt.Errorf("failed to get message: %w", ctx.Err())
+	}).Once()
+
+	terminationMessagePath := filepath.Join(t.TempDir(), "termination-log")
+	app := &App{
+		config: &config.Config{
+			IdleExitAfter: &metav1.Duration{Duration: time.Minute},
+		},
+		logger:   logr.Discard(),
+		clock:    fakeClock,
+		listener: listenerMock,
+		worker:   w,
+
+		terminationMessagePath: terminationMessagePath,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- app.Run(context.Background())
+	}()
+
+	require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-errCh:
+			assert.NoError(t, err)
+			message, err := os.ReadFile(terminationMessagePath)
+			require.NoError(t, err)
+			assert.Equal(t, v1alpha1.AutoscalingListenerIdleTerminationMessage, string(message))
+			return
+		case <-time.After(10 * time.Millisecond):
+			fakeClock.Step(30 * time.Second)
+		case <-timeout:
+			t.Fatal("app did not stop after being idle")
+		}
+	}
+}
+
+func TestApp_supervise(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RestartsAfterPanic", func(t *testing.T) {
+		t.Parallel()
+
+		publisher := metricsMocks.NewServerPublisher(t)
+		publisher.On("PublishPanic", "listener").Once()
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+
+		app := &App{
+			logger:  logr.Discard(),
+			clock:   fakeClock,
+			metrics: publisher,
+		}
+
+		runs := 0
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- app.supervise(context.Background(), "listener", func(ctx context.Context) error {
+				runs++
+				if runs == 1 {
+					panic("listener failed")
+				}
+				return errors.New("listener error")
+			})
+		}()
+
+		require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+		fakeClock.Step(minPanicBackoff)
+
+		select {
+		case err := <-errCh:
+			assert.EqualError(t, err, "listener error")
+		case <-time.After(5 * time.Second):
+			t.Fatal("component was not restarted after the panic")
+		}
+		assert.Equal(t, 2, runs)
+	})
+
+	t.Run("StopsWhenContextIsDone", func(t *testing.T) {
+		t.Parallel()
+
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+		app := &App{
+			logger: logr.Discard(),
+			clock:  fakeClock,
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- app.supervise(ctx, "telemetry", func(ctx context.Context) error {
+				panic("telemetry failed")
+			})
+		}()
+
+		require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+		cancel()
+
+		select {
+		case err := <-errCh:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("component was restarted after the context was done")
+		}
+	})
+}
+
+func TestApp_preStop(t *testing.T) {
+	t.Parallel()
+
+	listenerMock := appmocks.NewListener(t)
+	worker := appmocks.NewWorker(t)
+	worker.On("Backfill", mock.Anything).Return(nil).Once()
+
+	listening := make(chan struct{})
+	listenerMock.On("Listen", mock.Anything, mock.Anything).Return(func(ctx context.Context, _ listener.Handler) error {
+		close(listening)
+		<-ctx.Done()
+		return fmt.Errorf("failed to get message: %w", ctx.Err())
+	}).Once()
+
+	app := &App{
+		logger:           logr.Discard(),
+		clock:            clocktesting.NewFakeClock(time.Now()),
+		listener:         listenerMock,
+		worker:           worker,
+		preStopRequested: make(chan struct{}),
+		listenerStopped:  make(chan struct{}),
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- app.Run(context.Background())
+	}()
+	<-listening
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, app.preStop(ctx))
+	// calling the hook again returns right away
+	require.NoError(t, app.preStop(ctx))
+
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("app did not stop after the pre-stop hook")
+	}
+}
+
+type fakeVault struct {
+	secret string
+	err    error
+}
+
+func (v *fakeVault) GetSecret(ctx context.Context, name string) (string, error) {
+	return v.secret, v.err
+}
+
+func TestApp_refreshCredentials(t *testing.T) {
+	t.Parallel()
+
+	newApp := func(v *fakeVault) *App {
+		return &App{
+			config: &config.Config{
+				ConfigureUrl:                "https://github.com/org",
+				EphemeralRunnerSetNamespace: "namespace",
+				EphemeralRunnerSetName:      "deployment",
+				RunnerScaleSetId:            1,
+				VaultType:                   vault.VaultTypeAzureKeyVault,
+				VaultLookupKey:              "key",
+			},
+			logger:            logr.Discard(),
+			client:            newRotatingClient(listenermocks.NewClient(t)),
+			vault:             v,
+			credentialsDigest: credentialsDigest(&appconfig.AppConfig{Token: "old"}),
+		}
+	}
+
+	t.Run("SwapsClientOnChange", func(t *testing.T) {
+		t.Parallel()
+		app := newApp(&fakeVault{secret: `{"github_token": "new"}`})
+		previous := app.client.current()
+
+		err := app.refreshCredentials(context.Background())
+		require.NoError(t, err)
+		assert.NotSame(t, previous, app.client.current())
+		assert.Equal(t, credentialsDigest(&appconfig.AppConfig{Token: "new"}), app.credentialsDigest)
+		assert.Nil(t, app.config.AppConfig, "credentials must not be kept in the config")
+	})
+
+	t.Run("KeepsClientWhenUnchanged", func(t *testing.T) {
+		t.Parallel()
+		app := newApp(&fakeVault{secret: `{"github_token": "old"}`})
+		previous := app.client.current()
+
+		err := app.refreshCredentials(context.Background())
+		require.NoError(t, err)
+		assert.Same(t, previous, app.client.current())
+	})
+
+	t.Run("KeepsClientOnVaultError", func(t *testing.T) {
+		t.Parallel()
+		app := newApp(&fakeVault{err: errors.New("vault unavailable")})
+		previous := app.client.current()
+
+		err := app.refreshCredentials(context.Background())
+		assert.Error(t, err)
+		assert.Same(t, previous, app.client.current())
+		assert.Equal(t, credentialsDigest(&appconfig.AppConfig{Token: "old"}), app.credentialsDigest)
+	})
+}
+
+func TestApp_refreshCredentialsFromFiles(t *testing.T) {
+	t.Parallel()
+
+	keyFile := filepath.Join(t.TempDir(), "github_token")
+	require.NoError(t, os.WriteFile(keyFile, []byte("old\n"), 0o600))
+
+	app := &App{
+		config: &config.Config{
+			ConfigureUrl:                "https://github.com/org",
+			EphemeralRunnerSetNamespace: "namespace",
+			EphemeralRunnerSetName:      "deployment",
+			RunnerScaleSetId:            1,
+		},
+		logger:            logr.Discard(),
+		client:            newRotatingClient(listenermocks.NewClient(t)),
+		credentialsFiles:  &appconfig.AppConfig{TokenFile: keyFile},
+		credentialsDigest: credentialsDigest(&appconfig.AppConfig{Token: "old"}),
+	}
+	previous := app.client.current()
+
+	require.NoError(t, app.refreshCredentials(context.Background()))
+	assert.Same(t, previous, app.client.current())
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("new\n"), 0o600))
+	require.NoError(t, app.refreshCredentials(context.Background()))
+	assert.NotSame(t, previous, app.client.current())
+	assert.Equal(t, credentialsDigest(&appconfig.AppConfig{Token: "new"}), app.credentialsDigest)
+	assert.Nil(t, app.config.AppConfig, "credentials must not be kept in the config")
+	assert.Equal(t, config.DefaultCredentialsRefreshInterval, app.credentialsRefreshInterval())
+}
+
+// versionedSigner is a signer identified by the version of its key.
+type versionedSigner struct {
+	crypto.Signer
+	version string
+}
+
+func (s *versionedSigner) String() string {
+	return s.version
+}
+
+func TestCredentialsDigest_Signer(t *testing.T) {
+	t.Parallel()
+
+	v1 := credentialsDigest(&appconfig.AppConfig{AppID: "1", AppPrivateKeySigner: &versionedSigner{version: "v1"}})
+	assert.Equal(t, v1, credentialsDigest(&appconfig.AppConfig{AppID: "1", AppPrivateKeySigner: &versionedSigner{version: "v1"}}))
+	assert.NotEqual(t, v1, credentialsDigest(&appconfig.AppConfig{AppID: "1", AppPrivateKeySigner: &versionedSigner{version: "v2"}}),
+		"the client is rebuilt when the key is rotated")
+}
+
+func TestApp_observeVaultRequest(t *testing.T) {
+	t.Parallel()
+
+	publisher := metricsMocks.NewServerPublisher(t)
+	publisher.On("PublishVaultRequest", "kubernetes", vault.OperationGetSecret, vault.ResultError, time.Second).Once()
+	app := &App{
+		metrics:      publisher,
+		healthStatus: health.NewStatus(clocktesting.NewFakeClock(time.Now()), health.WithReadinessThresholds(1, 1)),
+	}
+
+	app.observeVaultRequest(vault.VaultTypeKubernetes, vault.OperationGetSecret, time.Second, errors.New("vault unavailable"))
+	report := app.healthStatus.Report(time.Minute)
+	require.NotNil(t, report.VaultReachable)
+	assert.False(t, *report.VaultReachable)
+	assert.False(t, report.Ready)
+}
+
+func TestApp_rotateCredentials(t *testing.T) {
+	t.Parallel()
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	app := &App{
+		config: &config.Config{
+			ConfigureUrl:                "https://github.com/org",
+			EphemeralRunnerSetNamespace: "namespace",
+			EphemeralRunnerSetName:      "deployment",
+			RunnerScaleSetId:            1,
+			VaultType:                   vault.VaultTypeAzureKeyVault,
+			VaultLookupKey:              "key",
+			VaultRefreshInterval:        &metav1.Duration{Duration: time.Hour},
+		},
+		logger:            logr.Discard(),
+		clock:             fakeClock,
+		client:            newRotatingClient(listenermocks.NewClient(t)),
+		vault:             &fakeVault{secret: `{"github_token": "new"}`},
+		credentialsDigest: credentialsDigest(&appconfig.AppConfig{Token: "old"}),
+	}
+	previous := app.client.current()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.rotateCredentials(ctx)
+	}()
+
+	require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+	assert.Same(t, previous, app.client.current(), "client must not be rotated before the interval elapses")
+
+	fakeClock.Step(time.Hour)
+	assert.Eventually(t, func() bool {
+		return app.client.current() != previous
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestWorkerScheduledOverrides(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	overrides, err := workerScheduledOverrides([]config.ScheduledOverride{
+		{
+			StartTime: start,
+			EndTime:   start.Add(8 * time.Hour),
+			Frequency: "Daily",
+			TimeZone:  "America/New_York",
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, overrides, 1)
+
+	assert.True(t, overrides[0].StartTime.Equal(start))
+	assert.Equal(t, "America/New_York", overrides[0].StartTime.Location().String())
+	assert.Equal(t, 9, overrides[0].StartTime.Hour())
+	assert.True(t, overrides[0].UntilTime.IsZero())
+}
+
+func TestWorkerMigration(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	migration := workerMigration(&config.Migration{
+		TargetEphemeralRunnerSetName: "green",
+		StartTime:                    start,
+		RampDuration:                 &metav1.Duration{Duration: 2 * time.Hour},
+	})
+	assert.Equal(t, &worker.Migration{
+		TargetEphemeralRunnerSetName: "green",
+		StartTime:                    start,
+		Duration:                     2 * time.Hour,
+		Percentage:                   config.DefaultMigrationPercentage,
+	}, migration)
+
+	migration = workerMigration(&config.Migration{TargetEphemeralRunnerSetName: "green", StartTime: start, Percentage: 10})
+	assert.Equal(t, 10, migration.Percentage)
+	assert.Zero(t, migration.Duration)
+}
+
+func TestWorkerCanary(t *testing.T) {
+	t.Parallel()
+
+	canary := workerCanary(&config.Canary{EphemeralRunnerSetName: "canary", Percentage: 10})
+	assert.Equal(t, &worker.Canary{
+		EphemeralRunnerSetName: "canary",
+		Percentage:             10,
+		MinJobs:                config.DefaultCanaryMinJobs,
+		MaxFailureRateIncrease: config.DefaultCanaryMaxFailureRateIncrease,
+	}, canary)
+
+	rate := 0.0
+	canary = workerCanary(&config.Canary{EphemeralRunnerSetName: "canary", Percentage: 10, MinJobs: 5, MaxFailureRateIncrease: &rate})
+	assert.Equal(t, 5, canary.MinJobs)
+	assert.Zero(t, canary.MaxFailureRateIncrease, "an explicit zero is kept")
+}
+
+func TestWorkerTopologySpread(t *testing.T) {
+	t.Parallel()
+
+	spread := workerTopologySpread(&config.TopologySpread{
+		TopologyKey: "topology.kubernetes.io/zone",
+		Zones: []config.TopologyZone{
+			{Name: "eu-west-1a"},
+			{Name: "eu-west-1b", EphemeralRunnerSetName: "deployment-b", Weight: 2},
+		},
+	}, "deployment")
+	assert.Equal(t, &worker.TopologySpread{
+		TopologyKey: "topology.kubernetes.io/zone",
+		Zones: []worker.TopologyZone{
+			{Name: "eu-west-1a", EphemeralRunnerSetName: "deployment", Weight: 1},
+			{Name: "eu-west-1b", EphemeralRunnerSetName: "deployment-b", Weight: 2},
+		},
+	}, spread)
+}
+
+func TestApp_dumpState(t *testing.T) {
+	t.Parallel()
+
+	w := appmocks.NewWorker(t)
+	w.On("State").Return(worker.State{LastPatch: 3}).Once()
+
+	app := &App{
+		logger: logr.Discard(),
+		worker: w,
+	}
+	app.dumpState()
+
+	stacks := string(goroutineStacks())
+	assert.Contains(t, stacks, "TestApp_dumpState")
 }
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/audit"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
+	"github.com/go-logr/logr"
+	"k8s.io/utils/clock"
+)
+
+// newAuditLog builds the audit log of the configuration, writing to the file of the work directory or to the URL.
+// The links to the workflow runs are built from the GitHub server of the config URL.
+// The HTTP sink is returned to be run when the records are posted to the URL.
+func newAuditLog(c *config.Audit, workDir *workdir.Dir, scaleSet audit.ScaleSet, configURL *url.URL, clock clock.PassiveClock, logger logr.Logger) (*audit.Log, *audit.HTTPSink, error) {
+	secret, err := readCredentialsFile(c.SecretFile)
+	if err != nil {
+		return nil, nil, errcode.Errorf(errcode.ConfigRead, "failed to read audit secret: %w", err)
+	}
+
+	var sink audit.Sink
+	var httpSink *audit.HTTPSink
+	if c.File != "" {
+		w, err := workDir.AppendWriter(c.File)
+		if err != nil {
+			return nil, nil, errcode.Errorf(errcode.ConfigInvalid, "failed to configure audit file: %w", err)
+		}
+		sink = audit.NewWriterSink(w)
+	} else {
+		timeout := config.DefaultAuditTimeout
+		if c.Timeout != nil {
+			timeout = c.Timeout.Duration
+		}
+		httpSink = audit.NewHTTPSink(audit.HTTPSinkConfig{
+			URL:             c.URL,
+			Client:          &http.Client{Timeout: timeout},
+			BearerTokenFile: c.BearerTokenFile,
+			Logger:          logger,
+		})
+		sink = httpSink
+	}
+
+	return audit.New(audit.Config{
+		Sink:      sink,
+		Secret:    []byte(secret),
+		ScaleSet:  scaleSet,
+		GitHubURL: gitHubServerURL(configURL),
+		Clock:     clock,
+		Logger:    logger,
+	}), httpSink, nil
+}
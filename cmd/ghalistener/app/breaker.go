@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// breakerMaxAttempts is the number of times a call is attempted while the service fails.
+	breakerMaxAttempts = 5
+	// breakerInitialBackoff is the time the calls are backed off after the first failure.
+	// It doubles with every consecutive failure, up to breakerMaxBackoff.
+	breakerInitialBackoff = time.Second
+	breakerMaxBackoff     = 5 * time.Minute
+)
+
+// breakerClient is a listener.Client that backs off all calls to the GitHub Actions service
+// once a call failed with a server error or was rate limited, instead of retrying right away.
+//
+// The circuit opens on such a failure, and the calls wait until the backoff elapsed. The next call
+// then probes the service: the circuit closes if it succeeds, and opens for twice as long if it fails.
+//
+// Only the idempotent calls are retried. CreateMessageSession and AcquireJobs are attempted once, since a
+// failed response does not tell whether the service created the session or acquired the jobs. GetMessage
+// is passed through: it is a long poll, whose failures are handled by the listener.
+type breakerClient struct {
+	client  listener.Client
+	clock   clock.Clock
+	metrics metrics.Publisher
+	logger  logr.Logger
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+var _ listener.Client = (*breakerClient)(nil)
+
+func newBreakerClient(client listener.Client, clock clock.Clock, publisher metrics.Publisher, logger logr.Logger) *breakerClient {
+	if publisher == nil {
+		publisher = metrics.Discard
+	}
+	return &breakerClient{
+		client:  client,
+		clock:   clock,
+		metrics: publisher,
+		logger:  logger,
+	}
+}
+
+// isServiceUnavailable reports whether err is a server error or a rate limit of the GitHub Actions service.
+func isServiceUnavailable(err error) bool {
+	var actionsErr *actions.ActionsError
+	if errors.As(err, &actionsErr) {
+		return actionsErr.StatusCode >= http.StatusInternalServerError || actionsErr.StatusCode == http.StatusTooManyRequests
+	}
+	var apiErr *actions.GitHubAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	var clientErr *actions.HttpClientSideError
+	if errors.As(err, &clientErr) {
+		return clientErr.Code == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// wait blocks until the circuit is closed or its backoff elapsed.
+func (b *breakerClient) wait(ctx context.Context) error {
+	b.mu.Lock()
+	backoff := b.openUntil.Sub(b.clock.Now())
+	b.mu.Unlock()
+	if backoff <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.clock.After(backoff):
+		return nil
+	}
+}
+
+// record updates the circuit with the result of a call. It reports whether the call should be retried.
+func (b *breakerClient) record(err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isServiceUnavailable(err) {
+		if b.failures > 0 {
+			b.logger.Info("GitHub Actions service recovered, closing the circuit breaker")
+			b.metrics.PublishCircuitBreakerState(false)
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return false
+	}
+
+	backoff := breakerMaxBackoff
+	if b.failures < 16 {
+		backoff = min(breakerInitialBackoff<<b.failures, breakerMaxBackoff)
+	}
+	if b.failures == 0 {
+		b.metrics.PublishCircuitBreakerState(true)
+	}
+	b.failures++
+	b.openUntil = b.clock.Now().Add(backoff)
+	b.logger.Info("GitHub Actions service is unavailable, backing off", "backoff", backoff.String(), "consecutiveFailures", b.failures, "error", err.Error())
+	return true
+}
+
+// once calls fn once the circuit allows it, without retrying it.
+func (b *breakerClient) once(ctx context.Context, fn func() error) error {
+	if err := b.wait(ctx); err != nil {
+		return err
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+// do calls fn once the circuit allows it, retrying it while the service is unavailable.
+// It must only be used for idempotent calls.
+func (b *breakerClient) do(ctx context.Context, fn func() error) error {
+	var err error
+	for range breakerMaxAttempts {
+		if err := b.wait(ctx); err != nil {
+			return err
+		}
+		err = fn()
+		if !b.record(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (b *breakerClient) GetAcquirableJobs(ctx context.Context, runnerScaleSetId int) (jobs *actions.AcquirableJobList, err error) {
+	err = b.do(ctx, func() error {
+		jobs, err = b.client.GetAcquirableJobs(ctx, runnerScaleSetId)
+		return err
+	})
+	return jobs, err
+}
+
+func (b *breakerClient) CreateMessageSession(ctx context.Context, runnerScaleSetId int, owner string) (session *actions.RunnerScaleSetSession, err error) {
+	err = b.once(ctx, func() error {
+		session, err = b.client.CreateMessageSession(ctx, runnerScaleSetId, owner)
+		return err
+	})
+	return session, err
+}
+
+func (b *breakerClient) GetMessage(ctx context.Context, messageQueueUrl, messageQueueAccessToken string, lastMessageId int64, maxCapacity int) (*actions.RunnerScaleSetMessage, error) {
+	return b.client.GetMessage(ctx, messageQueueUrl, messageQueueAccessToken, lastMessageId, maxCapacity)
+}
+
+func (b *breakerClient) DeleteMessage(ctx context.Context, messageQueueUrl, messageQueueAccessToken string, messageId int64) error {
+	return b.do(ctx, func() error {
+		return b.client.DeleteMessage(ctx, messageQueueUrl, messageQueueAccessToken, messageId)
+	})
+}
+
+func (b *breakerClient) AcquireJobs(ctx context.Context, runnerScaleSetId int, messageQueueAccessToken string, requestIds []int64) (ids []int64, err error) {
+	err = b.once(ctx, func() error {
+		ids, err = b.client.AcquireJobs(ctx, runnerScaleSetId, messageQueueAccessToken, requestIds)
+		return err
+	})
+	return ids, err
+}
+
+func (b *breakerClient) RefreshMessageSession(ctx context.Context, runnerScaleSetId int, sessionId *uuid.UUID) (session *actions.RunnerScaleSetSession, err error) {
+	err = b.do(ctx, func() error {
+		session, err = b.client.RefreshMessageSession(ctx, runnerScaleSetId, sessionId)
+		return err
+	})
+	return session, err
+}
+
+func (b *breakerClient) DeleteMessageSession(ctx context.Context, runnerScaleSetId int, sessionId *uuid.UUID) error {
+	return b.do(ctx, func() error {
+		return b.client.DeleteMessageSession(ctx, runnerScaleSetId, sessionId)
+	})
+}
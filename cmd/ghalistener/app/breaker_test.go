@@ -0,0 +1,211 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	listenermocks "github.com/actions/actions-runner-controller/cmd/ghalistener/listener/mocks"
+	metricsMocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestIsServiceUnavailable(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"server error":             {err: &actions.ActionsError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		"rate limited":             {err: &actions.ActionsError{StatusCode: http.StatusTooManyRequests}, want: true},
+		"wrapped github api error": {err: errors.Join(errors.New("oops"), &actions.GitHubAPIError{StatusCode: http.StatusBadGateway}), want: true},
+		"client side rate limited": {err: &actions.HttpClientSideError{Code: http.StatusTooManyRequests}, want: true},
+		"not found":                {err: &actions.ActionsError{StatusCode: http.StatusNotFound}, want: false},
+		"client side unauthorized": {err: &actions.HttpClientSideError{Code: http.StatusUnauthorized}, want: false},
+		"message session conflict": {err: &actions.MessageQueueTokenExpiredError{}, want: false},
+		"context cancelled":        {err: context.Canceled, want: false},
+		"no error":                 {err: nil, want: false},
+		"unclassified error":       {err: errors.New("oops"), want: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isServiceUnavailable(tt.err))
+		})
+	}
+}
+
+func TestBreakerClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("BacksOffUntilServiceRecovers", func(t *testing.T) {
+		t.Parallel()
+
+		clock := clocktesting.NewFakeClock(time.Now())
+		client := listenermocks.NewClient(t)
+		client.On("GetAcquirableJobs", mock.Anything, 1).Return(nil, &actions.ActionsError{StatusCode: http.StatusServiceUnavailable}).Twice()
+		client.On("GetAcquirableJobs", mock.Anything, 1).Return(&actions.AcquirableJobList{Count: 1}, nil).Once()
+
+		publisher := metricsMocks.NewPublisher(t)
+		publisher.On("PublishCircuitBreakerState", true).Once()
+		publisher.On("PublishCircuitBreakerState", false).Once()
+
+		b := newBreakerClient(client, clock, publisher, logr.Discard())
+
+		type result struct {
+			jobs *actions.AcquirableJobList
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			jobs, err := b.GetAcquirableJobs(context.Background(), 1)
+			done <- result{jobs, err}
+		}()
+
+		for _, backoff := range []time.Duration{breakerInitialBackoff, 2 * breakerInitialBackoff} {
+			require.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+			clock.Step(backoff - time.Millisecond)
+			select {
+			case <-done:
+				t.Fatalf("call retried before the backoff of %s elapsed", backoff)
+			case <-time.After(10 * time.Millisecond):
+			}
+			clock.Step(time.Millisecond)
+		}
+
+		select {
+		case r := <-done:
+			require.NoError(t, r.err)
+			assert.Equal(t, 1, r.jobs.Count)
+		case <-time.After(time.Second):
+			t.Fatal("call did not return once the service recovered")
+		}
+	})
+
+	t.Run("GivesUpAfterMaxAttempts", func(t *testing.T) {
+		t.Parallel()
+
+		clock := clocktesting.NewFakeClock(time.Now())
+		client := listenermocks.NewClient(t)
+		client.On("DeleteMessage", mock.Anything, "url", "token", int64(1)).Return(&actions.ActionsError{StatusCode: http.StatusTooManyRequests}).Times(breakerMaxAttempts)
+
+		publisher := metricsMocks.NewPublisher(t)
+		publisher.On("PublishCircuitBreakerState", true).Once()
+
+		b := newBreakerClient(client, clock, publisher, logr.Discard())
+
+		done := make(chan error, 1)
+		go func() {
+			done <- b.DeleteMessage(context.Background(), "url", "token", 1)
+		}()
+
+		for range breakerMaxAttempts - 1 {
+			require.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+			clock.Step(breakerMaxBackoff)
+		}
+
+		select {
+		case err := <-done:
+			var actionsErr *actions.ActionsError
+			require.ErrorAs(t, err, &actionsErr)
+			assert.Equal(t, http.StatusTooManyRequests, actionsErr.StatusCode)
+		case <-time.After(time.Second):
+			t.Fatal("call did not give up after the max attempts")
+		}
+
+		// The circuit stays open for the next call.
+		b.mu.Lock()
+		assert.Equal(t, breakerMaxAttempts, b.failures)
+		assert.True(t, b.openUntil.After(clock.Now()))
+		b.mu.Unlock()
+	})
+
+	t.Run("DoesNotRetryOtherErrors", func(t *testing.T) {
+		t.Parallel()
+
+		client := listenermocks.NewClient(t)
+		client.On("CreateMessageSession", mock.Anything, 1, "owner").Return(nil, &actions.ActionsError{StatusCode: http.StatusConflict}).Once()
+
+		b := newBreakerClient(client, clocktesting.NewFakeClock(time.Now()), metricsMocks.NewPublisher(t), logr.Discard())
+
+		_, err := b.CreateMessageSession(context.Background(), 1, "owner")
+		var actionsErr *actions.ActionsError
+		require.ErrorAs(t, err, &actionsErr)
+		assert.Equal(t, http.StatusConflict, actionsErr.StatusCode)
+	})
+
+	t.Run("DoesNotRetryNonIdempotentCalls", func(t *testing.T) {
+		t.Parallel()
+
+		clock := clocktesting.NewFakeClock(time.Now())
+		client := listenermocks.NewClient(t)
+		client.On("AcquireJobs", mock.Anything, 1, "token", []int64{1}).Return(nil, &actions.ActionsError{StatusCode: http.StatusBadGateway}).Once()
+		client.On("CreateMessageSession", mock.Anything, 1, "owner").Return(nil, &actions.ActionsError{StatusCode: http.StatusBadGateway}).Once()
+
+		publisher := metricsMocks.NewPublisher(t)
+		publisher.On("PublishCircuitBreakerState", true).Once()
+
+		b := newBreakerClient(client, clock, publisher, logr.Discard())
+
+		_, err := b.AcquireJobs(context.Background(), 1, "token", []int64{1})
+		var actionsErr *actions.ActionsError
+		require.ErrorAs(t, err, &actionsErr)
+		assert.Equal(t, http.StatusBadGateway, actionsErr.StatusCode)
+
+		// The call waits for the circuit opened by the failure, but is not retried.
+		done := make(chan error, 1)
+		go func() {
+			_, err := b.CreateMessageSession(context.Background(), 1, "owner")
+			done <- err
+		}()
+		require.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+		clock.Step(breakerInitialBackoff)
+		select {
+		case err := <-done:
+			require.ErrorAs(t, err, &actionsErr)
+		case <-time.After(time.Second):
+			t.Fatal("call did not return after its single attempt")
+		}
+	})
+
+	t.Run("PassesThroughTheMessagePoll", func(t *testing.T) {
+		t.Parallel()
+
+		client := listenermocks.NewClient(t)
+		client.On("GetMessage", mock.Anything, "url", "token", int64(0), 10).Return(nil, &actions.ActionsError{StatusCode: http.StatusInternalServerError}).Once()
+
+		b := newBreakerClient(client, clocktesting.NewFakeClock(time.Now()), metricsMocks.NewPublisher(t), logr.Discard())
+
+		_, err := b.GetMessage(context.Background(), "url", "token", 0, 10)
+		var actionsErr *actions.ActionsError
+		require.ErrorAs(t, err, &actionsErr)
+		b.mu.Lock()
+		assert.Zero(t, b.failures, "the failures of the long poll do not open the circuit")
+		b.mu.Unlock()
+	})
+
+	t.Run("StopsWaitingOnContextCancel", func(t *testing.T) {
+		t.Parallel()
+
+		client := listenermocks.NewClient(t)
+		client.On("GetAcquirableJobs", mock.Anything, 1).Return(nil, &actions.ActionsError{StatusCode: http.StatusInternalServerError}).Once()
+
+		publisher := metricsMocks.NewPublisher(t)
+		publisher.On("PublishCircuitBreakerState", true).Once()
+
+		b := newBreakerClient(client, clocktesting.NewFakeClock(time.Now()), publisher, logr.Discard())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		_, err := b.GetAcquirableJobs(ctx, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
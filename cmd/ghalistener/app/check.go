@@ -0,0 +1,249 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// checkTimeout bounds each step of the self-check reaching out to the Actions service or the Kubernetes API.
+const checkTimeout = 30 * time.Second
+
+// CheckStatus is the outcome of a step of the self-check.
+type CheckStatus string
+
+const (
+	CheckPassed  CheckStatus = "OK"
+	CheckFailed  CheckStatus = "FAILED"
+	CheckSkipped CheckStatus = "SKIPPED"
+)
+
+// CheckResult is the outcome of a step of the self-check, with what was verified, or why it failed
+// and how to fix it.
+type CheckResult struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+	Err    error
+	Hint   string
+}
+
+// CheckReport is the diagnostic report of the self-check.
+type CheckReport struct {
+	Results []CheckResult
+}
+
+// Failed reports whether a step of the self-check failed.
+func (r *CheckReport) Failed() bool {
+	for _, result := range r.Results {
+		if result.Status == CheckFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the error of the first failed step of the self-check, nil if none failed.
+func (r *CheckReport) Err() error {
+	for _, result := range r.Results {
+		if result.Status == CheckFailed {
+			return result.Err
+		}
+	}
+	return nil
+}
+
+// Write writes the report in a human-readable form.
+func (r *CheckReport) Write(w io.Writer) error {
+	for _, result := range r.Results {
+		line := fmt.Sprintf("[%s] %s", result.Status, result.Name)
+		if result.Detail != "" {
+			line += ": " + result.Detail
+		}
+		if result.Err != nil {
+			line += ": " + result.Err.Error()
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+		if result.Hint != "" {
+			if _, err := fmt.Fprintf(w, "    hint: %s\n", result.Hint); err != nil {
+				return err
+			}
+		}
+	}
+	if r.Failed() {
+		_, err := fmt.Fprintln(w, "The listener cannot start, fix the failed checks above.")
+		return err
+	}
+	_, err := fmt.Fprintln(w, "The listener is ready to start.")
+	return err
+}
+
+func (r *CheckReport) pass(name, detail string) {
+	r.Results = append(r.Results, CheckResult{Name: name, Status: CheckPassed, Detail: detail})
+}
+
+func (r *CheckReport) fail(name string, err error, hint string) {
+	r.Results = append(r.Results, CheckResult{Name: name, Status: CheckFailed, Err: err, Hint: hint})
+}
+
+func (r *CheckReport) skip(name, detail string) {
+	r.Results = append(r.Results, CheckResult{Name: name, Status: CheckSkipped, Detail: detail})
+}
+
+// Check verifies that the listener of the config file can start, without starting it: it loads and validates
+// the config, resolves the secrets from the vault, authenticates to the Actions service and gets the scale set,
+// discovers the ephemeral runner resources and patches the ephemeral runner set in dry-run mode, which verifies
+// the RBAC permissions of the listener. The steps depending on a failed one are skipped.
+func Check(ctx context.Context, configPath string, options ...Option) *CheckReport {
+	app := &App{logger: logr.Discard()}
+	for _, option := range options {
+		option(app)
+	}
+
+	report := &CheckReport{}
+
+	const (
+		configStep     = "config"
+		actionsStep    = "actions-service"
+		discoveryStep  = "kubernetes-api"
+		permissionStep = "kubernetes-rbac"
+	)
+
+	c, err := config.Read(ctx, configPath)
+	if err != nil {
+		report.fail(configStep, err, "fix the listener config, or the vault it reads the GitHub credentials from")
+		report.skip(actionsStep, "the config is not loaded")
+		report.skip(discoveryStep, "the config is not loaded")
+		report.skip(permissionStep, "the config is not loaded")
+		return report
+	}
+	detail := "loaded and validated"
+	if c.VaultType != "" {
+		detail += fmt.Sprintf(", the GitHub credentials resolved from the %s vault", c.VaultType)
+	}
+	report.pass(configStep, detail)
+
+	actionsClient, err := c.ActionsClient(app.logger)
+	if err != nil {
+		report.fail(actionsStep, err, "")
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		detail, err := checkActionsService(ctx, actionsClient, c.RunnerScaleSetId)
+		cancel()
+		if err != nil {
+			report.fail(actionsStep, err, actionsHint(err))
+		} else {
+			report.pass(actionsStep, detail)
+		}
+	}
+
+	if c.ScaleTarget != nil {
+		report.skip(discoveryStep, "the ScaleTarget is scaled in place of the ephemeral runner set")
+		report.skip(permissionStep, "the ScaleTarget is scaled in place of the ephemeral runner set")
+		return report
+	}
+
+	resources, err := discoverResources(app.kubeConfig)
+	if err != nil {
+		report.fail(discoveryStep, err, "")
+		report.skip(permissionStep, "the ephemeral runner resources are not discovered")
+		return report
+	}
+	report.pass(discoveryStep, fmt.Sprintf("the ephemeral runner sets are served by %s", resources.EphemeralRunnerSets.GroupVersion()))
+
+	kubeConfig, err := kubernetesConfig(app.kubeConfig)
+	if err != nil {
+		report.fail(permissionStep, err, "")
+		return report
+	}
+	client, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		report.fail(permissionStep, errcode.Errorf(errcode.KubernetesClient, "failed to create kubernetes client: %w", err), "")
+		return report
+	}
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	if err := checkPatchPermission(ctx, client, resources.EphemeralRunnerSets, c.EphemeralRunnerSetNamespace, c.EphemeralRunnerSetName); err != nil {
+		report.fail(permissionStep, err, permissionHint(err, resources.EphemeralRunnerSets, c.EphemeralRunnerSetNamespace, c.EphemeralRunnerSetName))
+		return report
+	}
+	report.pass(permissionStep, fmt.Sprintf("the ephemeral runner set %s/%s can be patched", c.EphemeralRunnerSetNamespace, c.EphemeralRunnerSetName))
+
+	return report
+}
+
+// scaleSetGetter is the part of the Actions client the self-check authenticates with.
+type scaleSetGetter interface {
+	GetRunnerScaleSetById(ctx context.Context, runnerScaleSetId int) (*actions.RunnerScaleSet, error)
+}
+
+// checkActionsService authenticates to the Actions service by getting the scale set.
+func checkActionsService(ctx context.Context, client scaleSetGetter, runnerScaleSetId int) (string, error) {
+	scaleSet, err := client.GetRunnerScaleSetById(ctx, runnerScaleSetId)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the runner scale set %d: %w", runnerScaleSetId, err)
+	}
+	if scaleSet == nil {
+		return "", fmt.Errorf("the runner scale set %d does not exist", runnerScaleSetId)
+	}
+	return fmt.Sprintf("authenticated, the runner scale set %q (ID %d) exists", scaleSet.Name, scaleSet.Id), nil
+}
+
+// checkPatchPermission patches the ephemeral runner set with an empty patch in dry-run mode, which the API server
+// authorizes and admits as a real patch without persisting it.
+func checkPatchPermission(ctx context.Context, client dynamic.Interface, gvr schema.GroupVersionResource, namespace, name string) error {
+	_, err := client.
+		Resource(gvr).
+		Namespace(namespace).
+		Patch(ctx, name, types.MergePatchType, []byte("{}"), metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil {
+		return errcode.Errorf(errcode.EphemeralRunnerSetPatch, "failed to patch the ephemeral runner set in dry-run mode: %w", err)
+	}
+	return nil
+}
+
+func actionsHint(err error) string {
+	var actionsErr *actions.ActionsError
+	var githubErr *actions.GitHubAPIError
+	status := 0
+	switch {
+	case errors.As(err, &actionsErr):
+		status = actionsErr.StatusCode
+	case errors.As(err, &githubErr):
+		status = githubErr.StatusCode
+	}
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "check the GitHub App ID, installation ID and private key, or the token, and that they are granted access to the configure URL"
+	case http.StatusNotFound:
+		return "check that the runner scale set ID matches a scale set of the configure URL, it may have been deleted"
+	case 0:
+		return "check that the GitHub server, or the proxy of the listener, is reachable from the pod"
+	}
+	return ""
+}
+
+func permissionHint(err error, gvr schema.GroupVersionResource, namespace, name string) string {
+	switch {
+	case kerrors.IsForbidden(err):
+		return fmt.Sprintf("grant the service account of the listener the patch verb on the %s resource in the namespace %s", gvr.GroupResource(), namespace)
+	case kerrors.IsNotFound(err):
+		return fmt.Sprintf("the ephemeral runner set %s does not exist in the namespace %s, check the AutoscalingRunnerSet", name, namespace)
+	}
+	return ""
+}
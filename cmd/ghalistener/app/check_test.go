@@ -0,0 +1,88 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type fakeScaleSetGetter struct {
+	scaleSet *actions.RunnerScaleSet
+	err      error
+}
+
+func (f *fakeScaleSetGetter) GetRunnerScaleSetById(ctx context.Context, runnerScaleSetId int) (*actions.RunnerScaleSet, error) {
+	return f.scaleSet, f.err
+}
+
+func TestCheck_InvalidConfig(t *testing.T) {
+	report := Check(context.Background(), filepath.Join(t.TempDir(), "missing.json"))
+	require.True(t, report.Failed())
+	assert.Equal(t, errcode.ExitConfig, errcode.ExitCode(report.Err()))
+	require.Len(t, report.Results, 4)
+	assert.Equal(t, CheckFailed, report.Results[0].Status)
+	for _, result := range report.Results[1:] {
+		assert.Equal(t, CheckSkipped, result.Status, result.Name)
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, report.Write(&out))
+	assert.Contains(t, out.String(), "[FAILED] config: ARC-LSTN-1001: failed to open config")
+	assert.Contains(t, out.String(), "[SKIPPED] kubernetes-rbac: the config is not loaded")
+	assert.Contains(t, out.String(), "The listener cannot start")
+}
+
+func TestCheckActionsService(t *testing.T) {
+	detail, err := checkActionsService(context.Background(), &fakeScaleSetGetter{scaleSet: &actions.RunnerScaleSet{Id: 1, Name: "arc"}}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, `authenticated, the runner scale set "arc" (ID 1) exists`, detail)
+
+	_, err = checkActionsService(context.Background(), &fakeScaleSetGetter{}, 1)
+	assert.EqualError(t, err, "the runner scale set 1 does not exist")
+
+	_, err = checkActionsService(context.Background(), &fakeScaleSetGetter{err: &actions.ActionsError{StatusCode: http.StatusUnauthorized}}, 1)
+	require.Error(t, err)
+	assert.Contains(t, actionsHint(err), "GitHub App ID")
+}
+
+func TestCheckPatchPermission(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	gvr := v1alpha1.GroupVersion.WithResource("ephemeralrunnersets")
+
+	client := dynamicfake.NewSimpleDynamicClient(scheme, &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"},
+	})
+	var patch []byte
+	client.PrependReactor("patch", "ephemeralrunnersets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch = action.(k8stesting.PatchActionImpl).GetPatch()
+		return false, nil, nil
+	})
+	require.NoError(t, checkPatchPermission(context.Background(), client, gvr, "namespace", "set"))
+	assert.Equal(t, "{}", string(patch), "the ephemeral runner set is not modified")
+
+	err := checkPatchPermission(context.Background(), client, gvr, "namespace", "missing")
+	require.Error(t, err)
+	assert.Contains(t, permissionHint(err, gvr, "namespace", "missing"), "does not exist")
+
+	client.PrependReactor("patch", "ephemeralrunnersets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kerrors.NewForbidden(gvr.GroupResource(), "set", nil)
+	})
+	err = checkPatchPermission(context.Background(), client, gvr, "namespace", "set")
+	require.Error(t, err)
+	assert.Equal(t, "grant the service account of the listener the patch verb on the ephemeralrunnersets.actions.github.com resource in the namespace namespace",
+		permissionHint(err, gvr, "namespace", "set"))
+}
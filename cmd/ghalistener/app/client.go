@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"sync"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/google/uuid"
+)
+
+// rotatingClient is a listener.Client that delegates to an underlying client
+// which can be replaced at runtime, e.g. after the credentials are rotated.
+// The message session is owned by the listener, so swapping the client
+// does not drop the session.
+type rotatingClient struct {
+	mu     sync.RWMutex
+	client listener.Client
+}
+
+var _ listener.Client = (*rotatingClient)(nil)
+
+func newRotatingClient(client listener.Client) *rotatingClient {
+	return &rotatingClient{client: client}
+}
+
+func (c *rotatingClient) current() listener.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
+}
+
+func (c *rotatingClient) swap(client listener.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.client = client
+}
+
+func (c *rotatingClient) GetAcquirableJobs(ctx context.Context, runnerScaleSetId int) (*actions.AcquirableJobList, error) {
+	return c.current().GetAcquirableJobs(ctx, runnerScaleSetId)
+}
+
+func (c *rotatingClient) CreateMessageSession(ctx context.Context, runnerScaleSetId int, owner string) (*actions.RunnerScaleSetSession, error) {
+	return c.current().CreateMessageSession(ctx, runnerScaleSetId, owner)
+}
+
+func (c *rotatingClient) GetMessage(ctx context.Context, messageQueueUrl, messageQueueAccessToken string, lastMessageId int64, maxCapacity int) (*actions.RunnerScaleSetMessage, error) {
+	return c.current().GetMessage(ctx, messageQueueUrl, messageQueueAccessToken, lastMessageId, maxCapacity)
+}
+
+func (c *rotatingClient) DeleteMessage(ctx context.Context, messageQueueUrl, messageQueueAccessToken string, messageId int64) error {
+	return c.current().DeleteMessage(ctx, messageQueueUrl, messageQueueAccessToken, messageId)
+}
+
+func (c *rotatingClient) AcquireJobs(ctx context.Context, runnerScaleSetId int, messageQueueAccessToken string, requestIds []int64) ([]int64, error) {
+	return c.current().AcquireJobs(ctx, runnerScaleSetId, messageQueueAccessToken, requestIds)
+}
+
+func (c *rotatingClient) RefreshMessageSession(ctx context.Context, runnerScaleSetId int, sessionId *uuid.UUID) (*actions.RunnerScaleSetSession, error) {
+	return c.current().RefreshMessageSession(ctx, runnerScaleSetId, sessionId)
+}
+
+func (c *rotatingClient) DeleteMessageSession(ctx context.Context, runnerScaleSetId int, sessionId *uuid.UUID) error {
+	return c.current().DeleteMessageSession(ctx, runnerScaleSetId, sessionId)
+}
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// discoverResources resolves the resources patched by the worker, so the listener fails at startup
+// with a clear message if the CRDs are not installed, instead of failing the first patch.
+func discoverResources(conf *rest.Config) (worker.Resources, error) {
+	conf, err := kubernetesConfig(conf)
+	if err != nil {
+		return worker.Resources{}, err
+	}
+	client, err := discovery.NewDiscoveryClientForConfig(conf)
+	if err != nil {
+		return worker.Resources{}, errcode.Errorf(errcode.KubernetesClient, "failed to create kubernetes discovery client: %w", err)
+	}
+	return worker.DiscoverResources(client)
+}
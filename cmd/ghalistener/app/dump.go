@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"runtime"
+)
+
+// dumpStateOnSignal logs a state dump every time one of the dumpSignals is received,
+// until the context is cancelled.
+func (app *App) dumpStateOnSignal(ctx context.Context) {
+	if len(dumpSignals) == 0 {
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, dumpSignals...)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			app.dumpState()
+		}
+	}
+}
+
+// dumpState logs the goroutine stacks and the scaling state of the worker,
+// including its most recent decisions.
+func (app *App) dumpState() {
+	app.logger.Info("State dump",
+		"worker", app.worker.State(),
+		"goroutines", string(goroutineStacks()),
+	)
+}
+
+// goroutineStacks returns the stack traces of all goroutines.
+func goroutineStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpSignals are the signals requesting a state dump.
+var dumpSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package app
+
+import "os"
+
+// dumpSignals are the signals requesting a state dump. SIGUSR1 does not exist on Windows.
+var dumpSignals []os.Signal
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/webhook"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"github.com/go-logr/logr"
+	"k8s.io/utils/clock"
+)
+
+// capacityForecaster periodically forecasts the peak runner count of the worker within the horizon,
+// and exports it as metrics and to the webhook, if configured.
+type capacityForecaster struct {
+	forecast func(horizon time.Duration) worker.CapacityForecast
+	interval time.Duration
+	horizon  time.Duration
+	metrics  metrics.Publisher
+	// webhook is nil if the forecasts are only exported as metrics.
+	webhook *forecastWebhook
+	clock   clock.WithTicker
+	logger  logr.Logger
+}
+
+// forecastWebhook posts the forecasts to a webhook.
+type forecastWebhook struct {
+	endpoint webhook.Endpoint
+	scaleSet worker.WebhookScaleSet
+}
+
+// forecastWebhookRequest is the body posted to the forecast webhook.
+type forecastWebhookRequest struct {
+	ScaleSet worker.WebhookScaleSet  `json:"scaleSet"`
+	Forecast worker.CapacityForecast `json:"forecast"`
+}
+
+func newCapacityForecaster(c *config.CapacityForecast, forecast func(time.Duration) worker.CapacityForecast, scaleSetID int, scaleSetName string, publisher metrics.Publisher, clock clock.WithTicker, logger logr.Logger) *capacityForecaster {
+	f := &capacityForecaster{
+		forecast: forecast,
+		interval: config.DefaultCapacityForecastInterval,
+		horizon:  config.DefaultCapacityForecastHorizon,
+		metrics:  publisher,
+		clock:    clock,
+		logger:   logger,
+	}
+	if c.Interval != nil {
+		f.interval = c.Interval.Duration
+	}
+	if c.Horizon != nil {
+		f.horizon = c.Horizon.Duration
+	}
+	if f.metrics == nil {
+		f.metrics = metrics.Discard
+	}
+	if w := c.Webhook; w != nil {
+		timeout := config.DefaultForecastWebhookTimeout
+		if w.Timeout != nil {
+			timeout = w.Timeout.Duration
+		}
+		f.webhook = &forecastWebhook{
+			endpoint: webhook.Endpoint{
+				URL:             w.URL,
+				Client:          &http.Client{Timeout: timeout},
+				BearerTokenFile: w.BearerTokenFile,
+				SecretFile:      w.SecretFile,
+			},
+			scaleSet: worker.WebhookScaleSet{ID: scaleSetID, Name: scaleSetName},
+		}
+	}
+	return f
+}
+
+// run forecasts right away and then every interval, until the context is cancelled.
+// A failure to post a forecast is logged, the next forecast is posted regardless.
+func (f *capacityForecaster) run(ctx context.Context) error {
+	f.logger.Info("Starting capacity forecasts", "interval", f.interval.String(), "horizon", f.horizon.String())
+
+	ticker := f.clock.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		f.export(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+		}
+	}
+}
+
+func (f *capacityForecaster) export(ctx context.Context) {
+	forecast := f.forecast(f.horizon)
+	f.metrics.PublishCapacityForecast(forecast.PeakRunners, forecast.PeakTime)
+	if f.webhook == nil {
+		return
+	}
+	if err := f.webhook.post(ctx, forecast); err != nil {
+		f.logger.Error(err, "Failed to post the capacity forecast")
+	}
+}
+
+// post posts the forecast to the webhook, which must answer with a 2xx status.
+func (w *forecastWebhook) post(ctx context.Context, forecast worker.CapacityForecast) error {
+	body, err := json.Marshal(&forecastWebhookRequest{
+		ScaleSet: w.scaleSet,
+		Forecast: forecast,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal forecast: %w", err)
+	}
+
+	if err := w.endpoint.Post(ctx, body); err != nil {
+		return fmt.Errorf("failed to post forecast: %w", err)
+	}
+	return nil
+}
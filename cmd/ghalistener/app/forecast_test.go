@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	metricsmocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/webhook"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestCapacityForecaster(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	forecast := worker.CapacityForecast{
+		Time:           now,
+		HorizonSeconds: 3600,
+		PeakRunners:    9,
+		PeakTime:       now.Add(50 * time.Minute),
+	}
+
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token\n"), 0o600))
+	require.NoError(t, os.WriteFile(secretFile, []byte("secret\n"), 0o600))
+
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	t.Cleanup(server.Close)
+
+	publisher := metricsmocks.NewPublisher(t)
+	publisher.On("PublishCapacityForecast", 9, now.Add(50*time.Minute)).Once()
+
+	var horizon time.Duration
+	f := newCapacityForecaster(
+		&config.CapacityForecast{
+			Webhook: &config.ForecastWebhook{URL: server.URL, BearerTokenFile: tokenFile, SecretFile: secretFile},
+		},
+		func(h time.Duration) worker.CapacityForecast {
+			horizon = h
+			return forecast
+		},
+		1,
+		"scale-set",
+		publisher,
+		clocktesting.NewFakeClock(now),
+		logr.Discard(),
+	)
+	f.export(context.Background())
+	assert.Equal(t, config.DefaultCapacityForecastHorizon, horizon)
+
+	req, body := <-requests, <-bodies
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	assert.Equal(t, webhook.Sign([]byte("secret"), body), req.Header.Get(webhook.SignatureHeader))
+
+	var got forecastWebhookRequest
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, worker.WebhookScaleSet{ID: 1, Name: "scale-set"}, got.ScaleSet)
+	assert.Equal(t, 9, got.Forecast.PeakRunners)
+	assert.True(t, forecast.PeakTime.Equal(got.Forecast.PeakTime))
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+)
+
+// errIdle is the cause of the listener being stopped once the scale set was idle for IdleExitAfter.
+var errIdle = errors.New("scale set idle")
+
+// idleCheckInterval is the maximum interval at which the worker state is checked for idleness.
+const idleCheckInterval = 30 * time.Second
+
+// defaultTerminationMessagePath is the default termination message path of the listener container.
+const defaultTerminationMessagePath = "/dev/termination-log"
+
+// isIdle reports whether the scale set has no job assigned and was last scaled to zero runners.
+func isIdle(state worker.State) bool {
+	return state.LastPatch == 0 && state.LastAssigned == 0
+}
+
+// stopWhenIdle cancels the listener once the scale set was idle for the duration,
+// recording the final state of the worker in the logs. It returns once the context is done
+// or the listener was cancelled.
+func (app *App) stopWhenIdle(ctx context.Context, idleFor time.Duration, cancel context.CancelCauseFunc) {
+	ticker := app.clock.NewTicker(min(idleCheckInterval, idleFor))
+	defer ticker.Stop()
+
+	var idleSince time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		state := app.worker.State()
+		if !isIdle(state) {
+			idleSince = time.Time{}
+			continue
+		}
+		now := app.clock.Now()
+		if idleSince.IsZero() {
+			idleSince = now
+		}
+		if now.Sub(idleSince) < idleFor {
+			continue
+		}
+
+		app.logger.Info("Scale set idle, stopping the listener", "idleFor", idleFor, "worker", state)
+		cancel(errIdle)
+		return
+	}
+}
+
+// recordIdleExit writes the idle termination message of the listener container, so that the controller
+// waits for acquirable jobs before recreating the listener pod.
+func (app *App) recordIdleExit() {
+	if app.terminationMessagePath == "" {
+		return
+	}
+	if err := workdir.WriteTerminationMessage(app.terminationMessagePath, v1alpha1.AutoscalingListenerIdleTerminationMessage); err != nil {
+		app.logger.Error(err, "Failed to write the idle termination message", "path", app.terminationMessagePath)
+	}
+}
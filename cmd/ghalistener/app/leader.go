@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// errLeadershipLost is the cause of the listener being stopped once the replica failed to renew the lease.
+var errLeadershipLost = errors.New("leadership lost")
+
+// kubernetesConfig returns the config of the Kubernetes clients, the in-cluster one unless conf is set.
+func kubernetesConfig(conf *rest.Config) (*rest.Config, error) {
+	if conf != nil {
+		return conf, nil
+	}
+	conf, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, errcode.Errorf(errcode.KubernetesClient, "failed to load in-cluster config: %w", err)
+	}
+	return conf, nil
+}
+
+// newClientset builds the client of the Kubernetes resources the worker does not patch,
+// the lease of the leader election and the runner limits ConfigMap.
+func newClientset(conf *rest.Config) (kubernetes.Interface, error) {
+	conf, err := kubernetesConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(conf)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.KubernetesClient, "failed to create kubernetes client: %w", err)
+	}
+	return clientset, nil
+}
+
+// leaderElectionConfig builds the leader election of the listener replicas on the lease of the configuration.
+// The callbacks are set by lead.
+func leaderElectionConfig(clientset kubernetes.Interface, c *config.LeaderElection, defaultNamespace string) (*leaderelection.LeaderElectionConfig, error) {
+	namespace := c.LeaseNamespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	identity := c.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the hostname to identify the replica: %w", err)
+		}
+		identity = hostname
+	}
+
+	leaseDuration, renewDeadline, retryPeriod := c.Durations()
+	return &leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      c.LeaseName,
+				Namespace: namespace,
+			},
+			Client: clientset.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: identity,
+			},
+		},
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		// The lease is released once the listener deleted its message session,
+		// so a standby replica takes over without waiting for the lease to expire.
+		ReleaseOnCancel: true,
+		Name:            c.LeaseName,
+	}, nil
+}
+
+// lead blocks until the replica holds the lease, or the context is cancelled.
+// Once leading, the listener is cancelled with errLeadershipLost if the lease cannot be renewed.
+// The returned function releases the lease, it must be called once the listener returned.
+func (app *App) lead(ctx context.Context, cancelListener context.CancelCauseFunc) (release func(), err error) {
+	leading := make(chan struct{})
+	// released is closed before the lease is released, which stops leading as well.
+	released := make(chan struct{})
+	lec := *app.leaderElection
+	lec.Callbacks = leaderelection.LeaderCallbacks{
+		OnStartedLeading: func(context.Context) {
+			app.logger.Info("Started leading, starting the listener", "identity", lec.Lock.Identity())
+			close(leading)
+		},
+		OnStoppedLeading: func() {
+			select {
+			case <-released:
+			default:
+				app.logger.Info("Stopped leading, stopping the listener")
+				cancelListener(errLeadershipLost)
+			}
+		},
+		OnNewLeader: func(identity string) {
+			app.logger.Info("New leader elected", "leader", identity)
+		},
+	}
+	elector, err := leaderelection.NewLeaderElector(lec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create leader elector: %w", err)
+	}
+
+	// The election outlives the listener context, so the lease is only released once the listener returned.
+	electionCtx, cancelElection := context.WithCancel(context.WithoutCancel(ctx))
+	electionDone := make(chan struct{})
+	go func() {
+		defer close(electionDone)
+		elector.Run(electionCtx)
+	}()
+	release = func() {
+		close(released)
+		cancelElection()
+		<-electionDone
+	}
+
+	app.logger.Info("Standing by until the lease is acquired", "lease", lec.Name)
+	app.healthStatus.SetStandby(true)
+	defer app.healthStatus.SetStandby(false)
+
+	select {
+	case <-leading:
+		return release, nil
+	case <-ctx.Done():
+		release()
+		return nil, context.Cause(ctx)
+	}
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/health"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestApp_lead(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset()
+	newReplica := func(identity string) *App {
+		lec, err := leaderElectionConfig(clientset, &config.LeaderElection{
+			LeaseName:     "listener",
+			Identity:      identity,
+			LeaseDuration: &metav1.Duration{Duration: time.Second},
+			RenewDeadline: &metav1.Duration{Duration: 500 * time.Millisecond},
+			RetryPeriod:   &metav1.Duration{Duration: 100 * time.Millisecond},
+		}, "namespace")
+		require.NoError(t, err)
+		return &App{
+			logger:         logr.Discard(),
+			healthStatus:   health.NewStatus(clocktesting.NewFakeClock(time.Now())),
+			leaderElection: lec,
+		}
+	}
+
+	leader := newReplica("leader")
+	leaderCtx, cancelLeader := context.WithCancelCause(context.Background())
+	defer cancelLeader(nil)
+	releaseLeader, err := leader.lead(leaderCtx, cancelLeader)
+	require.NoError(t, err)
+
+	standby := newReplica("standby")
+	standbyCtx, cancelStandby := context.WithCancelCause(context.Background())
+	defer cancelStandby(nil)
+	type result struct {
+		release func()
+		err     error
+	}
+	standbyResult := make(chan result, 1)
+	go func() {
+		release, err := standby.lead(standbyCtx, cancelStandby)
+		standbyResult <- result{release, err}
+	}()
+
+	require.Eventually(t, func() bool {
+		return standby.healthStatus.Report(time.Minute).Standby
+	}, 5*time.Second, 10*time.Millisecond)
+	select {
+	case <-standbyResult:
+		t.Fatal("standby replica acquired the lease held by the leader")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	releaseLeader()
+	assert.NoError(t, leaderCtx.Err(), "releasing the lease does not cancel the listener")
+
+	select {
+	case r := <-standbyResult:
+		require.NoError(t, r.err)
+		assert.False(t, standby.healthStatus.Report(time.Minute).Standby)
+		r.release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("standby replica did not take over the released lease")
+	}
+}
+
+func TestApp_lead_CancelledWhileStandingBy(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset()
+	newReplica := func(identity string) *App {
+		lec, err := leaderElectionConfig(clientset, &config.LeaderElection{
+			LeaseName: "listener",
+			Identity:  identity,
+		}, "namespace")
+		require.NoError(t, err)
+		return &App{logger: logr.Discard(), leaderElection: lec}
+	}
+
+	leaderCtx, cancelLeader := context.WithCancelCause(context.Background())
+	defer cancelLeader(nil)
+	release, err := newReplica("leader").lead(leaderCtx, cancelLeader)
+	require.NoError(t, err)
+	defer release()
+
+	standbyCtx, cancelStandby := context.WithCancelCause(context.Background())
+	time.AfterFunc(100*time.Millisecond, func() { cancelStandby(errMaxUptimeReached) })
+	_, err = newReplica("standby").lead(standbyCtx, cancelStandby)
+	assert.ErrorIs(t, err, errMaxUptimeReached)
+}
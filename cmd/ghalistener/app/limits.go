@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// runnerLimitsWatcher watches the ConfigMap the min and max runners are read from,
+// and applies them to the worker every time it changes.
+type runnerLimitsWatcher struct {
+	clientset kubernetes.Interface
+	namespace string
+	name      string
+
+	minRunnersKey string
+	maxRunnersKey string
+	// minRunners and maxRunners are the configured limits, applied while the ConfigMap does not set them.
+	minRunners int
+	maxRunners int
+
+	apply  func(minRunners, maxRunners int)
+	logger logr.Logger
+}
+
+func newRunnerLimitsWatcher(clientset kubernetes.Interface, c *config.RunnerLimitsConfigMap, defaultNamespace string, minRunners, maxRunners int, apply func(minRunners, maxRunners int), logger logr.Logger) *runnerLimitsWatcher {
+	namespace := c.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	minRunnersKey, maxRunnersKey := c.Keys()
+	return &runnerLimitsWatcher{
+		clientset:     clientset,
+		namespace:     namespace,
+		name:          c.Name,
+		minRunnersKey: minRunnersKey,
+		maxRunnersKey: maxRunnersKey,
+		minRunners:    minRunners,
+		maxRunners:    maxRunners,
+		apply:         apply,
+		logger:        logger,
+	}
+}
+
+// run watches the ConfigMap until the context is cancelled.
+// The configured limits are restored when the ConfigMap is deleted.
+func (r *runnerLimitsWatcher) run(ctx context.Context) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(r.clientset, 0,
+		informers.WithNamespace(r.namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("metadata.name", r.name).String()
+		}),
+	)
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    r.update,
+		UpdateFunc: func(_, obj any) { r.update(obj) },
+		DeleteFunc: func(any) {
+			r.logger.Info("Runner limits ConfigMap deleted, restoring the configured limits", "name", r.name)
+			r.apply(r.minRunners, r.maxRunners)
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to add runner limits ConfigMap event handler: %w", err)
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	<-ctx.Done()
+	return nil
+}
+
+func (r *runnerLimitsWatcher) update(obj any) {
+	configMap, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return
+	}
+	minRunners, maxRunners, err := r.limits(configMap)
+	if err != nil {
+		r.logger.Error(err, "Invalid runner limits ConfigMap, keeping the current limits", "name", configMap.Name)
+		return
+	}
+	r.apply(minRunners, maxRunners)
+}
+
+// limits reads the min and max runners of the ConfigMap. The configured MaxRunners bounds the max runners.
+func (r *runnerLimitsWatcher) limits(configMap *corev1.ConfigMap) (minRunners, maxRunners int, err error) {
+	minRunners, maxRunners = r.minRunners, r.maxRunners
+	for _, l := range []struct {
+		key   string
+		limit *int
+	}{
+		{r.minRunnersKey, &minRunners},
+		{r.maxRunnersKey, &maxRunners},
+	} {
+		value, ok := configMap.Data[l.key]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("%s %q must be a non-negative integer", l.key, value)
+		}
+		*l.limit = n
+	}
+
+	if maxRunners > r.maxRunners {
+		r.logger.Info("Runner limits ConfigMap exceeds the configured max runners, capping it", "maxRunners", maxRunners, "configuredMaxRunners", r.maxRunners)
+		maxRunners = r.maxRunners
+	}
+	if minRunners > maxRunners {
+		return 0, 0, fmt.Errorf("%s %d cannot be greater than %s %d", r.minRunnersKey, minRunners, r.maxRunnersKey, maxRunners)
+	}
+	return minRunners, maxRunners, nil
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunnerLimitsWatcher(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset()
+
+	var (
+		mu      sync.Mutex
+		applied [][2]int
+	)
+	last := func() [2]int {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(applied) == 0 {
+			return [2]int{-1, -1}
+		}
+		return applied[len(applied)-1]
+	}
+
+	w := newRunnerLimitsWatcher(
+		clientset,
+		&config.RunnerLimitsConfigMap{Name: "limits"},
+		"arc-runners",
+		1,
+		10,
+		func(minRunners, maxRunners int) {
+			mu.Lock()
+			defer mu.Unlock()
+			applied = append(applied, [2]int{minRunners, maxRunners})
+		},
+		logr.Discard(),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- w.run(ctx) }()
+
+	configMaps := clientset.CoreV1().ConfigMaps("arc-runners")
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "limits", Namespace: "arc-runners"},
+		Data:       map[string]string{config.DefaultMinRunnersKey: "2", config.DefaultMaxRunnersKey: "5"},
+	}
+	_, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return last() == [2]int{2, 5} }, 5*time.Second, 10*time.Millisecond)
+
+	configMap.Data = map[string]string{config.DefaultMaxRunnersKey: "3"}
+	_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return last() == [2]int{1, 3} }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, configMaps.Delete(ctx, "limits", metav1.DeleteOptions{}))
+	require.Eventually(t, func() bool { return last() == [2]int{1, 10} }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not stop on context cancel")
+	}
+}
+
+func TestRunnerLimitsWatcherLimits(t *testing.T) {
+	t.Parallel()
+
+	w := newRunnerLimitsWatcher(
+		fake.NewClientset(),
+		&config.RunnerLimitsConfigMap{Name: "limits", MinRunnersKey: "min", MaxRunnersKey: "max"},
+		"arc-runners",
+		1,
+		10,
+		func(int, int) {},
+		logr.Discard(),
+	)
+
+	tests := map[string]struct {
+		data    map[string]string
+		wantMin int
+		wantMax int
+		wantErr bool
+	}{
+		"both keys":            {data: map[string]string{"min": "2", "max": "4"}, wantMin: 2, wantMax: 4},
+		"missing keys":         {data: map[string]string{}, wantMin: 1, wantMax: 10},
+		"only min":             {data: map[string]string{"min": " 3 "}, wantMin: 3, wantMax: 10},
+		"max capped":           {data: map[string]string{"max": "20"}, wantMin: 1, wantMax: 10},
+		"min greater than max": {data: map[string]string{"min": "5", "max": "4"}, wantErr: true},
+		"not a number":         {data: map[string]string{"max": "many"}, wantErr: true},
+		"negative":             {data: map[string]string{"min": "-1"}, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			minRunners, maxRunners, err := w.limits(&corev1.ConfigMap{Data: tt.data})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMin, minRunners)
+			assert.Equal(t, tt.wantMax, maxRunners)
+		})
+	}
+}
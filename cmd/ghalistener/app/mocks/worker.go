@@ -8,6 +8,10 @@ import (
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+
+	worker "github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
 )
 
 // Worker is an autogenerated mock type for the Worker type
@@ -15,6 +19,20 @@ type Worker struct {
 	mock.Mock
 }
 
+// Backfill provides a mock function with given fields: ctx
+func (_m *Worker) Backfill(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // HandleDesiredRunnerCount provides a mock function with given fields: ctx, count, acquireCount
 func (_m *Worker) HandleDesiredRunnerCount(ctx context.Context, count int, acquireCount int) (int, error) {
 	ret := _m.Called(ctx, count, acquireCount)
@@ -39,6 +57,34 @@ func (_m *Worker) HandleDesiredRunnerCount(ctx context.Context, count int, acqui
 	return r0, r1
 }
 
+// HandleJobAssigned provides a mock function with given fields: ctx, jobInfo
+func (_m *Worker) HandleJobAssigned(ctx context.Context, jobInfo *actions.JobAssigned) error {
+	ret := _m.Called(ctx, jobInfo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *actions.JobAssigned) error); ok {
+		r0 = rf(ctx, jobInfo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// HandleJobCompleted provides a mock function with given fields: ctx, jobInfo
+func (_m *Worker) HandleJobCompleted(ctx context.Context, jobInfo *actions.JobCompleted) error {
+	ret := _m.Called(ctx, jobInfo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *actions.JobCompleted) error); ok {
+		r0 = rf(ctx, jobInfo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // HandleJobStarted provides a mock function with given fields: ctx, jobInfo
 func (_m *Worker) HandleJobStarted(ctx context.Context, jobInfo *actions.JobStarted) error {
 	ret := _m.Called(ctx, jobInfo)
@@ -53,6 +99,34 @@ func (_m *Worker) HandleJobStarted(ctx context.Context, jobInfo *actions.JobStar
 	return r0
 }
 
+// HandleSessionCreated provides a mock function with given fields: ctx, session, createdAt
+func (_m *Worker) HandleSessionCreated(ctx context.Context, session *actions.RunnerScaleSetSession, createdAt time.Time) error {
+	ret := _m.Called(ctx, session, createdAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *actions.RunnerScaleSetSession, time.Time) error); ok {
+		r0 = rf(ctx, session, createdAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// State provides a mock function with given fields:
+func (_m *Worker) State() worker.State {
+	ret := _m.Called()
+
+	var r0 worker.State
+	if rf, ok := ret.Get(0).(func() worker.State); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(worker.State)
+	}
+
+	return r0
+}
+
 // NewWorker creates a new instance of Worker. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewWorker(t interface {
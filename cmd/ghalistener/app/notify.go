@@ -0,0 +1,35 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/notify"
+	"github.com/go-logr/logr"
+)
+
+// newNotifier builds the notifier of the completed jobs of the configuration. The links to the workflow runs
+// are built from the GitHub server of the config URL.
+func newNotifier(c *config.JobNotifications, configURL *url.URL, scaleSet notify.ScaleSet, logger logr.Logger) *notify.Notifier {
+	timeout := config.DefaultJobNotificationsTimeout
+	if c.Timeout != nil {
+		timeout = c.Timeout.Duration
+	}
+	return notify.New(notify.Config{
+		URL:             c.URL,
+		Client:          &http.Client{Timeout: timeout},
+		Format:          c.Format,
+		Results:         c.Results,
+		BearerTokenFile: c.BearerTokenFile,
+		GitHubURL:       gitHubServerURL(configURL),
+		ScaleSet:        scaleSet,
+		Logger:          logger,
+	})
+}
+
+// gitHubServerURL returns the URL of the GitHub server of the config URL, e.g. "https://github.com".
+func gitHubServerURL(configURL *url.URL) string {
+	u := &url.URL{Scheme: configURL.Scheme, Host: configURL.Host}
+	return u.String()
+}
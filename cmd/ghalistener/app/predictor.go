@@ -0,0 +1,27 @@
+package app
+
+import (
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/predictor"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
+	"github.com/go-logr/logr"
+)
+
+// newPredictor creates the predictor of the config, loaded with the demand recorded by the previous runs.
+func newPredictor(c *config.Predictor, workDir *workdir.Dir, logger logr.Logger) (*predictor.Predictor, error) {
+	predictorConfig := predictor.Config{
+		Days:        c.Days,
+		WorkDir:     workDir,
+		HistoryFile: c.HistoryFile,
+		Logger:      logger,
+	}
+	if c.Lead != nil {
+		predictorConfig.Lead = c.Lead.Duration
+	}
+
+	p := predictor.New(predictorConfig)
+	if err := p.Load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
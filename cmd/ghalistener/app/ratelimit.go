@@ -0,0 +1,143 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/go-logr/logr"
+	"k8s.io/utils/clock"
+)
+
+const (
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+	headerRateLimitResource  = "X-RateLimit-Resource"
+	headerRetryAfter         = "Retry-After"
+
+	// maxRateLimitDelay bounds the delay read from the headers, GitHub rate limit windows last an hour.
+	maxRateLimitDelay = time.Hour
+)
+
+// rateLimiter delays the requests to GitHub once a response reported the rate limit as exhausted,
+// or asked to retry after some time, instead of sending requests bound to be rejected.
+// It publishes the rate limit reported by the responses. The delays are kept per host, since the hosts,
+// e.g. the GitHub API and the Actions service, have rate limits of their own.
+//
+// A single rate limiter is shared by the successive actions clients, so the delay outlives a credentials rotation.
+type rateLimiter struct {
+	clock   clock.Clock
+	metrics metrics.Publisher
+	logger  logr.Logger
+
+	mu sync.Mutex
+	// resumeAt is the time the requests to each host can be sent again.
+	resumeAt map[string]time.Time
+}
+
+func newRateLimiter(clock clock.Clock, logger logr.Logger) *rateLimiter {
+	return &rateLimiter{
+		clock:    clock,
+		metrics:  metrics.Discard,
+		logger:   logger,
+		resumeAt: make(map[string]time.Time),
+	}
+}
+
+// wrap returns the transport sending the requests through the rate limiter.
+func (r *rateLimiter) wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if err := r.wait(req); err != nil {
+			return nil, err
+		}
+		resp, err := next.RoundTrip(req)
+		if resp != nil {
+			r.observe(req, resp)
+		}
+		return resp, err
+	})
+}
+
+func (r *rateLimiter) wait(req *http.Request) error {
+	r.mu.Lock()
+	delay := r.resumeAt[req.URL.Host].Sub(r.clock.Now())
+	r.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	r.logger.Info("Delaying request until the GitHub rate limit resets", "host", req.URL.Host, "delay", delay.String())
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-r.clock.After(delay):
+		return nil
+	}
+}
+
+// observe reads the rate limit headers of the response.
+func (r *rateLimiter) observe(req *http.Request, resp *http.Response) {
+	now := r.clock.Now()
+
+	var resumeAt time.Time
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get(headerRetryAfter), now); ok {
+		resumeAt = now.Add(retryAfter)
+	}
+
+	limit, limitErr := strconv.Atoi(resp.Header.Get(headerRateLimitLimit))
+	remaining, remainingErr := strconv.Atoi(resp.Header.Get(headerRateLimitRemaining))
+	resetUnix, resetErr := strconv.ParseInt(resp.Header.Get(headerRateLimitReset), 10, 64)
+	if limitErr == nil && remainingErr == nil && resetErr == nil {
+		reset := time.Unix(resetUnix, 0)
+		resource := resp.Header.Get(headerRateLimitResource)
+		if resource == "" {
+			resource = req.URL.Host
+		}
+		r.metrics.PublishRateLimit(resource, limit, remaining, reset)
+
+		if remaining == 0 && reset.After(resumeAt) {
+			resumeAt = reset
+		}
+	}
+
+	if resumeAt.IsZero() {
+		return
+	}
+	if latest := now.Add(maxRateLimitDelay); resumeAt.After(latest) {
+		resumeAt = latest
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for host, at := range r.resumeAt {
+		if !at.After(now) {
+			delete(r.resumeAt, host)
+		}
+	}
+	if resumeAt.After(r.resumeAt[req.URL.Host]) {
+		r.resumeAt[req.URL.Host] = resumeAt
+	}
+}
+
+// parseRetryAfter parses the Retry-After header, either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, seconds > 0
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now), date.After(now)
+	}
+	return 0, false
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
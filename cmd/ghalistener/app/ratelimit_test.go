@@ -0,0 +1,186 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	metricsMocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	newHostRequest := func(t *testing.T, ctx context.Context, url string) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		require.NoError(t, err)
+		return req
+	}
+	newRequest := func(t *testing.T, ctx context.Context) *http.Request {
+		return newHostRequest(t, ctx, "https://api.github.com/app")
+	}
+
+	// roundTrip sends a request through the rate limiter in the background, the response carrying the headers.
+	roundTrip := func(ctx context.Context, r *rateLimiter, req *http.Request, header http.Header) <-chan error {
+		transport := r.wrap(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Header: header}, nil
+		}))
+		done := make(chan error, 1)
+		go func() {
+			_, err := transport.RoundTrip(req)
+			done <- err
+		}()
+		return done
+	}
+
+	t.Run("DelaysUntilExhaustedLimitResets", func(t *testing.T) {
+		t.Parallel()
+
+		clock := clocktesting.NewFakeClock(time.Unix(1700000000, 0))
+		reset := clock.Now().Add(time.Minute)
+
+		publisher := metricsMocks.NewPublisher(t)
+		publisher.On("PublishRateLimit", "core", 5000, 0, reset).Once()
+		publisher.On("PublishRateLimit", "core", 5000, 4999, reset.Add(time.Hour)).Once()
+
+		r := newRateLimiter(clock, logr.Discard())
+		r.metrics = publisher
+
+		ctx := context.Background()
+		require.NoError(t, <-roundTrip(ctx, r, newRequest(t, ctx), rateLimitHeader(5000, 0, reset, "core")))
+
+		done := roundTrip(ctx, r, newRequest(t, ctx), rateLimitHeader(5000, 4999, reset.Add(time.Hour), "core"))
+		require.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+		select {
+		case <-done:
+			t.Fatal("request sent before the rate limit reset")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		clock.Step(time.Minute)
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("request not sent once the rate limit reset")
+		}
+	})
+
+	t.Run("DelaysAfterRetryAfter", func(t *testing.T) {
+		t.Parallel()
+
+		clock := clocktesting.NewFakeClock(time.Now())
+		r := newRateLimiter(clock, logr.Discard())
+
+		ctx := context.Background()
+		require.NoError(t, <-roundTrip(ctx, r, newRequest(t, ctx), retryAfterHeader("30")))
+
+		done := roundTrip(ctx, r, newRequest(t, ctx), nil)
+		require.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+		clock.Step(30 * time.Second)
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("request not sent once the retry after elapsed")
+		}
+	})
+
+	t.Run("DelaysOnlyTheHost", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRateLimiter(clocktesting.NewFakeClock(time.Now()), logr.Discard())
+
+		ctx := context.Background()
+		require.NoError(t, <-roundTrip(ctx, r, newRequest(t, ctx), retryAfterHeader("30")))
+
+		req := newHostRequest(t, ctx, "https://pipelines.actions.githubusercontent.com/_apis/runtime/runnerscalesets")
+		select {
+		case err := <-roundTrip(ctx, r, req, nil):
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("request to another host delayed")
+		}
+	})
+
+	t.Run("StopsWaitingOnContextCancel", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRateLimiter(clocktesting.NewFakeClock(time.Now()), logr.Discard())
+
+		ctx := context.Background()
+		require.NoError(t, <-roundTrip(ctx, r, newRequest(t, ctx), retryAfterHeader("30")))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		assert.ErrorIs(t, <-roundTrip(ctx, r, newRequest(t, ctx), nil), context.Canceled)
+	})
+
+	t.Run("PublishesHostWithoutResource", func(t *testing.T) {
+		t.Parallel()
+
+		clock := clocktesting.NewFakeClock(time.Unix(1700000000, 0))
+		reset := clock.Now().Add(time.Minute)
+
+		publisher := metricsMocks.NewPublisher(t)
+		publisher.On("PublishRateLimit", "api.github.com", 100, 50, reset).Once()
+
+		r := newRateLimiter(clock, logr.Discard())
+		r.metrics = publisher
+
+		ctx := context.Background()
+		require.NoError(t, <-roundTrip(ctx, r, newRequest(t, ctx), rateLimitHeader(100, 50, reset, "")))
+		assert.Empty(t, r.resumeAt, "requests are not delayed while the rate limit is not exhausted")
+	})
+}
+
+func rateLimitHeader(limit, remaining int, reset time.Time, resource string) http.Header {
+	header := make(http.Header)
+	header.Set(headerRateLimitLimit, strconv.Itoa(limit))
+	header.Set(headerRateLimitRemaining, strconv.Itoa(remaining))
+	header.Set(headerRateLimitReset, strconv.FormatInt(reset.Unix(), 10))
+	if resource != "" {
+		header.Set(headerRateLimitResource, resource)
+	}
+	return header
+}
+
+func retryAfterHeader(value string) http.Header {
+	header := make(http.Header)
+	header.Set(headerRetryAfter, value)
+	return header
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		"seconds":     {value: "120", want: 2 * time.Minute, ok: true},
+		"http date":   {value: now.Add(time.Minute).Format(http.TimeFormat), want: time.Minute, ok: true},
+		"past date":   {value: now.Add(-time.Minute).Format(http.TimeFormat), want: -time.Minute, ok: false},
+		"zero":        {value: "0", ok: false},
+		"empty":       {value: "", ok: false},
+		"unparseable": {value: "soon", ok: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/recovery"
+)
+
+const (
+	minPanicBackoff = time.Second
+	maxPanicBackoff = time.Minute
+)
+
+// supervise runs the component until it returns, restarting it with a backoff when it panics.
+// The panic is logged with its stack trace and counted by the panic metric. It returns the
+// result of the component, or nil if the context is done while waiting to restart it.
+func (app *App) supervise(ctx context.Context, component string, fn func(ctx context.Context) error) error {
+	backoff := minPanicBackoff
+	for {
+		err := runRecovered(ctx, fn)
+
+		var panicErr *recovery.PanicError
+		if !errors.As(err, &panicErr) {
+			return err
+		}
+
+		app.logger.Error(panicErr, "Recovered from a panic, restarting", "component", component, "backoff", backoff, "stack", string(panicErr.Stack))
+		if app.metrics != nil {
+			app.metrics.PublishPanic(component)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-app.clock.After(backoff):
+		}
+		backoff = min(2*backoff, maxPanicBackoff)
+	}
+}
+
+func runRecovered(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer recovery.Recover(&err)
+	return fn(ctx)
+}
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+)
+
+// newRunnerCache builds the cache of the ephemeral runners of the namespace, of the discovered resource.
+func newRunnerCache(conf *rest.Config, namespace string, resource schema.GroupVersionResource) (*worker.RunnerCache, error) {
+	conf, err := kubernetesConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	client, err := metadata.NewForConfig(conf)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.KubernetesClient, "failed to create kubernetes metadata client: %w", err)
+	}
+	return worker.NewRunnerCache(client, namespace, resource), nil
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/utils/clock"
+)
+
+// stateSecretKey is the key of the state in the state Secret.
+const stateSecretKey = "state.json"
+
+// stateExporter periodically exports the durable scaling state of the worker to a file in the work directory
+// and to a Secret, and imports the state exported last when the listener starts.
+type stateExporter struct {
+	workDir    *workdir.Dir
+	file       string
+	secrets    corev1client.SecretInterface
+	secretName string
+	interval   time.Duration
+
+	snapshot func() worker.Snapshot
+	restore  func(worker.Snapshot) error
+	// loaded is set once the state exported last is imported. The state is not exported before then,
+	// so that a listener standing by for the lead does not overwrite the state exported by the leader.
+	loaded atomic.Bool
+	// leading is set once the listener leads the scale set, from when the state exported last is imported.
+	leading atomic.Bool
+
+	clock  clock.WithTicker
+	logger logr.Logger
+}
+
+func newStateExporter(c *config.StateExport, workDir *workdir.Dir, clientset kubernetes.Interface, namespace string, snapshot func() worker.Snapshot, restore func(worker.Snapshot) error, clock clock.WithTicker, logger logr.Logger) *stateExporter {
+	e := &stateExporter{
+		workDir:    workDir,
+		file:       c.File,
+		secretName: c.SecretName,
+		interval:   config.DefaultStateExportInterval,
+		snapshot:   snapshot,
+		restore:    restore,
+		clock:      clock,
+		logger:     logger,
+	}
+	if c.SecretName != "" {
+		e.secrets = clientset.CoreV1().Secrets(namespace)
+	}
+	if c.Interval != nil {
+		e.interval = c.Interval.Duration
+	}
+	return e
+}
+
+// start imports the state exported last once the listener leads the scale set. If the import fails, it is
+// retried on every interval of the exports, which only start once it succeeds.
+func (e *stateExporter) start(ctx context.Context) {
+	e.leading.Store(true)
+	if err := e.load(ctx); err != nil {
+		e.logger.Error(err, "Failed to import the exported scaling state, will retry on next interval", "interval", e.interval.String())
+	}
+}
+
+// load imports the state exported last, from the Secret if set, from the file otherwise.
+// It does nothing if no state was exported yet. The state is not exported until it is loaded,
+// so that the state exported last is not overwritten after failing to read it.
+func (e *stateExporter) load(ctx context.Context) error {
+	b, err := e.read(ctx)
+	if err != nil {
+		return err
+	}
+	if b != nil {
+		var snapshot worker.Snapshot
+		if err := json.Unmarshal(b, &snapshot); err != nil {
+			return fmt.Errorf("failed to decode the exported state: %w", err)
+		}
+		if err := e.restore(snapshot); err != nil {
+			return err
+		}
+	}
+	e.loaded.Store(true)
+	return nil
+}
+
+func (e *stateExporter) read(ctx context.Context) ([]byte, error) {
+	if e.secrets != nil {
+		secret, err := e.secrets.Get(ctx, e.secretName, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the state Secret %q: %w", e.secretName, err)
+		}
+		return secret.Data[stateSecretKey], nil
+	}
+
+	path, err := e.workDir.Join(e.file)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the state file: %w", err)
+	}
+	return b, nil
+}
+
+// run exports the state every interval until the context is cancelled, and once more on the way out.
+func (e *stateExporter) run(ctx context.Context) error {
+	e.logger.Info("Starting state exports", "interval", e.interval.String(), "file", e.file, "secret", e.secretName)
+
+	ticker := e.clock.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			e.export(ctx)
+			return nil
+		case <-ticker.C():
+			e.export(ctx)
+		}
+	}
+}
+
+func (e *stateExporter) export(ctx context.Context) {
+	if !e.loaded.Load() {
+		if !e.leading.Load() {
+			return
+		}
+		if err := e.load(ctx); err != nil {
+			e.logger.Error(err, "Failed to import the exported scaling state, the state is not exported until it is")
+			return
+		}
+	}
+	b, err := json.Marshal(e.snapshot())
+	if err != nil {
+		e.logger.Error(err, "Failed to encode the state")
+		return
+	}
+	if e.file != "" {
+		if err := e.writeFile(b); err != nil {
+			e.logger.Error(err, "Failed to export the state to the file", "file", e.file)
+		}
+	}
+	if e.secrets != nil {
+		if err := e.writeSecret(ctx, b); err != nil {
+			e.logger.Error(err, "Failed to export the state to the Secret", "name", e.secretName)
+		}
+	}
+}
+
+func (e *stateExporter) writeFile(b []byte) error {
+	f, err := e.workDir.Create(e.file)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (e *stateExporter) writeSecret(ctx context.Context, b []byte) error {
+	secret, err := e.secrets.Get(ctx, e.secretName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err = e.secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: e.secretName},
+			Data:       map[string][]byte{stateSecretKey: b},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[stateSecretKey] = b
+	_, err = e.secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
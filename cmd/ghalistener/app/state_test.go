@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestStateExporter(t *testing.T) {
+	t.Parallel()
+
+	newExporter := func(c *config.StateExport, workDir *workdir.Dir, clientset *fake.Clientset, state *worker.Snapshot) *stateExporter {
+		return newStateExporter(c, workDir, clientset, "arc-runners",
+			func() worker.Snapshot { return *state },
+			func(s worker.Snapshot) error {
+				state.PatchSeq = max(state.PatchSeq, s.PatchSeq)
+				return nil
+			},
+			clocktesting.NewFakeClock(time.Now()),
+			logr.Discard(),
+		)
+	}
+
+	t.Run("Secret", func(t *testing.T) {
+		t.Parallel()
+
+		clientset := fake.NewClientset()
+		c := &config.StateExport{SecretName: "listener-state"}
+
+		primaryState := &worker.Snapshot{Version: worker.SnapshotVersion, PatchSeq: 7}
+		primary := newExporter(c, nil, clientset, primaryState)
+		primary.export(context.Background())
+		_, err := clientset.CoreV1().Secrets("arc-runners").Get(context.Background(), "listener-state", metav1.GetOptions{})
+		assert.Error(t, err, "the state is not exported before it is loaded")
+
+		require.NoError(t, primary.load(context.Background()), "a missing Secret is not an error")
+		primary.export(context.Background())
+		primaryState.PatchSeq = 9
+		primary.export(context.Background())
+
+		standbyState := &worker.Snapshot{Version: worker.SnapshotVersion, PatchSeq: -1}
+		standby := newExporter(c, nil, clientset, standbyState)
+		require.NoError(t, standby.load(context.Background()))
+		assert.Equal(t, 9, standbyState.PatchSeq)
+	})
+
+	t.Run("RetriesTheImport", func(t *testing.T) {
+		t.Parallel()
+
+		clientset := fake.NewClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "listener-state", Namespace: "arc-runners"},
+			Data:       map[string][]byte{stateSecretKey: []byte(`{"version":1,"patchSeq":5}`)},
+		})
+		failures := 1
+		clientset.PrependReactor("get", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+			if failures == 0 {
+				return false, nil, nil
+			}
+			failures--
+			return true, nil, kerrors.NewServiceUnavailable("unavailable")
+		})
+		c := &config.StateExport{SecretName: "listener-state"}
+
+		state := &worker.Snapshot{Version: worker.SnapshotVersion, PatchSeq: -1}
+		exporter := newExporter(c, nil, clientset, state)
+		exporter.export(context.Background())
+		assert.Equal(t, 1, failures, "the state is not imported before leading")
+
+		exporter.start(context.Background())
+		assert.False(t, exporter.loaded.Load())
+		assert.Equal(t, -1, state.PatchSeq)
+
+		exporter.export(context.Background())
+		assert.True(t, exporter.loaded.Load(), "the import is retried on the next export")
+		assert.Equal(t, 5, state.PatchSeq)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		t.Parallel()
+
+		workDir, err := workdir.New(t.TempDir())
+		require.NoError(t, err)
+		c := &config.StateExport{File: "state/state.json"}
+
+		primaryState := &worker.Snapshot{Version: worker.SnapshotVersion, PatchSeq: 4}
+		primary := newExporter(c, workDir, nil, primaryState)
+		require.NoError(t, primary.load(context.Background()), "a missing file is not an error")
+		primary.export(context.Background())
+
+		restartedState := &worker.Snapshot{Version: worker.SnapshotVersion, PatchSeq: -1}
+		restarted := newExporter(c, workDir, nil, restartedState)
+		require.NoError(t, restarted.load(context.Background()))
+		assert.Equal(t, 4, restartedState.PatchSeq)
+	})
+}
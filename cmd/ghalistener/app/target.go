@@ -0,0 +1,57 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// newScaleTarget builds the scale target of the configuration, replacing the ephemeral runner set.
+func newScaleTarget(c *config.ScaleTarget, defaultNamespace string, scaleSetID int, scaleSetName string, conf *rest.Config) (worker.ScaleTarget, error) {
+	if w := c.Webhook; w != nil {
+		timeout := config.DefaultScaleTargetWebhookTimeout
+		if w.Timeout != nil {
+			timeout = w.Timeout.Duration
+		}
+		return worker.NewWebhookTarget(worker.WebhookTargetConfig{
+			URL:             w.URL,
+			Client:          &http.Client{Timeout: timeout},
+			BearerTokenFile: w.BearerTokenFile,
+			SecretFile:      w.SecretFile,
+			ScaleSetID:      scaleSetID,
+			ScaleSetName:    scaleSetName,
+		}), nil
+	}
+
+	k := c.Kubernetes
+	apiVersion, resource, namespace := config.DefaultScaleTargetAPIVersion, config.DefaultScaleTargetResource, defaultNamespace
+	if k.APIVersion != "" {
+		apiVersion = k.APIVersion
+	}
+	if k.Resource != "" {
+		resource = k.Resource
+	}
+	if k.Namespace != "" {
+		namespace = k.Namespace
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the api version of the scale target: %w", err)
+	}
+
+	conf, err = kubernetesConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(conf)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.KubernetesClient, "failed to create kubernetes client: %w", err)
+	}
+	return worker.NewScaleSubresourceTarget(client, gv.WithResource(resource), namespace, k.Name), nil
+}
@@ -0,0 +1,20 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewScaleTarget(t *testing.T) {
+	t.Parallel()
+
+	target, err := newScaleTarget(&config.ScaleTarget{
+		Webhook: &config.WebhookScaleTarget{URL: "https://scaler.example.com/scale"},
+	}, "namespace", 1, "scale-set", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &worker.WebhookTarget{}, target)
+}
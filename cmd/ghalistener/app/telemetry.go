@@ -0,0 +1,107 @@
+package app
+
+import (
+	"sort"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/telemetry"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+)
+
+func newTelemetryReporter(c *config.Config, ghConfig *actions.GitHubConfig, errors *telemetry.ErrorCounter, logger logr.Logger) *telemetry.Reporter {
+	interval := config.DefaultTelemetryInterval
+	if c.Telemetry.Interval != nil {
+		interval = c.Telemetry.Interval.Duration
+	}
+	return telemetry.NewReporter(telemetry.ReporterConfig{
+		Endpoint: c.Telemetry.Endpoint,
+		Interval: interval,
+		Shape:    telemetryShape(c, ghConfig),
+		Errors:   errors,
+		Logger:   logger,
+	})
+}
+
+// telemetryShape returns the features enabled in the configuration, leaving out every value which could
+// identify the listener or its GitHub organization.
+func telemetryShape(c *config.Config, ghConfig *actions.GitHubConfig) telemetry.Shape {
+	enabled := map[string]bool{
+		"vault":                     c.VaultType != "",
+		"vault-refresh":             c.VaultRefreshInterval != nil,
+		"credentials-files":         c.AppConfig != nil && c.AppConfig.HasFiles(),
+		"vault-cache":               c.VaultCache != nil,
+		"github-app":                c.AppConfig != nil && c.AppConfig.Token == "",
+		"server-root-ca":            c.ServerRootCA != "",
+		"server-root-ca-files":      len(c.ServerRootCAFiles) > 0 || c.ServerRootCADir != "",
+		"server-cert-revocation":    c.ServerCertRevocation != nil,
+		"metrics-server":            c.MetricsAddr != "",
+		"metrics-push":              c.Metrics != nil && c.Metrics.Push != nil,
+		"metrics-tls":               c.MetricsTLSCertFile != "",
+		"metrics-auth":              c.MetricsBearerTokenFile != "" || c.MetricsBasicAuthUsername != "",
+		"stale-runner-grace":        c.StaleRunnerGracePeriod != nil,
+		"missing-runner-policy":     c.MissingRunnerPolicy != "",
+		"misrouted-job-policy":      c.MisroutedJobPolicy != "",
+		"ephemeral-runner-cache":    c.EphemeralRunnerCache,
+		"message-concurrency":       c.MessageConcurrency > 1,
+		"scale-steps":               c.MaxScaleUpStep > 0 || c.MaxScaleDownStep > 0,
+		"scheduled-overrides":       len(c.ScheduledOverrides) > 0,
+		"job-policies":              len(c.JobPolicies) > 0,
+		"priority-runners":          c.PriorityRunners > 0,
+		"pending-runners":           c.PendingRunners != nil,
+		"idle-timeout":              c.IdleTimeout != nil,
+		"predictor":                 c.Predictor != nil,
+		"capacity-forecast":         c.CapacityForecast != nil,
+		"capacity-forecast-webhook": c.CapacityForecast != nil && c.CapacityForecast.Webhook != nil,
+		"duplicate-patch-ttl":       c.DuplicatePatchTTL != nil,
+		"runner-minutes-budget":     c.MaxRunnerMinutesPerDay > 0,
+		"session-fallback":          c.FallbackAfter != nil,
+		"session-startup-timeout":   c.SessionStartupTimeout != nil,
+		"health":                    c.HealthAddr != "",
+		"gops":                      c.GopsAddr != "",
+		"debug-server":              c.DebugAddr != "",
+		"keda-scaler":               c.KedaScalerAddr != "",
+		"log-sampling":              c.LogSampling != nil,
+		"admin-api":                 c.AdminAddr != "",
+		"admin-tls":                 c.AdminTLSCertFile != "",
+		"admin-socket":              c.AdminSocket != "",
+		"max-uptime":                c.MaxUptime != nil,
+		"idle-exit":                 c.IdleExitAfter != nil,
+		"resume-session":            c.ResumeSession,
+		"leader-election":           c.LeaderElection != nil,
+		"dead-letter":               c.DeadLetterFile != "",
+		"state-export":              c.StateExport != nil,
+		"http-client":               c.HTTPClient != nil,
+		"proxy":                     c.HTTPProxy != "" || c.HTTPSProxy != "",
+		"runner-limits-config-map":  c.RunnerLimitsConfigMap != nil,
+		"migration":                 c.Migration != nil,
+		"canary":                    c.Canary != nil,
+		"topology-spread":           c.TopologySpread != nil,
+		"audit-file":                c.Audit != nil && c.Audit.File != "",
+		"audit-http":                c.Audit != nil && c.Audit.URL != "",
+		"job-notifications":         c.JobNotifications != nil,
+		"scale-target-kubernetes":   c.ScaleTarget != nil && c.ScaleTarget.Kubernetes != nil,
+		"scale-target-webhook":      c.ScaleTarget != nil && c.ScaleTarget.Webhook != nil,
+	}
+	features := []string{}
+	for feature, ok := range enabled {
+		if ok {
+			features = append(features, feature)
+		}
+	}
+	sort.Strings(features)
+
+	shape := telemetry.Shape{Features: features}
+	if ghConfig != nil {
+		shape.Hosted = ghConfig.IsHosted
+		switch ghConfig.Scope {
+		case actions.GitHubScopeEnterprise:
+			shape.Scope = "enterprise"
+		case actions.GitHubScopeOrganization:
+			shape.Scope = "organization"
+		case actions.GitHubScopeRepository:
+			shape.Scope = "repository"
+		}
+	}
+	return shape
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/telemetry"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetryShape(t *testing.T) {
+	t.Parallel()
+
+	c := &config.Config{
+		ConfigureUrl:                "https://github.com/org",
+		EphemeralRunnerSetNamespace: "namespace",
+		EphemeralRunnerSetName:      "deployment",
+		RunnerScaleSetId:            1,
+		AppConfig:                   &appconfig.AppConfig{Token: "token"},
+		MetricsAddr:                 ":8080",
+		HealthAddr:                  ":8081",
+		Canary:                      &config.Canary{EphemeralRunnerSetName: "canary", Percentage: 10},
+		ScaleTarget:                 &config.ScaleTarget{Webhook: &config.WebhookScaleTarget{URL: "https://scaler.example.com"}},
+	}
+	ghConfig, err := actions.ParseGitHubConfigFromURL(c.ConfigureUrl)
+	require.NoError(t, err)
+
+	assert.Equal(t, telemetry.Shape{
+		Scope:    "organization",
+		Hosted:   true,
+		Features: []string{"canary", "health", "metrics-server", "scale-target-webhook"},
+	}, telemetryShape(c, ghConfig))
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/admin"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
+)
+
+// adminStatus returns the state of the listener shown by the status page of the admin server.
+// The configuration summary is built once, since the config does not change while the listener runs.
+func adminStatus(c *config.Config, state func() worker.State, jobs func() []worker.Job) func() admin.Status {
+	scaleSet := c.EphemeralRunnerSetNamespace + "/" + c.EphemeralRunnerSetName
+	target := scaleTargetDescription(c)
+	summary := configSummary(c)
+	return func() admin.Status {
+		return admin.Status{
+			ScaleSet: scaleSet,
+			Target:   target,
+			Scaling:  state(),
+			Jobs:     jobs(),
+			Config:   summary,
+		}
+	}
+}
+
+// scaleTargetDescription describes the resource the scaling decisions are applied to.
+func scaleTargetDescription(c *config.Config) string {
+	switch {
+	case c.ScaleTarget != nil && c.ScaleTarget.Kubernetes != nil:
+		t := c.ScaleTarget.Kubernetes
+		return fmt.Sprintf("%s %s/%s (%s)",
+			cmp.Or(t.Resource, config.DefaultScaleTargetResource),
+			cmp.Or(t.Namespace, c.EphemeralRunnerSetNamespace),
+			t.Name,
+			cmp.Or(t.APIVersion, config.DefaultScaleTargetAPIVersion),
+		)
+	case c.ScaleTarget != nil && c.ScaleTarget.Webhook != nil:
+		if u, err := url.Parse(c.ScaleTarget.Webhook.URL); err == nil {
+			return "webhook " + u.Redacted()
+		}
+		return "webhook"
+	default:
+		return fmt.Sprintf("ephemeralrunnersets %s/%s", c.EphemeralRunnerSetNamespace, c.EphemeralRunnerSetName)
+	}
+}
+
+// configSummary lists the settings operators look for first, and the features enabled,
+// leaving out the credentials.
+func configSummary(c *config.Config) []admin.Setting {
+	configureURL := c.ConfigureUrl
+	if u, err := url.Parse(c.ConfigureUrl); err == nil {
+		configureURL = u.Redacted()
+	}
+	return []admin.Setting{
+		{Name: "Configure URL", Value: configureURL},
+		{Name: "Runner scale set ID", Value: strconv.Itoa(c.RunnerScaleSetId)},
+		{Name: "Runner scale set name", Value: c.RunnerScaleSetName},
+		{Name: "Min runners", Value: strconv.Itoa(c.MinRunners)},
+		{Name: "Max runners", Value: strconv.Itoa(c.MaxRunners)},
+		{Name: "Scheduled overrides", Value: strconv.Itoa(len(c.ScheduledOverrides))},
+		{Name: "Message concurrency", Value: strconv.Itoa(max(c.MessageConcurrency, 1))},
+		{Name: "Features", Value: strings.Join(telemetryShape(c, nil).Features, ", ")},
+	}
+}
@@ -0,0 +1,240 @@
+// Package audit writes a structured, tamper-evident record of the job lifecycle events handled by the listener:
+// every job started and job completed message, and the desired runner count of every message, so security teams
+// can trace which runner executed which job.
+//
+// The records are chained: every record holds the hash of the previous one, and its own hash covers it.
+// The hash is an HMAC-SHA256 when a secret is configured, so the chain cannot be recomputed without it.
+// An altered, removed, or reordered record, as well as one that failed to be written, breaks the chain,
+// which Verify detects. Every start of the listener begins a new chain, with sequence 1 and no previous hash.
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"k8s.io/utils/clock"
+)
+
+// The events of the records.
+const (
+	EventJobStarted         = "JobStarted"
+	EventJobCompleted       = "JobCompleted"
+	EventDesiredRunnerCount = "DesiredRunnerCount"
+)
+
+// Record is an audit record.
+type Record struct {
+	// Sequence is the position of the record in the chain, starting at 1.
+	Sequence int64     `json:"sequence"`
+	Time     time.Time `json:"time"`
+	// Event is one of the Event constants.
+	Event    string   `json:"event"`
+	ScaleSet ScaleSet `json:"scaleSet"`
+	// Job is set on the job started and job completed records.
+	Job *Job `json:"job,omitempty"`
+	// DesiredRunners is set on the desired runner count records.
+	DesiredRunners *DesiredRunners `json:"desiredRunners,omitempty"`
+	// PrevHash is the hash of the previous record of the chain, empty on its first record.
+	PrevHash string `json:"prevHash"`
+	// Hash is the hex digest of the record with an empty Hash.
+	Hash string `json:"hash"`
+}
+
+// ScaleSet identifies the scale set of the listener.
+type ScaleSet struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// Job is the job of a job started or job completed record.
+type Job struct {
+	JobID           string `json:"jobId"`
+	RunnerRequestID int64  `json:"runnerRequestId"`
+	WorkflowRunID   int64  `json:"workflowRunId"`
+	Owner           string `json:"owner"`
+	Repository      string `json:"repository"`
+	WorkflowRef     string `json:"workflowRef"`
+	RunnerName      string `json:"runnerName"`
+	RunnerID        int    `json:"runnerId"`
+	// WorkflowRunURL is the URL of the workflow run of the job, whose page links to the logs of the job,
+	// set if the URL of the GitHub server is known. The job messages do not carry the ID the GitHub UI
+	// links the logs of the job itself with.
+	WorkflowRunURL string `json:"workflowRunUrl,omitempty"`
+	// Result is the result of a completed job.
+	Result             string     `json:"result,omitempty"`
+	QueueTime          time.Time  `json:"queueTime"`
+	ScaleSetAssignTime time.Time  `json:"scaleSetAssignTime"`
+	RunnerAssignTime   time.Time  `json:"runnerAssignTime"`
+	FinishTime         *time.Time `json:"finishTime,omitempty"`
+}
+
+// DesiredRunners is the desired runner count of a message.
+type DesiredRunners struct {
+	AssignedJobs  int `json:"assignedJobs"`
+	JobsCompleted int `json:"jobsCompleted"`
+	Count         int `json:"count"`
+}
+
+// Sink receives the records, marshalled as JSON.
+type Sink interface {
+	Write(ctx context.Context, record []byte) error
+}
+
+// Config configures the Log.
+type Config struct {
+	// Sink receives the records. It is required.
+	Sink Sink
+	// Secret is the key of the HMAC-SHA256 of the records, if set. The records are hashed with SHA-256 otherwise.
+	Secret   []byte
+	ScaleSet ScaleSet
+	// GitHubURL is the URL of the GitHub server the links to the workflow runs are built from, if set.
+	GitHubURL string
+	// Clock stamps the records. Defaults to the real clock.
+	Clock  clock.PassiveClock
+	Logger logr.Logger
+}
+
+// Log writes the audit records to its sink. A nil Log discards them.
+type Log struct {
+	sink      Sink
+	secret    []byte
+	scaleSet  ScaleSet
+	gitHubURL string
+	clock     clock.PassiveClock
+	logger    logr.Logger
+
+	// mu serializes the records, so they are written in the order of the chain.
+	mu       sync.Mutex
+	sequence int64
+	prevHash string
+}
+
+func New(config Config) *Log {
+	l := &Log{
+		sink:      config.Sink,
+		secret:    config.Secret,
+		scaleSet:  config.ScaleSet,
+		gitHubURL: strings.TrimSuffix(config.GitHubURL, "/"),
+		clock:     config.Clock,
+		logger:    config.Logger,
+	}
+	if l.clock == nil {
+		l.clock = clock.RealClock{}
+	}
+	return l
+}
+
+// JobStarted records the job started on the runner.
+func (l *Log) JobStarted(ctx context.Context, jobStarted *actions.JobStarted) {
+	if l == nil {
+		return
+	}
+	job := l.newJob(&jobStarted.JobMessageBase)
+	job.RunnerName = jobStarted.RunnerName
+	job.RunnerID = jobStarted.RunnerID
+	l.write(ctx, &Record{Event: EventJobStarted, Job: job})
+}
+
+// JobCompleted records the job completed on the runner, with its result.
+func (l *Log) JobCompleted(ctx context.Context, jobCompleted *actions.JobCompleted) {
+	if l == nil {
+		return
+	}
+	job := l.newJob(&jobCompleted.JobMessageBase)
+	job.RunnerName = jobCompleted.RunnerName
+	job.RunnerID = jobCompleted.RunnerId
+	job.Result = jobCompleted.Result
+	finishTime := jobCompleted.FinishTime
+	job.FinishTime = &finishTime
+	l.write(ctx, &Record{Event: EventJobCompleted, Job: job})
+}
+
+// DesiredRunnerCount records the desired runner count of a message.
+func (l *Log) DesiredRunnerCount(ctx context.Context, assignedJobs, jobsCompleted, count int) {
+	if l == nil {
+		return
+	}
+	l.write(ctx, &Record{
+		Event: EventDesiredRunnerCount,
+		DesiredRunners: &DesiredRunners{
+			AssignedJobs:  assignedJobs,
+			JobsCompleted: jobsCompleted,
+			Count:         count,
+		},
+	})
+}
+
+func (l *Log) newJob(base *actions.JobMessageBase) *Job {
+	job := &Job{
+		JobID:              base.JobID,
+		RunnerRequestID:    base.RunnerRequestID,
+		WorkflowRunID:      base.WorkflowRunID,
+		Owner:              base.OwnerName,
+		Repository:         base.RepositoryName,
+		WorkflowRef:        base.JobWorkflowRef,
+		QueueTime:          base.QueueTime,
+		ScaleSetAssignTime: base.ScaleSetAssignTime,
+		RunnerAssignTime:   base.RunnerAssignTime,
+	}
+	if l.gitHubURL != "" && base.WorkflowRunID != 0 {
+		job.WorkflowRunURL = fmt.Sprintf("%s/%s/%s/actions/runs/%d", l.gitHubURL, base.OwnerName, base.RepositoryName, base.WorkflowRunID)
+	}
+	return job
+}
+
+// write chains the record and writes it to the sink. The chain advances even if the write fails,
+// so the missing record shows as a break of the chain.
+func (l *Log) write(ctx context.Context, record *Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sequence++
+	record.Sequence = l.sequence
+	record.Time = l.clock.Now().UTC()
+	record.ScaleSet = l.scaleSet
+	record.PrevHash = l.prevHash
+
+	data, err := seal(record, l.secret)
+	if err != nil {
+		l.logger.Error(err, "Failed to marshal the audit record", "event", record.Event, "sequence", record.Sequence)
+		return
+	}
+	l.prevHash = record.Hash
+
+	if err := l.sink.Write(ctx, data); err != nil {
+		l.logger.Error(err, "Failed to write the audit record", "event", record.Event, "sequence", record.Sequence)
+	}
+}
+
+// seal sets the hash of the record and returns the record marshalled with it.
+func seal(record *Record, secret []byte) ([]byte, error) {
+	record.Hash = ""
+	unsealed, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	record.Hash = digest(unsealed, secret)
+	return json.Marshal(record)
+}
+
+func digest(data, secret []byte) string {
+	var h hash.Hash
+	if len(secret) > 0 {
+		h = hmac.New(sha256.New, secret)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
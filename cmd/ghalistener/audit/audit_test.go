@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func newTestLog(t *testing.T, sink Sink, secret []byte) *Log {
+	t.Helper()
+	return New(Config{
+		Sink:     sink,
+		Secret:   secret,
+		ScaleSet: ScaleSet{ID: 1, Name: "set", Namespace: "namespace"},
+		Clock:    clocktesting.NewFakeClock(time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)),
+		Logger:   logr.Discard(),
+	})
+}
+
+func writeRecords(l *Log) {
+	ctx := context.Background()
+	l.JobStarted(ctx, &actions.JobStarted{
+		JobMessageBase: actions.JobMessageBase{
+			RunnerRequestID: 1,
+			JobID:           "1",
+			OwnerName:       "owner",
+			RepositoryName:  "repo",
+			JobWorkflowRef:  "owner/repo/.github/workflows/ci.yaml@refs/heads/main",
+			QueueTime:       time.Date(2026, 5, 1, 8, 59, 0, 0, time.UTC),
+		},
+		RunnerID:   3,
+		RunnerName: "runner",
+	})
+	l.JobCompleted(ctx, &actions.JobCompleted{
+		JobMessageBase: actions.JobMessageBase{
+			RunnerRequestID: 1,
+			JobID:           "1",
+			FinishTime:      time.Date(2026, 5, 1, 9, 10, 0, 0, time.UTC),
+		},
+		Result:     "succeeded",
+		RunnerId:   3,
+		RunnerName: "runner",
+	})
+	l.DesiredRunnerCount(ctx, 2, 1, 3)
+}
+
+func TestLog_ChainsRecords(t *testing.T) {
+	var buf bytes.Buffer
+	writeRecords(newTestLog(t, NewWriterSink(&buf), nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+
+	var records []Record
+	for _, line := range lines {
+		var record Record
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+
+	assert.Equal(t, EventJobStarted, records[0].Event)
+	assert.Equal(t, int64(1), records[0].Sequence)
+	assert.Empty(t, records[0].PrevHash)
+	assert.Equal(t, ScaleSet{ID: 1, Name: "set", Namespace: "namespace"}, records[0].ScaleSet)
+	assert.Equal(t, "runner", records[0].Job.RunnerName)
+	assert.Equal(t, "owner/repo/.github/workflows/ci.yaml@refs/heads/main", records[0].Job.WorkflowRef)
+	assert.Nil(t, records[0].Job.FinishTime)
+
+	assert.Equal(t, EventJobCompleted, records[1].Event)
+	assert.Equal(t, records[0].Hash, records[1].PrevHash)
+	assert.Equal(t, "succeeded", records[1].Job.Result)
+	require.NotNil(t, records[1].Job.FinishTime)
+
+	assert.Equal(t, EventDesiredRunnerCount, records[2].Event)
+	assert.Equal(t, records[1].Hash, records[2].PrevHash)
+	assert.Equal(t, &DesiredRunners{AssignedJobs: 2, JobsCompleted: 1, Count: 3}, records[2].DesiredRunners)
+
+	count, err := Verify(&buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	secret := []byte("secret")
+	var buf bytes.Buffer
+	writeRecords(newTestLog(t, NewWriterSink(&buf), secret))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	tests := map[string][]string{
+		"altered":   {lines[0], strings.Replace(lines[1], "succeeded", "failed", 1), lines[2]},
+		"removed":   {lines[0], lines[2]},
+		"reordered": {lines[1], lines[0], lines[2]},
+	}
+	for name, lines := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(strings.Join(lines, "\n")), secret)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		_, err := Verify(bytes.NewReader(buf.Bytes()), []byte("other"))
+		assert.ErrorContains(t, err, "does not match its hash")
+	})
+
+	t.Run("RestartedChain", func(t *testing.T) {
+		var restarted bytes.Buffer
+		restarted.Write(buf.Bytes())
+		writeRecords(newTestLog(t, NewWriterSink(&restarted), secret))
+		count, err := Verify(&restarted, secret)
+		require.NoError(t, err)
+		assert.Equal(t, 6, count)
+	})
+}
+
+type failingSink struct {
+	records [][]byte
+	fail    bool
+}
+
+func (s *failingSink) Write(_ context.Context, record []byte) error {
+	if s.fail {
+		s.fail = false
+		return errors.New("unavailable")
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func TestLog_FailedWriteBreaksChain(t *testing.T) {
+	sink := &failingSink{}
+	l := newTestLog(t, sink, nil)
+
+	l.DesiredRunnerCount(context.Background(), 1, 0, 1)
+	sink.fail = true
+	l.DesiredRunnerCount(context.Background(), 2, 0, 2)
+	l.DesiredRunnerCount(context.Background(), 3, 0, 3)
+
+	require.Len(t, sink.records, 2)
+	_, err := Verify(bytes.NewReader(bytes.Join(sink.records, []byte("\n"))), nil)
+	assert.ErrorContains(t, err, "does not follow the record with sequence 1")
+}
+
+func TestLog_NilIsNoop(t *testing.T) {
+	var l *Log
+	writeRecords(l)
+}
+
+func TestLog_WorkflowRunURL(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{
+		Sink:      NewWriterSink(&buf),
+		GitHubURL: "https://github.example.com/",
+		Logger:    logr.Discard(),
+	})
+	base := actions.JobMessageBase{JobID: "1", OwnerName: "owner", RepositoryName: "repo", WorkflowRunID: 42}
+	l.JobStarted(context.Background(), &actions.JobStarted{JobMessageBase: base})
+	l.JobCompleted(context.Background(), &actions.JobCompleted{JobMessageBase: base, Result: "failed"})
+	base.WorkflowRunID = 0
+	l.JobStarted(context.Background(), &actions.JobStarted{JobMessageBase: base})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	for i, want := range []string{
+		"https://github.example.com/owner/repo/actions/runs/42",
+		"https://github.example.com/owner/repo/actions/runs/42",
+		"",
+	} {
+		var record Record
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &record))
+		assert.Equal(t, want, record.Job.WorkflowRunURL, lines[i])
+	}
+}
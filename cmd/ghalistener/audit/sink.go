@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/webhook"
+	"github.com/go-logr/logr"
+	"k8s.io/utils/clock"
+)
+
+// WriterSink appends the records to a writer, one JSON record per line,
+// e.g. a file of the work directory.
+type WriterSink struct {
+	w io.Writer
+}
+
+var _ Sink = (*WriterSink)(nil)
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(_ context.Context, record []byte) error {
+	_, err := s.w.Write(append(record, '\n'))
+	return err
+}
+
+// The queue and retries of the HTTPSink.
+const (
+	// httpQueueSize is the number of records waiting to be posted, beyond which they are dropped.
+	httpQueueSize = 1000
+	// httpMaxAttempts is the number of times a record is posted before it is dropped.
+	httpMaxAttempts = 5
+	httpMinBackoff  = time.Second
+	httpMaxBackoff  = 30 * time.Second
+)
+
+// HTTPSinkConfig configures the HTTPSink.
+type HTTPSinkConfig struct {
+	URL    string
+	Client *http.Client
+	// BearerTokenFile is the file holding the bearer token sent with the records, if set.
+	// It is read for every request, so the token can be rotated.
+	BearerTokenFile string
+	// Clock times the retries. Defaults to the real clock.
+	Clock  clock.Clock
+	Logger logr.Logger
+}
+
+// HTTPSink posts every record as JSON to an endpoint, e.g. the collector of a SIEM,
+// which must answer with a 2xx status.
+//
+// The records are queued and posted in order in the background by Run, so a slow or unavailable endpoint
+// does not delay the messages of the listener. A record failing with a network error, a 429 or a 5xx status
+// is retried with an exponential backoff; it is dropped once it failed httpMaxAttempts times, or when the
+// queue is full, which shows as a break of the chain.
+type HTTPSink struct {
+	config HTTPSinkConfig
+	queue  chan []byte
+}
+
+var _ Sink = (*HTTPSink)(nil)
+
+func NewHTTPSink(config HTTPSinkConfig) *HTTPSink {
+	if config.Clock == nil {
+		config.Clock = clock.RealClock{}
+	}
+	return &HTTPSink{
+		config: config,
+		queue:  make(chan []byte, httpQueueSize),
+	}
+}
+
+// Write queues the record, failing if the queue is full.
+func (s *HTTPSink) Write(_ context.Context, record []byte) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return errors.New("audit queue is full")
+	}
+}
+
+// Run posts the queued records until the context is cancelled.
+func (s *HTTPSink) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			if n := len(s.queue); n > 0 {
+				s.config.Logger.Info("Dropping the audit records not posted before stopping", "records", n)
+			}
+			return
+		case record := <-s.queue:
+			if err := s.send(ctx, record); err != nil && ctx.Err() == nil {
+				s.config.Logger.Error(err, "Failed to post the audit record, dropping it", "attempts", httpMaxAttempts)
+			}
+		}
+	}
+}
+
+// send posts the record, retrying the transient failures.
+func (s *HTTPSink) send(ctx context.Context, record []byte) error {
+	backoff := httpMinBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.post(ctx, record)
+		if err == nil || !isRetryable(err) || attempt == httpMaxAttempts {
+			return err
+		}
+		s.config.Logger.V(1).Info("Retrying the audit record", "error", err.Error(), "attempt", attempt, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.config.Clock.After(backoff):
+		}
+		backoff = min(2*backoff, httpMaxBackoff)
+	}
+}
+
+// isRetryable reports whether the post may succeed if retried.
+func isRetryable(err error) bool {
+	var statusErr *webhook.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= 500
+	}
+	return true
+}
+
+func (s *HTTPSink) post(ctx context.Context, record []byte) error {
+	endpoint := webhook.Endpoint{
+		URL:             s.config.URL,
+		Client:          s.config.Client,
+		BearerTokenFile: s.config.BearerTokenFile,
+	}
+	if err := endpoint.Post(ctx, record); err != nil {
+		return fmt.Errorf("failed to post audit record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestHTTPSink(t *testing.T) {
+	var bodies []string
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		authorization = r.Header.Get("Authorization")
+		bodies = append(bodies, string(body))
+		if string(body) == `{"fail":true}` {
+			http.Error(w, "rejected", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token\n"), 0o600))
+
+	sink := NewHTTPSink(HTTPSinkConfig{
+		URL:             server.URL,
+		Client:          server.Client(),
+		BearerTokenFile: tokenFile,
+		Logger:          logr.Discard(),
+	})
+
+	require.NoError(t, sink.post(context.Background(), []byte(`{"sequence":1}`)))
+	assert.Equal(t, []string{`{"sequence":1}`}, bodies)
+	assert.Equal(t, "Bearer token", authorization)
+
+	err := sink.send(context.Background(), []byte(`{"fail":true}`))
+	assert.ErrorContains(t, err, "failed to post audit record: webhook answered with status 400: rejected")
+	assert.Len(t, bodies, 2, "the records rejected by the endpoint are not retried")
+}
+
+func TestHTTPSink_Queue(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	failures := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	t.Cleanup(server.Close)
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	sink := NewHTTPSink(HTTPSinkConfig{
+		URL:    server.URL,
+		Client: server.Client(),
+		Clock:  fakeClock,
+		Logger: logr.Discard(),
+	})
+
+	require.NoError(t, sink.Write(context.Background(), []byte(`{"sequence":1}`)))
+	require.NoError(t, sink.Write(context.Background(), []byte(`{"sequence":2}`)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go sink.Run(ctx)
+
+	for range 2 {
+		require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+		fakeClock.Step(httpMaxBackoff)
+	}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(bodies) == 2
+	}, time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{`{"sequence":1}`, `{"sequence":2}`}, bodies, "the records are retried and posted in order")
+	mu.Unlock()
+
+	t.Run("full", func(t *testing.T) {
+		sink := NewHTTPSink(HTTPSinkConfig{URL: server.URL, Client: server.Client(), Logger: logr.Discard()})
+		for range httpQueueSize {
+			require.NoError(t, sink.Write(context.Background(), []byte(`{}`)))
+		}
+		assert.ErrorContains(t, sink.Write(context.Background(), []byte(`{}`)), "audit queue is full")
+	})
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxRecordBytes bounds the size of a record read by Verify.
+const maxRecordBytes = 1 << 20
+
+// Verify checks the chains of the records read from r, one JSON record per line, as written by a WriterSink.
+// It returns the number of records verified, and an error locating the first record breaking its chain.
+// The secret must be the one the records were written with, if any.
+func Verify(r io.Reader, secret []byte) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxRecordBytes)
+
+	var (
+		count    int
+		sequence int64
+		prevHash string
+	)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		count++
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return count - 1, fmt.Errorf("record %d is invalid: %w", count, err)
+		}
+
+		// A listener start begins a new chain.
+		if record.Sequence == 1 && record.PrevHash == "" {
+			sequence, prevHash = 0, ""
+		}
+		if record.Sequence != sequence+1 || record.PrevHash != prevHash {
+			return count - 1, fmt.Errorf("record %d with sequence %d does not follow the record with sequence %d", count, record.Sequence, sequence)
+		}
+
+		want := record.Hash
+		if _, err := seal(&record, secret); err != nil {
+			return count - 1, fmt.Errorf("record %d is invalid: %w", count, err)
+		}
+		if record.Hash != want {
+			return count - 1, fmt.Errorf("record %d with sequence %d does not match its hash", count, record.Sequence)
+		}
+
+		sequence, prevHash = record.Sequence, record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("failed to read the records: %w", err)
+	}
+	return count, nil
+}
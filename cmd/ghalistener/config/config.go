@@ -1,94 +1,937 @@
 package config
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
 	"github.com/actions/actions-runner-controller/build"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/gops"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/notify"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/revocation"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/worker"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/logging"
 	"github.com/actions/actions-runner-controller/vault"
 	"github.com/actions/actions-runner-controller/vault/azurekeyvault"
+	"github.com/actions/actions-runner-controller/vault/execvault"
+	"github.com/actions/actions-runner-controller/vault/kubernetesvault"
 	"github.com/go-logr/logr"
 	"golang.org/x/net/http/httpproxy"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// kubernetesSecretVolumeDataDir is the directory, relative to the mount path, that the kubelet
+// links the files of a secret volume to.
+const kubernetesSecretVolumeDataDir = "..data/"
+
+// Config is the configuration of the listener.
+//
+// The controller renders it from the AutoscalingListener, setting the scale set, its runner bounds and its runner
+// minutes budget, the GitHub credentials or vault, the TLS settings of the GitHub server, the metrics server, and the log settings. The other
+// settings are options of standalone listeners, run from a config of their own outside of the controller, e.g. as a
+// Deployment: the listener pod created by the controller never has them set, and its role only grants the access the
+// rendered settings need. The role of a standalone listener must grant the access documented by the options it sets.
 type Config struct {
 	ConfigureUrl   string          `json:"configure_url"`
 	VaultType      vault.VaultType `json:"vault_type"`
 	VaultLookupKey string          `json:"vault_lookup_key"`
 	// If the VaultType is set to "azure_key_vault", this field must be populated.
 	AzureKeyVaultConfig *azurekeyvault.Config `json:"azure_key_vault,omitempty"`
+	// If the VaultType is set to "exec", this field must be populated.
+	ExecVaultConfig *execvault.Config `json:"exec_vault,omitempty"`
+	// VaultRefreshInterval is the interval at which the GitHub App configuration is re-read from the vault.
+	// If it is not set, the vault is only read once at startup.
+	VaultRefreshInterval *metav1.Duration `json:"vault_refresh_interval,omitempty"`
+	// VaultCache caches the secrets read from the vault, so that a brief outage of the vault does not prevent
+	// the listener from starting or rotating its credentials. If it is not set, the vault is read on every lookup.
+	VaultCache *VaultCache `json:"vault_cache,omitempty"`
+	// CredentialsRefreshInterval is the interval at which the files of the AppPrivateKeyFile or the TokenFile
+	// are re-read. The actions client is only rebuilt when the credentials changed. Defaults to 1 minute.
+	CredentialsRefreshInterval *metav1.Duration `json:"credentials_refresh_interval,omitempty"`
 	// AppConfig contains the GitHub App configuration.
 	// It is initially set to nil if VaultType is set.
-	// Otherwise, it is populated with the GitHub App credentials from the GitHub secret.
+	// Otherwise, it is populated with the GitHub App credentials from the GitHub secret, or with the files
+	// they are read from, e.g. the keys of the listener config Secret the controller mounts. The files are
+	// re-read periodically, so that the rotated credentials are picked up without restarting the listener.
 	*appconfig.AppConfig
-	EphemeralRunnerSetNamespace string                  `json:"ephemeral_runner_set_namespace"`
-	EphemeralRunnerSetName      string                  `json:"ephemeral_runner_set_name"`
-	MaxRunners                  int                     `json:"max_runners"`
-	MinRunners                  int                     `json:"min_runners"`
-	RunnerScaleSetId            int                     `json:"runner_scale_set_id"`
-	RunnerScaleSetName          string                  `json:"runner_scale_set_name"`
-	ServerRootCA                string                  `json:"server_root_ca"`
-	LogLevel                    string                  `json:"log_level"`
-	LogFormat                   string                  `json:"log_format"`
-	MetricsAddr                 string                  `json:"metrics_addr"`
-	MetricsEndpoint             string                  `json:"metrics_endpoint"`
-	Metrics                     *v1alpha1.MetricsConfig `json:"metrics"`
+	EphemeralRunnerSetNamespace string `json:"ephemeral_runner_set_namespace"`
+	EphemeralRunnerSetName      string `json:"ephemeral_runner_set_name"`
+	MaxRunners                  int    `json:"max_runners"`
+	MinRunners                  int    `json:"min_runners"`
+	RunnerScaleSetId            int    `json:"runner_scale_set_id"`
+	RunnerScaleSetName          string `json:"runner_scale_set_name"`
+	ServerRootCA                string `json:"server_root_ca"`
+	LogLevel                    string `json:"log_level"`
+	LogFormat                   string `json:"log_format"`
+	// ServerRootCAFiles are the files of the PEM bundles of root certificates the GitHub server is verified with,
+	// in addition to the system ones and ServerRootCA, e.g. mounted from a ConfigMap shared by the scale sets
+	// so that the CAs are rotated in one place. They are re-read whenever the actions client is rebuilt.
+	// The controller does not read them, the listeners it manages being passed the CA of the githubServerTLS
+	// ConfigMap of their scale set in ServerRootCA, which the controller verifies the server with as well.
+	ServerRootCAFiles []string `json:"server_root_ca_files,omitempty"`
+	// ServerRootCADir is a directory of PEM bundles of root certificates, all of its .pem and .crt files being read.
+	ServerRootCADir string `json:"server_root_ca_dir,omitempty"`
+	// ServerCertRevocation checks the certificates of the GitHub Enterprise Server are not revoked, e.g. the ones
+	// issued by an internal CA. Only the connections to its host are checked, and it cannot be set for github.com
+	// and ghe.com. If it is not set, the revocation is not checked.
+	ServerCertRevocation *ServerCertRevocation `json:"server_cert_revocation,omitempty"`
+	// LogSampling samples the repetitive logs, which busy scale sets write for every message, if set.
+	LogSampling     *LogSampling            `json:"log_sampling,omitempty"`
+	MetricsAddr     string                  `json:"metrics_addr"`
+	MetricsEndpoint string                  `json:"metrics_endpoint"`
+	Metrics         *v1alpha1.MetricsConfig `json:"metrics"`
+	// MetricsTLSCertFile and MetricsTLSKeyFile are the paths of the certificate and key
+	// the metrics endpoint is served with over TLS. If they are not set, it is served over plain HTTP.
+	MetricsTLSCertFile string `json:"metrics_tls_cert_file,omitempty"`
+	MetricsTLSKeyFile  string `json:"metrics_tls_key_file,omitempty"`
+	// MetricsBearerTokenFile is the path of the file holding the bearer token scrapes of the metrics endpoint must present.
+	MetricsBearerTokenFile string `json:"metrics_bearer_token_file,omitempty"`
+	// MetricsBasicAuthUsername and MetricsBasicAuthPasswordFile are the username, and the path of the file holding
+	// the password, scrapes of the metrics endpoint must present with basic auth.
+	// When both a bearer token and basic auth are configured, scrapes presenting either are accepted.
+	MetricsBasicAuthUsername     string `json:"metrics_basic_auth_username,omitempty"`
+	MetricsBasicAuthPasswordFile string `json:"metrics_basic_auth_password_file,omitempty"`
+	// StaleRunnerGracePeriod is the time an ephemeral runner may still exist after its job completed
+	// before the listener annotates it for priority garbage collection.
+	// If it is not set, ephemeral runners are never annotated.
+	StaleRunnerGracePeriod *metav1.Duration `json:"stale_runner_grace_period,omitempty"`
+	// MissingRunnerPolicy is how a started job is handled when its ephemeral runner is not found:
+	// "skip" skips updating the job information of the runner, "retry" retries it with backoff for about
+	// a minute before skipping it, and "fail" stops the listener. Every miss is counted by the
+	// gha_ephemeral_runner_misses_total metric. Defaults to "skip".
+	MissingRunnerPolicy string `json:"missing_runner_policy,omitempty"`
+	// MisroutedJobPolicy is how the jobs requiring labels the scale set does not provide are handled:
+	// "provision" provisions runners for them like for the other jobs, and "exclude" does not acquire them
+	// and excludes them from the desired runner count, since no runner of the scale set can run them.
+	// Every misrouted job is counted by the gha_misrouted_jobs_total metric and recorded as a warning event
+	// on the ephemeral runner set, along with its workflow. Defaults to "provision".
+	MisroutedJobPolicy string `json:"misrouted_job_policy,omitempty"`
+	// EphemeralRunnerCache watches the metadata of the ephemeral runners of the namespace, so that the status
+	// patches and the garbage collection annotations of the runners which no longer exist are not sent to the
+	// API server, which cuts its traffic in large scale sets. It cannot be set along with ScaleTarget.
+	// The role of the listener must allow to list, watch, and get the ephemeral runners: the runners missing
+	// from the cache are looked up, since the watch lags behind the runners just created.
+	EphemeralRunnerCache bool `json:"ephemeral_runner_cache,omitempty"`
+	// MessageConcurrency is the maximum number of job messages the listener handles in parallel.
+	MessageConcurrency int `json:"message_concurrency,omitempty"`
+	// MaxScaleUpStep is the maximum number of runners added by a single scale decision.
+	// Zero means unlimited.
+	MaxScaleUpStep int `json:"max_scale_up_step,omitempty"`
+	// MaxScaleDownStep is the maximum number of runners removed by a single scale decision.
+	// Zero means unlimited.
+	MaxScaleDownStep int `json:"max_scale_down_step,omitempty"`
+	// DuplicatePatchTTL is the time the scale decisions repeating the runners of the last patch are not
+	// patched to the ephemeral runner set, which cuts the writes of the scale sets steady at a runner count.
+	// The runners are patched again once it elapses, which resyncs the ephemeral runner set, and whenever jobs
+	// completed since the last patch.
+	// If it is not set, every scale decision is patched.
+	DuplicatePatchTTL *metav1.Duration `json:"duplicate_patch_ttl,omitempty"`
+	// ScheduledOverrides override MinRunners and MaxRunners during time windows.
+	// The earlier an override is in the list, the higher it is prioritized.
+	ScheduledOverrides []ScheduledOverride `json:"scheduled_overrides,omitempty"`
+	// JobPolicies adjust the runners the jobs they match count for toward the desired runner count,
+	// e.g. so that the jobs of a heavy workflow count double, or the jobs of a repository are capped.
+	// A job is matched by the first policy of the list whose selectors all match it.
+	JobPolicies []JobPolicy `json:"job_policies,omitempty"`
+	// PriorityRunners clamps the runners provisioned for the jobs which are not of a priority JobPolicy to
+	// MaxRunners minus PriorityRunners, e.g. so that the jobs of a busy low-priority repository cannot make the
+	// scale set ask for all of MaxRunners while the deployment pipelines wait. It is a clamp of the desired runner
+	// count, not a reservation: the runners are not isolated, and GitHub assigns the jobs to them first come,
+	// first served, so a priority job only runs on the headroom if it is queued before the other jobs take it.
+	PriorityRunners int `json:"priority_runners,omitempty"`
+	// IdleTimeout is the time without assigned jobs after which the warm runners kept by MinRunners,
+	// or by the MinRunners of an active scheduled override, are scaled down to HardMinRunners
+	// until a job is assigned again. If it is not set, MinRunners is always kept.
+	IdleTimeout *metav1.Duration `json:"idle_timeout,omitempty"`
+	// HardMinRunners is the minimum number of runners kept once the scale set was idle for IdleTimeout.
+	// It cannot be greater than MinRunners.
+	HardMinRunners int `json:"hard_min_runners,omitempty"`
+	// MaxRunnerMinutesPerDay is the budget of runner minutes the runners of the scale set can run per UTC day,
+	// read from the status of the ephemeral runner sets, where the controller counts the time their runners ran.
+	// Once it is exhausted, scale-ups are capped at MinRunners until the next day. It cannot be set along with
+	// ScaleTarget. Zero means unlimited.
+	MaxRunnerMinutesPerDay int `json:"max_runner_minutes_per_day,omitempty"`
+	// FallbackAfter is the time the message session may fail to be established or refreshed, while the
+	// GitHub Actions service is unreachable, before the ephemeral runner set is scaled to FallbackReplicas
+	// instead of staying at the last scale decision. The listener keeps retrying the session meanwhile.
+	// If it is not set, the listener exits once it fails to establish or refresh the session.
+	FallbackAfter *metav1.Duration `json:"fallback_after,omitempty"`
+	// FallbackReplicas is the number of runners scaled to once the session was unavailable for FallbackAfter,
+	// kept between MinRunners and MaxRunners. If it is not set, the runners of the last known demand are kept.
+	FallbackReplicas *int `json:"fallback_replicas,omitempty"`
+	// SessionStartupTimeout is the time the listener retries to create the message session at startup, with an
+	// exponential backoff and jitter, while the GitHub Actions service is unreachable, before it exits. This rides
+	// out transient outages rather than crash looping, without the listeners restarting all at once.
+	// Defaults to DefaultSessionStartupTimeout. It does not apply with FallbackAfter, which retries indefinitely.
+	SessionStartupTimeout *metav1.Duration `json:"session_startup_timeout,omitempty"`
+	// WorkDir is the writable directory every file written by the listener is placed in,
+	// so that the listener can run with a read-only root filesystem.
+	// If it is not set, the system temporary directory is used.
+	WorkDir string `json:"work_dir,omitempty"`
+	// HealthAddr is the address of the server serving the /livez (or /healthz) and /readyz endpoints,
+	// and the /prestop endpoint to be called by the preStop hook of the listener pod.
+	// The /prestop endpoint only accepts POST requests from the loopback interface: the preStop hook
+	// runs `ghalistener -prestop=<health_addr>` in the listener container to call it.
+	// If it is not set, the health server is not started.
+	HealthAddr string `json:"health_addr,omitempty"`
+	// GopsAddr is the loopback address of the gops agent, which serves the goroutines, GC stats, and profiles
+	// of the listener to the gops tool, e.g. "127.0.0.1:6060". If it is not set, the agent is not started.
+	GopsAddr string `json:"gops_addr,omitempty"`
+	// DebugAddr is the loopback address of the server serving the net/http/pprof profiles at /debug/pprof/ and the
+	// expvar variables at /debug/vars, e.g. "127.0.0.1:6061". If it is not set, the server is not started.
+	DebugAddr string `json:"debug_addr,omitempty"`
+	// KedaScalerAddr is the address of the server serving the desired runner count over the KEDA external scaler
+	// gRPC protocol, e.g. ":9090". It is served over plain gRPC. If it is not set, the server is not started.
+	KedaScalerAddr string `json:"keda_scaler_addr,omitempty"`
+	// KedaScalerTargetSize is the desired runner count per replica of the resource KEDA scales. Defaults to 1.
+	KedaScalerTargetSize int64 `json:"keda_scaler_target_size,omitempty"`
+	// AdminAddr is the address of the server serving the admin API, through which external systems, e.g. release
+	// orchestrators, reserve runners ahead of a planned demand, e.g. ":8081". The server also serves a read-only
+	// status page of the listener at "/", which the operators log in to with the bearer token, e.g. through a
+	// Service in front of the listener. It requires AdminBearerTokenFile. If it is not set, the server is not started.
+	AdminAddr string `json:"admin_addr,omitempty"`
+	// AdminBearerTokenFile is the path of the file holding the bearer token requests of the admin API must present.
+	AdminBearerTokenFile string `json:"admin_bearer_token_file,omitempty"`
+	// AdminTLSCertFile and AdminTLSKeyFile are the paths of the certificate and key the admin API is served with
+	// over TLS on AdminAddr. If they are not set, it is served over plain HTTP.
+	AdminTLSCertFile string `json:"admin_tls_cert_file,omitempty"`
+	AdminTLSKeyFile  string `json:"admin_tls_key_file,omitempty"`
+	// AdminSocket is the file in WorkDir of a Unix socket the admin API is also served on, for break-glass operations
+	// through kubectl exec. Its requests are not authenticated, the socket being only accessible to the listener user.
+	AdminSocket string `json:"admin_socket,omitempty"`
+	// HealthStaleAfter is the time without a successful poll for messages after which
+	// /livez reports the listener as not live. Defaults to 5 minutes.
+	HealthStaleAfter *metav1.Duration `json:"health_stale_after,omitempty"`
+	// HealthReadinessFailureThreshold is the number of consecutive failed Kubernetes requests after which
+	// /readyz reports the listener as not ready. Defaults to 3.
+	HealthReadinessFailureThreshold int `json:"health_readiness_failure_threshold,omitempty"`
+	// HealthReadinessSuccessThreshold is the number of consecutive successful Kubernetes requests after which
+	// /readyz reports the listener as ready again. Defaults to 1.
+	HealthReadinessSuccessThreshold int `json:"health_readiness_success_threshold,omitempty"`
+	// MaxUptime is the time after which the listener stops gracefully and exits successfully,
+	// so that the controller recycles the listener pod. Without leader election the listener has no standby replica;
+	// the message session is deleted on exit so that the replacement can take over immediately.
+	// If it is not set, the listener runs until it is stopped or fails.
+	MaxUptime *metav1.Duration `json:"max_uptime,omitempty"`
+	// IdleExitAfter is the time without assigned jobs and with zero runners after which the listener
+	// stops gracefully and exits successfully, deleting its message session, so that clusters with many
+	// mostly idle scale sets do not keep their listeners running. The controller recreates the listener
+	// pod once the scale set has acquirable jobs. It requires MinRunners to be 0.
+	// If it is not set, the listener does not exit when idle.
+	IdleExitAfter *metav1.Duration `json:"idle_exit_after,omitempty"`
+	// DrainTimeout bounds the time the listener takes, once stopped, to flush the final desired
+	// runner count and to close the message session. It should stay below the termination
+	// grace period of the listener pod. Defaults to 30 seconds.
+	DrainTimeout *metav1.Duration `json:"drain_timeout,omitempty"`
+	// ResumeSession records the message session and the last message processed in it in annotations
+	// of the ephemeral runner set, so a listener restarted after a crash resumes the session
+	// instead of creating a new one once the previous session expired.
+	ResumeSession bool `json:"resume_session,omitempty"`
+	// LeaderElection enables running redundant listener replicas. Only the replica holding the lease
+	// creates the message session and scales the ephemeral runner set, the others stand by to take over.
+	LeaderElection *LeaderElection `json:"leader_election,omitempty"`
+	// DeadLetterFile is the file in WorkDir the job messages quarantined for failing validation
+	// are appended to, one JSON record per line. If it is not set, they are only logged.
+	DeadLetterFile string `json:"dead_letter_file,omitempty"`
+	// StateExport periodically exports the durable scaling state of the listener, and imports it when the listener
+	// starts or takes the lead, so that it survives restarts and can be restored in a standby cluster.
+	// If it is not set, the state is only exported on request to the admin API.
+	StateExport *StateExport `json:"state_export,omitempty"`
+	// HTTPClient tunes the client of the GitHub API and the Actions service, e.g. for GitHub Enterprise Server
+	// deployments with a high latency or behind slow proxies.
+	HTTPClient *HTTPClient `json:"http_client,omitempty"`
+	// HTTPProxy, HTTPSProxy, and NoProxy configure the proxy of the listener like the HTTP_PROXY, HTTPS_PROXY,
+	// and NO_PROXY environment variables, so listeners sharing a pod template can use different proxies.
+	// Each field that is set takes precedence over its environment variable.
+	HTTPProxy  string `json:"http_proxy,omitempty"`
+	HTTPSProxy string `json:"https_proxy,omitempty"`
+	NoProxy    string `json:"no_proxy,omitempty"`
+	// RunnerLimitsConfigMap points MinRunners and MaxRunners at the keys of a ConfigMap the listener watches,
+	// so automation can change them at runtime without updating the scale set.
+	RunnerLimitsConfigMap *RunnerLimitsConfigMap `json:"runner_limits_config_map,omitempty"`
+	// Migration gradually shifts the runners to another ephemeral runner set of the scale set,
+	// e.g. to roll out a new runner image or node pool without downtime.
+	Migration *Migration `json:"migration,omitempty"`
+	// Canary patches a share of the runners into a canary ephemeral runner set, e.g. one with a new runner template,
+	// and rolls it back if its runners fail jobs at a higher rate. It cannot be set along with Migration.
+	Canary *Canary `json:"canary,omitempty"`
+	// TopologySpread splits the runners between ephemeral runner sets pinned to topology zones, so that the runner
+	// capacity survives the outage of a zone. With PendingRunners, the runners of a zone whose runners are stuck
+	// pending are redistributed to the other zones. It cannot be set along with Migration, Canary, or ScaleTarget.
+	TopologySpread *TopologySpread `json:"topology_spread,omitempty"`
+	// ScaleTarget replaces the ephemeral runner set the listener scales, for deployments that use the demand signal
+	// of the listener without the EphemeralRunnerSet machinery. The ephemeral runner set and its ephemeral runners
+	// are then left untouched. It cannot be set along with Migration, Canary, or ResumeSession.
+	ScaleTarget *ScaleTarget `json:"scale_target,omitempty"`
+	// Predictor provisions runners ahead of the demand forecast from the demand of the previous days,
+	// to absorb recurring daily spikes. If it is not set, runners are provisioned for the assigned jobs only.
+	Predictor *Predictor `json:"predictor,omitempty"`
+	// CapacityForecast periodically forecasts the peak runner count of the next hour from the scheduled overrides,
+	// the reservations, and the Predictor, and exports it as metrics and to an optional webhook, e.g. for the tooling
+	// provisioning nodes ahead of the scale-ups. If it is not set, nothing is forecast.
+	CapacityForecast *CapacityForecast `json:"capacity_forecast,omitempty"`
+	// PendingRunners periodically counts the ephemeral runners pending for longer than a threshold, e.g. since the
+	// cluster has no node or quota left to schedule their pods, exports the count as a metric, and optionally holds
+	// the scale-ups while runners are stuck. It cannot be set along with ScaleTarget. If it is not set, nothing is counted.
+	PendingRunners *PendingRunners `json:"pending_runners,omitempty"`
+	// Telemetry opts in to periodic reports of anonymized usage statistics. If it is not set, nothing is reported.
+	Telemetry *Telemetry `json:"telemetry,omitempty"`
+	// Audit writes a tamper-evident record of every job started and job completed message, and of the desired
+	// runner count of every message, e.g. to trace which runner executed which job. If it is not set, nothing is recorded.
+	Audit *Audit `json:"audit,omitempty"`
+	// JobNotifications posts the completed jobs to a webhook, e.g. to alert on the failed jobs of the scale set.
+	// If it is not set, nothing is posted.
+	JobNotifications *JobNotifications `json:"job_notifications,omitempty"`
+
+	// vaultObserver holds the requests of the vault the config was read from, until ObserveVaultRequests.
+	vaultObserver *vault.BufferedObserver
+}
+
+// JobNotifications configures the webhook the completed jobs are posted to.
+type JobNotifications struct {
+	// URL is the HTTPS URL of the webhook. It is required.
+	URL string `json:"url"`
+	// Format is the format of the body: "json" for the job as JSON, or "slack" for a message of a Slack
+	// incoming webhook. Defaults to "json".
+	Format string `json:"format,omitempty"`
+	// Results are the results of the jobs posted, e.g. ["failed", "canceled"]. If empty, every completed job is posted.
+	Results []string `json:"results,omitempty"`
+	// BearerTokenFile is the path of the file holding the bearer token sent to the webhook.
+	// It is read for every request, so the token can be rotated.
+	BearerTokenFile string `json:"bearer_token_file,omitempty"`
+	// Timeout bounds every request to the webhook. Defaults to 10 seconds.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+const DefaultJobNotificationsTimeout = 10 * time.Second
+
+// Audit configures the sink of the audit records. Exactly one of File and URL must be set.
+type Audit struct {
+	// File is the file in WorkDir the records are appended to, one JSON record per line.
+	File string `json:"file,omitempty"`
+	// URL is the HTTPS URL every record is posted to as JSON. The records are queued and posted in the background,
+	// and retried on network errors, 429 and 5xx statuses.
+	URL string `json:"url,omitempty"`
+	// BearerTokenFile is the path of the file holding the bearer token sent to URL.
+	// It is read for every request, so the token can be rotated.
+	BearerTokenFile string `json:"bearer_token_file,omitempty"`
+	// SecretFile is the path of the file holding the key the records are chained with as HMAC-SHA256,
+	// so the chain cannot be recomputed without it. If it is not set, the records are chained with SHA-256.
+	SecretFile string `json:"secret_file,omitempty"`
+	// Timeout bounds every request to URL. Defaults to 10 seconds.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+const DefaultAuditTimeout = 10 * time.Second
+
+// LogSampling samples the log entries of the same level and message: within every Interval, the First entries
+// are logged, then every Thereafter-th entry. The entries are not sampled at the log levels more verbose than debug.
+type LogSampling struct {
+	// Interval is the interval the entries are counted over. Defaults to 1 second.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// First is the number of entries logged per Interval before sampling. It is required.
+	First int `json:"first"`
+	// Thereafter is the sampling rate of the entries after the First ones. If it is not set,
+	// they are dropped, which rate-limits the entries to First per Interval.
+	Thereafter int `json:"thereafter,omitempty"`
+}
+
+const DefaultLogSamplingInterval = time.Second
+
+// Telemetry configures the reports of anonymized usage statistics: the features enabled in the configuration
+// and the number of errors logged per error code. The reports never hold names, URLs, IDs, or error messages.
+type Telemetry struct {
+	// Endpoint is the URL the reports are posted to as JSON. It is required.
+	Endpoint string `json:"endpoint"`
+	// Interval is the interval between two reports. Defaults to 1 hour.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+const DefaultTelemetryInterval = time.Hour
+
+// Predictor configures the forecast of the demand of the scale set.
+type Predictor struct {
+	// Lead is how far ahead of the forecast demand runners are provisioned. Defaults to 5 minutes.
+	Lead *metav1.Duration `json:"lead,omitempty"`
+	// Days is the number of previous days the demand is averaged over. Defaults to 7.
+	Days int `json:"days,omitempty"`
+	// HistoryFile is the file within WorkDir the recorded demand is persisted to, so it survives restarts
+	// of the listener when WorkDir is backed by a persistent volume. If it is not set, the demand is kept in memory.
+	HistoryFile string `json:"history_file,omitempty"`
+}
+
+// CapacityForecast configures the forecasts of the peak runner count.
+type CapacityForecast struct {
+	// Interval is the interval between two forecasts. Defaults to 1 minute.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Horizon is how far ahead the forecasts look, at most 24 hours. Defaults to 1 hour.
+	Horizon *metav1.Duration `json:"horizon,omitempty"`
+	// Webhook posts every forecast as JSON, along with the scale set. If it is not set, the forecasts are
+	// only exported as metrics.
+	Webhook *ForecastWebhook `json:"webhook,omitempty"`
+}
+
+// PendingRunners configures the checks of the ephemeral runners stuck pending.
+type PendingRunners struct {
+	// Threshold is the time after its creation an ephemeral runner still pending counts as stuck. Defaults to 5 minutes.
+	Threshold *metav1.Duration `json:"threshold,omitempty"`
+	// Interval is the interval between two checks. Defaults to 30 seconds.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// CapScaleUps holds the desired runners at the last patch while runners are stuck pending, instead of
+	// asking for replicas the cluster cannot schedule. The scale-downs still apply.
+	CapScaleUps bool `json:"cap_scale_ups,omitempty"`
+}
+
+const (
+	DefaultPendingRunnersThreshold = 5 * time.Minute
+	DefaultPendingRunnersInterval  = 30 * time.Second
+)
+
+// DefaultSessionStartupTimeout is the default time the listener retries to create the message session at startup.
+const DefaultSessionStartupTimeout = 5 * time.Minute
+
+const (
+	DefaultCapacityForecastInterval = time.Minute
+	DefaultCapacityForecastHorizon  = time.Hour
+	// MaxCapacityForecastHorizon bounds the horizon to the day the demand of the Predictor recurs over.
+	MaxCapacityForecastHorizon = 24 * time.Hour
+)
+
+// ForecastWebhook is a webhook the forecasts are posted to. The webhook must answer with a 2xx status.
+type ForecastWebhook struct {
+	// URL is the HTTPS URL of the webhook. It is required.
+	URL string `json:"url"`
+	// BearerTokenFile is the path of the file holding the bearer token sent to the webhook.
+	// It is read for every request, so the token can be rotated.
+	BearerTokenFile string `json:"bearer_token_file,omitempty"`
+	// SecretFile is the path of the file holding the secret the body is signed with,
+	// in the X-ARC-Signature-256 header as "sha256=<HMAC-SHA256 hex digest>".
+	// It is read for every request, so the secret can be rotated.
+	SecretFile string `json:"secret_file,omitempty"`
+	// Timeout bounds every request to the webhook. Defaults to 10 seconds.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+const DefaultForecastWebhookTimeout = 10 * time.Second
+
+// Migration ramps the share of the runners patched into a target ephemeral runner set up to Percentage.
+// The target must be in the namespace of the ephemeral runner set and register its runners to the same scale set.
+// The role of the listener must allow to get and patch the target: a role restricting the ephemeral runner sets
+// by name must list it. The target is patched before the ephemeral runner set, which keeps its runners if it fails.
+type Migration struct {
+	// TargetEphemeralRunnerSetName is the name of the ephemeral runner set the runners shift to. It is required.
+	TargetEphemeralRunnerSetName string `json:"target_ephemeral_runner_set_name"`
+	// StartTime is the time at which the runners start to shift, in RFC3339 format.
+	StartTime time.Time `json:"start_time"`
+	// RampDuration is the time the share of the target takes to grow from zero to Percentage.
+	// If it is not set, the runners shift right at StartTime.
+	RampDuration *metav1.Duration `json:"ramp_duration,omitempty"`
+	// Percentage is the share of the runners in the target once the ramp completed,
+	// between 1 and 100. Defaults to 100.
+	Percentage int `json:"percentage,omitempty"`
+}
+
+const DefaultMigrationPercentage = 100
+
+// Canary configures the canary ephemeral runner set. The canary must be in the namespace of the ephemeral runner set
+// and register its runners to the same scale set. The role of the listener must allow to get and patch the canary.
+//
+// Once rolled back, the canary is annotated with "actions.github.com/canary-rolled-back" and gets no runners
+// until the annotation is removed.
+type Canary struct {
+	// EphemeralRunnerSetName is the name of the canary ephemeral runner set. It is required.
+	EphemeralRunnerSetName string `json:"ephemeral_runner_set_name"`
+	// Percentage is the share of the runners in the canary, between 1 and 99. It is required.
+	Percentage int `json:"percentage"`
+	// MinJobs is the number of jobs the canary runners complete before their failure rate is evaluated.
+	// Defaults to 20.
+	MinJobs int `json:"min_jobs,omitempty"`
+	// MaxFailureRateIncrease is the failure rate of the canary runners, above the one of the other runners,
+	// which rolls the canary back, between 0 and 1. Defaults to 0.1.
+	MaxFailureRateIncrease *float64 `json:"max_failure_rate_increase,omitempty"`
+}
+
+const (
+	DefaultCanaryMinJobs                = 20
+	DefaultCanaryMaxFailureRateIncrease = 0.1
+)
+
+// StateExport configures where the durable scaling state of the listener is exported to: the ID of the last patch,
+// the runner minutes provisioned in the day, and the reservations. The state is imported from the Secret if it is set,
+// from the file otherwise. At least one of them is required.
+type StateExport struct {
+	// File is the file in WorkDir the state is written to.
+	File string `json:"file,omitempty"`
+	// SecretName is the name of the Secret of the namespace of the ephemeral runner set the state is written to,
+	// under the "state.json" key, e.g. to be backed up or replicated to a standby cluster. The role of the listener
+	// must allow to get, create, and update the Secret.
+	SecretName string `json:"secret_name,omitempty"`
+	// Interval is the interval between two exports. The state is also exported when the listener stops.
+	// Defaults to 1 minute.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+const DefaultStateExportInterval = time.Minute
+
+const DefaultCredentialsRefreshInterval = time.Minute
+
+// ServerCertRevocation configures the revocation checks of the certificates of the GitHub server. The certificates
+// are checked with OCSP first, then with the CRLs, until their revocation status is determined.
+type ServerCertRevocation struct {
+	// CRLFiles are the files of the CRLs, PEM or DER encoded, e.g. mounted from a ConfigMap. They are re-read
+	// once past their next update.
+	CRLFiles []string `json:"crl_files,omitempty"`
+	// CRLDistributionPoints fetches the CRLs of the distribution points of the certificates.
+	CRLDistributionPoints bool `json:"crl_distribution_points,omitempty"`
+	// OCSP checks the certificates with the OCSP response stapled by the server, or with their OCSP responders.
+	OCSP bool `json:"ocsp,omitempty"`
+	// SoftFail accepts the certificates whose revocation status cannot be determined, e.g. while the OCSP
+	// responder is unreachable. Otherwise, the connections to the GitHub server fail.
+	SoftFail bool `json:"soft_fail,omitempty"`
+	// Timeout bounds the requests to the CRL distribution points and the OCSP responders. Defaults to 10 seconds.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+func (r *ServerCertRevocation) validate() error {
+	if len(r.CRLFiles) == 0 && !r.CRLDistributionPoints && !r.OCSP {
+		return fmt.Errorf("ServerCertRevocation requires CRLFiles, CRLDistributionPoints or OCSP to be set")
+	}
+	if r.Timeout != nil && r.Timeout.Duration <= 0 {
+		return fmt.Errorf(`ServerCertRevocation Timeout "%s" must be positive`, r.Timeout.Duration)
+	}
+	return nil
+}
+
+// checker returns the revocation checker of the certificates of the GitHub server.
+func (r *ServerCertRevocation) checker(host string, proxy revocation.ProxyFunc, logger logr.Logger) (*revocation.Checker, error) {
+	options := revocation.Options{
+		Hosts:                 []string{host},
+		CRLFiles:              r.CRLFiles,
+		CRLDistributionPoints: r.CRLDistributionPoints,
+		OCSP:                  r.OCSP,
+		SoftFail:              r.SoftFail,
+		Proxy:                 proxy,
+		Logger:                logger,
+	}
+	if r.Timeout != nil {
+		options.Timeout = r.Timeout.Duration
+	}
+	return revocation.New(options)
+}
+
+// VaultCache configures the cache of the secrets read from the vault.
+type VaultCache struct {
+	// TTL is how long a secret is served from the cache before it is read from the vault again, so the credentials
+	// rotated in the vault are picked up once it ran. Defaults to 5 minutes.
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+	// StaleTTL is how long past its TTL a secret is still served, while it is revalidated in the background
+	// or while the vault is unavailable. Defaults to 24 hours.
+	StaleTTL *metav1.Duration `json:"stale_ttl,omitempty"`
+	// NegativeTTL is how long a failure to read a secret is cached. Defaults to 30 seconds.
+	NegativeTTL *metav1.Duration `json:"negative_ttl,omitempty"`
+	// File is the file in WorkDir the cache is persisted to, encrypted with the key of KeyFile, so that a listener
+	// restarting while the vault is unavailable starts from the cached secrets. The file only outlives the listener
+	// pod if WorkDir is on a persistent volume, not e.g. an emptyDir. If it is not set, the cache is kept in memory.
+	File string `json:"file,omitempty"`
+	// KeyFile is the file of the key the cache file is encrypted with, e.g. mounted from a Kubernetes Secret.
+	// It must hold at least 32 random bytes, e.g. generated with `openssl rand 32`, the encryption key being
+	// derived from them with HKDF-SHA256. Required if File is set.
+	KeyFile string `json:"key_file,omitempty"`
+}
+
+const (
+	DefaultVaultCacheTTL         = 5 * time.Minute
+	DefaultVaultCacheStaleTTL    = vault.DefaultCacheStaleTTL
+	DefaultVaultCacheNegativeTTL = vault.DefaultCacheNegativeTTL
+)
+
+func (c *VaultCache) validate() error {
+	for _, ttl := range []struct {
+		name string
+		d    *metav1.Duration
+	}{{"TTL", c.TTL}, {"StaleTTL", c.StaleTTL}, {"NegativeTTL", c.NegativeTTL}} {
+		if ttl.d != nil && ttl.d.Duration < 0 {
+			return fmt.Errorf(`VaultCache %s "%s" cannot be negative`, ttl.name, ttl.d.Duration)
+		}
+	}
+	if c.File != "" {
+		if !filepath.IsLocal(c.File) {
+			return fmt.Errorf(`VaultCache File "%s" must be a relative path within WorkDir`, c.File)
+		}
+		if c.KeyFile == "" {
+			return fmt.Errorf("VaultCache KeyFile is required when File is set")
+		}
+	}
+	return nil
+}
+
+// wrap returns the cache of the vault.
+func (c *VaultCache) wrap(v vault.Vault, workDir string, logger logr.Logger) (*vault.Cache, error) {
+	options := vault.CacheOptions{
+		TTL:         DefaultVaultCacheTTL,
+		StaleTTL:    DefaultVaultCacheStaleTTL,
+		NegativeTTL: DefaultVaultCacheNegativeTTL,
+		Logger:      logger,
+	}
+	if c.TTL != nil {
+		options.TTL = c.TTL.Duration
+	}
+	if c.StaleTTL != nil {
+		options.StaleTTL = c.StaleTTL.Duration
+	}
+	if c.NegativeTTL != nil {
+		options.NegativeTTL = c.NegativeTTL.Duration
+	}
+	if c.File != "" {
+		dir, err := workdir.New(workDir)
+		if err != nil {
+			return nil, err
+		}
+		key, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault cache key: %w", err)
+		}
+		options.Store = &cacheFile{dir: dir, name: c.File}
+		options.Key = key
+	}
+	return vault.NewCache(v, options)
+}
+
+// cacheFile persists the vault cache in a file of the work directory.
+type cacheFile struct {
+	dir  *workdir.Dir
+	name string
+}
+
+func (f *cacheFile) Load() ([]byte, error) {
+	path, err := f.dir.Join(f.name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (f *cacheFile) Save(data []byte) error {
+	file, err := f.dir.Create(f.name)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// TopologySpread configures the zones the runners are spread across. Every zone is served by an ephemeral runner set
+// of the namespace of the ephemeral runner set, registering its runners to the same scale set and pinning them to the
+// zone, e.g. with a node selector on the topology key. The role of the listener must allow to get and patch them.
+type TopologySpread struct {
+	// TopologyKey is the node label of the zones, e.g. "topology.kubernetes.io/zone". It is required.
+	TopologyKey string `json:"topology_key"`
+	// Zones are the zones the runners are spread across. At least 2 are required, and exactly one of them
+	// must be served by the ephemeral runner set.
+	Zones []TopologyZone `json:"zones"`
+}
+
+// TopologyZone configures a zone of the TopologySpread.
+type TopologyZone struct {
+	// Name is the value of the topology key of the zone, e.g. "eu-west-1a". It is required.
+	Name string `json:"name"`
+	// EphemeralRunnerSetName is the name of the ephemeral runner set of the zone.
+	// Defaults to the ephemeral runner set.
+	EphemeralRunnerSetName string `json:"ephemeral_runner_set_name,omitempty"`
+	// Weight is the share of the runners in the zone, relative to the weights of the other zones. Defaults to 1.
+	Weight int `json:"weight,omitempty"`
+}
+
+// RunnerLimitsConfigMap configures the ConfigMap the min and max runners are read from at runtime.
+// A key that is not set keeps the configured value, and the configured MaxRunners bounds the one of the ConfigMap,
+// since it is the capacity the listener advertises to GitHub. The role of the listener must allow to list and
+// watch the ConfigMap.
+type RunnerLimitsConfigMap struct {
+	// Name is the name of the ConfigMap. It is required.
+	Name string `json:"name"`
+	// Namespace is the namespace of the ConfigMap. Defaults to the namespace of the ephemeral runner set.
+	Namespace string `json:"namespace,omitempty"`
+	// MinRunnersKey is the key of the min runners. Defaults to "minRunners".
+	MinRunnersKey string `json:"min_runners_key,omitempty"`
+	// MaxRunnersKey is the key of the max runners. Defaults to "maxRunners".
+	MaxRunnersKey string `json:"max_runners_key,omitempty"`
+}
+
+const (
+	DefaultMinRunnersKey = "minRunners"
+	DefaultMaxRunnersKey = "maxRunners"
+)
+
+// Keys returns the keys of the min and max runners, defaulting the ones not set.
+func (r *RunnerLimitsConfigMap) Keys() (minRunnersKey, maxRunnersKey string) {
+	minRunnersKey, maxRunnersKey = DefaultMinRunnersKey, DefaultMaxRunnersKey
+	if r.MinRunnersKey != "" {
+		minRunnersKey = r.MinRunnersKey
+	}
+	if r.MaxRunnersKey != "" {
+		maxRunnersKey = r.MaxRunnersKey
+	}
+	return minRunnersKey, maxRunnersKey
+}
+
+// ScaleTarget configures the resource scaled instead of the ephemeral runner set. Exactly one field must be set.
+type ScaleTarget struct {
+	// Kubernetes patches the replicas of a Kubernetes resource through its scale subresource.
+	Kubernetes *KubernetesScaleTarget `json:"kubernetes,omitempty"`
+	// Webhook posts the scaling decisions to an external webhook.
+	Webhook *WebhookScaleTarget `json:"webhook,omitempty"`
+}
+
+// KubernetesScaleTarget is a Kubernetes resource with a scale subresource, e.g. a Deployment.
+// The role of the listener must allow to patch the scale subresource of the resource.
+type KubernetesScaleTarget struct {
+	// APIVersion is the group and version of the resource. Defaults to "apps/v1".
+	APIVersion string `json:"api_version,omitempty"`
+	// Resource is the plural resource name. Defaults to "deployments".
+	Resource string `json:"resource,omitempty"`
+	// Name is the name of the resource. It is required.
+	Name string `json:"name"`
+	// Namespace is the namespace of the resource. Defaults to the namespace of the ephemeral runner set.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+const (
+	DefaultScaleTargetAPIVersion = "apps/v1"
+	DefaultScaleTargetResource   = "deployments"
+)
+
+// WebhookScaleTarget is a webhook the scaling decisions are posted to as JSON, along with the scale set,
+// the desired runner count, and the patch ID. The webhook must answer with a 2xx status.
+type WebhookScaleTarget struct {
+	// URL is the HTTPS URL of the webhook. It is required.
+	URL string `json:"url"`
+	// BearerTokenFile is the path of the file holding the bearer token sent to the webhook.
+	// It is read for every request, so the token can be rotated.
+	BearerTokenFile string `json:"bearer_token_file,omitempty"`
+	// SecretFile is the path of the file holding the secret the body is signed with,
+	// in the X-ARC-Signature-256 header as "sha256=<HMAC-SHA256 hex digest>".
+	// It is read for every request, so the secret can be rotated. It is required, so that the webhook can verify
+	// the decisions come from the listener.
+	SecretFile string `json:"secret_file"`
+	// Timeout bounds every request to the webhook. Defaults to 10 seconds.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+const DefaultScaleTargetWebhookTimeout = 10 * time.Second
+
+// HTTPClient configures the timeouts and the connection pool of the actions client.
+// The settings not set keep the defaults of the client.
+type HTTPClient struct {
+	// Timeout bounds every attempt of a request, including reading the response.
+	// It must be longer than LongPollTimeout. Defaults to 5 minutes.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// TLSHandshakeTimeout bounds the TLS handshakes. Defaults to 10 seconds.
+	TLSHandshakeTimeout *metav1.Duration `json:"tls_handshake_timeout,omitempty"`
+	// MaxIdleConns is the number of idle connections kept alive per host.
+	MaxIdleConns int `json:"max_idle_conns,omitempty"`
+	// LongPollTimeout bounds the time the listener waits for a message before polling again, e.g. below
+	// the idle timeout of a proxy. By default the listener waits until the Actions service ends the poll.
+	LongPollTimeout *metav1.Duration `json:"long_poll_timeout,omitempty"`
+}
+
+// LeaderElection configures the Kubernetes Lease the listener replicas elect their leader with.
+// The role of the listener must allow to get, create, and update the lease.
+type LeaderElection struct {
+	// LeaseName is the name of the lease. It is required.
+	LeaseName string `json:"lease_name"`
+	// LeaseNamespace is the namespace of the lease. Defaults to the namespace of the ephemeral runner set.
+	LeaseNamespace string `json:"lease_namespace,omitempty"`
+	// Identity identifies the replica in the lease. Defaults to the hostname, which is the name of the pod.
+	Identity string `json:"identity,omitempty"`
+	// LeaseDuration is the time standby replicas wait before taking over a lease that was not renewed.
+	// Defaults to 15 seconds.
+	LeaseDuration *metav1.Duration `json:"lease_duration,omitempty"`
+	// RenewDeadline is the time the leader retries renewing the lease before it stops leading.
+	// It must be lower than LeaseDuration. Defaults to 10 seconds.
+	RenewDeadline *metav1.Duration `json:"renew_deadline,omitempty"`
+	// RetryPeriod is the time between attempts to acquire or renew the lease.
+	// It must be lower than RenewDeadline. Defaults to 2 seconds.
+	RetryPeriod *metav1.Duration `json:"retry_period,omitempty"`
+}
+
+const (
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+// Durations returns the lease duration, renew deadline, and retry period, defaulting the ones not set.
+func (l *LeaderElection) Durations() (leaseDuration, renewDeadline, retryPeriod time.Duration) {
+	leaseDuration, renewDeadline, retryPeriod = DefaultLeaseDuration, DefaultRenewDeadline, DefaultRetryPeriod
+	if l.LeaseDuration != nil {
+		leaseDuration = l.LeaseDuration.Duration
+	}
+	if l.RenewDeadline != nil {
+		renewDeadline = l.RenewDeadline.Duration
+	}
+	if l.RetryPeriod != nil {
+		retryPeriod = l.RetryPeriod.Duration
+	}
+	return leaseDuration, renewDeadline, retryPeriod
+}
+
+// options returns the actions client options of the settings. It is nil-safe.
+func (h *HTTPClient) options() []actions.ClientOption {
+	if h == nil {
+		return nil
+	}
+	var options []actions.ClientOption
+	if h.Timeout != nil {
+		options = append(options, actions.WithTimeout(h.Timeout.Duration))
+	}
+	if h.TLSHandshakeTimeout != nil {
+		options = append(options, actions.WithTLSHandshakeTimeout(h.TLSHandshakeTimeout.Duration))
+	}
+	if h.MaxIdleConns > 0 {
+		options = append(options, actions.WithMaxIdleConns(h.MaxIdleConns))
+	}
+	if h.LongPollTimeout != nil {
+		options = append(options, actions.WithLongPollTimeout(h.LongPollTimeout.Duration))
+	}
+	return options
+}
+
+// ScheduledOverride overrides MinRunners and/or MaxRunners during a time window,
+// which optionally recurs every day, week, month, or year.
+type ScheduledOverride struct {
+	// StartTime is the time at which the first window starts, in RFC3339 format.
+	StartTime time.Time `json:"start_time"`
+	// EndTime is the time at which the first window ends, in RFC3339 format.
+	EndTime time.Time `json:"end_time"`
+	// Frequency is one of "Daily", "Weekly", "Monthly", and "Yearly".
+	// If empty, the window happens only once.
+	Frequency string `json:"frequency,omitempty"`
+	// UntilTime is the time after which the window no longer recurs.
+	UntilTime *time.Time `json:"until_time,omitempty"`
+	// TimeZone is the IANA time zone recurrences are evaluated in, e.g. "Europe/Berlin".
+	// It keeps recurring windows at the same wall clock time across daylight saving time transitions.
+	// Defaults to the offset of StartTime.
+	TimeZone   string `json:"time_zone,omitempty"`
+	MinRunners *int   `json:"min_runners,omitempty"`
+	MaxRunners *int   `json:"max_runners,omitempty"`
+	// Name identifies the override in the scaling policy metric. Defaults to its index in the list.
+	Name string `json:"name,omitempty"`
+}
+
+// JobPolicy adjusts the runners the jobs it matches count for. Its selectors which are set must all match the job.
+type JobPolicy struct {
+	// Name identifies the policy in the logs. Defaults to its index in the list.
+	Name string `json:"name,omitempty"`
+	// Repository is the path.Match pattern the "owner/repo" of the job matches, e.g. "octo-org/*".
+	Repository string `json:"repository,omitempty"`
+	// Workflow is the path.Match pattern the workflow of the job matches, without its git ref,
+	// e.g. "octo-org/octo-repo/.github/workflows/release.yml".
+	Workflow string `json:"workflow,omitempty"`
+	// Labels are the runner labels the job must all request.
+	Labels []string `json:"labels,omitempty"`
+	// Weight is the runners counted for every job matched. Defaults to 1, zero does not count the jobs.
+	Weight *int `json:"weight,omitempty"`
+	// MaxRunners caps the runners counted for all the jobs matched toward the desired runner count, if set.
+	// It is not a concurrency limit: GitHub assigns the jobs to any idle runner of the scale set, so more jobs
+	// matched can run at once on the runners provisioned for other jobs.
+	MaxRunners *int `json:"max_runners,omitempty"`
+	// Priority counts the jobs matched toward the PriorityRunners, which the other jobs are not counted toward.
+	Priority bool `json:"priority,omitempty"`
+}
+
+func (p *JobPolicy) validate() error {
+	for _, pattern := range []struct{ name, value string }{{"Repository", p.Repository}, {"Workflow", p.Workflow}} {
+		if _, err := path.Match(pattern.value, ""); err != nil {
+			return fmt.Errorf(`%s "%s" is not a valid pattern: %w`, pattern.name, pattern.value, err)
+		}
+	}
+	if p.Repository == "" && p.Workflow == "" && len(p.Labels) == 0 {
+		return fmt.Errorf("one of Repository, Workflow or Labels must be set")
+	}
+	if p.Weight != nil && *p.Weight < 0 {
+		return fmt.Errorf(`Weight "%d" cannot be negative`, *p.Weight)
+	}
+	if p.MaxRunners != nil && *p.MaxRunners < 0 {
+		return fmt.Errorf(`MaxRunners "%d" cannot be negative`, *p.MaxRunners)
+	}
+	return nil
 }
 
 func Read(ctx context.Context, configPath string) (*Config, error) {
 	f, err := os.Open(configPath)
 	if err != nil {
-		return nil, err
+		return nil, errcode.Errorf(errcode.ConfigRead, "failed to open config: %w", err)
 	}
 	defer f.Close()
 
 	var config Config
 	if err := json.NewDecoder(f).Decode(&config); err != nil {
-		return nil, fmt.Errorf("failed to decode config: %w", err)
+		return nil, errcode.Errorf(errcode.ConfigRead, "failed to decode config: %w", err)
 	}
 
-	var vault vault.Vault
-	switch config.VaultType {
-	case "":
-		if err := config.Validate(); err != nil {
-			return nil, fmt.Errorf("failed to validate configuration: %v", err)
+	if config.hasCredentials() {
+		if err := checkCredentialsFilePermissions(configPath); err != nil {
+			return nil, errcode.Wrap(errcode.ConfigInvalid, err)
 		}
+	}
 
-		return &config, nil
-	case "azure_key_vault":
-		akv, err := azurekeyvault.New(*config.AzureKeyVaultConfig)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create Azure Key Vault client: %w", err)
+	if config.VaultType == "" {
+		if err := config.LoadCredentialsFiles(); err != nil {
+			return nil, err
+		}
+		if err := config.Validate(); err != nil {
+			return nil, errcode.Errorf(errcode.ConfigInvalid, "failed to validate configuration: %v", err)
 		}
 
-		vault = akv
-	default:
-		return nil, fmt.Errorf("unsupported vault type: %s", config.VaultType)
+		return &config, nil
 	}
 
-	appConfigRaw, err := vault.GetSecret(ctx, config.VaultLookupKey)
+	config.vaultObserver = &vault.BufferedObserver{}
+	v, err := config.Vault(config.vaultObserver)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get app config from vault: %w", err)
+		return nil, err
 	}
 
-	appConfig, err := appconfig.FromJSONString(appConfigRaw)
+	appConfig, err := FetchAppConfig(ctx, v, config.VaultLookupKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read app config from string: %v", err)
+		return nil, err
 	}
 
 	config.AppConfig = appConfig
 
 	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
+		return nil, errcode.Errorf(errcode.ConfigInvalid, "config validation failed: %w", err)
 	}
 
 	if ctx.Err() != nil {
@@ -98,6 +941,149 @@ func Read(ctx context.Context, configPath string) (*Config, error) {
 	return &config, nil
 }
 
+// ObserveVaultRequests notifies the observer of the requests of the vault the config was read from, e.g. the
+// signatures of its GitHub App key, starting with the ones made while reading the config.
+func (c *Config) ObserveVaultRequests(observer vault.Observer) {
+	if c.vaultObserver != nil {
+		c.vaultObserver.SetObserver(observer)
+	}
+}
+
+// LoadCredentialsFiles reads the credentials of the AppPrivateKeyFile or the TokenFile into the AppConfig, if set.
+func (c *Config) LoadCredentialsFiles() error {
+	if c.AppConfig == nil || !c.AppConfig.HasFiles() {
+		return nil
+	}
+	appConfig, err := c.AppConfig.ReadFiles()
+	if err != nil {
+		return errcode.Errorf(errcode.ConfigRead, "failed to read credentials file: %w", err)
+	}
+	c.AppConfig = appConfig
+	return nil
+}
+
+func (c *Config) hasCredentials() bool {
+	return c.AppConfig != nil && (c.Token != "" || c.AppPrivateKey != "")
+}
+
+// checkCredentialsFilePermissions refuses a config file holding credentials that any user on the node can read.
+// Files projected from a Kubernetes secret volume are exempt: the volume is private to the pod,
+// and its mode is managed by the kubelet and must stay readable by the non-root listener user.
+func checkCredentialsFilePermissions(path string) error {
+	if target, err := os.Readlink(path); err == nil && strings.HasPrefix(target, kubernetesSecretVolumeDataDir) {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat config file: %w", err)
+	}
+
+	if info.Mode().Perm()&0o004 != 0 {
+		return fmt.Errorf("config file %q contains credentials and must not be world-readable (mode %s)", path, info.Mode().Perm())
+	}
+
+	return nil
+}
+
+// ScrubCredentials drops the credentials held by the config once they are no longer needed,
+// e.g. after the actions client has been constructed, so that they are not kept around
+// or dumped with the config. Go strings are immutable, so the underlying memory
+// is released to the garbage collector rather than overwritten.
+func (c *Config) ScrubCredentials() {
+	if c.AppConfig == nil {
+		return
+	}
+	*c.AppConfig = appconfig.AppConfig{}
+	c.AppConfig = nil
+}
+
+// Hash returns a short hash of the config, without the credentials, so that the listeners running different
+// configs can be told apart, and the credentials rotating does not change it.
+func (c *Config) Hash() string {
+	withoutCredentials := *c
+	withoutCredentials.AppConfig = nil
+	b, err := json.Marshal(withoutCredentials)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Vault creates the vault client for the configured VaultType, notifying the observer of its requests
+// if not nil. The cached reads are not requests to the vault, they are not observed.
+// It returns nil if no vault is configured.
+func (c *Config) Vault(observer vault.Observer) (vault.Vault, error) {
+	switch c.VaultType {
+	case "":
+		return nil, nil
+	case vault.VaultTypeAzureKeyVault:
+		akv, err := azurekeyvault.New(*c.AzureKeyVaultConfig)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.VaultRead, "failed to create Azure Key Vault client: %w", err)
+		}
+
+		return c.cacheVault(vault.Instrument(akv, c.VaultType, observer))
+	case vault.VaultTypeKubernetes:
+		conf, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, errcode.Errorf(errcode.KubernetesClient, "failed to load in-cluster config: %w", err)
+		}
+		k8sClient, err := client.New(conf, client.Options{})
+		if err != nil {
+			return nil, errcode.Errorf(errcode.KubernetesClient, "failed to create kubernetes client: %w", err)
+		}
+
+		return c.cacheVault(vault.Instrument(kubernetesvault.New(k8sClient, c.EphemeralRunnerSetNamespace), c.VaultType, observer))
+	case vault.VaultTypeExec:
+		ev, err := execvault.New(*c.ExecVaultConfig)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.VaultRead, "failed to create exec vault: %w", err)
+		}
+
+		return c.cacheVault(vault.Instrument(ev, c.VaultType, observer))
+	default:
+		return nil, errcode.Errorf(errcode.ConfigInvalid, "unsupported vault type: %s", c.VaultType)
+	}
+}
+
+func (c *Config) cacheVault(v vault.Vault) (vault.Vault, error) {
+	if c.VaultCache == nil {
+		return v, nil
+	}
+	logger, err := c.Logger()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	cache, err := c.VaultCache.wrap(v, c.WorkDir, logger.WithName("vault-cache"))
+	if err != nil {
+		return nil, errcode.Errorf(errcode.ConfigInvalid, "failed to create vault cache: %w", err)
+	}
+	return cache, nil
+}
+
+// FetchAppConfig reads the GitHub App configuration stored under the lookup key in the vault.
+func FetchAppConfig(ctx context.Context, v vault.Vault, lookupKey string) (*appconfig.AppConfig, error) {
+	appConfigRaw, err := v.GetSecret(ctx, lookupKey)
+	if err != nil {
+		if vault.IsThrottled(err) {
+			return nil, errcode.Errorf(errcode.VaultRead, "failed to get app config from vault, the vault is throttling the requests: %w", err)
+		}
+		return nil, errcode.Errorf(errcode.VaultRead, "failed to get app config from vault: %w", err)
+	}
+
+	appConfig, err := vault.ResolveAppConfig(ctx, v, appConfigRaw)
+	if err != nil {
+		if vault.IsThrottled(err) {
+			return nil, errcode.Errorf(errcode.VaultRead, "failed to resolve the GitHub App key from vault, the vault is throttling the requests: %w", err)
+		}
+		return nil, errcode.Errorf(errcode.VaultRead, "failed to read app config from string: %w", err)
+	}
+
+	return appConfig, nil
+}
+
 // Validate checks the configuration for errors.
 func (c *Config) Validate() error {
 	if len(c.ConfigureUrl) == 0 {
@@ -123,6 +1109,425 @@ func (c *Config) Validate() error {
 		if c.VaultLookupKey == "" {
 			return fmt.Errorf("VaultLookupKey is required when VaultType is set to %q", c.VaultType)
 		}
+		if c.VaultType == vault.VaultTypeKubernetes {
+			if _, err := kubernetesvault.ParseLookupKey(c.VaultLookupKey); err != nil {
+				return fmt.Errorf("VaultLookupKey validation failed: %w", err)
+			}
+		}
+		if c.VaultType == vault.VaultTypeExec {
+			if c.ExecVaultConfig == nil {
+				return fmt.Errorf("ExecVaultConfig is required when VaultType is set to %q", c.VaultType)
+			}
+			if err := c.ExecVaultConfig.Validate(); err != nil {
+				return fmt.Errorf("ExecVaultConfig validation failed: %w", err)
+			}
+		}
+	}
+
+	if c.AppConfig != nil && c.AppConfig.HasFiles() && c.VaultType != "" {
+		return fmt.Errorf("AppPrivateKeyFile and TokenFile cannot be set together with VaultType")
+	}
+	if c.CredentialsRefreshInterval != nil {
+		if c.AppConfig == nil || !c.AppConfig.HasFiles() {
+			return fmt.Errorf("CredentialsRefreshInterval requires AppPrivateKeyFile or TokenFile to be set")
+		}
+		if c.CredentialsRefreshInterval.Duration <= 0 {
+			return fmt.Errorf(`CredentialsRefreshInterval "%s" must be positive`, c.CredentialsRefreshInterval.Duration)
+		}
+	}
+
+	if c.ServerCertRevocation != nil {
+		if err := c.ServerCertRevocation.validate(); err != nil {
+			return err
+		}
+		if ghConfig, err := actions.ParseGitHubConfigFromURL(c.ConfigureUrl); err == nil && ghConfig.IsHosted {
+			return fmt.Errorf(`ServerCertRevocation is only supported for GitHub Enterprise Server, not "%s"`, c.ConfigureUrl)
+		}
+	}
+
+	if c.VaultCache != nil {
+		if c.VaultType == "" {
+			return fmt.Errorf("VaultCache requires VaultType to be set")
+		}
+		if err := c.VaultCache.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.VaultRefreshInterval != nil {
+		if c.VaultType == "" {
+			return fmt.Errorf("VaultRefreshInterval requires VaultType to be set")
+		}
+		if c.VaultRefreshInterval.Duration <= 0 {
+			return fmt.Errorf(`VaultRefreshInterval "%s" must be positive`, c.VaultRefreshInterval.Duration)
+		}
+	}
+
+	if c.KedaScalerAddr != "" {
+		if _, _, err := net.SplitHostPort(c.KedaScalerAddr); err != nil {
+			return fmt.Errorf(`KedaScalerAddr "%s" is invalid: %w`, c.KedaScalerAddr, err)
+		}
+	}
+	if c.KedaScalerTargetSize < 0 {
+		return fmt.Errorf(`KedaScalerTargetSize "%d" cannot be negative`, c.KedaScalerTargetSize)
+	}
+
+	if c.AdminAddr != "" {
+		if _, _, err := net.SplitHostPort(c.AdminAddr); err != nil {
+			return fmt.Errorf(`AdminAddr "%s" is invalid: %w`, c.AdminAddr, err)
+		}
+		if c.AdminBearerTokenFile == "" {
+			return fmt.Errorf("AdminAddr requires AdminBearerTokenFile to be set")
+		}
+	}
+	if (c.AdminTLSCertFile == "") != (c.AdminTLSKeyFile == "") {
+		return fmt.Errorf("AdminTLSCertFile and AdminTLSKeyFile must be set together")
+	}
+	if c.AdminTLSCertFile != "" && c.AdminAddr == "" {
+		return fmt.Errorf("AdminTLSCertFile requires AdminAddr to be set")
+	}
+
+	if c.AdminSocket != "" && !filepath.IsLocal(c.AdminSocket) {
+		return fmt.Errorf(`AdminSocket "%s" must be a relative path within WorkDir`, c.AdminSocket)
+	}
+
+	if c.GopsAddr != "" {
+		if err := gops.ValidateAddr(c.GopsAddr); err != nil {
+			return fmt.Errorf(`GopsAddr "%s" must be a loopback address: %w`, c.GopsAddr, err)
+		}
+	}
+
+	if c.DebugAddr != "" {
+		if err := gops.ValidateAddr(c.DebugAddr); err != nil {
+			return fmt.Errorf(`DebugAddr "%s" must be a loopback address: %w`, c.DebugAddr, err)
+		}
+	}
+
+	if c.HealthStaleAfter != nil && c.HealthStaleAfter.Duration <= 0 {
+		return fmt.Errorf(`HealthStaleAfter "%s" must be positive`, c.HealthStaleAfter.Duration)
+	}
+
+	if c.HealthReadinessFailureThreshold < 0 || c.HealthReadinessSuccessThreshold < 0 {
+		return fmt.Errorf(`HealthReadinessFailureThreshold "%d" and HealthReadinessSuccessThreshold "%d" cannot be negative`, c.HealthReadinessFailureThreshold, c.HealthReadinessSuccessThreshold)
+	}
+
+	if c.MaxUptime != nil && c.MaxUptime.Duration <= 0 {
+		return fmt.Errorf(`MaxUptime "%s" must be positive`, c.MaxUptime.Duration)
+	}
+
+	if c.IdleExitAfter != nil {
+		if c.IdleExitAfter.Duration <= 0 {
+			return fmt.Errorf(`IdleExitAfter "%s" must be positive`, c.IdleExitAfter.Duration)
+		}
+		if c.MinRunners != 0 {
+			return fmt.Errorf(`IdleExitAfter requires MinRunners "%d" to be 0`, c.MinRunners)
+		}
+	}
+
+	if c.DrainTimeout != nil && c.DrainTimeout.Duration <= 0 {
+		return fmt.Errorf(`DrainTimeout "%s" must be positive`, c.DrainTimeout.Duration)
+	}
+
+	if c.LeaderElection != nil {
+		if c.LeaderElection.LeaseName == "" {
+			return fmt.Errorf("LeaderElection LeaseName is not provided")
+		}
+		leaseDuration, renewDeadline, retryPeriod := c.LeaderElection.Durations()
+		if retryPeriod <= 0 || renewDeadline <= retryPeriod || leaseDuration <= renewDeadline {
+			return fmt.Errorf(`LeaderElection LeaseDuration "%s", RenewDeadline "%s", and RetryPeriod "%s" must be positive and decreasing`, leaseDuration, renewDeadline, retryPeriod)
+		}
+	}
+
+	if c.HTTPClient != nil {
+		h := c.HTTPClient
+		for _, d := range []struct {
+			name     string
+			duration *metav1.Duration
+		}{
+			{"Timeout", h.Timeout},
+			{"TLSHandshakeTimeout", h.TLSHandshakeTimeout},
+			{"LongPollTimeout", h.LongPollTimeout},
+		} {
+			if d.duration != nil && d.duration.Duration <= 0 {
+				return fmt.Errorf(`HTTPClient %s "%s" must be positive`, d.name, d.duration.Duration)
+			}
+		}
+		if h.MaxIdleConns < 0 {
+			return fmt.Errorf(`HTTPClient MaxIdleConns "%d" cannot be negative`, h.MaxIdleConns)
+		}
+		if h.Timeout != nil && h.LongPollTimeout != nil && h.LongPollTimeout.Duration >= h.Timeout.Duration {
+			return fmt.Errorf(`HTTPClient LongPollTimeout "%s" must be lower than Timeout "%s"`, h.LongPollTimeout.Duration, h.Timeout.Duration)
+		}
+	}
+
+	if c.RunnerLimitsConfigMap != nil {
+		if c.RunnerLimitsConfigMap.Name == "" {
+			return fmt.Errorf("RunnerLimitsConfigMap Name is not provided")
+		}
+		if minRunnersKey, maxRunnersKey := c.RunnerLimitsConfigMap.Keys(); minRunnersKey == maxRunnersKey {
+			return fmt.Errorf(`RunnerLimitsConfigMap MinRunnersKey and MaxRunnersKey "%s" must be different`, minRunnersKey)
+		}
+	}
+
+	if c.Migration != nil {
+		m := c.Migration
+		if m.TargetEphemeralRunnerSetName == "" {
+			return fmt.Errorf("Migration TargetEphemeralRunnerSetName is not provided")
+		}
+		if m.TargetEphemeralRunnerSetName == c.EphemeralRunnerSetName {
+			return fmt.Errorf(`Migration TargetEphemeralRunnerSetName "%s" must differ from EphemeralRunnerSetName`, m.TargetEphemeralRunnerSetName)
+		}
+		if m.RampDuration != nil && m.RampDuration.Duration < 0 {
+			return fmt.Errorf(`Migration RampDuration "%s" cannot be negative`, m.RampDuration.Duration)
+		}
+		if m.Percentage < 0 || m.Percentage > 100 {
+			return fmt.Errorf(`Migration Percentage "%d" must be between 1 and 100`, m.Percentage)
+		}
+	}
+
+	for _, proxy := range []struct {
+		name string
+		url  string
+	}{
+		{"HTTPProxy", c.HTTPProxy},
+		{"HTTPSProxy", c.HTTPSProxy},
+	} {
+		if proxy.url == "" {
+			continue
+		}
+		if parsed, err := url.Parse(proxy.url); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf(`%s "%s" must be an absolute URL`, proxy.name, redactURL(proxy.url))
+		}
+	}
+
+	if c.Canary != nil {
+		canary := c.Canary
+		if c.Migration != nil {
+			return fmt.Errorf("Canary and Migration cannot be set together")
+		}
+		if canary.EphemeralRunnerSetName == "" {
+			return fmt.Errorf("Canary EphemeralRunnerSetName is not provided")
+		}
+		if canary.EphemeralRunnerSetName == c.EphemeralRunnerSetName {
+			return fmt.Errorf(`Canary EphemeralRunnerSetName "%s" must differ from EphemeralRunnerSetName`, canary.EphemeralRunnerSetName)
+		}
+		if canary.Percentage < 1 || canary.Percentage > 99 {
+			return fmt.Errorf(`Canary Percentage "%d" must be between 1 and 99`, canary.Percentage)
+		}
+		if canary.MinJobs < 0 {
+			return fmt.Errorf(`Canary MinJobs "%d" cannot be negative`, canary.MinJobs)
+		}
+		if r := canary.MaxFailureRateIncrease; r != nil && (*r < 0 || *r > 1) {
+			return fmt.Errorf(`Canary MaxFailureRateIncrease "%v" must be between 0 and 1`, *r)
+		}
+	}
+
+	if c.TopologySpread != nil {
+		if err := c.TopologySpread.validate(c); err != nil {
+			return err
+		}
+	}
+
+	if c.ScaleTarget != nil {
+		if err := c.ScaleTarget.validate(c); err != nil {
+			return err
+		}
+	}
+
+	if c.Audit != nil {
+		if err := c.Audit.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Telemetry != nil {
+		if u, err := url.Parse(c.Telemetry.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf(`Telemetry Endpoint "%s" must be an absolute URL`, redactURL(c.Telemetry.Endpoint))
+		}
+		if i := c.Telemetry.Interval; i != nil && i.Duration <= 0 {
+			return fmt.Errorf(`Telemetry Interval "%s" must be positive`, i.Duration)
+		}
+	}
+
+	if c.Predictor != nil {
+		if l := c.Predictor.Lead; l != nil && l.Duration <= 0 {
+			return fmt.Errorf(`Predictor Lead "%s" must be positive`, l.Duration)
+		}
+		if c.Predictor.Days < 0 {
+			return fmt.Errorf(`Predictor Days "%d" cannot be negative`, c.Predictor.Days)
+		}
+		if c.Predictor.HistoryFile != "" && !filepath.IsLocal(c.Predictor.HistoryFile) {
+			return fmt.Errorf(`Predictor HistoryFile "%s" must be a relative path within WorkDir`, c.Predictor.HistoryFile)
+		}
+	}
+
+	if c.JobNotifications != nil {
+		if err := c.JobNotifications.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.CapacityForecast != nil {
+		if err := c.CapacityForecast.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.PendingRunners != nil {
+		if err := c.PendingRunners.validate(); err != nil {
+			return err
+		}
+		if c.ScaleTarget != nil {
+			return fmt.Errorf("PendingRunners cannot be set along with ScaleTarget")
+		}
+	}
+
+	if c.StateExport != nil {
+		if err := c.StateExport.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.LogSampling != nil {
+		if err := c.LogSampling.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.WorkDir != "" && !filepath.IsAbs(c.WorkDir) {
+		return fmt.Errorf(`WorkDir "%s" must be an absolute path`, c.WorkDir)
+	}
+
+	if c.DeadLetterFile != "" && !filepath.IsLocal(c.DeadLetterFile) {
+		return fmt.Errorf(`DeadLetterFile "%s" must be a relative path within WorkDir`, c.DeadLetterFile)
+	}
+
+	if c.MaxScaleUpStep < 0 || c.MaxScaleDownStep < 0 {
+		return fmt.Errorf(`MaxScaleUpStep "%d" and MaxScaleDownStep "%d" cannot be negative`, c.MaxScaleUpStep, c.MaxScaleDownStep)
+	}
+
+	if c.DuplicatePatchTTL != nil && c.DuplicatePatchTTL.Duration <= 0 {
+		return fmt.Errorf(`DuplicatePatchTTL "%s" must be positive`, c.DuplicatePatchTTL.Duration)
+	}
+
+	if c.IdleTimeout != nil && c.IdleTimeout.Duration <= 0 {
+		return fmt.Errorf(`IdleTimeout "%s" must be positive`, c.IdleTimeout.Duration)
+	}
+
+	if c.HardMinRunners < 0 || c.HardMinRunners > c.MinRunners {
+		return fmt.Errorf(`HardMinRunners "%d" must be between 0 and MinRunners "%d"`, c.HardMinRunners, c.MinRunners)
+	}
+
+	if c.HardMinRunners > 0 && c.IdleTimeout == nil {
+		return fmt.Errorf("HardMinRunners requires IdleTimeout")
+	}
+
+	if c.MaxRunnerMinutesPerDay < 0 {
+		return fmt.Errorf(`MaxRunnerMinutesPerDay "%d" cannot be negative`, c.MaxRunnerMinutesPerDay)
+	}
+	if c.MaxRunnerMinutesPerDay > 0 && c.ScaleTarget != nil {
+		return fmt.Errorf("MaxRunnerMinutesPerDay cannot be set along with ScaleTarget")
+	}
+
+	if c.FallbackAfter != nil && c.FallbackAfter.Duration <= 0 {
+		return fmt.Errorf(`FallbackAfter "%s" must be positive`, c.FallbackAfter.Duration)
+	}
+
+	if c.SessionStartupTimeout != nil && c.SessionStartupTimeout.Duration <= 0 {
+		return fmt.Errorf(`SessionStartupTimeout "%s" must be positive`, c.SessionStartupTimeout.Duration)
+	}
+
+	if c.FallbackReplicas != nil {
+		if c.FallbackAfter == nil {
+			return fmt.Errorf("FallbackReplicas requires FallbackAfter")
+		}
+		if *c.FallbackReplicas < 0 {
+			return fmt.Errorf(`FallbackReplicas "%d" cannot be negative`, *c.FallbackReplicas)
+		}
+	}
+
+	for i, o := range c.ScheduledOverrides {
+		if o.TimeZone != "" {
+			if _, err := time.LoadLocation(o.TimeZone); err != nil {
+				return fmt.Errorf("ScheduledOverrides[%d] has invalid TimeZone %q: %w", i, o.TimeZone, err)
+			}
+		}
+		if o.MaxRunners != nil && *o.MaxRunners > c.MaxRunners {
+			return fmt.Errorf(`ScheduledOverrides[%d] MaxRunners "%d" cannot be greater than MaxRunners "%d"`, i, *o.MaxRunners, c.MaxRunners)
+		}
+	}
+
+	if c.StaleRunnerGracePeriod != nil && c.StaleRunnerGracePeriod.Duration < 0 {
+		return fmt.Errorf(`StaleRunnerGracePeriod "%s" cannot be negative`, c.StaleRunnerGracePeriod.Duration)
+	}
+
+	if c.EphemeralRunnerCache && c.ScaleTarget != nil {
+		return fmt.Errorf("EphemeralRunnerCache cannot be set along with ScaleTarget")
+	}
+
+	for i := range c.JobPolicies {
+		if err := c.JobPolicies[i].validate(); err != nil {
+			return fmt.Errorf("JobPolicies[%d] is invalid: %w", i, err)
+		}
+	}
+
+	if c.PriorityRunners < 0 || c.PriorityRunners > c.MaxRunners {
+		return fmt.Errorf(`PriorityRunners "%d" must be between 0 and MaxRunners "%d"`, c.PriorityRunners, c.MaxRunners)
+	}
+	if c.PriorityRunners > 0 && !slices.ContainsFunc(c.JobPolicies, func(p JobPolicy) bool { return p.Priority }) {
+		return fmt.Errorf("PriorityRunners requires a JobPolicy with Priority set")
+	}
+
+	switch worker.MissingRunnerPolicy(c.MissingRunnerPolicy) {
+	case "", worker.MissingRunnerSkip, worker.MissingRunnerRetry, worker.MissingRunnerFail:
+	default:
+		return fmt.Errorf(`MissingRunnerPolicy "%s" must be one of "%s", "%s" and "%s"`, c.MissingRunnerPolicy, worker.MissingRunnerSkip, worker.MissingRunnerRetry, worker.MissingRunnerFail)
+	}
+
+	switch listener.MisroutedJobPolicy(c.MisroutedJobPolicy) {
+	case "", listener.MisroutedJobProvision, listener.MisroutedJobExclude:
+	default:
+		return fmt.Errorf(`MisroutedJobPolicy "%s" must be one of "%s" and "%s"`, c.MisroutedJobPolicy, listener.MisroutedJobProvision, listener.MisroutedJobExclude)
+	}
+
+	if (c.MetricsTLSCertFile == "") != (c.MetricsTLSKeyFile == "") {
+		return fmt.Errorf("MetricsTLSCertFile and MetricsTLSKeyFile must be set together")
+	}
+
+	if (c.MetricsBasicAuthUsername == "") != (c.MetricsBasicAuthPasswordFile == "") {
+		return fmt.Errorf("MetricsBasicAuthUsername and MetricsBasicAuthPasswordFile must be set together")
+	}
+
+	if c.Metrics != nil && c.Metrics.Push != nil {
+		push := c.Metrics.Push
+		if push.PushgatewayURL == "" && push.RemoteWriteURL == "" {
+			return fmt.Errorf("Metrics push requires PushgatewayURL or RemoteWriteURL to be set")
+		}
+		for _, u := range []string{push.PushgatewayURL, push.RemoteWriteURL} {
+			if u == "" {
+				continue
+			}
+			if parsed, err := url.Parse(u); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+				return fmt.Errorf(`Metrics push URL "%s" must be an absolute URL`, u)
+			}
+		}
+		if push.Interval != nil && push.Interval.Duration <= 0 {
+			return fmt.Errorf(`Metrics push Interval "%s" must be positive`, push.Interval.Duration)
+		}
+	}
+
+	if c.Metrics != nil {
+		for name, h := range c.Metrics.Histograms {
+			if h == nil {
+				continue
+			}
+			if h.NativeHistogramBucketFactor != 0 && h.NativeHistogramBucketFactor <= 1 {
+				return fmt.Errorf(`Histogram "%s" NativeHistogramBucketFactor "%g" must be greater than 1`, name, h.NativeHistogramBucketFactor)
+			}
+			if h.NativeHistogramMaxBuckets < 0 {
+				return fmt.Errorf(`Histogram "%s" NativeHistogramMaxBuckets "%d" cannot be negative`, name, h.NativeHistogramMaxBuckets)
+			}
+		}
 	}
 
 	if c.VaultType == "" && c.VaultLookupKey == "" {
@@ -145,12 +1550,21 @@ func (c *Config) Logger() (logr.Logger, error) {
 		logFormat = c.LogFormat
 	}
 
-	logger, err := logging.NewLogger(logLevel, logFormat)
+	var options []logging.Option
+	if s := c.LogSampling; s != nil {
+		interval := DefaultLogSamplingInterval
+		if s.Interval != nil {
+			interval = s.Interval.Duration
+		}
+		options = append(options, logging.WithSampling(interval, s.First, s.Thereafter))
+	}
+
+	logger, err := logging.NewLogger(logLevel, logFormat, options...)
 	if err != nil {
 		return logr.Logger{}, fmt.Errorf("NewLogger failed: %w", err)
 	}
 
-	return logger, nil
+	return logging.Redact(logger), nil
 }
 
 func (c *Config) ActionsClient(logger logr.Logger, clientOptions ...actions.ClientOption) (*actions.Client, error) {
@@ -158,9 +1572,10 @@ func (c *Config) ActionsClient(logger logr.Logger, clientOptions ...actions.Clie
 	switch c.Token {
 	case "":
 		creds.AppCreds = &actions.GitHubAppAuth{
-			AppID:             c.AppID,
-			AppInstallationID: c.AppInstallationID,
-			AppPrivateKey:     c.AppPrivateKey,
+			AppID:               c.AppID,
+			AppInstallationID:   c.AppInstallationID,
+			AppPrivateKey:       c.AppPrivateKey,
+			AppPrivateKeySigner: c.AppPrivateKeySigner,
 		}
 	default:
 		creds.Token = c.Token
@@ -168,23 +1583,29 @@ func (c *Config) ActionsClient(logger logr.Logger, clientOptions ...actions.Clie
 
 	options := append([]actions.ClientOption{
 		actions.WithLogger(logger),
-	}, clientOptions...)
+	}, c.HTTPClient.options()...)
+	options = append(options, clientOptions...)
 
-	if c.ServerRootCA != "" {
-		systemPool, err := x509.SystemCertPool()
+	pool, err := c.rootCAs()
+	if err != nil {
+		return nil, err
+	}
+	if pool != nil {
+		options = append(options, actions.WithRootCAs(pool))
+	}
+
+	proxyFunc := c.proxyConfig().ProxyFunc()
+	if c.ServerCertRevocation != nil {
+		ghConfig, err := actions.ParseGitHubConfigFromURL(c.ConfigureUrl)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load system cert pool: %w", err)
+			return nil, fmt.Errorf("failed to parse GitHub config URL: %w", err)
 		}
-		pool := systemPool.Clone()
-		ok := pool.AppendCertsFromPEM([]byte(c.ServerRootCA))
-		if !ok {
-			return nil, fmt.Errorf("failed to parse root certificate")
+		checker, err := c.ServerCertRevocation.checker(ghConfig.ConfigURL.Hostname(), proxyFunc, logger.WithName("revocation"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create certificate revocation checker: %w", err)
 		}
-
-		options = append(options, actions.WithRootCAs(pool))
+		options = append(options, actions.WithVerifyConnection("revocation", checker.VerifyConnection))
 	}
-
-	proxyFunc := httpproxy.FromEnvironment().ProxyFunc()
 	options = append(options, actions.WithProxy(func(req *http.Request) (*url.URL, error) {
 		return proxyFunc(req.URL)
 	}))
@@ -198,14 +1619,294 @@ func (c *Config) ActionsClient(logger logr.Logger, clientOptions ...actions.Clie
 		Version:    build.Version,
 		CommitSHA:  build.CommitSHA,
 		ScaleSetID: c.RunnerScaleSetId,
-		HasProxy:   hasProxy(),
+		HasProxy:   c.hasProxy(),
 		Subsystem:  "ghalistener",
 	})
 
 	return client, nil
 }
 
-func hasProxy() bool {
-	proxyFunc := httpproxy.FromEnvironment().ProxyFunc()
+// rootCAs returns the system root certificates along with the ones of ServerRootCA, ServerRootCAFiles
+// and ServerRootCADir, or nil if none of them is set.
+func (c *Config) rootCAs() (*x509.CertPool, error) {
+	if c.ServerRootCA == "" && len(c.ServerRootCAFiles) == 0 && c.ServerRootCADir == "" {
+		return nil, nil
+	}
+
+	systemPool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load system cert pool: %w", err)
+	}
+	pool := systemPool.Clone()
+	if c.ServerRootCA != "" {
+		if ok := pool.AppendCertsFromPEM([]byte(c.ServerRootCA)); !ok {
+			return nil, fmt.Errorf("failed to parse root certificate")
+		}
+	}
+
+	files := slices.Clone(c.ServerRootCAFiles)
+	if c.ServerRootCADir != "" {
+		entries, err := os.ReadDir(c.ServerRootCADir)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.ConfigRead, "failed to read root certificates directory: %w", err)
+		}
+		for _, entry := range entries {
+			// The kubelet links the files of the ConfigMap volumes from hidden directories, e.g. ..data.
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			if ext := filepath.Ext(entry.Name()); ext == ".pem" || ext == ".crt" {
+				files = append(files, filepath.Join(c.ServerRootCADir, entry.Name()))
+			}
+		}
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.ConfigRead, "failed to read root certificates: %w", err)
+		}
+		if ok := pool.AppendCertsFromPEM(data); !ok {
+			return nil, errcode.Errorf(errcode.ConfigInvalid, "failed to parse root certificates of %s", file)
+		}
+	}
+
+	return pool, nil
+}
+
+// hostedActionsServiceURLs are the Actions service endpoints the listener talks to on github.com and ghe.com.
+// On GitHub Enterprise Server, the Actions service is served by the GitHub host itself.
+var hostedActionsServiceURLs = []string{
+	"https://pipelines.actions.githubusercontent.com",
+	"https://broker.actions.githubusercontent.com",
+}
+
+// ProxyDecision describes the proxy used to reach a GitHub endpoint.
+type ProxyDecision struct {
+	Endpoint string
+	// Proxy is the URL of the proxy used for the endpoint.
+	// It is empty if the endpoint is reached directly.
+	Proxy string
+}
+
+// ProxyDecisions evaluates the proxy configuration, including NO_PROXY,
+// against every GitHub endpoint the listener talks to.
+func (c *Config) ProxyDecisions() ([]ProxyDecision, error) {
+	ghConfig, err := actions.ParseGitHubConfigFromURL(c.ConfigureUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub config from URL: %w", err)
+	}
+
+	endpoints := []string{
+		ghConfig.ConfigURL.String(),
+		ghConfig.GitHubAPIURL("").String(),
+	}
+	if ghConfig.IsHosted {
+		endpoints = append(endpoints, hostedActionsServiceURLs...)
+	}
+
+	proxyFunc := c.proxyConfig().ProxyFunc()
+	decisions := make([]ProxyDecision, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse endpoint %q: %w", endpoint, err)
+		}
+
+		proxyURL, err := proxyFunc(u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate proxy for endpoint %q: %w", endpoint, err)
+		}
+
+		decision := ProxyDecision{Endpoint: endpoint}
+		if proxyURL != nil {
+			decision.Proxy = proxyURL.Redacted()
+		}
+		decisions = append(decisions, decision)
+	}
+
+	return decisions, nil
+}
+
+func (c *Config) hasProxy() bool {
+	proxyFunc := c.proxyConfig().ProxyFunc()
 	return proxyFunc != nil
 }
+
+// proxyConfig returns the proxy configuration of the environment, overridden by the proxy fields of the config.
+func (c *Config) proxyConfig() *httpproxy.Config {
+	proxyConfig := httpproxy.FromEnvironment()
+	if c.HTTPProxy != "" {
+		proxyConfig.HTTPProxy = c.HTTPProxy
+	}
+	if c.HTTPSProxy != "" {
+		proxyConfig.HTTPSProxy = c.HTTPSProxy
+	}
+	if c.NoProxy != "" {
+		proxyConfig.NoProxy = c.NoProxy
+	}
+	return proxyConfig
+}
+
+func (s *TopologySpread) validate(c *Config) error {
+	if c.Migration != nil || c.Canary != nil || c.ScaleTarget != nil {
+		return fmt.Errorf("TopologySpread cannot be set along with Migration, Canary, or ScaleTarget")
+	}
+	if s.TopologyKey == "" {
+		return fmt.Errorf("TopologySpread TopologyKey is not provided")
+	}
+	if len(s.Zones) < 2 {
+		return fmt.Errorf(`TopologySpread Zones "%d" must be at least 2`, len(s.Zones))
+	}
+	names := make(map[string]bool, len(s.Zones))
+	sets := make(map[string]bool, len(s.Zones))
+	for _, zone := range s.Zones {
+		if zone.Name == "" {
+			return fmt.Errorf("TopologySpread zone Name is not provided")
+		}
+		if names[zone.Name] {
+			return fmt.Errorf(`TopologySpread zone Name "%s" is not unique`, zone.Name)
+		}
+		names[zone.Name] = true
+		set := cmp.Or(zone.EphemeralRunnerSetName, c.EphemeralRunnerSetName)
+		if sets[set] {
+			return fmt.Errorf(`TopologySpread zone EphemeralRunnerSetName "%s" is not unique`, set)
+		}
+		sets[set] = true
+		if zone.Weight < 0 {
+			return fmt.Errorf(`TopologySpread zone Weight "%d" cannot be negative`, zone.Weight)
+		}
+	}
+	if !sets[c.EphemeralRunnerSetName] {
+		return fmt.Errorf(`TopologySpread requires a zone served by EphemeralRunnerSetName "%s"`, c.EphemeralRunnerSetName)
+	}
+	return nil
+}
+
+func (t *ScaleTarget) validate(c *Config) error {
+	if c.Migration != nil || c.Canary != nil || c.ResumeSession {
+		return fmt.Errorf("ScaleTarget cannot be set along with Migration, Canary, or ResumeSession")
+	}
+	if (t.Kubernetes == nil) == (t.Webhook == nil) {
+		return fmt.Errorf("ScaleTarget requires exactly one of Kubernetes and Webhook to be set")
+	}
+	if k := t.Kubernetes; k != nil {
+		if k.Name == "" {
+			return fmt.Errorf("ScaleTarget Kubernetes Name is not provided")
+		}
+		if k.APIVersion != "" {
+			if _, err := schema.ParseGroupVersion(k.APIVersion); err != nil {
+				return fmt.Errorf(`ScaleTarget Kubernetes APIVersion "%s" is invalid: %w`, k.APIVersion, err)
+			}
+		}
+	}
+	if w := t.Webhook; w != nil {
+		if parsed, err := url.Parse(w.URL); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf(`ScaleTarget Webhook URL "%s" must be an absolute HTTPS URL`, redactURL(w.URL))
+		}
+		if w.SecretFile == "" {
+			return fmt.Errorf("ScaleTarget Webhook SecretFile is not provided")
+		}
+		if w.Timeout != nil && w.Timeout.Duration <= 0 {
+			return fmt.Errorf(`ScaleTarget Webhook Timeout "%s" must be positive`, w.Timeout.Duration)
+		}
+	}
+	return nil
+}
+
+func (a *Audit) validate() error {
+	if (a.File == "") == (a.URL == "") {
+		return fmt.Errorf("Audit requires exactly one of File and URL to be set")
+	}
+	if a.File != "" && !filepath.IsLocal(a.File) {
+		return fmt.Errorf(`Audit File "%s" must be a relative path within WorkDir`, a.File)
+	}
+	if a.URL != "" {
+		if parsed, err := url.Parse(a.URL); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf(`Audit URL "%s" must be an absolute HTTPS URL`, redactURL(a.URL))
+		}
+	}
+	if a.BearerTokenFile != "" && a.URL == "" {
+		return fmt.Errorf("Audit BearerTokenFile requires URL to be set")
+	}
+	if a.Timeout != nil && a.Timeout.Duration <= 0 {
+		return fmt.Errorf(`Audit Timeout "%s" must be positive`, a.Timeout.Duration)
+	}
+	return nil
+}
+
+func (s *LogSampling) validate() error {
+	if s.First <= 0 {
+		return fmt.Errorf(`LogSampling First "%d" must be positive`, s.First)
+	}
+	if s.Thereafter < 0 {
+		return fmt.Errorf(`LogSampling Thereafter "%d" cannot be negative`, s.Thereafter)
+	}
+	if i := s.Interval; i != nil && i.Duration <= 0 {
+		return fmt.Errorf(`LogSampling Interval "%s" must be positive`, i.Duration)
+	}
+	return nil
+}
+
+func (e *StateExport) validate() error {
+	if e.File == "" && e.SecretName == "" {
+		return fmt.Errorf("StateExport requires at least one of File and SecretName to be set")
+	}
+	if e.File != "" && !filepath.IsLocal(e.File) {
+		return fmt.Errorf(`StateExport File "%s" must be a relative path within WorkDir`, e.File)
+	}
+	if i := e.Interval; i != nil && i.Duration <= 0 {
+		return fmt.Errorf(`StateExport Interval "%s" must be positive`, i.Duration)
+	}
+	return nil
+}
+
+func (f *CapacityForecast) validate() error {
+	if i := f.Interval; i != nil && i.Duration <= 0 {
+		return fmt.Errorf(`CapacityForecast Interval "%s" must be positive`, i.Duration)
+	}
+	if h := f.Horizon; h != nil && (h.Duration <= 0 || h.Duration > MaxCapacityForecastHorizon) {
+		return fmt.Errorf(`CapacityForecast Horizon "%s" must be positive and at most %s`, h.Duration, MaxCapacityForecastHorizon)
+	}
+	if w := f.Webhook; w != nil {
+		if parsed, err := url.Parse(w.URL); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf(`CapacityForecast Webhook URL "%s" must be an absolute HTTPS URL`, redactURL(w.URL))
+		}
+		if w.Timeout != nil && w.Timeout.Duration <= 0 {
+			return fmt.Errorf(`CapacityForecast Webhook Timeout "%s" must be positive`, w.Timeout.Duration)
+		}
+	}
+	return nil
+}
+
+func (p *PendingRunners) validate() error {
+	if t := p.Threshold; t != nil && t.Duration <= 0 {
+		return fmt.Errorf(`PendingRunners Threshold "%s" must be positive`, t.Duration)
+	}
+	if i := p.Interval; i != nil && i.Duration <= 0 {
+		return fmt.Errorf(`PendingRunners Interval "%s" must be positive`, i.Duration)
+	}
+	return nil
+}
+
+func (n *JobNotifications) validate() error {
+	if parsed, err := url.Parse(n.URL); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf(`JobNotifications URL "%s" must be an absolute HTTPS URL`, redactURL(n.URL))
+	}
+	switch n.Format {
+	case "", notify.FormatJSON, notify.FormatSlack:
+	default:
+		return fmt.Errorf(`JobNotifications Format "%s" must be one of "%s" and "%s"`, n.Format, notify.FormatJSON, notify.FormatSlack)
+	}
+	if n.Timeout != nil && n.Timeout.Duration <= 0 {
+		return fmt.Errorf(`JobNotifications Timeout "%s" must be positive`, n.Timeout.Duration)
+	}
+	return nil
+}
+
+// redactURL hides the password of the URL, if it can be parsed.
+func redactURL(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Redacted()
+	}
+	return "<unparseable>"
+}
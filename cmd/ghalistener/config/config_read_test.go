@@ -0,0 +1,118 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const configWithToken = `{
+	"configure_url": "https://github.com/org",
+	"ephemeral_runner_set_namespace": "namespace",
+	"ephemeral_runner_set_name": "deployment",
+	"runner_scale_set_id": 1,
+	"github_token": "token"
+}`
+
+func writeConfig(t *testing.T, dir string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(configWithToken), mode))
+	require.NoError(t, os.Chmod(path, mode))
+	return path
+}
+
+func TestReadCredentialsFilePermissions(t *testing.T) {
+	t.Run("RefusesWorldReadableFile", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), 0o644)
+
+		_, err := config.Read(context.Background(), path)
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "must not be world-readable")
+		code, ok := errcode.Of(err)
+		require.True(t, ok)
+		assert.Equal(t, errcode.ConfigInvalid, code)
+	})
+
+	t.Run("AcceptsPrivateFile", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), 0o600)
+
+		cfg, err := config.Read(context.Background(), path)
+		require.NoError(t, err)
+		assert.Equal(t, "token", cfg.Token)
+	})
+
+	t.Run("AcceptsSecretVolume", func(t *testing.T) {
+		dir := t.TempDir()
+		dataDir := filepath.Join(dir, "..data")
+		require.NoError(t, os.Mkdir(dataDir, 0o755))
+		writeConfig(t, dataDir, 0o644)
+
+		path := filepath.Join(dir, "config.json")
+		require.NoError(t, os.Symlink(filepath.Join("..data", "config.json"), path))
+
+		_, err := config.Read(context.Background(), path)
+		require.NoError(t, err)
+	})
+}
+
+func TestScrubCredentials(t *testing.T) {
+	appConfig := &appconfig.AppConfig{Token: "token"}
+	cfg := &config.Config{AppConfig: appConfig}
+
+	cfg.ScrubCredentials()
+
+	assert.Nil(t, cfg.AppConfig)
+	assert.Empty(t, appConfig.Token, "shared references must not keep the credentials")
+}
+
+func TestConfigHash(t *testing.T) {
+	cfg := &config.Config{
+		ConfigureUrl: "https://github.com/org",
+		MaxRunners:   10,
+		AppConfig:    &appconfig.AppConfig{Token: "token"},
+	}
+	hash := cfg.Hash()
+	assert.Len(t, hash, 12)
+
+	cfg.AppConfig = &appconfig.AppConfig{Token: "rotated"}
+	assert.Equal(t, hash, cfg.Hash(), "the credentials are not part of the hash")
+
+	cfg.MaxRunners = 20
+	assert.NotEqual(t, hash, cfg.Hash())
+}
+
+func TestReadCredentialsFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "github_app_private_key"), []byte("private key\n"), 0o600))
+
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+	"configure_url": "https://github.com/org",
+	"ephemeral_runner_set_namespace": "namespace",
+	"ephemeral_runner_set_name": "deployment",
+	"runner_scale_set_id": 1,
+	"github_app_id": "123",
+	"github_app_installation_id": 456,
+	"github_app_private_key_file": "`+filepath.Join(dir, "github_app_private_key")+`"
+}`), 0o644))
+
+	cfg, err := config.Read(context.Background(), path)
+	require.NoError(t, err, "the config without inline credentials may be readable by others")
+	assert.Equal(t, "123", cfg.AppID)
+	assert.Equal(t, int64(456), cfg.AppInstallationID)
+	assert.Equal(t, "private key", cfg.AppPrivateKey)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "github_app_private_key")))
+	_, err = config.Read(context.Background(), path)
+	code, ok := errcode.Of(err)
+	require.True(t, ok)
+	assert.Equal(t, errcode.ConfigRead, code)
+}
@@ -0,0 +1,80 @@
+// Package debugserver serves the net/http/pprof profiles and the expvar variables of the listener, so the
+// memory growth and goroutine leaks of a long-running listener can be diagnosed in production:
+//
+//	kubectl port-forward <listener pod> <port> & go tool pprof http://127.0.0.1:<port>/debug/pprof/heap
+//	curl http://127.0.0.1:<port>/debug/vars
+//
+// The server only listens on loopback addresses, since its endpoints are not authenticated.
+package debugserver
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/gops"
+	"github.com/go-logr/logr"
+)
+
+const (
+	// PprofPath is the path prefix of the pprof index and profiles.
+	PprofPath = "/debug/pprof/"
+	// VarsPath is the path of the expvar variables, the memory stats and the command line among them.
+	VarsPath = "/debug/vars"
+)
+
+type ServerConfig struct {
+	// Addr is the loopback address the server listens on. It is required.
+	Addr   string
+	Logger logr.Logger
+}
+
+// Server serves the debug endpoints of the listener.
+type Server struct {
+	srv    *http.Server
+	logger logr.Logger
+}
+
+func NewServer(config ServerConfig) *Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PprofPath, pprof.Index)
+	mux.HandleFunc(PprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(PprofPath+"profile", pprof.Profile)
+	mux.HandleFunc(PprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(PprofPath+"trace", pprof.Trace)
+	mux.Handle(VarsPath, expvar.Handler())
+
+	return &Server{
+		srv: &http.Server{
+			Addr:              config.Addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		logger: config.Logger,
+	}
+}
+
+// ListenAndServe serves the debug endpoints until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if err := gops.ValidateAddr(s.srv.Addr); err != nil {
+		return errcode.Errorf(errcode.DebugServer, "refusing to serve the debug endpoints on %q: %w", s.srv.Addr, err)
+	}
+
+	s.logger.Info("starting debug server", "addr", s.srv.Addr)
+	go func() {
+		<-ctx.Done()
+		s.logger.Info("stopping debug server", "err", ctx.Err())
+		// The CPU profiles and traces being taken are cut short, rather than delaying the exit of the listener.
+		s.srv.Close()
+	}()
+
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errcode.Wrap(errcode.DebugServer, fmt.Errorf("failed to serve the debug endpoints: %w", err))
+	}
+	return nil
+}
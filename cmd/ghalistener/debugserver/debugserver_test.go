@@ -0,0 +1,49 @@
+package debugserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Endpoints(t *testing.T) {
+	server := NewServer(ServerConfig{Addr: "127.0.0.1:0", Logger: logr.Discard()})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get(PprofPath)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine")
+
+	rec = get(PprofPath + "goroutine?debug=1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine profile:")
+
+	rec = get(PprofPath + "heap")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, rec.Body.Bytes())
+
+	rec = get(VarsPath)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var vars map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &vars))
+	assert.Contains(t, vars, "memstats")
+}
+
+func TestServer_RefusesNonLoopbackAddr(t *testing.T) {
+	err := NewServer(ServerConfig{Addr: "0.0.0.0:6061", Logger: logr.Discard()}).ListenAndServe(context.Background())
+	code, ok := errcode.Of(err)
+	require.True(t, ok)
+	assert.Equal(t, errcode.DebugServer, code)
+}
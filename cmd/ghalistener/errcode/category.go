@@ -0,0 +1,106 @@
+package errcode
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/actions/actions-runner-controller/vault"
+)
+
+// Category classifies the errors of the listener by how they may be recovered from, so that the supervisor
+// of the listener can tell e.g. bad credentials, which a restart does not fix, from a transient network error.
+type Category string
+
+const (
+	// CategoryConfig is an invalid or unreadable configuration. A restart does not fix it.
+	CategoryConfig Category = "config"
+	// CategoryAuth is credentials rejected by GitHub, or which could not be read or rotated. A restart does not fix it.
+	CategoryAuth Category = "auth"
+	// CategoryGitHubAPI is a failure of the GitHub Actions service, e.g. an outage or a network error.
+	CategoryGitHubAPI Category = "github-api"
+	// CategoryKubernetesAPI is a failure of the Kubernetes API, e.g. an unreachable API server or missing permissions.
+	CategoryKubernetesAPI Category = "kubernetes-api"
+	// CategoryVault is a transient failure of the vault, e.g. an outage, a network error, or throttling.
+	// A restart may fix it, unlike the credentials which could not be read from the vault.
+	CategoryVault Category = "vault"
+	// CategoryUnknown is every other error, including the errors without a code.
+	CategoryUnknown Category = "unknown"
+)
+
+// The exit codes of the listener process, one per category. The errors without a category exit with 1,
+// as the listener always did. The codes of the categories start at 10, away from 2, which is the exit code
+// of the Go runtime on an unrecovered panic and of the command-line usage errors.
+const (
+	ExitUnknown       = 1
+	ExitConfig        = 10
+	ExitAuth          = 11
+	ExitGitHubAPI     = 12
+	ExitKubernetesAPI = 13
+	ExitVault         = 14
+)
+
+// ExitCode returns the exit code of the listener process failing with an error of the category.
+func (c Category) ExitCode() int {
+	switch c {
+	case CategoryConfig:
+		return ExitConfig
+	case CategoryAuth:
+		return ExitAuth
+	case CategoryGitHubAPI:
+		return ExitGitHubAPI
+	case CategoryKubernetesAPI:
+		return ExitKubernetesAPI
+	case CategoryVault:
+		return ExitVault
+	default:
+		return ExitUnknown
+	}
+}
+
+// Category returns the category of the errors annotated with the code, from the range of the code.
+func (c Code) Category() Category {
+	switch {
+	case c == VaultRead || c == CredentialRotation:
+		return CategoryAuth
+	case strings.HasPrefix(string(c), "ARC-LSTN-1"):
+		return CategoryConfig
+	case strings.HasPrefix(string(c), "ARC-LSTN-2"):
+		return CategoryGitHubAPI
+	case strings.HasPrefix(string(c), "ARC-LSTN-3"):
+		return CategoryKubernetesAPI
+	default:
+		return CategoryUnknown
+	}
+}
+
+// Classify returns the category of err. A GitHub error with status 401 Unauthorized in the chain of err
+// is an auth error whatever its code, and a vault error a transient failure, e.g. throttling, is a vault error.
+// Otherwise, the category is the one of the outermost code of err.
+func Classify(err error) Category {
+	if err == nil {
+		return CategoryUnknown
+	}
+	var apiErr *actions.GitHubAPIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		return CategoryAuth
+	}
+	var actionsErr *actions.ActionsError
+	if errors.As(err, &actionsErr) && actionsErr.StatusCode == http.StatusUnauthorized {
+		return CategoryAuth
+	}
+	code, ok := Of(err)
+	if !ok {
+		return CategoryUnknown
+	}
+	if code == VaultRead && vault.IsTransient(err) {
+		return CategoryVault
+	}
+	return code.Category()
+}
+
+// ExitCode returns the exit code of the listener process failing with err.
+func ExitCode(err error) int {
+	return Classify(err).ExitCode()
+}
@@ -0,0 +1,73 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestClassify(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		category Category
+		exitCode int
+	}{
+		"NoCode": {
+			err:      errors.New("boom"),
+			category: CategoryUnknown,
+			exitCode: ExitUnknown,
+		},
+		"Config": {
+			err:      Errorf(ConfigInvalid, "failed to validate configuration: %w", errors.New("boom")),
+			category: CategoryConfig,
+			exitCode: ExitConfig,
+		},
+		"Credentials": {
+			err:      Wrap(VaultRead, errors.New("boom")),
+			category: CategoryAuth,
+			exitCode: ExitAuth,
+		},
+		"VaultThrottled": {
+			err:      Errorf(VaultRead, "failed to get app config from vault: %w", apierrors.NewTooManyRequests("slow down", 1)),
+			category: CategoryVault,
+			exitCode: ExitVault,
+		},
+		"VaultUnreachable": {
+			err:      Wrap(VaultRead, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}),
+			category: CategoryVault,
+			exitCode: ExitVault,
+		},
+		"GitHubAPI": {
+			err:      Errorf(SessionCreate, "failed to create session: %w", &actions.ActionsError{StatusCode: http.StatusServiceUnavailable}),
+			category: CategoryGitHubAPI,
+			exitCode: ExitGitHubAPI,
+		},
+		"Unauthorized": {
+			err:      Errorf(SessionCreate, "failed to create session: %w", &actions.GitHubAPIError{StatusCode: http.StatusUnauthorized}),
+			category: CategoryAuth,
+			exitCode: ExitAuth,
+		},
+		"KubernetesAPI": {
+			err:      fmt.Errorf("worker failed: %w", Wrap(EphemeralRunnerSetPatch, errors.New("boom"))),
+			category: CategoryKubernetesAPI,
+			exitCode: ExitKubernetesAPI,
+		},
+		"Server": {
+			err:      Wrap(MetricsServer, errors.New("address already in use")),
+			category: CategoryUnknown,
+			exitCode: ExitUnknown,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.category, Classify(tt.err))
+			assert.Equal(t, tt.exitCode, ExitCode(tt.err))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Package errcode defines the stable error codes attached to operator-facing errors of the listener.
+//
+// Codes never change meaning once released, so runbooks and automated remediation
+// can key off them instead of error messages. They are grouped by range:
+//
+//	ARC-LSTN-1xxx  configuration and credentials
+//	ARC-LSTN-2xxx  GitHub Actions service
+//	ARC-LSTN-3xxx  Kubernetes API and scale targets
+//	ARC-LSTN-4xxx  metrics, health, diagnostics, and admin servers
+//
+// The errors are further classified into a Category, which the listener process exits with as its exit code.
+package errcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code is a stable, machine-parseable identifier of an error condition.
+type Code string
+
+const (
+	ConfigRead         Code = "ARC-LSTN-1001"
+	ConfigInvalid      Code = "ARC-LSTN-1002"
+	VaultRead          Code = "ARC-LSTN-1003"
+	CredentialRotation Code = "ARC-LSTN-1004"
+
+	SessionCreate  Code = "ARC-LSTN-2001"
+	SessionRefresh Code = "ARC-LSTN-2002"
+	SessionDelete  Code = "ARC-LSTN-2003"
+	MessageGet     Code = "ARC-LSTN-2004"
+	MessageDelete  Code = "ARC-LSTN-2005"
+	MessageInvalid Code = "ARC-LSTN-2006"
+	JobAcquire     Code = "ARC-LSTN-2007"
+
+	KubernetesClient        Code = "ARC-LSTN-3001"
+	EphemeralRunnerSetPatch Code = "ARC-LSTN-3002"
+	EphemeralRunnerPatch    Code = "ARC-LSTN-3003"
+	LeaderElection          Code = "ARC-LSTN-3004"
+	ScaleTarget             Code = "ARC-LSTN-3005"
+	APIDiscovery            Code = "ARC-LSTN-3006"
+	EphemeralRunnerNotFound Code = "ARC-LSTN-3007"
+
+	MetricsServer Code = "ARC-LSTN-4001"
+	HealthServer  Code = "ARC-LSTN-4002"
+	GopsAgent     Code = "ARC-LSTN-4003"
+	KedaScaler    Code = "ARC-LSTN-4004"
+	AdminServer   Code = "ARC-LSTN-4005"
+	DebugServer   Code = "ARC-LSTN-4006"
+)
+
+func (c Code) String() string {
+	return string(c)
+}
+
+// Error is an error annotated with a Code.
+type Error struct {
+	Code Code
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Code, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap annotates err with the code. It returns nil if err is nil.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Errorf formats an error according to the format specifier and annotates it with the code.
+func Errorf(code Code, format string, args ...any) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Of returns the code of the outermost annotated error in the chain of err.
+func Of(err error) (Code, bool) {
+	var codeErr *Error
+	if !errors.As(err, &codeErr) {
+		return "", false
+	}
+	return codeErr.Code, true
+}
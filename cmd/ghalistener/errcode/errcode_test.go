@@ -0,0 +1,44 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrap(t *testing.T) {
+	t.Run("NilError", func(t *testing.T) {
+		assert.NoError(t, Wrap(SessionCreate, nil))
+	})
+
+	t.Run("PrefixesMessageAndUnwraps", func(t *testing.T) {
+		cause := errors.New("boom")
+		err := Wrap(SessionCreate, cause)
+		assert.Equal(t, "ARC-LSTN-2001: boom", err.Error())
+		assert.ErrorIs(t, err, cause)
+	})
+}
+
+func TestOf(t *testing.T) {
+	t.Run("NoCode", func(t *testing.T) {
+		_, ok := Of(errors.New("boom"))
+		assert.False(t, ok)
+	})
+
+	t.Run("WrappedCode", func(t *testing.T) {
+		err := fmt.Errorf("listener failed: %w", Errorf(MessageGet, "failed to get next message: %w", errors.New("boom")))
+		code, ok := Of(err)
+		require.True(t, ok)
+		assert.Equal(t, MessageGet, code)
+	})
+
+	t.Run("OutermostCodeWins", func(t *testing.T) {
+		err := Wrap(ConfigInvalid, Wrap(VaultRead, errors.New("boom")))
+		code, ok := Of(err)
+		require.True(t, ok)
+		assert.Equal(t, ConfigInvalid, code)
+	})
+}
@@ -0,0 +1,286 @@
+// Package gateway runs the listeners of many scale sets in a single process, so clusters with many
+// scale sets do not pay the memory overhead of a listener pod per scale set.
+//
+// Every scale set runs its own listener app, with its own message session and ephemeral runner set,
+// and the listeners share a pool of goroutines to handle their job messages on. A listener which fails,
+// or panics, is restarted with a backoff without affecting the others. The gateway scales horizontally:
+// each replica runs the scale sets of its shard.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/app"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/recovery"
+	"github.com/go-logr/logr"
+	"k8s.io/utils/clock"
+)
+
+const (
+	DefaultMessageWorkers = 16
+
+	minRestartBackoff = time.Second
+	maxRestartBackoff = 5 * time.Minute
+)
+
+// Config is the configuration of the gateway.
+type Config struct {
+	// ConfigDir is the directory holding the listener config of every scale set, one JSON file per scale set.
+	ConfigDir string `json:"config_dir"`
+	// Shards is the number of gateway replicas the scale sets are spread over. Defaults to 1.
+	Shards int `json:"shards,omitempty"`
+	// Shard is the shard of the replica, between 0 and Shards - 1. The replica runs the scale sets
+	// whose ID modulo Shards is Shard. If it is not set, it is the ordinal suffix of the hostname,
+	// e.g. 2 for the pod "gateway-2" of a StatefulSet.
+	Shard *int `json:"shard,omitempty"`
+	// MessageWorkers is the number of goroutines the listeners handle their job messages on. Defaults to 16.
+	MessageWorkers int `json:"message_workers,omitempty"`
+	// LogLevel and LogFormat configure the logs of the gateway and of the listeners.
+	LogLevel  string `json:"log_level,omitempty"`
+	LogFormat string `json:"log_format,omitempty"`
+}
+
+// Read reads and validates the gateway config.
+func Read(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.ConfigRead, "failed to read gateway config: %w", err)
+	}
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, errcode.Errorf(errcode.ConfigRead, "failed to decode gateway config: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, errcode.Errorf(errcode.ConfigInvalid, "failed to validate gateway config: %w", err)
+	}
+	return &c, nil
+}
+
+func (c *Config) Validate() error {
+	if c.ConfigDir == "" {
+		return errors.New("ConfigDir is required")
+	}
+	if c.Shards < 0 {
+		return fmt.Errorf(`Shards "%d" cannot be negative`, c.Shards)
+	}
+	if c.Shard != nil && (*c.Shard < 0 || *c.Shard >= max(c.Shards, 1)) {
+		return fmt.Errorf(`Shard "%d" must be between 0 and Shards - 1`, *c.Shard)
+	}
+	if c.MessageWorkers < 0 {
+		return fmt.Errorf(`MessageWorkers "%d" cannot be negative`, c.MessageWorkers)
+	}
+	return nil
+}
+
+// shard returns the shard of the replica and the number of shards.
+func (c *Config) shard(hostname string) (int, int, error) {
+	shards := max(c.Shards, 1)
+	if c.Shard != nil {
+		return *c.Shard, shards, nil
+	}
+	if shards == 1 {
+		return 0, 1, nil
+	}
+	i := strings.LastIndex(hostname, "-")
+	shard, err := strconv.Atoi(hostname[i+1:])
+	if i < 0 || err != nil || shard >= shards {
+		return 0, 0, fmt.Errorf("hostname %q has no ordinal suffix below %d, Shard must be set", hostname, shards)
+	}
+	return shard, shards, nil
+}
+
+// Gateway runs the listener apps of the scale sets of its shard.
+type Gateway struct {
+	config Config
+	logger logr.Logger
+	clock  clock.Clock
+	pool   *Pool
+
+	// newApp creates the listener app of a scale set.
+	newApp func(config config.Config, options ...app.Option) (runner, error)
+	// readConfig reads the listener config of a scale set.
+	readConfig func(ctx context.Context, path string) (*config.Config, error)
+}
+
+type runner interface {
+	Run(ctx context.Context) error
+}
+
+func New(c Config, logger logr.Logger) *Gateway {
+	return &Gateway{
+		config: c,
+		logger: logger,
+		clock:  clock.RealClock{},
+		newApp: func(config config.Config, options ...app.Option) (runner, error) {
+			return app.New(config, options...)
+		},
+		readConfig: config.Read,
+	}
+}
+
+// session is the listener config of a scale set of the shard.
+type session struct {
+	path       string
+	name       string
+	scaleSetID int
+}
+
+// Run runs the listeners of the shard until the context is cancelled.
+func (g *Gateway) Run(ctx context.Context) error {
+	hostname, _ := os.Hostname()
+	shard, shards, err := g.config.shard(hostname)
+	if err != nil {
+		return errcode.Wrap(errcode.ConfigInvalid, err)
+	}
+
+	sessions, err := g.sessions(ctx, shard, shards)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return errcode.Errorf(errcode.ConfigInvalid, "no listener config of shard %d/%d in %q", shard, shards, g.config.ConfigDir)
+	}
+
+	workers := g.config.MessageWorkers
+	if workers == 0 {
+		workers = DefaultMessageWorkers
+	}
+	g.pool = NewPool(workers, g.logger.WithName("pool"))
+	defer g.pool.Close()
+
+	g.logger.Info("Starting gateway", "shard", shard, "shards", shards, "scaleSets", len(sessions), "messageWorkers", workers)
+
+	var wg sync.WaitGroup
+	for _, s := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.supervise(ctx, s)
+		}()
+	}
+	wg.Wait()
+
+	g.logger.Info("Gateway stopped")
+	return nil
+}
+
+// sessions returns the listener configs of the scale sets of the shard, sorted by path.
+func (g *Gateway) sessions(ctx context.Context, shard, shards int) ([]session, error) {
+	paths, err := filepath.Glob(filepath.Join(g.config.ConfigDir, "*.json"))
+	if err != nil {
+		return nil, errcode.Errorf(errcode.ConfigRead, "failed to list listener configs: %w", err)
+	}
+	sort.Strings(paths)
+
+	var sessions []session
+	seen := make(map[int]string)
+	for _, path := range paths {
+		c, err := g.readConfig(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read listener config %q: %w", path, err)
+		}
+		if err := validateSessionConfig(c); err != nil {
+			return nil, errcode.Errorf(errcode.ConfigInvalid, "listener config %q cannot run in a gateway: %w", path, err)
+		}
+		if other, ok := seen[c.RunnerScaleSetId]; ok {
+			return nil, errcode.Errorf(errcode.ConfigInvalid, "listener configs %q and %q have the same scale set", other, path)
+		}
+		seen[c.RunnerScaleSetId] = path
+
+		if c.RunnerScaleSetId%shards != shard {
+			continue
+		}
+		sessions = append(sessions, session{
+			path:       path,
+			name:       c.EphemeralRunnerSetNamespace + "/" + c.EphemeralRunnerSetName,
+			scaleSetID: c.RunnerScaleSetId,
+		})
+	}
+	return sessions, nil
+}
+
+// validateSessionConfig rejects the settings of the listener which serve on an address,
+// since the listeners of the gateway share the network namespace.
+func validateSessionConfig(c *config.Config) error {
+	for _, addr := range []struct{ name, value string }{
+		{"MetricsAddr", c.MetricsAddr},
+		{"HealthAddr", c.HealthAddr},
+		{"GopsAddr", c.GopsAddr},
+		{"KedaScalerAddr", c.KedaScalerAddr},
+		{"AdminAddr", c.AdminAddr},
+	} {
+		if addr.value != "" {
+			return fmt.Errorf("%s must not be set", addr.name)
+		}
+	}
+	return nil
+}
+
+// supervise runs the listener of the scale set until the context is cancelled, restarting it with a backoff
+// when it fails. A listener which exits successfully, e.g. after its maximum uptime, is restarted right away.
+func (g *Gateway) supervise(ctx context.Context, s session) {
+	logger := g.logger.WithValues("scaleSet", s.name, "scaleSetID", s.scaleSetID)
+	backoff := minRestartBackoff
+
+	for {
+		start := g.clock.Now()
+		err := g.runSession(ctx, s, logger)
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := time.Duration(0)
+		if err != nil {
+			// A listener which ran for a while failed on its own, not because of a previous failure.
+			if g.clock.Since(start) > maxRestartBackoff {
+				backoff = minRestartBackoff
+			}
+			delay = backoff
+			backoff = min(2*backoff, maxRestartBackoff)
+			if code, ok := errcode.Of(err); ok {
+				logger.Error(err, "Listener failed, restarting", "backoff", delay, "errorCode", code, "errorCategory", errcode.Classify(err))
+			} else {
+				logger.Error(err, "Listener failed, restarting", "backoff", delay, "errorCategory", errcode.Classify(err))
+			}
+		} else {
+			logger.Info("Listener exited, restarting")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-g.clock.After(delay):
+		}
+	}
+}
+
+// runSession reads the config of the scale set and runs its listener, turning a panic into an error.
+// The config is read again on every restart, so the credentials of the vault are fresh.
+func (g *Gateway) runSession(ctx context.Context, s session, logger logr.Logger) (err error) {
+	defer recovery.Recover(&err)
+
+	c, err := g.readConfig(ctx, s.path)
+	if err != nil {
+		return err
+	}
+	a, err := g.newApp(*c,
+		app.WithLogger(logger),
+		app.WithMessageExecutor(g.pool.Executor(s.name)),
+	)
+	if err != nil {
+		return err
+	}
+	return a.Run(ctx)
+}
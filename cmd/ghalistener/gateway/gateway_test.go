@@ -0,0 +1,174 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/app"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestConfigShard(t *testing.T) {
+	t.Parallel()
+
+	one := 1
+	tests := map[string]struct {
+		config   Config
+		hostname string
+		shard    int
+		shards   int
+		err      string
+	}{
+		"SingleShard":      {config: Config{}, hostname: "gateway", shard: 0, shards: 1},
+		"ExplicitShard":    {config: Config{Shards: 3, Shard: &one}, hostname: "gateway-2", shard: 1, shards: 3},
+		"HostnameOrdinal":  {config: Config{Shards: 3}, hostname: "arc-gateway-2", shard: 2, shards: 3},
+		"NoOrdinal":        {config: Config{Shards: 3}, hostname: "gateway", err: `hostname "gateway" has no ordinal suffix below 3`},
+		"OrdinalTooLarge":  {config: Config{Shards: 3}, hostname: "gateway-3", err: `hostname "gateway-3" has no ordinal suffix below 3`},
+		"NonNumericSuffix": {config: Config{Shards: 2}, hostname: "gateway-abc12", err: "has no ordinal suffix"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			shard, shards, err := tt.config.shard(tt.hostname)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.shard, shard)
+			assert.Equal(t, tt.shards, shards)
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	assert.ErrorContains(t, (&Config{}).Validate(), "ConfigDir is required")
+	two := 2
+	assert.ErrorContains(t, (&Config{ConfigDir: "/configs", Shards: 2, Shard: &two}).Validate(), `Shard "2" must be between 0 and Shards - 1`)
+	assert.NoError(t, (&Config{ConfigDir: "/configs", Shards: 3, Shard: &two}).Validate())
+}
+
+type fakeRunner func(ctx context.Context) error
+
+func (f fakeRunner) Run(ctx context.Context) error { return f(ctx) }
+
+func newTestGateway(t *testing.T, configs map[string]*config.Config) *Gateway {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name := range configs {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600))
+	}
+	zero := 0
+	g := New(Config{ConfigDir: dir, Shards: 2, Shard: &zero, MessageWorkers: 1}, logr.Discard())
+	g.readConfig = func(_ context.Context, path string) (*config.Config, error) {
+		return configs[filepath.Base(path)], nil
+	}
+	return g
+}
+
+func TestGatewaySessions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RunsScaleSetsOfShard", func(t *testing.T) {
+		t.Parallel()
+
+		g := newTestGateway(t, map[string]*config.Config{
+			"a.json": {RunnerScaleSetId: 2, EphemeralRunnerSetNamespace: "ns", EphemeralRunnerSetName: "a"},
+			"b.json": {RunnerScaleSetId: 3, EphemeralRunnerSetNamespace: "ns", EphemeralRunnerSetName: "b"},
+			"c.json": {RunnerScaleSetId: 4, EphemeralRunnerSetNamespace: "ns", EphemeralRunnerSetName: "c"},
+		})
+		sessions, err := g.sessions(context.Background(), 0, 2)
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.Equal(t, "ns/a", sessions[0].name)
+		assert.Equal(t, "ns/c", sessions[1].name)
+	})
+
+	t.Run("RejectsServerAddresses", func(t *testing.T) {
+		t.Parallel()
+
+		g := newTestGateway(t, map[string]*config.Config{
+			"a.json": {RunnerScaleSetId: 2, MetricsAddr: ":8080"},
+		})
+		_, err := g.sessions(context.Background(), 0, 2)
+		assert.ErrorContains(t, err, "cannot run in a gateway: MetricsAddr must not be set")
+	})
+
+	t.Run("RejectsDuplicateScaleSets", func(t *testing.T) {
+		t.Parallel()
+
+		g := newTestGateway(t, map[string]*config.Config{
+			"a.json": {RunnerScaleSetId: 2},
+			"b.json": {RunnerScaleSetId: 2},
+		})
+		_, err := g.sessions(context.Background(), 0, 2)
+		assert.ErrorContains(t, err, "have the same scale set")
+	})
+}
+
+func TestGatewayRun(t *testing.T) {
+	t.Parallel()
+
+	g := newTestGateway(t, map[string]*config.Config{
+		"failing.json":   {RunnerScaleSetId: 2, EphemeralRunnerSetName: "failing"},
+		"healthy.json":   {RunnerScaleSetId: 4, EphemeralRunnerSetName: "healthy"},
+		"panicking.json": {RunnerScaleSetId: 6, EphemeralRunnerSetName: "panicking"},
+	})
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	g.clock = fakeClock
+
+	var mu sync.Mutex
+	runs := make(map[string]int)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g.newApp = func(c config.Config, options ...app.Option) (runner, error) {
+		assert.Len(t, options, 2)
+		return fakeRunner(func(ctx context.Context) error {
+			mu.Lock()
+			runs[c.EphemeralRunnerSetName]++
+			mu.Unlock()
+			switch c.EphemeralRunnerSetName {
+			case "failing":
+				return errors.New("session conflict")
+			case "panicking":
+				panic("boom")
+			}
+			<-ctx.Done()
+			return nil
+		}), nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- g.Run(ctx)
+	}()
+
+	// both failing listeners wait for their backoff, while the healthy one keeps running
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return runs["failing"] == 1 && runs["panicking"] == 1 && runs["healthy"] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		fakeClock.Step(minRestartBackoff)
+		mu.Lock()
+		defer mu.Unlock()
+		return runs["failing"] >= 2 && runs["panicking"] >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-errCh)
+	assert.Equal(t, 1, runs["healthy"])
+}
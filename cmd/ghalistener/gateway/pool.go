@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/go-logr/logr"
+)
+
+// Pool is a fixed set of goroutines shared by the listeners of the gateway.
+//
+// Every scale set queues its functions on its own, and a goroutine done with a function takes the next one
+// of the following scale set with queued functions. A scale set with a burst of jobs is therefore
+// spread over the idle goroutines without delaying the functions of the other scale sets behind its own.
+type Pool struct {
+	logger logr.Logger
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// queues are the functions queued per scale set.
+	queues map[string][]func()
+	// ready are the scale sets with queued functions, in the order they are served.
+	ready  []string
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewPool(workers int, logger logr.Logger) *Pool {
+	p := &Pool{
+		logger: logger,
+		queues: make(map[string][]func()),
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	p.wg.Add(workers)
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+// Executor returns the executor queuing the functions of the scale set on the pool.
+func (p *Pool) Executor(scaleSet string) listener.Executor {
+	return &poolExecutor{pool: p, scaleSet: scaleSet}
+}
+
+// Close stops the goroutines once the queued functions ran.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.cond.Broadcast()
+	p.wg.Wait()
+}
+
+func (p *Pool) submit(scaleSet string, fn func()) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		// The listeners still draining when the gateway stops are not left waiting.
+		go p.run(scaleSet, fn)
+		return
+	}
+	if len(p.queues[scaleSet]) == 0 {
+		p.ready = append(p.ready, scaleSet)
+	}
+	p.queues[scaleSet] = append(p.queues[scaleSet], fn)
+	p.mu.Unlock()
+	p.cond.Signal()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		for len(p.ready) == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if len(p.ready) == 0 {
+			p.mu.Unlock()
+			return
+		}
+
+		scaleSet := p.ready[0]
+		p.ready = p.ready[1:]
+		queue := p.queues[scaleSet]
+		fn := queue[0]
+		if len(queue) == 1 {
+			delete(p.queues, scaleSet)
+		} else {
+			p.queues[scaleSet] = queue[1:]
+			p.ready = append(p.ready, scaleSet)
+		}
+		p.mu.Unlock()
+
+		p.run(scaleSet, fn)
+	}
+}
+
+// run runs the function, recovering from its panic so the other scale sets keep their goroutine.
+func (p *Pool) run(scaleSet string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.logger.Error(fmt.Errorf("panic: %v", r), "Recovered from a panic of a message handler", "scaleSet", scaleSet, "stack", string(debug.Stack()))
+		}
+	}()
+	fn()
+}
+
+type poolExecutor struct {
+	pool     *Pool
+	scaleSet string
+}
+
+func (e *poolExecutor) Go(fn func()) {
+	e.pool.submit(e.scaleSet, fn)
+}
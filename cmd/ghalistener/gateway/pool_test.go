@@ -0,0 +1,55 @@
+package gateway
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ServesScaleSetsInTurn", func(t *testing.T) {
+		t.Parallel()
+
+		pool := NewPool(0, logr.Discard())
+		var order []string
+		for _, scaleSet := range []string{"a", "a", "a", "b", "c"} {
+			pool.Executor(scaleSet).Go(func() { order = append(order, scaleSet) })
+		}
+
+		// a single goroutine started once everything is queued
+		pool.wg.Add(1)
+		go pool.work()
+		pool.Close()
+		assert.Equal(t, []string{"a", "b", "c", "a", "a"}, order)
+	})
+
+	t.Run("RecoversFromPanics", func(t *testing.T) {
+		t.Parallel()
+
+		pool := NewPool(2, logr.Discard())
+		var wg sync.WaitGroup
+		wg.Add(2)
+		pool.Executor("a").Go(func() {
+			defer wg.Done()
+			panic("boom")
+		})
+		pool.Executor("b").Go(wg.Done)
+		wg.Wait()
+		pool.Close()
+	})
+
+	t.Run("RunsFunctionsSubmittedAfterClose", func(t *testing.T) {
+		t.Parallel()
+
+		pool := NewPool(1, logr.Discard())
+		pool.Close()
+
+		done := make(chan struct{})
+		pool.Executor("a").Go(func() { close(done) })
+		<-done
+	})
+}
@@ -0,0 +1,215 @@
+// Package gops serves the diagnostics of the gops agent protocol, so the goroutines, GC stats,
+// and heap of a running listener can be inspected with the standard gops tool during incidents.
+// The tool is not in the listener image: it runs on the workstation of the operator, through a port-forward:
+//
+//	kubectl port-forward <listener pod> <port> & gops memstats 127.0.0.1:<port>
+//
+// The agent is addressed by host and port: it writes no discovery file, so it works with a read-only
+// root filesystem. It only listens on loopback addresses, since its commands are not authenticated.
+package gops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/go-logr/logr"
+)
+
+// The commands of the gops protocol, the single byte the client sends after connecting.
+const (
+	cmdStackTrace  = byte(0x1)
+	cmdGC          = byte(0x2)
+	cmdMemStats    = byte(0x3)
+	cmdVersion     = byte(0x4)
+	cmdHeapProfile = byte(0x5)
+	cmdCPUProfile  = byte(0x6)
+	cmdStats       = byte(0x7)
+	cmdTrace       = byte(0x8)
+)
+
+const (
+	cpuProfileDuration = 30 * time.Second
+	traceDuration      = 5 * time.Second
+	// commandTimeout bounds the time a client has to send its command.
+	commandTimeout = 10 * time.Second
+	// The backoff between the failed accepts, e.g. when the process runs out of file descriptors,
+	// doubling from minAcceptBackoff up to maxAcceptBackoff.
+	minAcceptBackoff = 5 * time.Millisecond
+	maxAcceptBackoff = time.Second
+)
+
+// ValidateAddr checks that the address is a loopback host and a port.
+func ValidateAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("host %q is not a loopback address", host)
+	}
+	return nil
+}
+
+// Agent serves the gops commands.
+type Agent struct {
+	addr   string
+	logger logr.Logger
+	// profiling serializes the CPU profiles and traces, which the runtime runs one at a time.
+	profiling sync.Mutex
+}
+
+func NewAgent(addr string, logger logr.Logger) *Agent {
+	return &Agent{
+		addr:   addr,
+		logger: logger,
+	}
+}
+
+// ListenAndServe serves the gops commands until the context is cancelled.
+func (a *Agent) ListenAndServe(ctx context.Context) error {
+	if err := ValidateAddr(a.addr); err != nil {
+		return errcode.Errorf(errcode.GopsAgent, "refusing to serve gops on %q: %w", a.addr, err)
+	}
+	ln, err := net.Listen("tcp", a.addr)
+	if err != nil {
+		return errcode.Wrap(errcode.GopsAgent, err)
+	}
+	return a.serve(ctx, ln)
+}
+
+func (a *Agent) serve(ctx context.Context, ln net.Listener) error {
+	a.logger.Info("starting gops agent", "addr", ln.Addr().String())
+	go func() {
+		<-ctx.Done()
+		a.logger.Info("stopping gops agent", "err", ctx.Err())
+		ln.Close()
+	}()
+
+	var backoff time.Duration
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return errcode.Wrap(errcode.GopsAgent, err)
+			}
+			backoff = min(max(2*backoff, minAcceptBackoff), maxAcceptBackoff)
+			a.logger.Error(err, "failed to accept gops connection", "retryIn", backoff.String())
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		go a.handle(conn)
+	}
+}
+
+func (a *Agent) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var cmd [1]byte
+	conn.SetReadDeadline(time.Now().Add(commandTimeout))
+	if _, err := io.ReadFull(conn, cmd[:]); err != nil {
+		a.logger.Error(err, "failed to read gops command")
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	a.logger.Info("serving gops command", "command", cmd[0])
+	if err := a.run(cmd[0], conn); err != nil {
+		a.logger.Error(err, "failed to serve gops command", "command", cmd[0])
+	}
+}
+
+func (a *Agent) run(cmd byte, w io.Writer) error {
+	switch cmd {
+	case cmdStackTrace:
+		return pprof.Lookup("goroutine").WriteTo(w, 2)
+	case cmdGC:
+		runtime.GC()
+		_, err := io.WriteString(w, "ok")
+		return err
+	case cmdMemStats:
+		return writeMemStats(w)
+	case cmdVersion:
+		_, err := fmt.Fprintf(w, "%v\n", runtime.Version())
+		return err
+	case cmdHeapProfile:
+		return pprof.WriteHeapProfile(w)
+	case cmdCPUProfile:
+		a.profiling.Lock()
+		defer a.profiling.Unlock()
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return err
+		}
+		time.Sleep(cpuProfileDuration)
+		pprof.StopCPUProfile()
+		return nil
+	case cmdStats:
+		_, err := fmt.Fprintf(w, "goroutines: %v\nOS threads: %v\nGOMAXPROCS: %v\nnum CPU: %v\n",
+			runtime.NumGoroutine(), pprof.Lookup("threadcreate").Count(), runtime.GOMAXPROCS(0), runtime.NumCPU())
+		return err
+	case cmdTrace:
+		a.profiling.Lock()
+		defer a.profiling.Unlock()
+		if err := trace.Start(w); err != nil {
+			return err
+		}
+		time.Sleep(traceDuration)
+		trace.Stop()
+		return nil
+	default:
+		_, err := fmt.Fprintf(w, "unsupported command %#x\n", cmd)
+		return err
+	}
+}
+
+func writeMemStats(w io.Writer) error {
+	var s runtime.MemStats
+	runtime.ReadMemStats(&s)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	_, err := fmt.Fprintf(w, "alloc: %v bytes\n"+
+		"total-alloc: %v bytes\n"+
+		"sys: %v bytes\n"+
+		"lookups: %v\n"+
+		"mallocs: %v\n"+
+		"frees: %v\n"+
+		"heap-alloc: %v bytes\n"+
+		"heap-sys: %v bytes\n"+
+		"heap-idle: %v bytes\n"+
+		"heap-in-use: %v bytes\n"+
+		"heap-released: %v bytes\n"+
+		"heap-objects: %v\n"+
+		"stack-in-use: %v bytes\n"+
+		"stack-sys: %v bytes\n"+
+		"next-gc: when heap-alloc >= %v bytes\n"+
+		"last-gc: %v\n"+
+		"gc-pause-total: %v\n"+
+		"num-gc: %v\n"+
+		"gc-cpu-fraction: %v\n",
+		s.Alloc, s.TotalAlloc, s.Sys, s.Lookups, s.Mallocs, s.Frees,
+		s.HeapAlloc, s.HeapSys, s.HeapIdle, s.HeapInuse, s.HeapReleased, s.HeapObjects,
+		s.StackInuse, s.StackSys, s.NextGC, gc.LastGC, gc.PauseTotal, gc.NumGC, s.GCCPUFraction,
+	)
+	return err
+}
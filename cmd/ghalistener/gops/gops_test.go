@@ -0,0 +1,96 @@
+package gops
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAddr(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:6060", "localhost:6060", "[::1]:0"} {
+		assert.NoError(t, ValidateAddr(addr), addr)
+	}
+	for _, addr := range []string{":6060", "0.0.0.0:6060", "10.0.0.1:6060", "example.com:6060", "127.0.0.1"} {
+		assert.Error(t, ValidateAddr(addr), addr)
+	}
+}
+
+func TestAgent(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- NewAgent(ln.Addr().String(), logr.Discard()).serve(ctx, ln)
+	}()
+
+	send := func(t *testing.T, cmd byte) string {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte{cmd})
+		require.NoError(t, err)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		out, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	assert.Contains(t, send(t, cmdStackTrace), "goroutine")
+	assert.Equal(t, "ok", send(t, cmdGC))
+	assert.Contains(t, send(t, cmdMemStats), "heap-alloc:")
+	assert.Contains(t, send(t, cmdVersion), "go")
+	assert.Contains(t, send(t, cmdStats), "goroutines:")
+	assert.NotEmpty(t, send(t, cmdHeapProfile))
+	assert.Equal(t, "unsupported command 0x9\n", send(t, 0x9))
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not stop on context cancel")
+	}
+}
+
+func TestAgentRefusesNonLoopbackAddr(t *testing.T) {
+	err := NewAgent("0.0.0.0:0", logr.Discard()).ListenAndServe(context.Background())
+	assert.ErrorContains(t, err, `refusing to serve gops on "0.0.0.0:0"`)
+}
+
+// failingListener fails every accept, as a listener of a process out of file descriptors does.
+type failingListener struct {
+	net.Listener
+	accepts atomic.Int32
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.accepts.Add(1)
+	return nil, errors.New("too many open files")
+}
+
+func TestAgentBacksOffOnAcceptErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	failing := &failingListener{Listener: ln}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- NewAgent(ln.Addr().String(), logr.Discard()).serve(ctx, failing)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	assert.Less(t, failing.accepts.Load(), int32(10), "the failed accepts are retried with a backoff")
+}
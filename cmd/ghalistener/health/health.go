@@ -0,0 +1,381 @@
+// Package health tracks the liveness and readiness of the listener and serves them over HTTP,
+// so that a wedged listener is restarted by Kubernetes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/go-logr/logr"
+	"k8s.io/utils/clock"
+)
+
+const (
+	LivenessPath  = "/livez"
+	ReadinessPath = "/readyz"
+	PreStopPath   = "/prestop"
+	// HealthzPath is served as an alias of LivenessPath.
+	HealthzPath = "/healthz"
+
+	// DefaultStaleAfter is the default time after which the listener is considered wedged
+	// if it did not successfully poll for a message. A poll takes up to about a minute.
+	DefaultStaleAfter = 5 * time.Minute
+
+	// DefaultReadinessFailureThreshold is the default number of consecutive failed Kubernetes or vault requests
+	// after which the listener is reported not ready.
+	DefaultReadinessFailureThreshold = 3
+	// DefaultReadinessSuccessThreshold is the default number of consecutive successful Kubernetes or vault requests
+	// after which a listener reported not ready is reported ready again.
+	DefaultReadinessSuccessThreshold = 1
+)
+
+// Status records the progress of the listener. A nil Status discards all records.
+type Status struct {
+	clock   clock.PassiveClock
+	started time.Time
+
+	readinessFailureThreshold int
+	readinessSuccessThreshold int
+
+	mu                 sync.RWMutex
+	standby            bool
+	sessionEstablished bool
+	lastPoll           time.Time
+	lastPatch          time.Time
+
+	// kubernetesReachable only changes once the failure or success threshold is reached,
+	// so that a single failed request does not flip the readiness of the listener.
+	kubernetesReachable bool
+	kubernetesFailures  int
+	kubernetesSuccesses int
+
+	// vaultRequested is set once a request is made to the vault, the listeners not reading their
+	// credentials from a vault not reporting its reachability.
+	vaultRequested bool
+	vaultReachable bool
+	vaultFailures  int
+	vaultSuccesses int
+}
+
+// StatusOption configures the Status.
+type StatusOption func(*Status)
+
+// WithReadinessThresholds sets the number of consecutive failed Kubernetes, or vault, requests after which the listener
+// is reported not ready, and of consecutive successful ones after which it is reported ready again.
+// Values lower than 1 keep the defaults.
+func WithReadinessThresholds(failure, success int) StatusOption {
+	return func(s *Status) {
+		if failure > 0 {
+			s.readinessFailureThreshold = failure
+		}
+		if success > 0 {
+			s.readinessSuccessThreshold = success
+		}
+	}
+}
+
+// NewStatus creates a status using the clock.
+func NewStatus(clock clock.PassiveClock, options ...StatusOption) *Status {
+	s := &Status{
+		clock:                     clock,
+		started:                   clock.Now(),
+		readinessFailureThreshold: DefaultReadinessFailureThreshold,
+		readinessSuccessThreshold: DefaultReadinessSuccessThreshold,
+		kubernetesReachable:       true,
+		vaultReachable:            true,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// SetStandby records whether the listener is standing by for the leader election.
+// A standby listener does not poll for messages, it is live but not ready.
+// Once it stops standing by, it has the stale period to start polling again.
+func (s *Status) SetStandby(standby bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.standby && !standby {
+		s.started = s.clock.Now()
+	}
+	s.standby = standby
+}
+
+// SetSessionEstablished records whether the message session is established.
+func (s *Status) SetSessionEstablished(established bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionEstablished = established
+}
+
+// RecordPoll records a successful poll for a message.
+func (s *Status) RecordPoll() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastPoll = s.clock.Now()
+}
+
+// RecordPatch records a successful patch of the EphemeralRunnerSet.
+func (s *Status) RecordPatch() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastPatch = s.clock.Now()
+}
+
+// RecordKubernetesRequest records whether a request to the Kubernetes API server succeeded.
+func (s *Status) RecordKubernetesRequest(reachable bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordRequest(reachable, &s.kubernetesReachable, &s.kubernetesFailures, &s.kubernetesSuccesses)
+}
+
+// RecordVaultRequest records whether a request to the vault the credentials are read from succeeded.
+// A throttled request is a failed one: the credentials cannot be rotated until the vault serves them again.
+func (s *Status) RecordVaultRequest(reachable bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vaultRequested = true
+	s.recordRequest(reachable, &s.vaultReachable, &s.vaultFailures, &s.vaultSuccesses)
+}
+
+// recordRequest flips the reachability once the failure or success threshold is reached, s.mu being held.
+func (s *Status) recordRequest(reachable bool, state *bool, failures, successes *int) {
+	if reachable {
+		*failures = 0
+		*successes++
+		if *successes >= s.readinessSuccessThreshold {
+			*state = true
+		}
+		return
+	}
+
+	*successes = 0
+	*failures++
+	if *failures >= s.readinessFailureThreshold {
+		*state = false
+	}
+}
+
+// Report is the health report served by the endpoints.
+type Report struct {
+	Standby             bool       `json:"standby"`
+	SessionEstablished  bool       `json:"session_established"`
+	KubernetesReachable bool       `json:"kubernetes_reachable"`
+	VaultReachable      *bool      `json:"vault_reachable,omitempty"`
+	LastPollTime        *time.Time `json:"last_poll_time,omitempty"`
+	LastPatchTime       *time.Time `json:"last_patch_time,omitempty"`
+	Live                bool       `json:"live"`
+	Ready               bool       `json:"ready"`
+}
+
+// Report returns the current health report. The listener is live as long as it polled
+// for a message within staleAfter, started less than staleAfter ago, or stands by.
+// It is ready while the message session is established and the Kubernetes API server, and the vault if one
+// was requested, are reachable.
+func (s *Status) Report(staleAfter time.Duration) Report {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report := Report{
+		Standby:             s.standby,
+		SessionEstablished:  s.sessionEstablished,
+		KubernetesReachable: s.kubernetesReachable,
+		Ready:               s.sessionEstablished && s.kubernetesReachable && s.vaultReachable,
+	}
+	if s.vaultRequested {
+		vaultReachable := s.vaultReachable
+		report.VaultReachable = &vaultReachable
+	}
+	if lastPoll := s.lastPoll; !lastPoll.IsZero() {
+		report.LastPollTime = &lastPoll
+	}
+	if lastPatch := s.lastPatch; !lastPatch.IsZero() {
+		report.LastPatchTime = &lastPatch
+	}
+
+	lastProgress := s.started
+	if s.lastPoll.After(lastProgress) {
+		lastProgress = s.lastPoll
+	}
+	report.Live = s.standby || s.clock.Since(lastProgress) < staleAfter
+
+	return report
+}
+
+type ServerConfig struct {
+	Addr string
+	// Status is the status to report. It is required.
+	Status *Status
+	// StaleAfter is the time without a successful poll after which the listener is reported not live.
+	// Defaults to DefaultStaleAfter.
+	StaleAfter time.Duration
+	// PreStop is called by the pre-stop endpoint to stop the listener. It blocks until the listener
+	// can be terminated safely. The pre-stop endpoint is not served when it is nil.
+	PreStop func(ctx context.Context) error
+	Logger  logr.Logger
+}
+
+// Server serves the health of the listener.
+type Server struct {
+	srv        *http.Server
+	status     *Status
+	staleAfter time.Duration
+	preStop    func(ctx context.Context) error
+	logger     logr.Logger
+}
+
+func NewServer(config ServerConfig) *Server {
+	s := &Server{
+		status:     config.Status,
+		staleAfter: config.StaleAfter,
+		preStop:    config.PreStop,
+		logger:     config.Logger,
+	}
+	if s.staleAfter <= 0 {
+		s.staleAfter = DefaultStaleAfter
+	}
+
+	mux := http.NewServeMux()
+	live := s.handle(func(r Report) bool { return r.Live })
+	mux.HandleFunc(LivenessPath, live)
+	mux.HandleFunc(HealthzPath, live)
+	mux.HandleFunc(ReadinessPath, s.handle(func(r Report) bool { return r.Ready }))
+	if s.preStop != nil {
+		mux.HandleFunc(PreStopPath, s.handlePreStop)
+	}
+	s.srv = &http.Server{
+		Addr:              config.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return s
+}
+
+func (s *Server) handle(healthy func(Report) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := s.status.Report(s.staleAfter)
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy(report) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			s.logger.Error(err, "failed to write health report")
+		}
+	}
+}
+
+// handlePreStop stops the listener, responding once it can be terminated safely.
+// The request is bounded by the termination grace period of the pod.
+// Only POST requests from the loopback interface are accepted, so that the listener can only be stopped
+// from within its pod, e.g. by the exec preStop hook running RequestPreStop.
+func (s *Server) handlePreStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoopback(r.RemoteAddr) {
+		s.logger.Info("rejected pre-stop request from outside the pod", "remoteAddr", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	s.logger.Info("pre-stop hook called")
+	if err := s.preStop(r.Context()); err != nil {
+		s.logger.Error(err, "pre-stop hook did not complete")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.logger.Info("pre-stop hook completed")
+	w.WriteHeader(http.StatusOK)
+}
+
+// isLoopback reports whether the remote address of a request is on the loopback interface.
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// RequestPreStop calls the pre-stop endpoint of the health server listening on addr, and blocks until
+// the listener can be terminated safely. It is meant to be run by the exec preStop hook of the listener pod,
+// since the endpoint only accepts POST requests from the loopback interface.
+func RequestPreStop(ctx context.Context, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid health address %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+
+	u := url.URL{Scheme: "http", Host: net.JoinHostPort(host, port), Path: PreStopPath}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create pre-stop request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call pre-stop endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("pre-stop endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// ListenAndServe serves the health endpoints until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	s.logger.Info("starting health server", "addr", s.srv.Addr)
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		s.logger.Info("stopping health server", "err", ctx.Err())
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.srv.Shutdown(ctx)
+	}()
+
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errcode.Wrap(errcode.HealthServer, err)
+	}
+	// Wait for the in-flight requests, so the response of the pre-stop hook is sent before the listener exits.
+	<-shutdown
+	return nil
+}
@@ -0,0 +1,256 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestStatus_NilIsNoop(t *testing.T) {
+	var s *Status
+	s.SetStandby(true)
+	s.SetSessionEstablished(true)
+	s.RecordPoll()
+	s.RecordPatch()
+	s.RecordKubernetesRequest(false)
+	s.RecordVaultRequest(false)
+}
+
+func TestStatus_Report(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	s := NewStatus(fakeClock)
+
+	report := s.Report(time.Minute)
+	assert.False(t, report.SessionEstablished)
+	assert.Nil(t, report.LastPollTime)
+	assert.True(t, report.Live, "listener is live during startup")
+
+	fakeClock.Step(2 * time.Minute)
+	assert.False(t, s.Report(time.Minute).Live, "listener that never polled is not live after the threshold")
+
+	s.SetSessionEstablished(true)
+	s.RecordPoll()
+	s.RecordPatch()
+	report = s.Report(time.Minute)
+	assert.True(t, report.SessionEstablished)
+	assert.True(t, report.Live)
+	require.NotNil(t, report.LastPollTime)
+	assert.Equal(t, fakeClock.Now(), *report.LastPollTime)
+	require.NotNil(t, report.LastPatchTime)
+
+	fakeClock.Step(time.Minute)
+	assert.False(t, s.Report(time.Minute).Live, "listener that stopped polling is not live")
+}
+
+func TestStatus_Standby(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	s := NewStatus(fakeClock)
+	s.SetStandby(true)
+
+	fakeClock.Step(2 * time.Minute)
+	report := s.Report(time.Minute)
+	assert.True(t, report.Standby)
+	assert.True(t, report.Live, "standby listener is live without polling")
+	assert.False(t, report.Ready, "standby listener is not ready")
+
+	s.SetStandby(false)
+	assert.True(t, s.Report(time.Minute).Live, "leading listener has the stale period to start polling")
+	fakeClock.Step(2 * time.Minute)
+	assert.False(t, s.Report(time.Minute).Live)
+}
+
+func TestStatus_KubernetesReachable(t *testing.T) {
+	s := NewStatus(clocktesting.NewFakeClock(time.Now()), WithReadinessThresholds(2, 2))
+	s.SetSessionEstablished(true)
+	assert.True(t, s.Report(time.Minute).Ready)
+
+	s.RecordKubernetesRequest(false)
+	assert.True(t, s.Report(time.Minute).Ready, "a single failure stays below the failure threshold")
+	s.RecordKubernetesRequest(true)
+	s.RecordKubernetesRequest(false)
+	assert.True(t, s.Report(time.Minute).Ready, "failures must be consecutive")
+
+	s.RecordKubernetesRequest(false)
+	report := s.Report(time.Minute)
+	assert.False(t, report.KubernetesReachable)
+	assert.False(t, report.Ready)
+	assert.True(t, report.Live, "an unreachable API server does not affect liveness")
+
+	s.RecordKubernetesRequest(true)
+	assert.False(t, s.Report(time.Minute).Ready, "a single success stays below the success threshold")
+	s.RecordKubernetesRequest(true)
+	assert.True(t, s.Report(time.Minute).Ready)
+}
+
+func TestStatus_VaultReachable(t *testing.T) {
+	s := NewStatus(clocktesting.NewFakeClock(time.Now()), WithReadinessThresholds(2, 1))
+	s.SetSessionEstablished(true)
+	assert.Nil(t, s.Report(time.Minute).VaultReachable, "the vault is not reported before it is requested")
+
+	s.RecordVaultRequest(true)
+	report := s.Report(time.Minute)
+	require.NotNil(t, report.VaultReachable)
+	assert.True(t, *report.VaultReachable)
+
+	s.RecordVaultRequest(false)
+	assert.True(t, s.Report(time.Minute).Ready, "a single failure stays below the failure threshold")
+	s.RecordVaultRequest(false)
+	report = s.Report(time.Minute)
+	assert.False(t, *report.VaultReachable)
+	assert.False(t, report.Ready)
+	assert.True(t, report.KubernetesReachable, "the vault is tracked apart from the API server")
+
+	s.RecordVaultRequest(true)
+	assert.True(t, s.Report(time.Minute).Ready)
+}
+
+func TestServer_Endpoints(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	status := NewStatus(fakeClock)
+	server := NewServer(ServerConfig{
+		Status:     status,
+		StaleAfter: time.Minute,
+		Logger:     logr.Discard(),
+	})
+
+	get := func(path string) (int, Report) {
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var report Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec.Code, report
+	}
+
+	code, _ := get(ReadinessPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "not ready without a session")
+	code, _ = get(LivenessPath)
+	assert.Equal(t, http.StatusOK, code)
+
+	status.SetSessionEstablished(true)
+	status.RecordPoll()
+	code, report := get(ReadinessPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, report.SessionEstablished)
+
+	for range DefaultReadinessFailureThreshold {
+		status.RecordKubernetesRequest(false)
+	}
+	code, report = get(ReadinessPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "not ready without the API server")
+	assert.False(t, report.KubernetesReachable)
+	code, _ = get(LivenessPath)
+	assert.Equal(t, http.StatusOK, code)
+
+	fakeClock.Step(2 * time.Minute)
+	code, report = get(LivenessPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "wedged listener must fail liveness")
+	assert.False(t, report.Live)
+	code, _ = get(HealthzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
+
+func TestServer_PreStop(t *testing.T) {
+	status := NewStatus(clocktesting.NewFakeClock(time.Now()))
+
+	preStopRequest := func(method, remoteAddr string) *http.Request {
+		req := httptest.NewRequest(method, PreStopPath, nil)
+		req.RemoteAddr = remoteAddr
+		return req
+	}
+
+	t.Run("not served without pre-stop", func(t *testing.T) {
+		server := NewServer(ServerConfig{Status: status, Logger: logr.Discard()})
+
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, preStopRequest(http.MethodPost, "127.0.0.1:1234"))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("responds once stopped", func(t *testing.T) {
+		called := 0
+		server := NewServer(ServerConfig{
+			Status: status,
+			PreStop: func(ctx context.Context) error {
+				called++
+				return nil
+			},
+			Logger: logr.Discard(),
+		})
+
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, preStopRequest(http.MethodPost, "127.0.0.1:1234"))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, called)
+
+		rec = httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, preStopRequest(http.MethodPost, "[::1]:1234"))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 2, called)
+	})
+
+	t.Run("rejects the requests that cannot come from the hook", func(t *testing.T) {
+		called := 0
+		server := NewServer(ServerConfig{
+			Status: status,
+			PreStop: func(ctx context.Context) error {
+				called++
+				return nil
+			},
+			Logger: logr.Discard(),
+		})
+
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, preStopRequest(http.MethodGet, "127.0.0.1:1234"))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, http.MethodPost, rec.Header().Get("Allow"))
+
+		rec = httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, preStopRequest(http.MethodPost, "10.0.0.1:1234"))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		assert.Equal(t, 0, called, "the listener is not stopped")
+	})
+
+	t.Run("fails when not stopped in time", func(t *testing.T) {
+		server := NewServer(ServerConfig{
+			Status: status,
+			PreStop: func(ctx context.Context) error {
+				return context.DeadlineExceeded
+			},
+			Logger: logr.Discard(),
+		})
+
+		rec := httptest.NewRecorder()
+		server.srv.Handler.ServeHTTP(rec, preStopRequest(http.MethodPost, "127.0.0.1:1234"))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+}
+
+func TestRequestPreStop(t *testing.T) {
+	status := NewStatus(clocktesting.NewFakeClock(time.Now()))
+	var stopErr error
+	server := NewServer(ServerConfig{
+		Status:  status,
+		PreStop: func(ctx context.Context) error { return stopErr },
+		Logger:  logr.Discard(),
+	})
+	ts := httptest.NewServer(server.srv.Handler)
+	t.Cleanup(ts.Close)
+
+	addr := strings.TrimPrefix(ts.URL, "http://")
+	require.NoError(t, RequestPreStop(context.Background(), addr))
+
+	stopErr = context.DeadlineExceeded
+	assert.ErrorContains(t, RequestPreStop(context.Background(), addr), "503")
+
+	assert.Error(t, RequestPreStop(context.Background(), "invalid"))
+}
@@ -0,0 +1,312 @@
+package kedascaler
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of the externalscaler.proto of KEDA, encoded by hand like the remote write requests
+// of the metrics package, since the listener has no generated protobuf code.
+
+// message is a message of the protocol.
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+// codec encodes the messages of the protocol for the gRPC server, in place of the proto codec.
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+// scaledObjectRef identifies the ScaledObject KEDA queries the scaler for.
+type scaledObjectRef struct {
+	name           string
+	namespace      string
+	scalerMetadata map[string]string
+}
+
+func (m *scaledObjectRef) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.name)
+	b = appendString(b, 2, m.namespace)
+	for k, v := range m.scalerMetadata {
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendString(entry, 2, v)
+		b = appendMessage(b, 3, entry)
+	}
+	return b
+}
+
+func (m *scaledObjectRef) unmarshal(b []byte) error {
+	return consumeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.name = string(f.bytes)
+		case 2:
+			m.namespace = string(f.bytes)
+		case 3:
+			var k, v string
+			if err := consumeFields(f.bytes, func(f field) error {
+				switch f.num {
+				case 1:
+					k = string(f.bytes)
+				case 2:
+					v = string(f.bytes)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if m.scalerMetadata == nil {
+				m.scalerMetadata = make(map[string]string)
+			}
+			m.scalerMetadata[k] = v
+		}
+		return nil
+	})
+}
+
+type isActiveResponse struct {
+	result bool
+}
+
+func (m *isActiveResponse) marshal() []byte {
+	var b []byte
+	if m.result {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b
+}
+
+func (m *isActiveResponse) unmarshal(b []byte) error {
+	return consumeFields(b, func(f field) error {
+		if f.num == 1 {
+			m.result = f.varint != 0
+		}
+		return nil
+	})
+}
+
+type metricSpec struct {
+	metricName      string
+	targetSize      int64
+	targetSizeFloat float64
+}
+
+func (m *metricSpec) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.metricName)
+	b = appendInt64(b, 2, m.targetSize)
+	b = appendDouble(b, 3, m.targetSizeFloat)
+	return b
+}
+
+func (m *metricSpec) unmarshal(b []byte) error {
+	return consumeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.metricName = string(f.bytes)
+		case 2:
+			m.targetSize = int64(f.varint)
+		case 3:
+			m.targetSizeFloat = math.Float64frombits(f.fixed64)
+		}
+		return nil
+	})
+}
+
+type getMetricSpecResponse struct {
+	metricSpecs []metricSpec
+}
+
+func (m *getMetricSpecResponse) marshal() []byte {
+	var b []byte
+	for i := range m.metricSpecs {
+		b = appendMessage(b, 1, m.metricSpecs[i].marshal())
+	}
+	return b
+}
+
+func (m *getMetricSpecResponse) unmarshal(b []byte) error {
+	return consumeFields(b, func(f field) error {
+		if f.num != 1 {
+			return nil
+		}
+		var spec metricSpec
+		if err := spec.unmarshal(f.bytes); err != nil {
+			return err
+		}
+		m.metricSpecs = append(m.metricSpecs, spec)
+		return nil
+	})
+}
+
+type getMetricsRequest struct {
+	scaledObjectRef scaledObjectRef
+	metricName      string
+}
+
+func (m *getMetricsRequest) marshal() []byte {
+	var b []byte
+	b = appendMessage(b, 1, m.scaledObjectRef.marshal())
+	b = appendString(b, 2, m.metricName)
+	return b
+}
+
+func (m *getMetricsRequest) unmarshal(b []byte) error {
+	return consumeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			return m.scaledObjectRef.unmarshal(f.bytes)
+		case 2:
+			m.metricName = string(f.bytes)
+		}
+		return nil
+	})
+}
+
+type metricValue struct {
+	metricName       string
+	metricValue      int64
+	metricValueFloat float64
+}
+
+func (m *metricValue) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.metricName)
+	b = appendInt64(b, 2, m.metricValue)
+	b = appendDouble(b, 3, m.metricValueFloat)
+	return b
+}
+
+func (m *metricValue) unmarshal(b []byte) error {
+	return consumeFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.metricName = string(f.bytes)
+		case 2:
+			m.metricValue = int64(f.varint)
+		case 3:
+			m.metricValueFloat = math.Float64frombits(f.fixed64)
+		}
+		return nil
+	})
+}
+
+type getMetricsResponse struct {
+	metricValues []metricValue
+}
+
+func (m *getMetricsResponse) marshal() []byte {
+	var b []byte
+	for i := range m.metricValues {
+		b = appendMessage(b, 1, m.metricValues[i].marshal())
+	}
+	return b
+}
+
+func (m *getMetricsResponse) unmarshal(b []byte) error {
+	return consumeFields(b, func(f field) error {
+		if f.num != 1 {
+			return nil
+		}
+		var value metricValue
+		if err := value.unmarshal(f.bytes); err != nil {
+			return err
+		}
+		m.metricValues = append(m.metricValues, value)
+		return nil
+	})
+}
+
+// field is a field of a message, its value being set according to its wire type.
+type field struct {
+	num     protowire.Number
+	varint  uint64
+	fixed64 uint64
+	bytes   []byte
+}
+
+// consumeFields calls fn for every field of the message, in the order they are encoded.
+func consumeFields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := field{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.fixed64, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func appendInt64(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
@@ -0,0 +1,123 @@
+package kedascaler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// externalScalerProto describes the messages of the externalscaler.proto of KEDA this package encodes.
+func externalScalerProto(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	scalar := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{Name: proto.String(name), JsonName: proto.String(name), Number: proto.Int32(number), Label: optional, Type: typ.Enum()}
+	}
+	nested := func(name string, number int32, label *descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{Name: proto.String(name), JsonName: proto.String(name), Number: proto.Int32(number), Label: label, Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(typeName)}
+	}
+	str, i64, f64, b := descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, descriptorpb.FieldDescriptorProto_TYPE_BOOL
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("externalscaler.proto"),
+		Package: proto.String("externalscaler"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("ScaledObjectRef"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					scalar("name", 1, str),
+					scalar("namespace", 2, str),
+					nested("scalerMetadata", 3, repeated, ".externalscaler.ScaledObjectRef.ScalerMetadataEntry"),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name:    proto.String("ScalerMetadataEntry"),
+					Field:   []*descriptorpb.FieldDescriptorProto{scalar("key", 1, str), scalar("value", 2, str)},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+			},
+			{Name: proto.String("IsActiveResponse"), Field: []*descriptorpb.FieldDescriptorProto{scalar("result", 1, b)}},
+			{Name: proto.String("GetMetricSpecResponse"), Field: []*descriptorpb.FieldDescriptorProto{nested("metricSpecs", 1, repeated, ".externalscaler.MetricSpec")}},
+			{Name: proto.String("MetricSpec"), Field: []*descriptorpb.FieldDescriptorProto{scalar("metricName", 1, str), scalar("targetSize", 2, i64), scalar("targetSizeFloat", 3, f64)}},
+			{Name: proto.String("GetMetricsRequest"), Field: []*descriptorpb.FieldDescriptorProto{nested("scaledObjectRef", 1, optional, ".externalscaler.ScaledObjectRef"), scalar("metricName", 2, str)}},
+			{Name: proto.String("GetMetricsResponse"), Field: []*descriptorpb.FieldDescriptorProto{nested("metricValues", 1, repeated, ".externalscaler.MetricValue")}},
+			{Name: proto.String("MetricValue"), Field: []*descriptorpb.FieldDescriptorProto{scalar("metricName", 1, str), scalar("metricValue", 2, i64), scalar("metricValueFloat", 3, f64)}},
+		},
+	}, nil)
+	require.NoError(t, err)
+	return file
+}
+
+func TestMessagesWireCompatibility(t *testing.T) {
+	t.Parallel()
+
+	file := externalScalerProto(t)
+	newMessage := func(name protoreflect.Name) *dynamicpb.Message {
+		return dynamicpb.NewMessage(file.Messages().ByName(name))
+	}
+
+	t.Run("GetMetricsRequest", func(t *testing.T) {
+		// encoded by KEDA
+		ref := newMessage("ScaledObjectRef")
+		ref.Set(ref.Descriptor().Fields().ByName("name"), protoreflect.ValueOfString("runners"))
+		ref.Set(ref.Descriptor().Fields().ByName("namespace"), protoreflect.ValueOfString("arc-runners"))
+		metadata := ref.Mutable(ref.Descriptor().Fields().ByName("scalerMetadata")).Map()
+		metadata.Set(protoreflect.ValueOfString("scalerAddress").MapKey(), protoreflect.ValueOfString("10.0.0.1:9090"))
+		req := newMessage("GetMetricsRequest")
+		req.Set(req.Descriptor().Fields().ByName("scaledObjectRef"), protoreflect.ValueOfMessage(ref))
+		req.Set(req.Descriptor().Fields().ByName("metricName"), protoreflect.ValueOfString("desired-runners"))
+		b, err := proto.Marshal(req)
+		require.NoError(t, err)
+
+		var got getMetricsRequest
+		require.NoError(t, got.unmarshal(b))
+		assert.Equal(t, getMetricsRequest{
+			scaledObjectRef: scaledObjectRef{
+				name:           "runners",
+				namespace:      "arc-runners",
+				scalerMetadata: map[string]string{"scalerAddress": "10.0.0.1:9090"},
+			},
+			metricName: "desired-runners",
+		}, got)
+	})
+
+	t.Run("GetMetricsResponse", func(t *testing.T) {
+		resp := &getMetricsResponse{metricValues: []metricValue{{metricName: "desired-runners", metricValue: 7, metricValueFloat: 7}}}
+
+		// decoded by KEDA
+		got := newMessage("GetMetricsResponse")
+		require.NoError(t, proto.Unmarshal(resp.marshal(), got))
+		values := got.Get(got.Descriptor().Fields().ByName("metricValues")).List()
+		require.Equal(t, 1, values.Len())
+		value := values.Get(0).Message()
+		fields := value.Descriptor().Fields()
+		assert.Equal(t, "desired-runners", value.Get(fields.ByName("metricName")).String())
+		assert.Equal(t, int64(7), value.Get(fields.ByName("metricValue")).Int())
+		assert.Equal(t, 7.0, value.Get(fields.ByName("metricValueFloat")).Float())
+	})
+
+	t.Run("GetMetricSpecResponse", func(t *testing.T) {
+		resp := &getMetricSpecResponse{metricSpecs: []metricSpec{{metricName: "desired-runners", targetSize: 4, targetSizeFloat: 4}}}
+
+		got := newMessage("GetMetricSpecResponse")
+		require.NoError(t, proto.Unmarshal(resp.marshal(), got))
+		specs := got.Get(got.Descriptor().Fields().ByName("metricSpecs")).List()
+		require.Equal(t, 1, specs.Len())
+		spec := specs.Get(0).Message()
+		assert.Equal(t, int64(4), spec.Get(spec.Descriptor().Fields().ByName("targetSize")).Int())
+	})
+
+	t.Run("IsActiveResponse", func(t *testing.T) {
+		got := newMessage("IsActiveResponse")
+		require.NoError(t, proto.Unmarshal((&isActiveResponse{result: true}).marshal(), got))
+		assert.True(t, got.Get(got.Descriptor().Fields().ByName("result")).Bool())
+	})
+}
@@ -0,0 +1,213 @@
+// Package kedascaler serves the desired runner count of the listener over the external scaler gRPC protocol
+// of KEDA, so clusters standardized on KEDA can scale, e.g., placeholder pods reserving nodes for the runners
+// from the same demand as the scale set. A ScaledObject points its "external" trigger at the server:
+//
+//	triggers:
+//	  - type: external
+//	    metadata:
+//	      scalerAddress: <listener pod IP>:<port>
+//
+// The scale set is the one of the listener, the name and metadata of the ScaledObject are not used.
+package kedascaler
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultMetricName = "desired-runners"
+
+	// defaultStreamInterval is the interval at which StreamIsActive checks the desired runner count for changes.
+	defaultStreamInterval = 5 * time.Second
+)
+
+// ServerConfig configures the Server.
+type ServerConfig struct {
+	Addr string
+	// MetricName is the name of the metric of the desired runner count. Defaults to "desired-runners".
+	MetricName string
+	// TargetSize is the desired runner count per replica of the scaled resource. Defaults to 1.
+	TargetSize int64
+	// DesiredRunners returns the desired runner count of the scale set.
+	DesiredRunners func() int
+	Logger         logr.Logger
+}
+
+// Server serves the external scaler protocol.
+type Server struct {
+	addr           string
+	metricName     string
+	targetSize     int64
+	desiredRunners func() int
+	logger         logr.Logger
+	streamInterval time.Duration
+}
+
+func NewServer(config ServerConfig) *Server {
+	s := &Server{
+		addr:           config.Addr,
+		metricName:     config.MetricName,
+		targetSize:     config.TargetSize,
+		desiredRunners: config.DesiredRunners,
+		logger:         config.Logger,
+		streamInterval: defaultStreamInterval,
+	}
+	if s.metricName == "" {
+		s.metricName = DefaultMetricName
+	}
+	if s.targetSize <= 0 {
+		s.targetSize = 1
+	}
+	return s
+}
+
+// ListenAndServe serves the external scaler protocol until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return errcode.Wrap(errcode.KedaScaler, err)
+	}
+	return s.serve(ctx, ln)
+}
+
+func (s *Server) serve(ctx context.Context, ln net.Listener) error {
+	srv := grpc.NewServer(grpc.ForceServerCodec(codec{}))
+	srv.RegisterService(&serviceDesc, s)
+
+	go func() {
+		<-ctx.Done()
+		s.logger.Info("stopping KEDA external scaler", "err", ctx.Err())
+		// The StreamIsActive streams are held open by KEDA, they are closed right away.
+		srv.Stop()
+	}()
+
+	s.logger.Info("starting KEDA external scaler", "addr", ln.Addr().String())
+	if err := srv.Serve(ln); err != nil {
+		return errcode.Wrap(errcode.KedaScaler, err)
+	}
+	return nil
+}
+
+func (s *Server) desired() int64 {
+	return int64(max(s.desiredRunners(), 0))
+}
+
+func (s *Server) isActive(context.Context, *scaledObjectRef) (*isActiveResponse, error) {
+	return &isActiveResponse{result: s.desired() > 0}, nil
+}
+
+// streamIsActive sends whether the scale set is active once, then again every time it changes.
+func (s *Server) streamIsActive(_ *scaledObjectRef, stream grpc.ServerStream) error {
+	ticker := time.NewTicker(s.streamInterval)
+	defer ticker.Stop()
+
+	active := s.desired() > 0
+	for {
+		if err := stream.SendMsg(&isActiveResponse{result: active}); err != nil {
+			return err
+		}
+		for {
+			select {
+			case <-stream.Context().Done():
+				return nil
+			case <-ticker.C:
+			}
+			if now := s.desired() > 0; now != active {
+				active = now
+				break
+			}
+		}
+	}
+}
+
+func (s *Server) getMetricSpec(context.Context, *scaledObjectRef) (*getMetricSpecResponse, error) {
+	return &getMetricSpecResponse{
+		metricSpecs: []metricSpec{{
+			metricName:      s.metricName,
+			targetSize:      s.targetSize,
+			targetSizeFloat: float64(s.targetSize),
+		}},
+	}, nil
+}
+
+func (s *Server) getMetrics(_ context.Context, req *getMetricsRequest) (*getMetricsResponse, error) {
+	if req.metricName != s.metricName {
+		return nil, status.Errorf(codes.NotFound, "unknown metric %q", req.metricName)
+	}
+	desired := s.desired()
+	return &getMetricsResponse{
+		metricValues: []metricValue{{
+			metricName:       s.metricName,
+			metricValue:      desired,
+			metricValueFloat: float64(desired),
+		}},
+	}, nil
+}
+
+// externalScaler is the handler type of the service.
+type externalScaler interface {
+	isActive(ctx context.Context, in *scaledObjectRef) (*isActiveResponse, error)
+	streamIsActive(in *scaledObjectRef, stream grpc.ServerStream) error
+	getMetricSpec(ctx context.Context, in *scaledObjectRef) (*getMetricSpecResponse, error)
+	getMetrics(ctx context.Context, in *getMetricsRequest) (*getMetricsResponse, error)
+}
+
+// serviceDesc is the externalscaler.ExternalScaler service of KEDA.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "externalscaler.ExternalScaler",
+	HandlerType: (*externalScaler)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "IsActive",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				in := new(scaledObjectRef)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return srv.(externalScaler).isActive(ctx, in)
+			},
+		},
+		{
+			MethodName: "GetMetricSpec",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				in := new(scaledObjectRef)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return srv.(externalScaler).getMetricSpec(ctx, in)
+			},
+		},
+		{
+			MethodName: "GetMetrics",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				in := new(getMetricsRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return srv.(externalScaler).getMetrics(ctx, in)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamIsActive",
+			ServerStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				in := new(scaledObjectRef)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return srv.(externalScaler).streamIsActive(in, stream)
+			},
+		},
+	},
+	Metadata: "externalscaler.proto",
+}
@@ -0,0 +1,93 @@
+package kedascaler
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	var desired atomic.Int64
+	server := NewServer(ServerConfig{
+		TargetSize:     2,
+		DesiredRunners: func() int { return int(desired.Load()) },
+		Logger:         logr.Discard(),
+	})
+	server.streamInterval = 10 * time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.serve(ctx, ln)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+
+	conn, err := grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	ref := &scaledObjectRef{name: "runners", namespace: "arc-runners"}
+
+	t.Run("IsActive", func(t *testing.T) {
+		var resp isActiveResponse
+		require.NoError(t, conn.Invoke(context.Background(), "/externalscaler.ExternalScaler/IsActive", ref, &resp))
+		assert.False(t, resp.result)
+	})
+
+	t.Run("GetMetricSpec", func(t *testing.T) {
+		var resp getMetricSpecResponse
+		require.NoError(t, conn.Invoke(context.Background(), "/externalscaler.ExternalScaler/GetMetricSpec", ref, &resp))
+		assert.Equal(t, []metricSpec{{metricName: DefaultMetricName, targetSize: 2, targetSizeFloat: 2}}, resp.metricSpecs)
+	})
+
+	t.Run("GetMetrics", func(t *testing.T) {
+		desired.Store(5)
+		t.Cleanup(func() { desired.Store(0) })
+
+		var resp getMetricsResponse
+		require.NoError(t, conn.Invoke(context.Background(), "/externalscaler.ExternalScaler/GetMetrics", &getMetricsRequest{scaledObjectRef: *ref, metricName: DefaultMetricName}, &resp))
+		assert.Equal(t, []metricValue{{metricName: DefaultMetricName, metricValue: 5, metricValueFloat: 5}}, resp.metricValues)
+
+		err := conn.Invoke(context.Background(), "/externalscaler.ExternalScaler/GetMetrics", &getMetricsRequest{scaledObjectRef: *ref, metricName: "other"}, &resp)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("StreamIsActive", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], "/externalscaler.ExternalScaler/StreamIsActive")
+		require.NoError(t, err)
+		require.NoError(t, stream.SendMsg(ref))
+		require.NoError(t, stream.CloseSend())
+
+		var resp isActiveResponse
+		require.NoError(t, stream.RecvMsg(&resp))
+		assert.False(t, resp.result)
+
+		desired.Store(1)
+		t.Cleanup(func() { desired.Store(0) })
+		require.NoError(t, stream.RecvMsg(&resp))
+		assert.True(t, resp.result)
+	})
+}
@@ -0,0 +1,98 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+)
+
+// sessionRetryInterval is the maximum time waited between the attempts to establish or refresh
+// the message session while the GitHub Actions service is unreachable.
+const sessionRetryInterval = 30 * time.Second
+
+//go:generate mockery --name Fallback --output ./mocks --outpkg mocks --case underscore
+type Fallback interface {
+	// HandleSessionUnavailable scales the runners to the fallback replicas and returns them.
+	HandleSessionUnavailable(ctx context.Context) (int, error)
+}
+
+// isServiceUnreachable reports whether err may go away by retrying, unlike the client errors of the
+// GitHub Actions service, e.g. invalid credentials or a deleted scale set.
+func isServiceUnreachable(err error) bool {
+	var clientErr *actions.HttpClientSideError
+	if errors.As(err, &clientErr) {
+		return clientErr.Code == http.StatusTooManyRequests
+	}
+	var actionsErr *actions.ActionsError
+	if errors.As(err, &actionsErr) {
+		return actionsErr.StatusCode >= http.StatusInternalServerError || actionsErr.StatusCode == http.StatusTooManyRequests
+	}
+	var apiErr *actions.GitHubAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// retriesSession reports whether the failure to establish or refresh the message session is retried
+// until the service is reachable again, which is only the case with a fallback.
+func (l *Listener) retriesSession(err error) bool {
+	return l.fallback != nil && isServiceUnreachable(err)
+}
+
+// sessionUnavailable records the failure to establish or refresh the message session, and waits before
+// the next attempt. Once the session was unavailable for the fallback duration, the runners are scaled
+// to the fallback replicas instead of staying at the last patch until the service is reachable again.
+func (l *Listener) sessionUnavailable(ctx context.Context, err error) error {
+	now := l.clock.Now()
+	if l.unavailableSince.IsZero() {
+		l.unavailableSince = now
+		l.publishSessionInfo()
+	}
+	unavailableFor := now.Sub(l.unavailableSince)
+
+	wait := sessionRetryInterval
+	if !l.fellBack {
+		if unavailableFor >= l.fallbackAfter {
+			l.fallBack(ctx, unavailableFor)
+		} else {
+			wait = min(wait, l.fallbackAfter-unavailableFor)
+		}
+	}
+
+	l.logger.Info("Unable to establish the message session. Will try again", "retryIn", wait.String(), "unavailableFor", unavailableFor.String(), "error", err.Error())
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context cancelled: %w", ctx.Err())
+	case <-l.clock.After(wait):
+		return nil
+	}
+}
+
+// fallBack scales the runners to the fallback replicas. It is attempted again on the next failure
+// if the runners could not be scaled.
+func (l *Listener) fallBack(ctx context.Context, unavailableFor time.Duration) {
+	l.logger.Info("Message session unavailable, scaling to the fallback replicas", "unavailableFor", unavailableFor.String())
+	replicas, err := l.fallback.HandleSessionUnavailable(ctx)
+	if err != nil {
+		l.logger.Error(err, "Failed to scale to the fallback replicas")
+		return
+	}
+	l.fellBack = true
+	l.metrics.PublishDesiredRunners(replicas)
+}
+
+// sessionAvailable resets the unavailability of the message session once it is established or refreshed.
+// The fallback replicas are replaced by the desired runner count of the next message.
+func (l *Listener) sessionAvailable() {
+	if l.fellBack {
+		l.logger.Info("Message session available again, leaving the fallback replicas", "unavailableFor", l.clock.Since(l.unavailableSince).String())
+	}
+	l.unavailableSince = time.Time{}
+	l.fellBack = false
+}
@@ -0,0 +1,41 @@
+package listener
+
+import (
+	"github.com/actions/actions-runner-controller/github/actions"
+)
+
+// JobCounts are the jobs of the scale set at each stage from their acquisition to a runner picking them up,
+// from the statistics of a message. They tell whether the jobs wait on GitHub to be assigned, or on the
+// runners to be scheduled.
+type JobCounts struct {
+	// Acquired are the jobs acquired by the scale set which GitHub did not assign to it yet.
+	Acquired int
+	// Assigned are the jobs assigned to the scale set, picked up by a runner or not.
+	Assigned int
+	// Running are the assigned jobs picked up by a runner.
+	Running int
+}
+
+// WaitingForRunner returns the assigned jobs which no runner picked up yet.
+func (c JobCounts) WaitingForRunner() int {
+	return max(c.Assigned-c.Running, 0)
+}
+
+// JobCountsHandler is implemented by the handlers which record the job counts of every message,
+// before its desired runner count is handled.
+type JobCountsHandler interface {
+	HandleJobCounts(counts JobCounts)
+}
+
+// handleJobCounts passes the job counts of the statistics to the handler, if it records them.
+func (l *Listener) handleJobCounts(handler Handler, stats *actions.RunnerScaleSetStatistic, assigned int) {
+	h, ok := handler.(JobCountsHandler)
+	if !ok {
+		return
+	}
+	h.HandleJobCounts(JobCounts{
+		Acquired: stats.TotalAcquiredJobs,
+		Assigned: assigned,
+		Running:  stats.TotalRunningJobs,
+	})
+}
@@ -0,0 +1,53 @@
+package listener
+
+import (
+	"context"
+	"testing"
+
+	listenermocks "github.com/actions/actions-runner-controller/cmd/ghalistener/listener/mocks"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type jobCountsHandler struct {
+	*listenermocks.Handler
+	counts []JobCounts
+}
+
+func (h *jobCountsHandler) HandleJobCounts(counts JobCounts) {
+	h.counts = append(h.counts, counts)
+}
+
+func TestListener_handleMessageJobCounts(t *testing.T) {
+	t.Parallel()
+
+	client := listenermocks.NewClient(t)
+	client.On("DeleteMessage", mock.Anything, "https://example.com", "token", int64(1)).Return(nil).Once()
+	handler := &jobCountsHandler{Handler: listenermocks.NewHandler(t)}
+	handler.On("HandleDesiredRunnerCount", mock.Anything, 3, 0).Return(3, nil).Once()
+
+	l, err := New(Config{
+		ScaleSetID: 1,
+		Client:     client,
+		Metrics:    metrics.Discard,
+	})
+	require.NoError(t, err)
+	l.session = &actions.RunnerScaleSetSession{
+		MessageQueueUrl:         "https://example.com",
+		MessageQueueAccessToken: "token",
+	}
+
+	err = l.handleMessage(context.Background(), handler, &actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{TotalAcquiredJobs: 1, TotalAssignedJobs: 3, TotalRunningJobs: 1},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []JobCounts{{Acquired: 1, Assigned: 3, Running: 1}}, handler.counts)
+	assert.Equal(t, 2, handler.counts[0].WaitingForRunner())
+	assert.Equal(t, 0, JobCounts{Assigned: 1, Running: 2}.WaitingForRunner(), "the runners picking up jobs just assigned do not count")
+}
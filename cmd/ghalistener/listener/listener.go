@@ -5,18 +5,36 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/audit"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/health"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/notify"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/recovery"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/tracing"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/utils/clock"
 )
 
 const (
 	sessionCreationMaxRetries = 10
+	// defaultMessageConcurrency is the default number of job messages handled in parallel.
+	defaultMessageConcurrency = 4
+	// defaultDrainTimeout is the default time the listener has to drain once it is stopped.
+	defaultDrainTimeout = 30 * time.Second
 )
 
 // message types
@@ -27,6 +45,12 @@ const (
 	messageTypeJobCompleted = "JobCompleted"
 )
 
+var tracer = otel.Tracer("github.com/actions/actions-runner-controller/cmd/ghalistener/listener")
+
+// processingTypeDesiredRunnerCount labels the processing latency of the desired runner count,
+// which is derived from the statistics of every message rather than a job message.
+const processingTypeDesiredRunnerCount = "DesiredRunnerCount"
+
 //go:generate mockery --name Client --output ./mocks --outpkg mocks --case underscore
 type Client interface {
 	GetAcquirableJobs(ctx context.Context, runnerScaleSetId int) (*actions.AcquirableJobList, error)
@@ -45,6 +69,48 @@ type Config struct {
 	MaxRunners int
 	Logger     logr.Logger
 	Metrics    metrics.Publisher
+	// MessageConcurrency is the maximum number of job messages handled in parallel.
+	// The desired runner count is always handled on its own and is not subject to this limit.
+	// Defaults to 4.
+	MessageConcurrency int
+	// Clock is used for retries and latency measurements. Defaults to the real clock.
+	Clock clock.Clock
+	// Health records the progress of the listener for the health endpoints, if set.
+	Health *health.Status
+	// DrainTimeout bounds the time the listener takes, once stopped, to flush the final desired
+	// runner count and to delete the message session. Defaults to 30 seconds.
+	DrainTimeout time.Duration
+	// SessionStore persists the message session, if set, so a restarted listener resumes it.
+	SessionStore SessionStore
+	// DeadLetter receives the job messages quarantined for failing validation, one JSON record per line, if set.
+	DeadLetter io.Writer
+	// Executor runs the job started handlers in place of a pool of MessageConcurrency goroutines, if set,
+	// e.g. a pool shared by the listeners of a gateway.
+	Executor Executor
+	// FallbackAfter is the time the message session may fail to be established or refreshed, while the
+	// GitHub Actions service is unreachable, before Fallback scales the runners. The session is retried
+	// until it is established again. Zero returns the error of the session instead.
+	FallbackAfter time.Duration
+	// Fallback scales the runners once the message session was unavailable for FallbackAfter.
+	Fallback Fallback
+	// SessionStartupTimeout is the time the listener retries to create the message session at startup,
+	// with an exponential backoff and jitter, while the GitHub Actions service is unreachable.
+	// Zero returns the error of the session instead. It does not apply with FallbackAfter.
+	SessionStartupTimeout time.Duration
+	// Audit records the job started and job completed messages, and the desired runner count of every message, if set.
+	Audit *audit.Log
+	// Notifier posts the completed jobs to a webhook, if set.
+	Notifier *notify.Notifier
+	// MisroutedJobPolicy is how the jobs requiring labels the scale set does not provide are handled.
+	// Defaults to MisroutedJobProvision.
+	MisroutedJobPolicy MisroutedJobPolicy
+	// MisroutedJobs records the jobs requiring labels the scale set does not provide, if set.
+	MisroutedJobs MisroutedJobRecorder
+}
+
+// Executor runs functions asynchronously.
+type Executor interface {
+	Go(fn func())
 }
 
 func (c *Config) Validate() error {
@@ -63,6 +129,24 @@ func (c *Config) Validate() error {
 	if c.MaxRunners > 0 && c.MinRunners > c.MaxRunners {
 		return errors.New("minRunners must be less than or equal to maxRunners")
 	}
+	if c.MessageConcurrency < 0 {
+		return errors.New("messageConcurrency must be greater than or equal to 0")
+	}
+	if c.DrainTimeout < 0 {
+		return errors.New("drainTimeout must be greater than or equal to 0")
+	}
+	if c.FallbackAfter < 0 {
+		return errors.New("fallbackAfter must be greater than or equal to 0")
+	}
+	if c.FallbackAfter > 0 && c.Fallback == nil {
+		return errors.New("fallback is required with fallbackAfter")
+	}
+	if c.SessionStartupTimeout < 0 {
+		return errors.New("sessionStartupTimeout must be greater than or equal to 0")
+	}
+	if err := c.MisroutedJobPolicy.validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -73,15 +157,49 @@ type Listener struct {
 	scaleSetID int               // The ID of the scale set associated with the listener.
 	client     Client            // The client used to interact with the scale set.
 	metrics    metrics.Publisher // The publisher used to publish metrics.
+	health     *health.Status    // The status reported by the health endpoints. Nil discards it.
+
+	sessionStore SessionStore     // The store the message session is resumed from. Nil disables resuming.
+	deadLetter   io.Writer        // The log quarantined job messages are written to. Nil only logs them.
+	executor     Executor         // The executor of the job started handlers. Nil uses a pool of its own.
+	fallback     Fallback         // The fallback of an unavailable message session. Nil returns the error instead.
+	audit        *audit.Log       // The audit log of the job lifecycle events. Nil discards them.
+	notifier     *notify.Notifier // The notifier of the completed jobs. Nil discards them.
+
+	misroutedJobPolicy MisroutedJobPolicy   // How the jobs requiring labels the scale set does not provide are handled.
+	misroutedJobs      MisroutedJobRecorder // The recorder of the misrouted jobs. Nil only counts them.
+
+	messageConcurrency    int           // The maximum number of job messages handled in parallel.
+	drainTimeout          time.Duration // The time the listener has to drain once stopped.
+	fallbackAfter         time.Duration // The time the message session may be unavailable before falling back.
+	sessionStartupTimeout time.Duration // The time the message session is retried at startup. Zero disables it.
 
 	// internal fields
 	logger   logr.Logger // The logger used for logging.
 	hostname string      // The hostname of the listener.
+	clock    clock.Clock // The clock used for retries and latency measurements.
 
 	// updated fields
+	draining      bool                           // Whether the listener is stopped and must not acquire jobs anymore.
 	lastMessageID int64                          // The ID of the last processed message.
 	maxCapacity   int                            // The maximum number of runners that can be created.
 	session       *actions.RunnerScaleSetSession // The session for managing the runner scale set.
+	// The time the session was created at.
+	sessionCreatedAt time.Time
+	// The time the message queue token of the session expires at, zero if it is unknown.
+	sessionExpiresAt time.Time
+	// The time the session is refreshed again before its expiry at the earliest, after a refresh attempt.
+	nextRefreshAt time.Time
+	// The time of the first failure to establish or refresh the session, zero while it is available.
+	unavailableSince time.Time
+	// Whether the runners were scaled to the fallback replicas since the session is unavailable.
+	fellBack bool
+	// The runner request IDs of the misrouted jobs assigned to the scale set, excluded from the desired runner count.
+	excludedJobs map[int64]struct{}
+
+	// fields shared with the admin API
+	sessionInfo      atomic.Pointer[SessionInfo] // The message session, published for Session.
+	refreshRequested atomic.Bool                 // Whether RequestSessionRefresh was called since the last refresh.
 }
 
 func New(config Config) (*Listener, error) {
@@ -94,7 +212,44 @@ func New(config Config) (*Listener, error) {
 		client:      config.Client,
 		logger:      config.Logger,
 		metrics:     metrics.Discard,
+		health:      config.Health,
 		maxCapacity: config.MaxRunners,
+
+		sessionStore: config.SessionStore,
+		deadLetter:   config.DeadLetter,
+		executor:     config.Executor,
+		audit:        config.Audit,
+		notifier:     config.Notifier,
+
+		misroutedJobPolicy: MisroutedJobProvision,
+		misroutedJobs:      config.MisroutedJobs,
+		excludedJobs:       map[int64]struct{}{},
+
+		messageConcurrency:    defaultMessageConcurrency,
+		drainTimeout:          defaultDrainTimeout,
+		sessionStartupTimeout: config.SessionStartupTimeout,
+		clock:                 clock.RealClock{},
+	}
+
+	if config.DrainTimeout > 0 {
+		listener.drainTimeout = config.DrainTimeout
+	}
+
+	if config.MisroutedJobPolicy != "" {
+		listener.misroutedJobPolicy = config.MisroutedJobPolicy
+	}
+
+	if config.FallbackAfter > 0 {
+		listener.fallback = config.Fallback
+		listener.fallbackAfter = config.FallbackAfter
+	}
+
+	if config.Clock != nil {
+		listener.clock = config.Clock
+	}
+
+	if config.MessageConcurrency > 0 {
+		listener.messageConcurrency = config.MessageConcurrency
 	}
 
 	if config.Metrics != nil {
@@ -113,9 +268,28 @@ func New(config Config) (*Listener, error) {
 	return listener, nil
 }
 
+// SessionState is the persisted state of a message session.
+type SessionState struct {
+	SessionID     uuid.UUID
+	CreatedAt     time.Time
+	LastMessageID int64
+}
+
+// SessionStore persists the message session, so a restarted listener resumes it instead of creating
+// a new one, which would re-deliver the messages the previous listener already processed.
+type SessionStore interface {
+	// LoadSession returns the last stored session, or nil if there is none.
+	LoadSession(ctx context.Context) (*SessionState, error)
+	// SaveLastMessageID stores the ID of the last message processed in the session.
+	SaveLastMessageID(ctx context.Context, lastMessageID int64) error
+}
+
 //go:generate mockery --name Handler --output ./mocks --outpkg mocks --case underscore
 type Handler interface {
+	HandleSessionCreated(ctx context.Context, session *actions.RunnerScaleSetSession, createdAt time.Time) error
+	HandleJobAssigned(ctx context.Context, jobInfo *actions.JobAssigned) error
 	HandleJobStarted(ctx context.Context, jobInfo *actions.JobStarted) error
+	HandleJobCompleted(ctx context.Context, jobInfo *actions.JobCompleted) error
 	HandleDesiredRunnerCount(ctx context.Context, count, jobsCompleted int) (int, error)
 }
 
@@ -129,11 +303,12 @@ func (l *Listener) Listen(ctx context.Context, handler Handler) error {
 		return fmt.Errorf("createSession failed: %w", err)
 	}
 
-	defer func() {
-		if err := l.deleteMessageSession(); err != nil {
-			l.logger.Error(err, "failed to delete message session")
-		}
-	}()
+	defer l.drain(ctx, handler)
+
+	// The session is only recorded to match the listener to it, it is not needed to scale.
+	if err := handler.HandleSessionCreated(ctx, l.session, l.sessionCreatedAt); err != nil {
+		l.logger.Error(err, "Failed to record the message session")
+	}
 
 	initialMessage := &actions.RunnerScaleSetMessage{
 		MessageId:   0,
@@ -143,11 +318,16 @@ func (l *Listener) Listen(ctx context.Context, handler Handler) error {
 	}
 
 	if l.session.Statistics == nil {
-		return fmt.Errorf("session statistics is nil")
+		return errcode.Errorf(errcode.MessageInvalid, "session statistics is nil")
 	}
 	l.metrics.PublishStatistics(initialMessage.Statistics)
 
-	desiredRunners, err := handler.HandleDesiredRunnerCount(ctx, initialMessage.Statistics.TotalAssignedJobs, 0)
+	// The jobs queued before the session existed are acquired right away, so capacity is created for them
+	// with the initial desired runner count instead of after the next message.
+	assignedJobs := initialMessage.Statistics.TotalAssignedJobs + l.acquireStartupJobs(ctx)
+	l.handleJobCounts(handler, initialMessage.Statistics, assignedJobs)
+
+	desiredRunners, err := handler.HandleDesiredRunnerCount(ctx, assignedJobs, 0)
 	if err != nil {
 		return fmt.Errorf("handling initial message failed: %w", err)
 	}
@@ -160,6 +340,12 @@ func (l *Listener) Listen(ctx context.Context, handler Handler) error {
 		default:
 		}
 
+		if err := l.refreshSessionIfRequested(ctx); err != nil {
+			return fmt.Errorf("failed to refresh the message session on request: %w", err)
+		}
+		l.refreshSessionBeforeExpiry(ctx)
+		l.publishSessionHeartbeat()
+
 		msg, err := l.getMessage(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to get message: %w", err)
@@ -174,8 +360,22 @@ func (l *Listener) Listen(ctx context.Context, handler Handler) error {
 			continue
 		}
 
+		// A message received while the listener is being stopped is still handled,
+		// but its jobs are left to be acquired by another listener.
+		l.draining = ctx.Err() != nil
+
 		// Remove cancellation from the context to avoid cancelling the message handling.
-		if err := l.handleMessage(context.WithoutCancel(ctx), handler, msg); err != nil {
+		msgCtx, span := tracer.Start(
+			context.WithoutCancel(ctx),
+			"listener.handleMessage",
+			trace.WithAttributes(
+				attribute.Int64("message.id", msg.MessageId),
+				attribute.String("message.type", msg.MessageType),
+			),
+		)
+		err = l.handleMessage(msgCtx, handler, msg)
+		tracing.End(span, err)
+		if err != nil {
 			return fmt.Errorf("failed to handle message: %w", err)
 		}
 	}
@@ -184,47 +384,149 @@ func (l *Listener) Listen(ctx context.Context, handler Handler) error {
 func (l *Listener) handleMessage(ctx context.Context, handler Handler, msg *actions.RunnerScaleSetMessage) error {
 	parsedMsg, err := l.parseMessage(ctx, msg)
 	if err != nil {
-		return fmt.Errorf("failed to parse message: %w", err)
+		return errcode.Errorf(errcode.MessageInvalid, "failed to parse message: %w", err)
 	}
 	l.metrics.PublishStatistics(parsedMsg.statistics)
 
-	if len(parsedMsg.jobsAvailable) > 0 {
+	parsedMsg.jobsAvailable = l.excludeMisroutedJobs(ctx, parsedMsg.jobsAvailable)
+	l.trackMisroutedJobs(ctx, parsedMsg)
+	assignedJobs := l.assignedJobs(parsedMsg.statistics)
+	l.handleJobCounts(handler, parsedMsg.statistics, assignedJobs)
+
+	if len(parsedMsg.jobsAvailable) > 0 && l.draining {
+		l.logger.Info("Listener is draining, skipping acquiring jobs", "count", len(parsedMsg.jobsAvailable))
+	} else if len(parsedMsg.jobsAvailable) > 0 {
+		start := l.clock.Now()
 		acquiredJobIDs, err := l.acquireAvailableJobs(ctx, parsedMsg.jobsAvailable)
 		if err != nil {
 			return fmt.Errorf("failed to acquire jobs: %w", err)
 		}
+		l.metrics.PublishMessageProcessingDuration(messageTypeJobAvailable, l.clock.Since(start))
 
 		l.logger.Info("Jobs are acquired", "count", len(acquiredJobIDs), "requestIds", fmt.Sprint(acquiredJobIDs))
 	}
 
+	for _, jobAssigned := range parsedMsg.jobsAssigned {
+		l.metrics.PublishJobAssigned(jobAssigned)
+		if err := handler.HandleJobAssigned(ctx, jobAssigned); err != nil {
+			return fmt.Errorf("failed to handle job assigned: %w", err)
+		}
+	}
+
 	for _, jobCompleted := range parsedMsg.jobsCompleted {
+		start := l.clock.Now()
 		l.metrics.PublishJobCompleted(jobCompleted)
+		if err := handler.HandleJobCompleted(ctx, jobCompleted); err != nil {
+			return fmt.Errorf("failed to handle job completed: %w", err)
+		}
+		l.audit.JobCompleted(ctx, jobCompleted)
+		l.notifier.JobCompleted(jobCompleted)
+		l.metrics.PublishMessageProcessingDuration(messageTypeJobCompleted, l.clock.Since(start))
 	}
 
 	l.lastMessageID = msg.MessageId
+	l.publishSessionInfo()
 
 	if err := l.deleteLastMessage(ctx); err != nil {
 		return fmt.Errorf("failed to delete message: %w", err)
 	}
+	if l.sessionStore != nil {
+		// A listener resuming from a previous message re-processes the messages after it, which is only redundant.
+		if err := l.sessionStore.SaveLastMessageID(ctx, l.lastMessageID); err != nil {
+			l.logger.Error(err, "Failed to store the last message ID", "lastMessageID", l.lastMessageID)
+		}
+	}
+
+	// The desired runner count is what creates capacity, so it is handled on its own
+	// instead of waiting behind the job started patches, which are handled by a bounded pool.
+	type desiredRunnerCountResult struct {
+		count int
+		err   error
+	}
+	desiredRunnersResult := make(chan desiredRunnerCountResult, 1)
+	go func() {
+		var result desiredRunnerCountResult
+		// A panic of the handler fails the message instead of the listener, on this goroutine of its own.
+		defer func() { desiredRunnersResult <- result }()
+		defer recovery.Recover(&result.err)
+
+		start := l.clock.Now()
+		result.count, result.err = handler.HandleDesiredRunnerCount(ctx, assignedJobs, len(parsedMsg.jobsCompleted))
+		if result.err == nil {
+			l.metrics.PublishMessageProcessingDuration(processingTypeDesiredRunnerCount, l.clock.Since(start))
+		}
+	}()
+
+	jobsStartedErr := l.handleJobsStarted(ctx, handler, parsedMsg.jobsStarted)
 
-	for _, jobStarted := range parsedMsg.jobsStarted {
+	result := <-desiredRunnersResult
+	if jobsStartedErr != nil {
+		return jobsStartedErr
+	}
+	if result.err != nil {
+		return fmt.Errorf("failed to handle desired runner count: %w", result.err)
+	}
+	l.audit.DesiredRunnerCount(ctx, assignedJobs, len(parsedMsg.jobsCompleted), result.count)
+	l.metrics.PublishDesiredRunners(result.count)
+	return nil
+}
+
+// handleJobsStarted handles the job started messages in parallel and returns the first error.
+func (l *Listener) handleJobsStarted(ctx context.Context, handler Handler, jobsStarted []*actions.JobStarted) error {
+	handle := func(jobStarted *actions.JobStarted) (err error) {
+		defer recovery.Recover(&err)
+
+		start := l.clock.Now()
 		if err := handler.HandleJobStarted(ctx, jobStarted); err != nil {
 			return fmt.Errorf("failed to handle job started: %w", err)
 		}
 		l.metrics.PublishJobStarted(jobStarted)
+		l.metrics.PublishMessageProcessingDuration(messageTypeJobStarted, l.clock.Since(start))
+		l.audit.JobStarted(ctx, jobStarted)
+		return nil
 	}
 
-	desiredRunners, err := handler.HandleDesiredRunnerCount(ctx, parsedMsg.statistics.TotalAssignedJobs, len(parsedMsg.jobsCompleted))
-	if err != nil {
-		return fmt.Errorf("failed to handle desired runner count: %w", err)
+	if l.executor == nil {
+		var g errgroup.Group
+		g.SetLimit(l.messageConcurrency)
+		for _, jobStarted := range jobsStarted {
+			g.Go(func() error {
+				return handle(jobStarted)
+			})
+		}
+		return g.Wait()
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, jobStarted := range jobsStarted {
+		wg.Add(1)
+		l.executor.Go(func() {
+			defer wg.Done()
+			if err := handle(jobStarted); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		})
 	}
-	l.metrics.PublishDesiredRunners(desiredRunners)
-	return nil
+	wg.Wait()
+	return firstErr
 }
 
 func (l *Listener) createSession(ctx context.Context) error {
+	if l.resumeSession(ctx) {
+		return nil
+	}
+
 	var session *actions.RunnerScaleSetSession
-	var retries int
+	var retries, startupFailures int
+	startedAt := l.clock.Now()
 
 	for {
 		var err error
@@ -234,17 +536,26 @@ func (l *Listener) createSession(ctx context.Context) error {
 		}
 
 		clientErr := &actions.HttpClientSideError{}
-		if !errors.As(err, &clientErr) {
-			return fmt.Errorf("failed to create session: %w", err)
-		}
-
-		if clientErr.Code != http.StatusConflict {
-			return fmt.Errorf("failed to create session: %w", err)
+		if !errors.As(err, &clientErr) || clientErr.Code != http.StatusConflict {
+			if l.retriesSession(err) {
+				if err := l.sessionUnavailable(ctx, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if l.sessionStartupTimeout == 0 || !isServiceUnreachable(err) {
+				return errcode.Errorf(errcode.SessionCreate, "failed to create session: %w", err)
+			}
+			startupFailures++
+			if err := l.waitSessionStartup(ctx, startedAt, startupFailures, err); err != nil {
+				return err
+			}
+			continue
 		}
 
 		retries++
 		if retries >= sessionCreationMaxRetries {
-			return fmt.Errorf("failed to create session after %d retries: %w", retries, err)
+			return errcode.Errorf(errcode.SessionCreate, "failed to create session after %d retries: %w", retries, err)
 		}
 
 		l.logger.Info("Unable to create message session. Will try again in 30 seconds", "error", err.Error())
@@ -252,7 +563,7 @@ func (l *Listener) createSession(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("context cancelled: %w", ctx.Err())
-		case <-time.After(30 * time.Second):
+		case <-l.clock.After(30 * time.Second):
 		}
 	}
 
@@ -262,21 +573,71 @@ func (l *Listener) createSession(ctx context.Context) error {
 	}
 	l.logger.Info("Current runner scale set statistics.", "statistics", string(statistics))
 
-	l.session = session
+	if l.sessionStore != nil {
+		// The stored last message ID belongs to the previous session. It is reset before the new session
+		// is recorded by the handler, so the previous session is never resumed from a message of the new one.
+		if err := l.sessionStore.SaveLastMessageID(ctx, 0); err != nil {
+			l.logger.Error(err, "Failed to reset the stored last message ID")
+		}
+	}
+
+	l.sessionAvailable()
+	l.sessionCreatedAt = l.clock.Now()
+	l.setSession(session)
+	l.health.SetSessionEstablished(true)
+	l.metrics.PublishSession(session, l.sessionCreatedAt)
 
 	return nil
 }
 
+// resumeSession resumes the stored message session from the last message processed in it.
+// It reports false if there is no session to resume, in which case a new session is created.
+func (l *Listener) resumeSession(ctx context.Context) bool {
+	if l.sessionStore == nil {
+		return false
+	}
+
+	state, err := l.sessionStore.LoadSession(ctx)
+	if err != nil {
+		l.logger.Error(err, "Failed to load the stored message session, creating a new one")
+		return false
+	}
+	if state == nil {
+		return false
+	}
+
+	// Refreshing the session fails once it was deleted, e.g. by a listener that stopped gracefully.
+	session, err := l.client.RefreshMessageSession(ctx, l.scaleSetID, &state.SessionID)
+	if err != nil {
+		l.logger.Info("Unable to resume the stored message session, creating a new one", "sessionId", state.SessionID.String(), "error", err.Error())
+		return false
+	}
+	if session.Statistics == nil {
+		l.logger.Info("Resumed message session has no statistics, creating a new one", "sessionId", state.SessionID.String())
+		return false
+	}
+
+	l.logger.Info("Resumed message session", "sessionId", state.SessionID.String(), "lastMessageID", state.LastMessageID)
+	l.sessionCreatedAt = state.CreatedAt
+	l.lastMessageID = state.LastMessageID
+	l.setSession(session)
+	l.health.SetSessionEstablished(true)
+	l.metrics.PublishSession(session, l.sessionCreatedAt)
+
+	return true
+}
+
 func (l *Listener) getMessage(ctx context.Context) (*actions.RunnerScaleSetMessage, error) {
 	l.logger.Info("Getting next message", "lastMessageID", l.lastMessageID)
 	msg, err := l.client.GetMessage(ctx, l.session.MessageQueueUrl, l.session.MessageQueueAccessToken, l.lastMessageID, l.maxCapacity)
 	if err == nil { // if NO error
+		l.health.RecordPoll()
 		return msg, nil
 	}
 
 	expiredError := &actions.MessageQueueTokenExpiredError{}
 	if !errors.As(err, &expiredError) {
-		return nil, fmt.Errorf("failed to get next message: %w", err)
+		return nil, errcode.Errorf(errcode.MessageGet, "failed to get next message: %w", err)
 	}
 
 	if err := l.refreshSession(ctx); err != nil {
@@ -287,9 +648,10 @@ func (l *Listener) getMessage(ctx context.Context) (*actions.RunnerScaleSetMessa
 
 	msg, err = l.client.GetMessage(ctx, l.session.MessageQueueUrl, l.session.MessageQueueAccessToken, l.lastMessageID, l.maxCapacity)
 	if err != nil { // if NO error
-		return nil, fmt.Errorf("failed to get next message after message session refresh: %w", err)
+		return nil, errcode.Errorf(errcode.MessageGet, "failed to get next message after message session refresh: %w", err)
 	}
 
+	l.health.RecordPoll()
 	return msg, nil
 }
 
@@ -302,7 +664,7 @@ func (l *Listener) deleteLastMessage(ctx context.Context) error {
 
 	expiredError := &actions.MessageQueueTokenExpiredError{}
 	if !errors.As(err, &expiredError) {
-		return fmt.Errorf("failed to delete last message: %w", err)
+		return errcode.Errorf(errcode.MessageDelete, "failed to delete last message: %w", err)
 	}
 
 	if err := l.refreshSession(ctx); err != nil {
@@ -311,7 +673,7 @@ func (l *Listener) deleteLastMessage(ctx context.Context) error {
 
 	err = l.client.DeleteMessage(ctx, l.session.MessageQueueUrl, l.session.MessageQueueAccessToken, l.lastMessageID)
 	if err != nil {
-		return fmt.Errorf("failed to delete last message after message session refresh: %w", err)
+		return errcode.Errorf(errcode.MessageDelete, "failed to delete last message after message session refresh: %w", err)
 	}
 
 	return nil
@@ -321,6 +683,7 @@ type parsedMessage struct {
 	statistics    *actions.RunnerScaleSetStatistic
 	jobsStarted   []*actions.JobStarted
 	jobsAvailable []*actions.JobAvailable
+	jobsAssigned  []*actions.JobAssigned
 	jobsCompleted []*actions.JobCompleted
 }
 
@@ -348,6 +711,7 @@ func (l *Listener) parseMessage(ctx context.Context, msg *actions.RunnerScaleSet
 		statistics: msg.Statistics,
 	}
 
+	messageID := msg.MessageId
 	for _, msg := range batchedMessages {
 		var messageType actions.JobMessageType
 		if err := json.Unmarshal(msg, &messageType); err != nil {
@@ -371,12 +735,17 @@ func (l *Listener) parseMessage(ctx context.Context, msg *actions.RunnerScaleSet
 			}
 
 			l.logger.Info("Job assigned message received", "jobId", jobAssigned.JobID)
+			parsedMsg.jobsAssigned = append(parsedMsg.jobsAssigned, &jobAssigned)
 
 		case messageTypeJobStarted:
 			var jobStarted actions.JobStarted
 			if err := json.Unmarshal(msg, &jobStarted); err != nil {
 				return nil, fmt.Errorf("could not decode job started message. %w", err)
 			}
+			if err := validateJobStarted(&jobStarted); err != nil {
+				l.quarantine(messageID, messageTypeJobStarted, msg, err)
+				continue
+			}
 			l.logger.Info("Job started message received.", "JobID", jobStarted.JobID, "RunnerId", jobStarted.RunnerID)
 			parsedMsg.jobsStarted = append(parsedMsg.jobsStarted, &jobStarted)
 
@@ -385,6 +754,10 @@ func (l *Listener) parseMessage(ctx context.Context, msg *actions.RunnerScaleSet
 			if err := json.Unmarshal(msg, &jobCompleted); err != nil {
 				return nil, fmt.Errorf("failed to decode job completed: %w", err)
 			}
+			if err := validateJobCompleted(&jobCompleted); err != nil {
+				l.quarantine(messageID, messageTypeJobCompleted, msg, err)
+				continue
+			}
 
 			l.logger.Info(
 				"Job completed message received.",
@@ -403,6 +776,48 @@ func (l *Listener) parseMessage(ctx context.Context, msg *actions.RunnerScaleSet
 	return parsedMsg, nil
 }
 
+// acquireStartupJobs acquires the jobs available to the scale set when the session is created,
+// and returns the number of jobs acquired. The statistics of the session do not count them as assigned yet.
+// Failing to acquire them is not fatal, they are delivered again with the next messages.
+func (l *Listener) acquireStartupJobs(ctx context.Context) int {
+	acquirableJobs, err := l.client.GetAcquirableJobs(ctx, l.scaleSetID)
+	if err != nil {
+		l.logger.Error(err, "Failed to get the acquirable jobs at startup")
+		return 0
+	}
+	if acquirableJobs == nil || len(acquirableJobs.Jobs) == 0 {
+		return 0
+	}
+
+	jobsAvailable := make([]*actions.JobAvailable, 0, len(acquirableJobs.Jobs))
+	for _, job := range acquirableJobs.Jobs {
+		jobsAvailable = append(jobsAvailable, &actions.JobAvailable{
+			AcquireJobUrl: job.AcquireJobUrl,
+			JobMessageBase: actions.JobMessageBase{
+				RunnerRequestID: job.RunnerRequestId,
+				RepositoryName:  job.RepositoryName,
+				OwnerName:       job.OwnerName,
+				JobWorkflowRef:  job.JobWorkflowRef,
+				EventName:       job.EventName,
+				RequestLabels:   job.RequestLabels,
+			},
+		})
+	}
+	jobsAvailable = l.excludeMisroutedJobs(ctx, jobsAvailable)
+	if len(jobsAvailable) == 0 {
+		return 0
+	}
+
+	acquiredJobIDs, err := l.acquireAvailableJobs(ctx, jobsAvailable)
+	if err != nil {
+		l.logger.Error(err, "Failed to acquire the jobs available at startup", "count", len(jobsAvailable))
+		return 0
+	}
+
+	l.logger.Info("Jobs available at startup are acquired", "count", len(acquiredJobIDs), "requestIds", fmt.Sprint(acquiredJobIDs))
+	return len(acquiredJobIDs)
+}
+
 func (l *Listener) acquireAvailableJobs(ctx context.Context, jobsAvailable []*actions.JobAvailable) ([]int64, error) {
 	ids := make([]int64, 0, len(jobsAvailable))
 	for _, job := range jobsAvailable {
@@ -418,7 +833,7 @@ func (l *Listener) acquireAvailableJobs(ctx context.Context, jobsAvailable []*ac
 
 	expiredError := &actions.MessageQueueTokenExpiredError{}
 	if !errors.As(err, &expiredError) {
-		return nil, fmt.Errorf("failed to acquire jobs: %w", err)
+		return nil, errcode.Errorf(errcode.JobAcquire, "failed to acquire jobs: %w", err)
 	}
 
 	if err := l.refreshSession(ctx); err != nil {
@@ -427,7 +842,7 @@ func (l *Listener) acquireAvailableJobs(ctx context.Context, jobsAvailable []*ac
 
 	idsAcquired, err = l.client.AcquireJobs(ctx, l.scaleSetID, l.session.MessageQueueAccessToken, ids)
 	if err != nil {
-		return nil, fmt.Errorf("failed to acquire jobs after session refresh: %w", err)
+		return nil, errcode.Errorf(errcode.JobAcquire, "failed to acquire jobs after session refresh: %w", err)
 	}
 
 	return idsAcquired, nil
@@ -435,23 +850,53 @@ func (l *Listener) acquireAvailableJobs(ctx context.Context, jobsAvailable []*ac
 
 func (l *Listener) refreshSession(ctx context.Context) error {
 	l.logger.Info("Message queue token is expired during GetNextMessage, refreshing...")
-	session, err := l.client.RefreshMessageSession(ctx, l.session.RunnerScaleSet.Id, l.session.SessionId)
-	if err != nil {
-		return fmt.Errorf("refresh message session failed. %w", err)
+	for {
+		session, err := l.client.RefreshMessageSession(ctx, l.session.RunnerScaleSet.Id, l.session.SessionId)
+		if err == nil {
+			l.sessionAvailable()
+			l.setSession(session)
+			return nil
+		}
+		if ctx.Err() == nil {
+			l.metrics.PublishSessionRefreshFailure()
+		}
+		if !l.retriesSession(err) {
+			return errcode.Errorf(errcode.SessionRefresh, "refresh message session failed. %w", err)
+		}
+		if err := l.sessionUnavailable(ctx, err); err != nil {
+			return err
+		}
 	}
-
-	l.session = session
-	return nil
 }
 
-func (l *Listener) deleteMessageSession() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// drain is called once the listener stops. If the listener was stopped by cancelling the context,
+// the desired runner count is patched a final time, so that the last scaling decision is not lost.
+// The message session is then deleted, so that the next listener can create one right away.
+// Draining is bounded by the drain timeout rather than the cancelled context.
+func (l *Listener) drain(ctx context.Context, handler Handler) {
+	drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.drainTimeout)
 	defer cancel()
 
+	if ctx.Err() != nil {
+		l.draining = true
+		l.logger.Info("Draining listener", "timeout", l.drainTimeout)
+		if _, err := handler.HandleDesiredRunnerCount(drainCtx, 0, 0); err != nil {
+			l.logger.Error(err, "failed to flush the desired runner count")
+		}
+	}
+
+	if err := l.deleteMessageSession(drainCtx); err != nil {
+		l.logger.Error(err, "failed to delete message session", "errorCode", errcode.SessionDelete)
+	}
+}
+
+func (l *Listener) deleteMessageSession(ctx context.Context) error {
 	l.logger.Info("Deleting message session")
+	l.health.SetSessionEstablished(false)
+	l.sessionInfo.Store(nil)
 
 	if err := l.client.DeleteMessageSession(ctx, l.session.RunnerScaleSet.Id, l.session.SessionId); err != nil {
-		return fmt.Errorf("failed to delete message session: %w", err)
+		return errcode.Errorf(errcode.SessionDelete, "failed to delete message session: %w", err)
 	}
 
 	return nil
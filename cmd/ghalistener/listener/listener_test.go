@@ -1,6 +1,7 @@
 package listener
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -8,13 +9,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/audit"
 	listenermocks "github.com/actions/actions-runner-controller/cmd/ghalistener/listener/mocks"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	metricsmocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/recovery"
 	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
 )
 
 func TestNew(t *testing.T) {
@@ -83,6 +89,220 @@ func TestListener_createSession(t *testing.T) {
 		assert.True(t, errors.Is(err, context.DeadlineExceeded))
 	})
 
+	t.Run("RetriesAfterConflict", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+
+		config := Config{
+			ScaleSetID: 1,
+			Metrics:    metrics.Discard,
+			Clock:      fakeClock,
+		}
+
+		uuid := uuid.New()
+		session := &actions.RunnerScaleSetSession{
+			SessionId:      &uuid,
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+			Statistics:     &actions.RunnerScaleSetStatistic{},
+		}
+
+		client := listenermocks.NewClient(t)
+		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(nil,
+			&actions.HttpClientSideError{Code: http.StatusConflict}).Once()
+		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(session, nil).Once()
+		config.Client = client
+
+		l, err := New(config)
+		require.Nil(t, err)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- l.createSession(ctx)
+		}()
+
+		require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+		fakeClock.Step(30 * time.Second)
+
+		require.NoError(t, <-errCh)
+		assert.Equal(t, session, l.session)
+	})
+
+	t.Run("FallsBackWhileUnavailable", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+
+		fallback := listenermocks.NewFallback(t)
+		fallback.On("HandleSessionUnavailable", ctx).Return(3, nil).Once()
+		config := Config{
+			ScaleSetID:    1,
+			Metrics:       metrics.Discard,
+			Clock:         fakeClock,
+			FallbackAfter: time.Minute,
+			Fallback:      fallback,
+		}
+
+		uuid := uuid.New()
+		session := &actions.RunnerScaleSetSession{
+			SessionId:      &uuid,
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+			Statistics:     &actions.RunnerScaleSetStatistic{},
+		}
+
+		client := listenermocks.NewClient(t)
+		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(nil,
+			&actions.ActionsError{StatusCode: http.StatusServiceUnavailable}).Times(4)
+		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(session, nil).Once()
+		config.Client = client
+
+		l, err := New(config)
+		require.Nil(t, err)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- l.createSession(ctx)
+		}()
+
+		// The session is retried every 30 seconds, the fallback scales the runners after a minute.
+		for range 4 {
+			require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+			fakeClock.Step(30 * time.Second)
+		}
+
+		require.NoError(t, <-errCh)
+		assert.Equal(t, session, l.session)
+		assert.False(t, l.fellBack)
+		assert.True(t, l.unavailableSince.IsZero())
+	})
+
+	t.Run("FailsOnClientErrorWithFallback", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		config := Config{
+			ScaleSetID:    1,
+			Metrics:       metrics.Discard,
+			FallbackAfter: time.Minute,
+			Fallback:      listenermocks.NewFallback(t),
+		}
+
+		client := listenermocks.NewClient(t)
+		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(nil,
+			&actions.HttpClientSideError{Code: http.StatusUnauthorized}).Once()
+		config.Client = client
+
+		l, err := New(config)
+		require.Nil(t, err)
+
+		err = l.createSession(ctx)
+		assert.Error(t, err)
+	})
+
+	t.Run("RetriesAtStartup", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+
+		config := Config{
+			ScaleSetID:            1,
+			Metrics:               metrics.Discard,
+			Clock:                 fakeClock,
+			SessionStartupTimeout: 5 * time.Minute,
+		}
+
+		uuid := uuid.New()
+		session := &actions.RunnerScaleSetSession{
+			SessionId:      &uuid,
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+			Statistics:     &actions.RunnerScaleSetStatistic{},
+		}
+
+		client := listenermocks.NewClient(t)
+		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(nil,
+			&actions.ActionsError{StatusCode: http.StatusBadGateway}).Times(3)
+		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(session, nil).Once()
+		config.Client = client
+
+		l, err := New(config)
+		require.Nil(t, err)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- l.createSession(ctx)
+		}()
+
+		for range 3 {
+			require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+			fakeClock.Step(sessionRetryInterval)
+		}
+
+		require.NoError(t, <-errCh)
+		assert.Equal(t, session, l.session)
+	})
+
+	t.Run("GivesUpAfterStartupTimeout", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+
+		config := Config{
+			ScaleSetID:            1,
+			Metrics:               metrics.Discard,
+			Clock:                 fakeClock,
+			SessionStartupTimeout: time.Minute,
+		}
+		startedAt := fakeClock.Now()
+
+		client := listenermocks.NewClient(t)
+		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(nil,
+			&actions.ActionsError{StatusCode: http.StatusServiceUnavailable})
+		config.Client = client
+
+		l, err := New(config)
+		require.Nil(t, err)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- l.createSession(ctx)
+		}()
+
+		for {
+			select {
+			case err := <-errCh:
+				assert.ErrorContains(t, err, "failed to create session within 1m0s")
+				assert.GreaterOrEqual(t, fakeClock.Since(startedAt), time.Minute)
+				return
+			case <-time.After(10 * time.Millisecond):
+				if fakeClock.HasWaiters() {
+					fakeClock.Step(sessionRetryInterval)
+				}
+			}
+		}
+	})
+
+	t.Run("FailsOnClientErrorAtStartup", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		config := Config{
+			ScaleSetID:            1,
+			Metrics:               metrics.Discard,
+			SessionStartupTimeout: 5 * time.Minute,
+		}
+
+		client := listenermocks.NewClient(t)
+		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(nil,
+			&actions.HttpClientSideError{Code: http.StatusNotFound}).Once()
+		config.Client = client
+
+		l, err := New(config)
+		require.Nil(t, err)
+
+		err = l.createSession(ctx)
+		assert.Error(t, err)
+	})
+
 	t.Run("SetsSession", func(t *testing.T) {
 		t.Parallel()
 		config := Config{
@@ -113,6 +333,116 @@ func TestListener_createSession(t *testing.T) {
 	})
 }
 
+// fakeSessionStore is a SessionStore holding the session in memory.
+type fakeSessionStore struct {
+	state   *SessionState
+	loadErr error
+	saved   []int64
+}
+
+func (s *fakeSessionStore) LoadSession(context.Context) (*SessionState, error) {
+	return s.state, s.loadErr
+}
+
+func (s *fakeSessionStore) SaveLastMessageID(_ context.Context, lastMessageID int64) error {
+	s.saved = append(s.saved, lastMessageID)
+	return nil
+}
+
+func TestListener_resumeSession(t *testing.T) {
+	t.Parallel()
+
+	storedID := uuid.New()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored := func() *fakeSessionStore {
+		return &fakeSessionStore{state: &SessionState{SessionID: storedID, CreatedAt: createdAt, LastMessageID: 42}}
+	}
+
+	t.Run("ResumesStoredSession", func(t *testing.T) {
+		t.Parallel()
+		session := &actions.RunnerScaleSetSession{
+			SessionId:      &storedID,
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+			Statistics:     &actions.RunnerScaleSetStatistic{},
+		}
+
+		client := listenermocks.NewClient(t)
+		client.On("RefreshMessageSession", mock.Anything, 1, &storedID).Return(session, nil).Once()
+
+		l, err := New(Config{ScaleSetID: 1, Client: client, SessionStore: stored()})
+		require.NoError(t, err)
+
+		require.NoError(t, l.createSession(context.Background()))
+		assert.Equal(t, session, l.session)
+		assert.Equal(t, int64(42), l.lastMessageID)
+		assert.Equal(t, createdAt, l.sessionCreatedAt)
+	})
+
+	t.Run("CreatesSessionWhenStoredOneIsGone", func(t *testing.T) {
+		t.Parallel()
+		newID := uuid.New()
+		session := &actions.RunnerScaleSetSession{
+			SessionId:      &newID,
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+			Statistics:     &actions.RunnerScaleSetStatistic{},
+		}
+
+		client := listenermocks.NewClient(t)
+		client.On("RefreshMessageSession", mock.Anything, 1, &storedID).Return(nil, &actions.HttpClientSideError{Code: http.StatusNotFound}).Once()
+		client.On("CreateMessageSession", mock.Anything, 1, mock.Anything).Return(session, nil).Once()
+
+		store := stored()
+		l, err := New(Config{ScaleSetID: 1, Client: client, SessionStore: store})
+		require.NoError(t, err)
+
+		require.NoError(t, l.createSession(context.Background()))
+		assert.Equal(t, session, l.session)
+		assert.Equal(t, int64(0), l.lastMessageID)
+		assert.Equal(t, []int64{0}, store.saved, "the last message ID of the previous session is reset")
+	})
+
+	t.Run("CreatesSessionWithoutStoredOne", func(t *testing.T) {
+		t.Parallel()
+		newID := uuid.New()
+		session := &actions.RunnerScaleSetSession{
+			SessionId:      &newID,
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+			Statistics:     &actions.RunnerScaleSetStatistic{},
+		}
+
+		client := listenermocks.NewClient(t)
+		client.On("CreateMessageSession", mock.Anything, 1, mock.Anything).Return(session, nil).Once()
+
+		l, err := New(Config{ScaleSetID: 1, Client: client, SessionStore: &fakeSessionStore{loadErr: assert.AnError}})
+		require.NoError(t, err)
+
+		require.NoError(t, l.createSession(context.Background()))
+		assert.Equal(t, session, l.session)
+	})
+
+	t.Run("StoresLastMessageID", func(t *testing.T) {
+		t.Parallel()
+		id := uuid.New()
+		client := listenermocks.NewClient(t)
+		client.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything, int64(7)).Return(nil).Once()
+		handler := listenermocks.NewHandler(t)
+		handler.On("HandleDesiredRunnerCount", mock.Anything, 0, 0).Return(0, nil).Once()
+		store := &fakeSessionStore{}
+
+		l, err := New(Config{ScaleSetID: 1, Client: client, SessionStore: store})
+		require.NoError(t, err)
+		l.session = &actions.RunnerScaleSetSession{SessionId: &id, RunnerScaleSet: &actions.RunnerScaleSet{}}
+
+		err = l.handleMessage(context.Background(), handler, &actions.RunnerScaleSetMessage{
+			MessageId:   7,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{7}, store.saved)
+	})
+}
+
 func TestListener_getMessage(t *testing.T) {
 	t.Parallel()
 
@@ -321,6 +651,181 @@ func TestListener_refreshSession(t *testing.T) {
 		assert.NotNil(t, err)
 		assert.Equal(t, oldSession, l.session)
 	})
+
+	t.Run("RetriesWhileUnavailableWithFallback", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := context.Background()
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+		config := Config{
+			ScaleSetID:    1,
+			Metrics:       metrics.Discard,
+			Clock:         fakeClock,
+			FallbackAfter: time.Hour,
+			Fallback:      listenermocks.NewFallback(t),
+		}
+
+		client := listenermocks.NewClient(t)
+
+		newUUID := uuid.New()
+		session := &actions.RunnerScaleSetSession{
+			SessionId:      &newUUID,
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+		}
+		client.On("RefreshMessageSession", ctx, mock.Anything, mock.Anything).Return(nil, errors.New("connection refused")).Once()
+		client.On("RefreshMessageSession", ctx, mock.Anything, mock.Anything).Return(session, nil).Once()
+
+		config.Client = client
+
+		l, err := New(config)
+		require.Nil(t, err)
+
+		oldUUID := uuid.New()
+		l.session = &actions.RunnerScaleSetSession{
+			SessionId:      &oldUUID,
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- l.refreshSession(ctx)
+		}()
+
+		require.Eventually(t, fakeClock.HasWaiters, time.Second, 10*time.Millisecond)
+		fakeClock.Step(30 * time.Second)
+
+		require.NoError(t, <-errCh)
+		assert.Equal(t, session, l.session)
+	})
+
+	t.Run("RefreshesOnRequest", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := context.Background()
+		config := Config{
+			ScaleSetID: 1,
+			Metrics:    metrics.Discard,
+		}
+
+		client := listenermocks.NewClient(t)
+
+		newUUID := uuid.New()
+		session := &actions.RunnerScaleSetSession{
+			SessionId:      &newUUID,
+			OwnerName:      "example",
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+		}
+		client.On("RefreshMessageSession", ctx, mock.Anything, mock.Anything).Return(session, nil).Once()
+
+		config.Client = client
+
+		l, err := New(config)
+		require.Nil(t, err)
+
+		oldUUID := uuid.New()
+		l.session = &actions.RunnerScaleSetSession{
+			SessionId:      &oldUUID,
+			RunnerScaleSet: &actions.RunnerScaleSet{},
+		}
+		l.lastMessageID = 5
+		assert.Nil(t, l.Session(), "the session is published once established")
+
+		require.NoError(t, l.refreshSessionIfRequested(ctx))
+		client.AssertNotCalled(t, "RefreshMessageSession", ctx, mock.Anything, mock.Anything)
+
+		l.RequestSessionRefresh()
+		require.NoError(t, l.refreshSessionIfRequested(ctx))
+		assert.Equal(t, session, l.session)
+		assert.Equal(t, &SessionInfo{
+			ID:            newUUID.String(),
+			OwnerName:     "example",
+			CreatedAt:     l.sessionCreatedAt,
+			LastMessageID: 5,
+		}, l.Session())
+
+		require.NoError(t, l.refreshSessionIfRequested(ctx), "the session is refreshed once per request")
+	})
+}
+
+// messageQueueToken returns a message queue token expiring at expiresAt.
+func messageQueueToken(t *testing.T, expiresAt time.Time) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(expiresAt)}).SignedString([]byte("key"))
+	require.NoError(t, err)
+	return token
+}
+
+func TestListener_refreshSessionBeforeExpiry(t *testing.T) {
+	t.Parallel()
+
+	newSession := func(token string) *actions.RunnerScaleSetSession {
+		id := uuid.New()
+		return &actions.RunnerScaleSetSession{
+			SessionId:               &id,
+			RunnerScaleSet:          &actions.RunnerScaleSet{},
+			MessageQueueAccessToken: token,
+		}
+	}
+
+	t.Run("RefreshesBeforeExpiry", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		fakeClock := clocktesting.NewFakeClock(time.Now().Truncate(time.Second))
+
+		refreshed := newSession(messageQueueToken(t, fakeClock.Now().Add(time.Hour)))
+		client := listenermocks.NewClient(t)
+		client.On("RefreshMessageSession", ctx, 1, mock.Anything).Return(refreshed, nil).Once()
+
+		l, err := New(Config{Client: client, ScaleSetID: 1, Metrics: metrics.Discard, Clock: fakeClock})
+		require.NoError(t, err)
+		l.setSession(newSession(messageQueueToken(t, fakeClock.Now().Add(10*time.Minute))))
+
+		l.refreshSessionBeforeExpiry(ctx)
+		assert.NotEqual(t, refreshed, l.session, "the session is not refreshed before the refresh margin")
+
+		fakeClock.Step(6 * time.Minute)
+		l.refreshSessionBeforeExpiry(ctx)
+		assert.Equal(t, refreshed, l.session)
+		assert.Equal(t, fakeClock.Now().Add(54*time.Minute), l.sessionExpiresAt)
+		require.NotNil(t, l.Session().ExpiresAt)
+		assert.Equal(t, l.sessionExpiresAt, *l.Session().ExpiresAt)
+	})
+
+	t.Run("RecordsFailures", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+
+		client := listenermocks.NewClient(t)
+		client.On("RefreshMessageSession", ctx, 1, mock.Anything).Return(nil, &actions.ActionsError{StatusCode: http.StatusServiceUnavailable}).Twice()
+
+		publisher := metricsmocks.NewPublisher(t)
+		publisher.On("PublishStatic", mock.Anything, mock.Anything).Once()
+		publisher.On("PublishSessionRefreshFailure").Twice()
+
+		l, err := New(Config{Client: client, ScaleSetID: 1, Metrics: publisher, Clock: fakeClock})
+		require.NoError(t, err)
+		session := newSession(messageQueueToken(t, fakeClock.Now().Add(time.Minute)))
+		l.setSession(session)
+
+		l.refreshSessionBeforeExpiry(ctx)
+		l.refreshSessionBeforeExpiry(ctx)
+		fakeClock.Step(sessionRetryInterval)
+		l.refreshSessionBeforeExpiry(ctx)
+
+		assert.Equal(t, session, l.session, "the session is kept until its token is rejected")
+	})
+
+	t.Run("UnknownExpiry", func(t *testing.T) {
+		t.Parallel()
+
+		l, err := New(Config{Client: listenermocks.NewClient(t), ScaleSetID: 1, Metrics: metrics.Discard})
+		require.NoError(t, err)
+		l.setSession(newSession("1234567890"))
+
+		assert.True(t, l.sessionExpiresAt.IsZero())
+		l.refreshSessionBeforeExpiry(context.Background())
+	})
 }
 
 func TestListener_deleteLastMessage(t *testing.T) {
@@ -509,6 +1014,7 @@ func TestListener_Listen(t *testing.T) {
 			Statistics:              &actions.RunnerScaleSetStatistic{},
 		}
 		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(session, nil).Once()
+		client.On("GetAcquirableJobs", ctx, 1).Return(&actions.AcquirableJobList{}, nil).Once()
 		client.On("DeleteMessageSession", mock.Anything, session.RunnerScaleSet.Id, session.SessionId).Return(nil).Once()
 
 		config.Client = client
@@ -518,6 +1024,7 @@ func TestListener_Listen(t *testing.T) {
 
 		var called bool
 		handler := listenermocks.NewHandler(t)
+		handler.On("HandleSessionCreated", mock.Anything, session, mock.Anything).Return(nil).Once()
 		handler.On("HandleDesiredRunnerCount", mock.Anything, mock.Anything, 0).
 			Return(0, nil).
 			Run(
@@ -527,6 +1034,8 @@ func TestListener_Listen(t *testing.T) {
 				},
 			).
 			Once()
+		// Final desired runner count patch while draining.
+		handler.On("HandleDesiredRunnerCount", mock.Anything, 0, 0).Return(0, nil).Once()
 
 		err = l.Listen(ctx, handler)
 		assert.True(t, errors.Is(err, context.Canceled))
@@ -555,6 +1064,7 @@ func TestListener_Listen(t *testing.T) {
 			Statistics:              &actions.RunnerScaleSetStatistic{},
 		}
 		client.On("CreateMessageSession", ctx, mock.Anything, mock.Anything).Return(session, nil).Once()
+		client.On("GetAcquirableJobs", ctx, 1).Return(&actions.AcquirableJobList{}, nil).Once()
 		client.On("DeleteMessageSession", mock.Anything, session.RunnerScaleSet.Id, session.SessionId).Return(nil).Once()
 
 		msg := &actions.RunnerScaleSetMessage{
@@ -572,11 +1082,13 @@ func TestListener_Listen(t *testing.T) {
 			Once()
 
 		// Ensure delete message is called without cancel
-		client.On("DeleteMessage", context.WithoutCancel(ctx), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+		client.On("DeleteMessage", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil }), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 
 		config.Client = client
 
 		handler := listenermocks.NewHandler(t)
+		// Failing to record the session does not stop the listener.
+		handler.On("HandleSessionCreated", mock.Anything, session, mock.Anything).Return(assert.AnError).Once()
 		handler.On("HandleDesiredRunnerCount", mock.Anything, mock.Anything, 0).
 			Return(0, nil).
 			Once()
@@ -585,6 +1097,11 @@ func TestListener_Listen(t *testing.T) {
 			Return(0, nil).
 			Once()
 
+		// Final desired runner count patch while draining.
+		handler.On("HandleDesiredRunnerCount", mock.Anything, 0, 0).
+			Return(0, nil).
+			Once()
+
 		l, err := New(config)
 		require.Nil(t, err)
 
@@ -593,6 +1110,49 @@ func TestListener_Listen(t *testing.T) {
 	})
 }
 
+func TestListener_acquireStartupJobs(t *testing.T) {
+	t.Parallel()
+
+	newListener := func(t *testing.T, client *listenermocks.Client) *Listener {
+		l, err := New(Config{ScaleSetID: 1, Client: client, Metrics: metrics.Discard})
+		require.NoError(t, err)
+		l.session = &actions.RunnerScaleSetSession{MessageQueueAccessToken: "token", RunnerScaleSet: &actions.RunnerScaleSet{}}
+		return l
+	}
+
+	t.Run("AcquiresAvailableJobs", func(t *testing.T) {
+		t.Parallel()
+		client := listenermocks.NewClient(t)
+		client.On("GetAcquirableJobs", mock.Anything, 1).Return(&actions.AcquirableJobList{
+			Count: 2,
+			Jobs:  []actions.AcquirableJob{{RunnerRequestId: 1}, {RunnerRequestId: 2}},
+		}, nil).Once()
+		client.On("AcquireJobs", mock.Anything, 1, "token", []int64{1, 2}).Return([]int64{1, 2}, nil).Once()
+
+		assert.Equal(t, 2, newListener(t, client).acquireStartupJobs(context.Background()))
+	})
+
+	t.Run("NoAvailableJobs", func(t *testing.T) {
+		t.Parallel()
+		client := listenermocks.NewClient(t)
+		client.On("GetAcquirableJobs", mock.Anything, 1).Return(&actions.AcquirableJobList{}, nil).Once()
+
+		assert.Equal(t, 0, newListener(t, client).acquireStartupJobs(context.Background()))
+	})
+
+	t.Run("IgnoresErrors", func(t *testing.T) {
+		t.Parallel()
+		client := listenermocks.NewClient(t)
+		client.On("GetAcquirableJobs", mock.Anything, 1).Return(&actions.AcquirableJobList{
+			Count: 1,
+			Jobs:  []actions.AcquirableJob{{RunnerRequestId: 1}},
+		}, nil).Once()
+		client.On("AcquireJobs", mock.Anything, 1, "token", []int64{1}).Return(nil, assert.AnError).Once()
+
+		assert.Equal(t, 0, newListener(t, client).acquireStartupJobs(context.Background()))
+	})
+}
+
 func TestListener_acquireAvailableJobs(t *testing.T) {
 	t.Parallel()
 
@@ -964,7 +1524,340 @@ func TestListener_parseMessage(t *testing.T) {
 
 		assert.Equal(t, msg.Statistics, parsedMsg.statistics)
 		assert.Equal(t, jobsAvailable, parsedMsg.jobsAvailable)
+		assert.Equal(t, jobsAssigned, parsedMsg.jobsAssigned)
 		assert.Equal(t, jobsStarted, parsedMsg.jobsStarted)
 		assert.Equal(t, jobsCompleted, parsedMsg.jobsCompleted)
 	})
+
+	t.Run("QuarantinesInvalidJobMessages", func(t *testing.T) {
+		jobStarted := &actions.JobStarted{
+			JobMessageBase: actions.JobMessageBase{
+				JobMessageType:  actions.JobMessageType{MessageType: messageTypeJobStarted},
+				RunnerRequestID: 1,
+			},
+			RunnerName: "runner1",
+		}
+		// The runner name is missing, the patch would target no ephemeral runner.
+		jobStartedWithoutRunner := &actions.JobStarted{
+			JobMessageBase: actions.JobMessageBase{
+				JobMessageType:  actions.JobMessageType{MessageType: messageTypeJobStarted},
+				RunnerRequestID: 2,
+			},
+		}
+		// The request ID is missing, the job would not be tracked on the runner.
+		jobCompletedWithoutRequest := &actions.JobCompleted{
+			JobMessageBase: actions.JobMessageBase{
+				JobMessageType: actions.JobMessageType{MessageType: messageTypeJobCompleted},
+				JobID:          "job",
+			},
+			RunnerName: "runner3",
+		}
+		// Jobs cancelled before they started are completed without a runner.
+		jobCompletedWithoutRunner := &actions.JobCompleted{
+			JobMessageBase: actions.JobMessageBase{
+				JobMessageType:  actions.JobMessageType{MessageType: messageTypeJobCompleted},
+				RunnerRequestID: 4,
+			},
+			Result: "canceled",
+		}
+
+		b, err := json.Marshal([]any{jobStarted, jobStartedWithoutRunner, jobCompletedWithoutRequest, jobCompletedWithoutRunner})
+		require.NoError(t, err)
+		msg := &actions.RunnerScaleSetMessage{
+			MessageId:   7,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{},
+			Body:        string(b),
+		}
+
+		publisher := metricsmocks.NewPublisher(t)
+		publisher.On("PublishQuarantinedMessage", messageTypeJobStarted).Once()
+		publisher.On("PublishQuarantinedMessage", messageTypeJobCompleted).Once()
+
+		var deadLetters bytes.Buffer
+		l := &Listener{
+			metrics:    publisher,
+			clock:      clocktesting.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+			deadLetter: &deadLetters,
+		}
+		parsedMsg, err := l.parseMessage(context.Background(), msg)
+		require.NoError(t, err)
+
+		assert.Equal(t, []*actions.JobStarted{jobStarted}, parsedMsg.jobsStarted)
+		assert.Equal(t, []*actions.JobCompleted{jobCompletedWithoutRunner}, parsedMsg.jobsCompleted)
+
+		decoder := json.NewDecoder(&deadLetters)
+		var records []deadLetter
+		for decoder.More() {
+			var record deadLetter
+			require.NoError(t, decoder.Decode(&record))
+			records = append(records, record)
+		}
+		require.Len(t, records, 2)
+
+		assert.Equal(t, int64(7), records[0].MessageID)
+		assert.Equal(t, messageTypeJobStarted, records[0].MessageType)
+		assert.Equal(t, "runnerName is empty", records[0].Reason)
+		var quarantinedStarted actions.JobStarted
+		require.NoError(t, json.Unmarshal(records[0].Message, &quarantinedStarted))
+		assert.Equal(t, jobStartedWithoutRunner, &quarantinedStarted)
+
+		assert.Equal(t, messageTypeJobCompleted, records[1].MessageType)
+		assert.Equal(t, "runnerRequestId 0 is not positive", records[1].Reason)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), records[1].Time)
+	})
+}
+
+func TestListener_handleMessageAudit(t *testing.T) {
+	t.Parallel()
+
+	jobStarted := &actions.JobStarted{
+		JobMessageBase: actions.JobMessageBase{
+			JobMessageType:  actions.JobMessageType{MessageType: messageTypeJobStarted},
+			RunnerRequestID: 1,
+			JobID:           "1",
+			RepositoryName:  "repo",
+			OwnerName:       "owner",
+			JobWorkflowRef:  "owner/repo/.github/workflows/ci.yaml@refs/heads/main",
+		},
+		RunnerID:   1,
+		RunnerName: "runner1",
+	}
+	jobCompleted := &actions.JobCompleted{
+		JobMessageBase: actions.JobMessageBase{
+			JobMessageType:  actions.JobMessageType{MessageType: messageTypeJobCompleted},
+			RunnerRequestID: 2,
+			JobID:           "2",
+		},
+		Result:     "succeeded",
+		RunnerId:   2,
+		RunnerName: "runner2",
+	}
+	body, err := json.Marshal([]any{jobStarted, jobCompleted})
+	require.NoError(t, err)
+	msg := &actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Body:        string(body),
+		Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 3},
+	}
+
+	handler := listenermocks.NewHandler(t)
+	handler.On("HandleJobStarted", mock.Anything, mock.Anything).Return(nil).Once()
+	handler.On("HandleJobCompleted", mock.Anything, mock.Anything).Return(nil).Once()
+	handler.On("HandleDesiredRunnerCount", mock.Anything, 3, 1).Return(4, nil).Once()
+
+	client := listenermocks.NewClient(t)
+	client.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+	var records bytes.Buffer
+	l, err := New(Config{
+		Client:     client,
+		ScaleSetID: 1,
+		Metrics:    metrics.Discard,
+		Audit:      audit.New(audit.Config{Sink: audit.NewWriterSink(&records)}),
+	})
+	require.NoError(t, err)
+	l.session = &actions.RunnerScaleSetSession{RunnerScaleSet: &actions.RunnerScaleSet{}}
+
+	require.NoError(t, l.handleMessage(context.Background(), handler, msg))
+
+	count, err := audit.Verify(bytes.NewReader(records.Bytes()), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	decoder := json.NewDecoder(&records)
+	var events []audit.Record
+	for decoder.More() {
+		var record audit.Record
+		require.NoError(t, decoder.Decode(&record))
+		events = append(events, record)
+	}
+	require.Len(t, events, 3)
+	assert.Equal(t, audit.EventJobCompleted, events[0].Event)
+	assert.Equal(t, "runner2", events[0].Job.RunnerName)
+	assert.Equal(t, "succeeded", events[0].Job.Result)
+	assert.Equal(t, audit.EventJobStarted, events[1].Event)
+	assert.Equal(t, "runner1", events[1].Job.RunnerName)
+	assert.Equal(t, jobStarted.JobWorkflowRef, events[1].Job.WorkflowRef)
+	assert.Equal(t, audit.EventDesiredRunnerCount, events[2].Event)
+	assert.Equal(t, &audit.DesiredRunners{AssignedJobs: 3, JobsCompleted: 1, Count: 4}, events[2].DesiredRunners)
+}
+
+func TestListener_drain(t *testing.T) {
+	t.Parallel()
+
+	newListener := func(t *testing.T, client *listenermocks.Client) *Listener {
+		l, err := New(Config{
+			Client:       client,
+			ScaleSetID:   1,
+			Metrics:      metrics.Discard,
+			DrainTimeout: time.Minute,
+		})
+		require.NoError(t, err)
+
+		uuid := uuid.New()
+		l.session = &actions.RunnerScaleSetSession{
+			SessionId:               &uuid,
+			RunnerScaleSet:          &actions.RunnerScaleSet{Id: 1},
+			MessageQueueUrl:         "https://example.com",
+			MessageQueueAccessToken: "1234567890",
+			Statistics:              &actions.RunnerScaleSetStatistic{},
+		}
+		return l
+	}
+
+	notDone := mock.MatchedBy(func(ctx context.Context) bool {
+		_, hasDeadline := ctx.Deadline()
+		return ctx.Err() == nil && hasDeadline
+	})
+
+	t.Run("FlushesDesiredRunnerCountWhenCancelled", func(t *testing.T) {
+		t.Parallel()
+
+		client := listenermocks.NewClient(t)
+		l := newListener(t, client)
+		client.On("DeleteMessageSession", notDone, 1, l.session.SessionId).Return(nil).Once()
+
+		handler := listenermocks.NewHandler(t)
+		handler.On("HandleDesiredRunnerCount", notDone, 0, 0).Return(0, nil).Once()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		l.drain(ctx, handler)
+		assert.True(t, l.draining)
+	})
+
+	t.Run("OnlyDeletesSessionWhenNotCancelled", func(t *testing.T) {
+		t.Parallel()
+
+		client := listenermocks.NewClient(t)
+		l := newListener(t, client)
+		client.On("DeleteMessageSession", notDone, 1, l.session.SessionId).Return(nil).Once()
+
+		l.drain(context.Background(), listenermocks.NewHandler(t))
+		assert.False(t, l.draining)
+	})
+
+	t.Run("SkipsAcquiringJobsWhileDraining", func(t *testing.T) {
+		t.Parallel()
+
+		client := listenermocks.NewClient(t)
+		l := newListener(t, client)
+		l.draining = true
+		client.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything, int64(1)).Return(nil).Once()
+
+		handler := listenermocks.NewHandler(t)
+		handler.On("HandleDesiredRunnerCount", mock.Anything, 0, 0).Return(0, nil).Once()
+
+		body, err := json.Marshal([]*actions.JobAvailable{
+			{
+				JobMessageBase: actions.JobMessageBase{
+					JobMessageType:  actions.JobMessageType{MessageType: messageTypeJobAvailable},
+					RunnerRequestID: 1,
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		err = l.handleMessage(context.Background(), handler, &actions.RunnerScaleSetMessage{
+			MessageId:   1,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{},
+			Body:        string(body),
+		})
+		require.NoError(t, err)
+	})
+}
+
+// inlineExecutor runs the functions right away.
+type inlineExecutor struct{}
+
+func (inlineExecutor) Go(fn func()) {
+	fn()
+}
+
+func TestListener_handleJobsStarted(t *testing.T) {
+	t.Parallel()
+
+	l, err := New(Config{
+		Client:     listenermocks.NewClient(t),
+		ScaleSetID: 1,
+		Metrics:    metrics.Discard,
+		Executor:   inlineExecutor{},
+	})
+	require.NoError(t, err)
+
+	jobsStarted := []*actions.JobStarted{
+		{RunnerID: 1, JobMessageBase: actions.JobMessageBase{RunnerRequestID: 1}},
+		{RunnerID: 2, JobMessageBase: actions.JobMessageBase{RunnerRequestID: 2}},
+	}
+
+	t.Run("RunsOnExecutor", func(t *testing.T) {
+		handler := listenermocks.NewHandler(t)
+		handler.On("HandleJobStarted", mock.Anything, jobsStarted[0]).Return(nil).Once()
+		handler.On("HandleJobStarted", mock.Anything, jobsStarted[1]).Return(errors.New("patch failed")).Once()
+
+		err := l.handleJobsStarted(context.Background(), handler, jobsStarted)
+		assert.ErrorContains(t, err, "failed to handle job started: patch failed")
+	})
+
+	t.Run("FailsOnPanic", func(t *testing.T) {
+		handler := listenermocks.NewHandler(t)
+		handler.On("HandleJobStarted", mock.Anything, jobsStarted[0]).Return(nil).Once()
+		handler.On("HandleJobStarted", mock.Anything, jobsStarted[1]).Run(func(mock.Arguments) {
+			panic("boom")
+		}).Return(nil).Once()
+
+		err := l.handleJobsStarted(context.Background(), handler, jobsStarted)
+		var panicErr *recovery.PanicError
+		assert.ErrorAs(t, err, &panicErr)
+	})
+}
+
+func TestListener_handleMessageRecoversHandlerPanics(t *testing.T) {
+	t.Parallel()
+
+	client := listenermocks.NewClient(t)
+	l, err := New(Config{
+		Client:     client,
+		ScaleSetID: 1,
+		Metrics:    metrics.Discard,
+	})
+	require.NoError(t, err)
+	uuid := uuid.New()
+	l.session = &actions.RunnerScaleSetSession{
+		SessionId:               &uuid,
+		RunnerScaleSet:          &actions.RunnerScaleSet{Id: 1},
+		MessageQueueUrl:         "https://example.com",
+		MessageQueueAccessToken: "1234567890",
+		Statistics:              &actions.RunnerScaleSetStatistic{},
+	}
+	client.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything, int64(1)).Return(nil).Once()
+
+	handler := listenermocks.NewHandler(t)
+	handler.On("HandleDesiredRunnerCount", mock.Anything, 0, 0).Run(func(mock.Arguments) {
+		panic("desired runner count")
+	}).Return(0, nil).Once()
+
+	err = l.handleMessage(context.Background(), handler, &actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Statistics:  &actions.RunnerScaleSetStatistic{},
+		Body:        "[]",
+	})
+	var panicErr *recovery.PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "desired runner count", panicErr.Value)
+}
+
+func TestSessionStartupBackoff(t *testing.T) {
+	t.Parallel()
+	for failures, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 6: sessionRetryInterval, 100: sessionRetryInterval} {
+		for range 20 {
+			backoff := sessionStartupBackoff(failures)
+			assert.GreaterOrEqual(t, backoff, want/2, "failures %d", failures)
+			assert.LessOrEqual(t, backoff, want, "failures %d", failures)
+		}
+	}
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	listenermocks "github.com/actions/actions-runner-controller/cmd/ghalistener/listener/mocks"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
 	metricsmocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/google/uuid"
@@ -67,6 +69,7 @@ func TestInitialMetrics(t *testing.T) {
 		metrics := metricsmocks.NewPublisher(t)
 		metrics.On("PublishStatic", mock.Anything, mock.Anything).Once()
 		metrics.On("PublishStatistics", sessionStatistics).Once()
+		metrics.On("PublishSession", session, mock.Anything).Once()
 		metrics.On("PublishDesiredRunners", sessionStatistics.TotalAssignedJobs).
 			Run(
 				func(mock.Arguments) {
@@ -82,13 +85,19 @@ func TestInitialMetrics(t *testing.T) {
 
 		client := listenermocks.NewClient(t)
 		client.On("CreateMessageSession", mock.Anything, mock.Anything, mock.Anything).Return(session, nil).Once()
+		client.On("GetAcquirableJobs", mock.Anything, 1).Return(&actions.AcquirableJobList{}, nil).Once()
 		client.On("DeleteMessageSession", mock.Anything, session.RunnerScaleSet.Id, session.SessionId).Return(nil).Once()
 		config.Client = client
 
 		handler := listenermocks.NewHandler(t)
+		handler.On("HandleSessionCreated", mock.Anything, session, mock.Anything).Return(nil).Once()
 		handler.On("HandleDesiredRunnerCount", mock.Anything, sessionStatistics.TotalAssignedJobs, 0).
 			Return(sessionStatistics.TotalAssignedJobs, nil).
 			Once()
+		// Final desired runner count patch while draining.
+		handler.On("HandleDesiredRunnerCount", mock.Anything, 0, 0).
+			Return(sessionStatistics.TotalAssignedJobs, nil).
+			Once()
 
 		l, err := New(config)
 		assert.Nil(t, err)
@@ -133,6 +142,20 @@ func TestHandleMessageMetrics(t *testing.T) {
 		batchedMessages = append(batchedMessages, msg)
 	}
 
+	jobsAssigned := []*actions.JobAssigned{
+		{
+			JobMessageBase: actions.JobMessageBase{
+				JobMessageType: actions.JobMessageType{
+					MessageType: messageTypeJobAssigned,
+				},
+				RunnerRequestID: 9,
+			},
+		},
+	}
+	for _, msg := range jobsAssigned {
+		batchedMessages = append(batchedMessages, msg)
+	}
+
 	jobsCompleted := []*actions.JobCompleted{
 		{
 			JobMessageBase: actions.JobMessageBase{
@@ -171,13 +194,20 @@ func TestHandleMessageMetrics(t *testing.T) {
 	metrics := metricsmocks.NewPublisher(t)
 	metrics.On("PublishStatic", 0, 0).Once()
 	metrics.On("PublishStatistics", msg.Statistics).Once()
+	metrics.On("PublishJobAssigned", jobsAssigned[0]).Once()
 	metrics.On("PublishJobCompleted", jobsCompleted[0]).Once()
 	metrics.On("PublishJobCompleted", jobsCompleted[1]).Once()
 	metrics.On("PublishJobStarted", jobsStarted[0]).Once()
 	metrics.On("PublishDesiredRunners", desiredResult).Once()
+	metrics.On("PublishMessageProcessingDuration", messageTypeJobStarted, mock.Anything).Once()
+	metrics.On("PublishMessageProcessingDuration", messageTypeJobCompleted, mock.Anything).Twice()
+	metrics.On("PublishMessageProcessingDuration", processingTypeDesiredRunnerCount, mock.Anything).Once()
 
 	handler := listenermocks.NewHandler(t)
 	handler.On("HandleJobStarted", mock.Anything, jobsStarted[0]).Return(nil).Once()
+	handler.On("HandleJobAssigned", mock.Anything, jobsAssigned[0]).Return(nil).Once()
+	handler.On("HandleJobCompleted", mock.Anything, jobsCompleted[0]).Return(nil).Once()
+	handler.On("HandleJobCompleted", mock.Anything, jobsCompleted[1]).Return(nil).Once()
 	handler.On("HandleDesiredRunnerCount", mock.Anything, mock.Anything, 2).Return(desiredResult, nil).Once()
 
 	client := listenermocks.NewClient(t)
@@ -203,3 +233,74 @@ func TestHandleMessageMetrics(t *testing.T) {
 	err = l.handleMessage(context.Background(), handler, msg)
 	require.NoError(t, err)
 }
+
+func TestHandleMessage_DesiredRunnerCountNotBlockedByJobStarted(t *testing.T) {
+	t.Parallel()
+
+	jobsStarted := []*actions.JobStarted{
+		{
+			JobMessageBase: actions.JobMessageBase{
+				JobMessageType: actions.JobMessageType{
+					MessageType: messageTypeJobStarted,
+				},
+				RunnerRequestID: 1,
+			},
+			RunnerName: "runner1",
+		},
+		{
+			JobMessageBase: actions.JobMessageBase{
+				JobMessageType: actions.JobMessageType{
+					MessageType: messageTypeJobStarted,
+				},
+				RunnerRequestID: 2,
+			},
+			RunnerName: "runner2",
+		},
+	}
+
+	b, err := json.Marshal(jobsStarted)
+	require.NoError(t, err)
+
+	msg := &actions.RunnerScaleSetMessage{
+		MessageId:   1,
+		MessageType: "RunnerScaleSetJobMessages",
+		Body:        string(b),
+		Statistics: &actions.RunnerScaleSetStatistic{
+			TotalAssignedJobs: 2,
+		},
+	}
+
+	desiredHandled := make(chan struct{})
+
+	handler := listenermocks.NewHandler(t)
+	handler.On("HandleJobStarted", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		select {
+		case <-desiredHandled:
+		case <-time.After(5 * time.Second):
+			t.Error("desired runner count was not handled while job started was in progress")
+		}
+	}).Return(nil).Twice()
+	handler.On("HandleDesiredRunnerCount", mock.Anything, 2, 0).Run(func(mock.Arguments) {
+		close(desiredHandled)
+	}).Return(2, nil).Once()
+
+	client := listenermocks.NewClient(t)
+	client.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+	config := Config{
+		Client:             client,
+		ScaleSetID:         1,
+		Metrics:            metrics.Discard,
+		MessageConcurrency: 1,
+	}
+
+	l, err := New(config)
+	require.NoError(t, err)
+	l.session = &actions.RunnerScaleSetSession{
+		RunnerScaleSet: &actions.RunnerScaleSet{},
+		Statistics:     &actions.RunnerScaleSetStatistic{},
+	}
+
+	err = l.handleMessage(context.Background(), handler, msg)
+	require.NoError(t, err)
+}
@@ -0,0 +1,123 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+)
+
+// MisroutedJobPolicy is how the jobs requiring labels the scale set does not provide are handled.
+type MisroutedJobPolicy string
+
+const (
+	// MisroutedJobProvision acquires the misrouted jobs and provisions runners for them, like for the other jobs.
+	MisroutedJobProvision MisroutedJobPolicy = "provision"
+	// MisroutedJobExclude does not acquire the misrouted jobs, and excludes the misrouted jobs assigned
+	// to the scale set from the desired runner count, since no runner of the scale set can run them.
+	MisroutedJobExclude MisroutedJobPolicy = "exclude"
+)
+
+// implicitLabels are the labels the runners of every scale set provide, besides the labels of the scale set.
+var implicitLabels = []string{"self-hosted"}
+
+func (p MisroutedJobPolicy) validate() error {
+	switch p {
+	case "", MisroutedJobProvision, MisroutedJobExclude:
+		return nil
+	default:
+		return fmt.Errorf("unknown misrouted job policy %q", p)
+	}
+}
+
+//go:generate mockery --name MisroutedJobRecorder --output ./mocks --outpkg mocks --case underscore
+type MisroutedJobRecorder interface {
+	// RecordMisroutedJob records the job requiring the missing labels, which the scale set does not provide.
+	RecordMisroutedJob(ctx context.Context, job *actions.JobMessageBase, missingLabels []string)
+}
+
+// missingLabels returns the labels requested by the job which the scale set does not provide.
+// The labels are compared case-insensitively, as GitHub does. Nothing is missing while the labels
+// of the scale set are unknown.
+func (l *Listener) missingLabels(requested []string) []string {
+	if l.session == nil || l.session.RunnerScaleSet == nil || len(l.session.RunnerScaleSet.Labels) == 0 {
+		return nil
+	}
+	provided := l.session.RunnerScaleSet.Labels
+
+	var missing []string
+	for _, label := range requested {
+		found := false
+		for _, implicit := range implicitLabels {
+			found = found || strings.EqualFold(label, implicit)
+		}
+		for _, p := range provided {
+			found = found || strings.EqualFold(label, p.Name)
+		}
+		if !found {
+			missing = append(missing, label)
+		}
+	}
+	return missing
+}
+
+// misrouted reports whether the job requires labels the scale set does not provide,
+// in which case it is counted and recorded.
+func (l *Listener) misrouted(ctx context.Context, job *actions.JobMessageBase) bool {
+	missing := l.missingLabels(job.RequestLabels)
+	if len(missing) == 0 {
+		return false
+	}
+
+	l.metrics.PublishMisroutedJob(string(l.misroutedJobPolicy))
+	l.logger.Info("Job requires labels the scale set does not provide",
+		"jobId", job.JobID,
+		"runnerRequestId", job.RunnerRequestID,
+		"workflow", job.JobWorkflowRef,
+		"missingLabels", fmt.Sprint(missing),
+		"policy", string(l.misroutedJobPolicy),
+	)
+	if l.misroutedJobs != nil {
+		l.misroutedJobs.RecordMisroutedJob(ctx, job, missing)
+	}
+	return true
+}
+
+// excludeMisroutedJobs returns the jobs available to acquire, without the misrouted ones with MisroutedJobExclude.
+// The misrouted jobs are counted and recorded with every policy.
+func (l *Listener) excludeMisroutedJobs(ctx context.Context, jobsAvailable []*actions.JobAvailable) []*actions.JobAvailable {
+	kept := jobsAvailable[:0:0]
+	for _, job := range jobsAvailable {
+		if l.misrouted(ctx, &job.JobMessageBase) && l.misroutedJobPolicy == MisroutedJobExclude {
+			continue
+		}
+		kept = append(kept, job)
+	}
+	return kept
+}
+
+// trackMisroutedJobs tracks the misrouted jobs assigned to the scale set with MisroutedJobExclude, which are
+// excluded from the desired runner count until they start or complete. They are assigned when acquired before,
+// e.g. by a listener with another policy.
+func (l *Listener) trackMisroutedJobs(ctx context.Context, msg *parsedMessage) {
+	if l.misroutedJobPolicy != MisroutedJobExclude {
+		return
+	}
+	for _, job := range msg.jobsAssigned {
+		if l.misrouted(ctx, &job.JobMessageBase) {
+			l.excludedJobs[job.RunnerRequestID] = struct{}{}
+		}
+	}
+	for _, job := range msg.jobsStarted {
+		delete(l.excludedJobs, job.RunnerRequestID)
+	}
+	for _, job := range msg.jobsCompleted {
+		delete(l.excludedJobs, job.RunnerRequestID)
+	}
+}
+
+// assignedJobs returns the jobs assigned to the scale set which its runners can run.
+func (l *Listener) assignedJobs(stats *actions.RunnerScaleSetStatistic) int {
+	return max(stats.TotalAssignedJobs-len(l.excludedJobs), 0)
+}
@@ -0,0 +1,125 @@
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	listenermocks "github.com/actions/actions-runner-controller/cmd/ghalistener/listener/mocks"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListener_missingLabels(t *testing.T) {
+	l := &Listener{session: &actions.RunnerScaleSetSession{
+		RunnerScaleSet: &actions.RunnerScaleSet{Labels: []actions.Label{{Type: "System", Name: "arc-set"}}},
+	}}
+
+	assert.Empty(t, l.missingLabels([]string{"arc-set"}))
+	assert.Empty(t, l.missingLabels([]string{"Self-Hosted", "ARC-set"}), "the labels are compared case-insensitively")
+	assert.Equal(t, []string{"gpu"}, l.missingLabels([]string{"arc-set", "gpu"}))
+
+	l.session.RunnerScaleSet.Labels = nil
+	assert.Empty(t, l.missingLabels([]string{"gpu"}), "nothing is missing while the labels are unknown")
+}
+
+func TestListener_handleMessageMisroutedJobs(t *testing.T) {
+	t.Parallel()
+
+	body := func(t *testing.T, messages ...any) string {
+		b, err := json.Marshal(messages)
+		require.NoError(t, err)
+		return string(b)
+	}
+	job := func(messageType string, runnerRequestID int64, labels ...string) actions.JobMessageBase {
+		return actions.JobMessageBase{
+			JobMessageType:  actions.JobMessageType{MessageType: messageType},
+			RunnerRequestID: runnerRequestID,
+			JobWorkflowRef:  "org/repo/.github/workflows/ci.yaml@refs/heads/main",
+			RequestLabels:   labels,
+		}
+	}
+	newListener := func(t *testing.T, policy MisroutedJobPolicy, client *listenermocks.Client, recorder *listenermocks.MisroutedJobRecorder) *Listener {
+		l, err := New(Config{
+			ScaleSetID:         1,
+			Client:             client,
+			Metrics:            metrics.Discard,
+			MisroutedJobPolicy: policy,
+			MisroutedJobs:      recorder,
+		})
+		require.NoError(t, err)
+		l.session = &actions.RunnerScaleSetSession{
+			MessageQueueUrl:         "https://example.com",
+			MessageQueueAccessToken: "token",
+			RunnerScaleSet:          &actions.RunnerScaleSet{Labels: []actions.Label{{Type: "System", Name: "arc-set"}}},
+		}
+		return l
+	}
+
+	t.Run("ProvisionsMisroutedJobs", func(t *testing.T) {
+		t.Parallel()
+
+		client := listenermocks.NewClient(t)
+		client.On("AcquireJobs", mock.Anything, 1, "token", []int64{1, 2}).Return([]int64{1, 2}, nil).Once()
+		client.On("DeleteMessage", mock.Anything, "https://example.com", "token", int64(1)).Return(nil).Once()
+		recorder := listenermocks.NewMisroutedJobRecorder(t)
+		recorder.On("RecordMisroutedJob", mock.Anything, mock.MatchedBy(func(job *actions.JobMessageBase) bool {
+			return job.RunnerRequestID == 2
+		}), []string{"gpu"}).Once()
+		handler := listenermocks.NewHandler(t)
+		handler.On("HandleDesiredRunnerCount", mock.Anything, 2, 0).Return(2, nil).Once()
+
+		l := newListener(t, "", client, recorder)
+		err := l.handleMessage(context.Background(), handler, &actions.RunnerScaleSetMessage{
+			MessageId:   1,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 2},
+			Body: body(t,
+				actions.JobAvailable{JobMessageBase: job(messageTypeJobAvailable, 1, "arc-set")},
+				actions.JobAvailable{JobMessageBase: job(messageTypeJobAvailable, 2, "arc-set", "gpu")},
+			),
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("ExcludesMisroutedJobs", func(t *testing.T) {
+		t.Parallel()
+
+		client := listenermocks.NewClient(t)
+		client.On("AcquireJobs", mock.Anything, 1, "token", []int64{1}).Return([]int64{1}, nil).Once()
+		client.On("DeleteMessage", mock.Anything, "https://example.com", "token", mock.Anything).Return(nil).Twice()
+		recorder := listenermocks.NewMisroutedJobRecorder(t)
+		recorder.On("RecordMisroutedJob", mock.Anything, mock.Anything, []string{"gpu"}).Twice()
+		handler := listenermocks.NewHandler(t)
+		handler.On("HandleJobAssigned", mock.Anything, mock.Anything).Return(nil).Once()
+		handler.On("HandleJobCompleted", mock.Anything, mock.Anything).Return(nil).Once()
+		handler.On("HandleDesiredRunnerCount", mock.Anything, 2, 0).Return(2, nil).Once()
+		handler.On("HandleDesiredRunnerCount", mock.Anything, 3, 1).Return(3, nil).Once()
+
+		l := newListener(t, MisroutedJobExclude, client, recorder)
+		err := l.handleMessage(context.Background(), handler, &actions.RunnerScaleSetMessage{
+			MessageId:   1,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 3},
+			Body: body(t,
+				actions.JobAvailable{JobMessageBase: job(messageTypeJobAvailable, 1, "arc-set")},
+				actions.JobAvailable{JobMessageBase: job(messageTypeJobAvailable, 2, "gpu")},
+				actions.JobAssigned{JobMessageBase: job(messageTypeJobAssigned, 3, "gpu")},
+			),
+		})
+		require.NoError(t, err, "the misrouted job assigned to the scale set is excluded from the desired runners")
+
+		err = l.handleMessage(context.Background(), handler, &actions.RunnerScaleSetMessage{
+			MessageId:   2,
+			MessageType: "RunnerScaleSetJobMessages",
+			Statistics:  &actions.RunnerScaleSetStatistic{TotalAssignedJobs: 3},
+			Body: body(t,
+				actions.JobCompleted{Result: "canceled", JobMessageBase: job(messageTypeJobCompleted, 3, "gpu")},
+			),
+		})
+		require.NoError(t, err, "the misrouted job is no longer excluded once completed")
+	})
+}
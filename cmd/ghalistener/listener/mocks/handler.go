@@ -8,6 +8,8 @@ import (
 	actions "github.com/actions/actions-runner-controller/github/actions"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Handler is an autogenerated mock type for the Handler type
@@ -39,6 +41,34 @@ func (_m *Handler) HandleDesiredRunnerCount(ctx context.Context, count int, jobs
 	return r0, r1
 }
 
+// HandleJobAssigned provides a mock function with given fields: ctx, jobInfo
+func (_m *Handler) HandleJobAssigned(ctx context.Context, jobInfo *actions.JobAssigned) error {
+	ret := _m.Called(ctx, jobInfo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *actions.JobAssigned) error); ok {
+		r0 = rf(ctx, jobInfo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// HandleJobCompleted provides a mock function with given fields: ctx, jobInfo
+func (_m *Handler) HandleJobCompleted(ctx context.Context, jobInfo *actions.JobCompleted) error {
+	ret := _m.Called(ctx, jobInfo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *actions.JobCompleted) error); ok {
+		r0 = rf(ctx, jobInfo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // HandleJobStarted provides a mock function with given fields: ctx, jobInfo
 func (_m *Handler) HandleJobStarted(ctx context.Context, jobInfo *actions.JobStarted) error {
 	ret := _m.Called(ctx, jobInfo)
@@ -53,6 +83,20 @@ func (_m *Handler) HandleJobStarted(ctx context.Context, jobInfo *actions.JobSta
 	return r0
 }
 
+// HandleSessionCreated provides a mock function with given fields: ctx, session, createdAt
+func (_m *Handler) HandleSessionCreated(ctx context.Context, session *actions.RunnerScaleSetSession, createdAt time.Time) error {
+	ret := _m.Called(ctx, session, createdAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *actions.RunnerScaleSetSession, time.Time) error); ok {
+		r0 = rf(ctx, session, createdAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewHandler creates a new instance of Handler. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewHandler(t interface {
@@ -0,0 +1,73 @@
+package listener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+)
+
+// validateJobStarted checks the fields the job started patch of the ephemeral runner is built from.
+func validateJobStarted(jobStarted *actions.JobStarted) error {
+	var errs []error
+	if jobStarted.RunnerName == "" {
+		errs = append(errs, errors.New("runnerName is empty"))
+	}
+	if jobStarted.RunnerID < 0 {
+		errs = append(errs, fmt.Errorf("runnerId %d is negative", jobStarted.RunnerID))
+	}
+	return errors.Join(append(errs, validateJobMessageBase(&jobStarted.JobMessageBase)...)...)
+}
+
+// validateJobCompleted checks the fields the job completed message is handled with.
+// The runner name is not required, jobs cancelled before they started are completed without a runner.
+func validateJobCompleted(jobCompleted *actions.JobCompleted) error {
+	var errs []error
+	if jobCompleted.RunnerId < 0 {
+		errs = append(errs, fmt.Errorf("runnerId %d is negative", jobCompleted.RunnerId))
+	}
+	return errors.Join(append(errs, validateJobMessageBase(&jobCompleted.JobMessageBase)...)...)
+}
+
+func validateJobMessageBase(base *actions.JobMessageBase) []error {
+	if base.RunnerRequestID <= 0 {
+		return []error{fmt.Errorf("runnerRequestId %d is not positive", base.RunnerRequestID)}
+	}
+	return nil
+}
+
+// deadLetter is the record of a quarantined job message written to the dead letter log.
+type deadLetter struct {
+	Time        time.Time       `json:"time"`
+	MessageID   int64           `json:"messageId"`
+	MessageType string          `json:"messageType"`
+	Reason      string          `json:"reason"`
+	Message     json.RawMessage `json:"message"`
+}
+
+// quarantine records a job message that failed validation, so it is not handled but can be inspected.
+// The message is logged, counted, and appended to the dead letter log if one is configured.
+func (l *Listener) quarantine(messageID int64, messageType string, msg json.RawMessage, reason error) {
+	l.logger.Error(reason, "Quarantining invalid job message", "messageId", messageID, "messageType", messageType)
+	l.metrics.PublishQuarantinedMessage(messageType)
+
+	if l.deadLetter == nil {
+		return
+	}
+	record, err := json.Marshal(&deadLetter{
+		Time:        l.clock.Now().UTC(),
+		MessageID:   messageID,
+		MessageType: messageType,
+		Reason:      reason.Error(),
+		Message:     msg,
+	})
+	if err != nil {
+		l.logger.Error(err, "Failed to marshal the quarantined job message", "messageId", messageID)
+		return
+	}
+	if _, err := l.deadLetter.Write(append(record, '\n')); err != nil {
+		l.logger.Error(err, "Failed to write the quarantined job message to the dead letter log", "messageId", messageID)
+	}
+}
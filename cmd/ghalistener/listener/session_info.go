@@ -0,0 +1,65 @@
+package listener
+
+import (
+	"context"
+	"time"
+)
+
+// SessionInfo describes the message session of the listener, for the admin API.
+type SessionInfo struct {
+	ID            string    `json:"id"`
+	OwnerName     string    `json:"ownerName"`
+	CreatedAt     time.Time `json:"createdAt"`
+	LastMessageID int64     `json:"lastMessageId"`
+	// ExpiresAt is the time the message queue token of the session expires at, if it is known.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// UnavailableSince is the time of the first failure to establish or refresh the session, while it is unavailable.
+	UnavailableSince *time.Time `json:"unavailableSince,omitempty"`
+}
+
+// Session returns the message session of the listener, or nil if no session is established.
+// It is safe to call while the listener runs.
+func (l *Listener) Session() *SessionInfo {
+	return l.sessionInfo.Load()
+}
+
+// RequestSessionRefresh refreshes the message session before the next message is fetched, e.g. to recover
+// from a message queue token GitHub stopped accepting without waiting for it to expire.
+// It is safe to call while the listener runs.
+func (l *Listener) RequestSessionRefresh() {
+	l.refreshRequested.Store(true)
+}
+
+// refreshSessionIfRequested must be called from the goroutine of Listen.
+func (l *Listener) refreshSessionIfRequested(ctx context.Context) error {
+	if !l.refreshRequested.Swap(false) {
+		return nil
+	}
+	l.logger.Info("Message session refresh requested")
+	return l.refreshSession(ctx)
+}
+
+// publishSessionInfo must be called from the goroutine of Listen, after the session changed.
+func (l *Listener) publishSessionInfo() {
+	if l.session == nil {
+		l.sessionInfo.Store(nil)
+		return
+	}
+	info := &SessionInfo{
+		OwnerName:     l.session.OwnerName,
+		CreatedAt:     l.sessionCreatedAt,
+		LastMessageID: l.lastMessageID,
+	}
+	if l.session.SessionId != nil {
+		info.ID = l.session.SessionId.String()
+	}
+	if !l.sessionExpiresAt.IsZero() {
+		expiresAt := l.sessionExpiresAt
+		info.ExpiresAt = &expiresAt
+	}
+	if !l.unavailableSince.IsZero() {
+		since := l.unavailableSince
+		info.UnavailableSince = &since
+	}
+	l.sessionInfo.Store(info)
+}
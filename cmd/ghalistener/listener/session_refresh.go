@@ -0,0 +1,60 @@
+package listener
+
+import (
+	"context"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// sessionRefreshMargin is the time before the message queue token expires at which the message session
+// is refreshed. It is longer than a long poll of the message queue, so the token does not expire while polling.
+const sessionRefreshMargin = 5 * time.Minute
+
+// messageQueueTokenExpiresAt returns the expiry of the message queue token of the session,
+// or the zero time if the token is not a JWT with an expiry.
+func messageQueueTokenExpiresAt(session *actions.RunnerScaleSetSession) time.Time {
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(session.MessageQueueAccessToken, &claims); err != nil || claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return claims.ExpiresAt.Time
+}
+
+// setSession must be called from the goroutine of Listen once the session is established or refreshed.
+func (l *Listener) setSession(session *actions.RunnerScaleSetSession) {
+	l.session = session
+	l.sessionExpiresAt = messageQueueTokenExpiresAt(session)
+	l.publishSessionInfo()
+}
+
+// publishSessionHeartbeat publishes the age of the session and the expiry of its message queue token before
+// every message is fetched, so that a session silently expiring shows up before the scaling stops.
+func (l *Listener) publishSessionHeartbeat() {
+	l.metrics.PublishSessionHeartbeat(l.clock.Since(l.sessionCreatedAt), l.sessionExpiresAt)
+}
+
+// refreshSessionBeforeExpiry refreshes the message session once its message queue token is about to expire,
+// rather than once GitHub rejects it. The token is still valid if the refresh fails, so the failure is only
+// recorded and the refresh attempted again after sessionRetryInterval. Once the token expired, the session
+// is refreshed as GitHub rejects it.
+func (l *Listener) refreshSessionBeforeExpiry(ctx context.Context) {
+	now := l.clock.Now()
+	if l.sessionExpiresAt.IsZero() || l.sessionExpiresAt.Sub(now) > sessionRefreshMargin || now.Before(l.nextRefreshAt) {
+		return
+	}
+	// The refreshed token may expire as soon, e.g. if GitHub returns the same one, which is not refreshed again right away.
+	l.nextRefreshAt = now.Add(sessionRetryInterval)
+
+	l.logger.Info("Message queue token is about to expire, refreshing the message session", "expiresAt", l.sessionExpiresAt.UTC().Format(time.RFC3339))
+	session, err := l.client.RefreshMessageSession(ctx, l.scaleSetID, l.session.SessionId)
+	if err != nil {
+		if ctx.Err() == nil {
+			l.metrics.PublishSessionRefreshFailure()
+			l.logger.Error(err, "Failed to refresh the message session before its expiry", "retryIn", sessionRetryInterval.String())
+		}
+		return
+	}
+	l.setSession(session)
+}
@@ -0,0 +1,45 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+)
+
+// sessionStartupInitialBackoff is the time waited after the first failure to create the message session
+// at startup. It doubles with every failed attempt, up to sessionRetryInterval.
+const sessionStartupInitialBackoff = time.Second
+
+// sessionStartupBackoff returns the time waited after the given number of failed attempts to create the
+// message session at startup. It is jittered between half the exponential backoff and the backoff, so that
+// the listeners restarted together by an outage do not all create their sessions at once when it is over.
+func sessionStartupBackoff(failures int) time.Duration {
+	backoff := sessionRetryInterval
+	if failures < 16 {
+		backoff = min(sessionStartupInitialBackoff<<max(failures-1, 0), sessionRetryInterval)
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// waitSessionStartup waits before the next attempt to create the message session at startup, which failed
+// with an error that may go away by retrying. It returns the error to exit with once the listener retried
+// for the session startup timeout since it started creating the session.
+func (l *Listener) waitSessionStartup(ctx context.Context, startedAt time.Time, failures int, err error) error {
+	remaining := l.sessionStartupTimeout - l.clock.Since(startedAt)
+	if remaining <= 0 {
+		return errcode.Errorf(errcode.SessionCreate, "failed to create session within %s: %w", l.sessionStartupTimeout, err)
+	}
+	wait := min(sessionStartupBackoff(failures), remaining)
+
+	l.logger.Info("Unable to create message session at startup. Will try again", "retryIn", wait.String(), "failures", failures, "error", err.Error())
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context cancelled: %w", ctx.Err())
+	case <-l.clock.After(wait):
+		return nil
+	}
+}
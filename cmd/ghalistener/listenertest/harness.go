@@ -0,0 +1,188 @@
+// Package listenertest runs the listener end to end against a Kubernetes cluster, e.g. a kind cluster,
+// with the actions-runner-controller CRDs installed and a scripted Actions service, so scaling features
+// can be tested against the Kubernetes API instead of fakes.
+//
+// The cluster is the one of the current kubeconfig context. Tests using the harness are skipped unless
+// USE_EXISTING_CLUSTER is "true", so they do not run with the unit tests; `make listener-e2e` creates
+// a kind cluster and runs them.
+package listenertest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/app"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// ScaleSetID is the ID of the scale set served by the ActionsServer.
+const ScaleSetID = 1
+
+// DefaultWaitTimeout is how long the Wait methods of the harness wait for the listener.
+const DefaultWaitTimeout = 30 * time.Second
+
+const ephemeralRunnerSetName = "listenertest"
+
+// Harness is the cluster, the Actions service and the ephemeral runner set a listener runs against.
+type Harness struct {
+	t *testing.T
+
+	// Server is the Actions service the listener gets its messages from.
+	Server *ActionsServer
+	// Client is a client of the cluster.
+	Client client.Client
+	// Namespace is the namespace created for the test, deleted when the test ends.
+	Namespace string
+	// EphemeralRunnerSetName is the name of the ephemeral runner set scaled by the listener.
+	EphemeralRunnerSetName string
+
+	restConfig *rest.Config
+}
+
+// New installs the CRDs in the cluster, and creates the namespace and the ephemeral runner set of the test.
+// It skips the test unless USE_EXISTING_CLUSTER is "true".
+func New(t *testing.T, options ...ActionsServerOption) *Harness {
+	t.Helper()
+	if os.Getenv("USE_EXISTING_CLUSTER") != "true" {
+		t.Skip("USE_EXISTING_CLUSTER is not true, set it to run the listener against the cluster of the current kubeconfig context")
+	}
+
+	env := &envtest.Environment{
+		UseExistingCluster:    ptr.To(true),
+		CRDDirectoryPaths:     []string{crdDirectory()},
+		ErrorIfCRDPathMissing: true,
+	}
+	restConfig, err := env.Start()
+	require.NoError(t, err, "failed to install the CRDs in the cluster")
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Logf("Failed to stop the test environment: %v", err)
+		}
+	})
+
+	scheme := k8sruntime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	require.NoError(t, err)
+
+	h := &Harness{
+		t:                      t,
+		Server:                 NewActionsServer(t, options...),
+		Client:                 c,
+		EphemeralRunnerSetName: ephemeralRunnerSetName,
+		restConfig:             restConfig,
+	}
+
+	ctx := context.Background()
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "listenertest-"},
+	}
+	require.NoError(t, c.Create(ctx, namespace))
+	h.Namespace = namespace.Name
+	t.Cleanup(func() {
+		if err := c.Delete(context.Background(), namespace); err != nil {
+			t.Logf("Failed to delete namespace %q: %v", namespace.Name, err)
+		}
+	})
+
+	ers := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      h.EphemeralRunnerSetName,
+			Namespace: h.Namespace,
+		},
+		Spec: v1alpha1.EphemeralRunnerSetSpec{
+			EphemeralRunnerSpec: v1alpha1.EphemeralRunnerSpec{
+				GitHubConfigUrl:    h.Server.ConfigURL(),
+				GitHubConfigSecret: "listenertest",
+				RunnerScaleSetId:   ScaleSetID,
+			},
+		},
+	}
+	require.NoError(t, c.Create(ctx, ers))
+
+	return h
+}
+
+// Run runs the listener with the config until the test ends. The Actions service, the scale set and the
+// ephemeral runner set of the config are the ones of the harness, and MaxRunners defaults to unlimited.
+func (h *Harness) Run(c config.Config) {
+	h.t.Helper()
+
+	c.ConfigureUrl = h.Server.ConfigURL()
+	c.AppConfig = &appconfig.AppConfig{Token: "token"}
+	c.EphemeralRunnerSetNamespace = h.Namespace
+	c.EphemeralRunnerSetName = h.EphemeralRunnerSetName
+	c.RunnerScaleSetId = ScaleSetID
+	c.RunnerScaleSetName = ephemeralRunnerSetName
+	if c.MaxRunners == 0 {
+		c.MaxRunners = math.MaxInt32
+	}
+	if c.WorkDir == "" {
+		c.WorkDir = h.t.TempDir()
+	}
+
+	a, err := app.New(c,
+		app.WithLogger(testr.New(h.t)),
+		app.WithKubernetesConfig(h.restConfig),
+	)
+	require.NoError(h.t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+	h.t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil && !errors.Is(err, context.Canceled) {
+			h.t.Errorf("Listener failed: %v", err)
+		}
+	})
+}
+
+// EphemeralRunnerSet returns the ephemeral runner set scaled by the listener.
+func (h *Harness) EphemeralRunnerSet() *v1alpha1.EphemeralRunnerSet {
+	h.t.Helper()
+	ers := new(v1alpha1.EphemeralRunnerSet)
+	key := client.ObjectKey{Namespace: h.Namespace, Name: h.EphemeralRunnerSetName}
+	require.NoError(h.t, h.Client.Get(context.Background(), key, ers))
+	return ers
+}
+
+// WaitForReplicas waits until the listener scaled the ephemeral runner set to the replicas.
+func (h *Harness) WaitForReplicas(replicas int) {
+	h.t.Helper()
+	key := client.ObjectKey{Namespace: h.Namespace, Name: h.EphemeralRunnerSetName}
+	require.EventuallyWithT(h.t, func(c *assert.CollectT) {
+		ers := new(v1alpha1.EphemeralRunnerSet)
+		if assert.NoError(c, h.Client.Get(context.Background(), key, ers)) {
+			assert.Equal(c, replicas, ers.Spec.Replicas)
+		}
+	}, DefaultWaitTimeout, 100*time.Millisecond, "the ephemeral runner set was not scaled to %d replicas", replicas)
+}
+
+// crdDirectory returns the directory of the CRDs of the repository.
+func crdDirectory() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "config", "crd", "bases")
+}
@@ -0,0 +1,26 @@
+package listenertest
+
+import (
+	"testing"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHarness_ScalesToAssignedJobs(t *testing.T) {
+	h := New(t)
+	h.Run(config.Config{MinRunners: 1})
+	h.WaitForReplicas(1)
+
+	h.Server.Send(actions.RunnerScaleSetStatistic{TotalAssignedJobs: 3}, JobAvailable(1), JobAvailable(2), JobAvailable(3))
+	h.WaitForReplicas(4)
+	assert.ElementsMatch(t, []int64{1, 2, 3}, h.Server.AcquiredJobs())
+
+	h.Server.Send(actions.RunnerScaleSetStatistic{},
+		JobCompleted(1, "runner-1", "succeeded"),
+		JobCompleted(2, "runner-2", "succeeded"),
+		JobCompleted(3, "runner-3", "succeeded"),
+	)
+	h.WaitForReplicas(1)
+}
@@ -0,0 +1,253 @@
+package listenertest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/actions/actions-runner-controller/github/actions/testserver"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// DefaultPollTimeout is how long the ActionsServer holds a message request when no message is queued.
+const DefaultPollTimeout = time.Second
+
+const (
+	messageTypeJobMessages  = "RunnerScaleSetJobMessages"
+	messageTypeJobAvailable = "JobAvailable"
+	messageTypeJobAssigned  = "JobAssigned"
+	messageTypeJobStarted   = "JobStarted"
+	messageTypeJobCompleted = "JobCompleted"
+
+	messageQueuePath = "/message"
+)
+
+// ActionsServer is a scripted Actions service. It serves the message session of a scale set
+// and returns the messages queued with Send to the listener, in order.
+type ActionsServer struct {
+	t           testing.TB
+	url         string
+	configURL   string
+	pollTimeout time.Duration
+	messages    chan *actions.RunnerScaleSetMessage
+
+	mu         sync.Mutex
+	statistics actions.RunnerScaleSetStatistic
+	lastID     int64
+	acquired   []int64
+	deleted    []int64
+	sessions   int
+}
+
+// ActionsServerOption configures the ActionsServer.
+type ActionsServerOption func(*ActionsServer)
+
+// WithPollTimeout sets how long a message request is held when no message is queued.
+func WithPollTimeout(timeout time.Duration) ActionsServerOption {
+	return func(s *ActionsServer) {
+		s.pollTimeout = timeout
+	}
+}
+
+// WithSessionStatistics sets the statistics of the message sessions created by the listener.
+func WithSessionStatistics(statistics actions.RunnerScaleSetStatistic) ActionsServerOption {
+	return func(s *ActionsServer) {
+		s.statistics = statistics
+	}
+}
+
+// NewActionsServer starts an ActionsServer, which is closed when the test ends.
+func NewActionsServer(t *testing.T, options ...ActionsServerOption) *ActionsServer {
+	s := &ActionsServer{
+		t:           t,
+		pollTimeout: DefaultPollTimeout,
+		messages:    make(chan *actions.RunnerScaleSetMessage, 100),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	server := testserver.New(t, http.HandlerFunc(s.serveHTTP))
+	s.url = server.URL
+	s.configURL = server.ConfigURLForOrg("listenertest")
+	return s
+}
+
+// ConfigURL is the GitHub config URL of the organization served by the server.
+func (s *ActionsServer) ConfigURL() string {
+	return s.configURL
+}
+
+// Send queues a message with the statistics and the job messages, e.g. the ones returned by JobAssigned.
+// The statistics are also returned with the message sessions created afterwards.
+func (s *ActionsServer) Send(statistics actions.RunnerScaleSetStatistic, jobs ...any) {
+	body, err := json.Marshal(jobs)
+	require.NoError(s.t, err)
+
+	s.mu.Lock()
+	s.lastID++
+	s.statistics = statistics
+	msg := &actions.RunnerScaleSetMessage{
+		MessageId:   s.lastID,
+		MessageType: messageTypeJobMessages,
+		Body:        string(body),
+		Statistics:  &statistics,
+	}
+	s.mu.Unlock()
+
+	s.messages <- msg
+}
+
+// AcquiredJobs returns the runner request IDs of the jobs acquired by the listener.
+func (s *ActionsServer) AcquiredJobs() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.acquired...)
+}
+
+// DeletedMessages returns the IDs of the messages deleted by the listener once handled.
+func (s *ActionsServer) DeletedMessages() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.deleted...)
+}
+
+// Sessions returns the number of message sessions created by the listener.
+func (s *ActionsServer) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions
+}
+
+func (s *ActionsServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, messageQueuePath):
+		s.serveMessageQueue(w, r)
+	case strings.Contains(path, "/sessions"):
+		s.serveSession(w, r)
+	case strings.HasSuffix(path, "/acquirablejobs"):
+		writeJSON(w, http.StatusOK, &actions.AcquirableJobList{})
+	case strings.HasSuffix(path, "/acquirejobs"):
+		s.serveAcquireJobs(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *ActionsServer) serveSession(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost, http.MethodPatch:
+		s.mu.Lock()
+		if r.Method == http.MethodPost {
+			s.sessions++
+		}
+		statistics := s.statistics
+		s.mu.Unlock()
+
+		sessionID := uuid.New()
+		writeJSON(w, http.StatusOK, &actions.RunnerScaleSetSession{
+			SessionId:               &sessionID,
+			OwnerName:               "listenertest",
+			RunnerScaleSet:          &actions.RunnerScaleSet{Id: ScaleSetID, Name: "listenertest"},
+			MessageQueueUrl:         s.url + messageQueuePath,
+			MessageQueueAccessToken: "token",
+			Statistics:              &statistics,
+		})
+	case http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *ActionsServer) serveMessageQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, messageQueuePath+"/"), 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.deleted = append(s.deleted, id)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	select {
+	case msg := <-s.messages:
+		writeJSON(w, http.StatusOK, msg)
+	case <-time.After(s.pollTimeout):
+		w.WriteHeader(http.StatusAccepted)
+	case <-r.Context().Done():
+	}
+}
+
+func (s *ActionsServer) serveAcquireJobs(w http.ResponseWriter, r *http.Request) {
+	var ids []int64
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.acquired = append(s.acquired, ids...)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, &actions.Int64List{Count: len(ids), Value: ids})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// JobAvailable returns the message of a job available to the scale set, to be acquired by the listener.
+func JobAvailable(requestID int64) *actions.JobAvailable {
+	return &actions.JobAvailable{JobMessageBase: jobMessageBase(messageTypeJobAvailable, requestID)}
+}
+
+// JobAssigned returns the message of a job assigned to the scale set.
+func JobAssigned(requestID int64) *actions.JobAssigned {
+	return &actions.JobAssigned{JobMessageBase: jobMessageBase(messageTypeJobAssigned, requestID)}
+}
+
+// JobStarted returns the message of a job started on the runner.
+func JobStarted(requestID int64, runnerName string) *actions.JobStarted {
+	return &actions.JobStarted{
+		RunnerName:     runnerName,
+		JobMessageBase: jobMessageBase(messageTypeJobStarted, requestID),
+	}
+}
+
+// JobCompleted returns the message of a job completed on the runner with the result, e.g. "succeeded".
+func JobCompleted(requestID int64, runnerName, result string) *actions.JobCompleted {
+	base := jobMessageBase(messageTypeJobCompleted, requestID)
+	base.FinishTime = base.RunnerAssignTime
+	return &actions.JobCompleted{
+		Result:         result,
+		RunnerName:     runnerName,
+		JobMessageBase: base,
+	}
+}
+
+func jobMessageBase(messageType string, requestID int64) actions.JobMessageBase {
+	now := time.Now()
+	return actions.JobMessageBase{
+		JobMessageType:     actions.JobMessageType{MessageType: messageType},
+		RunnerRequestID:    requestID,
+		RepositoryName:     "repository",
+		OwnerName:          "listenertest",
+		JobID:              strconv.FormatInt(requestID, 10),
+		JobDisplayName:     "job",
+		ScaleSetAssignTime: now,
+		RunnerAssignTime:   now,
+		QueueTime:          now,
+	}
+}
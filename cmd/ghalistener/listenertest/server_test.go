@@ -0,0 +1,56 @@
+package listenertest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionsServer(t *testing.T) {
+	ctx := context.Background()
+	server := NewActionsServer(t,
+		WithPollTimeout(10*time.Millisecond),
+		WithSessionStatistics(actions.RunnerScaleSetStatistic{TotalAssignedJobs: 1}),
+	)
+
+	c := config.Config{
+		ConfigureUrl: server.ConfigURL(),
+		AppConfig:    &appconfig.AppConfig{Token: "token"},
+	}
+	client, err := c.ActionsClient(logr.Discard())
+	require.NoError(t, err)
+
+	session, err := client.CreateMessageSession(ctx, ScaleSetID, "owner")
+	require.NoError(t, err)
+	assert.Equal(t, 1, session.Statistics.TotalAssignedJobs)
+	assert.Equal(t, 1, server.Sessions())
+
+	msg, err := client.GetMessage(ctx, session.MessageQueueUrl, session.MessageQueueAccessToken, 0, 10)
+	require.NoError(t, err)
+	assert.Nil(t, msg, "no message is queued")
+
+	server.Send(actions.RunnerScaleSetStatistic{TotalAssignedJobs: 2}, JobAvailable(1), JobAssigned(2))
+	msg, err = client.GetMessage(ctx, session.MessageQueueUrl, session.MessageQueueAccessToken, 0, 10)
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+	assert.Equal(t, int64(1), msg.MessageId)
+	assert.Equal(t, 2, msg.Statistics.TotalAssignedJobs)
+	assert.Contains(t, msg.Body, `"messageType":"JobAvailable"`)
+
+	acquired, err := client.AcquireJobs(ctx, ScaleSetID, session.MessageQueueAccessToken, []int64{1})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, acquired)
+	assert.Equal(t, []int64{1}, server.AcquiredJobs())
+
+	require.NoError(t, client.DeleteMessage(ctx, session.MessageQueueUrl, session.MessageQueueAccessToken, msg.MessageId))
+	assert.Equal(t, []int64{1}, server.DeletedMessages())
+
+	require.NoError(t, client.DeleteMessageSession(ctx, ScaleSetID, session.SessionId))
+}
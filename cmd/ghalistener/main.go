@@ -2,40 +2,152 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/app"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/gateway"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/health"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/tracing"
+	"github.com/actions/actions-runner-controller/logging"
 )
 
 func main() {
+	check := flag.Bool("check", false, "check that the listener of LISTENER_CONFIG_PATH can start, print a diagnostic report and exit")
+	preStop := flag.String("prestop", "", "call the pre-stop endpoint of the listener whose health server listens on the given address, wait for the listener to stop and exit")
+	flag.Parse()
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	if *preStop != "" {
+		os.Exit(runPreStop(ctx, *preStop))
+	}
+
+	if gatewayConfigPath, ok := os.LookupEnv("LISTENER_GATEWAY_CONFIG_PATH"); ok {
+		os.Exit(runGateway(ctx, gatewayConfigPath))
+	}
+
 	configPath, ok := os.LookupEnv("LISTENER_CONFIG_PATH")
 	if !ok {
 		fmt.Fprintf(os.Stderr, "Error: LISTENER_CONFIG_PATH environment variable is not set\n")
-		os.Exit(1)
+		os.Exit(errcode.ExitConfig)
+	}
+
+	if *check {
+		os.Exit(runCheck(ctx, configPath))
 	}
 
 	config, err := config.Read(ctx, configPath)
 	if err != nil {
-		log.Printf("Failed to read config: %v", err)
-		os.Exit(1)
+		logError("Failed to read config", err)
+		os.Exit(errcode.ExitCode(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		logError("Failed to set up tracing", err)
+		os.Exit(errcode.ExitCode(err))
 	}
 
 	app, err := app.New(*config)
 	if err != nil {
-		log.Printf("Failed to initialize app: %v", err)
-		os.Exit(1)
+		logError("Failed to initialize app", err)
+		os.Exit(errcode.ExitCode(err))
+	}
+
+	err = app.Run(ctx)
+	flushTraces(shutdownTracing)
+	if err != nil {
+		logError("Application returned an error", err)
+		os.Exit(errcode.ExitCode(err))
+	}
+}
+
+// runGateway runs the listeners of the scale sets of the gateway config, and returns the exit code.
+func runGateway(ctx context.Context, configPath string) int {
+	gatewayConfig, err := gateway.Read(configPath)
+	if err != nil {
+		logError("Failed to read gateway config", err)
+		return errcode.ExitCode(err)
+	}
+
+	logLevel, logFormat := string(logging.LogLevelDebug), string(logging.LogFormatText)
+	if gatewayConfig.LogLevel != "" {
+		logLevel = gatewayConfig.LogLevel
+	}
+	if gatewayConfig.LogFormat != "" {
+		logFormat = gatewayConfig.LogFormat
+	}
+	logger, err := logging.NewLogger(logLevel, logFormat)
+	if err != nil {
+		err = errcode.Errorf(errcode.ConfigInvalid, "invalid gateway log config: %w", err)
+		logError("Failed to create logger", err)
+		return errcode.ExitCode(err)
+	}
+	logger = logging.Redact(logger)
+
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		logError("Failed to set up tracing", err)
+		return errcode.ExitCode(err)
+	}
+
+	err = gateway.New(*gatewayConfig, logger.WithName("gateway")).Run(ctx)
+	flushTraces(shutdownTracing)
+	if err != nil {
+		logError("Gateway returned an error", err)
+		return errcode.ExitCode(err)
+	}
+	return 0
+}
+
+// runCheck runs the self-check of the listener, prints its report, and returns the exit code.
+func runCheck(ctx context.Context, configPath string) int {
+	report := app.Check(ctx, configPath)
+	if err := report.Write(os.Stdout); err != nil {
+		logError("Failed to write the self-check report", err)
+		return errcode.ExitCode(err)
+	}
+	if report.Failed() {
+		return errcode.ExitCode(report.Err())
+	}
+	return 0
+}
+
+// runPreStop calls the pre-stop endpoint of the listener and returns the exit code.
+func runPreStop(ctx context.Context, healthAddr string) int {
+	if err := health.RequestPreStop(ctx, healthAddr); err != nil {
+		logError("Pre-stop hook failed", err)
+		return errcode.ExitCode(err)
 	}
+	return 0
+}
+
+// flushTraces exports the pending spans before the process exits.
+func flushTraces(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := shutdown(ctx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+}
 
-	if err := app.Run(ctx); err != nil {
-		log.Printf("Application returned an error: %v", err)
-		os.Exit(1)
+// logError logs the error together with its code, if any, and its category, so that it can be parsed without
+// relying on the error message. The category matches the exit code of the listener.
+func logError(msg string, err error) {
+	category := errcode.Classify(err)
+	if code, ok := errcode.Of(err); ok {
+		log.Printf("%s: errorCode=%s: errorCategory=%s: %v", msg, code, category, err)
+		return
 	}
+	log.Printf("%s: errorCategory=%s: %v", msg, category, err)
 }
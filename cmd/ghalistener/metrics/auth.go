@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// authenticated wraps the handler of the metrics endpoint, requiring the scrapes to present the bearer token,
+// or the basic auth credentials. Scrapes are not authenticated when neither is configured.
+func authenticated(next http.Handler, bearerToken, username, password string) http.Handler {
+	if bearerToken == "" && username == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bearerToken != "" {
+			if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(token, bearerToken) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if username != "" {
+			if u, p, ok := r.BasicAuth(); ok && secureEqual(u, username) && secureEqual(p, password) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticated(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	scrape := func(h http.Handler, setAuth func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if setAuth != nil {
+			setAuth(req)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	basic := func(username, password string) func(r *http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(username, password) }
+	}
+
+	t.Run("without credentials", func(t *testing.T) {
+		h := authenticated(ok, "", "", "")
+		assert.Equal(t, http.StatusOK, scrape(h, nil).Code)
+	})
+
+	t.Run("bearer token", func(t *testing.T) {
+		h := authenticated(ok, "secret", "", "")
+		assert.Equal(t, http.StatusUnauthorized, scrape(h, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, scrape(h, bearer("wrong")).Code)
+		assert.Equal(t, http.StatusUnauthorized, scrape(h, basic("user", "secret")).Code)
+		assert.Equal(t, http.StatusOK, scrape(h, bearer("secret")).Code)
+	})
+
+	t.Run("basic auth", func(t *testing.T) {
+		h := authenticated(ok, "", "prometheus", "password")
+		rec := scrape(h, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, `Basic realm="metrics"`, rec.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, http.StatusUnauthorized, scrape(h, basic("prometheus", "wrong")).Code)
+		assert.Equal(t, http.StatusUnauthorized, scrape(h, basic("other", "password")).Code)
+		assert.Equal(t, http.StatusOK, scrape(h, basic("prometheus", "password")).Code)
+	})
+
+	t.Run("bearer token or basic auth", func(t *testing.T) {
+		h := authenticated(ok, "secret", "prometheus", "password")
+		assert.Equal(t, http.StatusOK, scrape(h, bearer("secret")).Code)
+		assert.Equal(t, http.StatusOK, scrape(h, basic("prometheus", "password")).Code)
+		assert.Equal(t, http.StatusUnauthorized, scrape(h, bearer("password")).Code)
+	})
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// overflowLabelValue is the value of the labels of the series recording the label sets
+// beyond the maximum cardinality of a metric.
+const overflowLabelValue = "overflow"
+
+// cardinalityLimit bounds the number of label sets recorded for a metric.
+// A label set is admitted the first time it is seen while the limit is not reached, and stays admitted,
+// so the increments and decrements of a gauge always end up on the same series.
+//
+// A nil cardinalityLimit admits every label set.
+type cardinalityLimit struct {
+	name   string
+	max    int
+	logger logr.Logger
+
+	mu         sync.Mutex
+	seen       map[string]struct{}
+	overflowed bool
+}
+
+func newCardinalityLimit(name string, max int, logger logr.Logger) *cardinalityLimit {
+	if max <= 0 {
+		return nil
+	}
+	return &cardinalityLimit{
+		name:   name,
+		max:    max,
+		logger: logger,
+		seen:   make(map[string]struct{}, max),
+	}
+}
+
+// limit returns the labels, or the overflow labels when the label set is not admitted.
+// The scale set name and namespace are kept on the overflow series to tell the listeners apart.
+func (c *cardinalityLimit) limit(keys []string, labels prometheus.Labels) prometheus.Labels {
+	if c == nil {
+		return labels
+	}
+
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = labels[key]
+	}
+	id := strings.Join(values, "\xff")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.seen[id]; ok {
+		return labels
+	}
+	if len(c.seen) < c.max {
+		c.seen[id] = struct{}{}
+		return labels
+	}
+
+	if !c.overflowed {
+		c.overflowed = true
+		c.logger.Info("Metric reached its maximum cardinality, recording new label sets in the overflow series", "metric", c.name, "maxCardinality", c.max)
+	}
+
+	overflow := make(prometheus.Labels, len(labels))
+	for key, value := range labels {
+		switch key {
+		case labelKeyRunnerScaleSetName, labelKeyRunnerScaleSetNamespace:
+			overflow[key] = value
+		default:
+			overflow[key] = overflowLabelValue
+		}
+	}
+	return overflow
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardinalityLimit(t *testing.T) {
+	assert.Nil(t, newCardinalityLimit("gha_started_jobs_total", 0, logr.Discard()))
+	var unlimited *cardinalityLimit
+	labels := prometheus.Labels{labelKeyJobName: "build"}
+	assert.Equal(t, labels, unlimited.limit([]string{labelKeyJobName}, labels))
+
+	keys := []string{labelKeyRunnerScaleSetName, labelKeyJobName}
+	c := newCardinalityLimit("gha_started_jobs_total", 2, logr.Discard())
+	job := func(name string) prometheus.Labels {
+		return prometheus.Labels{labelKeyRunnerScaleSetName: "scale-set", labelKeyJobName: name}
+	}
+
+	assert.Equal(t, job("a"), c.limit(keys, job("a")))
+	assert.Equal(t, job("b"), c.limit(keys, job("b")))
+	assert.Equal(t, prometheus.Labels{labelKeyRunnerScaleSetName: "scale-set", labelKeyJobName: overflowLabelValue}, c.limit(keys, job("c")))
+	// admitted label sets stay admitted
+	assert.Equal(t, job("a"), c.limit(keys, job("a")))
+	assert.Equal(t, prometheus.Labels{labelKeyRunnerScaleSetName: "scale-set", labelKeyJobName: overflowLabelValue}, c.limit(keys, job("d")))
+}
+
+func TestExporter_MaxCardinality(t *testing.T) {
+	exporter, ok := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Logger:            logr.Discard(),
+		Metrics: &v1alpha1.MetricsConfig{
+			Counters: map[string]*v1alpha1.CounterMetric{
+				MetricStartedJobsTotal: {
+					Labels:         []string{labelKeyRepository, labelKeyJobName, labelKeyRunnerName, "unknown"},
+					MaxCardinality: 1,
+				},
+			},
+		},
+	}).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+	assert.Equal(t, []string{labelKeyRepository, labelKeyJobName, labelKeyRunnerName}, exporter.counters[MetricStartedJobsTotal].config.Labels)
+
+	for _, runner := range []string{"runner-1", "runner-2", "runner-3"} {
+		exporter.PublishJobStarted(&actions.JobStarted{
+			JobMessageBase: actions.JobMessageBase{RepositoryName: "repo", JobDisplayName: "build"},
+			RunnerName:     runner,
+		})
+	}
+
+	counter := exporter.counters[MetricStartedJobsTotal].counter
+	assert.Equal(t, 2, testutil.CollectAndCount(counter))
+	assert.Equal(t, 1.0, testutil.ToFloat64(counter.With(prometheus.Labels{
+		labelKeyRepository: "repo",
+		labelKeyJobName:    "build",
+		labelKeyRunnerName: "runner-1",
+	})))
+	assert.Equal(t, 2.0, testutil.ToFloat64(counter.With(prometheus.Labels{
+		labelKeyRepository: overflowLabelValue,
+		labelKeyJobName:    overflowLabelValue,
+		labelKeyRunnerName: overflowLabelValue,
+	})))
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// jobDemand tracks the jobs assigned to the scale set until they are completed,
+// to publish the queued and acquired jobs per job labels.
+//
+// The labels of each job are kept so the gauges are decremented on the same series they were incremented on.
+type jobDemand struct {
+	mu sync.Mutex
+	// queued are the jobs assigned to the scale set which have not started yet.
+	queued map[string]prometheus.Labels
+	// acquired are the jobs started on a runner which have not completed yet.
+	acquired map[string]prometheus.Labels
+	// completed are the jobs that ran on a runner and completed before being seen as started.
+	// The job started and completed messages of a batch are not published in order.
+	// The jobs started before the listener restarted are never seen as started, but they are
+	// bounded by the runners busy at the time.
+	completed map[string]struct{}
+}
+
+func (e *exporter) jobDemandLabels(jobBase *actions.JobMessageBase) prometheus.Labels {
+	l := e.jobLabels(jobBase)
+	l[labelKeyRunnerScaleSetName] = e.scaleSetLabels[labelKeyRunnerScaleSetName]
+	l[labelKeyRunnerScaleSetNamespace] = e.scaleSetLabels[labelKeyRunnerScaleSetNamespace]
+	return l
+}
+
+func (e *exporter) queueJob(jobBase *actions.JobMessageBase) {
+	if jobBase.JobID == "" {
+		return
+	}
+
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+
+	if _, ok := e.jobs.queued[jobBase.JobID]; ok {
+		return
+	}
+	if _, ok := e.jobs.acquired[jobBase.JobID]; ok {
+		return
+	}
+	if _, ok := e.jobs.completed[jobBase.JobID]; ok {
+		return
+	}
+
+	if e.jobs.queued == nil {
+		e.jobs.queued = make(map[string]prometheus.Labels)
+	}
+	l := e.jobDemandLabels(jobBase)
+	e.jobs.queued[jobBase.JobID] = l
+	e.addGauge(MetricQueuedJobs, l, 1)
+}
+
+func (e *exporter) acquireJob(jobBase *actions.JobMessageBase) {
+	if jobBase.JobID == "" {
+		return
+	}
+
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+
+	if l, ok := e.jobs.queued[jobBase.JobID]; ok {
+		delete(e.jobs.queued, jobBase.JobID)
+		e.addGauge(MetricQueuedJobs, l, -1)
+	}
+	if _, ok := e.jobs.completed[jobBase.JobID]; ok {
+		delete(e.jobs.completed, jobBase.JobID)
+		return
+	}
+	if _, ok := e.jobs.acquired[jobBase.JobID]; ok {
+		return
+	}
+
+	if e.jobs.acquired == nil {
+		e.jobs.acquired = make(map[string]prometheus.Labels)
+	}
+	l := e.jobDemandLabels(jobBase)
+	e.jobs.acquired[jobBase.JobID] = l
+	e.addGauge(MetricAcquiredJobs, l, 1)
+}
+
+func (e *exporter) completeJob(msg *actions.JobCompleted) {
+	if msg.JobID == "" {
+		return
+	}
+
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+
+	if l, ok := e.jobs.queued[msg.JobID]; ok {
+		delete(e.jobs.queued, msg.JobID)
+		e.addGauge(MetricQueuedJobs, l, -1)
+	}
+	if l, ok := e.jobs.acquired[msg.JobID]; ok {
+		delete(e.jobs.acquired, msg.JobID)
+		e.addGauge(MetricAcquiredJobs, l, -1)
+		return
+	}
+
+	// Jobs cancelled before getting a runner are never started.
+	if msg.RunnerId == 0 {
+		return
+	}
+	if e.jobs.completed == nil {
+		e.jobs.completed = make(map[string]struct{})
+	}
+	e.jobs.completed[msg.JobID] = struct{}{}
+}
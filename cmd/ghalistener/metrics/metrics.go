@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/build"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
@@ -26,8 +30,65 @@ const (
 	labelKeyJobWorkflowTarget       = "job_workflow_target"
 	labelKeyEventName               = "event_name"
 	labelKeyJobResult               = "job_result"
+	labelKeyMessageType             = "message_type"
+	labelKeyRunnerLabels            = "runner_labels"
+	labelKeyRunnerName              = "runner_name"
+	labelKeySessionID               = "session_id"
+	labelKeySessionOwner            = "session_owner"
+	labelKeySessionCreatedAt        = "session_created_at"
+	labelKeyRateLimitResource       = "resource"
+	labelKeyComponent               = "component"
+	labelKeyScalingPolicy           = "policy"
+	labelKeySchedule                = "schedule"
+	labelKeyClamps                  = "clamps"
+	labelKeyZone                    = "zone"
+	labelKeyVersion                 = "version"
+	labelKeyCommit                  = "commit"
+	labelKeyRunnerScaleSetID        = "scale_set_id"
+	labelKeyMinRunners              = "min_runners"
+	labelKeyMaxRunners              = "max_runners"
+	labelKeyConfigHash              = "config_hash"
+	labelKeyVaultType               = "vault_type"
+	labelKeyVaultOperation          = "operation"
+	labelKeyVaultResult             = "result"
 )
 
+// knownLabels are the labels that can be attached to the metrics.
+var knownLabels = []string{
+	labelKeyRunnerScaleSetName,
+	labelKeyRunnerScaleSetNamespace,
+	labelKeyEnterprise,
+	labelKeyOrganization,
+	labelKeyRepository,
+	labelKeyJobName,
+	labelKeyJobWorkflowRef,
+	labelKeyJobWorkflowName,
+	labelKeyJobWorkflowTarget,
+	labelKeyEventName,
+	labelKeyJobResult,
+	labelKeyMessageType,
+	labelKeyRunnerLabels,
+	labelKeyRunnerName,
+	labelKeySessionID,
+	labelKeySessionOwner,
+	labelKeySessionCreatedAt,
+	labelKeyRateLimitResource,
+	labelKeyComponent,
+	labelKeyScalingPolicy,
+	labelKeySchedule,
+	labelKeyClamps,
+	labelKeyZone,
+	labelKeyVersion,
+	labelKeyCommit,
+	labelKeyRunnerScaleSetID,
+	labelKeyMinRunners,
+	labelKeyMaxRunners,
+	labelKeyConfigHash,
+	labelKeyVaultType,
+	labelKeyVaultOperation,
+	labelKeyVaultResult,
+}
+
 const (
 	githubScaleSetSubsystem       = "gha"
 	githubScaleSetSubsystemPrefix = "gha_"
@@ -37,6 +98,8 @@ const (
 const (
 	MetricAssignedJobs                = "gha_assigned_jobs"
 	MetricRunningJobs                 = "gha_running_jobs"
+	MetricWaitingForRunnerJobs        = "gha_waiting_for_runner_jobs"
+	MetricPendingAssignmentJobs       = "gha_pending_assignment_jobs"
 	MetricRegisteredRunners           = "gha_registered_runners"
 	MetricBusyRunners                 = "gha_busy_runners"
 	MetricMinRunners                  = "gha_min_runners"
@@ -47,6 +110,48 @@ const (
 	MetricCompletedJobsTotal          = "gha_completed_jobs_total"
 	MetricJobStartupDurationSeconds   = "gha_job_startup_duration_seconds"
 	MetricJobExecutionDurationSeconds = "gha_job_execution_duration_seconds"
+	MetricJobQueueDurationSeconds     = "gha_job_queue_duration_seconds"
+	MetricMessageProcessingSeconds    = "gha_message_processing_duration_seconds"
+	MetricQueuedJobs                  = "gha_queued_jobs"
+	MetricAcquiredJobs                = "gha_acquired_jobs"
+	MetricMessageSessionInfo          = "gha_message_session_info"
+	MetricMessageSessionAgeSeconds    = "gha_message_session_age_seconds"
+	MetricScalingPolicyInfo           = "gha_scaling_policy_info"
+	MetricListenerInfo                = "gha_listener_info"
+
+	MetricMessageSessionExpiryTimestampSeconds = "gha_message_session_expiry_timestamp_seconds"
+	MetricMessageSessionRefreshFailuresTotal   = "gha_message_session_refresh_failures_total"
+
+	MetricActionsCircuitBreakerOpen       = "gha_actions_circuit_breaker_open"
+	MetricActionsCircuitBreakerOpensTotal = "gha_actions_circuit_breaker_opens_total"
+
+	MetricBudgetExhausted = "gha_budget_exhausted"
+
+	MetricStuckPendingRunners = "gha_stuck_pending_runners"
+
+	MetricForecastPeakRunners          = "gha_forecast_peak_runners"
+	MetricForecastPeakTimestampSeconds = "gha_forecast_peak_timestamp_seconds"
+
+	MetricQuarantinedMessagesTotal = "gha_quarantined_messages_total"
+	MetricPanicsTotal              = "gha_listener_panics_total"
+
+	MetricEphemeralRunnerMissesTotal = "gha_ephemeral_runner_misses_total"
+	MetricMisroutedJobsTotal         = "gha_misrouted_jobs_total"
+
+	MetricRateLimitLimit          = "gha_rate_limit_limit"
+	MetricRateLimitRemaining      = "gha_rate_limit_remaining"
+	MetricRateLimitResetTimestamp = "gha_rate_limit_reset_timestamp_seconds"
+
+	MetricZoneDesiredRunners = "gha_zone_desired_runners"
+
+	MetricEphemeralRunnerSetPatchAttemptsTotal     = "gha_ephemeral_runner_set_patch_attempts_total"
+	MetricEphemeralRunnerSetPatchSuccessesTotal    = "gha_ephemeral_runner_set_patch_successes_total"
+	MetricEphemeralRunnerSetPatchFailuresTotal     = "gha_ephemeral_runner_set_patch_failures_total"
+	MetricEphemeralRunnerSetPatchesSuppressedTotal = "gha_ephemeral_runner_set_patches_suppressed_total"
+	MetricEphemeralRunnerSetPatchDurationSeconds   = "gha_ephemeral_runner_set_patch_duration_seconds"
+
+	MetricVaultRequestsTotal          = "gha_vault_requests_total"
+	MetricVaultRequestDurationSeconds = "gha_vault_request_duration_seconds"
 )
 
 type metricsHelpRegistry struct {
@@ -58,21 +163,67 @@ type metricsHelpRegistry struct {
 var metricsHelp = metricsHelpRegistry{
 	counters: map[string]string{
 		MetricStartedJobsTotal:   "Total number of jobs started.",
-		MetricCompletedJobsTotal: "Total number of jobs completed.",
+		MetricCompletedJobsTotal: "Total number of jobs completed, per result.",
+
+		MetricEphemeralRunnerSetPatchAttemptsTotal:     "Total number of requests patching the ephemeral runner set, including retries.",
+		MetricEphemeralRunnerSetPatchSuccessesTotal:    "Total number of scaling decisions patched to the ephemeral runner set.",
+		MetricEphemeralRunnerSetPatchFailuresTotal:     "Total number of scaling decisions that failed to be patched to the ephemeral runner set.",
+		MetricEphemeralRunnerSetPatchesSuppressedTotal: "Total number of scaling decisions not patched for repeating the last patch to the ephemeral runner set.",
+
+		MetricActionsCircuitBreakerOpensTotal: "Total number of times the circuit breaker of the GitHub Actions service calls opened.",
+		MetricQuarantinedMessagesTotal:        "Total number of job messages quarantined for failing validation, per message type.",
+		MetricPanicsTotal:                     "Total number of panics recovered by the listener, per restarted component.",
+		MetricEphemeralRunnerMissesTotal:      "Total number of started jobs whose ephemeral runner was not found, per missing runner policy.",
+		MetricMisroutedJobsTotal:              "Total number of jobs requiring labels the scale set does not provide, per misrouted job policy.",
+
+		MetricMessageSessionRefreshFailuresTotal: "Total number of failures to refresh the message session, before or after its message queue token expired.",
+
+		MetricVaultRequestsTotal: "Total number of requests made to the vault the GitHub credentials are read from, per vault type, operation and result.",
 	},
 	gauges: map[string]string{
-		MetricAssignedJobs:      "Number of jobs assigned to this scale set.",
-		MetricRunningJobs:       "Number of jobs running (or about to be run).",
-		MetricRegisteredRunners: "Number of runners registered by the scale set.",
-		MetricBusyRunners:       "Number of registered runners running a job.",
-		MetricMinRunners:        "Minimum number of runners.",
-		MetricMaxRunners:        "Maximum number of runners.",
-		MetricDesiredRunners:    "Number of runners desired by the scale set.",
-		MetricIdleRunners:       "Number of registered runners not running a job.",
+		MetricAssignedJobs:             "Number of jobs assigned to this scale set.",
+		MetricRunningJobs:              "Number of jobs running (or about to be run).",
+		MetricWaitingForRunnerJobs:     "Number of jobs assigned to this scale set which no runner picked up yet.",
+		MetricPendingAssignmentJobs:    "Number of jobs acquired by this scale set which are not assigned to it yet.",
+		MetricRegisteredRunners:        "Number of runners registered by the scale set.",
+		MetricBusyRunners:              "Number of registered runners running a job.",
+		MetricMinRunners:               "Minimum number of runners.",
+		MetricMaxRunners:               "Maximum number of runners.",
+		MetricDesiredRunners:           "Number of runners desired by the scale set.",
+		MetricIdleRunners:              "Number of registered runners not running a job.",
+		MetricQueuedJobs:               "Number of jobs assigned to this scale set and waiting for a runner, per job.",
+		MetricAcquiredJobs:             "Number of jobs acquired by a runner of this scale set and not completed yet, per job.",
+		MetricMessageSessionInfo:       "Information about the message session of the listener, set to 1 and labeled with the session ID, owner and creation time.",
+		MetricMessageSessionAgeSeconds: "Time since the message session was created (in seconds), updated before every message is fetched.",
+		MetricScalingPolicyInfo:        "Information about the scaling policy in effect, set to 1 and labeled with the policy, the active scheduled override and the clamps limiting the desired runners.",
+		MetricListenerInfo:             "Information about the listener, set to 1 and labeled with its version and commit, the scale set ID, the configured min and max runners, and the hash of its config.",
+
+		MetricMessageSessionExpiryTimestampSeconds: "Time the message queue token of the message session expires at (in seconds since the epoch).",
+
+		MetricActionsCircuitBreakerOpen: "Whether the GitHub Actions service calls are backed off after server errors or rate limiting (1) or not (0).",
+
+		MetricBudgetExhausted: "Whether the runner minutes budget of the day is exhausted and the scale-ups are capped at the min runners (1) or not (0).",
+
+		MetricStuckPendingRunners: "Number of ephemeral runners of this scale set pending for longer than the pending runners threshold.",
+
+		MetricForecastPeakRunners:          "Highest number of runners forecast within the forecast horizon, from the scheduled overrides, the reservations and the demand of the previous days.",
+		MetricForecastPeakTimestampSeconds: "Time the highest number of runners is forecast at within the forecast horizon (in seconds since the epoch).",
+
+		MetricRateLimitLimit:          "Number of requests allowed in the current rate limit window of GitHub, per resource.",
+		MetricRateLimitRemaining:      "Number of requests remaining in the current rate limit window of GitHub, per resource.",
+		MetricRateLimitResetTimestamp: "Time the current rate limit window of GitHub resets at, per resource (in seconds since the epoch).",
+
+		MetricZoneDesiredRunners: "Number of runners desired in the topology zone, when the runners are spread across zones.",
 	},
 	histograms: map[string]string{
 		MetricJobStartupDurationSeconds:   "Time spent waiting for workflow job to get started on the runner owned by the scale set (in seconds).",
 		MetricJobExecutionDurationSeconds: "Time spent executing workflow jobs by the scale set (in seconds).",
+		MetricJobQueueDurationSeconds:     "Time between the workflow job being queued and getting started on the runner owned by the scale set (in seconds).",
+		MetricMessageProcessingSeconds:    "Time spent by the listener processing messages, per message type (in seconds).",
+
+		MetricEphemeralRunnerSetPatchDurationSeconds: "Time spent patching the ephemeral runner set, including retries (in seconds).",
+
+		MetricVaultRequestDurationSeconds: "Time spent on the requests made to the vault the GitHub credentials are read from, per vault type, operation and result (in seconds).",
 	},
 }
 
@@ -87,26 +238,61 @@ func (e *exporter) jobLabels(jobBase *actions.JobMessageBase) prometheus.Labels
 		labelKeyJobWorkflowName:   workflowRefInfo.Name,
 		labelKeyJobWorkflowTarget: workflowRefInfo.Target,
 		labelKeyEventName:         jobBase.EventName,
+		labelKeyRunnerLabels:      runnerLabels(jobBase.RequestLabels),
 	}
 }
 
+// runnerLabels returns the runner labels requested by a job as a single label value,
+// sorted so the same labels requested in a different order are counted together.
+func runnerLabels(requestLabels []string) string {
+	labels := slices.Clone(requestLabels)
+	slices.Sort(labels)
+	return strings.Join(labels, ",")
+}
+
 func (e *exporter) completedJobLabels(msg *actions.JobCompleted) prometheus.Labels {
 	l := e.jobLabels(&msg.JobMessageBase)
 	l[labelKeyJobResult] = msg.Result
+	l[labelKeyRunnerName] = msg.RunnerName
+	// The scale set labels give the results of the jobs per scale set, e.g. for the failure rate of its runners.
+	l[labelKeyRunnerScaleSetName] = e.scaleSetLabels[labelKeyRunnerScaleSetName]
+	l[labelKeyRunnerScaleSetNamespace] = e.scaleSetLabels[labelKeyRunnerScaleSetNamespace]
 	return l
 }
 
 func (e *exporter) startedJobLabels(msg *actions.JobStarted) prometheus.Labels {
-	return e.jobLabels(&msg.JobMessageBase)
+	l := e.jobLabels(&msg.JobMessageBase)
+	l[labelKeyRunnerName] = msg.RunnerName
+	return l
 }
 
 //go:generate mockery --name Publisher --output ./mocks --outpkg mocks --case underscore
 type Publisher interface {
 	PublishStatic(min, max int)
 	PublishStatistics(stats *actions.RunnerScaleSetStatistic)
+	PublishJobAssigned(msg *actions.JobAssigned)
+	PublishSession(session *actions.RunnerScaleSetSession, createdAt time.Time)
+	PublishSessionHeartbeat(age time.Duration, expiresAt time.Time)
+	PublishSessionRefreshFailure()
 	PublishJobStarted(msg *actions.JobStarted)
 	PublishJobCompleted(msg *actions.JobCompleted)
 	PublishDesiredRunners(count int)
+	PublishMessageProcessingDuration(messageType string, duration time.Duration)
+	PublishEphemeralRunnerSetPatchAttempt()
+	PublishEphemeralRunnerSetPatch(duration time.Duration, err error)
+	PublishEphemeralRunnerSetPatchSuppressed()
+	PublishCircuitBreakerState(open bool)
+	PublishQuarantinedMessage(messageType string)
+	PublishPanic(component string)
+	PublishEphemeralRunnerMiss(policy string)
+	PublishMisroutedJob(policy string)
+	PublishRateLimit(resource string, limit, remaining int, reset time.Time)
+	PublishScalingPolicy(policy, schedule string, clamps []string)
+	PublishBudgetExhausted(exhausted bool)
+	PublishStuckPendingRunners(count int)
+	PublishCapacityForecast(peakRunners int, peakTime time.Time)
+	PublishZoneDesiredRunners(zone string, count int)
+	PublishVaultRequest(vaultType, operation, result string, duration time.Duration)
 }
 
 //go:generate mockery --name ServerPublisher --output ./mocks --outpkg mocks --case underscore
@@ -126,7 +312,16 @@ type exporter struct {
 	logger         logr.Logger
 	scaleSetLabels prometheus.Labels
 	*metrics
-	srv *http.Server
+	srv    *http.Server
+	tls    *serverTLS
+	pusher *pusher
+	jobs   jobDemand
+}
+
+// serverTLS are the certificate and key files the metrics server is served with.
+type serverTLS struct {
+	certFile string
+	keyFile  string
 }
 
 type metrics struct {
@@ -136,18 +331,21 @@ type metrics struct {
 }
 
 type counterMetric struct {
-	counter *prometheus.CounterVec
-	config  *v1alpha1.CounterMetric
+	counter     *prometheus.CounterVec
+	config      *v1alpha1.CounterMetric
+	cardinality *cardinalityLimit
 }
 
 type gaugeMetric struct {
-	gauge  *prometheus.GaugeVec
-	config *v1alpha1.GaugeMetric
+	gauge       *prometheus.GaugeVec
+	config      *v1alpha1.GaugeMetric
+	cardinality *cardinalityLimit
 }
 
 type histogramMetric struct {
-	histogram *prometheus.HistogramVec
-	config    *v1alpha1.HistogramMetric
+	histogram   *prometheus.HistogramVec
+	config      *v1alpha1.HistogramMetric
+	cardinality *cardinalityLimit
 }
 
 type ExporterConfig struct {
@@ -160,6 +358,20 @@ type ExporterConfig struct {
 	ServerEndpoint    string
 	Logger            logr.Logger
 	Metrics           *v1alpha1.MetricsConfig
+	// DisableServer disables the metrics server, when the metrics are only pushed.
+	DisableServer bool
+	// TLSCertFile and TLSKeyFile are the certificate and key the metrics server is served with over TLS.
+	TLSCertFile string
+	TLSKeyFile  string
+	// BearerToken, or BasicAuthUsername and BasicAuthPassword, are the credentials required to scrape the metrics.
+	BearerToken       string
+	BasicAuthUsername string
+	BasicAuthPassword string
+	// ScaleSetID, MinRunners, MaxRunners and ConfigHash label the listener info metric.
+	ScaleSetID int
+	MinRunners int
+	MaxRunners int
+	ConfigHash string
 }
 
 var defaultMetrics = v1alpha1.MetricsConfig{
@@ -178,11 +390,119 @@ var defaultMetrics = v1alpha1.MetricsConfig{
 				labelKeyEnterprise,
 				labelKeyOrganization,
 				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
 				labelKeyJobName,
 				labelKeyEventName,
 				labelKeyJobResult,
 			},
 		},
+		MetricEphemeralRunnerSetPatchAttemptsTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricEphemeralRunnerSetPatchSuccessesTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricEphemeralRunnerSetPatchFailuresTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricEphemeralRunnerSetPatchesSuppressedTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricActionsCircuitBreakerOpensTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricMessageSessionRefreshFailuresTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricQuarantinedMessagesTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyMessageType,
+			},
+		},
+		MetricPanicsTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyComponent,
+			},
+		},
+		MetricEphemeralRunnerMissesTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyScalingPolicy,
+			},
+		},
+		MetricMisroutedJobsTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyScalingPolicy,
+			},
+		},
+		MetricVaultRequestsTotal: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyVaultType,
+				labelKeyVaultOperation,
+				labelKeyVaultResult,
+			},
+		},
 	},
 	Gauges: map[string]*v1alpha1.GaugeMetric{
 		MetricAssignedJobs: {
@@ -203,6 +523,24 @@ var defaultMetrics = v1alpha1.MetricsConfig{
 				labelKeyRunnerScaleSetNamespace,
 			},
 		},
+		MetricWaitingForRunnerJobs: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricPendingAssignmentJobs: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
 		MetricRegisteredRunners: {
 			Labels: []string{
 				labelKeyEnterprise,
@@ -257,6 +595,168 @@ var defaultMetrics = v1alpha1.MetricsConfig{
 				labelKeyRunnerScaleSetNamespace,
 			},
 		},
+		MetricQueuedJobs: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyRunnerLabels,
+			},
+		},
+		MetricAcquiredJobs: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyRunnerLabels,
+			},
+		},
+		MetricMessageSessionInfo: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeySessionID,
+				labelKeySessionOwner,
+				labelKeySessionCreatedAt,
+			},
+		},
+		MetricMessageSessionAgeSeconds: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricMessageSessionExpiryTimestampSeconds: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricScalingPolicyInfo: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyScalingPolicy,
+				labelKeySchedule,
+				labelKeyClamps,
+			},
+		},
+		MetricListenerInfo: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyVersion,
+				labelKeyCommit,
+				labelKeyRunnerScaleSetID,
+				labelKeyMinRunners,
+				labelKeyMaxRunners,
+				labelKeyConfigHash,
+			},
+		},
+		MetricActionsCircuitBreakerOpen: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricStuckPendingRunners: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricBudgetExhausted: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricForecastPeakRunners: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricForecastPeakTimestampSeconds: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+		},
+		MetricRateLimitLimit: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyRateLimitResource,
+			},
+		},
+		MetricRateLimitRemaining: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyRateLimitResource,
+			},
+		},
+		MetricRateLimitResetTimestamp: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyRateLimitResource,
+			},
+		},
+		MetricZoneDesiredRunners: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyZone,
+			},
+		},
 	},
 	Histograms: map[string]*v1alpha1.HistogramMetric{
 		MetricJobStartupDurationSeconds: {
@@ -280,6 +780,50 @@ var defaultMetrics = v1alpha1.MetricsConfig{
 			},
 			Buckets: defaultRuntimeBuckets,
 		},
+		MetricJobQueueDurationSeconds: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyJobName,
+				labelKeyEventName,
+			},
+			Buckets: defaultRuntimeBuckets,
+		},
+		MetricMessageProcessingSeconds: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyMessageType,
+			},
+			Buckets: defaultRuntimeBuckets,
+		},
+		MetricEphemeralRunnerSetPatchDurationSeconds: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+			},
+			Buckets: defaultAPIRequestBuckets,
+		},
+		MetricVaultRequestDurationSeconds: {
+			Labels: []string{
+				labelKeyEnterprise,
+				labelKeyOrganization,
+				labelKeyRepository,
+				labelKeyRunnerScaleSetName,
+				labelKeyRunnerScaleSetNamespace,
+				labelKeyVaultType,
+				labelKeyVaultOperation,
+				labelKeyVaultResult,
+			},
+			Buckets: defaultAPIRequestBuckets,
+		},
 	},
 }
 
@@ -294,6 +838,11 @@ func (e *ExporterConfig) defaults() {
 		defaultMetrics := defaultMetrics
 		e.Metrics = &defaultMetrics
 	}
+	if e.Metrics.Push != nil && e.Metrics.Counters == nil && e.Metrics.Gauges == nil && e.Metrics.Histograms == nil {
+		defaultMetrics := defaultMetrics
+		defaultMetrics.Push = e.Metrics.Push
+		e.Metrics = &defaultMetrics
+	}
 }
 
 func NewExporter(config ExporterConfig) ServerExporter {
@@ -302,13 +851,7 @@ func NewExporter(config ExporterConfig) ServerExporter {
 
 	metrics := installMetrics(*config.Metrics, reg, config.Logger)
 
-	mux := http.NewServeMux()
-	mux.Handle(
-		config.ServerEndpoint,
-		promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}),
-	)
-
-	return &exporter{
+	e := &exporter{
 		logger: config.Logger.WithName("metrics"),
 		scaleSetLabels: prometheus.Labels{
 			labelKeyRunnerScaleSetName:      config.ScaleSetName,
@@ -318,14 +861,57 @@ func NewExporter(config ExporterConfig) ServerExporter {
 			labelKeyRepository:              config.Repository,
 		},
 		metrics: metrics,
-		srv: &http.Server{
+	}
+	e.publishInfo(config)
+
+	if !config.DisableServer {
+		mux := http.NewServeMux()
+		mux.Handle(
+			config.ServerEndpoint,
+			authenticated(
+				promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg, EnableOpenMetrics: true}),
+				config.BearerToken,
+				config.BasicAuthUsername,
+				config.BasicAuthPassword,
+			),
+		)
+		e.srv = &http.Server{
 			Addr:    config.ServerAddr,
 			Handler: mux,
-		},
+		}
+		if config.TLSCertFile != "" {
+			e.tls = &serverTLS{certFile: config.TLSCertFile, keyFile: config.TLSKeyFile}
+		}
 	}
+
+	if config.Metrics.Push != nil {
+		e.pusher = newPusher(config.Metrics.Push, reg, config.ScaleSetName, config.ScaleSetNamespace, e.logger.WithName("push"))
+	}
+
+	return e
 }
 
-var errUnknownMetricName = errors.New("unknown metric name")
+var (
+	errUnknownMetricName = errors.New("unknown metric name")
+	errUnknownLabelName  = errors.New("unknown label name")
+)
+
+// allowedLabels returns the labels of the metric that can be attached to it, logging the unknown ones.
+func allowedLabels(name string, labels []string, logger logr.Logger) []string {
+	if !slices.ContainsFunc(labels, func(label string) bool { return !slices.Contains(knownLabels, label) }) {
+		return labels
+	}
+
+	allowed := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if !slices.Contains(knownLabels, label) {
+			logger.Error(errUnknownLabelName, "name", label, "metric", name)
+			continue
+		}
+		allowed = append(allowed, label)
+	}
+	return allowed
+}
 
 func installMetrics(config v1alpha1.MetricsConfig, reg *prometheus.Registry, logger logr.Logger) *metrics {
 	logger.Info(
@@ -349,6 +935,7 @@ func installMetrics(config v1alpha1.MetricsConfig, reg *prometheus.Registry, log
 			continue
 		}
 
+		cfg.Labels = allowedLabels(name, cfg.Labels, logger)
 		g := prometheus.V2.NewGaugeVec(prometheus.GaugeVecOpts{
 			GaugeOpts: prometheus.GaugeOpts{
 				Subsystem: githubScaleSetSubsystem,
@@ -359,8 +946,9 @@ func installMetrics(config v1alpha1.MetricsConfig, reg *prometheus.Registry, log
 		})
 		reg.MustRegister(g)
 		metrics.gauges[name] = &gaugeMetric{
-			gauge:  g,
-			config: cfg,
+			gauge:       g,
+			config:      cfg,
+			cardinality: newCardinalityLimit(name, cfg.MaxCardinality, logger),
 		}
 	}
 
@@ -370,6 +958,7 @@ func installMetrics(config v1alpha1.MetricsConfig, reg *prometheus.Registry, log
 			logger.Error(errUnknownMetricName, "name", name, "kind", "counter")
 			continue
 		}
+		cfg.Labels = allowedLabels(name, cfg.Labels, logger)
 		c := prometheus.V2.NewCounterVec(prometheus.CounterVecOpts{
 			CounterOpts: prometheus.CounterOpts{
 				Subsystem: githubScaleSetSubsystem,
@@ -380,8 +969,9 @@ func installMetrics(config v1alpha1.MetricsConfig, reg *prometheus.Registry, log
 		})
 		reg.MustRegister(c)
 		metrics.counters[name] = &counterMetric{
-			counter: c,
-			config:  cfg,
+			counter:     c,
+			config:      cfg,
+			cardinality: newCardinalityLimit(name, cfg.MaxCardinality, logger),
 		}
 	}
 
@@ -392,24 +982,34 @@ func installMetrics(config v1alpha1.MetricsConfig, reg *prometheus.Registry, log
 			continue
 		}
 
+		cfg.Labels = allowedLabels(name, cfg.Labels, logger)
 		buckets := defaultRuntimeBuckets
 		if len(cfg.Buckets) > 0 {
 			buckets = cfg.Buckets
 		}
+		opts := prometheus.HistogramOpts{
+			Subsystem: githubScaleSetSubsystem,
+			Name:      strings.TrimPrefix(name, githubScaleSetSubsystemPrefix),
+			Help:      help,
+			Buckets:   buckets,
+		}
+		if cfg.NativeHistogramBucketFactor > 0 {
+			opts.NativeHistogramBucketFactor = cfg.NativeHistogramBucketFactor
+			opts.NativeHistogramMaxBucketNumber = defaultNativeHistogramMaxBuckets
+			if cfg.NativeHistogramMaxBuckets > 0 {
+				opts.NativeHistogramMaxBucketNumber = uint32(cfg.NativeHistogramMaxBuckets)
+			}
+		}
 		h := prometheus.V2.NewHistogramVec(prometheus.HistogramVecOpts{
-			HistogramOpts: prometheus.HistogramOpts{
-				Subsystem: githubScaleSetSubsystem,
-				Name:      strings.TrimPrefix(name, githubScaleSetSubsystemPrefix),
-				Help:      help,
-				Buckets:   buckets,
-			},
+			HistogramOpts:  opts,
 			VariableLabels: prometheus.UnconstrainedLabels(cfg.Labels),
 		})
 		cfg.Buckets = buckets
 		reg.MustRegister(h)
 		metrics.histograms[name] = &histogramMetric{
-			histogram: h,
-			config:    cfg,
+			histogram:   h,
+			config:      cfg,
+			cardinality: newCardinalityLimit(name, cfg.MaxCardinality, logger),
 		}
 	}
 
@@ -417,7 +1017,24 @@ func installMetrics(config v1alpha1.MetricsConfig, reg *prometheus.Registry, log
 }
 
 func (e *exporter) ListenAndServe(ctx context.Context) error {
-	e.logger.Info("starting metrics server", "addr", e.srv.Addr)
+	if e.pusher != nil {
+		pushCtx, cancelPush := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			e.pusher.run(pushCtx)
+		}()
+		// Wait for the final push before returning
+		defer func() { <-done }()
+		defer cancelPush()
+	}
+
+	if e.srv == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	e.logger.Info("starting metrics server", "addr", e.srv.Addr, "tls", e.tls != nil)
 	go func() {
 		<-ctx.Done()
 		e.logger.Info("stopping metrics server", "err", ctx.Err())
@@ -425,7 +1042,16 @@ func (e *exporter) ListenAndServe(ctx context.Context) error {
 		defer cancel()
 		e.srv.Shutdown(ctx)
 	}()
-	return e.srv.ListenAndServe()
+	var err error
+	if e.tls != nil {
+		err = e.srv.ListenAndServeTLS(e.tls.certFile, e.tls.keyFile)
+	} else {
+		err = e.srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errcode.Wrap(errcode.MetricsServer, err)
+	}
+	return nil
 }
 
 func (e *exporter) setGauge(name string, allLabels prometheus.Labels, val float64) {
@@ -437,7 +1063,19 @@ func (e *exporter) setGauge(name string, allLabels prometheus.Labels, val float6
 	for _, label := range m.config.Labels {
 		labels[label] = allLabels[label]
 	}
-	m.gauge.With(labels).Set(val)
+	m.gauge.With(m.cardinality.limit(m.config.Labels, labels)).Set(val)
+}
+
+func (e *exporter) addGauge(name string, allLabels prometheus.Labels, val float64) {
+	m, ok := e.gauges[name]
+	if !ok {
+		return
+	}
+	labels := make(prometheus.Labels, len(m.config.Labels))
+	for _, label := range m.config.Labels {
+		labels[label] = allLabels[label]
+	}
+	m.gauge.With(m.cardinality.limit(m.config.Labels, labels)).Add(val)
 }
 
 func (e *exporter) incCounter(name string, allLabels prometheus.Labels) {
@@ -449,7 +1087,7 @@ func (e *exporter) incCounter(name string, allLabels prometheus.Labels) {
 	for _, label := range m.config.Labels {
 		labels[label] = allLabels[label]
 	}
-	m.counter.With(labels).Inc()
+	m.counter.With(m.cardinality.limit(m.config.Labels, labels)).Inc()
 }
 
 func (e *exporter) observeHistogram(name string, allLabels prometheus.Labels, val float64) {
@@ -461,7 +1099,7 @@ func (e *exporter) observeHistogram(name string, allLabels prometheus.Labels, va
 	for _, label := range m.config.Labels {
 		labels[label] = allLabels[label]
 	}
-	m.histogram.With(labels).Observe(val)
+	m.histogram.With(m.cardinality.limit(m.config.Labels, labels)).Observe(val)
 }
 
 func (e *exporter) PublishStatic(min, max int) {
@@ -472,22 +1110,75 @@ func (e *exporter) PublishStatic(min, max int) {
 func (e *exporter) PublishStatistics(stats *actions.RunnerScaleSetStatistic) {
 	e.setGauge(MetricAssignedJobs, e.scaleSetLabels, float64(stats.TotalAssignedJobs))
 	e.setGauge(MetricRunningJobs, e.scaleSetLabels, float64(stats.TotalRunningJobs))
+	// The jobs waiting for a runner point at the pod scheduling, the jobs pending assignment at GitHub.
+	e.setGauge(MetricWaitingForRunnerJobs, e.scaleSetLabels, float64(max(stats.TotalAssignedJobs-stats.TotalRunningJobs, 0)))
+	e.setGauge(MetricPendingAssignmentJobs, e.scaleSetLabels, float64(stats.TotalAcquiredJobs))
 	e.setGauge(MetricRegisteredRunners, e.scaleSetLabels, float64(stats.TotalRegisteredRunners))
 	e.setGauge(MetricBusyRunners, e.scaleSetLabels, float64(stats.TotalBusyRunners))
 	e.setGauge(MetricIdleRunners, e.scaleSetLabels, float64(stats.TotalIdleRunners))
 }
 
+// publishInfo publishes the info metric of the listener, which does not change during its lifetime.
+// The min and max runners are the configured ones, the ones in effect being published by PublishStatic.
+func (e *exporter) publishInfo(config ExporterConfig) {
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+6)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyVersion] = build.Version
+	l[labelKeyCommit] = build.CommitSHA
+	l[labelKeyRunnerScaleSetID] = strconv.Itoa(config.ScaleSetID)
+	l[labelKeyMinRunners] = strconv.Itoa(config.MinRunners)
+	l[labelKeyMaxRunners] = strconv.Itoa(config.MaxRunners)
+	l[labelKeyConfigHash] = config.ConfigHash
+	e.setGauge(MetricListenerInfo, l, 1)
+}
+
+// PublishSession replaces the series of the previous message session, if any, with the one of the session.
+func (e *exporter) PublishSession(session *actions.RunnerScaleSetSession, createdAt time.Time) {
+	m, ok := e.gauges[MetricMessageSessionInfo]
+	if !ok {
+		return
+	}
+	m.gauge.Reset()
+
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+3)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeySessionOwner] = session.OwnerName
+	l[labelKeySessionCreatedAt] = createdAt.UTC().Format(time.RFC3339)
+	if session.SessionId != nil {
+		l[labelKeySessionID] = session.SessionId.String()
+	} else {
+		l[labelKeySessionID] = ""
+	}
+	e.setGauge(MetricMessageSessionInfo, l, 1)
+}
+
+func (e *exporter) PublishJobAssigned(msg *actions.JobAssigned) {
+	e.queueJob(&msg.JobMessageBase)
+}
+
 func (e *exporter) PublishJobStarted(msg *actions.JobStarted) {
 	l := e.startedJobLabels(msg)
 	e.incCounter(MetricStartedJobsTotal, l)
+	e.acquireJob(&msg.JobMessageBase)
 
 	startupDuration := msg.RunnerAssignTime.Unix() - msg.ScaleSetAssignTime.Unix()
 	e.observeHistogram(MetricJobStartupDurationSeconds, l, float64(startupDuration))
+
+	// The queue time is only observed when it is sent with the message.
+	if !msg.QueueTime.IsZero() {
+		queueDuration := msg.RunnerAssignTime.Unix() - msg.QueueTime.Unix()
+		e.observeHistogram(MetricJobQueueDurationSeconds, l, float64(queueDuration))
+	}
 }
 
 func (e *exporter) PublishJobCompleted(msg *actions.JobCompleted) {
 	l := e.completedJobLabels(msg)
 	e.incCounter(MetricCompletedJobsTotal, l)
+	e.completeJob(msg)
 
 	executionDuration := msg.FinishTime.Unix() - msg.RunnerAssignTime.Unix()
 	e.observeHistogram(MetricJobExecutionDurationSeconds, l, float64(executionDuration))
@@ -497,13 +1188,221 @@ func (e *exporter) PublishDesiredRunners(count int) {
 	e.setGauge(MetricDesiredRunners, e.scaleSetLabels, float64(count))
 }
 
+func (e *exporter) PublishMessageProcessingDuration(messageType string, duration time.Duration) {
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+1)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyMessageType] = messageType
+	e.observeHistogram(MetricMessageProcessingSeconds, l, duration.Seconds())
+}
+
+func (e *exporter) PublishEphemeralRunnerSetPatchAttempt() {
+	e.incCounter(MetricEphemeralRunnerSetPatchAttemptsTotal, e.scaleSetLabels)
+}
+
+func (e *exporter) PublishEphemeralRunnerSetPatch(duration time.Duration, err error) {
+	if err != nil {
+		e.incCounter(MetricEphemeralRunnerSetPatchFailuresTotal, e.scaleSetLabels)
+	} else {
+		e.incCounter(MetricEphemeralRunnerSetPatchSuccessesTotal, e.scaleSetLabels)
+	}
+	e.observeHistogram(MetricEphemeralRunnerSetPatchDurationSeconds, e.scaleSetLabels, duration.Seconds())
+}
+
+func (e *exporter) PublishEphemeralRunnerSetPatchSuppressed() {
+	e.incCounter(MetricEphemeralRunnerSetPatchesSuppressedTotal, e.scaleSetLabels)
+}
+
+// PublishSessionHeartbeat is called before every message is fetched with the age of the message session
+// and the expiry of its message queue token, zero if it is unknown.
+func (e *exporter) PublishSessionHeartbeat(age time.Duration, expiresAt time.Time) {
+	e.setGauge(MetricMessageSessionAgeSeconds, e.scaleSetLabels, age.Seconds())
+	if !expiresAt.IsZero() {
+		e.setGauge(MetricMessageSessionExpiryTimestampSeconds, e.scaleSetLabels, float64(expiresAt.Unix()))
+	}
+}
+
+// PublishSessionRefreshFailure is called when the message session fails to be refreshed.
+func (e *exporter) PublishSessionRefreshFailure() {
+	e.incCounter(MetricMessageSessionRefreshFailuresTotal, e.scaleSetLabels)
+}
+
+// PublishCircuitBreakerState is called when the circuit breaker opens or closes.
+func (e *exporter) PublishCircuitBreakerState(open bool) {
+	if !open {
+		e.setGauge(MetricActionsCircuitBreakerOpen, e.scaleSetLabels, 0)
+		return
+	}
+	e.setGauge(MetricActionsCircuitBreakerOpen, e.scaleSetLabels, 1)
+	e.incCounter(MetricActionsCircuitBreakerOpensTotal, e.scaleSetLabels)
+}
+
+// PublishBudgetExhausted is called when the runner minutes budget of the day is exhausted or renewed.
+func (e *exporter) PublishBudgetExhausted(exhausted bool) {
+	if exhausted {
+		e.setGauge(MetricBudgetExhausted, e.scaleSetLabels, 1)
+		return
+	}
+	e.setGauge(MetricBudgetExhausted, e.scaleSetLabels, 0)
+}
+
+// PublishStuckPendingRunners is called with the ephemeral runners stuck pending after every list of the runners.
+func (e *exporter) PublishStuckPendingRunners(count int) {
+	e.setGauge(MetricStuckPendingRunners, e.scaleSetLabels, float64(count))
+}
+
+// PublishCapacityForecast is called with the peak of every capacity forecast.
+func (e *exporter) PublishCapacityForecast(peakRunners int, peakTime time.Time) {
+	e.setGauge(MetricForecastPeakRunners, e.scaleSetLabels, float64(peakRunners))
+	e.setGauge(MetricForecastPeakTimestampSeconds, e.scaleSetLabels, float64(peakTime.Unix()))
+}
+
+// PublishQuarantinedMessage is called when a job message fails validation and is not handled.
+func (e *exporter) PublishQuarantinedMessage(messageType string) {
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+1)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyMessageType] = messageType
+	e.incCounter(MetricQuarantinedMessagesTotal, l)
+}
+
+// PublishPanic is called when a panic of a component of the listener is recovered and the component restarted.
+func (e *exporter) PublishPanic(component string) {
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+1)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyComponent] = component
+	e.incCounter(MetricPanicsTotal, l)
+}
+
+// PublishEphemeralRunnerMiss is called when the ephemeral runner of a started job is not found,
+// with the policy the miss was handled with.
+func (e *exporter) PublishEphemeralRunnerMiss(policy string) {
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+1)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyScalingPolicy] = policy
+	e.incCounter(MetricEphemeralRunnerMissesTotal, l)
+}
+
+// PublishMisroutedJob is called when a job requires labels the scale set does not provide,
+// with the policy the job was handled with.
+func (e *exporter) PublishMisroutedJob(policy string) {
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+1)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyScalingPolicy] = policy
+	e.incCounter(MetricMisroutedJobsTotal, l)
+}
+
+// PublishRateLimit is called with the rate limit of GitHub reported by a response.
+func (e *exporter) PublishRateLimit(resource string, limit, remaining int, reset time.Time) {
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+1)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyRateLimitResource] = resource
+	e.setGauge(MetricRateLimitLimit, l, float64(limit))
+	e.setGauge(MetricRateLimitRemaining, l, float64(remaining))
+	e.setGauge(MetricRateLimitResetTimestamp, l, float64(reset.Unix()))
+}
+
+// PublishZoneDesiredRunners is called with the share of the desired runners of a topology zone.
+func (e *exporter) PublishZoneDesiredRunners(zone string, count int) {
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+1)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyZone] = zone
+	e.setGauge(MetricZoneDesiredRunners, l, float64(count))
+}
+
+// PublishVaultRequest is called for every request made to the vault, e.g. to read the app config
+// or to sign with the GitHub App key. A throttled request has the result "throttled".
+func (e *exporter) PublishVaultRequest(vaultType, operation, result string, duration time.Duration) {
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+3)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyVaultType] = vaultType
+	l[labelKeyVaultOperation] = operation
+	l[labelKeyVaultResult] = result
+	e.incCounter(MetricVaultRequestsTotal, l)
+	e.observeHistogram(MetricVaultRequestDurationSeconds, l, duration.Seconds())
+}
+
+// PublishScalingPolicy replaces the series of the previous scaling decision with the policy, the scheduled override
+// and the clamps in effect. The clamps are joined with commas.
+func (e *exporter) PublishScalingPolicy(policy, schedule string, clamps []string) {
+	m, ok := e.gauges[MetricScalingPolicyInfo]
+	if !ok {
+		return
+	}
+	m.gauge.Reset()
+
+	l := make(prometheus.Labels, len(e.scaleSetLabels)+3)
+	for k, v := range e.scaleSetLabels {
+		l[k] = v
+	}
+	l[labelKeyScalingPolicy] = policy
+	l[labelKeySchedule] = schedule
+	l[labelKeyClamps] = strings.Join(clamps, ",")
+	e.setGauge(MetricScalingPolicyInfo, l, 1)
+}
+
 type discard struct{}
 
-func (*discard) PublishStatic(int, int)                             {}
-func (*discard) PublishStatistics(*actions.RunnerScaleSetStatistic) {}
-func (*discard) PublishJobStarted(*actions.JobStarted)              {}
-func (*discard) PublishJobCompleted(*actions.JobCompleted)          {}
-func (*discard) PublishDesiredRunners(int)                          {}
+func (*discard) PublishStatic(int, int)                                    {}
+func (*discard) PublishStatistics(*actions.RunnerScaleSetStatistic)        {}
+func (*discard) PublishJobAssigned(*actions.JobAssigned)                   {}
+func (*discard) PublishSession(*actions.RunnerScaleSetSession, time.Time)  {}
+func (*discard) PublishSessionHeartbeat(time.Duration, time.Time)          {}
+func (*discard) PublishSessionRefreshFailure()                             {}
+func (*discard) PublishJobStarted(*actions.JobStarted)                     {}
+func (*discard) PublishJobCompleted(*actions.JobCompleted)                 {}
+func (*discard) PublishDesiredRunners(int)                                 {}
+func (*discard) PublishMessageProcessingDuration(string, time.Duration)    {}
+func (*discard) PublishEphemeralRunnerSetPatchAttempt()                    {}
+func (*discard) PublishEphemeralRunnerSetPatch(time.Duration, error)       {}
+func (*discard) PublishEphemeralRunnerSetPatchSuppressed()                 {}
+func (*discard) PublishCircuitBreakerState(bool)                           {}
+func (*discard) PublishQuarantinedMessage(string)                          {}
+func (*discard) PublishPanic(string)                                       {}
+func (*discard) PublishEphemeralRunnerMiss(string)                         {}
+func (*discard) PublishMisroutedJob(string)                                {}
+func (*discard) PublishRateLimit(string, int, int, time.Time)              {}
+func (*discard) PublishScalingPolicy(string, string, []string)             {}
+func (*discard) PublishBudgetExhausted(bool)                               {}
+func (*discard) PublishStuckPendingRunners(int)                            {}
+func (*discard) PublishCapacityForecast(int, time.Time)                    {}
+func (*discard) PublishZoneDesiredRunners(string, int)                     {}
+func (*discard) PublishVaultRequest(string, string, string, time.Duration) {}
+
+// defaultNativeHistogramMaxBuckets bounds the buckets of the native histograms, as the factor alone does not
+// bound them for the observations spread over many orders of magnitude.
+const defaultNativeHistogramMaxBuckets = 160
+
+// defaultAPIRequestBuckets are the buckets for Kubernetes and vault API requests, which take milliseconds
+// unless they are retried.
+var defaultAPIRequestBuckets []float64 = []float64{
+	0.005,
+	0.01,
+	0.025,
+	0.05,
+	0.1,
+	0.25,
+	0.5,
+	1,
+	2.5,
+	5,
+	10,
+	30,
+}
 
 var defaultRuntimeBuckets []float64 = []float64{
 	0.01,
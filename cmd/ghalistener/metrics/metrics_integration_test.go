@@ -90,6 +90,7 @@ func TestMetricsWithWorkflowRefParsing(t *testing.T) {
 				labelKeyJobWorkflowName:   tt.wantName,
 				labelKeyJobWorkflowTarget: tt.wantTarget,
 				labelKeyEventName:         tt.jobBase.EventName,
+				labelKeyRunnerLabels:      "",
 			}
 
 			// Assert all expected labels match
@@ -1,11 +1,20 @@
 package metrics
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -263,3 +272,376 @@ func TestExporterConfigDefaults(t *testing.T) {
 
 	assert.Equal(t, want, config)
 }
+
+func TestExporter_PublishEphemeralRunnerSetPatch(t *testing.T) {
+	config := ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Repository:        "repo",
+		Logger:            logr.Discard(),
+	}
+
+	exporter, ok := NewExporter(config).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	exporter.PublishEphemeralRunnerSetPatchAttempt()
+	exporter.PublishEphemeralRunnerSetPatchAttempt()
+	exporter.PublishEphemeralRunnerSetPatch(2*time.Second, nil)
+	exporter.PublishEphemeralRunnerSetPatchAttempt()
+	exporter.PublishEphemeralRunnerSetPatch(time.Second, errors.New("conflict"))
+	exporter.PublishEphemeralRunnerSetPatchSuppressed()
+
+	counter := func(name string) float64 {
+		return testutil.ToFloat64(exporter.counters[name].counter.With(exporter.scaleSetLabels))
+	}
+	assert.Equal(t, 3.0, counter(MetricEphemeralRunnerSetPatchAttemptsTotal))
+	assert.Equal(t, 1.0, counter(MetricEphemeralRunnerSetPatchSuccessesTotal))
+	assert.Equal(t, 1.0, counter(MetricEphemeralRunnerSetPatchFailuresTotal))
+	assert.Equal(t, 1.0, counter(MetricEphemeralRunnerSetPatchesSuppressedTotal))
+
+	var m dto.Metric
+	histogram := exporter.histograms[MetricEphemeralRunnerSetPatchDurationSeconds].histogram
+	require.NoError(t, histogram.With(exporter.scaleSetLabels).(prometheus.Histogram).Write(&m))
+	assert.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+	assert.Equal(t, 3.0, m.GetHistogram().GetSampleSum())
+}
+
+func TestExporter_NativeHistograms(t *testing.T) {
+	patchDuration := *defaultMetrics.Histograms[MetricEphemeralRunnerSetPatchDurationSeconds]
+	patchDuration.NativeHistogramBucketFactor = 1.1
+	config := ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Repository:        "repo",
+		Logger:            logr.Discard(),
+		Metrics: &v1alpha1.MetricsConfig{
+			Histograms: map[string]*v1alpha1.HistogramMetric{
+				MetricEphemeralRunnerSetPatchDurationSeconds: &patchDuration,
+			},
+		},
+	}
+
+	exporter, ok := NewExporter(config).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+	exporter.PublishEphemeralRunnerSetPatch(2*time.Second, nil)
+
+	var m dto.Metric
+	histogram := exporter.histograms[MetricEphemeralRunnerSetPatchDurationSeconds].histogram
+	require.NoError(t, histogram.With(exporter.scaleSetLabels).(prometheus.Histogram).Write(&m))
+	assert.NotNil(t, m.GetHistogram().Schema, "the native histogram is recorded")
+	assert.NotEmpty(t, m.GetHistogram().GetBucket(), "the classic buckets are kept")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	exporter.srv.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/openmetrics-text")
+	assert.True(t, strings.HasSuffix(rec.Body.String(), "# EOF\n"))
+}
+
+func TestExporter_JobDemand(t *testing.T) {
+	config := ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Logger:            logr.Discard(),
+	}
+
+	exporter, ok := NewExporter(config).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	job := func(id, repository string, labels ...string) actions.JobMessageBase {
+		return actions.JobMessageBase{
+			JobID:          id,
+			OwnerName:      "org",
+			RepositoryName: repository,
+			RequestLabels:  labels,
+		}
+	}
+	gauge := func(name, repository, runnerLabels string) float64 {
+		return testutil.ToFloat64(exporter.gauges[name].gauge.With(prometheus.Labels{
+			labelKeyEnterprise:              "",
+			labelKeyOrganization:            "org",
+			labelKeyRepository:              repository,
+			labelKeyRunnerScaleSetName:      "test-scale-set",
+			labelKeyRunnerScaleSetNamespace: "test-namespace",
+			labelKeyRunnerLabels:            runnerLabels,
+		}))
+	}
+
+	exporter.PublishJobAssigned(&actions.JobAssigned{JobMessageBase: job("1", "a", "self-hosted", "linux")})
+	exporter.PublishJobAssigned(&actions.JobAssigned{JobMessageBase: job("2", "a", "linux", "self-hosted")})
+	exporter.PublishJobAssigned(&actions.JobAssigned{JobMessageBase: job("3", "b", "gpu")})
+	// duplicated messages are counted once
+	exporter.PublishJobAssigned(&actions.JobAssigned{JobMessageBase: job("3", "b", "gpu")})
+
+	assert.Equal(t, 2.0, gauge(MetricQueuedJobs, "a", "linux,self-hosted"))
+	assert.Equal(t, 1.0, gauge(MetricQueuedJobs, "b", "gpu"))
+
+	exporter.PublishJobStarted(&actions.JobStarted{JobMessageBase: job("1", "a", "self-hosted", "linux"), RunnerID: 1})
+	assert.Equal(t, 1.0, gauge(MetricQueuedJobs, "a", "linux,self-hosted"))
+	assert.Equal(t, 1.0, gauge(MetricAcquiredJobs, "a", "linux,self-hosted"))
+
+	exporter.PublishJobCompleted(&actions.JobCompleted{JobMessageBase: job("1", "a", "self-hosted", "linux"), RunnerId: 1})
+	assert.Equal(t, 0.0, gauge(MetricAcquiredJobs, "a", "linux,self-hosted"))
+
+	// job cancelled before getting a runner
+	exporter.PublishJobCompleted(&actions.JobCompleted{JobMessageBase: job("3", "b", "gpu")})
+	assert.Equal(t, 0.0, gauge(MetricQueuedJobs, "b", "gpu"))
+
+	// job completed before its start is published
+	exporter.PublishJobCompleted(&actions.JobCompleted{JobMessageBase: job("2", "a", "linux", "self-hosted"), RunnerId: 2})
+	exporter.PublishJobStarted(&actions.JobStarted{JobMessageBase: job("2", "a", "linux", "self-hosted"), RunnerID: 2})
+	assert.Equal(t, 0.0, gauge(MetricQueuedJobs, "a", "linux,self-hosted"))
+	assert.Equal(t, 0.0, gauge(MetricAcquiredJobs, "a", "linux,self-hosted"))
+	assert.Empty(t, exporter.jobs.queued)
+	assert.Empty(t, exporter.jobs.acquired)
+	assert.Empty(t, exporter.jobs.completed)
+}
+
+func TestExporter_JobDurations(t *testing.T) {
+	config := ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Logger:            logr.Discard(),
+	}
+
+	exporter, ok := NewExporter(config).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	queueTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	job := actions.JobMessageBase{
+		JobID:              "1",
+		OwnerName:          "org",
+		RepositoryName:     "repo",
+		JobDisplayName:     "build",
+		EventName:          "push",
+		QueueTime:          queueTime,
+		ScaleSetAssignTime: queueTime.Add(10 * time.Second),
+		RunnerAssignTime:   queueTime.Add(40 * time.Second),
+		FinishTime:         queueTime.Add(100 * time.Second),
+	}
+	exporter.PublishJobStarted(&actions.JobStarted{JobMessageBase: job, RunnerID: 1})
+	exporter.PublishJobCompleted(&actions.JobCompleted{JobMessageBase: job, Result: "succeeded", RunnerId: 1})
+
+	// jobs without a queue time are not observed
+	job.JobID = "2"
+	job.QueueTime = time.Time{}
+	exporter.PublishJobStarted(&actions.JobStarted{JobMessageBase: job, RunnerID: 2})
+
+	observed := func(name string) (uint64, float64) {
+		var metrics []*dto.Metric
+		ch := make(chan prometheus.Metric, 10)
+		exporter.histograms[name].histogram.Collect(ch)
+		close(ch)
+		for metric := range ch {
+			var m dto.Metric
+			require.NoError(t, metric.Write(&m))
+			metrics = append(metrics, &m)
+		}
+		require.Len(t, metrics, 1)
+		return metrics[0].GetHistogram().GetSampleCount(), metrics[0].GetHistogram().GetSampleSum()
+	}
+
+	count, sum := observed(MetricJobQueueDurationSeconds)
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 40.0, sum)
+
+	count, sum = observed(MetricJobStartupDurationSeconds)
+	assert.Equal(t, uint64(2), count)
+	assert.Equal(t, 60.0, sum)
+
+	count, sum = observed(MetricJobExecutionDurationSeconds)
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 60.0, sum)
+}
+
+func TestExporter_ListenerInfo(t *testing.T) {
+	exporter, ok := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Logger:            logr.Discard(),
+		ScaleSetID:        7,
+		MinRunners:        1,
+		MaxRunners:        10,
+		ConfigHash:        "0123456789ab",
+	}).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	gauge := exporter.gauges[MetricListenerInfo].gauge
+	assert.Equal(t, 1, testutil.CollectAndCount(gauge))
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge.With(prometheus.Labels{
+		labelKeyEnterprise:              "",
+		labelKeyOrganization:            "org",
+		labelKeyRepository:              "",
+		labelKeyRunnerScaleSetName:      "test-scale-set",
+		labelKeyRunnerScaleSetNamespace: "test-namespace",
+		labelKeyVersion:                 "NA",
+		labelKeyCommit:                  "NA",
+		labelKeyRunnerScaleSetID:        "7",
+		labelKeyMinRunners:              "1",
+		labelKeyMaxRunners:              "10",
+		labelKeyConfigHash:              "0123456789ab",
+	})))
+}
+
+func TestExporter_PublishSession(t *testing.T) {
+	exporter, ok := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Logger:            logr.Discard(),
+	}).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	sessionLabels := func(id string) prometheus.Labels {
+		return prometheus.Labels{
+			labelKeyEnterprise:              "",
+			labelKeyOrganization:            "org",
+			labelKeyRepository:              "",
+			labelKeyRunnerScaleSetName:      "test-scale-set",
+			labelKeyRunnerScaleSetNamespace: "test-namespace",
+			labelKeySessionID:               id,
+			labelKeySessionOwner:            "listener-pod",
+			labelKeySessionCreatedAt:        "2024-01-02T03:04:05Z",
+		}
+	}
+
+	first := uuid.New()
+	exporter.PublishSession(&actions.RunnerScaleSetSession{SessionId: &first, OwnerName: "listener-pod"}, createdAt)
+	second := uuid.New()
+	exporter.PublishSession(&actions.RunnerScaleSetSession{SessionId: &second, OwnerName: "listener-pod"}, createdAt)
+
+	gauge := exporter.gauges[MetricMessageSessionInfo].gauge
+	assert.Equal(t, 1, testutil.CollectAndCount(gauge), "the series of the previous session is removed")
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge.With(sessionLabels(second.String()))))
+}
+
+func TestExporter_PublishSessionHeartbeat(t *testing.T) {
+	exporter, ok := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Logger:            logr.Discard(),
+	}).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	exporter.PublishSessionHeartbeat(90*time.Second, expiresAt)
+	exporter.PublishSessionHeartbeat(2*time.Minute, time.Time{})
+	exporter.PublishSessionRefreshFailure()
+	exporter.PublishSessionRefreshFailure()
+
+	assert.Equal(t, 120.0, testutil.ToFloat64(exporter.gauges[MetricMessageSessionAgeSeconds].gauge.With(exporter.scaleSetLabels)))
+	assert.Equal(t, float64(expiresAt.Unix()), testutil.ToFloat64(exporter.gauges[MetricMessageSessionExpiryTimestampSeconds].gauge.With(exporter.scaleSetLabels)),
+		"an unknown expiry keeps the last known one")
+	assert.Equal(t, 2.0, testutil.ToFloat64(exporter.counters[MetricMessageSessionRefreshFailuresTotal].counter.With(exporter.scaleSetLabels)))
+}
+
+func TestExporter_PublishStatistics(t *testing.T) {
+	exporter, ok := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Logger:            logr.Discard(),
+	}).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	exporter.PublishStatistics(&actions.RunnerScaleSetStatistic{TotalAcquiredJobs: 2, TotalAssignedJobs: 5, TotalRunningJobs: 3})
+	assert.Equal(t, 2.0, testutil.ToFloat64(exporter.gauges[MetricWaitingForRunnerJobs].gauge.With(exporter.scaleSetLabels)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(exporter.gauges[MetricPendingAssignmentJobs].gauge.With(exporter.scaleSetLabels)))
+
+	exporter.PublishStatistics(&actions.RunnerScaleSetStatistic{TotalAssignedJobs: 1, TotalRunningJobs: 2})
+	assert.Equal(t, 0.0, testutil.ToFloat64(exporter.gauges[MetricWaitingForRunnerJobs].gauge.With(exporter.scaleSetLabels)),
+		"the runners picking up jobs just assigned do not make the count negative")
+}
+
+func TestExporter_PublishJobCompleted(t *testing.T) {
+	exporter, ok := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Logger:            logr.Discard(),
+	}).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	job := func(id string) actions.JobMessageBase {
+		return actions.JobMessageBase{JobID: id, OwnerName: "org", RepositoryName: "repo", JobDisplayName: "build", EventName: "push"}
+	}
+	exporter.PublishJobCompleted(&actions.JobCompleted{JobMessageBase: job("1"), Result: "succeeded", RunnerName: "runner-1"})
+	exporter.PublishJobCompleted(&actions.JobCompleted{JobMessageBase: job("2"), Result: "failed", RunnerName: "runner-2"})
+	exporter.PublishJobCompleted(&actions.JobCompleted{JobMessageBase: job("3"), Result: "failed", RunnerName: "runner-3"})
+	exporter.PublishJobCompleted(&actions.JobCompleted{JobMessageBase: job("4"), Result: "canceled"})
+
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(exporter.counters[MetricCompletedJobsTotal].counter.With(prometheus.Labels{
+			labelKeyEnterprise:              "",
+			labelKeyOrganization:            "org",
+			labelKeyRepository:              "repo",
+			labelKeyRunnerScaleSetName:      "test-scale-set",
+			labelKeyRunnerScaleSetNamespace: "test-namespace",
+			labelKeyJobName:                 "build",
+			labelKeyEventName:               "push",
+			labelKeyJobResult:               result,
+		}))
+	}
+	assert.Equal(t, 1.0, counter("succeeded"))
+	assert.Equal(t, 2.0, counter("failed"))
+	assert.Equal(t, 1.0, counter("canceled"))
+}
+
+func TestExporter_PublishPanic(t *testing.T) {
+	exporter, ok := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Logger:            logr.Discard(),
+	}).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	exporter.PublishPanic("listener")
+	exporter.PublishPanic("listener")
+	exporter.PublishPanic("telemetry")
+
+	counter := func(component string) float64 {
+		l := prometheus.Labels{labelKeyComponent: component}
+		for k, v := range exporter.scaleSetLabels {
+			l[k] = v
+		}
+		return testutil.ToFloat64(exporter.counters[MetricPanicsTotal].counter.With(l))
+	}
+	assert.Equal(t, 2.0, counter("listener"))
+	assert.Equal(t, 1.0, counter("telemetry"))
+}
+
+func TestExporter_PublishScalingPolicy(t *testing.T) {
+	exporter, ok := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Organization:      "org",
+		Logger:            logr.Discard(),
+	}).(*exporter)
+	require.True(t, ok, "expected exporter to be of type *exporter")
+
+	exporter.PublishScalingPolicy("strict", "", nil)
+	exporter.PublishScalingPolicy("predictive", "business-hours", []string{"max-runners", "scale-step"})
+
+	gauge := exporter.gauges[MetricScalingPolicyInfo].gauge
+	assert.Equal(t, 1, testutil.CollectAndCount(gauge), "the series of the previous decision is replaced")
+
+	l := prometheus.Labels{
+		labelKeyScalingPolicy: "predictive",
+		labelKeySchedule:      "business-hours",
+		labelKeyClamps:        "max-runners,scale-step",
+	}
+	for k, v := range exporter.scaleSetLabels {
+		l[k] = v
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge.With(l)))
+}
@@ -6,6 +6,8 @@ import (
 	actions "github.com/actions/actions-runner-controller/github/actions"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Publisher is an autogenerated mock type for the Publisher type
@@ -13,11 +15,51 @@ type Publisher struct {
 	mock.Mock
 }
 
+// PublishBudgetExhausted provides a mock function with given fields: exhausted
+func (_m *Publisher) PublishBudgetExhausted(exhausted bool) {
+	_m.Called(exhausted)
+}
+
+// PublishCapacityForecast provides a mock function with given fields: peakRunners, peakTime
+func (_m *Publisher) PublishCapacityForecast(peakRunners int, peakTime time.Time) {
+	_m.Called(peakRunners, peakTime)
+}
+
+// PublishCircuitBreakerState provides a mock function with given fields: open
+func (_m *Publisher) PublishCircuitBreakerState(open bool) {
+	_m.Called(open)
+}
+
 // PublishDesiredRunners provides a mock function with given fields: count
 func (_m *Publisher) PublishDesiredRunners(count int) {
 	_m.Called(count)
 }
 
+// PublishEphemeralRunnerMiss provides a mock function with given fields: policy
+func (_m *Publisher) PublishEphemeralRunnerMiss(policy string) {
+	_m.Called(policy)
+}
+
+// PublishEphemeralRunnerSetPatch provides a mock function with given fields: duration, err
+func (_m *Publisher) PublishEphemeralRunnerSetPatch(duration time.Duration, err error) {
+	_m.Called(duration, err)
+}
+
+// PublishEphemeralRunnerSetPatchAttempt provides a mock function with given fields:
+func (_m *Publisher) PublishEphemeralRunnerSetPatchAttempt() {
+	_m.Called()
+}
+
+// PublishEphemeralRunnerSetPatchSuppressed provides a mock function with given fields:
+func (_m *Publisher) PublishEphemeralRunnerSetPatchSuppressed() {
+	_m.Called()
+}
+
+// PublishJobAssigned provides a mock function with given fields: msg
+func (_m *Publisher) PublishJobAssigned(msg *actions.JobAssigned) {
+	_m.Called(msg)
+}
+
 // PublishJobCompleted provides a mock function with given fields: msg
 func (_m *Publisher) PublishJobCompleted(msg *actions.JobCompleted) {
 	_m.Called(msg)
@@ -28,6 +70,51 @@ func (_m *Publisher) PublishJobStarted(msg *actions.JobStarted) {
 	_m.Called(msg)
 }
 
+// PublishMessageProcessingDuration provides a mock function with given fields: messageType, duration
+func (_m *Publisher) PublishMessageProcessingDuration(messageType string, duration time.Duration) {
+	_m.Called(messageType, duration)
+}
+
+// PublishMisroutedJob provides a mock function with given fields: policy
+func (_m *Publisher) PublishMisroutedJob(policy string) {
+	_m.Called(policy)
+}
+
+// PublishPanic provides a mock function with given fields: component
+func (_m *Publisher) PublishPanic(component string) {
+	_m.Called(component)
+}
+
+// PublishQuarantinedMessage provides a mock function with given fields: messageType
+func (_m *Publisher) PublishQuarantinedMessage(messageType string) {
+	_m.Called(messageType)
+}
+
+// PublishRateLimit provides a mock function with given fields: resource, limit, remaining, reset
+func (_m *Publisher) PublishRateLimit(resource string, limit int, remaining int, reset time.Time) {
+	_m.Called(resource, limit, remaining, reset)
+}
+
+// PublishScalingPolicy provides a mock function with given fields: policy, schedule, clamps
+func (_m *Publisher) PublishScalingPolicy(policy string, schedule string, clamps []string) {
+	_m.Called(policy, schedule, clamps)
+}
+
+// PublishSession provides a mock function with given fields: session, createdAt
+func (_m *Publisher) PublishSession(session *actions.RunnerScaleSetSession, createdAt time.Time) {
+	_m.Called(session, createdAt)
+}
+
+// PublishSessionHeartbeat provides a mock function with given fields: age, expiresAt
+func (_m *Publisher) PublishSessionHeartbeat(age time.Duration, expiresAt time.Time) {
+	_m.Called(age, expiresAt)
+}
+
+// PublishSessionRefreshFailure provides a mock function with given fields:
+func (_m *Publisher) PublishSessionRefreshFailure() {
+	_m.Called()
+}
+
 // PublishStatic provides a mock function with given fields: min, max
 func (_m *Publisher) PublishStatic(min int, max int) {
 	_m.Called(min, max)
@@ -38,6 +125,21 @@ func (_m *Publisher) PublishStatistics(stats *actions.RunnerScaleSetStatistic) {
 	_m.Called(stats)
 }
 
+// PublishStuckPendingRunners provides a mock function with given fields: count
+func (_m *Publisher) PublishStuckPendingRunners(count int) {
+	_m.Called(count)
+}
+
+// PublishVaultRequest provides a mock function with given fields: vaultType, operation, result, duration
+func (_m *Publisher) PublishVaultRequest(vaultType string, operation string, result string, duration time.Duration) {
+	_m.Called(vaultType, operation, result, duration)
+}
+
+// PublishZoneDesiredRunners provides a mock function with given fields: zone, count
+func (_m *Publisher) PublishZoneDesiredRunners(zone string, count int) {
+	_m.Called(zone, count)
+}
+
 // NewPublisher creates a new instance of Publisher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewPublisher(t interface {
@@ -8,6 +8,8 @@ import (
 	actions "github.com/actions/actions-runner-controller/github/actions"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // ServerPublisher is an autogenerated mock type for the ServerPublisher type
@@ -29,11 +31,51 @@ func (_m *ServerPublisher) ListenAndServe(ctx context.Context) error {
 	return r0
 }
 
+// PublishBudgetExhausted provides a mock function with given fields: exhausted
+func (_m *ServerPublisher) PublishBudgetExhausted(exhausted bool) {
+	_m.Called(exhausted)
+}
+
+// PublishCapacityForecast provides a mock function with given fields: peakRunners, peakTime
+func (_m *ServerPublisher) PublishCapacityForecast(peakRunners int, peakTime time.Time) {
+	_m.Called(peakRunners, peakTime)
+}
+
+// PublishCircuitBreakerState provides a mock function with given fields: open
+func (_m *ServerPublisher) PublishCircuitBreakerState(open bool) {
+	_m.Called(open)
+}
+
 // PublishDesiredRunners provides a mock function with given fields: count
 func (_m *ServerPublisher) PublishDesiredRunners(count int) {
 	_m.Called(count)
 }
 
+// PublishEphemeralRunnerMiss provides a mock function with given fields: policy
+func (_m *ServerPublisher) PublishEphemeralRunnerMiss(policy string) {
+	_m.Called(policy)
+}
+
+// PublishEphemeralRunnerSetPatch provides a mock function with given fields: duration, err
+func (_m *ServerPublisher) PublishEphemeralRunnerSetPatch(duration time.Duration, err error) {
+	_m.Called(duration, err)
+}
+
+// PublishEphemeralRunnerSetPatchAttempt provides a mock function with given fields:
+func (_m *ServerPublisher) PublishEphemeralRunnerSetPatchAttempt() {
+	_m.Called()
+}
+
+// PublishEphemeralRunnerSetPatchSuppressed provides a mock function with given fields:
+func (_m *ServerPublisher) PublishEphemeralRunnerSetPatchSuppressed() {
+	_m.Called()
+}
+
+// PublishJobAssigned provides a mock function with given fields: msg
+func (_m *ServerPublisher) PublishJobAssigned(msg *actions.JobAssigned) {
+	_m.Called(msg)
+}
+
 // PublishJobCompleted provides a mock function with given fields: msg
 func (_m *ServerPublisher) PublishJobCompleted(msg *actions.JobCompleted) {
 	_m.Called(msg)
@@ -44,6 +86,51 @@ func (_m *ServerPublisher) PublishJobStarted(msg *actions.JobStarted) {
 	_m.Called(msg)
 }
 
+// PublishMessageProcessingDuration provides a mock function with given fields: messageType, duration
+func (_m *ServerPublisher) PublishMessageProcessingDuration(messageType string, duration time.Duration) {
+	_m.Called(messageType, duration)
+}
+
+// PublishMisroutedJob provides a mock function with given fields: policy
+func (_m *ServerPublisher) PublishMisroutedJob(policy string) {
+	_m.Called(policy)
+}
+
+// PublishPanic provides a mock function with given fields: component
+func (_m *ServerPublisher) PublishPanic(component string) {
+	_m.Called(component)
+}
+
+// PublishQuarantinedMessage provides a mock function with given fields: messageType
+func (_m *ServerPublisher) PublishQuarantinedMessage(messageType string) {
+	_m.Called(messageType)
+}
+
+// PublishRateLimit provides a mock function with given fields: resource, limit, remaining, reset
+func (_m *ServerPublisher) PublishRateLimit(resource string, limit int, remaining int, reset time.Time) {
+	_m.Called(resource, limit, remaining, reset)
+}
+
+// PublishScalingPolicy provides a mock function with given fields: policy, schedule, clamps
+func (_m *ServerPublisher) PublishScalingPolicy(policy string, schedule string, clamps []string) {
+	_m.Called(policy, schedule, clamps)
+}
+
+// PublishSession provides a mock function with given fields: session, createdAt
+func (_m *ServerPublisher) PublishSession(session *actions.RunnerScaleSetSession, createdAt time.Time) {
+	_m.Called(session, createdAt)
+}
+
+// PublishSessionHeartbeat provides a mock function with given fields: age, expiresAt
+func (_m *ServerPublisher) PublishSessionHeartbeat(age time.Duration, expiresAt time.Time) {
+	_m.Called(age, expiresAt)
+}
+
+// PublishSessionRefreshFailure provides a mock function with given fields:
+func (_m *ServerPublisher) PublishSessionRefreshFailure() {
+	_m.Called()
+}
+
 // PublishStatic provides a mock function with given fields: min, max
 func (_m *ServerPublisher) PublishStatic(min int, max int) {
 	_m.Called(min, max)
@@ -54,6 +141,21 @@ func (_m *ServerPublisher) PublishStatistics(stats *actions.RunnerScaleSetStatis
 	_m.Called(stats)
 }
 
+// PublishStuckPendingRunners provides a mock function with given fields: count
+func (_m *ServerPublisher) PublishStuckPendingRunners(count int) {
+	_m.Called(count)
+}
+
+// PublishVaultRequest provides a mock function with given fields: vaultType, operation, result, duration
+func (_m *ServerPublisher) PublishVaultRequest(vaultType string, operation string, result string, duration time.Duration) {
+	_m.Called(vaultType, operation, result, duration)
+}
+
+// PublishZoneDesiredRunners provides a mock function with given fields: zone, count
+func (_m *ServerPublisher) PublishZoneDesiredRunners(zone string, count int) {
+	_m.Called(zone, count)
+}
+
 // NewServerPublisher creates a new instance of ServerPublisher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewServerPublisher(t interface {
@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// pushJobName is the job the pushed metrics are grouped under.
+	pushJobName = "gha-runner-scale-set-listener"
+
+	defaultPushInterval = 30 * time.Second
+	pushTimeout         = 10 * time.Second
+)
+
+// pusher periodically pushes the gathered metrics to a Pushgateway and/or a
+// Prometheus remote write endpoint, so listeners that cannot be scraped still report them.
+type pusher struct {
+	logger         logr.Logger
+	gatherer       prometheus.Gatherer
+	interval       time.Duration
+	client         *http.Client
+	pushgateway    *push.Pusher
+	remoteWriteURL string
+	labels         map[string]string
+	now            func() time.Time
+}
+
+func newPusher(config *v1alpha1.MetricsPushConfig, gatherer prometheus.Gatherer, scaleSetName, scaleSetNamespace string, logger logr.Logger) *pusher {
+	interval := defaultPushInterval
+	if config.Interval != nil && config.Interval.Duration > 0 {
+		interval = config.Interval.Duration
+	}
+
+	p := &pusher{
+		logger:         logger,
+		gatherer:       gatherer,
+		interval:       interval,
+		client:         &http.Client{Timeout: pushTimeout},
+		remoteWriteURL: config.RemoteWriteURL,
+		labels: map[string]string{
+			"job":                           pushJobName,
+			labelKeyRunnerScaleSetName:      scaleSetName,
+			labelKeyRunnerScaleSetNamespace: scaleSetNamespace,
+		},
+		now: time.Now,
+	}
+
+	if config.PushgatewayURL != "" {
+		// The name and namespace labels are already set on the scale set metrics,
+		// and the Pushgateway rejects grouping labels colliding with the pushed ones.
+		p.pushgateway = push.New(config.PushgatewayURL, pushJobName).
+			Grouping("instance", scaleSetNamespace+"/"+scaleSetName).
+			Gatherer(gatherer).
+			Client(p.client)
+	}
+
+	return p
+}
+
+// run pushes the metrics every interval until ctx is done, and pushes them one last time
+// before returning so the final values are not lost when the listener exits.
+func (p *pusher) run(ctx context.Context) {
+	p.logger.Info("starting metrics push", "interval", p.interval, "pushgateway", p.pushgateway != nil, "remoteWrite", p.remoteWriteURL != "")
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+			defer cancel()
+			if err := p.push(ctx); err != nil {
+				p.logger.Error(err, "failed to push metrics on shutdown")
+			}
+			p.logger.Info("stopped metrics push")
+			return
+		case <-ticker.C:
+			if err := p.push(ctx); err != nil {
+				p.logger.Error(err, "failed to push metrics")
+			}
+		}
+	}
+}
+
+func (p *pusher) push(ctx context.Context) error {
+	var errs []error
+	if p.pushgateway != nil {
+		if err := p.pushgateway.PushContext(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to push metrics to pushgateway: %w", err))
+		}
+	}
+	if p.remoteWriteURL != "" {
+		if err := p.remoteWrite(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remote write metrics: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *pusher) remoteWrite(ctx context.Context) error {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	body := snappy.Encode(nil, encodeWriteRequest(families, p.labels, p.now()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.remoteWriteURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %q: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// encodeWriteRequest encodes the metric families as a remote write (v1) WriteRequest protobuf message.
+//
+// Histograms are expanded to their _bucket, _sum and _count series, the same way they are exposed for scraping.
+func encodeWriteRequest(families []*dto.MetricFamily, labels map[string]string, now time.Time) []byte {
+	timestamp := now.UnixMilli()
+
+	var buf []byte
+	appendSeries := func(name string, metric *dto.Metric, value float64, extra ...string) {
+		series := make(map[string]string, len(labels)+len(metric.GetLabel())+2)
+		for k, v := range labels {
+			series[k] = v
+		}
+		for _, l := range metric.GetLabel() {
+			series[l.GetName()] = l.GetValue()
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			series[extra[i]] = extra[i+1]
+		}
+		series["__name__"] = name
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, encodeTimeSeries(series, value, timestamp))
+	}
+
+	for _, family := range families {
+		name := family.GetName()
+		for _, metric := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				appendSeries(name, metric, metric.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				appendSeries(name, metric, metric.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				appendSeries(name, metric, metric.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := metric.GetHistogram()
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), +1) {
+						continue
+					}
+					appendSeries(name+"_bucket", metric, float64(b.GetCumulativeCount()), "le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64))
+				}
+				appendSeries(name+"_bucket", metric, float64(h.GetSampleCount()), "le", "+Inf")
+				appendSeries(name+"_sum", metric, h.GetSampleSum())
+				appendSeries(name+"_count", metric, float64(h.GetSampleCount()))
+			}
+		}
+	}
+
+	return buf
+}
+
+func encodeTimeSeries(labels map[string]string, value float64, timestamp int64) []byte {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	// Remote write requires the labels to be sorted by name.
+	sort.Strings(names)
+
+	var buf []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, labels[name])
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, label)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(timestamp))
+
+	buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+	buf = protowire.AppendBytes(buf, sample)
+
+	return buf
+}
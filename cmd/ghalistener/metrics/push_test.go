@@ -0,0 +1,276 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type decodedSeries struct {
+	labels    map[string]string
+	labelKeys []string
+	value     float64
+	timestamp int64
+}
+
+// decodeWriteRequest decodes the WriteRequest messages produced by encodeWriteRequest.
+func decodeWriteRequest(t *testing.T, b []byte) []decodedSeries {
+	t.Helper()
+
+	fields := func(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			require.GreaterOrEqual(t, n, 0)
+			b = b[n:]
+			n = fn(num, typ, b)
+			require.GreaterOrEqual(t, n, 0)
+			b = b[n:]
+		}
+	}
+
+	var result []decodedSeries
+	fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		require.Equal(t, protowire.Number(1), num)
+		ts, n := protowire.ConsumeBytes(b)
+		series := decodedSeries{labels: map[string]string{}}
+		fields(ts, func(num protowire.Number, typ protowire.Type, b []byte) int {
+			msg, n := protowire.ConsumeBytes(b)
+			switch num {
+			case 1:
+				var name, value string
+				fields(msg, func(num protowire.Number, typ protowire.Type, b []byte) int {
+					s, n := protowire.ConsumeString(b)
+					if num == 1 {
+						name = s
+					} else {
+						value = s
+					}
+					return n
+				})
+				series.labels[name] = value
+				series.labelKeys = append(series.labelKeys, name)
+			case 2:
+				fields(msg, func(num protowire.Number, typ protowire.Type, b []byte) int {
+					if num == 1 {
+						v, n := protowire.ConsumeFixed64(b)
+						series.value = math.Float64frombits(v)
+						return n
+					}
+					v, n := protowire.ConsumeVarint(b)
+					series.timestamp = int64(v)
+					return n
+				})
+			}
+			return n
+		})
+		result = append(result, series)
+		return n
+	})
+	return result
+}
+
+func findSeries(series []decodedSeries, name string, labels map[string]string) *decodedSeries {
+	for i := range series {
+		if series[i].labels["__name__"] != name {
+			continue
+		}
+		match := true
+		for k, v := range labels {
+			if series[i].labels[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			return &series[i]
+		}
+	}
+	return nil
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total"}, []string{"repository"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_seconds", Buckets: []float64{1, 5}})
+	reg.MustRegister(counter, histogram)
+
+	counter.WithLabelValues("repo").Add(3)
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+	histogram.Observe(10)
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	series := decodeWriteRequest(t, encodeWriteRequest(families, map[string]string{"job": "listener"}, now))
+	require.Len(t, series, 6)
+
+	for _, s := range series {
+		assert.True(t, sortedStrings(s.labelKeys), "labels must be sorted: %v", s.labelKeys)
+		assert.Equal(t, "listener", s.labels["job"])
+		assert.Equal(t, now.UnixMilli(), s.timestamp)
+	}
+
+	c := findSeries(series, "test_total", map[string]string{"repository": "repo"})
+	require.NotNil(t, c)
+	assert.Equal(t, 3.0, c.value)
+
+	expected := map[string]float64{"1": 1, "5": 2, "+Inf": 3}
+	for le, count := range expected {
+		b := findSeries(series, "test_seconds_bucket", map[string]string{"le": le})
+		require.NotNil(t, b, "bucket le=%s", le)
+		assert.Equal(t, count, b.value, "bucket le=%s", le)
+	}
+
+	sum := findSeries(series, "test_seconds_sum", nil)
+	require.NotNil(t, sum)
+	assert.Equal(t, 12.5, sum.value)
+
+	count := findSeries(series, "test_seconds_count", nil)
+	require.NotNil(t, count)
+	assert.Equal(t, 3.0, count.value)
+}
+
+func sortedStrings(s []string) bool {
+	for i := 1; i < len(s); i++ {
+		if s[i-1] > s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPusher_RemoteWrite(t *testing.T) {
+	var received []decodedSeries
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "0.1.0", r.Header.Get("X-Prometheus-Remote-Write-Version"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		decoded, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		received = decodeWriteRequest(t, decoded)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	e := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Repository:        "repo",
+		Logger:            logr.Discard(),
+		DisableServer:     true,
+		Metrics: &v1alpha1.MetricsConfig{
+			Push: &v1alpha1.MetricsPushConfig{RemoteWriteURL: srv.URL},
+		},
+	}).(*exporter)
+	require.NotNil(t, e.pusher)
+
+	e.PublishJobStarted(&actions.JobStarted{
+		JobMessageBase: actions.JobMessageBase{RepositoryName: "repo", JobDisplayName: "build"},
+	})
+
+	require.NoError(t, e.pusher.push(context.Background()))
+
+	started := findSeries(received, MetricStartedJobsTotal, map[string]string{
+		"job":                           pushJobName,
+		labelKeyRunnerScaleSetName:      "test-scale-set",
+		labelKeyRunnerScaleSetNamespace: "test-namespace",
+		labelKeyRepository:              "repo",
+		labelKeyJobName:                 "build",
+	})
+	require.NotNil(t, started)
+	assert.Equal(t, 1.0, started.value)
+}
+
+func TestPusher_RemoteWriteFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	p := newPusher(&v1alpha1.MetricsPushConfig{RemoteWriteURL: srv.URL}, prometheus.NewRegistry(), "name", "namespace", logr.Discard())
+	err := p.push(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "out of order sample")
+}
+
+func TestPusher_Pushgateway(t *testing.T) {
+	var pushes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/metrics/job/"+pushJobName+"/instance@base64/dGVzdC1uYW1lc3BhY2UvdGVzdC1zY2FsZS1zZXQ", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.NotEmpty(t, body)
+		pushes.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	e := NewExporter(ExporterConfig{
+		ScaleSetName:      "test-scale-set",
+		ScaleSetNamespace: "test-namespace",
+		Logger:            logr.Discard(),
+		DisableServer:     true,
+		Metrics: &v1alpha1.MetricsConfig{
+			Push: &v1alpha1.MetricsPushConfig{
+				PushgatewayURL: srv.URL,
+				Interval:       &metav1.Duration{Duration: time.Hour},
+			},
+		},
+	})
+	e.PublishDesiredRunners(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- e.ListenAndServe(ctx)
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe did not return")
+	}
+
+	assert.Equal(t, int32(1), pushes.Load(), "metrics should be pushed on shutdown")
+}
+
+func TestExporterConfigDefaults_PushOnly(t *testing.T) {
+	push := &v1alpha1.MetricsPushConfig{PushgatewayURL: "http://pushgateway:9091"}
+	config := ExporterConfig{
+		Logger:  logr.Discard(),
+		Metrics: &v1alpha1.MetricsConfig{Push: push},
+	}
+
+	config.defaults()
+
+	// when only push is configured, all default metrics should be pushed
+	assert.Equal(t, defaultMetrics.Counters, config.Metrics.Counters)
+	assert.Equal(t, defaultMetrics.Gauges, config.Metrics.Gauges)
+	assert.Equal(t, defaultMetrics.Histograms, config.Metrics.Histograms)
+	assert.Same(t, push, config.Metrics.Push)
+	assert.Nil(t, defaultMetrics.Push, "defaults must not be modified")
+}
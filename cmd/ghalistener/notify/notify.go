@@ -0,0 +1,190 @@
+// Package notify posts the jobs completed by the scale set to a webhook, e.g. to alert a team on the failed
+// jobs of its self-hosted runners without polling the GitHub API.
+//
+// The notifications are queued and posted in the background, so a slow or unavailable webhook does not delay
+// the messages of the listener. Notifications are not retried, and are dropped while the queue is full.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/webhook"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+)
+
+// The formats of the body posted to the webhook.
+const (
+	// FormatJSON posts the Notification as JSON.
+	FormatJSON = "json"
+	// FormatSlack posts a message for Slack incoming webhooks, and the webhooks compatible with them.
+	FormatSlack = "slack"
+)
+
+// queueSize is the number of notifications waiting to be posted, beyond which they are dropped.
+const queueSize = 100
+
+// Config configures the Notifier.
+type Config struct {
+	URL    string
+	Client *http.Client
+	// Format is FormatJSON or FormatSlack. Defaults to FormatJSON.
+	Format string
+	// Results are the results of the jobs notified, e.g. "failed". If empty, every completed job is notified.
+	Results []string
+	// BearerTokenFile is the file holding the bearer token sent to the webhook, if set.
+	// It is read for every request, so the token can be rotated.
+	BearerTokenFile string
+	// GitHubURL is the URL of the GitHub server the links to the workflow runs are built from, if set.
+	GitHubURL string
+	ScaleSet  ScaleSet
+	Logger    logr.Logger
+}
+
+// ScaleSet identifies the scale set of the listener.
+type ScaleSet struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// Notification is the body posted to the webhook with FormatJSON.
+type Notification struct {
+	ScaleSet ScaleSet `json:"scaleSet"`
+	// Repository is the owner and the name of the repository of the job, e.g. "octo-org/octo-repo".
+	Repository    string `json:"repository"`
+	JobID         string `json:"jobId"`
+	JobName       string `json:"jobName"`
+	WorkflowRef   string `json:"workflowRef"`
+	WorkflowRunID int64  `json:"workflowRunId"`
+	// WorkflowRunURL is the URL of the workflow run, set if the URL of the GitHub server is known.
+	WorkflowRunURL string `json:"workflowRunUrl,omitempty"`
+	EventName      string `json:"eventName"`
+	Result         string `json:"result"`
+	// RunnerName is empty if the job completed before a runner was assigned, e.g. when it was cancelled.
+	RunnerName string `json:"runnerName"`
+	// DurationSeconds is the time the job ran on the runner, zero if no runner was assigned.
+	DurationSeconds float64   `json:"durationSeconds"`
+	QueueTime       time.Time `json:"queueTime"`
+	FinishTime      time.Time `json:"finishTime"`
+}
+
+// slackMessage is the body posted to the webhook with FormatSlack.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// Notifier posts the completed jobs to a webhook. A nil Notifier discards them.
+type Notifier struct {
+	config Config
+	queue  chan *Notification
+}
+
+func New(config Config) *Notifier {
+	if config.Format == "" {
+		config.Format = FormatJSON
+	}
+	config.GitHubURL = strings.TrimSuffix(config.GitHubURL, "/")
+	return &Notifier{
+		config: config,
+		queue:  make(chan *Notification, queueSize),
+	}
+}
+
+// JobCompleted queues the notification of the completed job, unless its result is not notified.
+func (n *Notifier) JobCompleted(jobCompleted *actions.JobCompleted) {
+	if n == nil {
+		return
+	}
+	if len(n.config.Results) > 0 && !slices.Contains(n.config.Results, jobCompleted.Result) {
+		return
+	}
+
+	notification := n.notification(jobCompleted)
+	select {
+	case n.queue <- notification:
+	default:
+		n.config.Logger.Info("Notification queue is full, dropping the notification", "jobId", notification.JobID, "result", notification.Result)
+	}
+}
+
+func (n *Notifier) notification(jobCompleted *actions.JobCompleted) *Notification {
+	notification := &Notification{
+		ScaleSet:      n.config.ScaleSet,
+		Repository:    jobCompleted.OwnerName + "/" + jobCompleted.RepositoryName,
+		JobID:         jobCompleted.JobID,
+		JobName:       jobCompleted.JobDisplayName,
+		WorkflowRef:   jobCompleted.JobWorkflowRef,
+		WorkflowRunID: jobCompleted.WorkflowRunID,
+		EventName:     jobCompleted.EventName,
+		Result:        jobCompleted.Result,
+		RunnerName:    jobCompleted.RunnerName,
+		QueueTime:     jobCompleted.QueueTime,
+		FinishTime:    jobCompleted.FinishTime,
+	}
+	if !jobCompleted.RunnerAssignTime.IsZero() && jobCompleted.FinishTime.After(jobCompleted.RunnerAssignTime) {
+		notification.DurationSeconds = jobCompleted.FinishTime.Sub(jobCompleted.RunnerAssignTime).Seconds()
+	}
+	if n.config.GitHubURL != "" && jobCompleted.WorkflowRunID != 0 {
+		notification.WorkflowRunURL = fmt.Sprintf("%s/%s/actions/runs/%d", n.config.GitHubURL, notification.Repository, jobCompleted.WorkflowRunID)
+	}
+	return notification
+}
+
+// Run posts the queued notifications until the context is cancelled.
+// A failure is logged, the notification is not retried.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-n.queue:
+			if err := n.post(ctx, notification); err != nil {
+				n.config.Logger.Error(err, "Failed to post the job notification", "jobId", notification.JobID, "result", notification.Result)
+			}
+		}
+	}
+}
+
+// post posts the notification to the webhook, which must answer with a 2xx status.
+func (n *Notifier) post(ctx context.Context, notification *Notification) error {
+	var v any = notification
+	if n.config.Format == FormatSlack {
+		v = &slackMessage{Text: slackText(notification)}
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	endpoint := webhook.Endpoint{
+		URL:             n.config.URL,
+		Client:          n.config.Client,
+		BearerTokenFile: n.config.BearerTokenFile,
+	}
+	if err := endpoint.Post(ctx, body); err != nil {
+		return fmt.Errorf("failed to post notification: %w", err)
+	}
+	return nil
+}
+
+// slackText formats the notification as a Slack message, linking the job to its workflow run if known.
+func slackText(n *Notification) string {
+	job := fmt.Sprintf("*%s*", n.JobName)
+	if n.WorkflowRunURL != "" {
+		job = fmt.Sprintf("<%s|%s>", n.WorkflowRunURL, n.JobName)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Job %s of %s %s", job, n.Repository, n.Result)
+	if n.RunnerName != "" {
+		fmt.Fprintf(&b, " on runner %s after %s", n.RunnerName, time.Duration(n.DurationSeconds*float64(time.Second)).Round(time.Second))
+	}
+	fmt.Fprintf(&b, " (scale set %s/%s)", n.ScaleSet.Namespace, n.ScaleSet.Name)
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func jobCompleted(id, result, runnerName string) *actions.JobCompleted {
+	queueTime := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	job := &actions.JobCompleted{
+		Result:     result,
+		RunnerName: runnerName,
+		JobMessageBase: actions.JobMessageBase{
+			OwnerName:      "octo-org",
+			RepositoryName: "octo-repo",
+			JobID:          id,
+			JobDisplayName: "build",
+			JobWorkflowRef: "octo-org/octo-repo/.github/workflows/ci.yml@refs/heads/main",
+			WorkflowRunID:  42,
+			EventName:      "push",
+			QueueTime:      queueTime,
+			FinishTime:     queueTime.Add(2 * time.Minute),
+		},
+	}
+	if runnerName != "" {
+		job.RunnerAssignTime = queueTime.Add(20 * time.Second)
+	}
+	return job
+}
+
+// receive runs the notifier against a webhook server and returns the requests it received.
+func receive(t *testing.T, config Config) (*Notifier, <-chan *http.Request, <-chan []byte) {
+	t.Helper()
+	requests := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	t.Cleanup(server.Close)
+
+	config.URL = server.URL
+	config.Client = server.Client()
+	config.Logger = logr.Discard()
+	n := New(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go n.Run(ctx)
+	return n, requests, bodies
+}
+
+func TestNotifier_JSON(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token\n"), 0o600))
+
+	n, requests, bodies := receive(t, Config{
+		Results:         []string{"failed"},
+		BearerTokenFile: tokenFile,
+		GitHubURL:       "https://github.com/",
+		ScaleSet:        ScaleSet{Name: "arc-runners", Namespace: "arc"},
+	})
+
+	n.JobCompleted(jobCompleted("1", "succeeded", "runner-1"))
+	n.JobCompleted(jobCompleted("2", "failed", "runner-2"))
+
+	req, body := <-requests, <-bodies
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+	var got Notification
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, "2", got.JobID, "the results not configured are not notified")
+	assert.Equal(t, ScaleSet{Name: "arc-runners", Namespace: "arc"}, got.ScaleSet)
+	assert.Equal(t, "octo-org/octo-repo", got.Repository)
+	assert.Equal(t, "failed", got.Result)
+	assert.Equal(t, "runner-2", got.RunnerName)
+	assert.Equal(t, 100.0, got.DurationSeconds)
+	assert.Equal(t, "https://github.com/octo-org/octo-repo/actions/runs/42", got.WorkflowRunURL)
+}
+
+func TestNotifier_Slack(t *testing.T) {
+	n, _, bodies := receive(t, Config{
+		Format:    FormatSlack,
+		GitHubURL: "https://github.com",
+		ScaleSet:  ScaleSet{Name: "arc-runners", Namespace: "arc"},
+	})
+
+	n.JobCompleted(jobCompleted("1", "failed", "runner-1"))
+	n.JobCompleted(jobCompleted("2", "canceled", ""))
+
+	var got slackMessage
+	require.NoError(t, json.Unmarshal(<-bodies, &got))
+	assert.Equal(t, "Job <https://github.com/octo-org/octo-repo/actions/runs/42|build> of octo-org/octo-repo failed on runner runner-1 after 1m40s (scale set arc/arc-runners)", got.Text)
+
+	require.NoError(t, json.Unmarshal(<-bodies, &got))
+	assert.Equal(t, "Job <https://github.com/octo-org/octo-repo/actions/runs/42|build> of octo-org/octo-repo canceled (scale set arc/arc-runners)", got.Text)
+}
+
+func TestNotifier_DropsWhileQueueIsFull(t *testing.T) {
+	n := New(Config{URL: "https://hooks.example.com", Logger: logr.Discard()})
+	for i := range queueSize + 10 {
+		n.JobCompleted(jobCompleted(string(rune('a'+i%26)), "failed", "runner"))
+	}
+	assert.Len(t, n.queue, queueSize, "the notifications beyond the queue are dropped instead of blocking")
+
+	var nilNotifier *Notifier
+	nilNotifier.JobCompleted(jobCompleted("1", "failed", "runner"))
+}
@@ -0,0 +1,198 @@
+// Package predictor forecasts the demand of the scale set from the demand the listener recorded on the
+// previous days, so runners can be provisioned a few minutes before recurring daily spikes instead of once
+// their jobs are assigned.
+//
+// The peak assigned job count is recorded per 5 minutes slot. The forecast is the highest, over the slots
+// between now and the lead time, of the average peak of the same slot on the previous days.
+package predictor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
+	"github.com/go-logr/logr"
+)
+
+const (
+	DefaultLead = 5 * time.Minute
+	DefaultDays = 7
+
+	// slotDuration is the resolution the demand is recorded at.
+	slotDuration = 5 * time.Minute
+	// minDays is the number of previous days the demand of a slot must be known for to be forecast,
+	// so a one-off spike is not forecast as recurring.
+	minDays = 2
+
+	day = 24 * time.Hour
+)
+
+// Config configures the Predictor.
+type Config struct {
+	// Lead is how far ahead of the forecast demand runners are provisioned. Defaults to 5 minutes.
+	Lead time.Duration
+	// Days is the number of previous days the demand is averaged over. Defaults to 7.
+	Days int
+	// WorkDir and HistoryFile are the directory and the name of the file the recorded demand is persisted to,
+	// so it survives restarts of the listener. If HistoryFile is empty, the demand is kept in memory.
+	WorkDir     *workdir.Dir
+	HistoryFile string
+	Logger      logr.Logger
+}
+
+// Predictor records the demand of the scale set and forecasts it.
+type Predictor struct {
+	lead        time.Duration
+	days        int
+	workDir     *workdir.Dir
+	historyFile string
+	logger      logr.Logger
+
+	mu sync.Mutex
+	// peaks is the peak assigned job count per slot, by the Unix time the slot starts at.
+	peaks map[int64]int
+	// slot is the slot of the last sample.
+	slot int64
+}
+
+// history is the content of the history file.
+type history struct {
+	Peaks map[int64]int `json:"peaks"`
+}
+
+func New(config Config) *Predictor {
+	p := &Predictor{
+		lead:        config.Lead,
+		days:        config.Days,
+		workDir:     config.WorkDir,
+		historyFile: config.HistoryFile,
+		logger:      config.Logger,
+		peaks:       make(map[int64]int),
+	}
+	if p.lead <= 0 {
+		p.lead = DefaultLead
+	}
+	if p.days <= 0 {
+		p.days = DefaultDays
+	}
+	return p
+}
+
+// Load reads the demand recorded in the history file by a previous run of the listener, if any.
+func (p *Predictor) Load() error {
+	if p.historyFile == "" {
+		return nil
+	}
+	path, err := p.workDir.Join(p.historyFile)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read demand history: %w", err)
+	}
+	var h history
+	if err := json.Unmarshal(b, &h); err != nil {
+		return fmt.Errorf("failed to decode demand history: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for slot, peak := range h.Peaks {
+		p.peaks[slot] = max(p.peaks[slot], peak)
+	}
+	return nil
+}
+
+// Predict records the assigned job count at the time and returns the job count forecast to be assigned
+// within the lead time. It returns 0 until the demand of the previous days is known.
+func (p *Predictor) Predict(now time.Time, assigned int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slot := now.Truncate(slotDuration).Unix()
+	p.peaks[slot] = max(p.peaks[slot], assigned)
+	if slot != p.slot {
+		// The history is saved once per slot, when the previous slot is complete.
+		if p.slot != 0 {
+			p.prune(now)
+			p.save()
+		}
+		p.slot = slot
+	}
+
+	return p.forecast(now)
+}
+
+// ForecastAt returns the average peak job count of the slot of the time on the previous days,
+// e.g. to forecast the demand further ahead than the lead time. It returns 0 until the demand
+// of the previous days is known.
+func (p *Predictor) ForecastAt(t time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.slotForecast(t.Truncate(slotDuration))
+}
+
+// forecast must be called with p.mu held.
+func (p *Predictor) forecast(now time.Time) int {
+	forecast := 0
+	for offset := time.Duration(0); offset <= p.lead; offset += slotDuration {
+		forecast = max(forecast, p.slotForecast(now.Add(offset).Truncate(slotDuration)))
+	}
+	return forecast
+}
+
+// slotForecast returns the average peak of the slot on the previous days, or 0 if it is known
+// for less than minDays. It must be called with p.mu held.
+func (p *Predictor) slotForecast(slot time.Time) int {
+	sum, n := 0, 0
+	for d := 1; d <= p.days; d++ {
+		if peak, ok := p.peaks[slot.Add(-time.Duration(d)*day).Unix()]; ok {
+			sum += peak
+			n++
+		}
+	}
+	if n < minDays {
+		return 0
+	}
+	return (sum + n/2) / n
+}
+
+// prune drops the slots older than the days the demand is averaged over. It must be called with p.mu held.
+func (p *Predictor) prune(now time.Time) {
+	oldest := now.Add(-time.Duration(p.days)*day - slotDuration).Unix()
+	for slot := range p.peaks {
+		if slot < oldest {
+			delete(p.peaks, slot)
+		}
+	}
+}
+
+// save must be called with p.mu held. A failure is logged, the demand is still kept in memory.
+func (p *Predictor) save() {
+	if p.historyFile == "" {
+		return
+	}
+	b, err := json.Marshal(history{Peaks: p.peaks})
+	if err != nil {
+		p.logger.Error(err, "Failed to encode demand history")
+		return
+	}
+	f, err := p.workDir.Create(p.historyFile)
+	if err != nil {
+		p.logger.Error(err, "Failed to save demand history")
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(b); err != nil {
+		p.logger.Error(err, "Failed to save demand history")
+	}
+}
@@ -0,0 +1,81 @@
+package predictor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/workdir"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordDay records the demand of a day, every minute, with the spike between 09:00 and 09:30.
+func recordDay(p *Predictor, date time.Time, spike int) {
+	for now := date; now.Before(date.Add(day)); now = now.Add(time.Minute) {
+		assigned := 0
+		if h, m := now.Hour(), now.Minute(); h == 9 && m < 30 {
+			assigned = spike
+		}
+		p.Predict(now, assigned)
+	}
+}
+
+func TestPredictor_Predict(t *testing.T) {
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("ForecastsRecurringSpikes", func(t *testing.T) {
+		p := New(Config{Logger: logr.Discard()})
+		recordDay(p, start, 10)
+		recordDay(p, start.Add(day), 20)
+
+		today := start.Add(2 * day)
+		assert.Equal(t, 0, p.Predict(today.Add(8*time.Hour), 0), "no spike is forecast within the lead time")
+		assert.Equal(t, 15, p.Predict(today.Add(8*time.Hour+55*time.Minute), 0), "the spike is forecast ahead of the lead time")
+		assert.Equal(t, 15, p.Predict(today.Add(9*time.Hour+10*time.Minute), 3))
+		assert.Equal(t, 0, p.Predict(today.Add(9*time.Hour+30*time.Minute), 0), "the end of the spike is forecast")
+	})
+
+	t.Run("IgnoresOneOffSpikes", func(t *testing.T) {
+		p := New(Config{Logger: logr.Discard()})
+		recordDay(p, start, 10)
+
+		assert.Equal(t, 0, p.Predict(start.Add(day+9*time.Hour), 0))
+	})
+
+	t.Run("AveragesOverDays", func(t *testing.T) {
+		p := New(Config{Days: 2, Logger: logr.Discard()})
+		recordDay(p, start, 30)
+		recordDay(p, start.Add(day), 10)
+		recordDay(p, start.Add(2*day), 10)
+
+		assert.Equal(t, 10, p.Predict(start.Add(3*day+9*time.Hour), 0), "the days before Days are not averaged")
+	})
+}
+
+func TestPredictor_ForecastAt(t *testing.T) {
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	p := New(Config{Logger: logr.Discard()})
+	recordDay(p, start, 10)
+	recordDay(p, start.Add(day), 20)
+
+	today := start.Add(2 * day)
+	assert.Equal(t, 0, p.ForecastAt(today.Add(8*time.Hour+55*time.Minute)), "the spike is not forecast ahead of its slot")
+	assert.Equal(t, 15, p.ForecastAt(today.Add(9*time.Hour+12*time.Minute)))
+	assert.Equal(t, 0, p.ForecastAt(today.Add(9*time.Hour+30*time.Minute)))
+}
+
+func TestPredictor_History(t *testing.T) {
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	dir, err := workdir.New(t.TempDir())
+	require.NoError(t, err)
+
+	p := New(Config{WorkDir: dir, HistoryFile: "demand.json", Logger: logr.Discard()})
+	require.NoError(t, p.Load(), "a missing history is not an error")
+	recordDay(p, start, 10)
+	recordDay(p, start.Add(day), 10)
+
+	restarted := New(Config{WorkDir: dir, HistoryFile: "demand.json", Logger: logr.Discard()})
+	require.NoError(t, restarted.Load())
+	assert.Equal(t, 10, restarted.Predict(start.Add(2*day+9*time.Hour), 0), "the demand is loaded from the history")
+}
@@ -0,0 +1,27 @@
+// Package recovery turns the panics of the goroutines of the listener into errors, so a panic in
+// message handling is reported and the component restarted instead of killing the listener.
+package recovery
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is a recovered panic.
+type PanicError struct {
+	Value any
+	// Stack is the stack trace of the goroutine which panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Recover sets *err to a PanicError if the goroutine is panicking.
+// It must be deferred directly, e.g. defer recovery.Recover(&err).
+func Recover(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
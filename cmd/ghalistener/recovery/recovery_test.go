@@ -0,0 +1,34 @@
+package recovery
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	t.Run("Panic", func(t *testing.T) {
+		err := func() (err error) {
+			defer Recover(&err)
+			panic("boom")
+		}()
+
+		var panicErr *PanicError
+		require.ErrorAs(t, fmt.Errorf("handler failed: %w", err), &panicErr)
+		assert.Equal(t, "boom", panicErr.Value)
+		assert.Equal(t, "panic: boom", err.Error())
+		assert.Contains(t, string(panicErr.Stack), "TestRecover")
+	})
+
+	t.Run("NoPanic", func(t *testing.T) {
+		cause := errors.New("failed")
+		err := func() (err error) {
+			defer Recover(&err)
+			return cause
+		}()
+		assert.Same(t, cause, err)
+	})
+}
@@ -0,0 +1,391 @@
+// Package revocation checks the certificates of the GitHub server are not revoked, with the CRLs of their
+// issuers or their OCSP responders, e.g. for the GitHub Enterprise Server instances with an internal PKI.
+// The crypto/tls package verifies the certificate chains but not their revocation.
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/crypto/ocsp"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// DefaultTimeout bounds the requests to the CRL distribution points and to the OCSP responders.
+	DefaultTimeout = 10 * time.Second
+	// maxResponseSize bounds the CRLs and the OCSP responses fetched, the CRLs of large CAs being a few megabytes.
+	maxResponseSize = 32 << 20
+	// defaultCacheTTL is how long the CRLs and the OCSP responses without a next update are cached.
+	defaultCacheTTL = time.Hour
+)
+
+// ErrRevoked is returned for the certificates which are revoked.
+var ErrRevoked = errors.New("certificate is revoked")
+
+// ProxyFunc returns the proxy the URL is reached through, see http.Transport.
+type ProxyFunc func(*url.URL) (*url.URL, error)
+
+// Options configures a Checker.
+type Options struct {
+	// Hosts are the server names of the connections checked, e.g. the GitHub Enterprise Server host, the
+	// connections to the other hosts being accepted. All the connections are checked if it is empty.
+	Hosts []string
+	// CRLFiles are the files of the CRLs, PEM or DER encoded, the certificates are checked against.
+	// They are re-read once past their next update, and rejected if they are still past it.
+	CRLFiles []string
+	// CRLDistributionPoints fetches the CRLs of the distribution points of the certificates.
+	CRLDistributionPoints bool
+	// OCSP checks the certificates with the response stapled by the server, or with their OCSP responders.
+	OCSP bool
+	// SoftFail accepts the certificates whose revocation status cannot be determined, e.g. when the
+	// OCSP responder is unreachable, rather than failing the TLS handshake.
+	SoftFail bool
+	Proxy    ProxyFunc
+	Timeout  time.Duration
+	Logger   logr.Logger
+	Clock    clock.PassiveClock
+}
+
+// Checker checks the certificate chains verified by crypto/tls are not revoked.
+type Checker struct {
+	options Options
+	client  *http.Client
+
+	mu   sync.Mutex
+	crls map[string]*cachedCRL
+	ocsp map[string]*ocsp.Response
+}
+
+type cachedCRL struct {
+	list      *x509.RevocationList
+	expiresAt time.Time
+}
+
+// New returns the checker, loading the CRL files so that a missing or invalid file fails at startup.
+func New(options Options) (*Checker, error) {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.Clock == nil {
+		options.Clock = clock.RealClock{}
+	}
+	if options.Logger.GetSink() == nil {
+		options.Logger = logr.Discard()
+	}
+
+	c := &Checker{
+		options: options,
+		client: &http.Client{
+			Timeout:   options.Timeout,
+			Transport: &http.Transport{Proxy: func(req *http.Request) (*url.URL, error) { return proxy(options.Proxy, req) }},
+		},
+		crls: make(map[string]*cachedCRL),
+		ocsp: make(map[string]*ocsp.Response),
+	}
+	for _, file := range options.CRLFiles {
+		if _, err := c.fileCRL(file); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func proxy(f ProxyFunc, req *http.Request) (*url.URL, error) {
+	if f == nil {
+		return nil, nil
+	}
+	return f(req.URL)
+}
+
+// VerifyConnection checks the certificates of the verified chain, except its root, are not revoked.
+// It is meant for tls.Config.VerifyConnection.
+func (c *Checker) VerifyConnection(cs tls.ConnectionState) error {
+	if len(cs.VerifiedChains) == 0 {
+		return nil
+	}
+	if len(c.options.Hosts) > 0 && !slices.Contains(c.options.Hosts, cs.ServerName) {
+		return nil
+	}
+	chain := cs.VerifiedChains[0]
+	for i := 0; i < len(chain)-1; i++ {
+		var stapled []byte
+		if i == 0 {
+			stapled = cs.OCSPResponse
+		}
+		if err := c.check(chain[i], chain[i+1], stapled); err != nil {
+			return fmt.Errorf("failed to check the revocation of certificate %q of %s: %w", chain[i].Subject, cs.ServerName, err)
+		}
+	}
+	return nil
+}
+
+// check checks the certificate with OCSP, then with the CRLs until its revocation status is determined.
+func (c *Checker) check(cert, issuer *x509.Certificate, stapled []byte) error {
+	var errs []error
+	if c.options.OCSP {
+		determined, err := c.checkOCSP(cert, issuer, stapled)
+		if determined || errors.Is(err, ErrRevoked) {
+			return err
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(c.options.CRLFiles) > 0 || c.options.CRLDistributionPoints {
+		determined, err := c.checkCRLs(cert, issuer)
+		if determined || errors.Is(err, ErrRevoked) {
+			return err
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err == nil {
+		err = errors.New("no CRL or OCSP responder determines the revocation status")
+	}
+	if c.options.SoftFail {
+		c.options.Logger.Info("Accepting the certificate whose revocation status cannot be determined", "subject", cert.Subject.String(), "reason", err.Error())
+		return nil
+	}
+	return err
+}
+
+func (c *Checker) checkOCSP(cert, issuer *x509.Certificate, stapled []byte) (bool, error) {
+	key := string(issuer.RawSubject) + "\x00" + cert.SerialNumber.String()
+	now := c.options.Clock.Now()
+
+	c.mu.Lock()
+	resp, ok := c.ocsp[key]
+	c.mu.Unlock()
+	if !ok || !fresh(resp.NextUpdate, resp.ThisUpdate, now) {
+		var err error
+		resp, err = c.fetchOCSP(cert, issuer, stapled, now)
+		if err != nil {
+			return false, err
+		}
+		c.mu.Lock()
+		c.ocsp[key] = resp
+		c.mu.Unlock()
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return true, nil
+	case ocsp.Revoked:
+		return true, fmt.Errorf("%w at %s per OCSP", ErrRevoked, resp.RevokedAt.UTC().Format(time.RFC3339))
+	default:
+		return false, errors.New("the OCSP responder does not know the certificate")
+	}
+}
+
+// fetchOCSP returns the stapled response, or fetches a response from the OCSP responders. The responses
+// past their next update are rejected, so that a replayed response of a revoked certificate is not trusted.
+func (c *Checker) fetchOCSP(cert, issuer *x509.Certificate, stapled []byte, now time.Time) (*ocsp.Response, error) {
+	if len(stapled) > 0 {
+		resp, err := ocsp.ParseResponseForCert(stapled, cert, issuer)
+		if err == nil && !fresh(resp.NextUpdate, resp.ThisUpdate, now) {
+			err = staleError(resp.NextUpdate, resp.ThisUpdate)
+		}
+		if err == nil {
+			return resp, nil
+		}
+		c.options.Logger.V(1).Info("Ignoring the stapled OCSP response", "error", err.Error())
+	}
+	if len(cert.OCSPServer) == 0 {
+		return nil, errors.New("the certificate has no OCSP responder")
+	}
+
+	request, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+	var errs []error
+	for _, server := range cert.OCSPServer {
+		body, err := c.fetch(http.MethodPost, server, "application/ocsp-request", request)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resp, err := ocsp.ParseResponseForCert(body, cert, issuer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse OCSP response of %s: %w", server, err))
+			continue
+		}
+		if !fresh(resp.NextUpdate, resp.ThisUpdate, now) {
+			errs = append(errs, fmt.Errorf("OCSP response of %s is stale: %w", server, staleError(resp.NextUpdate, resp.ThisUpdate)))
+			continue
+		}
+		return resp, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (c *Checker) checkCRLs(cert, issuer *x509.Certificate) (bool, error) {
+	var lists []*x509.RevocationList
+	var errs []error
+	for _, file := range c.options.CRLFiles {
+		list, err := c.fileCRL(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		lists = append(lists, list)
+	}
+	if c.options.CRLDistributionPoints {
+		for _, dp := range cert.CRLDistributionPoints {
+			list, err := c.distributionPointCRL(dp)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			lists = append(lists, list)
+		}
+	}
+
+	determined := false
+	for _, list := range lists {
+		if !bytes.Equal(list.RawIssuer, issuer.RawSubject) {
+			continue
+		}
+		if err := list.CheckSignatureFrom(issuer); err != nil {
+			errs = append(errs, fmt.Errorf("CRL of %q is not signed by the issuer: %w", issuer.Subject, err))
+			continue
+		}
+		for _, entry := range list.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true, fmt.Errorf("%w at %s per CRL", ErrRevoked, entry.RevocationTime.UTC().Format(time.RFC3339))
+			}
+		}
+		determined = true
+	}
+	if determined {
+		return true, nil
+	}
+	if len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("no CRL of %q", issuer.Subject))
+	}
+	return false, errors.Join(errs...)
+}
+
+func (c *Checker) fileCRL(file string) (*x509.RevocationList, error) {
+	return c.cachedCRL("file:"+file, func() ([]byte, error) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CRL file: %w", err)
+		}
+		return data, nil
+	})
+}
+
+func (c *Checker) distributionPointCRL(dp string) (*x509.RevocationList, error) {
+	u, err := url.Parse(dp)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("CRL distribution point %q is not an HTTP URL", dp)
+	}
+	return c.cachedCRL("url:"+dp, func() ([]byte, error) {
+		return c.fetch(http.MethodGet, dp, "", nil)
+	})
+}
+
+// cachedCRL returns the cached CRL until its next update, or reads it. The CRLs read past their next update
+// are rejected, as they may not list the certificates revoked since.
+func (c *Checker) cachedCRL(key string, read func() ([]byte, error)) (*x509.RevocationList, error) {
+	now := c.options.Clock.Now()
+	c.mu.Lock()
+	cached, ok := c.crls[key]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.list, nil
+	}
+
+	data, err := read()
+	if err != nil {
+		return nil, err
+	}
+	list, err := parseCRL(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CRL %s: %w", key, err)
+	}
+	if !list.NextUpdate.IsZero() && !now.Before(list.NextUpdate) {
+		return nil, fmt.Errorf("CRL %s is stale: %w", key, staleError(list.NextUpdate, list.ThisUpdate))
+	}
+
+	expiresAt := list.NextUpdate
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(defaultCacheTTL)
+	}
+	c.mu.Lock()
+	c.crls[key] = &cachedCRL{list: list, expiresAt: expiresAt}
+	c.mu.Unlock()
+	return list, nil
+}
+
+func parseCRL(data []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("PEM block is a %q, not a X509 CRL", block.Type)
+		}
+		data = block.Bytes
+	}
+	return x509.ParseRevocationList(data)
+}
+
+func (c *Checker) fetch(method, target, contentType string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.options.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", target, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", target, err)
+	}
+	if len(data) > maxResponseSize {
+		return nil, fmt.Errorf("response of %s is larger than %d bytes", target, maxResponseSize)
+	}
+	return data, nil
+}
+
+// fresh reports whether a response of thisUpdate, valid until nextUpdate if set, is still fresh.
+func fresh(nextUpdate, thisUpdate, now time.Time) bool {
+	if nextUpdate.IsZero() {
+		return now.Before(thisUpdate.Add(defaultCacheTTL))
+	}
+	return now.Before(nextUpdate)
+}
+
+func staleError(nextUpdate, thisUpdate time.Time) error {
+	if nextUpdate.IsZero() {
+		return fmt.Errorf("updated at %s, more than %s ago", thisUpdate.UTC().Format(time.RFC3339), defaultCacheTTL)
+	}
+	return fmt.Errorf("next update was at %s", nextUpdate.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,266 @@
+package revocation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, serial int64, ocspServer, crlURL string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "ghes.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"ghes.example.com"},
+	}
+	if ocspServer != "" {
+		template.OCSPServer = []string{ocspServer}
+	}
+	if crlURL != "" {
+		template.CRLDistributionPoints = []string{crlURL}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func (ca *testCA) crl(t *testing.T, revoked ...int64) []byte {
+	t.Helper()
+	var entries []x509.RevocationListEntry
+	for _, serial := range revoked {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	require.NoError(t, err)
+	return der
+}
+
+func (ca *testCA) ocspResponse(t *testing.T, cert *x509.Certificate, status int) []byte {
+	t.Helper()
+	resp, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+		Status:       status,
+		SerialNumber: cert.SerialNumber,
+		ThisUpdate:   time.Now(),
+		NextUpdate:   time.Now().Add(time.Hour),
+		RevokedAt:    time.Now(),
+	}, ca.key)
+	require.NoError(t, err)
+	return resp
+}
+
+func writeFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "crl.pem")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func connectionState(cert *x509.Certificate, ca *testCA) tls.ConnectionState {
+	return tls.ConnectionState{
+		ServerName:     "ghes.example.com",
+		VerifiedChains: [][]*x509.Certificate{{cert, ca.cert}},
+	}
+}
+
+func TestChecker_CRLFiles(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	crlFile := writeFile(t, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: ca.crl(t, 2)}))
+
+	checker, err := New(Options{CRLFiles: []string{crlFile}})
+	require.NoError(t, err)
+
+	assert.NoError(t, checker.VerifyConnection(connectionState(ca.issue(t, 1, "", ""), ca)))
+	assert.ErrorIs(t, checker.VerifyConnection(connectionState(ca.issue(t, 2, "", ""), ca)), ErrRevoked)
+
+	other := newTestCA(t, "other")
+	err = checker.VerifyConnection(connectionState(other.issue(t, 1, "", ""), other))
+	assert.ErrorContains(t, err, `no CRL of "CN=other"`, "the CRL of another issuer does not determine the status")
+
+	softFail, err := New(Options{CRLFiles: []string{crlFile}, SoftFail: true})
+	require.NoError(t, err)
+	assert.NoError(t, softFail.VerifyConnection(connectionState(other.issue(t, 1, "", ""), other)))
+
+	_, err = New(Options{CRLFiles: []string{filepath.Join(t.TempDir(), "missing.pem")}})
+	assert.Error(t, err, "a missing CRL file fails at startup")
+}
+
+func TestChecker_CRLDistributionPoints(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	crl := ca.crl(t, 2)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write(crl)
+	}))
+	t.Cleanup(server.Close)
+
+	checker, err := New(Options{CRLDistributionPoints: true})
+	require.NoError(t, err)
+
+	assert.NoError(t, checker.VerifyConnection(connectionState(ca.issue(t, 1, "", server.URL), ca)))
+	assert.ErrorIs(t, checker.VerifyConnection(connectionState(ca.issue(t, 2, "", server.URL), ca)), ErrRevoked)
+	assert.Equal(t, 1, requests, "the CRL is cached until its next update")
+}
+
+func TestChecker_OCSP(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	responses := map[string]int{"1": ocsp.Good, "2": ocsp.Revoked, "3": ocsp.Unknown}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(t, err)
+		template := ocsp.Response{
+			Status:       responses[req.SerialNumber.String()],
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now(),
+		}
+		resp, err := ocsp.CreateResponse(ca.cert, ca.cert, template, ca.key)
+		require.NoError(t, err)
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	checker, err := New(Options{OCSP: true})
+	require.NoError(t, err)
+
+	assert.NoError(t, checker.VerifyConnection(connectionState(ca.issue(t, 1, server.URL, ""), ca)))
+	assert.ErrorIs(t, checker.VerifyConnection(connectionState(ca.issue(t, 2, server.URL, ""), ca)), ErrRevoked)
+	assert.ErrorContains(t, checker.VerifyConnection(connectionState(ca.issue(t, 3, server.URL, ""), ca)), "does not know the certificate")
+
+	t.Run("stapled", func(t *testing.T) {
+		cert := ca.issue(t, 4, "", "")
+		cs := connectionState(cert, ca)
+		cs.OCSPResponse = ca.ocspResponse(t, cert, ocsp.Revoked)
+		assert.ErrorIs(t, checker.VerifyConnection(cs), ErrRevoked)
+	})
+
+	t.Run("falls back to the CRLs", func(t *testing.T) {
+		checker, err := New(Options{OCSP: true, CRLFiles: []string{writeFile(t, ca.crl(t, 5))}})
+		require.NoError(t, err)
+		assert.ErrorIs(t, checker.VerifyConnection(connectionState(ca.issue(t, 5, "", ""), ca)), ErrRevoked,
+			"the certificate without an OCSP responder is checked with the CRLs")
+	})
+}
+
+func TestChecker_Stale(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(ca.crl(t))
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(t, err)
+		resp, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, ca.key)
+		require.NoError(t, err)
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	// The responses and the CRLs of the servers are past their next update.
+	future := clocktesting.NewFakePassiveClock(time.Now().Add(2 * time.Hour))
+
+	t.Run("OCSP", func(t *testing.T) {
+		checker, err := New(Options{OCSP: true, Clock: future})
+		require.NoError(t, err)
+		err = checker.VerifyConnection(connectionState(ca.issue(t, 1, server.URL, ""), ca))
+		assert.ErrorContains(t, err, "is stale")
+	})
+
+	t.Run("stapled", func(t *testing.T) {
+		checker, err := New(Options{OCSP: true, Clock: future})
+		require.NoError(t, err)
+		cert := ca.issue(t, 2, "", "")
+		cs := connectionState(cert, ca)
+		cs.OCSPResponse = ca.ocspResponse(t, cert, ocsp.Good)
+		assert.ErrorContains(t, checker.VerifyConnection(cs), "has no OCSP responder", "the stale stapled response is ignored")
+	})
+
+	t.Run("CRL distribution points", func(t *testing.T) {
+		checker, err := New(Options{CRLDistributionPoints: true, Clock: future})
+		require.NoError(t, err)
+		err = checker.VerifyConnection(connectionState(ca.issue(t, 3, "", server.URL), ca))
+		assert.ErrorContains(t, err, "is stale")
+	})
+
+	t.Run("CRL files", func(t *testing.T) {
+		_, err := New(Options{CRLFiles: []string{writeFile(t, ca.crl(t))}, Clock: future})
+		assert.ErrorContains(t, err, "is stale")
+	})
+}
+
+func TestChecker_Hosts(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	checker, err := New(Options{Hosts: []string{"ghes.example.com"}, CRLFiles: []string{writeFile(t, ca.crl(t, 1))}})
+	require.NoError(t, err)
+
+	cs := connectionState(ca.issue(t, 1, "", ""), ca)
+	assert.ErrorIs(t, checker.VerifyConnection(cs), ErrRevoked)
+
+	cs.ServerName = "api.github.com"
+	assert.NoError(t, checker.VerifyConnection(cs), "the connections to the other hosts are not checked")
+}
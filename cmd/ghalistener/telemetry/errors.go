@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"sync"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/go-logr/logr"
+)
+
+// uncoded is the class of the errors logged without an error code.
+const uncoded = "uncoded"
+
+// ErrorCounter counts the errors logged through the loggers it wraps, per error code.
+// The code is the "errorCode" value of the log entry, or else the code annotating the error.
+type ErrorCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewErrorCounter() *ErrorCounter {
+	return &ErrorCounter{counts: make(map[string]int)}
+}
+
+// Wrap returns the logger counting the errors it logs.
+func (c *ErrorCounter) Wrap(logger logr.Logger) logr.Logger {
+	sink := logger.GetSink()
+	if sink == nil {
+		return logger
+	}
+	// The wrapped sink is one frame further away from the caller.
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(1)
+	}
+	return logr.New(&countingSink{sink: sink, counter: c})
+}
+
+func (c *ErrorCounter) record(err error, keysAndValues []any) {
+	class := uncoded
+	if code, ok := errcode.Of(err); ok {
+		class = code.String()
+	}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if key, ok := keysAndValues[i].(string); ok && key == "errorCode" {
+			if code, ok := keysAndValues[i+1].(errcode.Code); ok {
+				class = code.String()
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.counts[class]++
+	c.mu.Unlock()
+}
+
+// take returns the counts and resets them.
+func (c *ErrorCounter) take() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.counts
+	c.counts = make(map[string]int)
+	return counts
+}
+
+func (c *ErrorCounter) add(counts map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for class, n := range counts {
+		c.counts[class] += n
+	}
+}
+
+// countingSink counts the errors before passing them to the wrapped sink.
+type countingSink struct {
+	sink    logr.LogSink
+	counter *ErrorCounter
+}
+
+var _ logr.CallDepthLogSink = (*countingSink)(nil)
+
+// Init does nothing, the wrapped sink was initialized by its own logger.
+func (s *countingSink) Init(info logr.RuntimeInfo) {}
+
+func (s *countingSink) Enabled(level int) bool {
+	return s.sink.Enabled(level)
+}
+
+func (s *countingSink) Info(level int, msg string, keysAndValues ...any) {
+	s.sink.Info(level, msg, keysAndValues...)
+}
+
+func (s *countingSink) Error(err error, msg string, keysAndValues ...any) {
+	s.counter.record(err, keysAndValues)
+	s.sink.Error(err, msg, keysAndValues...)
+}
+
+func (s *countingSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return &countingSink{sink: s.sink.WithValues(keysAndValues...), counter: s.counter}
+}
+
+func (s *countingSink) WithName(name string) logr.LogSink {
+	return &countingSink{sink: s.sink.WithName(name), counter: s.counter}
+}
+
+func (s *countingSink) WithCallDepth(depth int) logr.LogSink {
+	sink, ok := s.sink.(logr.CallDepthLogSink)
+	if !ok {
+		return s
+	}
+	return &countingSink{sink: sink.WithCallDepth(depth), counter: s.counter}
+}
@@ -0,0 +1,160 @@
+// Package telemetry reports anonymized usage statistics of the listener, if opted in.
+//
+// A report holds the shape of the configuration, i.e. which features are enabled, and the number
+// of errors logged per error code since the previous report. It never holds names, URLs, IDs,
+// credentials, or error messages, so platform teams running many scale sets can collect the reports
+// of all their listeners to spot fleet-wide regressions after upgrades.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/actions/actions-runner-controller/build"
+	"github.com/go-logr/logr"
+)
+
+const reportTimeout = 10 * time.Second
+
+// Shape is the anonymized shape of the configuration of the listener.
+type Shape struct {
+	// Scope is the scope of the GitHub config URL: "enterprise", "organization", or "repository".
+	Scope string `json:"scope"`
+	// Hosted is true for github.com and GitHub Enterprise Cloud, false for GitHub Enterprise Server.
+	Hosted bool `json:"hosted"`
+	// Features are the optional features enabled, sorted.
+	Features []string `json:"features"`
+}
+
+// Report is the body posted to the endpoint.
+type Report struct {
+	// InstanceID is random to the listener process, so reports can be deduplicated without identifying the listener.
+	InstanceID    string `json:"instanceId"`
+	Version       string `json:"version"`
+	GoVersion     string `json:"goVersion"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	UptimeSeconds int64  `json:"uptimeSeconds"`
+	Shape         Shape  `json:"shape"`
+	// Errors are the number of errors logged per error code since the previous report.
+	Errors map[string]int `json:"errors"`
+}
+
+// ReporterConfig configures the Reporter.
+type ReporterConfig struct {
+	Endpoint string
+	Interval time.Duration
+	Shape    Shape
+	Errors   *ErrorCounter
+	Logger   logr.Logger
+}
+
+// Reporter posts a report to the endpoint every interval.
+type Reporter struct {
+	endpoint   string
+	interval   time.Duration
+	shape      Shape
+	errors     *ErrorCounter
+	logger     logr.Logger
+	client     *http.Client
+	instanceID string
+	now        func() time.Time
+	start      time.Time
+}
+
+func NewReporter(config ReporterConfig) *Reporter {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	return &Reporter{
+		endpoint:   config.Endpoint,
+		interval:   config.Interval,
+		shape:      config.Shape,
+		errors:     config.Errors,
+		logger:     config.Logger,
+		client:     &http.Client{Timeout: reportTimeout},
+		instanceID: hex.EncodeToString(id),
+		now:        time.Now,
+		start:      time.Now(),
+	}
+}
+
+// Run posts a report every interval until ctx is done, and one last report before returning,
+// so the errors of the listener exiting are not lost.
+func (r *Reporter) Run(ctx context.Context) {
+	r.logger.Info("starting telemetry reports", "interval", r.interval)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+			defer cancel()
+			if err := r.report(ctx); err != nil {
+				r.logger.Info("failed to post telemetry report on shutdown", "error", err.Error())
+			}
+			r.logger.Info("stopped telemetry reports")
+			return
+		case <-ticker.C:
+			if err := r.report(ctx); err != nil {
+				// Not logged as an error, so the failures of the reports are not counted in the next one.
+				r.logger.Info("failed to post telemetry report", "error", err.Error())
+			}
+		}
+	}
+}
+
+// report posts the errors counted since the previous report. They are counted again in the next report
+// if the post fails.
+func (r *Reporter) report(ctx context.Context) error {
+	errors := r.errors.take()
+	if err := r.post(ctx, errors); err != nil {
+		r.errors.add(errors)
+		return err
+	}
+	return nil
+}
+
+func (r *Reporter) post(ctx context.Context, errors map[string]int) error {
+	body, err := json.Marshal(&Report{
+		InstanceID:    r.instanceID,
+		Version:       build.Version,
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		UptimeSeconds: int64(r.now().Sub(r.start).Seconds()),
+		Shape:         r.shape,
+		Errors:        errors,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %q: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
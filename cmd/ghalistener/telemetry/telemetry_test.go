@@ -0,0 +1,83 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCounter(t *testing.T) {
+	t.Parallel()
+
+	var logged []string
+	counter := NewErrorCounter()
+	logger := counter.Wrap(funcr.New(func(prefix, args string) {
+		logged = append(logged, prefix+" "+args)
+	}, funcr.Options{}))
+
+	logger.WithName("worker").WithValues("namespace", "namespace").Error(errors.New("boom"), "uncoded")
+	logger.Error(errcode.Wrap(errcode.MessageGet, errors.New("boom")), "annotated")
+	logger.Error(errcode.Wrap(errcode.MessageGet, errors.New("boom")), "annotated, with an explicit code", "errorCode", errcode.SessionDelete)
+	logger.Info("not counted")
+
+	assert.Len(t, logged, 4, "every entry is passed to the wrapped logger")
+	assert.Equal(t, map[string]int{
+		uncoded:                        1,
+		errcode.MessageGet.String():    1,
+		errcode.SessionDelete.String(): 1,
+	}, counter.take())
+	assert.Empty(t, counter.take(), "counts are reset once taken")
+
+	assert.Equal(t, logr.Discard(), counter.Wrap(logr.Discard()))
+}
+
+func TestReporter(t *testing.T) {
+	t.Parallel()
+
+	var got Report
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		got = Report{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	counter := NewErrorCounter()
+	counter.Wrap(funcr.New(func(string, string) {}, funcr.Options{})).Error(errcode.Wrap(errcode.MessageGet, errors.New("boom")), "failed")
+
+	reporter := NewReporter(ReporterConfig{
+		Endpoint: server.URL,
+		Interval: time.Hour,
+		Shape:    Shape{Scope: "organization", Hosted: true, Features: []string{"metrics-server"}},
+		Errors:   counter,
+		Logger:   logr.Discard(),
+	})
+	reporter.now = func() time.Time { return reporter.start.Add(time.Minute) }
+
+	err := reporter.report(context.Background())
+	assert.ErrorContains(t, err, "unexpected status")
+	assert.Equal(t, map[string]int{errcode.MessageGet.String(): 1}, got.Errors)
+
+	status = http.StatusAccepted
+	require.NoError(t, reporter.report(context.Background()))
+	assert.Equal(t, map[string]int{errcode.MessageGet.String(): 1}, got.Errors, "errors of a failed report are reported again")
+	assert.Equal(t, Shape{Scope: "organization", Hosted: true, Features: []string{"metrics-server"}}, got.Shape)
+	assert.Equal(t, int64(60), got.UptimeSeconds)
+	assert.Len(t, got.InstanceID, 32)
+
+	require.NoError(t, reporter.report(context.Background()))
+	assert.Empty(t, got.Errors)
+}
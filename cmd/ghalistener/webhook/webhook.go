@@ -0,0 +1,98 @@
+// Package webhook posts the JSON bodies of the listener to the webhooks of the operator, e.g. the scaling
+// decisions of the webhook scale target, the capacity forecasts, the job notifications or the audit records.
+//
+// The files of the bearer token and of the secret are read for every request, so they can be rotated
+// without restarting the listener.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// SignatureHeader is the header of the HMAC-SHA256 signature of the body posted to the webhook,
+// formatted as "sha256=<hex digest>" like the signatures of the GitHub webhooks.
+const SignatureHeader = "X-ARC-Signature-256"
+
+// maxErrorBody is the number of bytes of the body of a failed response included in the error.
+const maxErrorBody = 1024
+
+// Endpoint is a webhook the bodies are posted to.
+type Endpoint struct {
+	URL    string
+	Client *http.Client
+	// BearerTokenFile is the file holding the bearer token sent to the webhook, if set.
+	BearerTokenFile string
+	// SecretFile is the file holding the secret the body is signed with, if set.
+	SecretFile string
+}
+
+// StatusError is the error of a request answered with a non-2xx status.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook answered with status %d: %s", e.Code, e.Message)
+}
+
+// Post posts the JSON body to the webhook, which must answer with a 2xx status.
+// A non-2xx status is returned as a *StatusError.
+func (e *Endpoint) Post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.BearerTokenFile != "" {
+		token, err := readCredentialsFile(e.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read webhook bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if e.SecretFile != "" {
+		secret, err := readCredentialsFile(e.SecretFile)
+		if err != nil {
+			return fmt.Errorf("failed to read webhook secret: %w", err)
+		}
+		req.Header.Set(SignatureHeader, Sign([]byte(secret), body))
+	}
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
+
+// Sign returns the value of the signature header of the body, for webhooks to verify the requests with.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// readCredentialsFile returns the content of the file without the trailing newline.
+func readCredentialsFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
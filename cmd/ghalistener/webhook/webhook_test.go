@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpoint_Post(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token\n"), 0o600))
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("secret\n"), 0o600))
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, Sign([]byte("secret"), body), r.Header.Get(SignatureHeader))
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("rejected\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	endpoint := Endpoint{
+		URL:             server.URL,
+		Client:          server.Client(),
+		BearerTokenFile: tokenFile,
+		SecretFile:      secretFile,
+	}
+	require.NoError(t, endpoint.Post(context.Background(), []byte(`{}`)))
+
+	status = http.StatusTooManyRequests
+	err := endpoint.Post(context.Background(), []byte(`{}`))
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.Code)
+	assert.EqualError(t, err, "webhook answered with status 429: rejected")
+
+	endpoint.SecretFile = filepath.Join(dir, "missing")
+	assert.ErrorContains(t, endpoint.Post(context.Background(), []byte(`{}`)), "failed to read webhook secret")
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"desiredCount":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=18651099faf632337b3c9caa4cd799d5dc89a1520b7f4eef049344b05bf4e3c2",
+		Sign([]byte("secret"), []byte(`{"desiredCount":1}`)),
+	)
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package workdir
+
+import (
+	"net"
+	"sync"
+	"syscall"
+)
+
+// umaskMu serializes the sockets created with a restricted umask, which is a setting of the process.
+var umaskMu sync.Mutex
+
+// listenUnix listens on the Unix socket at the path, created with a umask leaving it only accessible by
+// the listener user, so that it is never accessible by other users, even before it is chmoded.
+// The files created by other goroutines meanwhile are restricted as well.
+func listenUnix(path string) (net.Listener, error) {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+	umask := syscall.Umask(0o077)
+	defer syscall.Umask(umask)
+	return net.Listen("unix", path)
+}
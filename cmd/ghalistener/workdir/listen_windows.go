@@ -0,0 +1,11 @@
+//go:build windows
+
+package workdir
+
+import "net"
+
+// listenUnix listens on the Unix socket at the path. Windows has no umask, the socket is only restricted
+// once it is chmoded.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,146 @@
+// Package workdir is the single place the listener writes files through.
+//
+// The listener must run with a read-only root filesystem, so every file it writes
+// (diagnostic dumps, audit logs, caches, ...) must be created under a configurable
+// writable directory, typically backed by an emptyDir volume.
+package workdir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// Dir is a writable directory.
+type Dir struct {
+	path string
+}
+
+// New returns the writable directory at path. If path is empty, the system temporary directory is used.
+// The directory is not created until a file is written to it.
+func New(path string) (*Dir, error) {
+	if path == "" {
+		path = os.TempDir()
+	}
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("writable directory %q must be an absolute path", path)
+	}
+	return &Dir{path: filepath.Clean(path)}, nil
+}
+
+// Path returns the path of the directory.
+func (d *Dir) Path() string {
+	return d.path
+}
+
+// Join returns the path of the named file in the directory.
+// The name must be local, i.e. it must not escape the directory.
+func (d *Dir) Join(name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("file name %q must be local to the writable directory", name)
+	}
+	return filepath.Join(d.path, name), nil
+}
+
+// Create creates or truncates the named file in the directory, creating missing parent directories.
+// Files and directories are only accessible by the listener user.
+func (d *Dir) Create(name string) (*os.File, error) {
+	path, err := d.Join(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %q: %w", name, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %q: %w", name, err)
+	}
+	return f, nil
+}
+
+// Append opens the named file in the directory for appending, creating it and missing parent directories.
+func (d *Dir) Append(name string) (*os.File, error) {
+	path, err := d.Join(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %q: %w", name, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q: %w", name, err)
+	}
+	return f, nil
+}
+
+// Listen listens on the named Unix socket in the directory, creating missing parent directories and
+// replacing the socket left behind by a previous process. The socket is only accessible by the listener user,
+// from its creation on.
+func (d *Dir) Listen(name string) (net.Listener, error) {
+	path, err := d.Join(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %q: %w", name, err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket %q: %w", name, err)
+	}
+
+	ln, err := listenUnix(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", name, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict %q: %w", name, err)
+	}
+	return ln, nil
+}
+
+// AppendWriter returns a writer appending every write to the named file in the directory.
+// The file is opened for every write, so it can be rotated or removed while the listener runs.
+func (d *Dir) AppendWriter(name string) (io.Writer, error) {
+	if _, err := d.Join(name); err != nil {
+		return nil, err
+	}
+	return &appendWriter{dir: d, name: name}, nil
+}
+
+type appendWriter struct {
+	dir  *Dir
+	name string
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	f, err := w.dir.Append(w.name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.Write(p)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// WriteTerminationMessage writes the termination message of the listener container to path, the termination
+// message path of the container, which the kubelet mounts writable even with a read-only root filesystem.
+func WriteTerminationMessage(path, message string) error {
+	if err := os.WriteFile(path, []byte(message), 0o644); err != nil {
+		return fmt.Errorf("failed to write termination message: %w", err)
+	}
+	return nil
+}
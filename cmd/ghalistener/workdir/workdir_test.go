@@ -0,0 +1,179 @@
+package workdir
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("DefaultsToTempDir", func(t *testing.T) {
+		d, err := New("")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Clean(os.TempDir()), d.Path())
+	})
+
+	t.Run("RejectsRelativePath", func(t *testing.T) {
+		_, err := New("relative/dir")
+		assert.Error(t, err)
+	})
+}
+
+func TestDir_Create(t *testing.T) {
+	d, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	t.Run("CreatesPrivateFileAndParents", func(t *testing.T) {
+		f, err := d.Create(filepath.Join("dumps", "state.json"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		info, err := os.Stat(filepath.Join(d.Path(), "dumps", "state.json"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("RejectsEscapingName", func(t *testing.T) {
+		_, err := d.Create(filepath.Join("..", "escape"))
+		assert.Error(t, err)
+
+		_, err = d.Append("/etc/passwd")
+		assert.Error(t, err)
+	})
+
+	t.Run("Appends", func(t *testing.T) {
+		for range 2 {
+			f, err := d.Append("audit.log")
+			require.NoError(t, err)
+			_, err = f.WriteString("line\n")
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+		}
+
+		b, err := os.ReadFile(filepath.Join(d.Path(), "audit.log"))
+		require.NoError(t, err)
+		assert.Equal(t, "line\nline\n", string(b))
+	})
+
+	t.Run("AppendWriter", func(t *testing.T) {
+		w, err := d.AppendWriter(filepath.Join("logs", "dead-letter.jsonl"))
+		require.NoError(t, err)
+		for range 2 {
+			_, err := w.Write([]byte("line\n"))
+			require.NoError(t, err)
+		}
+
+		b, err := os.ReadFile(filepath.Join(d.Path(), "logs", "dead-letter.jsonl"))
+		require.NoError(t, err)
+		assert.Equal(t, "line\nline\n", string(b))
+
+		_, err = d.AppendWriter(filepath.Join("..", "escape"))
+		assert.Error(t, err)
+	})
+}
+
+func TestDir_Listen(t *testing.T) {
+	d, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	for range 2 {
+		ln, err := d.Listen(filepath.Join("run", "admin.sock"))
+		require.NoError(t, err)
+
+		info, err := os.Stat(filepath.Join(d.Path(), "run", "admin.sock"))
+		require.NoError(t, err)
+		assert.Equal(t, fs.ModeSocket, info.Mode().Type())
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		// Keep the socket behind, as a listener process which did not exit cleanly would.
+		if l, ok := ln.(interface{ SetUnlinkOnClose(bool) }); ok {
+			l.SetUnlinkOnClose(false)
+		}
+		require.NoError(t, ln.Close())
+	}
+
+	_, err = d.Listen(filepath.Join("..", "escape.sock"))
+	assert.Error(t, err)
+}
+
+func TestWriteTerminationMessage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "termination-log")
+	require.NoError(t, WriteTerminationMessage(path, "idle"))
+
+	message, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "idle", string(message))
+
+	assert.Error(t, WriteTerminationMessage(filepath.Join(t.TempDir(), "missing", "termination-log"), "idle"))
+}
+
+// TestNoDirectFileWrites guards the read-only root filesystem compatibility of the listener:
+// files must only be written through this package, which places them in the writable directory.
+func TestNoDirectFileWrites(t *testing.T) {
+	forbidden := map[string]bool{
+		"Create":     true,
+		"CreateTemp": true,
+		"Mkdir":      true,
+		"MkdirAll":   true,
+		"MkdirTemp":  true,
+		"OpenFile":   true,
+		"WriteFile":  true,
+		"Rename":     true,
+		"Symlink":    true,
+		"Link":       true,
+	}
+
+	root, err := filepath.Abs("..")
+	require.NoError(t, err)
+	self, err := filepath.Abs(".")
+	require.NoError(t, err)
+
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == self {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, imp := range file.Imports {
+			if imp.Path.Value == `"io/ioutil"` {
+				t.Errorf("%s: io/ioutil must not be used, write files through the workdir package", fset.Position(imp.Pos()))
+			}
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			pkg, ok := sel.X.(*ast.Ident)
+			if ok && pkg.Name == "os" && forbidden[sel.Sel.Name] {
+				t.Errorf("%s: os.%s writes to the filesystem, use the workdir package instead", fset.Position(sel.Pos()), sel.Sel.Name)
+			}
+			return true
+		})
+		return nil
+	})
+	require.NoError(t, err)
+}
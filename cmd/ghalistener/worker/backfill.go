@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Backfill seeds the scaling state from the ephemeral runner set and its ephemeral runners in the cluster,
+// so the first patch after a restart does not scale the set down to the minimum runners while jobs are running.
+//
+// The replicas of the set become the last patch, which the scale step limits apply to,
+// and the runners running a job become the assigned job count re-used by empty batches.
+// During a migration or a canary, the target set and its runners are counted as well,
+// and a canary rolled back before the restart stays rolled back. With a topology spread, the sets
+// of all the zones are counted, and the sets which do not pin their runners to their zone are logged.
+// It does nothing once the worker patched the set, or with a scale target.
+func (w *Worker) Backfill(ctx context.Context) error {
+	if w.target != nil {
+		return nil
+	}
+
+	names := w.ephemeralRunnerSetNames()
+	replicas := 0
+	ephemeralRunnerSets := make([]*v1alpha1.EphemeralRunnerSet, 0, len(names))
+	for _, name := range names {
+		ephemeralRunnerSet, err := w.getEphemeralRunnerSet(ctx, name)
+		if err != nil {
+			return err
+		}
+		replicas += ephemeralRunnerSet.Spec.Replicas
+		if w.config.Canary != nil && name == w.config.Canary.EphemeralRunnerSetName {
+			w.restoreCanaryRollback(ephemeralRunnerSet)
+		}
+		if spread := w.config.TopologySpread; spread != nil {
+			if zone, ok := spread.zoneOf(name); ok && !pinnedTo(ephemeralRunnerSet, spread.TopologyKey, zone.Name) {
+				w.logger.Info("Ephemeral runner set of the zone does not pin its runners to the zone, they may be scheduled to another one",
+					"name", name,
+					"zone", zone.Name,
+					"topologyKey", spread.TopologyKey,
+				)
+			}
+		}
+		ephemeralRunnerSets = append(ephemeralRunnerSets, ephemeralRunnerSet)
+	}
+
+	list, err := w.client.
+		Resource(w.resource(ephemeralRunnersResource)).
+		Namespace(w.config.EphemeralRunnerSetNamespace).
+		List(ctx, metav1.ListOptions{})
+	w.health.RecordKubernetesRequest(err == nil || !isTransientError(err))
+	if err != nil {
+		return fmt.Errorf("failed to list ephemeral runners: %w", err)
+	}
+
+	busy := 0
+	for i := range list.Items {
+		ephemeralRunner := new(v1alpha1.EphemeralRunner)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].UnstructuredContent(), ephemeralRunner); err != nil {
+			return fmt.Errorf("failed to convert ephemeral runner %q: %w", list.Items[i].GetName(), err)
+		}
+		if !ownedByAny(ephemeralRunner, ephemeralRunnerSets) || !ephemeralRunner.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if ephemeralRunner.Status.JobRequestId > 0 {
+			busy++
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.lastPatch >= 0 {
+		return nil
+	}
+	w.lastPatch = replicas
+	w.lastAssigned = busy
+
+	w.logger.Info("Backfilled the scaling state from the cluster",
+		"replicas", w.lastPatch,
+		"busyRunners", busy,
+	)
+	return nil
+}
+
+// getEphemeralRunnerSet reads the ephemeral runner set of the namespace.
+func (w *Worker) getEphemeralRunnerSet(ctx context.Context, name string) (*v1alpha1.EphemeralRunnerSet, error) {
+	obj, err := w.client.
+		Resource(w.resource(ephemeralRunnerSetsResource)).
+		Namespace(w.config.EphemeralRunnerSetNamespace).
+		Get(ctx, name, metav1.GetOptions{})
+	w.health.RecordKubernetesRequest(err == nil || !isTransientError(err))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ephemeral runner set %q: %w", name, err)
+	}
+	ephemeralRunnerSet := new(v1alpha1.EphemeralRunnerSet)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), ephemeralRunnerSet); err != nil {
+		return nil, fmt.Errorf("failed to convert ephemeral runner set %q: %w", name, err)
+	}
+	return ephemeralRunnerSet, nil
+}
+
+// ephemeralRunnerSetNames returns the names of the ephemeral runner sets scaled by the worker: the set,
+// the target set of a migration or a canary, and the sets of the zones of a topology spread.
+func (w *Worker) ephemeralRunnerSetNames() []string {
+	names := []string{w.config.EphemeralRunnerSetName}
+	if target, _, ok := w.splitTarget(); ok {
+		names = append(names, target)
+	}
+	if spread := w.config.TopologySpread; spread != nil {
+		for _, zone := range spread.Zones {
+			if zone.EphemeralRunnerSetName != w.config.EphemeralRunnerSetName {
+				names = append(names, zone.EphemeralRunnerSetName)
+			}
+		}
+	}
+	return names
+}
+
+// ownedBy reports whether the ephemeral runner set is the controller of the ephemeral runner.
+func ownedBy(ephemeralRunner *v1alpha1.EphemeralRunner, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) bool {
+	owner := metav1.GetControllerOf(ephemeralRunner)
+	return owner != nil && owner.Kind == "EphemeralRunnerSet" && owner.Name == ephemeralRunnerSet.Name
+}
+
+func ownedByAny(ephemeralRunner *v1alpha1.EphemeralRunner, ephemeralRunnerSets []*v1alpha1.EphemeralRunnerSet) bool {
+	for _, ephemeralRunnerSet := range ephemeralRunnerSets {
+		if ownedBy(ephemeralRunner, ephemeralRunnerSet) {
+			return true
+		}
+	}
+	return false
+}
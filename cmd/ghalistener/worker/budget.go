@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// reasonBudgetExhausted is the reason of the event recorded on the ephemeral runner set
+// when the runner minutes budget of the day is exhausted.
+const reasonBudgetExhausted = "BudgetExhausted"
+
+var eventsResource = corev1.SchemeGroupVersion.WithResource("events")
+
+// runnerBudget is the runner time of the current UTC day.
+type runnerBudget struct {
+	// day is the start of the day the runner time is counted for.
+	day time.Time
+	// used is the runner time of the day, as last read from the ephemeral runner sets.
+	used time.Duration
+	// exhausted is set once used reaches the budget, until the next day.
+	exhausted bool
+	// published is the state of exhausted the budget metric was last published with.
+	published bool
+}
+
+// refreshBudget reads the runner time of the day from the status of the ephemeral runner sets, where the
+// controller counts the time their runners actually ran, so that the budget survives the restarts of the listener.
+// The runner time never decreases within a day. If a set cannot be read, the runner time last read is kept.
+func (w *Worker) refreshBudget(ctx context.Context) {
+	if w.config.MaxRunnerMinutesPerDay <= 0 || w.target != nil {
+		return
+	}
+
+	now := w.now()
+	var used time.Duration
+	for _, name := range w.ephemeralRunnerSetNames() {
+		ephemeralRunnerSet, err := w.getEphemeralRunnerSet(ctx, name)
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			w.logger.Error(err, "Failed to read the runner time of the day, keeping the runner time last read", "name", name)
+			return
+		}
+		used += runnerTime(&ephemeralRunnerSet.Status, now)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.renewBudget(now)
+	w.budget.used = max(w.budget.used, used)
+}
+
+// runnerTime returns the runner time of the day of the status: the time counted by the controller,
+// and the time the running runners ran since.
+func runnerTime(status *v1alpha1.EphemeralRunnerSetStatus, now time.Time) time.Duration {
+	m := status.RunnerMinutes
+	if m == nil {
+		return 0
+	}
+
+	day := now.UTC().Truncate(24 * time.Hour)
+	from := m.CountedAt.Time
+	var used time.Duration
+	if m.Day.Time.Equal(day) {
+		used = time.Duration(m.Seconds) * time.Second
+	} else if from.Before(day) {
+		from = day
+	}
+	if now.After(from) {
+		used += time.Duration(status.RunningEphemeralRunners) * now.Sub(from)
+	}
+	return used
+}
+
+// renewBudget resets the runner time once the day changed. It must be called with w.mu held.
+func (w *Worker) renewBudget(now time.Time) {
+	if day := now.UTC().Truncate(24 * time.Hour); !day.Equal(w.budget.day) {
+		w.budget.day, w.budget.used = day, 0
+	}
+}
+
+// budgetExhausted returns whether the runner minutes budget of the day is exhausted.
+// It must be called with w.mu held.
+func (w *Worker) budgetExhausted(now time.Time) bool {
+	if w.config.MaxRunnerMinutesPerDay <= 0 {
+		return false
+	}
+
+	w.renewBudget(now)
+	b := &w.budget
+	b.exhausted = b.used >= time.Duration(w.config.MaxRunnerMinutesPerDay)*time.Minute
+	return b.exhausted
+}
+
+// budgetChanged returns whether the budget is exhausted, and whether it got exhausted or renewed
+// since the last call.
+func (w *Worker) budgetChanged() (exhausted, changed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &w.budget
+	changed = b.exhausted != b.published
+	b.published = b.exhausted
+	return b.exhausted, changed
+}
+
+// publishBudget exports the state of the budget once it changes, and records an event
+// on the ephemeral runner set when it is exhausted.
+func (w *Worker) publishBudget(ctx context.Context) {
+	exhausted, changed := w.budgetChanged()
+	if !changed {
+		return
+	}
+	w.metrics.PublishBudgetExhausted(exhausted)
+	if !exhausted {
+		w.logger.Info("Runner minutes budget renewed")
+		return
+	}
+
+	message := fmt.Sprintf("The runner minutes budget of %d minutes per day is exhausted, scale-ups are capped at the min runners until the next day", w.config.MaxRunnerMinutesPerDay)
+	w.logger.Info("Runner minutes budget exhausted", "maxRunnerMinutesPerDay", w.config.MaxRunnerMinutesPerDay)
+	if err := w.recordEvent(ctx, corev1.EventTypeWarning, reasonBudgetExhausted, message); err != nil {
+		w.logger.Error(err, "Failed to record the budget exhausted event")
+	}
+}
+
+// recordEvent records an event on the ephemeral runner set.
+func (w *Worker) recordEvent(ctx context.Context, eventType, reason, message string) error {
+	now := metav1.NewTime(w.now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: w.config.EphemeralRunnerSetName + "-",
+			Namespace:    w.config.EphemeralRunnerSetNamespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: w.resource(ephemeralRunnerSetsResource).GroupVersion().String(),
+			Kind:       "EphemeralRunnerSet",
+			Namespace:  w.config.EphemeralRunnerSetNamespace,
+			Name:       w.config.EphemeralRunnerSetName,
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: "gha-runner-scale-set-listener"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(event)
+	if err != nil {
+		return fmt.Errorf("failed to convert the event: %w", err)
+	}
+	u := &unstructured.Unstructured{Object: object}
+	u.SetAPIVersion(corev1.SchemeGroupVersion.String())
+	u.SetKind("Event")
+
+	_, err = w.client.Resource(eventsResource).Namespace(w.config.EphemeralRunnerSetNamespace).Create(ctx, u, metav1.CreateOptions{})
+	return err
+}
@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	metricsmocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// runnerSet returns the ephemeral runner set whose runners ran for the runner time, and run since counted.
+func runnerSet(name string, running int, seconds int64, day, counted time.Time) *v1alpha1.EphemeralRunnerSet {
+	return &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "namespace"},
+		Status: v1alpha1.EphemeralRunnerSetStatus{
+			RunningEphemeralRunners: running,
+			RunnerMinutes: &v1alpha1.RunnerMinutesStatus{
+				Day:       metav1.NewTime(day),
+				Seconds:   seconds,
+				CountedAt: metav1.NewTime(counted),
+			},
+		},
+	}
+}
+
+func TestBudget_CapsScaleUpsOnceExhausted(t *testing.T) {
+	now := time.Date(2026, 5, 1, 22, 0, 0, 0, time.UTC)
+	day := now.Truncate(24 * time.Hour)
+	// 4 runners run since 21:50, after 10 runner minutes of the day.
+	w, client := newFakeClientWorker(t, runnerSet("set", 4, 10*60, day, now.Add(-10*time.Minute)))
+	w.config.MinRunners = 1
+	w.config.MaxRunnerMinutesPerDay = 60
+	fakeClock := clocktesting.NewFakeClock(now)
+	w.clock = fakeClock
+
+	w.refreshBudget(context.Background())
+	w.setDesiredWorkerState(5, 0)
+	assert.Equal(t, 6, w.lastPatch, "4 runners for 10 minutes are within the budget")
+	assert.Equal(t, 50*time.Minute, w.budget.used)
+
+	fakeClock.Step(5 * time.Minute)
+	w.refreshBudget(context.Background())
+	w.setDesiredWorkerState(5, 0)
+	assert.Equal(t, 1, w.lastPatch, "scale-ups are capped at the min runners once the budget is exhausted")
+	assert.Equal(t, ScaleReasonBudgetExhausted, w.lastDecision().Reason)
+	assert.Equal(t, []string{ClampBudget}, w.lastDecision().Clamps)
+
+	client.PrependReactor("get", ephemeralRunnerSetsResource, func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("unreachable")
+	})
+	w.refreshBudget(context.Background())
+	assert.Equal(t, 70*time.Minute, w.budget.used, "the runner time last read is kept while the set cannot be read")
+
+	fakeClock.Step(2 * time.Hour)
+	client.ReactionChain = client.ReactionChain[1:]
+	w.refreshBudget(context.Background())
+	w.setDesiredWorkerState(5, 0)
+	assert.Equal(t, 6, w.lastPatch, "the budget is renewed the next day")
+	assert.Equal(t, 4*5*time.Minute, w.budget.used, "only the runner time since midnight is counted")
+}
+
+func TestBudget_CountsTheRunnersOfAllTheSets(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	day := now.Truncate(24 * time.Hour)
+	w, _ := newFakeClientWorker(t,
+		runnerSet("set", 0, 30*60, day, now),
+		runnerSet("set-new", 2, 0, day, now.Add(-10*time.Minute)),
+	)
+	w.config.Migration = &Migration{TargetEphemeralRunnerSetName: "set-new", Percentage: 100}
+	w.config.MaxRunnerMinutesPerDay = 60
+	w.clock = clocktesting.NewFakeClock(now)
+
+	w.refreshBudget(context.Background())
+	assert.Equal(t, 50*time.Minute, w.budget.used, "the runners of the target of a migration are counted")
+}
+
+func TestBudget_PublishesExhaustion(t *testing.T) {
+	now := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	w, client := newFakeClientWorker(t, runnerSet("set", 2, 0, now.Truncate(24*time.Hour), now))
+	w.config.MaxRunnerMinutesPerDay = 1
+	fakeClock := clocktesting.NewFakeClock(now)
+	w.clock = fakeClock
+
+	publisher := metricsmocks.NewPublisher(t)
+	publisher.On("PublishScalingPolicy", ScalingPolicyStrict, "", []string(nil)).Once()
+	publisher.On("PublishScalingPolicy", ScalingPolicyStrict, "", []string{ClampBudget}).Twice()
+	publisher.On("PublishBudgetExhausted", true).Once()
+	publisher.On("PublishEphemeralRunnerSetPatchAttempt").Times(3)
+	publisher.On("PublishEphemeralRunnerSetPatch", mock.Anything, nil).Times(3)
+	w.metrics = publisher
+
+	_, err := w.HandleDesiredRunnerCount(context.Background(), 2, 0)
+	require.NoError(t, err)
+
+	fakeClock.Step(time.Minute)
+	replicas, err := w.HandleDesiredRunnerCount(context.Background(), 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, replicas)
+
+	fakeClock.Step(time.Minute)
+	_, err = w.HandleDesiredRunnerCount(context.Background(), 2, 0)
+	require.NoError(t, err)
+
+	var events []*unstructured.Unstructured
+	for _, action := range client.Actions() {
+		if create, ok := action.(k8stesting.CreateAction); ok && action.GetResource() == eventsResource {
+			events = append(events, create.GetObject().(*unstructured.Unstructured))
+		}
+	}
+	require.Len(t, events, 1, "the event is recorded once per exhaustion")
+	assert.Equal(t, reasonBudgetExhausted, events[0].Object["reason"])
+	assert.Equal(t, "Warning", events[0].Object["type"])
+	assert.Equal(t, "set", events[0].Object["involvedObject"].(map[string]any)["name"])
+}
@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/github/actions"
+)
+
+// Job results counted by the canary. Other results, e.g. cancelled jobs, say nothing about the runners.
+const (
+	jobResultSucceeded = "succeeded"
+	jobResultFailed    = "failed"
+)
+
+// Canary patches a share of the runners of the scale set into a canary ephemeral runner set,
+// e.g. one with a new runner template, and rolls it back if its runners fail jobs at a higher rate
+// than the runners of the ephemeral runner set of the config.
+type Canary struct {
+	// EphemeralRunnerSetName is the canary ephemeral runner set.
+	// It must be in the namespace of the ephemeral runner set of the config.
+	EphemeralRunnerSetName string
+	// Percentage is the share of the runners in the canary, between 1 and 99.
+	Percentage int
+	// MinJobs is the number of jobs the canary runners complete before their failure rate is evaluated.
+	MinJobs int
+	// MaxFailureRateIncrease is the failure rate of the canary runners, above the one of the other runners,
+	// which rolls the canary back, between 0 and 1.
+	MaxFailureRateIncrease float64
+}
+
+func (c *Canary) validate(ephemeralRunnerSetName string) error {
+	if c.EphemeralRunnerSetName == "" {
+		return errors.New("canary ephemeral runner set name is empty")
+	}
+	if c.EphemeralRunnerSetName == ephemeralRunnerSetName {
+		return fmt.Errorf("canary ephemeral runner set %q must differ from the ephemeral runner set", c.EphemeralRunnerSetName)
+	}
+	if c.Percentage < 1 || c.Percentage > 99 {
+		return fmt.Errorf("percentage %d must be between 1 and 99", c.Percentage)
+	}
+	if c.MinJobs < 1 {
+		return fmt.Errorf("min jobs %d must be positive", c.MinJobs)
+	}
+	if c.MaxFailureRateIncrease < 0 || c.MaxFailureRateIncrease > 1 {
+		return fmt.Errorf("max failure rate increase %v must be between 0 and 1", c.MaxFailureRateIncrease)
+	}
+	return nil
+}
+
+type jobResults struct {
+	completed int
+	failed    int
+}
+
+func (r *jobResults) record(result string) {
+	r.completed++
+	if result == jobResultFailed {
+		r.failed++
+	}
+}
+
+func (r *jobResults) failureRate() float64 {
+	if r.completed == 0 {
+		return 0
+	}
+	return float64(r.failed) / float64(r.completed)
+}
+
+// canaryState holds the job results of the canary and the other runners since the listener started.
+type canaryState struct {
+	mu         sync.Mutex
+	canary     jobResults
+	baseline   jobResults
+	rolledBack bool
+}
+
+func (s *canaryState) isRolledBack() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rolledBack
+}
+
+// runnerOf reports whether the ephemeral runner was created by the ephemeral runner set,
+// which generates the names of its runners from its own name.
+func runnerOf(runnerName, ephemeralRunnerSetName string) bool {
+	suffix, ok := strings.CutPrefix(runnerName, ephemeralRunnerSetName+"-runner-")
+	return ok && suffix != "" && !strings.Contains(suffix, "-")
+}
+
+// recordCanaryResult counts the result of the completed job against the canary or the other runners,
+// and rolls the canary back once its failure rate exceeds the one of the other runners by more than allowed.
+func (w *Worker) recordCanaryResult(ctx context.Context, jobInfo *actions.JobCompleted) {
+	canary := w.config.Canary
+	if canary == nil || jobInfo.RunnerName == "" {
+		return
+	}
+	if jobInfo.Result != jobResultSucceeded && jobInfo.Result != jobResultFailed {
+		return
+	}
+
+	w.canary.mu.Lock()
+	switch {
+	case runnerOf(jobInfo.RunnerName, canary.EphemeralRunnerSetName):
+		w.canary.canary.record(jobInfo.Result)
+	case runnerOf(jobInfo.RunnerName, w.config.EphemeralRunnerSetName):
+		w.canary.baseline.record(jobInfo.Result)
+	default:
+		w.canary.mu.Unlock()
+		return
+	}
+	if w.canary.rolledBack || w.canary.canary.completed < canary.MinJobs {
+		w.canary.mu.Unlock()
+		return
+	}
+	canaryRate, baselineRate := w.canary.canary.failureRate(), w.canary.baseline.failureRate()
+	if canaryRate <= baselineRate+canary.MaxFailureRateIncrease {
+		w.canary.mu.Unlock()
+		return
+	}
+	w.canary.rolledBack = true
+	w.canary.mu.Unlock()
+
+	w.logger.Info("Canary runners fail jobs at a higher rate, rolling the canary back",
+		"canary", canary.EphemeralRunnerSetName,
+		"canaryFailureRate", canaryRate,
+		"failureRate", baselineRate,
+	)
+	if err := w.annotateCanaryRollback(ctx, canary.EphemeralRunnerSetName); err != nil {
+		w.logger.Error(err, "Failed to annotate the rolled back canary, it is scaled up again after a restart", "canary", canary.EphemeralRunnerSetName, "errorCode", errcode.EphemeralRunnerSetPatch)
+	}
+}
+
+// annotateCanaryRollback records the rollback on the canary, so a restarted listener keeps it rolled back.
+func (w *Worker) annotateCanaryRollback(ctx context.Context, name string) error {
+	mergePatch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{
+				v1alpha1.EphemeralRunnerSetCanaryRolledBackAnnotationKey: w.now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal canary rollback patch: %w", err)
+	}
+	if err := w.patch(ctx, ephemeralRunnerSetsResource, name, mergePatch, nil); err != nil {
+		return fmt.Errorf("failed to patch the canary rollback annotation: %w", err)
+	}
+	return nil
+}
+
+// restoreCanaryRollback keeps the canary rolled back if it was annotated so.
+func (w *Worker) restoreCanaryRollback(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) {
+	rolledBackAt, ok := ephemeralRunnerSet.Annotations[v1alpha1.EphemeralRunnerSetCanaryRolledBackAnnotationKey]
+	if !ok {
+		return
+	}
+	w.canary.mu.Lock()
+	w.canary.rolledBack = true
+	w.canary.mu.Unlock()
+	w.logger.Info("Canary was rolled back, keeping it scaled down", "canary", ephemeralRunnerSet.Name, "rolledBackAt", rolledBackAt)
+}
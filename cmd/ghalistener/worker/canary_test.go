@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestRunnerOf(t *testing.T) {
+	assert.True(t, runnerOf("set-runner-abcde", "set"))
+	assert.False(t, runnerOf("set-canary-runner-abcde", "set"), "runners of a set prefixed with the name of another")
+	assert.True(t, runnerOf("set-canary-runner-abcde", "set-canary"))
+	assert.False(t, runnerOf("set-runner-", "set"))
+	assert.False(t, runnerOf("other-runner-abcde", "set"))
+}
+
+func TestCanaryValidate(t *testing.T) {
+	valid := Canary{EphemeralRunnerSetName: "canary", Percentage: 10, MinJobs: 5, MaxFailureRateIncrease: 0.1}
+	assert.NoError(t, valid.validate("set"))
+
+	tests := map[string]func(c *Canary){
+		"missing set":           func(c *Canary) { c.EphemeralRunnerSetName = "" },
+		"same set":              func(c *Canary) { c.EphemeralRunnerSetName = "set" },
+		"zero percentage":       func(c *Canary) { c.Percentage = 0 },
+		"all runners":           func(c *Canary) { c.Percentage = 100 },
+		"no jobs":               func(c *Canary) { c.MinJobs = 0 },
+		"negative rate":         func(c *Canary) { c.MaxFailureRateIncrease = -0.1 },
+		"rate above everything": func(c *Canary) { c.MaxFailureRateIncrease = 1.5 },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := valid
+			mutate(&c)
+			assert.Error(t, c.validate("set"))
+		})
+	}
+}
+
+func TestCanary(t *testing.T) {
+	newWorker := func(t *testing.T, canary *v1alpha1.EphemeralRunnerSet) *Worker {
+		w, _ := newFakeClientWorker(t,
+			&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"}},
+			canary,
+		)
+		w.clock = clocktesting.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		w.config.Canary = &Canary{
+			EphemeralRunnerSetName: "canary",
+			Percentage:             25,
+			MinJobs:                2,
+			MaxFailureRateIncrease: 0.2,
+		}
+		return w
+	}
+	canarySet := func(annotations map[string]string) *v1alpha1.EphemeralRunnerSet {
+		return &v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "canary", Namespace: "namespace", Annotations: annotations},
+		}
+	}
+	get := func(t *testing.T, w *Worker, name string) *v1alpha1.EphemeralRunnerSet {
+		obj, err := w.client.
+			Resource(v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource)).
+			Namespace("namespace").
+			Get(context.Background(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		set := new(v1alpha1.EphemeralRunnerSet)
+		require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), set))
+		return set
+	}
+	complete := func(t *testing.T, w *Worker, runnerName, result string) {
+		require.NoError(t, w.HandleJobCompleted(context.Background(), &actions.JobCompleted{RunnerName: runnerName, Result: result}))
+	}
+
+	t.Run("SplitsRunners", func(t *testing.T) {
+		w := newWorker(t, canarySet(nil))
+
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 8, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 6, get(t, w, "set").Spec.Replicas)
+		assert.Equal(t, 2, get(t, w, "canary").Spec.Replicas)
+	})
+
+	t.Run("RollsBackOnHigherFailureRate", func(t *testing.T) {
+		w := newWorker(t, canarySet(nil))
+
+		complete(t, w, "set-runner-aaaaa", jobResultSucceeded)
+		complete(t, w, "set-runner-bbbbb", jobResultFailed)
+		complete(t, w, "canary-runner-aaaaa", jobResultFailed)
+		complete(t, w, "canary-runner-bbbbb", "canceled")
+		assert.False(t, w.canary.isRolledBack(), "the canary is not evaluated before completing the min jobs")
+
+		complete(t, w, "canary-runner-ccccc", jobResultSucceeded)
+		assert.False(t, w.canary.isRolledBack(), "the canary fails at the rate of the other runners")
+
+		complete(t, w, "canary-runner-ddddd", jobResultFailed)
+		assert.False(t, w.canary.isRolledBack(), "the canary fails within the allowed increase")
+
+		complete(t, w, "canary-runner-eeeee", jobResultFailed)
+		require.True(t, w.canary.isRolledBack())
+		assert.Equal(t, "2024-01-01T00:00:00Z", get(t, w, "canary").Annotations[v1alpha1.EphemeralRunnerSetCanaryRolledBackAnnotationKey])
+
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 8, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 8, get(t, w, "set").Spec.Replicas)
+		assert.Equal(t, 0, get(t, w, "canary").Spec.Replicas)
+	})
+
+	t.Run("StaysRolledBackAfterRestart", func(t *testing.T) {
+		w := newWorker(t, canarySet(map[string]string{
+			v1alpha1.EphemeralRunnerSetCanaryRolledBackAnnotationKey: "2024-01-01T00:00:00Z",
+		}))
+
+		require.NoError(t, w.Backfill(context.Background()))
+		assert.True(t, w.canary.isRolledBack())
+	})
+}
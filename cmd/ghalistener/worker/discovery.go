@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// Resources are the resources of the actions.github.com kinds the worker patches, in the version served by the cluster.
+type Resources struct {
+	EphemeralRunners    schema.GroupVersionResource
+	EphemeralRunnerSets schema.GroupVersionResource
+}
+
+// WithResources sets the resources the worker patches, e.g. the ones resolved by DiscoverResources.
+// By default, the worker patches the resources of the version it is built against.
+func WithResources(resources Resources) Option {
+	return func(w *Worker) {
+		w.resources = resources
+	}
+}
+
+// supportedVersions are the versions of the actions.github.com API the worker can patch, by preference.
+// Only the version the worker is built against is supported, since the worker reads the resources into its
+// types: a newer version is added once its schema exists, so that none of its fields are silently dropped.
+var supportedVersions = []string{v1alpha1.GroupVersion.Version}
+
+// DiscoverResources resolves the resources of the EphemeralRunner and EphemeralRunnerSet kinds through
+// API discovery, in the first supported version served by the cluster. It fails if the CRDs are not installed,
+// if none of their served versions is supported, or if they do not serve the patch verb and the status
+// subresource of the ephemeral runners the worker relies on.
+func DiscoverResources(client discovery.DiscoveryInterface) (Resources, error) {
+	group := v1alpha1.GroupVersion.Group
+	version, err := discoverVersion(client)
+	if err != nil {
+		return Resources{}, err
+	}
+
+	groupVersion := schema.GroupVersion{Group: group, Version: version}
+	list, err := client.ServerResourcesForGroupVersion(groupVersion.String())
+	if err != nil {
+		return Resources{}, errcode.Errorf(errcode.APIDiscovery, "failed to discover the %s API: %w", groupVersion, err)
+	}
+
+	var resources Resources
+	for _, kind := range []struct {
+		name         string
+		into         *schema.GroupVersionResource
+		subresources []string
+	}{
+		{name: "EphemeralRunner", into: &resources.EphemeralRunners, subresources: []string{"status"}},
+		{name: "EphemeralRunnerSet", into: &resources.EphemeralRunnerSets},
+	} {
+		resource, ok := findResource(list.APIResources, kind.name)
+		if !ok {
+			return Resources{}, errcode.Errorf(errcode.APIDiscovery, "the %s API does not serve the %s kind, install or upgrade the actions-runner-controller CRDs", groupVersion, kind.name)
+		}
+		if !slices.Contains(resource.Verbs, "patch") {
+			return Resources{}, errcode.Errorf(errcode.APIDiscovery, "the %s resource of the %s API does not serve the patch verb", resource.Name, groupVersion)
+		}
+		for _, subresource := range kind.subresources {
+			if _, ok := findSubresource(list.APIResources, resource.Name, subresource); !ok {
+				return Resources{}, errcode.Errorf(errcode.APIDiscovery, "the %s resource of the %s API does not serve the %s subresource, upgrade the actions-runner-controller CRDs", resource.Name, groupVersion, subresource)
+			}
+		}
+		*kind.into = groupVersion.WithResource(resource.Name)
+	}
+	return resources, nil
+}
+
+// discoverVersion returns the first supported version of the actions.github.com API served by the cluster.
+func discoverVersion(client discovery.DiscoveryInterface) (string, error) {
+	group := v1alpha1.GroupVersion.Group
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return "", errcode.Errorf(errcode.APIDiscovery, "failed to discover the %s API: %w", group, err)
+	}
+
+	var served []string
+	for _, g := range groups.Groups {
+		if g.Name != group {
+			continue
+		}
+		for _, v := range g.Versions {
+			served = append(served, v.Version)
+		}
+	}
+	if len(served) == 0 {
+		return "", errcode.Errorf(errcode.APIDiscovery, "the %s API is not served, install the actions-runner-controller CRDs", group)
+	}
+
+	for _, version := range supportedVersions {
+		if slices.Contains(served, version) {
+			return version, nil
+		}
+	}
+	return "", errcode.Errorf(errcode.APIDiscovery, "none of the served versions of the %s API (%s) is supported by the listener (%s), align the versions of the listener image and the actions-runner-controller CRDs",
+		group, strings.Join(served, ", "), strings.Join(supportedVersions, ", "))
+}
+
+// findResource returns the resource of the kind, ignoring its subresources which share the kind.
+func findResource(resources []metav1.APIResource, kind string) (metav1.APIResource, bool) {
+	for _, r := range resources {
+		if r.Kind == kind && !strings.Contains(r.Name, "/") {
+			return r, true
+		}
+	}
+	return metav1.APIResource{}, false
+}
+
+func findSubresource(resources []metav1.APIResource, resource, subresource string) (metav1.APIResource, bool) {
+	name := resource + "/" + subresource
+	for _, r := range resources {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return metav1.APIResource{}, false
+}
+
+// resource returns the resource of the kind the worker patches, named after its default resource name.
+// Workers built without New and without WithResources patch the default resource.
+func (w *Worker) resource(name string) schema.GroupVersionResource {
+	var gvr schema.GroupVersionResource
+	switch name {
+	case ephemeralRunnersResource:
+		gvr = w.resources.EphemeralRunners
+	case ephemeralRunnerSetsResource:
+		gvr = w.resources.EphemeralRunnerSets
+	}
+	if gvr.Empty() {
+		return v1alpha1.GroupVersion.WithResource(name)
+	}
+	return gvr
+}
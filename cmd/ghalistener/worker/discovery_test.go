@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func actionsAPIResources(version string) *metav1.APIResourceList {
+	verbs := metav1.Verbs{"get", "list", "watch", "patch", "update"}
+	return &metav1.APIResourceList{
+		GroupVersion: "actions.github.com/" + version,
+		APIResources: []metav1.APIResource{
+			{Name: "ephemeralrunners", Kind: "EphemeralRunner", Namespaced: true, Verbs: verbs},
+			{Name: "ephemeralrunners/status", Kind: "EphemeralRunner", Namespaced: true, Verbs: metav1.Verbs{"get", "patch", "update"}},
+			{Name: "ephemeralrunnersets", Kind: "EphemeralRunnerSet", Namespaced: true, Verbs: verbs},
+			{Name: "ephemeralrunnersets/status", Kind: "EphemeralRunnerSet", Namespaced: true, Verbs: metav1.Verbs{"get", "patch", "update"}},
+		},
+	}
+}
+
+func TestDiscoverResources(t *testing.T) {
+	t.Run("Installed", func(t *testing.T) {
+		client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{actionsAPIResources("v1alpha1")}}}
+
+		resources, err := DiscoverResources(client)
+		require.NoError(t, err)
+		assert.Equal(t, v1alpha1.GroupVersion.WithResource(ephemeralRunnersResource), resources.EphemeralRunners)
+		assert.Equal(t, v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource), resources.EphemeralRunnerSets)
+	})
+
+	t.Run("NotInstalled", func(t *testing.T) {
+		client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+
+		_, err := DiscoverResources(client)
+		code, ok := errcode.Of(err)
+		require.True(t, ok)
+		assert.Equal(t, errcode.APIDiscovery, code)
+		assert.ErrorContains(t, err, "the actions.github.com API is not served, install the actions-runner-controller CRDs")
+	})
+
+	t.Run("NewerVersion", func(t *testing.T) {
+		client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{actionsAPIResources("v1beta1")}}}
+
+		_, err := DiscoverResources(client)
+		assert.ErrorContains(t, err, "none of the served versions of the actions.github.com API (v1beta1) is supported by the listener (v1alpha1)")
+	})
+
+	t.Run("PrefersBuiltVersion", func(t *testing.T) {
+		client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{
+			actionsAPIResources("v1beta1"),
+			actionsAPIResources("v1alpha1"),
+		}}}
+
+		resources, err := DiscoverResources(client)
+		require.NoError(t, err)
+		assert.Equal(t, v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource), resources.EphemeralRunnerSets)
+	})
+
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{actionsAPIResources("v2")}}}
+
+		_, err := DiscoverResources(client)
+		assert.ErrorContains(t, err, "none of the served versions of the actions.github.com API (v2) is supported by the listener (v1alpha1)")
+	})
+
+	t.Run("MissingKind", func(t *testing.T) {
+		list := actionsAPIResources("v1alpha1")
+		list.APIResources = list.APIResources[2:]
+		client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{list}}}
+
+		_, err := DiscoverResources(client)
+		assert.ErrorContains(t, err, "the actions.github.com/v1alpha1 API does not serve the EphemeralRunner kind")
+	})
+
+	t.Run("MissingStatusSubresource", func(t *testing.T) {
+		list := actionsAPIResources("v1alpha1")
+		list.APIResources = append(list.APIResources[:1], list.APIResources[2:]...)
+		client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{list}}}
+
+		_, err := DiscoverResources(client)
+		assert.ErrorContains(t, err, "the ephemeralrunners resource of the actions.github.com/v1alpha1 API does not serve the status subresource")
+	})
+
+	t.Run("DiscoveryError", func(t *testing.T) {
+		fake := &k8stesting.Fake{}
+		fake.AddReactor("get", "group", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("connection refused")
+		})
+		client := &fakediscovery.FakeDiscovery{Fake: fake}
+
+		_, err := DiscoverResources(client)
+		code, ok := errcode.Of(err)
+		require.True(t, ok)
+		assert.Equal(t, errcode.APIDiscovery, code)
+		assert.ErrorContains(t, err, "failed to discover the actions.github.com API: connection refused")
+	})
+}
+
+func TestWorker_resource(t *testing.T) {
+	w := &Worker{}
+	assert.Equal(t, v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource), w.resource(ephemeralRunnerSetsResource), "workers built without New patch the default resources")
+
+	discovered := v1alpha1.GroupVersion.WithResource("ephemeralrunnersets")
+	discovered.Version = "v1beta1"
+	WithResources(Resources{EphemeralRunnerSets: discovered})(w)
+	assert.Equal(t, discovered, w.resource(ephemeralRunnerSetsResource))
+}
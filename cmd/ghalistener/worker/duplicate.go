@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"slices"
+	"time"
+)
+
+// sentPatch is the last scale decision patched, against which the next ones are compared
+// to suppress the duplicates.
+type sentPatch struct {
+	// replicas are the replicas of every resource patched by the decision.
+	replicas []int
+	at       time.Time
+}
+
+// duplicatePatch reports whether the replicas repeat the last ones patched within the DuplicatePatchTTL.
+// A batch which completed jobs never repeats the last patch, since the runners of the completed jobs
+// are only replaced by the controller on a new patch ID. Once the TTL elapses, the replicas are
+// patched anyway, which periodically resyncs the ephemeral runner set.
+func (w *Worker) duplicatePatch(jobsCompleted int, replicas []int) bool {
+	if w.config.DuplicatePatchTTL <= 0 || jobsCompleted > 0 {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sent := w.sent
+	if sent == nil || !slices.Equal(sent.replicas, replicas) || w.now().Sub(sent.at) >= w.config.DuplicatePatchTTL {
+		return false
+	}
+	return true
+}
+
+// markPatchSent records the replicas of every resource patched by the last scale decision.
+func (w *Worker) markPatchSent(replicas []int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sent = &sentPatch{replicas: replicas, at: w.now()}
+}
+
+// resyncPatch reports whether the patch resyncs the ephemeral runner set, which is an empty batch repeating the
+// replicas of the last patch. It is patched with the patch ID 0, which lets the controller delete the idle runners
+// exceeding the replicas, e.g. the ones it created on accident during a scale-down. With a DuplicatePatchTTL, the
+// resyncs are the patches repeating the last one once the TTL elapses.
+func (w *Worker) resyncPatch(count, jobsCompleted int, replicas []int) bool {
+	if count != 0 || jobsCompleted != 0 {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sent != nil && slices.Equal(w.sent.replicas, replicas)
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestHandleDesiredRunnerCount_DuplicatePatch(t *testing.T) {
+	w, client := newFakeClientWorker(t,
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"}},
+	)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	w.clock = fakeClock
+	w.config.DuplicatePatchTTL = 10 * time.Minute
+
+	patches := 0
+	var patchIDs []int
+	client.PrependReactor("patch", ephemeralRunnerSetsResource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patches++
+		var patch v1alpha1.EphemeralRunnerSet
+		require.NoError(t, json.Unmarshal(action.(k8stesting.PatchAction).GetPatch(), &patch))
+		patchIDs = append(patchIDs, patch.Spec.PatchID)
+		return false, nil, nil
+	})
+	handle := func(count, jobsCompleted int) {
+		t.Helper()
+		_, err := w.HandleDesiredRunnerCount(context.Background(), count, jobsCompleted)
+		require.NoError(t, err)
+	}
+
+	handle(3, 0)
+	assert.Equal(t, 1, patches)
+
+	handle(0, 0)
+	handle(3, 0)
+	assert.Equal(t, 1, patches, "the decisions repeating the last patch are suppressed")
+
+	handle(3, 1)
+	assert.Equal(t, 2, patches, "the runners of the completed jobs are replaced")
+
+	handle(4, 0)
+	assert.Equal(t, 3, patches, "a new target is patched")
+
+	fakeClock.Step(10 * time.Minute)
+	handle(0, 0)
+	assert.Equal(t, 4, patches, "the last patch is resynced once the TTL elapsed")
+
+	w.config.DuplicatePatchTTL = 0
+	handle(0, 0)
+	assert.Equal(t, 5, patches, "every decision is patched without a TTL")
+	assert.Equal(t, []int{0, 3, 4, 0, 0}, patchIDs, "the empty batches repeating the last patch are resyncs")
+}
+
+func TestHandleDesiredRunnerCount_Resync(t *testing.T) {
+	w, client := newFakeClientWorker(t,
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"}},
+	)
+	w.config.MinRunners = 1
+
+	var patchIDs []int
+	client.PrependReactor("patch", ephemeralRunnerSetsResource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		var patch v1alpha1.EphemeralRunnerSet
+		require.NoError(t, json.Unmarshal(action.(k8stesting.PatchAction).GetPatch(), &patch))
+		patchIDs = append(patchIDs, patch.Spec.PatchID)
+		return false, nil, nil
+	})
+	handle := func(count, jobsCompleted int) {
+		t.Helper()
+		_, err := w.HandleDesiredRunnerCount(context.Background(), count, jobsCompleted)
+		require.NoError(t, err)
+	}
+
+	handle(3, 0)
+	handle(0, 3)
+	handle(0, 0)
+	handle(2, 0)
+	handle(2, 0)
+	assert.Equal(t, []int{0, 1, 0, 3, 4}, patchIDs,
+		"an empty batch repeating the last patch is a resync, the batches assigning jobs are not")
+}
@@ -0,0 +1,19 @@
+package worker
+
+import "context"
+
+// HandleSessionUnavailable scales the runners to the fallback replicas, once the message session
+// could not be established or refreshed for a while. Without FallbackReplicas, the runners of the last
+// assigned job count are kept, re-evaluated against the current runner bounds.
+func (w *Worker) HandleSessionUnavailable(ctx context.Context) (int, error) {
+	w.mu.Lock()
+	w.fallback = true
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.fallback = false
+		w.mu.Unlock()
+	}()
+
+	return w.HandleDesiredRunnerCount(ctx, 0, 0)
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestHandleSessionUnavailable(t *testing.T) {
+	set := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"},
+	}
+
+	t.Run("FallbackReplicas", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t, set.DeepCopy())
+		w.config.MinRunners = 1
+		w.config.MaxRunners = 10
+		w.config.FallbackReplicas = ptr.To(5)
+
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 2, 0)
+		require.NoError(t, err)
+
+		replicas, err := w.HandleSessionUnavailable(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 5, replicas)
+		assert.Equal(t, ScaleReasonFallback, w.lastDecision().Reason)
+
+		replicas, err = w.HandleDesiredRunnerCount(context.Background(), 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, replicas, "the last assigned jobs are scaled to once the session is available again")
+		assert.Equal(t, ScaleReasonAssignedJobs, w.lastDecision().Reason)
+	})
+
+	t.Run("FallbackReplicasWithinRunnerBounds", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t, set.DeepCopy())
+		w.config.MinRunners = 1
+		w.config.MaxRunners = 4
+		w.config.FallbackReplicas = ptr.To(5)
+
+		replicas, err := w.HandleSessionUnavailable(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 4, replicas)
+
+		w.config.FallbackReplicas = ptr.To(0)
+		replicas, err = w.HandleSessionUnavailable(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, replicas)
+	})
+
+	t.Run("LastKnownDemand", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t, set.DeepCopy())
+		w.config.MinRunners = 1
+
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 2, 0)
+		require.NoError(t, err)
+
+		replicas, err := w.HandleSessionUnavailable(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 3, replicas)
+		assert.Equal(t, ScaleReasonFallback, w.lastDecision().Reason)
+	})
+}
@@ -0,0 +1,43 @@
+package worker
+
+import "time"
+
+// forcedScale pins the replicas of the scaling decisions until it expires.
+type forcedScale struct {
+	replicas int
+	until    time.Time
+}
+
+// ForceScale pins the replicas to the runners until the time, whatever the jobs assigned, e.g. to scale
+// the scale set down to zero during an incident. The replicas are capped to the max runners, and are not
+// limited by the scale steps. The forced scale applies from the next patch, and replaces the previous one.
+func (w *Worker) ForceScale(replicas int, until time.Time) {
+	w.mu.Lock()
+	w.forced = &forcedScale{replicas: replicas, until: until}
+	w.mu.Unlock()
+
+	w.logger.Info("Scale forced", "replicas", replicas, "until", until)
+}
+
+// CancelForceScale lets the jobs assigned scale the runners again from the next patch.
+func (w *Worker) CancelForceScale() {
+	w.mu.Lock()
+	w.forced = nil
+	w.mu.Unlock()
+
+	w.logger.Info("Forced scale cancelled")
+}
+
+// forcedReplicas drops the forced scale once expired and returns its replicas.
+// It must be called with w.mu held.
+func (w *Worker) forcedReplicas(now time.Time) (int, bool) {
+	if w.forced == nil {
+		return 0, false
+	}
+	if !now.Before(w.forced.until) {
+		w.logger.Info("Forced scale expired", "replicas", w.forced.replicas)
+		w.forced = nil
+		return 0, false
+	}
+	return w.forced.replicas, true
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestSetDesiredWorkerState_ForceScale(t *testing.T) {
+	logger := logr.Discard()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clocktesting.NewFakeClock(now)
+	w := &Worker{
+		config: Config{
+			MaxRunners:     20,
+			MaxScaleUpStep: 2,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+		clock:     fakeClock,
+	}
+
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 0, w.lastPatch)
+
+	w.ForceScale(10, now.Add(time.Hour))
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 10, w.lastPatch, "the forced runners are not limited by the scale step")
+	assert.Equal(t, ScaleReasonForced, w.lastDecision().Reason)
+
+	w.ForceScale(math.MaxInt32, now.Add(time.Hour))
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 20, w.lastPatch, "the forced runners are capped to the max runners")
+
+	w.ForceScale(10, now.Add(time.Hour))
+	fakeClock.Step(time.Hour)
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 0, w.lastPatch, "the scale set scales back down once the forced scale expires")
+	assert.Nil(t, w.forced)
+
+	w.ForceScale(10, now.Add(2*time.Hour))
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 10, w.lastPatch)
+	w.CancelForceScale()
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 0, w.lastPatch)
+}
@@ -0,0 +1,69 @@
+package worker
+
+import "time"
+
+// forecastStep is the resolution of the capacity forecast, the resolution the predictor records the demand at.
+const forecastStep = 5 * time.Minute
+
+// Forecaster forecasts the demand of the scale set at any time, unlike Predict which only looks ahead by its lead.
+// The predictor of the worker implements it to contribute to the capacity forecast.
+type Forecaster interface {
+	// ForecastAt returns the job count forecast to be assigned at the time.
+	ForecastAt(t time.Time) int
+}
+
+// CapacityForecast is the peak runner count expected within a horizon, for tooling provisioning the nodes
+// of the runners ahead of the scale-ups of the listener.
+type CapacityForecast struct {
+	// Time is the time the forecast was made at.
+	Time time.Time `json:"time"`
+	// HorizonSeconds is how far ahead of Time the forecast looks.
+	HorizonSeconds int `json:"horizonSeconds"`
+	// PeakRunners is the highest runner count expected within the horizon.
+	PeakRunners int `json:"peakRunners"`
+	// PeakTime is the first time within the horizon PeakRunners is expected at.
+	PeakTime time.Time `json:"peakTime"`
+	// Schedule is the name of the scheduled override in effect at PeakTime, if any.
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// Forecast returns the peak runner count expected within the horizon. Every 5 minutes of the horizon,
+// the expected runner count is the min runners of the scheduled overrides and the reservations plus the
+// jobs forecast by the predictor, capped at the max runners. The jobs assigned now count towards the
+// start of the horizon. The idle timeout and the runner minutes budget are not forecast.
+func (w *Worker) Forecast(horizon time.Duration) CapacityForecast {
+	now := w.now()
+	forecaster, _ := w.predictor.(Forecaster)
+
+	w.mu.Lock()
+	assigned := w.lastAssigned
+	reservations := append([]reservation(nil), w.reservations...)
+	w.mu.Unlock()
+
+	f := CapacityForecast{Time: now, HorizonSeconds: int(horizon.Seconds()), PeakRunners: -1}
+	for offset := time.Duration(0); offset <= horizon; offset += forecastStep {
+		t := now.Add(offset)
+		minRunners, maxRunners, schedule := w.scheduledRunnerBounds(t)
+
+		reserved := 0
+		for _, r := range reservations {
+			if t.Before(r.until) {
+				reserved += r.runners
+			}
+		}
+		minRunners = max(minRunners, min(reserved, maxRunners))
+
+		jobs := 0
+		if forecaster != nil {
+			jobs = forecaster.ForecastAt(t)
+		}
+		if offset == 0 {
+			jobs = max(jobs, assigned)
+		}
+
+		if runners := min(minRunners+jobs, maxRunners); runners > f.PeakRunners {
+			f.PeakRunners, f.PeakTime, f.Schedule = runners, t, schedule
+		}
+	}
+	return f
+}
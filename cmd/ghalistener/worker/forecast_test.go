@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+)
+
+// fakeForecaster forecasts the jobs of its spikes, by start time of each 5 minutes slot.
+type fakeForecaster struct {
+	fakePredictor
+	spikes map[time.Time]int
+}
+
+func (f *fakeForecaster) ForecastAt(t time.Time) int {
+	return f.spikes[t.Truncate(forecastStep)]
+}
+
+func TestForecast(t *testing.T) {
+	logger := logr.Discard()
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+
+	t.Run("ScheduleAndPredictor", func(t *testing.T) {
+		w := &Worker{
+			config: Config{
+				MinRunners: 1,
+				MaxRunners: 20,
+				ScheduledOverrides: []ScheduledOverride{
+					{
+						StartTime:  now.Add(45 * time.Minute),
+						EndTime:    now.Add(8 * time.Hour),
+						MinRunners: ptr.To(5),
+						Name:       "business-hours",
+					},
+				},
+			},
+			logger: &logger,
+			clock:  clocktesting.NewFakeClock(now),
+			predictor: &fakeForecaster{spikes: map[time.Time]int{
+				now.Add(30 * time.Minute): 6,
+				now.Add(50 * time.Minute): 4,
+			}},
+		}
+
+		f := w.Forecast(time.Hour)
+		assert.Equal(t, 3600, f.HorizonSeconds)
+		assert.Equal(t, 9, f.PeakRunners, "the forecast jobs are added to the min runners of the schedule")
+		assert.Equal(t, now.Add(50*time.Minute), f.PeakTime)
+		assert.Equal(t, "business-hours", f.Schedule)
+
+		w.config.MaxRunners = 6
+		f = w.Forecast(time.Hour)
+		assert.Equal(t, 6, f.PeakRunners, "the forecast is capped at the max runners")
+		assert.Equal(t, now.Add(30*time.Minute), f.PeakTime, "the first time of the peak is forecast")
+		assert.Empty(t, f.Schedule)
+	})
+
+	t.Run("AssignedJobsAndReservations", func(t *testing.T) {
+		w := &Worker{
+			config: Config{
+				MinRunners: 1,
+				MaxRunners: 20,
+			},
+			logger:       &logger,
+			clock:        clocktesting.NewFakeClock(now),
+			lastAssigned: 3,
+			reservations: []reservation{
+				{runners: 6, until: now.Add(10 * time.Minute)},
+			},
+		}
+
+		f := w.Forecast(time.Hour)
+		assert.Equal(t, 9, f.PeakRunners, "the assigned jobs are added to the reserved runners")
+		assert.Equal(t, now, f.PeakTime)
+
+		w.lastAssigned = 0
+		w.predictor = &fakeForecaster{spikes: map[time.Time]int{
+			now.Add(5 * time.Minute):  2,
+			now.Add(30 * time.Minute): 4,
+		}}
+		f = w.Forecast(time.Hour)
+		assert.Equal(t, 8, f.PeakRunners, "the reserved runners are forecast until they expire")
+		assert.Equal(t, now.Add(5*time.Minute), f.PeakTime)
+
+		w.lastAssigned = 3
+		w.reservations = nil
+		w.predictor = nil
+		f = w.Forecast(time.Hour)
+		assert.Equal(t, 4, f.PeakRunners, "the assigned jobs are forecast at the start of the horizon")
+	})
+}
@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+)
+
+// maxRepositoryHints is the maximum number of repositories sent as hints with a scale patch.
+const maxRepositoryHints = 10
+
+// maxHintedJobs bounds the jobs tracked for the hints, so that the jobs whose start or completion is never
+// received, e.g. since the listener restarted, do not grow the map for good. The job assigned the earliest
+// is evicted to make room for a new one.
+const maxHintedJobs = 500
+
+// repositoryHints tracks the repositories of the jobs assigned to the scale set which have not started yet,
+// so the controller can place the runners created for them on nodes with warm caches for the same repositories.
+type repositoryHints struct {
+	mu sync.Mutex
+	// jobs maps the ID of the assigned jobs to their repository.
+	jobs map[string]hintedJob
+	// seq orders the assignments of the jobs.
+	seq uint64
+	// sent is the value of the last hints patched to the ephemeral runner set, nil if none was patched yet.
+	sent *string
+}
+
+type hintedJob struct {
+	repository string
+	seq        uint64
+}
+
+func (h *repositoryHints) assign(jobID, repository string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.jobs == nil {
+		h.jobs = make(map[string]hintedJob)
+	}
+	if _, ok := h.jobs[jobID]; !ok && len(h.jobs) >= maxHintedJobs {
+		h.evictEarliest()
+	}
+	h.seq++
+	h.jobs[jobID] = hintedJob{repository: repository, seq: h.seq}
+}
+
+// evictEarliest must be called with h.mu held.
+func (h *repositoryHints) evictEarliest() {
+	var earliest string
+	for jobID, job := range h.jobs {
+		if earliest == "" || job.seq < h.jobs[earliest].seq {
+			earliest = jobID
+		}
+	}
+	delete(h.jobs, earliest)
+}
+
+func (h *repositoryHints) remove(jobID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.jobs, jobID)
+}
+
+// value returns the repositories of the pending jobs, the repositories with the most jobs first,
+// and whether it differs from the last value sent.
+func (h *repositoryHints) value() (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, job := range h.jobs {
+		counts[job.repository]++
+	}
+
+	repositories := make([]string, 0, len(counts))
+	for repository := range counts {
+		repositories = append(repositories, repository)
+	}
+	slices.SortFunc(repositories, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	if len(repositories) > maxRepositoryHints {
+		repositories = repositories[:maxRepositoryHints]
+	}
+
+	value := strings.Join(repositories, ",")
+	return value, h.sent == nil || *h.sent != value
+}
+
+func (h *repositoryHints) markSent(value string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sent = &value
+}
+
+// withRepositoryHints adds the repository hints annotation of the ephemeral runner set to the merge patch.
+// Empty hints remove the annotation.
+func withRepositoryHints(mergePatch []byte, hints string) ([]byte, error) {
+	var patch map[string]any
+	if err := json.Unmarshal(mergePatch, &patch); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal merge patch: %w", err)
+	}
+
+	var value any
+	if hints != "" {
+		value = hints
+	}
+	metadata, _ := patch["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = make(map[string]any)
+		patch["metadata"] = metadata
+	}
+	annotations, _ := metadata["annotations"].(map[string]any)
+	if annotations == nil {
+		annotations = make(map[string]any)
+		metadata["annotations"] = annotations
+	}
+	annotations[v1alpha1.EphemeralRunnerSetRepositoryHintsAnnotationKey] = value
+
+	return json.Marshal(patch)
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryHints(t *testing.T) {
+	var h repositoryHints
+	h.assign("1", "octo-org/api")
+	h.assign("2", "octo-org/web")
+	h.assign("3", "octo-org/web")
+
+	value, changed := h.value()
+	assert.Equal(t, "octo-org/web,octo-org/api", value, "the repositories with the most jobs come first")
+	assert.True(t, changed)
+
+	h.markSent(value)
+	_, changed = h.value()
+	assert.False(t, changed)
+
+	h.remove("2")
+	h.remove("3")
+	value, changed = h.value()
+	assert.Equal(t, "octo-org/api", value)
+	assert.True(t, changed)
+}
+
+func TestRepositoryHints_Bounded(t *testing.T) {
+	var h repositoryHints
+	h.assign("stale", "octo-org/stale")
+	for i := range maxHintedJobs {
+		h.assign(fmt.Sprint(i), "octo-org/api")
+	}
+
+	assert.Len(t, h.jobs, maxHintedJobs, "the jobs never started nor completed do not grow the hints for good")
+	assert.NotContains(t, h.jobs, "stale", "the job assigned the earliest is evicted")
+	value, _ := h.value()
+	assert.Equal(t, "octo-org/api", value)
+
+	h.assign("0", "octo-org/web")
+	assert.Len(t, h.jobs, maxHintedJobs, "a job assigned again is not added twice")
+}
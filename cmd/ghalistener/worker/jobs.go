@@ -0,0 +1,165 @@
+package worker
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/actions/actions-runner-controller/github/actions"
+)
+
+// maxListedJobs bounds the jobs tracked for Jobs, so that the jobs whose completion is never received,
+// e.g. since the listener restarted, do not grow the list for good. The earliest tracked job is evicted
+// to track a new one once the bound is reached.
+const maxListedJobs = 500
+
+// Job is a job assigned to the scale set which did not complete yet.
+type Job struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Repository string `json:"repository"`
+	// WorkflowRef is the reference of the workflow of the job, e.g. "octo-org/octo-repo/.github/workflows/ci.yml@refs/heads/main".
+	WorkflowRef string `json:"workflowRef"`
+	// Labels are the runner labels requested by the job.
+	Labels    []string  `json:"labels,omitempty"`
+	QueueTime time.Time `json:"queueTime"`
+	// RunnerName is the ephemeral runner running the job, empty while the job is queued.
+	RunnerName string `json:"runnerName,omitempty"`
+	// StartTime is the time the job was assigned to its runner, zero while the job is queued.
+	StartTime time.Time `json:"startTime,omitzero"`
+}
+
+// jobList tracks the jobs of the scale set from the job messages, from their assignment to their completion.
+type jobList struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+	// seqs orders the jobs by the time they were tracked.
+	seqs map[string]uint64
+	seq  uint64
+}
+
+func (l *jobList) assign(msg *actions.JobAssigned) {
+	if msg.JobID == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.jobs[msg.JobID]; ok {
+		return
+	}
+	l.track(newJob(&msg.JobMessageBase))
+}
+
+func (l *jobList) start(msg *actions.JobStarted) {
+	if msg.JobID == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	job, ok := l.jobs[msg.JobID]
+	if !ok {
+		// The jobs assigned before the listener started are only seen as started.
+		job = newJob(&msg.JobMessageBase)
+		l.track(job)
+	}
+	job.RunnerName = msg.RunnerName
+	job.StartTime = msg.RunnerAssignTime
+}
+
+func (l *jobList) complete(jobID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.jobs, jobID)
+	delete(l.seqs, jobID)
+}
+
+// track must be called with l.mu held.
+func (l *jobList) track(job *Job) {
+	if l.jobs == nil {
+		l.jobs = make(map[string]*Job)
+		l.seqs = make(map[string]uint64)
+	}
+	if len(l.jobs) >= maxListedJobs {
+		l.evictEarliest()
+	}
+	l.seq++
+	l.jobs[job.ID] = job
+	l.seqs[job.ID] = l.seq
+}
+
+// evictEarliest must be called with l.mu held.
+func (l *jobList) evictEarliest() {
+	var earliest string
+	for jobID := range l.jobs {
+		if earliest == "" || l.seqs[jobID] < l.seqs[earliest] {
+			earliest = jobID
+		}
+	}
+	delete(l.jobs, earliest)
+	delete(l.seqs, earliest)
+}
+
+// latest returns the n jobs tracked the latest, or all the jobs if fewer are tracked. The jobs whose completion
+// was not received are tracked the earliest, so the job count assigned to the scale set bounds the jobs counted
+// for the scale decisions.
+func (l *jobList) latest(n int) []*Job {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(l.jobs))
+	for _, job := range l.jobs {
+		jobs = append(jobs, job)
+	}
+	if len(jobs) <= n {
+		return jobs
+	}
+	slices.SortFunc(jobs, func(a, b *Job) int {
+		return cmp.Compare(l.seqs[b.ID], l.seqs[a.ID])
+	})
+	return jobs[:max(n, 0)]
+}
+
+func newJob(msg *actions.JobMessageBase) *Job {
+	return &Job{
+		ID:          msg.JobID,
+		Name:        msg.JobDisplayName,
+		Repository:  fmt.Sprintf("%s/%s", msg.OwnerName, msg.RepositoryName),
+		WorkflowRef: msg.JobWorkflowRef,
+		Labels:      slices.Clone(msg.RequestLabels),
+		QueueTime:   msg.QueueTime,
+	}
+}
+
+// Jobs returns the jobs assigned to the scale set which did not complete yet, queued the longest first.
+func (w *Worker) Jobs() []Job {
+	w.jobs.mu.Lock()
+	defer w.jobs.mu.Unlock()
+
+	jobs := make([]Job, 0, len(w.jobs.jobs))
+	for _, job := range w.jobs.jobs {
+		jobs = append(jobs, *job)
+	}
+	slices.SortFunc(jobs, func(a, b Job) int {
+		if c := a.QueueTime.Compare(b.QueueTime); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return jobs
+}
+
+// HandleJobCounts records the job counts of the last message, so that the scale decisions log how many
+// of the assigned jobs wait for a runner, apart from the jobs GitHub did not assign yet.
+func (w *Worker) HandleJobCounts(counts listener.JobCounts) {
+	w.mu.Lock()
+	w.jobCounts = counts
+	w.mu.Unlock()
+}
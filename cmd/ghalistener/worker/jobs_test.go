@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker_Jobs(t *testing.T) {
+	w, _ := newFakeClientWorker(t)
+	queueTime := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	base := func(id string, queued time.Duration) actions.JobMessageBase {
+		return actions.JobMessageBase{
+			JobID:          id,
+			JobDisplayName: "build",
+			OwnerName:      "owner",
+			RepositoryName: "repo",
+			QueueTime:      queueTime.Add(queued),
+		}
+	}
+
+	require.NoError(t, w.HandleJobAssigned(context.Background(), &actions.JobAssigned{JobMessageBase: base("2", time.Minute)}))
+	require.NoError(t, w.HandleJobAssigned(context.Background(), &actions.JobAssigned{JobMessageBase: base("1", 0)}))
+	require.NoError(t, w.HandleJobAssigned(context.Background(), &actions.JobAssigned{JobMessageBase: base("3", 2*time.Minute)}))
+
+	started := base("1", 0)
+	started.RunnerAssignTime = queueTime.Add(5 * time.Second)
+	require.NoError(t, w.HandleJobStarted(context.Background(), &actions.JobStarted{RunnerName: "runner", JobMessageBase: started}))
+	require.NoError(t, w.HandleJobCompleted(context.Background(), &actions.JobCompleted{JobMessageBase: base("3", 2*time.Minute)}))
+
+	assert.Equal(t, []Job{
+		{ID: "1", Name: "build", Repository: "owner/repo", QueueTime: queueTime, RunnerName: "runner", StartTime: queueTime.Add(5 * time.Second)},
+		{ID: "2", Name: "build", Repository: "owner/repo", QueueTime: queueTime.Add(time.Minute)},
+	}, w.Jobs(), "the jobs queued the longest come first, the completed jobs are removed")
+
+	require.NoError(t, w.HandleJobStarted(context.Background(), &actions.JobStarted{RunnerName: "other", JobMessageBase: base("4", 3*time.Minute)}))
+	assert.Len(t, w.Jobs(), 3, "the jobs assigned before the listener started are listed once started")
+}
+
+func TestWorker_JobsBound(t *testing.T) {
+	w, _ := newFakeClientWorker(t)
+	base := func(id int) actions.JobMessageBase {
+		return actions.JobMessageBase{JobID: strconv.Itoa(id), OwnerName: "owner", RepositoryName: "repo"}
+	}
+
+	for id := range maxListedJobs {
+		require.NoError(t, w.HandleJobAssigned(context.Background(), &actions.JobAssigned{JobMessageBase: base(id)}))
+	}
+	require.Len(t, w.Jobs(), maxListedJobs)
+
+	require.NoError(t, w.HandleJobAssigned(context.Background(), &actions.JobAssigned{JobMessageBase: base(maxListedJobs)}))
+	require.NoError(t, w.HandleJobStarted(context.Background(), &actions.JobStarted{RunnerName: "runner", JobMessageBase: base(maxListedJobs + 1)}))
+
+	ids := make(map[string]bool, maxListedJobs)
+	for _, job := range w.Jobs() {
+		ids[job.ID] = true
+	}
+	assert.Len(t, ids, maxListedJobs)
+	assert.False(t, ids["0"], "the earliest tracked job is evicted")
+	assert.False(t, ids["1"])
+	assert.True(t, ids[strconv.Itoa(maxListedJobs)], "the new jobs are tracked once the bound is reached")
+	assert.True(t, ids[strconv.Itoa(maxListedJobs+1)])
+}
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Migration gradually shifts the runners of the scale set from the ephemeral runner set of the config
+// to a target ephemeral runner set, e.g. one with a new runner image or node selector.
+// The share of the runners patched into the target grows linearly from zero at StartTime
+// to Percentage once Duration elapsed, and stays at Percentage afterwards.
+type Migration struct {
+	// TargetEphemeralRunnerSetName is the ephemeral runner set the runners shift to.
+	// It must be in the namespace of the ephemeral runner set of the config.
+	TargetEphemeralRunnerSetName string
+	// StartTime is the time at which the runners start to shift.
+	StartTime time.Time
+	// Duration is the time the ramp takes. Zero shifts to Percentage right at StartTime.
+	Duration time.Duration
+	// Percentage is the share of the runners in the target once the ramp completed, between 1 and 100.
+	Percentage int
+}
+
+func (m *Migration) validate(ephemeralRunnerSetName string) error {
+	if m.TargetEphemeralRunnerSetName == "" {
+		return errors.New("target ephemeral runner set name is empty")
+	}
+	if m.TargetEphemeralRunnerSetName == ephemeralRunnerSetName {
+		return fmt.Errorf("target ephemeral runner set %q must differ from the ephemeral runner set", m.TargetEphemeralRunnerSetName)
+	}
+	if m.Duration < 0 {
+		return fmt.Errorf("duration %s cannot be negative", m.Duration)
+	}
+	if m.Percentage < 1 || m.Percentage > 100 {
+		return fmt.Errorf("percentage %d must be between 1 and 100", m.Percentage)
+	}
+	return nil
+}
+
+// percentage returns the share of the runners to patch into the target now.
+func (m *Migration) percentage(now time.Time) int {
+	elapsed := now.Sub(m.StartTime)
+	switch {
+	case elapsed < 0:
+		return 0
+	case elapsed >= m.Duration:
+		return m.Percentage
+	}
+	return int(int64(m.Percentage) * int64(elapsed) / int64(m.Duration))
+}
+
+// splitReplicas splits the replicas between the source and the target ephemeral runner sets.
+// The target share is rounded down, so the source keeps the runners until the ramp covers a whole one.
+func splitReplicas(replicas, percentage int) (source, target int) {
+	target = replicas * percentage / 100
+	return replicas - target, target
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationPercentage(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := &Migration{
+		TargetEphemeralRunnerSetName: "target",
+		StartTime:                    start,
+		Duration:                     time.Hour,
+		Percentage:                   80,
+	}
+
+	assert.Equal(t, 0, m.percentage(start.Add(-time.Minute)), "before the start")
+	assert.Equal(t, 0, m.percentage(start))
+	assert.Equal(t, 40, m.percentage(start.Add(30*time.Minute)))
+	assert.Equal(t, 80, m.percentage(start.Add(time.Hour)))
+	assert.Equal(t, 80, m.percentage(start.Add(48*time.Hour)), "holds once the ramp completed")
+
+	m.Duration = 0
+	assert.Equal(t, 0, m.percentage(start.Add(-time.Second)))
+	assert.Equal(t, 80, m.percentage(start), "shifts right at the start without a ramp")
+}
+
+func TestSplitReplicas(t *testing.T) {
+	tests := []struct {
+		replicas, percentage int
+		source, target       int
+	}{
+		{replicas: 10, percentage: 0, source: 10, target: 0},
+		{replicas: 10, percentage: 25, source: 8, target: 2},
+		{replicas: 3, percentage: 30, source: 3, target: 0},
+		{replicas: 3, percentage: 100, source: 0, target: 3},
+		{replicas: 0, percentage: 50, source: 0, target: 0},
+	}
+	for _, tt := range tests {
+		source, target := splitReplicas(tt.replicas, tt.percentage)
+		assert.Equal(t, tt.source, source, "source of %d replicas at %d%%", tt.replicas, tt.percentage)
+		assert.Equal(t, tt.target, target, "target of %d replicas at %d%%", tt.replicas, tt.percentage)
+	}
+}
+
+func TestMigrationValidate(t *testing.T) {
+	valid := Migration{TargetEphemeralRunnerSetName: "target", Duration: time.Hour, Percentage: 100}
+	assert.NoError(t, valid.validate("set"))
+
+	tests := map[string]func(m *Migration){
+		"missing target":  func(m *Migration) { m.TargetEphemeralRunnerSetName = "" },
+		"same set":        func(m *Migration) { m.TargetEphemeralRunnerSetName = "set" },
+		"negative ramp":   func(m *Migration) { m.Duration = -time.Minute },
+		"zero percentage": func(m *Migration) { m.Percentage = 0 },
+		"over 100":        func(m *Migration) { m.Percentage = 101 },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := valid
+			mutate(&m)
+			assert.Error(t, m.validate("set"))
+		})
+	}
+}
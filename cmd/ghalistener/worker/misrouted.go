@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/actions/actions-runner-controller/github/actions"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reasonMisroutedJob is the reason of the event recorded on the ephemeral runner set
+// when a job requires labels the scale set does not provide.
+const reasonMisroutedJob = "MisroutedJob"
+
+var _ listener.MisroutedJobRecorder = (*Worker)(nil)
+
+// RecordMisroutedJob records a warning event on the ephemeral runner set with the workflow of the job,
+// so that the owners of the workflow can be pointed to the labels it requires.
+func (w *Worker) RecordMisroutedJob(ctx context.Context, job *actions.JobMessageBase, missingLabels []string) {
+	message := fmt.Sprintf("Job %q of the workflow %s of %s/%s requires the labels %q the scale set does not provide",
+		job.JobDisplayName,
+		job.JobWorkflowRef,
+		job.OwnerName,
+		job.RepositoryName,
+		strings.Join(missingLabels, ", "),
+	)
+	if err := w.recordEvent(ctx, corev1.EventTypeWarning, reasonMisroutedJob, message); err != nil {
+		w.logger.Error(err, "Failed to record the misrouted job event", "workflow", job.JobWorkflowRef)
+	}
+}
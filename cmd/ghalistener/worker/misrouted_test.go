@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRecordMisroutedJob(t *testing.T) {
+	w, client := newFakeClientWorker(t)
+
+	w.RecordMisroutedJob(context.Background(), &actions.JobMessageBase{
+		JobDisplayName: "build",
+		JobWorkflowRef: "org/repo/.github/workflows/ci.yaml@refs/heads/main",
+		OwnerName:      "org",
+		RepositoryName: "repo",
+	}, []string{"gpu", "arm64"})
+
+	require.Len(t, client.Actions(), 1)
+	create, ok := client.Actions()[0].(k8stesting.CreateAction)
+	require.True(t, ok)
+	event := create.GetObject().(*unstructured.Unstructured)
+	assert.Equal(t, reasonMisroutedJob, event.Object["reason"])
+	assert.Equal(t, "Warning", event.Object["type"])
+	assert.Equal(t, `Job "build" of the workflow org/repo/.github/workflows/ci.yaml@refs/heads/main of org/repo requires the labels "gpu, arm64" the scale set does not provide`, event.Object["message"])
+}
@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// MissingRunnerPolicy is how a started job is handled when its ephemeral runner is not found,
+// e.g. because the controller already deleted it or did not create it yet.
+type MissingRunnerPolicy string
+
+const (
+	// MissingRunnerSkip skips the patch of the job information of the runner.
+	MissingRunnerSkip MissingRunnerPolicy = "skip"
+	// MissingRunnerRetry retries the patch with missingRunnerBackoff, then skips it.
+	MissingRunnerRetry MissingRunnerPolicy = "retry"
+	// MissingRunnerFail fails the handling of the job started message, which stops the listener.
+	MissingRunnerFail MissingRunnerPolicy = "fail"
+)
+
+// missingRunnerBackoff is the backoff used to retry the patch of a missing ephemeral runner with MissingRunnerRetry,
+// giving the controller about a minute to create it. The handling of the job started message waits meanwhile.
+var missingRunnerBackoff = wait.Backoff{
+	Steps:    6,
+	Duration: time.Second,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+func (p MissingRunnerPolicy) validate() error {
+	switch p {
+	case "", MissingRunnerSkip, MissingRunnerRetry, MissingRunnerFail:
+		return nil
+	default:
+		return fmt.Errorf("unknown missing runner policy %q", p)
+	}
+}
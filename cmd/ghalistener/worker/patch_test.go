@@ -0,0 +1,551 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	metricsmocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func newFakeClientWorker(t *testing.T, objects ...runtime.Object) (*Worker, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	client := dynamicfake.NewSimpleDynamicClient(scheme, objects...)
+
+	logger := logr.Discard()
+	w := &Worker{
+		client: client,
+		config: Config{
+			EphemeralRunnerSetNamespace: "namespace",
+			EphemeralRunnerSetName:      "set",
+			MaxRunners:                  math.MaxInt32,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+	}
+	require.NoError(t, w.applyDefaults())
+
+	return w, client
+}
+
+func failPatchOnce(client *dynamicfake.FakeDynamicClient, resource string, err error) *int {
+	calls := 0
+	client.PrependReactor("patch", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		if calls == 1 {
+			return true, nil, err
+		}
+		return false, nil, nil
+	})
+	return &calls
+}
+
+func TestHandleDesiredRunnerCount_Patch(t *testing.T) {
+	set := func() *v1alpha1.EphemeralRunnerSet {
+		return &v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"},
+		}
+	}
+	groupResource := v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource).GroupResource()
+
+	t.Run("PatchesReplicas", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t, set())
+
+		replicas, err := w.HandleDesiredRunnerCount(context.Background(), 3, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, replicas)
+	})
+
+	t.Run("AnnotatesScaleReason", func(t *testing.T) {
+		w, client := newFakeClientWorker(t, set())
+		w.config.MaxRunners = 2
+		reason := func() string {
+			obj, err := client.
+				Resource(v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource)).
+				Namespace("namespace").
+				Get(context.Background(), "set", metav1.GetOptions{})
+			require.NoError(t, err)
+			return obj.GetAnnotations()[v1alpha1.EphemeralRunnerSetScaleReasonAnnotationKey]
+		}
+
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 3, 0)
+		require.NoError(t, err)
+		assert.Equal(t, ScaleReasonMaxRunners, reason())
+
+		_, err = w.HandleDesiredRunnerCount(context.Background(), 1, 2)
+		require.NoError(t, err)
+		assert.Equal(t, ScaleReasonAssignedJobs, reason())
+
+		_, err = w.HandleDesiredRunnerCount(context.Background(), 0, 1)
+		require.NoError(t, err)
+		assert.Equal(t, ScaleReasonMinRunners, reason())
+	})
+
+	t.Run("RetriesTransientError", func(t *testing.T) {
+		w, client := newFakeClientWorker(t, set())
+		calls := failPatchOnce(client, ephemeralRunnerSetsResource, kerrors.NewConflict(groupResource, "set", nil))
+		publisher := metricsmocks.NewPublisher(t)
+		publisher.On("PublishScalingPolicy", ScalingPolicyStrict, "", []string(nil)).Once()
+		publisher.On("PublishEphemeralRunnerSetPatchAttempt").Twice()
+		publisher.On("PublishEphemeralRunnerSetPatch", mock.Anything, nil).Once()
+		w.metrics = publisher
+
+		replicas, err := w.HandleDesiredRunnerCount(context.Background(), 2, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, replicas)
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("DoesNotRetryPermanentError", func(t *testing.T) {
+		w, client := newFakeClientWorker(t, set())
+		calls := failPatchOnce(client, ephemeralRunnerSetsResource, kerrors.NewForbidden(groupResource, "set", nil))
+		publisher := metricsmocks.NewPublisher(t)
+		publisher.On("PublishScalingPolicy", ScalingPolicyStrict, "", []string(nil)).Once()
+		publisher.On("PublishEphemeralRunnerSetPatchAttempt").Once()
+		publisher.On("PublishEphemeralRunnerSetPatch", mock.Anything, mock.MatchedBy(kerrors.IsForbidden)).Once()
+		w.metrics = publisher
+
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 2, 0)
+		require.Error(t, err)
+		assert.True(t, kerrors.IsForbidden(err))
+		assert.Equal(t, 1, *calls)
+	})
+}
+
+func TestHandleDesiredRunnerCount_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	w, _ := newFakeClientWorker(t, &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"},
+	})
+
+	_, err := w.HandleDesiredRunnerCount(context.Background(), 3, 0)
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	patch, handle := spans[0], spans[1]
+	assert.Equal(t, "worker.patch", patch.Name())
+	assert.Equal(t, "worker.HandleDesiredRunnerCount", handle.Name())
+	assert.Equal(t, handle.SpanContext().SpanID(), patch.Parent().SpanID())
+	assert.Contains(t, handle.Attributes(), attribute.Int("replicas", 3))
+	assert.Contains(t, patch.Attributes(), attribute.Int("attempts", 1))
+}
+
+func TestHandleDesiredRunnerCount_Concurrent(t *testing.T) {
+	w, _ := newFakeClientWorker(t, &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"},
+	})
+
+	// The fallback and the listener may scale concurrently, which the race detector checks.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 10 {
+			_, err := w.HandleDesiredRunnerCount(context.Background(), i, 0)
+			assert.NoError(t, err)
+		}
+	}()
+	for range 10 {
+		_ = w.State()
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 0, 0)
+		require.NoError(t, err)
+	}
+	<-done
+}
+
+func TestHandleDesiredRunnerCount_Migration(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w, client := newFakeClientWorker(t,
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"}},
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "target", Namespace: "namespace"}},
+	)
+	fakeClock := clocktesting.NewFakeClock(start.Add(30 * time.Minute))
+	w.clock = fakeClock
+	w.config.Migration = &Migration{
+		TargetEphemeralRunnerSetName: "target",
+		StartTime:                    start,
+		Duration:                     time.Hour,
+		Percentage:                   100,
+	}
+	replicas := func(name string) (int64, int64) {
+		obj, err := client.
+			Resource(v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource)).
+			Namespace("namespace").
+			Get(context.Background(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		replicas, _, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		require.NoError(t, err)
+		patchID, _, err := unstructured.NestedInt64(obj.Object, "spec", "patchID")
+		require.NoError(t, err)
+		return replicas, patchID
+	}
+
+	count, err := w.HandleDesiredRunnerCount(context.Background(), 4, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, count, "the total of the runners is returned")
+	sourceReplicas, sourcePatchID := replicas("set")
+	targetReplicas, targetPatchID := replicas("target")
+	assert.Equal(t, int64(2), sourceReplicas)
+	assert.Equal(t, int64(2), targetReplicas)
+	assert.Equal(t, sourcePatchID, targetPatchID, "both sets share the patch ID")
+
+	fakeClock.Step(time.Hour)
+	_, err = w.HandleDesiredRunnerCount(context.Background(), 4, 0)
+	require.NoError(t, err)
+	sourceReplicas, _ = replicas("set")
+	targetReplicas, _ = replicas("target")
+	assert.Equal(t, int64(0), sourceReplicas)
+	assert.Equal(t, int64(4), targetReplicas)
+
+	t.Run("keeps the runners when the target cannot be patched", func(t *testing.T) {
+		w, client := newFakeClientWorker(t,
+			&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"}},
+		)
+		w.config.Migration = &Migration{TargetEphemeralRunnerSetName: "target", Percentage: 100}
+		client.PrependReactor("patch", ephemeralRunnerSetsResource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.(k8stesting.PatchAction).GetName() == "target" {
+				return true, nil, kerrors.NewForbidden(v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource).GroupResource(), "target", errors.New("not allowed"))
+			}
+			return false, nil, nil
+		})
+
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 4, 0)
+		require.Error(t, err)
+		for _, action := range client.Actions() {
+			if patch, ok := action.(k8stesting.PatchAction); ok {
+				assert.Equal(t, "target", patch.GetName(), "the ephemeral runner set is not scaled down")
+			}
+		}
+	})
+}
+
+func TestHandleJobStarted_Patch(t *testing.T) {
+	t.Run("PatchesStatus", func(t *testing.T) {
+		runner := &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "namespace"},
+		}
+		w, client := newFakeClientWorker(t, runner)
+
+		err := w.HandleJobStarted(context.Background(), &actions.JobStarted{
+			RunnerName: "runner",
+			JobMessageBase: actions.JobMessageBase{
+				RunnerRequestID: 42,
+				JobID:           "job",
+			},
+		})
+		require.NoError(t, err)
+
+		require.NotEmpty(t, client.Actions())
+		patch, ok := client.Actions()[0].(k8stesting.PatchAction)
+		require.True(t, ok)
+		assert.Equal(t, "status", patch.GetSubresource())
+		assert.Equal(t, "runner", patch.GetName())
+	})
+
+	t.Run("IgnoresMissingRunner", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t)
+		publisher := metricsmocks.NewPublisher(t)
+		publisher.On("PublishEphemeralRunnerMiss", "skip").Once()
+		w.metrics = publisher
+
+		err := w.HandleJobStarted(context.Background(), &actions.JobStarted{RunnerName: "missing"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("RetriesMissingRunner", func(t *testing.T) {
+		backoff := missingRunnerBackoff
+		t.Cleanup(func() { missingRunnerBackoff = backoff })
+		missingRunnerBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond}
+
+		runner := &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "namespace"},
+		}
+		w, client := newFakeClientWorker(t, runner)
+		w.config.MissingRunnerPolicy = MissingRunnerRetry
+		misses := 0
+		client.PrependReactor("patch", ephemeralRunnersResource, func(k8stesting.Action) (bool, runtime.Object, error) {
+			if misses < 2 {
+				misses++
+				return true, nil, kerrors.NewNotFound(v1alpha1.GroupVersion.WithResource(ephemeralRunnersResource).GroupResource(), "runner")
+			}
+			return false, nil, nil
+		})
+
+		err := w.HandleJobStarted(context.Background(), &actions.JobStarted{RunnerName: "runner"})
+		require.NoError(t, err)
+		assert.Len(t, client.Actions(), 3, "the patch is retried until the runner is created")
+	})
+
+	t.Run("FailsOnMissingRunner", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t)
+		w.config.MissingRunnerPolicy = MissingRunnerFail
+		publisher := metricsmocks.NewPublisher(t)
+		publisher.On("PublishEphemeralRunnerMiss", "fail").Once()
+		w.metrics = publisher
+
+		err := w.HandleJobStarted(context.Background(), &actions.JobStarted{
+			RunnerName:     "missing",
+			JobMessageBase: actions.JobMessageBase{JobID: "job"},
+		})
+		code, ok := errcode.Of(err)
+		require.True(t, ok)
+		assert.Equal(t, errcode.EphemeralRunnerNotFound, code)
+		assert.ErrorContains(t, err, `ephemeral runner "missing" of job "job" not found`)
+	})
+}
+
+func TestNew_MissingRunnerPolicy(t *testing.T) {
+	_, err := New(Config{MissingRunnerPolicy: "ignore"}, WithClient(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())), WithLogger(logr.Discard()))
+	assert.ErrorContains(t, err, `unknown missing runner policy "ignore"`)
+}
+
+func TestHandleJobCompleted_AnnotatesStaleRunner(t *testing.T) {
+	runner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "namespace"},
+	}
+	w, client := newFakeClientWorker(t, runner)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	w.clock = fakeClock
+	w.config.StaleRunnerGracePeriod = time.Minute
+
+	err := w.HandleJobCompleted(context.Background(), &actions.JobCompleted{RunnerName: "runner"})
+	require.NoError(t, err)
+	assert.Empty(t, client.Actions(), "runner must not be annotated before the grace period elapses")
+
+	fakeClock.Step(time.Minute)
+	require.Eventually(t, func() bool {
+		obj, err := client.
+			Resource(v1alpha1.GroupVersion.WithResource(ephemeralRunnersResource)).
+			Namespace("namespace").
+			Get(context.Background(), "runner", metav1.GetOptions{})
+		return err == nil && obj.GetAnnotations()[v1alpha1.EphemeralRunnerGCPriorityAnnotationKey] == "true"
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("cancelled", func(t *testing.T) {
+		client.ClearActions()
+		ctx, cancel := context.WithCancel(context.Background())
+		require.NoError(t, w.HandleJobCompleted(ctx, &actions.JobCompleted{RunnerName: "runner"}))
+		assert.True(t, fakeClock.HasWaiters())
+
+		cancel()
+		require.Eventually(t, func() bool { return !fakeClock.HasWaiters() }, time.Second, 10*time.Millisecond,
+			"the check is stopped with the context of the listener")
+		fakeClock.Step(time.Minute)
+		assert.Never(t, func() bool { return len(client.Actions()) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	})
+}
+
+func TestHandleDesiredRunnerCount_RepositoryHints(t *testing.T) {
+	w, client := newFakeClientWorker(t, &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"},
+	})
+	hints := func() (string, bool) {
+		obj, err := client.
+			Resource(v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource)).
+			Namespace("namespace").
+			Get(context.Background(), "set", metav1.GetOptions{})
+		require.NoError(t, err)
+		value, ok := obj.GetAnnotations()[v1alpha1.EphemeralRunnerSetRepositoryHintsAnnotationKey]
+		return value, ok
+	}
+	assign := func(jobID, repository string) {
+		require.NoError(t, w.HandleJobAssigned(context.Background(), &actions.JobAssigned{
+			JobMessageBase: actions.JobMessageBase{JobID: jobID, OwnerName: "owner", RepositoryName: repository},
+		}))
+	}
+
+	assign("1", "b")
+	assign("2", "a")
+	assign("3", "b")
+	_, err := w.HandleDesiredRunnerCount(context.Background(), 3, 0)
+	require.NoError(t, err)
+	value, ok := hints()
+	require.True(t, ok)
+	assert.Equal(t, "owner/b,owner/a", value, "repositories with the most assigned jobs come first")
+
+	client.ClearActions()
+	_, err = w.HandleDesiredRunnerCount(context.Background(), 0, 0)
+	require.NoError(t, err)
+	patch := client.Actions()[0].(k8stesting.PatchAction)
+	assert.NotContains(t, string(patch.GetPatch()), v1alpha1.EphemeralRunnerSetRepositoryHintsAnnotationKey, "unchanged hints are not patched again")
+
+	require.NoError(t, w.HandleJobStarted(context.Background(), &actions.JobStarted{
+		RunnerName:     "runner",
+		JobMessageBase: actions.JobMessageBase{JobID: "2"},
+	}))
+	require.NoError(t, w.HandleJobCompleted(context.Background(), &actions.JobCompleted{
+		JobMessageBase: actions.JobMessageBase{JobID: "1"},
+	}))
+	_, err = w.HandleDesiredRunnerCount(context.Background(), 1, 1)
+	require.NoError(t, err)
+	value, _ = hints()
+	assert.Equal(t, "owner/b", value)
+
+	require.NoError(t, w.HandleJobStarted(context.Background(), &actions.JobStarted{
+		RunnerName:     "runner",
+		JobMessageBase: actions.JobMessageBase{JobID: "3"},
+	}))
+	_, err = w.HandleDesiredRunnerCount(context.Background(), 0, 1)
+	require.NoError(t, err)
+	_, ok = hints()
+	assert.False(t, ok, "hints are removed once all assigned jobs started")
+}
+
+func TestHandleSessionCreated_Annotation(t *testing.T) {
+	w, client := newFakeClientWorker(t, &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"},
+	})
+
+	sessionID := uuid.MustParse("6bcd8f05-8c4a-4c56-9d35-1d1a0aa4fb4f")
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, w.HandleSessionCreated(context.Background(), &actions.RunnerScaleSetSession{
+		SessionId: &sessionID,
+		OwnerName: "listener-pod",
+	}, createdAt))
+
+	obj, err := client.
+		Resource(v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource)).
+		Namespace("namespace").
+		Get(context.Background(), "set", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.JSONEq(t,
+		`{"id":"6bcd8f05-8c4a-4c56-9d35-1d1a0aa4fb4f","owner":"listener-pod","createdAt":"2024-01-02T03:04:05Z"}`,
+		obj.GetAnnotations()[v1alpha1.EphemeralRunnerSetMessageSessionAnnotationKey],
+	)
+}
+
+func TestSessionStore_RoundTrip(t *testing.T) {
+	w, _ := newFakeClientWorker(t, &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"},
+	})
+
+	state, err := w.LoadSession(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, state, "no session was recorded")
+
+	sessionID := uuid.New()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, w.HandleSessionCreated(context.Background(), &actions.RunnerScaleSetSession{SessionId: &sessionID}, createdAt))
+
+	state, err = w.LoadSession(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, sessionID, state.SessionID)
+	assert.Equal(t, createdAt, state.CreatedAt)
+	assert.Equal(t, int64(0), state.LastMessageID)
+
+	require.NoError(t, w.SaveLastMessageID(context.Background(), 42))
+	state, err = w.LoadSession(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), state.LastMessageID)
+}
+
+func TestBackfill(t *testing.T) {
+	set := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"},
+		Spec:       v1alpha1.EphemeralRunnerSetSpec{Replicas: 4},
+	}
+	runner := func(name, owner string, jobRequestID int64) *v1alpha1.EphemeralRunner {
+		controller := true
+		return &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "namespace",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "EphemeralRunnerSet", Name: owner, Controller: &controller},
+				},
+			},
+			Status: v1alpha1.EphemeralRunnerStatus{JobRequestId: jobRequestID},
+		}
+	}
+
+	t.Run("SeedsScalingState", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t,
+			set,
+			runner("busy-1", "set", 1),
+			runner("busy-2", "set", 2),
+			runner("idle", "set", 0),
+			runner("other", "other-set", 3),
+		)
+		w.config.MinRunners = 1
+		w.config.MaxScaleDownStep = 1
+
+		require.NoError(t, w.Backfill(context.Background()))
+		state := w.State()
+		assert.Equal(t, 4, state.LastPatch)
+		assert.Equal(t, 2, state.LastAssigned)
+
+		// The initial empty batch keeps the runners of the running jobs, limited by the scale down step.
+		replicas, err := w.HandleDesiredRunnerCount(context.Background(), 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, replicas)
+	})
+
+	t.Run("KeepsPatchedState", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t, set, runner("busy-1", "set", 1))
+
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, w.Backfill(context.Background()))
+		assert.Equal(t, 0, w.State().LastPatch)
+	})
+
+	t.Run("CountsMigrationTarget", func(t *testing.T) {
+		target := &v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "target", Namespace: "namespace"},
+			Spec:       v1alpha1.EphemeralRunnerSetSpec{Replicas: 2},
+		}
+		w, _ := newFakeClientWorker(t,
+			set,
+			target,
+			runner("busy-1", "set", 1),
+			runner("busy-2", "target", 2),
+			runner("other", "other-set", 3),
+		)
+		w.config.Migration = &Migration{TargetEphemeralRunnerSetName: "target", Percentage: 100}
+
+		require.NoError(t, w.Backfill(context.Background()))
+		state := w.State()
+		assert.Equal(t, 6, state.LastPatch)
+		assert.Equal(t, 2, state.LastAssigned)
+	})
+
+	t.Run("FailsWithoutEphemeralRunnerSet", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t)
+
+		err := w.Backfill(context.Background())
+		require.Error(t, err)
+		assert.True(t, kerrors.IsNotFound(err))
+		assert.Equal(t, -1, w.State().LastPatch)
+	})
+}
@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var podsResource = corev1.SchemeGroupVersion.WithResource("pods")
+
+// ephemeralRunnerPodSelector selects the pods of the ephemeral runners, which are named after their runner.
+const ephemeralRunnerPodSelector = "actions-ephemeral-runner=True"
+
+// PendingRunners reports the ephemeral runners stuck pending, e.g. since the cluster has no node or quota
+// left to schedule their pods, so that the scale set does not keep asking for replicas which are not scheduled.
+// A runner whose pod is scheduled but does not start, e.g. on an image pull failure, is not stuck pending,
+// since more nodes would not help.
+type PendingRunners struct {
+	// Threshold is the time after its creation an ephemeral runner still pending counts as stuck.
+	Threshold time.Duration
+	// Interval is the time between the lists of the ephemeral runners.
+	Interval time.Duration
+	// CapScaleUps holds the replicas at the last patch while ephemeral runners are stuck pending.
+	// The scale-downs still apply, and the scale-ups resume once the runners are scheduled or deleted.
+	CapScaleUps bool
+}
+
+func (p *PendingRunners) validate() error {
+	if p.Threshold <= 0 {
+		return errors.New("threshold must be positive")
+	}
+	if p.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	return nil
+}
+
+// WatchPendingRunners lists the ephemeral runners of the scale set right away and then every interval of the
+// pending runners, until the context is cancelled, and records the runners stuck pending. A failure to list
+// the runners is logged, and the last count is kept until the next list.
+func (w *Worker) WatchPendingRunners(ctx context.Context) error {
+	config := w.config.PendingRunners
+	if config == nil || w.target != nil {
+		return nil
+	}
+
+	for {
+		if err := w.checkPendingRunners(ctx); err != nil && ctx.Err() == nil {
+			w.logger.Error(err, "Failed to check the pending ephemeral runners")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.clock.After(config.Interval):
+		}
+	}
+}
+
+func (w *Worker) checkPendingRunners(ctx context.Context) error {
+	list, err := w.client.
+		Resource(w.resource(ephemeralRunnersResource)).
+		Namespace(w.config.EphemeralRunnerSetNamespace).
+		List(ctx, metav1.ListOptions{})
+	w.health.RecordKubernetesRequest(err == nil || !isTransientError(err))
+	if err != nil {
+		return fmt.Errorf("failed to list ephemeral runners: %w", err)
+	}
+	scheduled, err := w.scheduledRunnerPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := w.ephemeralRunnerSetNames()
+	now := w.now()
+	stuck := 0
+	stuckSets := make(map[string]int)
+	var oldest *v1alpha1.EphemeralRunner
+	for i := range list.Items {
+		ephemeralRunner := new(v1alpha1.EphemeralRunner)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].UnstructuredContent(), ephemeralRunner); err != nil {
+			return fmt.Errorf("failed to convert ephemeral runner %q: %w", list.Items[i].GetName(), err)
+		}
+		owner := metav1.GetControllerOf(ephemeralRunner)
+		if owner == nil || owner.Kind != "EphemeralRunnerSet" || !slices.Contains(names, owner.Name) {
+			continue
+		}
+		if scheduled[ephemeralRunner.Name] || !stuckPending(ephemeralRunner, now, w.config.PendingRunners.Threshold) {
+			continue
+		}
+		stuck++
+		stuckSets[owner.Name]++
+		if oldest == nil || ephemeralRunner.CreationTimestamp.Before(&oldest.CreationTimestamp) {
+			oldest = ephemeralRunner
+		}
+	}
+
+	w.mu.Lock()
+	previous := w.stuckPending
+	w.stuckPending = stuck
+	if w.config.TopologySpread != nil {
+		w.markUnavailableZones(stuckSets, now)
+	}
+	w.mu.Unlock()
+
+	w.metrics.PublishStuckPendingRunners(stuck)
+	switch {
+	case stuck > 0 && previous == 0:
+		w.logger.Info("Ephemeral runners are stuck pending, the cluster may be out of nodes or quota",
+			"count", stuck,
+			"oldest", oldest.Name,
+			"pendingFor", now.Sub(oldest.CreationTimestamp.Time).Round(time.Second).String(),
+			"capScaleUps", w.config.PendingRunners.CapScaleUps,
+		)
+	case stuck == 0 && previous > 0:
+		w.logger.Info("Ephemeral runners are no longer stuck pending")
+	}
+	return nil
+}
+
+// scheduledRunnerPods returns the names of the pods of the ephemeral runners which are scheduled to a node.
+func (w *Worker) scheduledRunnerPods(ctx context.Context) (map[string]bool, error) {
+	list, err := w.client.
+		Resource(podsResource).
+		Namespace(w.config.EphemeralRunnerSetNamespace).
+		List(ctx, metav1.ListOptions{LabelSelector: ephemeralRunnerPodSelector})
+	w.health.RecordKubernetesRequest(err == nil || !isTransientError(err))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ephemeral runner pods: %w", err)
+	}
+
+	scheduled := make(map[string]bool, len(list.Items))
+	for i := range list.Items {
+		pod := new(corev1.Pod)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].UnstructuredContent(), pod); err != nil {
+			return nil, fmt.Errorf("failed to convert pod %q: %w", list.Items[i].GetName(), err)
+		}
+		if podScheduled(pod) {
+			scheduled[pod.Name] = true
+		}
+	}
+	return scheduled, nil
+}
+
+// podScheduled reports whether the pod is bound to a node.
+func podScheduled(pod *corev1.Pod) bool {
+	if pod.Spec.NodeName != "" {
+		return true
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// stuckPending reports whether the ephemeral runner, which is not being deleted, is pending for longer than
+// the threshold since its creation. A runner whose pod was not created yet, e.g. on an exceeded quota, is
+// pending as well.
+func stuckPending(ephemeralRunner *v1alpha1.EphemeralRunner, now time.Time, threshold time.Duration) bool {
+	if !ephemeralRunner.DeletionTimestamp.IsZero() {
+		return false
+	}
+	if phase := ephemeralRunner.Status.Phase; phase != "" && phase != corev1.PodPending {
+		return false
+	}
+	return now.Sub(ephemeralRunner.CreationTimestamp.Time) > threshold
+}
+
+// capPendingScaleUp holds the target at the last patch while ephemeral runners are stuck pending, if configured.
+// With a TopologySpread, the scale-ups are only held once every zone is unavailable, the replicas of the
+// unavailable zones being redistributed to the others until then. It must be called with w.mu held.
+func (w *Worker) capPendingScaleUp(target, minRunners int) (int, bool) {
+	if w.config.PendingRunners == nil || !w.config.PendingRunners.CapScaleUps || w.stuckPending == 0 || w.lastPatch < 0 {
+		return target, false
+	}
+	if spread := w.config.TopologySpread; spread != nil && len(w.unavailableZones) < len(spread.Zones) {
+		return target, false
+	}
+	limit := max(w.lastPatch, minRunners)
+	if target <= limit {
+		return target, false
+	}
+	return limit, true
+}
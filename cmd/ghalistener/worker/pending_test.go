@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	metricsmocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestWorker_checkPendingRunners(t *testing.T) {
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	runner := func(name, owner string, age time.Duration, phase corev1.PodPhase) *v1alpha1.EphemeralRunner {
+		controller := true
+		return &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "namespace",
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "EphemeralRunnerSet", Name: owner, Controller: &controller},
+				},
+			},
+			Status: v1alpha1.EphemeralRunnerStatus{Phase: phase},
+		}
+	}
+
+	pod := func(name string, scheduled corev1.ConditionStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "namespace",
+				Labels:    map[string]string{"actions-ephemeral-runner": "True"},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodScheduled, Status: scheduled, Reason: corev1.PodReasonUnschedulable},
+				},
+			},
+		}
+	}
+
+	w, client := newFakeClientWorker(t,
+		runner("stuck", "set", 10*time.Minute, corev1.PodPending),
+		pod("stuck", corev1.ConditionFalse),
+		runner("image-pull", "set", 10*time.Minute, corev1.PodPending),
+		pod("image-pull", corev1.ConditionTrue),
+		runner("no-pod", "set", 6*time.Minute, ""),
+		runner("recent", "set", time.Minute, corev1.PodPending),
+		runner("running", "set", time.Hour, corev1.PodRunning),
+		runner("other", "other-set", time.Hour, corev1.PodPending),
+	)
+	w.clock = clocktesting.NewFakeClock(now)
+	w.config.PendingRunners = &PendingRunners{Threshold: 5 * time.Minute, Interval: 30 * time.Second}
+	publisher := metricsmocks.NewPublisher(t)
+	publisher.On("PublishStuckPendingRunners", 2).Once()
+	w.metrics = publisher
+
+	require.NoError(t, w.checkPendingRunners(context.Background()))
+	assert.Equal(t, 2, w.stuckPending, "the runners pending past the threshold count, unless their pod is scheduled")
+
+	require.NoError(t, client.Tracker().Delete(v1alpha1.GroupVersion.WithResource("ephemeralrunners"), "namespace", "stuck"))
+	require.NoError(t, client.Tracker().Delete(v1alpha1.GroupVersion.WithResource("ephemeralrunners"), "namespace", "no-pod"))
+	publisher.On("PublishStuckPendingRunners", 0).Once()
+	require.NoError(t, w.checkPendingRunners(context.Background()))
+	assert.Equal(t, 0, w.stuckPending)
+}
+
+func TestSetDesiredWorkerState_PendingRunners(t *testing.T) {
+	w, _ := newFakeClientWorker(t)
+	w.config.PendingRunners = &PendingRunners{Threshold: 5 * time.Minute, Interval: 30 * time.Second, CapScaleUps: true}
+
+	w.setDesiredWorkerState(3, 0)
+	assert.Equal(t, 3, w.lastPatch)
+
+	w.stuckPending = 1
+	w.setDesiredWorkerState(5, 0)
+	assert.Equal(t, 3, w.lastPatch, "the scale-ups are held while runners are stuck pending")
+	assert.Equal(t, []string{ClampPendingRunners}, w.lastDecision().Clamps)
+
+	w.setDesiredWorkerState(2, 0)
+	assert.Equal(t, 2, w.lastPatch, "the scale-downs still apply")
+	assert.Empty(t, w.lastDecision().Clamps)
+
+	w.config.PendingRunners.CapScaleUps = false
+	w.setDesiredWorkerState(5, 0)
+	assert.Equal(t, 5, w.lastPatch, "the stuck runners are only reported unless the scale-ups are capped")
+}
@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// JobPolicy adjusts the runners the jobs it matches count for toward the desired runner count,
+// e.g. so that the jobs of a heavy workflow count double, or the jobs of a repository are capped.
+// A job is matched by the first policy of the list whose selectors all match it.
+type JobPolicy struct {
+	// Name identifies the policy in the logs. Defaults to its index in the list.
+	Name string
+	// Repository is the path.Match pattern the "owner/repo" of the job matches, e.g. "octo-org/*".
+	// Empty matches every repository.
+	Repository string
+	// Workflow is the path.Match pattern the workflow of the job matches, without its git ref,
+	// e.g. "octo-org/octo-repo/.github/workflows/release.yml". Empty matches every workflow.
+	Workflow string
+	// Labels are the labels the job must all request, compared case-insensitively.
+	Labels []string
+	// Weight is the runners counted for every job matched. Defaults to 1, zero does not count the jobs.
+	Weight *int
+	// MaxRunners caps the runners counted for all the jobs matched, if set. It clamps the desired runner count,
+	// it does not limit the jobs matched running at once on the runners provisioned for other jobs.
+	MaxRunners *int
+	// Priority counts the jobs matched toward the PriorityRunners of the config, see holdPriorityRunners.
+	Priority bool
+}
+
+func (p *JobPolicy) validate() error {
+	for _, pattern := range []string{p.Repository, p.Workflow} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if p.Weight != nil && *p.Weight < 0 {
+		return errors.New("weight cannot be negative")
+	}
+	if p.MaxRunners != nil && *p.MaxRunners < 0 {
+		return errors.New("max runners cannot be negative")
+	}
+	return nil
+}
+
+func (p *JobPolicy) matches(job *Job) bool {
+	if p.Repository != "" {
+		if ok, _ := path.Match(p.Repository, job.Repository); !ok {
+			return false
+		}
+	}
+	if p.Workflow != "" {
+		workflow, _, _ := strings.Cut(job.WorkflowRef, "@")
+		if ok, _ := path.Match(p.Workflow, workflow); !ok {
+			return false
+		}
+	}
+	for _, label := range p.Labels {
+		if !containsFold(job.Labels, label) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *JobPolicy) weight() int {
+	if p.Weight == nil {
+		return 1
+	}
+	return *p.Weight
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// jobWeights are the runners the assigned jobs count for.
+type jobWeights struct {
+	// total is the assigned job count weighted by the job policies.
+	total int
+	// priority is the part of total counted for the jobs of the priority job policies.
+	priority int
+	// capped is set when the max runners of a policy capped the runners counted for its jobs.
+	capped bool
+}
+
+// weighJobs returns the assigned job count weighted by the job policies. The jobs tracked from the job
+// messages count the runners of the policy they match, and the others one runner each, e.g. the jobs
+// assigned before the listener started. At most the assigned job count of the tracked jobs is counted.
+func (w *Worker) weighJobs(assigned int) jobWeights {
+	policies := w.config.JobPolicies
+	if len(policies) == 0 {
+		return jobWeights{total: assigned}
+	}
+
+	matched := make([]int, len(policies))
+	for _, job := range w.jobs.latest(assigned) {
+		for i := range policies {
+			if policies[i].matches(job) {
+				matched[i]++
+				break
+			}
+		}
+	}
+
+	weights := jobWeights{total: assigned}
+	for i := range policies {
+		if matched[i] == 0 {
+			continue
+		}
+		runners := matched[i] * policies[i].weight()
+		if limit := policies[i].MaxRunners; limit != nil && runners > *limit {
+			runners = *limit
+			weights.capped = true
+			w.logger.V(1).Info("Job policy caps the runners of its jobs", "policy", jobPolicyName(policies, i), "jobs", matched[i], "maxRunners", *limit)
+		}
+		weights.total += runners - matched[i]
+		if policies[i].Priority {
+			weights.priority += runners
+		}
+	}
+	// The completion of tracked jobs may be seen before the assigned job count drops.
+	weights.total = max(weights.total, 0)
+	weights.priority = min(weights.priority, weights.total)
+	return weights
+}
+
+// holdPriorityRunners caps the runners counted for the jobs which are not of a priority job policy at the max runners
+// less the priority runners, so that they alone do not scale the set up to its max runners. It only clamps the count:
+// the runners are not reserved, any job can be assigned to them. It returns the weighted job count, and whether it
+// was capped.
+func (w *Worker) holdPriorityRunners(weights jobWeights, minRunners, maxRunners int) (int, bool) {
+	if w.config.PriorityRunners == 0 {
+		return weights.total, false
+	}
+	limit := max(maxRunners-w.config.PriorityRunners-minRunners, 0)
+	others := weights.total - weights.priority
+	if others <= limit {
+		return weights.total, false
+	}
+	return limit + weights.priority, true
+}
+
+func jobPolicyName(policies []JobPolicy, i int) string {
+	if policies[i].Name != "" {
+		return policies[i].Name
+	}
+	return strconv.Itoa(i)
+}
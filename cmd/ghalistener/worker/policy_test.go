@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+)
+
+func TestJobPolicy_Matches(t *testing.T) {
+	job := &Job{
+		Repository:  "octo-org/octo-repo",
+		WorkflowRef: "octo-org/octo-repo/.github/workflows/release.yml@refs/heads/main",
+		Labels:      []string{"self-hosted", "GPU"},
+	}
+
+	assert.True(t, (&JobPolicy{}).matches(job), "a policy without selectors matches every job")
+	assert.True(t, (&JobPolicy{Repository: "octo-org/*"}).matches(job))
+	assert.False(t, (&JobPolicy{Repository: "other-org/*"}).matches(job))
+	assert.True(t, (&JobPolicy{Workflow: "octo-org/octo-repo/.github/workflows/release.yml"}).matches(job), "the git ref is not matched")
+	assert.False(t, (&JobPolicy{Workflow: "*/*/.github/workflows/ci.yml"}).matches(job))
+	assert.True(t, (&JobPolicy{Labels: []string{"gpu"}}).matches(job), "the labels are compared case-insensitively")
+	assert.False(t, (&JobPolicy{Labels: []string{"gpu", "arm64"}}).matches(job), "the job must request all the labels")
+	assert.False(t, (&JobPolicy{Repository: "octo-org/*", Labels: []string{"arm64"}}).matches(job), "all the selectors must match")
+}
+
+func TestJobPolicy_Validate(t *testing.T) {
+	assert.NoError(t, (&JobPolicy{Repository: "octo-org/*", Weight: ptr.To(0), MaxRunners: ptr.To(0)}).validate())
+	assert.ErrorContains(t, (&JobPolicy{Workflow: "["}).validate(), `invalid pattern "["`)
+	assert.ErrorContains(t, (&JobPolicy{Weight: ptr.To(-1)}).validate(), "weight cannot be negative")
+	assert.ErrorContains(t, (&JobPolicy{MaxRunners: ptr.To(-1)}).validate(), "max runners cannot be negative")
+}
+
+func TestWorker_WeighJobs(t *testing.T) {
+	logger := logr.Discard()
+	w := &Worker{
+		config: Config{
+			JobPolicies: []JobPolicy{
+				{Name: "gpu", Labels: []string{"gpu"}, Weight: ptr.To(2), MaxRunners: ptr.To(3)},
+				{Repository: "octo-org/docs", Weight: ptr.To(0)},
+			},
+		},
+		logger: &logger,
+	}
+	w.jobs.jobs = map[string]*Job{
+		"1": {ID: "1", Repository: "octo-org/app", Labels: []string{"gpu"}},
+		"2": {ID: "2", Repository: "octo-org/docs", Labels: []string{"gpu"}},
+		"3": {ID: "3", Repository: "octo-org/docs"},
+		"4": {ID: "4", Repository: "octo-org/app"},
+	}
+
+	weights := w.weighJobs(5)
+	assert.Equal(t, 5-2-1+3, weights.total, "the gpu jobs count 3 runners at most, the docs job none, the others one each")
+	assert.True(t, weights.capped)
+
+	delete(w.jobs.jobs, "2")
+	weights = w.weighJobs(4)
+	assert.Equal(t, 4, weights.total, "the job matches the first policy only")
+	assert.False(t, weights.capped)
+
+	w.config.JobPolicies[0].Priority = true
+	w.jobs.jobs["5"] = &Job{ID: "5", Repository: "octo-org/docs", Labels: []string{"gpu"}}
+	weights = w.weighJobs(5)
+	assert.Equal(t, jobWeights{total: 5, priority: 3, capped: true}, weights, "the runners of the gpu jobs are counted as priority")
+
+	w.config.JobPolicies = nil
+	weights = w.weighJobs(4)
+	assert.Equal(t, jobWeights{total: 4}, weights, "the jobs count one runner each without policies")
+}
+
+func TestWorker_WeighJobsTracked(t *testing.T) {
+	logger := logr.Discard()
+	w := &Worker{
+		config: Config{
+			JobPolicies: []JobPolicy{{Labels: []string{"gpu"}, Weight: ptr.To(2)}},
+		},
+		logger: &logger,
+	}
+	assign := func(id int, labels ...string) {
+		w.jobs.assign(&actions.JobAssigned{JobMessageBase: actions.JobMessageBase{JobID: strconv.Itoa(id), RequestLabels: labels}})
+	}
+
+	// The completion of the gpu jobs was lost, e.g. while the listener was disconnected.
+	assign(1, "gpu")
+	assign(2, "gpu")
+	assign(3)
+	assert.Equal(t, 1, w.weighJobs(1).total, "only the latest tracked jobs are counted for the assigned jobs")
+	assert.Equal(t, 2+2+1, w.weighJobs(3).total)
+
+	w.jobs = jobList{}
+	for id := range maxListedJobs + 10 {
+		assign(id, "gpu")
+	}
+	assert.Equal(t, maxListedJobs*2+10, w.weighJobs(maxListedJobs+10).total, "the jobs evicted above the bound count one runner each")
+	assert.Equal(t, maxListedJobs*2, w.weighJobs(maxListedJobs).total)
+}
+
+func TestSetDesiredWorkerState_JobPolicies(t *testing.T) {
+	logger := logr.Discard()
+	w := &Worker{
+		config: Config{
+			MaxRunners:  math.MaxInt32,
+			JobPolicies: []JobPolicy{{Workflow: "*/*/.github/workflows/heavy.yml", Weight: ptr.To(3), MaxRunners: ptr.To(4)}},
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+	}
+	w.jobs.jobs = map[string]*Job{
+		"1": {ID: "1", WorkflowRef: "octo-org/octo-repo/.github/workflows/heavy.yml@refs/heads/main"},
+		"2": {ID: "2", WorkflowRef: "octo-org/octo-repo/.github/workflows/heavy.yml@refs/heads/main"},
+	}
+
+	w.setDesiredWorkerState(3, 0)
+	assert.Equal(t, 5, w.lastPatch, "the heavy jobs count 4 runners, the other job one")
+	assert.Equal(t, 5, w.lastDecision().Weighted)
+	assert.Contains(t, w.lastDecision().Clamps, ClampJobPolicy)
+}
+
+func TestSetDesiredWorkerState_PriorityRunners(t *testing.T) {
+	logger := logr.Discard()
+	w := &Worker{
+		config: Config{
+			MinRunners:      1,
+			MaxRunners:      10,
+			JobPolicies:     []JobPolicy{{Repository: "octo-org/deploy", Priority: true}},
+			PriorityRunners: 3,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+	}
+
+	w.setDesiredWorkerState(20, 0)
+	assert.Equal(t, 7, w.lastPatch, "the other jobs leave the priority runners of the max runners")
+	assert.Equal(t, []string{ClampPriorityRunners}, w.lastDecision().Clamps)
+
+	w.jobs.jobs = map[string]*Job{
+		"1": {ID: "1", Repository: "octo-org/deploy"},
+		"2": {ID: "2", Repository: "octo-org/deploy"},
+	}
+	w.setDesiredWorkerState(20, 0)
+	assert.Equal(t, 9, w.lastPatch, "the priority jobs use the priority runners")
+
+	w.setDesiredWorkerState(4, 0)
+	assert.Equal(t, 5, w.lastPatch, "the other jobs are not capped below the priority runners")
+	assert.Empty(t, w.lastDecision().Clamps)
+}
@@ -0,0 +1,23 @@
+package worker
+
+import "time"
+
+// Predictor forecasts the demand of the scale set from the demand it recorded.
+type Predictor interface {
+	// Predict records the assigned job count at the time and returns the job count forecast to be assigned shortly.
+	Predict(now time.Time, assigned int) int
+}
+
+// WithPredictor sets the predictor the worker provisions runners ahead of the forecast demand with.
+func WithPredictor(predictor Predictor) Option {
+	return func(w *Worker) {
+		w.predictor = predictor
+	}
+}
+
+func (w *Worker) predict(assigned int) int {
+	if w.predictor == nil {
+		return 0
+	}
+	return w.predictor.Predict(w.now(), assigned)
+}
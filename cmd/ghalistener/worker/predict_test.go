@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePredictor forecasts a fixed job count and records the assigned job counts.
+type fakePredictor struct {
+	forecast int
+	assigned []int
+}
+
+func (p *fakePredictor) Predict(_ time.Time, assigned int) int {
+	p.assigned = append(p.assigned, assigned)
+	return p.forecast
+}
+
+func TestSetDesiredWorkerState_Predictor(t *testing.T) {
+	logger := logr.Discard()
+	predictor := &fakePredictor{forecast: 4}
+	w := &Worker{
+		config: Config{
+			MinRunners: 1,
+			MaxRunners: math.MaxInt32,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+		predictor: predictor,
+	}
+
+	w.setDesiredWorkerState(2, 0)
+	assert.Equal(t, 5, w.lastPatch, "runners are provisioned for the forecast jobs")
+	assert.Equal(t, ScaleReasonPredicted, w.lastDecision().Reason)
+	assert.Equal(t, 4, w.lastDecision().Predicted)
+
+	w.setDesiredWorkerState(6, 0)
+	assert.Equal(t, 7, w.lastPatch, "runners are provisioned for the assigned jobs above the forecast")
+	assert.Equal(t, ScaleReasonAssignedJobs, w.lastDecision().Reason)
+
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, []int{2, 6, 6}, predictor.assigned, "empty batches record the assigned jobs of the last batch")
+
+	predictor.forecast = 0
+	w.setDesiredWorkerState(0, 6)
+	assert.Equal(t, 1, w.lastPatch)
+}
@@ -0,0 +1,42 @@
+package worker
+
+import "time"
+
+// reservation is a number of runners kept available until a time, e.g. ahead of a planned release.
+type reservation struct {
+	runners int
+	until   time.Time
+}
+
+// Reserve keeps the runners available in addition to the assigned jobs until the time, by raising
+// the min runners to the runners of all reservations. The reservation applies from the next patch.
+func (w *Worker) Reserve(runners int, until time.Time) {
+	w.mu.Lock()
+	w.reservations = append(w.reservations, reservation{runners: runners, until: until})
+	w.mu.Unlock()
+
+	w.logger.Info("Runners reserved", "runners", runners, "until", until)
+}
+
+// Reserved returns the runners of the reservations which did not expire yet.
+func (w *Worker) Reserved() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.reservedRunners(w.now())
+}
+
+// reservedRunners drops the expired reservations and returns the runners of the others.
+// It must be called with w.mu held.
+func (w *Worker) reservedRunners(now time.Time) int {
+	var runners int
+	active := w.reservations[:0]
+	for _, r := range w.reservations {
+		if !now.Before(r.until) {
+			continue
+		}
+		active = append(active, r)
+		runners += r.runners
+	}
+	w.reservations = active
+	return runners
+}
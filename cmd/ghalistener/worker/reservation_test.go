@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestReserve_RaisesMinRunnersUntilExpired(t *testing.T) {
+	logger := logr.Discard()
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	w := &Worker{
+		config: Config{
+			MinRunners: 1,
+			MaxRunners: 12,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+		clock:     fakeClock,
+	}
+
+	w.Reserve(5, fakeClock.Now().Add(30*time.Minute))
+	w.Reserve(3, fakeClock.Now().Add(time.Hour))
+	assert.Equal(t, 8, w.Reserved())
+
+	w.setDesiredWorkerState(2, 0)
+	assert.Equal(t, 10, w.lastPatch, "the assigned jobs are added to the reserved runners")
+
+	w.Reserve(20, fakeClock.Now().Add(time.Minute))
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 12, w.lastPatch, "the reserved runners are capped to the max runners")
+
+	fakeClock.Step(45 * time.Minute)
+	assert.Equal(t, 3, w.Reserved())
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 5, w.lastPatch)
+
+	fakeClock.Step(time.Hour)
+	assert.Zero(t, w.Reserved())
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 3, w.lastPatch, "the min runners apply again once the reservations expired")
+	assert.Empty(t, w.reservations)
+}
+
+func TestReserve_KeptWhileIdle(t *testing.T) {
+	logger := logr.Discard()
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	w := &Worker{
+		config: Config{
+			MinRunners:     2,
+			MaxRunners:     math.MaxInt32,
+			IdleTimeout:    time.Minute,
+			HardMinRunners: 0,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+		clock:     fakeClock,
+	}
+
+	w.setDesiredWorkerState(0, 0)
+	fakeClock.Step(2 * time.Minute)
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 0, w.lastPatch)
+
+	w.Reserve(4, fakeClock.Now().Add(time.Hour))
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 4, w.lastPatch)
+}
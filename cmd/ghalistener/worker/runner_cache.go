@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// RunnerCache caches the names of the ephemeral runners of the namespace, from a watch of their metadata only,
+// so that the worker does not send the patches of the runners which no longer exist to the API server.
+//
+// The watch lags behind the API server, e.g. the runners created when scaling from zero can be assigned a job
+// before they are seen, so the runners missing from the cache are looked up through the API server before they
+// are skipped. The runners deleted but not seen as deleted yet are patched as usual.
+type RunnerCache struct {
+	namespace string
+	informer  cache.SharedIndexInformer
+}
+
+func NewRunnerCache(client metadata.Interface, namespace string, resource schema.GroupVersionResource) *RunnerCache {
+	informer := metadatainformer.NewFilteredMetadataInformer(client, resource, namespace, 0, cache.Indexers{}, nil).Informer()
+	// Only the names are looked up, the rest of the metadata is dropped to keep the cache small.
+	_ = informer.SetTransform(func(obj any) (any, error) {
+		if m, ok := obj.(*metav1.PartialObjectMetadata); ok {
+			m.ManagedFields = nil
+			m.Annotations = nil
+			m.Labels = nil
+			m.OwnerReferences = nil
+		}
+		return obj, nil
+	})
+	return &RunnerCache{
+		namespace: namespace,
+		informer:  informer,
+	}
+}
+
+// WithRunnerCache sets the cache the ephemeral runners are looked up in before they are patched.
+func WithRunnerCache(runners *RunnerCache) Option {
+	return func(w *Worker) {
+		w.runners = runners
+	}
+}
+
+// Run watches the ephemeral runners until the context is cancelled.
+func (c *RunnerCache) Run(ctx context.Context) error {
+	c.informer.Run(ctx.Done())
+	return nil
+}
+
+// missing returns whether the ephemeral runner is known not to exist. It returns false until the cache is synced.
+// A nil RunnerCache knows no runner to be missing.
+func (c *RunnerCache) missing(name string) bool {
+	if c == nil || !c.informer.HasSynced() {
+		return false
+	}
+	_, exists, err := c.informer.GetStore().GetByKey(c.namespace + "/" + name)
+	return err == nil && !exists
+}
+
+// runnerMissing returns whether the ephemeral runner does not exist. A runner missing from the cache is only
+// missing if the API server does not find it either.
+func (w *Worker) runnerMissing(ctx context.Context, name string) bool {
+	if !w.runners.missing(name) {
+		return false
+	}
+	_, err := w.client.
+		Resource(w.resource(ephemeralRunnersResource)).
+		Namespace(w.config.EphemeralRunnerSetNamespace).
+		Get(ctx, name, metav1.GetOptions{})
+	return kerrors.IsNotFound(err)
+}
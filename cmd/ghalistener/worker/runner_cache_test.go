@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	metricsmocks "github.com/actions/actions-runner-controller/cmd/ghalistener/metrics/mocks"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// newSyncedRunnerCache returns a synced cache of the ephemeral runners of the namespace.
+func newSyncedRunnerCache(t *testing.T, names ...string) *RunnerCache {
+	t.Helper()
+
+	scheme := metadatafake.NewTestScheme()
+	require.NoError(t, metav1.AddMetaToScheme(scheme))
+	var objects []runtime.Object
+	for _, name := range names {
+		objects = append(objects, &metav1.PartialObjectMetadata{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "EphemeralRunner"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "namespace"},
+		})
+	}
+	client := metadatafake.NewSimpleMetadataClient(scheme, objects...)
+
+	runners := NewRunnerCache(client, "namespace", v1alpha1.GroupVersion.WithResource(ephemeralRunnersResource))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go runners.Run(ctx)
+	require.Eventually(t, runners.informer.HasSynced, time.Second, 10*time.Millisecond)
+	return runners
+}
+
+func TestRunnerCache_SkipsMissingRunners(t *testing.T) {
+	runner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "namespace"},
+	}
+	w, client := newFakeClientWorker(t, runner)
+	WithRunnerCache(newSyncedRunnerCache(t, "runner"))(w)
+	publisher := metricsmocks.NewPublisher(t)
+	publisher.On("PublishEphemeralRunnerMiss", "skip").Once()
+	w.metrics = publisher
+
+	require.NoError(t, w.HandleJobStarted(context.Background(), &actions.JobStarted{RunnerName: "missing"}))
+	require.Len(t, client.Actions(), 1, "the runners missing from the cache and from the API server are not patched")
+	assert.Equal(t, "get", client.Actions()[0].GetVerb())
+
+	client.ClearActions()
+	require.NoError(t, w.HandleJobStarted(context.Background(), &actions.JobStarted{RunnerName: "runner"}))
+	require.Len(t, client.Actions(), 1, "the runners of the cache are patched without being looked up")
+	assert.Equal(t, "runner", client.Actions()[0].(k8stesting.PatchAction).GetName())
+
+	client.ClearActions()
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	w.clock = fakeClock
+	w.config.StaleRunnerGracePeriod = time.Minute
+	require.NoError(t, w.HandleJobCompleted(context.Background(), &actions.JobCompleted{RunnerName: "missing"}))
+	fakeClock.Step(time.Minute)
+	assert.Never(t, func() bool {
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "patch" {
+				return true
+			}
+		}
+		return false
+	}, 100*time.Millisecond, 10*time.Millisecond, "the runners missing from the cache are not annotated")
+}
+
+func TestRunnerCache_ConfirmsMissingRunners(t *testing.T) {
+	// The runner was created after the cache synced, e.g. when scaling from zero.
+	runner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "namespace"},
+	}
+	w, client := newFakeClientWorker(t, runner)
+	WithRunnerCache(newSyncedRunnerCache(t))(w)
+
+	require.NoError(t, w.HandleJobStarted(context.Background(), &actions.JobStarted{RunnerName: "runner"}))
+	require.Len(t, client.Actions(), 2)
+	assert.Equal(t, "get", client.Actions()[0].GetVerb())
+	assert.Equal(t, "runner", client.Actions()[1].(k8stesting.PatchAction).GetName())
+}
+
+func TestRunnerCache_Unsynced(t *testing.T) {
+	var runners *RunnerCache
+	assert.False(t, runners.missing("runner"), "a nil cache knows no runner to be missing")
+
+	runners = NewRunnerCache(metadatafake.NewSimpleMetadataClient(metadatafake.NewTestScheme()), "namespace", v1alpha1.GroupVersion.WithResource(ephemeralRunnersResource))
+	assert.False(t, runners.missing("runner"), "an unsynced cache knows no runner to be missing")
+}
@@ -0,0 +1,207 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// ScheduledOverride overrides MinRunners and/or MaxRunners of the worker during a time window.
+// The window can optionally recur, in which case each occurrence starts at the wall clock time
+// of StartTime in the location of StartTime, so recurrences follow daylight saving time transitions.
+type ScheduledOverride struct {
+	// StartTime is the time at which the first window starts.
+	StartTime time.Time
+	// EndTime is the time at which the first window ends.
+	EndTime time.Time
+	// Frequency is the recurrence of the window: "Daily", "Weekly", "Monthly" or "Yearly".
+	// If empty, the window happens only once.
+	Frequency string
+	// UntilTime is the time after which the window no longer recurs. Zero means forever.
+	UntilTime time.Time
+	// MinRunners overrides Config.MinRunners while the window is active, if set.
+	MinRunners *int
+	// MaxRunners overrides Config.MaxRunners while the window is active, if set.
+	MaxRunners *int
+	// Name identifies the override in the scaling policy metric. Defaults to its index in the list.
+	Name string
+}
+
+func (o *ScheduledOverride) validate() error {
+	if !o.EndTime.After(o.StartTime) {
+		return fmt.Errorf("end time %s must be after start time %s", o.EndTime, o.StartTime)
+	}
+	if o.MinRunners != nil && *o.MinRunners < 0 {
+		return errors.New("min runners cannot be negative")
+	}
+	if o.MaxRunners != nil && *o.MaxRunners < 0 {
+		return errors.New("max runners cannot be negative")
+	}
+	if o.MinRunners != nil && o.MaxRunners != nil && *o.MinRunners > *o.MaxRunners {
+		return fmt.Errorf("min runners %d cannot be greater than max runners %d", *o.MinRunners, *o.MaxRunners)
+	}
+
+	freq, err := recurrenceFrequency(o.Frequency)
+	if err != nil {
+		return err
+	}
+	if freq == nil {
+		return nil
+	}
+
+	// The window must end before the next one begins, otherwise windows overlap.
+	start := o.StartTime
+	var next time.Time
+	switch *freq {
+	case rrule.DAILY:
+		next = start.AddDate(0, 0, 1)
+	case rrule.WEEKLY:
+		next = start.AddDate(0, 0, 7)
+	case rrule.MONTHLY:
+		next = start.AddDate(0, 1, 0)
+	case rrule.YEARLY:
+		next = start.AddDate(1, 0, 0)
+	}
+	if o.EndTime.Sub(start) > next.Sub(start) {
+		return fmt.Errorf("window duration %s must not exceed the recurrence period implied by frequency %q", o.EndTime.Sub(start), o.Frequency)
+	}
+
+	return nil
+}
+
+// isActive reports whether now falls into an occurrence of the window.
+func (o *ScheduledOverride) isActive(now time.Time) (bool, error) {
+	freq, err := recurrenceFrequency(o.Frequency)
+	if err != nil {
+		return false, err
+	}
+
+	if freq == nil {
+		return !now.Before(o.StartTime) && now.Before(o.EndTime), nil
+	}
+
+	rule, err := rrule.NewRRule(rrule.ROption{
+		Freq:    *freq,
+		Dtstart: o.StartTime,
+		Until:   o.UntilTime,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create recurrence rule: %w", err)
+	}
+
+	// An occurrence is active if it started within the window duration before now.
+	duration := o.EndTime.Sub(o.StartTime)
+	starts := rule.Between(now.Add(-duration+1), now, true)
+	return len(starts) > 0, nil
+}
+
+func recurrenceFrequency(frequency string) (*rrule.Frequency, error) {
+	var freq rrule.Frequency
+	switch frequency {
+	case "":
+		return nil, nil
+	case "Daily":
+		freq = rrule.DAILY
+	case "Weekly":
+		freq = rrule.WEEKLY
+	case "Monthly":
+		freq = rrule.MONTHLY
+	case "Yearly":
+		freq = rrule.YEARLY
+	default:
+		return nil, fmt.Errorf(`invalid frequency %q: it must be one of "Daily", "Weekly", "Monthly", and "Yearly"`, frequency)
+	}
+	return &freq, nil
+}
+
+// runnerBounds returns the min and max runners to apply now.
+// The first active scheduled override in the list takes precedence, and the min runners
+// are lowered to the hard min runners once the scale set was idle for the idle timeout,
+// and raised to the reserved runners while runners are reserved.
+func (w *Worker) runnerBounds() (minRunners, maxRunners int) {
+	b := w.scalingBounds()
+	return b.minRunners, b.maxRunners
+}
+
+// scalingBounds are the min and max runners to apply now, along with what they derive from.
+type scalingBounds struct {
+	minRunners, maxRunners int
+	// schedule is the name of the active scheduled override, if any.
+	schedule string
+	// idle is set when the min runners are lowered to the hard min runners.
+	idle bool
+	// budget is set when the scale-ups are capped at the min runners by the runner minutes budget.
+	budget bool
+	// fallback is set when the runners are scaled to the fallback replicas.
+	fallback bool
+	// reserved is set when the min runners are raised to the reserved runners.
+	reserved bool
+	// forced is set when the replicas are pinned by ForceScale.
+	forced bool
+	// jobPolicy is set when the runners counted for the jobs of a job policy are capped to its max runners.
+	jobPolicy bool
+	// priority is set when the runners counted for the other jobs are capped to leave the priority runners.
+	priority bool
+	// pending is set when the scale-ups are held at the last patch while ephemeral runners are stuck pending.
+	pending bool
+}
+
+func (w *Worker) scalingBounds() scalingBounds {
+	var b scalingBounds
+	b.minRunners, b.maxRunners, b.schedule = w.scheduledRunnerBounds(w.now())
+	warmMinRunners := w.warmPoolMinRunners(b.minRunners)
+	b.idle = warmMinRunners < b.minRunners
+	b.minRunners = warmMinRunners
+
+	// The reserved runners are kept even while the scale set is idle, they are reserved ahead of the jobs.
+	w.mu.Lock()
+	reserved := min(w.reservedRunners(w.now()), b.maxRunners)
+	w.mu.Unlock()
+	if reserved > b.minRunners {
+		b.minRunners = reserved
+		b.reserved = true
+	}
+	return b
+}
+
+// scheduledRunnerBounds returns the min and max runners of the first scheduled override active at the time,
+// or the configured ones if none is active.
+func (w *Worker) scheduledRunnerBounds(now time.Time) (minRunners, maxRunners int, schedule string) {
+	w.mu.Lock()
+	minRunners, maxRunners = w.config.MinRunners, w.config.MaxRunners
+	w.mu.Unlock()
+	if len(w.config.ScheduledOverrides) == 0 {
+		return minRunners, maxRunners, ""
+	}
+
+	for i := range w.config.ScheduledOverrides {
+		o := &w.config.ScheduledOverrides[i]
+		active, err := o.isActive(now)
+		if err != nil {
+			w.logger.Error(err, "Failed to evaluate scheduled override, ignoring it", "index", i)
+			continue
+		}
+		if !active {
+			continue
+		}
+
+		if o.MinRunners != nil {
+			minRunners = *o.MinRunners
+		}
+		if o.MaxRunners != nil {
+			maxRunners = *o.MaxRunners
+		}
+		minRunners = min(minRunners, maxRunners)
+
+		schedule = o.Name
+		if schedule == "" {
+			schedule = strconv.Itoa(i)
+		}
+		return minRunners, maxRunners, schedule
+	}
+
+	return minRunners, maxRunners, ""
+}
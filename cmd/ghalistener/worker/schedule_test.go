@@ -0,0 +1,153 @@
+package worker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestScheduledOverride_isActive(t *testing.T) {
+	mustParse := func(t *testing.T, v string) time.Time {
+		t.Helper()
+		ts, err := time.Parse(time.RFC3339, v)
+		require.NoError(t, err)
+		return ts
+	}
+
+	t.Run("one time window", func(t *testing.T) {
+		o := ScheduledOverride{
+			StartTime: mustParse(t, "2026-05-01T09:00:00Z"),
+			EndTime:   mustParse(t, "2026-05-01T17:00:00Z"),
+		}
+		require.NoError(t, o.validate())
+
+		for now, want := range map[string]bool{
+			"2026-05-01T08:59:59Z": false,
+			"2026-05-01T09:00:00Z": true,
+			"2026-05-01T16:59:59Z": true,
+			"2026-05-01T17:00:00Z": false,
+			"2026-05-02T10:00:00Z": false,
+		} {
+			active, err := o.isActive(mustParse(t, now))
+			require.NoError(t, err)
+			assert.Equal(t, want, active, now)
+		}
+	})
+
+	t.Run("daily window", func(t *testing.T) {
+		o := ScheduledOverride{
+			StartTime: mustParse(t, "2026-05-01T09:00:00Z"),
+			EndTime:   mustParse(t, "2026-05-01T17:00:00Z"),
+			Frequency: "Daily",
+			UntilTime: mustParse(t, "2026-05-10T00:00:00Z"),
+		}
+		require.NoError(t, o.validate())
+
+		for now, want := range map[string]bool{
+			"2026-04-30T10:00:00Z": false,
+			"2026-05-05T10:00:00Z": true,
+			"2026-05-05T18:00:00Z": false,
+			"2026-05-11T10:00:00Z": false,
+		} {
+			active, err := o.isActive(mustParse(t, now))
+			require.NoError(t, err)
+			assert.Equal(t, want, active, now)
+		}
+	})
+
+	t.Run("daily window follows daylight saving time", func(t *testing.T) {
+		loc, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+
+		// 09:00-17:00 local time, starting while EST (UTC-5) is in effect.
+		o := ScheduledOverride{
+			StartTime: time.Date(2026, 3, 1, 9, 0, 0, 0, loc),
+			EndTime:   time.Date(2026, 3, 1, 17, 0, 0, 0, loc),
+			Frequency: "Daily",
+		}
+		require.NoError(t, o.validate())
+
+		// DST starts on 2026-03-08, after which 09:00 local is 13:00 UTC instead of 14:00 UTC.
+		for now, want := range map[string]bool{
+			"2026-03-07T13:30:00Z": false,
+			"2026-03-07T14:30:00Z": true,
+			"2026-03-09T13:30:00Z": true,
+			"2026-03-09T21:30:00Z": false,
+		} {
+			active, err := o.isActive(mustParse(t, now))
+			require.NoError(t, err)
+			assert.Equal(t, want, active, now)
+		}
+	})
+}
+
+func TestScheduledOverride_validate(t *testing.T) {
+	start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	minRunners, maxRunners, negative := 3, 2, -1
+
+	invalid := map[string]ScheduledOverride{
+		"end before start":       {StartTime: start, EndTime: start.Add(-time.Hour)},
+		"unknown frequency":      {StartTime: start, EndTime: start.Add(time.Hour), Frequency: "Hourly"},
+		"longer than recurrence": {StartTime: start, EndTime: start.Add(25 * time.Hour), Frequency: "Daily"},
+		"min greater than max":   {StartTime: start, EndTime: start.Add(time.Hour), MinRunners: &minRunners, MaxRunners: &maxRunners},
+		"negative max runners":   {StartTime: start, EndTime: start.Add(time.Hour), MaxRunners: &negative},
+	}
+	for name, o := range invalid {
+		assert.Error(t, o.validate(), name)
+	}
+}
+
+func TestSetDesiredWorkerState_ScheduledOverrides(t *testing.T) {
+	logger := logr.Discard()
+	businessHoursStart := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	warm, zero := 5, 0
+
+	fakeClock := clocktesting.NewFakeClock(businessHoursStart.Add(time.Hour))
+	w := &Worker{
+		config: Config{
+			MinRunners: 1,
+			MaxRunners: math.MaxInt32,
+			ScheduledOverrides: []ScheduledOverride{
+				{
+					StartTime:  businessHoursStart,
+					EndTime:    businessHoursStart.Add(8 * time.Hour),
+					Frequency:  "Daily",
+					MinRunners: &warm,
+				},
+				{
+					StartTime:  businessHoursStart.Add(12 * time.Hour),
+					EndTime:    businessHoursStart.Add(22 * time.Hour),
+					Frequency:  "Daily",
+					MinRunners: &zero,
+					MaxRunners: &zero,
+					Name:       "night",
+				},
+			},
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+		clock:     fakeClock,
+	}
+
+	w.setDesiredWorkerState(2, 0)
+	assert.Equal(t, 7, w.lastPatch, "business hours keep warm runners")
+	assert.Equal(t, "0", w.lastDecision().Schedule, "an override without a name is identified by its index")
+
+	fakeClock.Step(8 * time.Hour)
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 3, w.lastPatch, "outside of overrides the configured min runners apply")
+	assert.Empty(t, w.lastDecision().Schedule)
+
+	fakeClock.Step(4 * time.Hour)
+	w.setDesiredWorkerState(0, 0)
+	patchID := w.lastDecision().PatchID
+	assert.Equal(t, 0, w.lastPatch, "night window scales to zero")
+	assert.Equal(t, 2, patchID)
+	assert.Equal(t, "night", w.lastDecision().Schedule)
+}
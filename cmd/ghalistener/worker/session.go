@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ listener.SessionStore = (*Worker)(nil)
+
+// messageSession is the value of the message session annotation of the ephemeral runner set.
+type messageSession struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// HandleSessionCreated records the message session created by the listener
+// in an annotation of the ephemeral runner set. It does nothing with a scale target.
+func (w *Worker) HandleSessionCreated(ctx context.Context, session *actions.RunnerScaleSetSession, createdAt time.Time) error {
+	if w.target != nil {
+		return nil
+	}
+
+	annotation := messageSession{
+		Owner:     session.OwnerName,
+		CreatedAt: createdAt.UTC(),
+	}
+	if session.SessionId != nil {
+		annotation.ID = session.SessionId.String()
+	}
+	value, err := json.Marshal(annotation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message session: %w", err)
+	}
+
+	mergePatch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{
+				v1alpha1.EphemeralRunnerSetMessageSessionAnnotationKey: string(value),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message session patch: %w", err)
+	}
+
+	if err := w.patch(ctx, ephemeralRunnerSetsResource, w.config.EphemeralRunnerSetName, mergePatch, nil); err != nil {
+		return errcode.Errorf(errcode.EphemeralRunnerSetPatch, "failed to patch the message session annotation of the ephemeral runner set: %w", err)
+	}
+
+	w.logger.Info("Recorded message session", "sessionId", annotation.ID, "owner", annotation.Owner)
+	return nil
+}
+
+// LoadSession returns the message session recorded in the annotations of the ephemeral runner set,
+// with the last message processed in it, or nil if no session was recorded.
+func (w *Worker) LoadSession(ctx context.Context) (*listener.SessionState, error) {
+	obj, err := w.client.
+		Resource(w.resource(ephemeralRunnerSetsResource)).
+		Namespace(w.config.EphemeralRunnerSetNamespace).
+		Get(ctx, w.config.EphemeralRunnerSetName, metav1.GetOptions{})
+	w.health.RecordKubernetesRequest(err == nil || !isTransientError(err))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ephemeral runner set: %w", err)
+	}
+
+	annotations := obj.GetAnnotations()
+	value, ok := annotations[v1alpha1.EphemeralRunnerSetMessageSessionAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	var annotation messageSession
+	if err := json.Unmarshal([]byte(value), &annotation); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal message session annotation: %w", err)
+	}
+	sessionID, err := uuid.Parse(annotation.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message session ID %q: %w", annotation.ID, err)
+	}
+
+	state := &listener.SessionState{
+		SessionID: sessionID,
+		CreatedAt: annotation.CreatedAt,
+	}
+	if value, ok := annotations[v1alpha1.EphemeralRunnerSetLastMessageIDAnnotationKey]; ok {
+		state.LastMessageID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse last message ID %q: %w", value, err)
+		}
+	}
+	return state, nil
+}
+
+// SaveLastMessageID records the ID of the last message processed in the message session
+// in an annotation of the ephemeral runner set.
+func (w *Worker) SaveLastMessageID(ctx context.Context, lastMessageID int64) error {
+	mergePatch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{
+				v1alpha1.EphemeralRunnerSetLastMessageIDAnnotationKey: strconv.FormatInt(lastMessageID, 10),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal last message ID patch: %w", err)
+	}
+
+	if err := w.patch(ctx, ephemeralRunnerSetsResource, w.config.EphemeralRunnerSetName, mergePatch, nil); err != nil {
+		return errcode.Errorf(errcode.EphemeralRunnerSetPatch, "failed to patch the last message ID annotation of the ephemeral runner set: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SnapshotVersion is the version of the Snapshot format written by the worker.
+const SnapshotVersion = 1
+
+// Snapshot is the durable scaling state of the worker, which outlives the listener process.
+// It is exported to restore the state in another listener of the scale set, e.g. one of a standby cluster,
+// the rest of the state being read from the cluster by Backfill.
+type Snapshot struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	// PatchSeq is the ID of the last patch of the ephemeral runner set.
+	PatchSeq int `json:"patchSeq"`
+	// Budget is the runner time of the day, if a runner minutes budget is configured.
+	Budget *BudgetSnapshot `json:"budget,omitempty"`
+	// Reservations are the reservations which did not expire yet.
+	Reservations []ReservationSnapshot `json:"reservations,omitempty"`
+	// SentPatch is the last scale decision patched, if the duplicate patches are suppressed.
+	SentPatch *SentPatchSnapshot `json:"sentPatch,omitempty"`
+}
+
+// SentPatchSnapshot is the last scale decision patched, against which the duplicates are suppressed.
+type SentPatchSnapshot struct {
+	Replicas []int     `json:"replicas"`
+	At       time.Time `json:"at"`
+}
+
+// BudgetSnapshot is the runner time of a UTC day.
+type BudgetSnapshot struct {
+	Day         time.Time `json:"day"`
+	UsedMinutes float64   `json:"usedMinutes"`
+}
+
+// ReservationSnapshot is a reservation of runners.
+type ReservationSnapshot struct {
+	Runners int       `json:"runners"`
+	Until   time.Time `json:"until"`
+}
+
+// Snapshot returns the durable scaling state of the worker.
+func (w *Worker) Snapshot() Snapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	s := Snapshot{
+		Version:    SnapshotVersion,
+		ExportedAt: now.UTC(),
+		PatchSeq:   w.patchSeq,
+	}
+	if w.config.MaxRunnerMinutesPerDay > 0 && !w.budget.day.IsZero() {
+		s.Budget = &BudgetSnapshot{
+			Day:         w.budget.day,
+			UsedMinutes: w.budget.used.Minutes(),
+		}
+	}
+	w.reservedRunners(now)
+	for _, r := range w.reservations {
+		s.Reservations = append(s.Reservations, ReservationSnapshot{Runners: r.runners, Until: r.until.UTC()})
+	}
+	if w.config.DuplicatePatchTTL > 0 && w.sent != nil {
+		s.SentPatch = &SentPatchSnapshot{Replicas: slices.Clone(w.sent.replicas), At: w.sent.at.UTC()}
+	}
+	return s
+}
+
+// Restore merges the snapshot into the scaling state of the worker. It never moves the state backwards:
+// the patch sequence is the highest of both, the runner time of the day is the highest of both, and a budget
+// of another day is ignored. The reservations which did not expire yet are added, unless already present.
+// The last patch sent is restored unless a later one was sent, so that its duplicates are still suppressed
+// until the DuplicatePatchTTL elapses.
+func (w *Worker) Restore(s Snapshot) error {
+	if s.Version != SnapshotVersion {
+		return fmt.Errorf("snapshot version %d is not supported, expected %d", s.Version, SnapshotVersion)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	w.patchSeq = max(w.patchSeq, s.PatchSeq)
+
+	if b := s.Budget; b != nil && w.config.MaxRunnerMinutesPerDay > 0 {
+		day := now.UTC().Truncate(24 * time.Hour)
+		if b.Day.Equal(day) {
+			if !w.budget.day.Equal(day) {
+				w.budget.day, w.budget.used = day, 0
+			}
+			w.budget.used = max(w.budget.used, time.Duration(b.UsedMinutes*float64(time.Minute)))
+			w.budget.exhausted = w.budget.used >= time.Duration(w.config.MaxRunnerMinutesPerDay)*time.Minute
+		}
+	}
+
+	for _, r := range s.Reservations {
+		if r.Runners <= 0 || !now.Before(r.Until) || w.hasReservation(r.Runners, r.Until) {
+			continue
+		}
+		w.reservations = append(w.reservations, reservation{runners: r.Runners, until: r.Until})
+	}
+
+	if p := s.SentPatch; p != nil && w.config.DuplicatePatchTTL > 0 && (w.sent == nil || p.At.After(w.sent.at)) {
+		w.sent = &sentPatch{replicas: slices.Clone(p.Replicas), at: p.At}
+	}
+
+	w.logger.Info("Restored the scaling state from a snapshot",
+		"exportedAt", s.ExportedAt,
+		"patchSeq", w.patchSeq,
+		"reservations", len(w.reservations),
+	)
+	return nil
+}
+
+// hasReservation must be called with w.mu held.
+func (w *Worker) hasReservation(runners int, until time.Time) bool {
+	for _, r := range w.reservations {
+		if r.runners == runners && r.until.Equal(until) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestSnapshot_Restore(t *testing.T) {
+	newWorker := func(fakeClock *clocktesting.FakeClock) *Worker {
+		logger := logr.Discard()
+		return &Worker{
+			config: Config{
+				MaxRunners:             math.MaxInt32,
+				MaxRunnerMinutesPerDay: 60,
+				DuplicatePatchTTL:      time.Hour,
+			},
+			lastPatch: -1,
+			patchSeq:  -1,
+			logger:    &logger,
+			clock:     fakeClock,
+		}
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	primaryClock := clocktesting.NewFakeClock(now)
+	primary := newWorker(primaryClock)
+	primary.Reserve(5, now.Add(time.Hour))
+	primary.Reserve(3, now.Add(time.Minute))
+	primary.setDesiredWorkerState(2, 0)
+	primary.markPatchSent([]int{2})
+	primaryClock.Step(10 * time.Minute)
+	primary.setDesiredWorkerState(2, 0)
+	// 10 runners ran for 10 minutes, as read from the ephemeral runner set.
+	primary.budget.used = 100 * time.Minute
+
+	snapshot := primary.Snapshot()
+	assert.Equal(t, Snapshot{
+		Version:    SnapshotVersion,
+		ExportedAt: now.Add(10 * time.Minute),
+		PatchSeq:   1,
+		Budget: &BudgetSnapshot{
+			Day:         now.Truncate(24 * time.Hour),
+			UsedMinutes: 100,
+		},
+		Reservations: []ReservationSnapshot{{Runners: 5, Until: now.Add(time.Hour)}},
+		SentPatch:    &SentPatchSnapshot{Replicas: []int{2}, At: now},
+	}, snapshot, "the expired reservations are left out")
+
+	t.Run("RestoresTheState", func(t *testing.T) {
+		standby := newWorker(clocktesting.NewFakeClock(now.Add(15 * time.Minute)))
+		require.NoError(t, standby.Restore(snapshot))
+
+		assert.Equal(t, 1, standby.patchSeq)
+		assert.Equal(t, 5, standby.Reserved())
+		assert.Equal(t, 100*time.Minute, standby.budget.used)
+		assert.True(t, standby.budget.exhausted)
+
+		assert.True(t, standby.duplicatePatch(0, []int{2}), "the duplicates of the last patch are still suppressed")
+		assert.False(t, standby.duplicatePatch(0, []int{3}))
+
+		require.NoError(t, standby.Restore(snapshot))
+		assert.Equal(t, 5, standby.Reserved(), "the reservations already restored are not added twice")
+	})
+
+	t.Run("NeverMovesBackwards", func(t *testing.T) {
+		standby := newWorker(clocktesting.NewFakeClock(now.Add(15 * time.Minute)))
+		standby.patchSeq = 10
+		standby.budget.day = now.Truncate(24 * time.Hour)
+		standby.budget.used = 200 * time.Minute
+		require.NoError(t, standby.Restore(snapshot))
+
+		standby.sent = &sentPatch{replicas: []int{4}, at: now.Add(12 * time.Minute)}
+		require.NoError(t, standby.Restore(snapshot))
+
+		assert.Equal(t, 10, standby.patchSeq)
+		assert.Equal(t, 200*time.Minute, standby.budget.used)
+		assert.Equal(t, []int{4}, standby.sent.replicas, "a later patch sent is kept")
+	})
+
+	t.Run("IgnoresTheBudgetOfAnotherDay", func(t *testing.T) {
+		standby := newWorker(clocktesting.NewFakeClock(now.Add(24 * time.Hour)))
+		require.NoError(t, standby.Restore(snapshot))
+
+		assert.Zero(t, standby.budget.used)
+		assert.False(t, standby.budget.exhausted)
+		assert.Zero(t, standby.Reserved(), "the reservations expired in the meantime")
+	})
+
+	t.Run("RejectsAnotherVersion", func(t *testing.T) {
+		standby := newWorker(clocktesting.NewFakeClock(now))
+		assert.Error(t, standby.Restore(Snapshot{Version: SnapshotVersion + 1, PatchSeq: 10}))
+		assert.Equal(t, -1, standby.patchSeq)
+	})
+}
@@ -0,0 +1,187 @@
+package worker
+
+import "time"
+
+// maxRecentDecisions is the number of scaling decisions kept for the state dump.
+const maxRecentDecisions = 20
+
+// The reasons of the scaling decisions, recorded in the status of the ephemeral runner set.
+const (
+	// ScaleReasonAssignedJobs is the reason of the replicas being the min runners plus the assigned jobs.
+	ScaleReasonAssignedJobs = "AssignedJobs"
+	// ScaleReasonMinRunners is the reason of the replicas being the min runners, since no job is assigned.
+	ScaleReasonMinRunners = "MinRunners"
+	// ScaleReasonMaxRunners is the reason of the replicas being capped to the max runners.
+	ScaleReasonMaxRunners = "MaxRunners"
+	// ScaleReasonScaleStepLimited is the reason of the replicas being limited by MaxScaleUpStep or MaxScaleDownStep.
+	ScaleReasonScaleStepLimited = "ScaleStepLimited"
+	// ScaleReasonPredicted is the reason of the replicas being the min runners plus the jobs forecast
+	// from the demand of the previous days, since more jobs are forecast than assigned.
+	ScaleReasonPredicted = "Predicted"
+	// ScaleReasonBudgetExhausted is the reason of the replicas being capped to the min runners,
+	// since the runner minutes budget of the day is exhausted.
+	ScaleReasonBudgetExhausted = "BudgetExhausted"
+	// ScaleReasonFallback is the reason of the replicas being the fallback replicas,
+	// since the message session could not be established or refreshed.
+	ScaleReasonFallback = "Fallback"
+	// ScaleReasonForced is the reason of the replicas being pinned by ForceScale.
+	ScaleReasonForced = "Forced"
+)
+
+// The scaling policies of the worker, exported by the scaling policy metric.
+const (
+	// ScalingPolicyStrict scales the runners to the min runners plus the assigned jobs.
+	ScalingPolicyStrict = "strict"
+	// ScalingPolicyPredictive also provisions runners ahead of the demand forecast by the predictor.
+	ScalingPolicyPredictive = "predictive"
+)
+
+// The clamps which limit the replicas of a scaling decision, exported by the scaling policy metric.
+const (
+	// ClampMaxRunners is set when the replicas are capped to the max runners.
+	ClampMaxRunners = "max-runners"
+	// ClampScaleStep is set when the replicas are limited by MaxScaleUpStep or MaxScaleDownStep.
+	ClampScaleStep = "scale-step"
+	// ClampIdleTimeout is set when the min runners are lowered to the hard min runners.
+	ClampIdleTimeout = "idle-timeout"
+	// ClampBudget is set when the scale-ups are capped at the min runners by the runner minutes budget.
+	ClampBudget = "budget"
+	// ClampJobPolicy is set when the runners counted for the jobs of a job policy are capped to its max runners.
+	ClampJobPolicy = "job-policy"
+	// ClampPriorityRunners is set when the runners counted for the jobs which are not of a priority job policy
+	// are capped to leave the priority runners to the priority jobs.
+	ClampPriorityRunners = "priority-runners"
+	// ClampPendingRunners is set when the scale-ups are held at the last patch while ephemeral runners are stuck pending.
+	ClampPendingRunners = "pending-runners"
+)
+
+// Decision is a scaling decision taken by the worker.
+type Decision struct {
+	Time          time.Time `json:"time"`
+	AssignedJobs  int       `json:"assignedJobs"`
+	JobsCompleted int       `json:"jobsCompleted"`
+	// Target is the runner count before the scale step limits are applied.
+	Target   int `json:"target"`
+	Replicas int `json:"replicas"`
+	PatchID  int `json:"patchId"`
+	// Predicted is the job count forecast by the predictor.
+	Predicted int `json:"predicted"`
+	// Weighted is the assigned job count weighted by the job policies, the runners the assigned jobs count for.
+	Weighted int `json:"weighted"`
+	// Reason is one of the ScaleReason constants.
+	Reason string `json:"reason"`
+	// Schedule is the name of the scheduled override in effect, if any.
+	Schedule string `json:"schedule,omitempty"`
+	// Clamps are the Clamp constants which limited the replicas.
+	Clamps []string `json:"clamps,omitempty"`
+}
+
+// scaleReason returns the reason of the replicas, given the target before the scale step limits.
+func scaleReason(b scalingBounds, assigned, predicted, target, replicas int) string {
+	demand := max(assigned, predicted)
+	switch {
+	case b.forced:
+		return ScaleReasonForced
+	case replicas != target:
+		return ScaleReasonScaleStepLimited
+	case b.budget && demand > 0:
+		return ScaleReasonBudgetExhausted
+	case b.fallback:
+		return ScaleReasonFallback
+	case b.minRunners+demand > b.maxRunners:
+		return ScaleReasonMaxRunners
+	case predicted > assigned:
+		return ScaleReasonPredicted
+	case assigned == 0:
+		return ScaleReasonMinRunners
+	default:
+		return ScaleReasonAssignedJobs
+	}
+}
+
+// scaleClamps returns the clamps which limited the replicas, given the target before the scale step limits.
+func scaleClamps(b scalingBounds, demand, target, replicas int) []string {
+	var clamps []string
+	if b.minRunners+demand > b.maxRunners {
+		clamps = append(clamps, ClampMaxRunners)
+	}
+	if replicas != target {
+		clamps = append(clamps, ClampScaleStep)
+	}
+	if b.idle {
+		clamps = append(clamps, ClampIdleTimeout)
+	}
+	if b.budget && demand > 0 {
+		clamps = append(clamps, ClampBudget)
+	}
+	if b.jobPolicy {
+		clamps = append(clamps, ClampJobPolicy)
+	}
+	if b.priority {
+		clamps = append(clamps, ClampPriorityRunners)
+	}
+	if b.pending {
+		clamps = append(clamps, ClampPendingRunners)
+	}
+	return clamps
+}
+
+// scalingPolicy returns the ScalingPolicy constant of the worker.
+func (w *Worker) scalingPolicy() string {
+	if w.predictor != nil {
+		return ScalingPolicyPredictive
+	}
+	return ScalingPolicyStrict
+}
+
+// State is a snapshot of the scaling state of the worker.
+type State struct {
+	MinRunners      int        `json:"minRunners"`
+	MaxRunners      int        `json:"maxRunners"`
+	LastPatch       int        `json:"lastPatch"`
+	PatchSeq        int        `json:"patchSeq"`
+	LastAssigned    int        `json:"lastAssigned"`
+	RecentDecisions []Decision `json:"recentDecisions"`
+}
+
+// State returns a snapshot of the scaling state, including the effective runner bounds
+// and the most recent scaling decisions, oldest first.
+func (w *Worker) State() State {
+	minRunners, maxRunners := w.runnerBounds()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return State{
+		MinRunners:      minRunners,
+		MaxRunners:      maxRunners,
+		LastPatch:       w.lastPatch,
+		PatchSeq:        w.patchSeq,
+		LastAssigned:    w.lastAssigned,
+		RecentDecisions: append([]Decision(nil), w.decisions...),
+	}
+}
+
+// lastDecision returns the most recent scaling decision.
+func (w *Worker) lastDecision() Decision {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.decisions[len(w.decisions)-1]
+}
+
+// recordDecision must be called with w.mu held.
+func (w *Worker) recordDecision(d Decision) {
+	if len(w.decisions) == maxRecentDecisions {
+		copy(w.decisions, w.decisions[1:])
+		w.decisions = w.decisions[:maxRecentDecisions-1]
+	}
+	w.decisions = append(w.decisions, d)
+}
+
+// now returns the current time of the clock, which is not set on workers built without New.
+func (w *Worker) now() time.Time {
+	if w.clock == nil {
+		return time.Now()
+	}
+	return w.clock.Now()
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker_State(t *testing.T) {
+	logger := logr.Discard()
+	w := &Worker{
+		config: Config{
+			MinRunners: 1,
+			MaxRunners: math.MaxInt32,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+	}
+	require.NoError(t, w.applyDefaults())
+
+	for i := range maxRecentDecisions + 5 {
+		w.setDesiredWorkerState(i+1, 0)
+	}
+
+	state := w.State()
+	assert.Equal(t, 1, state.MinRunners)
+	assert.Equal(t, math.MaxInt32, state.MaxRunners)
+	assert.Equal(t, maxRecentDecisions+5+1, state.LastPatch)
+	require.Len(t, state.RecentDecisions, maxRecentDecisions, "only the most recent decisions are kept")
+	assert.Equal(t, 6, state.RecentDecisions[0].AssignedJobs, "oldest decision comes first")
+
+	last := state.RecentDecisions[maxRecentDecisions-1]
+	assert.Equal(t, maxRecentDecisions+5, last.AssignedJobs)
+	assert.Equal(t, state.LastPatch, last.Replicas)
+	assert.Equal(t, state.PatchSeq, last.PatchID)
+}
+
+func TestScaleClamps(t *testing.T) {
+	bounds := scalingBounds{minRunners: 1, maxRunners: 5}
+	assert.Empty(t, scaleClamps(bounds, 2, 3, 3))
+	assert.Equal(t, []string{ClampMaxRunners}, scaleClamps(bounds, 6, 5, 5))
+	assert.Equal(t, []string{ClampMaxRunners, ClampScaleStep}, scaleClamps(bounds, 6, 5, 2))
+
+	bounds.idle = true
+	assert.Equal(t, []string{ClampIdleTimeout}, scaleClamps(bounds, 0, 1, 1))
+}
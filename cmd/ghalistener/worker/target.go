@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/webhook"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+// ScaleTarget applies the scaling decisions of the worker in place of the ephemeral runner set,
+// for users who want the demand signal of the listener without the EphemeralRunnerSet machinery.
+//
+// With a scale target, the worker does not touch the ephemeral runner set and its ephemeral runners:
+// repository hints, job info and stale runner patches, session annotations, and the backfill are skipped.
+type ScaleTarget interface {
+	// Scale applies the decision, whose Replicas is the runner count to scale to.
+	Scale(ctx context.Context, decision Decision) error
+}
+
+// WithScaleTarget sets the scale target the worker applies its decisions to instead of the ephemeral runner set.
+func WithScaleTarget(target ScaleTarget) Option {
+	return func(w *Worker) {
+		w.target = target
+	}
+}
+
+// ScaleSubresourceTarget patches the replicas of a Kubernetes resource through its scale subresource,
+// e.g. a Deployment or a StatefulSet of long-lived runners.
+type ScaleSubresourceTarget struct {
+	client    dynamic.Interface
+	resource  schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+var _ ScaleTarget = (*ScaleSubresourceTarget)(nil)
+
+func NewScaleSubresourceTarget(client dynamic.Interface, resource schema.GroupVersionResource, namespace, name string) *ScaleSubresourceTarget {
+	return &ScaleSubresourceTarget{
+		client:    client,
+		resource:  resource,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+// Scale patches the replicas of the resource, retrying transient errors like the ephemeral runner set patches.
+func (t *ScaleSubresourceTarget) Scale(ctx context.Context, decision Decision) error {
+	mergePatch, err := json.Marshal(map[string]any{
+		"spec": map[string]any{
+			"replicas": decision.Replicas,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal scale patch: %w", err)
+	}
+
+	return retry.OnError(patchBackoff, isTransientError, func() error {
+		_, err := t.client.
+			Resource(t.resource).
+			Namespace(t.namespace).
+			Patch(ctx, t.name, types.MergePatchType, mergePatch, metav1.PatchOptions{}, "scale")
+		return err
+	})
+}
+
+// WebhookTargetConfig configures the WebhookTarget.
+type WebhookTargetConfig struct {
+	URL    string
+	Client *http.Client
+	// BearerTokenFile is the file holding the bearer token sent to the webhook, if set.
+	BearerTokenFile string
+	// SecretFile is the file holding the secret the body is signed with. It is required.
+	SecretFile   string
+	ScaleSetID   int
+	ScaleSetName string
+}
+
+// WebhookTarget posts the decisions to an external webhook, which scales the runners itself,
+// e.g. a fleet of virtual machines or a Nomad job.
+// The files of the bearer token and of the secret are read for every request, so they can be rotated.
+type WebhookTarget struct {
+	config WebhookTargetConfig
+}
+
+var _ ScaleTarget = (*WebhookTarget)(nil)
+
+// WebhookRequest is the body posted to the webhook.
+type WebhookRequest struct {
+	ScaleSet     WebhookScaleSet `json:"scaleSet"`
+	DesiredCount int             `json:"desiredCount"`
+	PatchID      int             `json:"patchID"`
+	// Decision details the scaling decision the desired count results from.
+	Decision Decision `json:"decision"`
+}
+
+// WebhookScaleSet identifies the scale set of the listener.
+type WebhookScaleSet struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func NewWebhookTarget(config WebhookTargetConfig) *WebhookTarget {
+	return &WebhookTarget{config: config}
+}
+
+// Scale posts the decision to the webhook, which must answer with a 2xx status.
+// It is not retried: the next batch of messages posts the decision again.
+func (t *WebhookTarget) Scale(ctx context.Context, decision Decision) error {
+	body, err := json.Marshal(&WebhookRequest{
+		ScaleSet: WebhookScaleSet{
+			ID:   t.config.ScaleSetID,
+			Name: t.config.ScaleSetName,
+		},
+		DesiredCount: decision.Replicas,
+		PatchID:      decision.PatchID,
+		Decision:     decision,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook request: %w", err)
+	}
+
+	endpoint := webhook.Endpoint{
+		URL:             t.config.URL,
+		Client:          t.config.Client,
+		BearerTokenFile: t.config.BearerTokenFile,
+		SecretFile:      t.config.SecretFile,
+	}
+	return endpoint.Post(ctx, body)
+}
@@ -0,0 +1,134 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/webhook"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type scaleTargetFunc func(ctx context.Context, decision Decision) error
+
+func (f scaleTargetFunc) Scale(ctx context.Context, decision Decision) error {
+	return f(ctx, decision)
+}
+
+func TestScaleTarget(t *testing.T) {
+	t.Run("ReplacesEphemeralRunnerSet", func(t *testing.T) {
+		w, client := newFakeClientWorker(t)
+		var decisions []Decision
+		w.target = scaleTargetFunc(func(_ context.Context, decision Decision) error {
+			decisions = append(decisions, decision)
+			return nil
+		})
+		w.config.StaleRunnerGracePeriod = time.Minute
+
+		require.NoError(t, w.Backfill(context.Background()))
+		require.NoError(t, w.HandleSessionCreated(context.Background(), &actions.RunnerScaleSetSession{}, time.Now()))
+		require.NoError(t, w.HandleJobStarted(context.Background(), &actions.JobStarted{RunnerName: "runner"}))
+		require.NoError(t, w.HandleJobCompleted(context.Background(), &actions.JobCompleted{RunnerName: "runner"}))
+
+		replicas, err := w.HandleDesiredRunnerCount(context.Background(), 3, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, replicas)
+		require.Len(t, decisions, 1)
+		assert.Equal(t, 3, decisions[0].Replicas)
+		assert.Equal(t, 3, decisions[0].AssignedJobs)
+		assert.Empty(t, client.Actions(), "the ephemeral runner set and its runners are not touched")
+	})
+
+	t.Run("FailsWithScaleTargetCode", func(t *testing.T) {
+		w, _ := newFakeClientWorker(t)
+		w.target = scaleTargetFunc(func(context.Context, Decision) error {
+			return assert.AnError
+		})
+
+		_, err := w.HandleDesiredRunnerCount(context.Background(), 1, 0)
+		require.ErrorIs(t, err, assert.AnError)
+		code, ok := errcode.Of(err)
+		require.True(t, ok)
+		assert.Equal(t, errcode.ScaleTarget, code)
+	})
+}
+
+func TestScaleSubresourceTarget(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	calls := 0
+	client.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		if calls == 1 {
+			return true, nil, kerrors.NewServiceUnavailable("unavailable")
+		}
+		patch := action.(k8stesting.PatchAction)
+		assert.Equal(t, "scale", patch.GetSubresource())
+		assert.Equal(t, "runners", patch.GetName())
+		assert.Equal(t, "namespace", patch.GetNamespace())
+		assert.JSONEq(t, `{"spec":{"replicas":4}}`, string(patch.GetPatch()))
+		return true, nil, nil
+	})
+
+	target := NewScaleSubresourceTarget(client, appsv1.SchemeGroupVersion.WithResource("deployments"), "namespace", "runners")
+	require.NoError(t, target.Scale(context.Background(), Decision{Replicas: 4}))
+	assert.Equal(t, 2, calls, "transient errors are retried")
+}
+
+func TestWebhookTarget(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile, secretFile := filepath.Join(dir, "token"), filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token\n"), 0o600))
+	require.NoError(t, os.WriteFile(secretFile, []byte("secret\n"), 0o600))
+
+	var got WebhookRequest
+	status := http.StatusNoContent
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, webhook.Sign([]byte("secret"), body), r.Header.Get(webhook.SignatureHeader))
+		require.NoError(t, json.Unmarshal(body, &got))
+		w.WriteHeader(status)
+		if status >= 300 {
+			_, _ = w.Write([]byte("scaling disabled"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	target := NewWebhookTarget(WebhookTargetConfig{
+		URL:             server.URL,
+		Client:          server.Client(),
+		BearerTokenFile: tokenFile,
+		SecretFile:      secretFile,
+		ScaleSetID:      7,
+		ScaleSetName:    "nomad",
+	})
+	decision := Decision{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), AssignedJobs: 2, Target: 3, Replicas: 3, PatchID: 5}
+	require.NoError(t, target.Scale(context.Background(), decision))
+	assert.Equal(t, WebhookRequest{
+		ScaleSet:     WebhookScaleSet{ID: 7, Name: "nomad"},
+		DesiredCount: 3,
+		PatchID:      5,
+		Decision:     decision,
+	}, got)
+
+	status = http.StatusServiceUnavailable
+	err := target.Scale(context.Background(), decision)
+	assert.ErrorContains(t, err, "webhook answered with status 503: scaling disabled")
+}
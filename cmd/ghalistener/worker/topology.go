@@ -0,0 +1,185 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TopologySpread spreads the runners of the scale set across topology domains, e.g. zones, so that the runner
+// capacity survives the outage of a domain. Every domain is served by an ephemeral runner set whose runners
+// are pinned to it, e.g. with a node selector on the topology key, and the replicas of every scaling decision
+// are split between them by weight. If PendingRunners is set, the replicas of the zones whose runners are stuck
+// pending, e.g. during a zone outage, are redistributed to the other zones until no runner of theirs was stuck
+// pending for the threshold.
+type TopologySpread struct {
+	// TopologyKey is the node label of the domains, e.g. "topology.kubernetes.io/zone".
+	TopologyKey string
+	// Zones are the domains. Exactly one of them is served by the ephemeral runner set of the config.
+	Zones []TopologyZone
+}
+
+// TopologyZone is a topology domain of a TopologySpread.
+type TopologyZone struct {
+	// Name is the value of the topology key of the domain, e.g. "eu-west-1a".
+	Name string
+	// EphemeralRunnerSetName is the ephemeral runner set of the domain.
+	// It must be in the namespace of the ephemeral runner set of the config.
+	EphemeralRunnerSetName string
+	// Weight is the share of the replicas of the domain, relative to the weights of the other domains.
+	Weight int
+}
+
+func (s *TopologySpread) validate(ephemeralRunnerSetName string) error {
+	if s.TopologyKey == "" {
+		return errors.New("topology key is empty")
+	}
+	if len(s.Zones) < 2 {
+		return fmt.Errorf("%d zones are configured, at least 2 are required", len(s.Zones))
+	}
+	own := 0
+	for i, zone := range s.Zones {
+		if zone.Name == "" {
+			return fmt.Errorf("zone %d has no name", i)
+		}
+		if zone.EphemeralRunnerSetName == "" {
+			return fmt.Errorf("zone %q has no ephemeral runner set name", zone.Name)
+		}
+		if zone.Weight < 1 {
+			return fmt.Errorf("zone %q weight %d must be positive", zone.Name, zone.Weight)
+		}
+		for _, other := range s.Zones[:i] {
+			if other.Name == zone.Name {
+				return fmt.Errorf("zone %q is configured twice", zone.Name)
+			}
+			if other.EphemeralRunnerSetName == zone.EphemeralRunnerSetName {
+				return fmt.Errorf("ephemeral runner set %q serves zones %q and %q", zone.EphemeralRunnerSetName, other.Name, zone.Name)
+			}
+		}
+		if zone.EphemeralRunnerSetName == ephemeralRunnerSetName {
+			own++
+		}
+	}
+	if own != 1 {
+		return fmt.Errorf("exactly one zone must be served by the ephemeral runner set %q", ephemeralRunnerSetName)
+	}
+	return nil
+}
+
+// spreadReplicas splits the replicas between the zones in proportion to their weights. The replicas left over
+// by rounding down go to the zones with the largest remainders, the earlier zones first on a tie, so that the
+// split is stable across decisions.
+func spreadReplicas(replicas int, zones []TopologyZone) []int {
+	total := 0
+	for _, zone := range zones {
+		total += zone.Weight
+	}
+
+	shares := make([]int, len(zones))
+	remainders := make([]int, len(zones))
+	left := replicas
+	for i, zone := range zones {
+		shares[i] = replicas * zone.Weight / total
+		remainders[i] = replicas * zone.Weight % total
+		left -= shares[i]
+	}
+
+	order := make([]int, len(zones))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return remainders[b] - remainders[a]
+	})
+	for _, i := range order[:left] {
+		shares[i]++
+	}
+	return shares
+}
+
+// spreadZones returns the zones to spread the replicas between, the unavailable zones having no weight so that
+// their replicas are redistributed to the other zones. The zones keep their weights if none is available.
+// It must be called with w.mu held.
+func (w *Worker) spreadZones() []TopologyZone {
+	zones := w.config.TopologySpread.Zones
+	if len(w.unavailableZones) == 0 || len(w.unavailableZones) == len(zones) {
+		return zones
+	}
+	zones = slices.Clone(zones)
+	for i := range zones {
+		if _, ok := w.unavailableZones[zones[i].Name]; ok {
+			zones[i].Weight = 0
+		}
+	}
+	return zones
+}
+
+// markUnavailableZones records the zones with runners stuck pending as unavailable, and the zones without
+// runners stuck pending for the threshold as available again. It must be called with w.mu held.
+func (w *Worker) markUnavailableZones(stuck map[string]int, now time.Time) {
+	for _, zone := range w.config.TopologySpread.Zones {
+		if count := stuck[zone.EphemeralRunnerSetName]; count > 0 {
+			if _, ok := w.unavailableZones[zone.Name]; !ok {
+				w.logger.Info("Redistributing the runners of the zone to the other zones, its runners are stuck pending",
+					"zone", zone.Name,
+					"count", count,
+				)
+			}
+			if w.unavailableZones == nil {
+				w.unavailableZones = make(map[string]time.Time)
+			}
+			w.unavailableZones[zone.Name] = now
+			continue
+		}
+		if last, ok := w.unavailableZones[zone.Name]; ok && now.Sub(last) >= w.config.PendingRunners.Threshold {
+			delete(w.unavailableZones, zone.Name)
+			w.logger.Info("Spreading the runners to the zone again, its runners are no longer stuck pending", "zone", zone.Name)
+		}
+	}
+}
+
+// ownZone returns the index of the zone served by the ephemeral runner set of the config.
+func (s *TopologySpread) ownZone(ephemeralRunnerSetName string) int {
+	return slices.IndexFunc(s.Zones, func(zone TopologyZone) bool {
+		return zone.EphemeralRunnerSetName == ephemeralRunnerSetName
+	})
+}
+
+// zoneOf returns the zone served by the ephemeral runner set, if any.
+func (s *TopologySpread) zoneOf(ephemeralRunnerSetName string) (TopologyZone, bool) {
+	for _, zone := range s.Zones {
+		if zone.EphemeralRunnerSetName == ephemeralRunnerSetName {
+			return zone, true
+		}
+	}
+	return TopologyZone{}, false
+}
+
+// pinnedTo reports whether the runners of the ephemeral runner set are scheduled to the zone only,
+// by a node selector or a required node affinity on the topology key.
+func pinnedTo(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, topologyKey, zone string) bool {
+	pod := ephemeralRunnerSet.Spec.EphemeralRunnerSpec.Spec
+	if pod.NodeSelector[topologyKey] == zone {
+		return true
+	}
+	if pod.Affinity == nil || pod.Affinity.NodeAffinity == nil || pod.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return false
+	}
+	terms := pod.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) == 0 {
+		return false
+	}
+	// The terms are ORed, so every one of them must pin the zone.
+	for _, term := range terms {
+		if !slices.ContainsFunc(term.MatchExpressions, func(r corev1.NodeSelectorRequirement) bool {
+			return r.Key == topologyKey && r.Operator == corev1.NodeSelectorOpIn && len(r.Values) == 1 && r.Values[0] == zone
+		}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestSpreadReplicas(t *testing.T) {
+	zones := func(weights ...int) []TopologyZone {
+		z := make([]TopologyZone, len(weights))
+		for i, weight := range weights {
+			z[i] = TopologyZone{Weight: weight}
+		}
+		return z
+	}
+
+	tests := []struct {
+		replicas int
+		weights  []int
+		want     []int
+	}{
+		{replicas: 0, weights: []int{1, 1, 1}, want: []int{0, 0, 0}},
+		{replicas: 6, weights: []int{1, 1, 1}, want: []int{2, 2, 2}},
+		{replicas: 7, weights: []int{1, 1, 1}, want: []int{3, 2, 2}},
+		{replicas: 8, weights: []int{1, 1, 1}, want: []int{3, 3, 2}},
+		{replicas: 1, weights: []int{1, 1}, want: []int{1, 0}},
+		{replicas: 10, weights: []int{3, 1}, want: []int{8, 2}},
+		{replicas: 5, weights: []int{1, 2, 3}, want: []int{1, 2, 2}},
+	}
+	for _, tt := range tests {
+		got := spreadReplicas(tt.replicas, zones(tt.weights...))
+		assert.Equal(t, tt.want, got, "replicas %d, weights %v", tt.replicas, tt.weights)
+	}
+}
+
+func TestTopologySpreadValidate(t *testing.T) {
+	valid := func() TopologySpread {
+		return TopologySpread{
+			TopologyKey: "topology.kubernetes.io/zone",
+			Zones: []TopologyZone{
+				{Name: "a", EphemeralRunnerSetName: "set", Weight: 1},
+				{Name: "b", EphemeralRunnerSetName: "set-b", Weight: 2},
+			},
+		}
+	}
+	s := valid()
+	assert.NoError(t, s.validate("set"))
+
+	tests := map[string]func(s *TopologySpread){
+		"missing key":        func(s *TopologySpread) { s.TopologyKey = "" },
+		"single zone":        func(s *TopologySpread) { s.Zones = s.Zones[:1] },
+		"missing name":       func(s *TopologySpread) { s.Zones[1].Name = "" },
+		"missing set":        func(s *TopologySpread) { s.Zones[1].EphemeralRunnerSetName = "" },
+		"zero weight":        func(s *TopologySpread) { s.Zones[1].Weight = 0 },
+		"duplicate name":     func(s *TopologySpread) { s.Zones[1].Name = "a" },
+		"duplicate set":      func(s *TopologySpread) { s.Zones[1].EphemeralRunnerSetName = "set" },
+		"no zone of the set": func(s *TopologySpread) { s.Zones[0].EphemeralRunnerSetName = "set-a" },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := valid()
+			mutate(&s)
+			assert.Error(t, s.validate("set"))
+		})
+	}
+}
+
+func TestPinnedTo(t *testing.T) {
+	const key = "topology.kubernetes.io/zone"
+	set := func(pod corev1.PodSpec) *v1alpha1.EphemeralRunnerSet {
+		return &v1alpha1.EphemeralRunnerSet{
+			Spec: v1alpha1.EphemeralRunnerSetSpec{
+				EphemeralRunnerSpec: v1alpha1.EphemeralRunnerSpec{
+					PodTemplateSpec: corev1.PodTemplateSpec{Spec: pod},
+				},
+			},
+		}
+	}
+	affinity := func(terms ...corev1.NodeSelectorTerm) corev1.PodSpec {
+		return corev1.PodSpec{
+			Affinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: terms},
+				},
+			},
+		}
+	}
+	in := func(values ...string) corev1.NodeSelectorTerm {
+		return corev1.NodeSelectorTerm{
+			MatchExpressions: []corev1.NodeSelectorRequirement{
+				{Key: key, Operator: corev1.NodeSelectorOpIn, Values: values},
+			},
+		}
+	}
+
+	assert.True(t, pinnedTo(set(corev1.PodSpec{NodeSelector: map[string]string{key: "a"}}), key, "a"))
+	assert.False(t, pinnedTo(set(corev1.PodSpec{NodeSelector: map[string]string{key: "b"}}), key, "a"))
+	assert.False(t, pinnedTo(set(corev1.PodSpec{}), key, "a"))
+	assert.True(t, pinnedTo(set(affinity(in("a"))), key, "a"))
+	assert.False(t, pinnedTo(set(affinity(in("a", "b"))), key, "a"), "the runners can be scheduled to another zone")
+	assert.False(t, pinnedTo(set(affinity(in("a"), corev1.NodeSelectorTerm{})), key, "a"), "a term does not pin the zone")
+}
+
+func TestHandleDesiredRunnerCount_TopologySpread(t *testing.T) {
+	w, client := newFakeClientWorker(t,
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"}},
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set-b", Namespace: "namespace"}},
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set-c", Namespace: "namespace"}},
+	)
+	w.config.TopologySpread = &TopologySpread{
+		TopologyKey: "topology.kubernetes.io/zone",
+		Zones: []TopologyZone{
+			{Name: "b", EphemeralRunnerSetName: "set-b", Weight: 1},
+			{Name: "a", EphemeralRunnerSetName: "set", Weight: 1},
+			{Name: "c", EphemeralRunnerSetName: "set-c", Weight: 2},
+		},
+	}
+	publisher := &zonePublisher{Publisher: metrics.Discard, zones: map[string]int{}}
+	w.metrics = publisher
+
+	replicas := func(name string) int {
+		obj, err := client.
+			Resource(v1alpha1.GroupVersion.WithResource(ephemeralRunnerSetsResource)).
+			Namespace("namespace").
+			Get(context.Background(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		set := new(v1alpha1.EphemeralRunnerSet)
+		require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), set))
+		return set.Spec.Replicas
+	}
+
+	count, err := w.HandleDesiredRunnerCount(context.Background(), 6, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 6, count, "the total of the runners is returned")
+	assert.Equal(t, 2, replicas("set-b"))
+	assert.Equal(t, 1, replicas("set"))
+	assert.Equal(t, 3, replicas("set-c"))
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3}, publisher.zones)
+}
+
+func TestHandleDesiredRunnerCount_TopologySpreadUnavailableZone(t *testing.T) {
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	controller := true
+	stuck := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "stuck",
+			Namespace:         "namespace",
+			CreationTimestamp: metav1.NewTime(now.Add(-10 * time.Minute)),
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "EphemeralRunnerSet", Name: "set-c", Controller: &controller},
+			},
+		},
+		Status: v1alpha1.EphemeralRunnerStatus{Phase: corev1.PodPending},
+	}
+	w, client := newFakeClientWorker(t,
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "namespace"}},
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set-b", Namespace: "namespace"}},
+		&v1alpha1.EphemeralRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set-c", Namespace: "namespace"}},
+		stuck,
+	)
+	fakeClock := clocktesting.NewFakeClock(now)
+	w.clock = fakeClock
+	w.config.TopologySpread = &TopologySpread{
+		TopologyKey: "topology.kubernetes.io/zone",
+		Zones: []TopologyZone{
+			{Name: "b", EphemeralRunnerSetName: "set-b", Weight: 1},
+			{Name: "a", EphemeralRunnerSetName: "set", Weight: 1},
+			{Name: "c", EphemeralRunnerSetName: "set-c", Weight: 2},
+		},
+	}
+	w.config.PendingRunners = &PendingRunners{Threshold: 5 * time.Minute, Interval: 30 * time.Second, CapScaleUps: true}
+	publisher := &zonePublisher{Publisher: metrics.Discard, zones: map[string]int{}}
+	w.metrics = publisher
+
+	require.NoError(t, w.checkPendingRunners(context.Background()))
+	_, err := w.HandleDesiredRunnerCount(context.Background(), 6, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 3, "b": 3, "c": 0}, publisher.zones, "the runners of the unavailable zone are redistributed, not capped")
+
+	require.NoError(t, client.Tracker().Delete(v1alpha1.GroupVersion.WithResource("ephemeralrunners"), "namespace", "stuck"))
+	require.NoError(t, w.checkPendingRunners(context.Background()))
+	_, err = w.HandleDesiredRunnerCount(context.Background(), 6, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, publisher.zones["c"], "the zone is unavailable until no runner of it was stuck pending for the threshold")
+
+	fakeClock.Step(5 * time.Minute)
+	require.NoError(t, w.checkPendingRunners(context.Background()))
+	_, err = w.HandleDesiredRunnerCount(context.Background(), 6, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3}, publisher.zones)
+}
+
+type zonePublisher struct {
+	metrics.Publisher
+	zones map[string]int
+}
+
+func (p *zonePublisher) PublishZoneDesiredRunners(zone string, count int) {
+	p.zones[zone] = count
+}
@@ -0,0 +1,37 @@
+package worker
+
+import "time"
+
+// trackIdle records since when no job is assigned to the scale set, and returns the assigned job count
+// the batch is scaled for. It must be called with w.mu held, before the batch is applied to the last
+// assigned job count.
+func (w *Worker) trackIdle(count, jobsCompleted int) int {
+	assigned := count
+	if count == 0 && jobsCompleted == 0 { // empty batch
+		assigned = w.lastAssigned
+	}
+	switch {
+	case assigned > 0:
+		w.idleSince = time.Time{}
+	case w.idleSince.IsZero():
+		w.idleSince = w.now()
+	}
+	return assigned
+}
+
+// warmPoolMinRunners returns the min runners to keep warm, which are lowered to the hard min runners
+// once no job was assigned for the idle timeout. The first assigned job restores them.
+func (w *Worker) warmPoolMinRunners(minRunners int) int {
+	if w.config.IdleTimeout <= 0 {
+		return minRunners
+	}
+
+	w.mu.Lock()
+	idleSince := w.idleSince
+	w.mu.Unlock()
+
+	if idleSince.IsZero() || w.now().Sub(idleSince) < w.config.IdleTimeout {
+		return minRunners
+	}
+	return min(minRunners, w.config.HardMinRunners)
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestWarmPool_IdleTimeout(t *testing.T) {
+	logger := logr.Discard()
+	fakeClock := clocktesting.NewFakeClock(time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC))
+	w := &Worker{
+		config: Config{
+			MinRunners:     5,
+			MaxRunners:     math.MaxInt32,
+			IdleTimeout:    time.Hour,
+			HardMinRunners: 1,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+		clock:     fakeClock,
+	}
+
+	w.setDesiredWorkerState(2, 0)
+	assert.Equal(t, 7, w.lastPatch)
+
+	w.setDesiredWorkerState(0, 2)
+	assert.Equal(t, 5, w.lastPatch, "min runners are kept warm once idle")
+
+	fakeClock.Step(59 * time.Minute)
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 5, w.lastPatch, "min runners are kept warm before the idle timeout")
+
+	fakeClock.Step(time.Minute)
+	w.setDesiredWorkerState(0, 0)
+	patchID := w.lastDecision().PatchID
+	assert.Equal(t, 1, w.lastPatch, "the warm pool shrinks to the hard min runners after the idle timeout")
+	assert.Equal(t, 3, patchID)
+	assert.Equal(t, 1, w.State().MinRunners)
+
+	w.setDesiredWorkerState(1, 0)
+	assert.Equal(t, 6, w.lastPatch, "an assigned job restores the min runners")
+	assert.Equal(t, 5, w.State().MinRunners)
+}
+
+func TestWarmPool_Disabled(t *testing.T) {
+	logger := logr.Discard()
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	w := &Worker{
+		config: Config{
+			MinRunners: 5,
+			MaxRunners: math.MaxInt32,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+		clock:     fakeClock,
+	}
+
+	w.setDesiredWorkerState(0, 0)
+	fakeClock.Step(24 * time.Hour)
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 5, w.lastPatch)
+}
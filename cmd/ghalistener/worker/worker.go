@@ -3,22 +3,57 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/health"
 	"github.com/actions/actions-runner-controller/cmd/ghalistener/listener"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/tracing"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/logging"
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/kubernetes"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/clock"
 )
 
 const workerName = "kubernetesworker"
 
+var tracer = otel.Tracer("github.com/actions/actions-runner-controller/cmd/ghalistener/worker")
+
+// The default resource names of the kinds patched by the worker, in the version it is built against.
+// The resources served by the API server are resolved by DiscoverResources.
+const (
+	ephemeralRunnersResource    = "ephemeralrunners"
+	ephemeralRunnerSetsResource = "ephemeralrunnersets"
+)
+
+// patchBackoff is the backoff used to retry patches failing with a transient Kubernetes API error,
+// so that a temporarily unavailable apiserver does not stop the listener.
+var patchBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 200 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
 type Option func(*Worker)
 
 func WithLogger(logger logr.Logger) Option {
@@ -28,48 +63,210 @@ func WithLogger(logger logr.Logger) Option {
 	}
 }
 
+// WithClient sets the client of the Kubernetes API in place of the in-cluster one.
+func WithClient(client dynamic.Interface) Option {
+	return func(w *Worker) {
+		w.client = client
+	}
+}
+
+// WithClock sets the clock used to schedule delayed work.
+func WithClock(clock clock.WithDelayedExecution) Option {
+	return func(w *Worker) {
+		w.clock = clock
+	}
+}
+
+// WithHealth sets the status the worker records successful EphemeralRunnerSet patches in.
+func WithHealth(status *health.Status) Option {
+	return func(w *Worker) {
+		w.health = status
+	}
+}
+
+// WithMetrics sets the publisher of the EphemeralRunnerSet patch metrics.
+func WithMetrics(publisher metrics.Publisher) Option {
+	return func(w *Worker) {
+		w.metrics = publisher
+	}
+}
+
 type Config struct {
 	EphemeralRunnerSetNamespace string
 	EphemeralRunnerSetName      string
 	MaxRunners                  int
 	MinRunners                  int
+	// StaleRunnerGracePeriod is the time an ephemeral runner may still exist after its job completed
+	// before it is annotated for priority garbage collection. Zero disables the annotation.
+	StaleRunnerGracePeriod time.Duration
+	// MaxScaleUpStep is the maximum number of replicas added by a single patch. Zero means unlimited.
+	MaxScaleUpStep int
+	// MaxScaleDownStep is the maximum number of replicas removed by a single patch. Zero means unlimited.
+	MaxScaleDownStep int
+	// ScheduledOverrides override MinRunners and MaxRunners during time windows.
+	// The earlier an override is in the list, the higher it is prioritized.
+	ScheduledOverrides []ScheduledOverride
+	// IdleTimeout is the time without assigned jobs after which the min runners are lowered to HardMinRunners.
+	// Zero disables it.
+	IdleTimeout time.Duration
+	// HardMinRunners is the min runners once the scale set was idle for IdleTimeout.
+	HardMinRunners int
+	// MaxRunnerMinutesPerDay caps the scale-ups at the min runners once the desired runners
+	// add up to this many runner minutes in the UTC day. Zero disables it.
+	MaxRunnerMinutesPerDay int
+	// Migration shifts the runners to another ephemeral runner set, if set.
+	Migration *Migration
+	// Canary patches a share of the runners into a canary ephemeral runner set, if set.
+	// It cannot be set along with Migration.
+	Canary *Canary
+	// TopologySpread splits the runners between the ephemeral runner sets of topology domains, if set.
+	// It cannot be set along with Migration or Canary.
+	TopologySpread *TopologySpread
+	// FallbackReplicas are the replicas scaled to while the message session is unavailable,
+	// within the runner bounds. If it is nil, the runners of the last assigned job count are kept.
+	FallbackReplicas *int
+	// MissingRunnerPolicy is how a started job is handled when its ephemeral runner is not found.
+	// Defaults to MissingRunnerSkip.
+	MissingRunnerPolicy MissingRunnerPolicy
+	// DuplicatePatchTTL is the time the scale decisions repeating the replicas of the last patch are not patched,
+	// unless the batch completed jobs. Zero disables it, so that every batch is patched.
+	DuplicatePatchTTL time.Duration
+	// JobPolicies adjust the runners the jobs they match count for toward the desired runner count.
+	JobPolicies []JobPolicy
+	// PriorityRunners are the runners of the max runners held back for the jobs of the priority job policies,
+	// so that the other jobs cannot scale the scale set to its max runners on their own.
+	PriorityRunners int
+	// PendingRunners reports the ephemeral runners stuck pending, and caps the scale-ups while they are, if set.
+	PendingRunners *PendingRunners
 }
 
 // The Worker's role is to process the messages it receives from the listener.
 // It then initiates Kubernetes API requests to carry out the necessary actions.
 type Worker struct {
-	clientset *kubernetes.Clientset
-	config    Config
+	client dynamic.Interface
+	config Config
+	// mu guards the min and max runners of the config, which are set at runtime by SetRunnerLimits,
+	// and the scaling state below, which is read by State.
+	mu        sync.Mutex
 	lastPatch int
-	patchSeq  int
-	logger    *logr.Logger
+	// lastAssigned is the assigned job count of the last non-empty batch.
+	lastAssigned int
+	patchSeq     int
+	logger       *logr.Logger
+	clock        clock.WithDelayedExecution
+	health       *health.Status
+	metrics      metrics.Publisher
+	decisions    []Decision
+	hints        repositoryHints
+	jobs         jobList
+	canary       canaryState
+	// target replaces the ephemeral runner set as the resource scaled by the worker, if set.
+	target ScaleTarget
+	// resources are the resources of the kinds patched by the worker, the default ones if not discovered.
+	resources Resources
+	// runners caches the ephemeral runners which exist, if set.
+	runners *RunnerCache
+	// idleSince is the time of the first batch without assigned jobs, zero while jobs are assigned.
+	idleSince time.Time
+	// predictor forecasts the assigned jobs from the demand of the previous days, if set.
+	predictor Predictor
+	// budget is the runner time of the current day.
+	budget runnerBudget
+	// fallback is set while the runners are scaled to the fallback replicas.
+	fallback bool
+	// reservations raise the min runners until they expire.
+	reservations []reservation
+	// forced pins the replicas until it expires, if set.
+	forced *forcedScale
+	// sent is the last scale decision patched, nil before the first one.
+	sent *sentPatch
+	// jobCounts are the job counts of the last message, logged along with the scale decisions.
+	jobCounts listener.JobCounts
+	// stuckPending is the count of ephemeral runners stuck pending at the last check of PendingRunners.
+	stuckPending int
+	// unavailableZones are the zones of the TopologySpread whose replicas are redistributed to the other zones,
+	// with the time of the last check of PendingRunners their runners were stuck pending at.
+	unavailableZones map[string]time.Time
 }
 
-var _ listener.Handler = (*Worker)(nil)
+var (
+	_ listener.Handler          = (*Worker)(nil)
+	_ listener.Fallback         = (*Worker)(nil)
+	_ listener.JobCountsHandler = (*Worker)(nil)
+)
 
 func New(config Config, options ...Option) (*Worker, error) {
-	w := &Worker{
-		config:    config,
-		lastPatch: -1,
-		patchSeq:  -1,
+	for i := range config.ScheduledOverrides {
+		if err := config.ScheduledOverrides[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid scheduled override at index %d: %w", i, err)
+		}
+	}
+	if config.Migration != nil {
+		if err := config.Migration.validate(config.EphemeralRunnerSetName); err != nil {
+			return nil, fmt.Errorf("invalid migration: %w", err)
+		}
+	}
+	if config.Canary != nil {
+		if config.Migration != nil {
+			return nil, errors.New("a canary cannot run during a migration")
+		}
+		if err := config.Canary.validate(config.EphemeralRunnerSetName); err != nil {
+			return nil, fmt.Errorf("invalid canary: %w", err)
+		}
+	}
+	if config.TopologySpread != nil {
+		if config.Migration != nil || config.Canary != nil {
+			return nil, errors.New("the runners cannot be spread across topology domains during a migration or a canary")
+		}
+		if err := config.TopologySpread.validate(config.EphemeralRunnerSetName); err != nil {
+			return nil, fmt.Errorf("invalid topology spread: %w", err)
+		}
 	}
 
-	conf, err := rest.InClusterConfig()
-	if err != nil {
-		return nil, err
+	for i := range config.JobPolicies {
+		if err := config.JobPolicies[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid job policy at index %d: %w", i, err)
+		}
+	}
+	if config.PendingRunners != nil {
+		if err := config.PendingRunners.validate(); err != nil {
+			return nil, fmt.Errorf("invalid pending runners: %w", err)
+		}
+	}
+	if config.PriorityRunners < 0 {
+		return nil, errors.New("priority runners cannot be negative")
+	}
+	if config.PriorityRunners > 0 && !slices.ContainsFunc(config.JobPolicies, func(p JobPolicy) bool { return p.Priority }) {
+		return nil, errors.New("priority runners require a priority job policy")
 	}
 
-	clientset, err := kubernetes.NewForConfig(conf)
-	if err != nil {
+	if err := config.MissingRunnerPolicy.validate(); err != nil {
 		return nil, err
 	}
 
-	w.clientset = clientset
+	w := &Worker{
+		config:    config,
+		lastPatch: -1,
+		patchSeq:  -1,
+	}
 
 	for _, option := range options {
 		option(w)
 	}
 
+	if w.client == nil {
+		conf, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, errcode.Errorf(errcode.KubernetesClient, "failed to load in-cluster config: %w", err)
+		}
+
+		client, err := dynamic.NewForConfig(conf)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.KubernetesClient, "failed to create kubernetes client: %w", err)
+		}
+		w.client = client
+	}
+
 	if err := w.applyDefaults(); err != nil {
 		return nil, err
 	}
@@ -83,10 +280,26 @@ func (w *Worker) applyDefaults() error {
 		if err != nil {
 			return fmt.Errorf("NewLogger failed: %w", err)
 		}
-		logger = logger.WithName(workerName)
+		logger = logging.Redact(logger).WithName(workerName)
 		w.logger = &logger
 	}
 
+	if w.clock == nil {
+		w.clock = clock.RealClock{}
+	}
+
+	if w.metrics == nil {
+		w.metrics = metrics.Discard
+	}
+
+	return nil
+}
+
+// HandleJobAssigned records the repository of the job assigned to the scale set,
+// which is sent as a placement hint with the next scale patch until the job is started.
+func (w *Worker) HandleJobAssigned(ctx context.Context, jobInfo *actions.JobAssigned) error {
+	w.hints.assign(jobInfo.JobID, fmt.Sprintf("%s/%s", jobInfo.OwnerName, jobInfo.RepositoryName))
+	w.jobs.assign(jobInfo)
 	return nil
 }
 
@@ -106,6 +319,12 @@ func (w *Worker) HandleJobStarted(ctx context.Context, jobInfo *actions.JobStart
 		"jobDisplayName", jobInfo.JobDisplayName,
 		"requestId", jobInfo.RunnerRequestID)
 
+	w.hints.remove(jobInfo.JobID)
+	w.jobs.start(jobInfo)
+	if w.target != nil {
+		return nil
+	}
+
 	original, err := json.Marshal(&v1alpha1.EphemeralRunner{})
 	if err != nil {
 		return fmt.Errorf("failed to marshal empty ephemeral runner: %w", err)
@@ -132,25 +351,35 @@ func (w *Worker) HandleJobStarted(ctx context.Context, jobInfo *actions.JobStart
 		return fmt.Errorf("failed to create merge patch json for ephemeral runner: %w", err)
 	}
 
-	w.logger.Info("Updating ephemeral runner with merge patch", "json", string(mergePatch))
+	w.logger.V(1).Info("Updating ephemeral runner with merge patch", "json", string(mergePatch))
 
-	patchedStatus := &v1alpha1.EphemeralRunner{}
-	err = w.clientset.RESTClient().
-		Patch(types.MergePatchType).
-		Prefix("apis", v1alpha1.GroupVersion.Group, v1alpha1.GroupVersion.Version).
-		Namespace(w.config.EphemeralRunnerSetNamespace).
-		Resource("EphemeralRunners").
-		Name(jobInfo.RunnerName).
-		SubResource("status").
-		Body(mergePatch).
-		Do(ctx).
-		Into(patchedStatus)
+	policy := w.config.MissingRunnerPolicy
+	if policy == "" {
+		policy = MissingRunnerSkip
+	}
+	patchStatus := func() error {
+		return w.patch(ctx, ephemeralRunnersResource, jobInfo.RunnerName, mergePatch, &v1alpha1.EphemeralRunner{}, "status")
+	}
+	if policy != MissingRunnerRetry && w.runnerMissing(ctx, jobInfo.RunnerName) {
+		// The patch would only fail, and the retries wait for the runner through the API server.
+		err = kerrors.NewNotFound(w.resource(ephemeralRunnersResource).GroupResource(), jobInfo.RunnerName)
+	} else {
+		err = patchStatus()
+	}
+	if kerrors.IsNotFound(err) && policy == MissingRunnerRetry {
+		w.logger.Info("Ephemeral runner not found, retrying patching of ephemeral runner status", "runnerName", jobInfo.RunnerName)
+		err = retry.OnError(missingRunnerBackoff, kerrors.IsNotFound, patchStatus)
+	}
 	if err != nil {
 		if kerrors.IsNotFound(err) {
+			w.metrics.PublishEphemeralRunnerMiss(string(policy))
+			if policy == MissingRunnerFail {
+				return errcode.Errorf(errcode.EphemeralRunnerNotFound, "ephemeral runner %q of job %q not found, the controller and the listener may be out of sync: %w", jobInfo.RunnerName, jobInfo.JobID, err)
+			}
 			w.logger.Info("Ephemeral runner not found, skipping patching of ephemeral runner status", "runnerName", jobInfo.RunnerName)
 			return nil
 		}
-		return fmt.Errorf("could not patch ephemeral runner status, patch JSON: %s, error: %w", string(mergePatch), err)
+		return errcode.Errorf(errcode.EphemeralRunnerPatch, "could not patch ephemeral runner status, patch JSON: %s, error: %w", string(mergePatch), err)
 	}
 
 	w.logger.Info("Ephemeral runner status updated with the merge patch successfully.")
@@ -158,16 +387,288 @@ func (w *Worker) HandleJobStarted(ctx context.Context, jobInfo *actions.JobStart
 	return nil
 }
 
+// HandleJobCompleted schedules a check of the ephemeral runner that completed the job.
+// If the ephemeral runner still exists after the configured grace period, it is annotated
+// for priority garbage collection, so the controller reaps it ahead of other runners.
+// It does nothing if the grace period is not configured.
+func (w *Worker) HandleJobCompleted(ctx context.Context, jobInfo *actions.JobCompleted) error {
+	// Jobs cancelled before they started are only completed.
+	w.hints.remove(jobInfo.JobID)
+	w.jobs.complete(jobInfo.JobID)
+	w.recordCanaryResult(ctx, jobInfo)
+
+	if w.config.StaleRunnerGracePeriod <= 0 || jobInfo.RunnerName == "" || w.target != nil {
+		return nil
+	}
+
+	runnerName := jobInfo.RunnerName
+	// The check is cancelled with the context, i.e. when the listener stops, and releases it once run.
+	ctx, cancel := context.WithCancel(ctx)
+	timer := w.clock.AfterFunc(w.config.StaleRunnerGracePeriod, func() {
+		defer cancel()
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		if err := w.markRunnerForGC(ctx, runnerName); err != nil {
+			w.logger.Error(err, "Failed to annotate stale ephemeral runner", "runnerName", runnerName, "errorCode", errcode.EphemeralRunnerPatch)
+		}
+	})
+	context.AfterFunc(ctx, func() { timer.Stop() })
+
+	return nil
+}
+
+func (w *Worker) markRunnerForGC(ctx context.Context, runnerName string) error {
+	if w.runnerMissing(ctx, runnerName) {
+		w.logger.Info("Ephemeral runner already removed, skipping gc priority annotation", "runnerName", runnerName)
+		return nil
+	}
+
+	original, err := json.Marshal(&v1alpha1.EphemeralRunner{})
+	if err != nil {
+		return fmt.Errorf("failed to marshal empty ephemeral runner: %w", err)
+	}
+
+	patch, err := json.Marshal(
+		&v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					v1alpha1.EphemeralRunnerGCPriorityAnnotationKey: "true",
+				},
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ephemeral runner patch: %w", err)
+	}
+
+	mergePatch, err := jsonpatch.CreateMergePatch(original, patch)
+	if err != nil {
+		return fmt.Errorf("failed to create merge patch json for ephemeral runner: %w", err)
+	}
+
+	err = w.patch(ctx, ephemeralRunnersResource, runnerName, mergePatch, nil)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			w.logger.Info("Ephemeral runner already removed, skipping gc priority annotation", "runnerName", runnerName)
+			return nil
+		}
+		return errcode.Errorf(errcode.EphemeralRunnerPatch, "could not annotate ephemeral runner, patch JSON: %s, error: %w", string(mergePatch), err)
+	}
+
+	w.logger.Info("Ephemeral runner still exists after job completion, annotated for gc priority", "runnerName", runnerName)
+	return nil
+}
+
+// patch applies the merge patch to the named resource in the namespace of the ephemeral runner set,
+// retrying transient errors with exponential backoff. The patched object is decoded into into, if set.
+func (w *Worker) patch(ctx context.Context, resource, name string, mergePatch []byte, into any, subresources ...string) (err error) {
+	ctx, span := tracer.Start(ctx, "worker.patch", trace.WithAttributes(
+		attribute.String("k8s.resource", resource),
+		attribute.String("k8s.name", name),
+		attribute.String("k8s.namespace", w.config.EphemeralRunnerSetNamespace),
+	))
+	attempts := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("attempts", attempts))
+		tracing.End(span, err)
+	}()
+
+	return retry.OnError(patchBackoff, isTransientError, func() error {
+		attempts++
+		if resource == ephemeralRunnerSetsResource {
+			w.metrics.PublishEphemeralRunnerSetPatchAttempt()
+		}
+
+		patched, err := w.client.
+			Resource(w.resource(resource)).
+			Namespace(w.config.EphemeralRunnerSetNamespace).
+			Patch(ctx, name, types.MergePatchType, mergePatch, metav1.PatchOptions{}, subresources...)
+		// Conflicts and non-transient errors are answers of the API server, hence it is reachable.
+		w.health.RecordKubernetesRequest(err == nil || kerrors.IsConflict(err) || !isTransientError(err))
+		if err != nil {
+			if isTransientError(err) {
+				w.logger.Info("Transient error patching resource, retrying", "resource", resource, "name", name, "error", err.Error())
+			}
+			return err
+		}
+
+		if into == nil {
+			return nil
+		}
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(patched.UnstructuredContent(), into)
+	})
+}
+
+func isTransientError(err error) bool {
+	return kerrors.IsConflict(err) ||
+		kerrors.IsServerTimeout(err) ||
+		kerrors.IsTimeout(err) ||
+		kerrors.IsTooManyRequests(err) ||
+		kerrors.IsServiceUnavailable(err) ||
+		kerrors.IsInternalError(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
 // HandleDesiredRunnerCount handles the desired runner count by scaling the ephemeral runner set.
 // The function calculates the target runner count based on the minimum and maximum runner count configuration.
-// If the target runner count is the same as the last patched count, it skips patching and returns nil.
+// If the DuplicatePatchTTL is set and the target runner count repeats the last patch, it skips patching.
 // Otherwise, it creates a merge patch JSON for updating the ephemeral runner set with the desired count.
 // The function then scales the ephemeral runner set by applying the merge patch.
 // Finally, it logs the scaled ephemeral runner set details and returns nil if successful.
 // If any error occurs during the process, it returns an error with a descriptive message.
-func (w *Worker) HandleDesiredRunnerCount(ctx context.Context, count, jobsCompleted int) (int, error) {
-	patchID := w.setDesiredWorkerState(count, jobsCompleted)
+func (w *Worker) HandleDesiredRunnerCount(ctx context.Context, count, jobsCompleted int) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "worker.HandleDesiredRunnerCount", trace.WithAttributes(
+		attribute.Int("assigned_jobs", count),
+		attribute.Int("jobs_completed", jobsCompleted),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	w.refreshBudget(ctx)
+	w.setDesiredWorkerState(count, jobsCompleted)
+	// The decision holds the replicas and the patch ID of the last patch, which are read under w.mu.
+	decision := w.lastDecision()
+	desired, patchID := decision.Replicas, decision.PatchID
+	w.metrics.PublishScalingPolicy(w.scalingPolicy(), decision.Schedule, decision.Clamps)
+	w.publishBudget(ctx)
+	span.SetAttributes(
+		attribute.Int("replicas", desired),
+		attribute.Int("patch_id", patchID),
+	)
+
+	if w.target != nil {
+		sent := []int{desired}
+		if w.duplicatePatch(jobsCompleted, sent) {
+			w.skipDuplicatePatch(span, desired)
+			return desired, nil
+		}
+		if err := w.target.Scale(ctx, decision); err != nil {
+			return 0, errcode.Errorf(errcode.ScaleTarget, "could not scale the scale target to %d replicas: %w", desired, err)
+		}
+		w.health.RecordPatch()
+		w.markPatchSent(sent)
+		w.logger.Info("Scale target scaled.", "replicas", desired)
+		return desired, nil
+	}
+
+	replicas, targetReplicas := desired, 0
+	var zoneReplicas []int
+	target, percentage, split := w.splitTarget()
+	if spread := w.config.TopologySpread; spread != nil {
+		w.mu.Lock()
+		zones := w.spreadZones()
+		w.mu.Unlock()
+		zoneReplicas = spreadReplicas(desired, zones)
+		for i, zone := range spread.Zones {
+			w.metrics.PublishZoneDesiredRunners(zone.Name, zoneReplicas[i])
+		}
+		replicas = zoneReplicas[spread.ownZone(w.config.EphemeralRunnerSetName)]
+		w.logger.Info("Spreading runners across zones", "topologyKey", spread.TopologyKey, "replicas", fmt.Sprint(zoneReplicas))
+	} else if split {
+		replicas, targetReplicas = splitReplicas(desired, percentage)
+		span.SetAttributes(attribute.Int("split_percentage", percentage))
+		w.logger.Info("Splitting runners",
+			"percentage", percentage,
+			"replicas", replicas,
+			"target", target,
+			"targetReplicas", targetReplicas,
+		)
+	}
+
+	hints, hintsChanged := w.hints.value()
+	sent := []int{replicas, targetReplicas}
+	if zoneReplicas != nil {
+		sent = zoneReplicas
+	}
+	if !hintsChanged && w.duplicatePatch(jobsCompleted, sent) {
+		w.skipDuplicatePatch(span, desired)
+		return desired, nil
+	}
+	if w.resyncPatch(count, jobsCompleted, sent) {
+		patchID = 0
+		span.SetAttributes(attribute.Bool("resync", true))
+	}
+
+	mergePatch, err := w.replicasPatch(replicas, patchID, decision.Reason)
+	if err != nil {
+		return 0, err
+	}
+
+	if hintsChanged {
+		mergePatch, err = withRepositoryHints(mergePatch, hints)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add repository hints to the merge patch: %w", err)
+		}
+	}
+
+	// The targets are scaled up before the ephemeral runner set is scaled down, so that the runners
+	// it gives up are not lost if a target cannot be patched, e.g. when the role does not allow it.
+	if split {
+		if err := w.scaleSplitTarget(ctx, target, targetReplicas, patchID); err != nil {
+			return 0, err
+		}
+	}
+	if spread := w.config.TopologySpread; spread != nil {
+		for i, zone := range spread.Zones {
+			if zone.EphemeralRunnerSetName == w.config.EphemeralRunnerSetName {
+				continue
+			}
+			if err := w.scaleSplitTarget(ctx, zone.EphemeralRunnerSetName, zoneReplicas[i], patchID); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	w.logger.V(1).Info("Preparing EphemeralRunnerSet update", "json", string(mergePatch))
+
+	patchedEphemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{}
+	start := w.now()
+	err = w.patch(ctx, ephemeralRunnerSetsResource, w.config.EphemeralRunnerSetName, mergePatch, patchedEphemeralRunnerSet)
+	w.metrics.PublishEphemeralRunnerSetPatch(w.now().Sub(start), err)
+	if err != nil {
+		return 0, errcode.Errorf(errcode.EphemeralRunnerSetPatch, "could not patch ephemeral runner set , patch JSON: %s, error: %w", string(mergePatch), err)
+	}
+	w.health.RecordPatch()
+	if hintsChanged {
+		w.hints.markSent(hints)
+	}
 
+	w.logger.Info("Ephemeral runner set scaled.",
+		"namespace", w.config.EphemeralRunnerSetNamespace,
+		"name", w.config.EphemeralRunnerSetName,
+		"replicas", patchedEphemeralRunnerSet.Spec.Replicas,
+	)
+
+	w.markPatchSent(sent)
+	return desired, nil
+}
+
+func (w *Worker) skipDuplicatePatch(span trace.Span, replicas int) {
+	span.SetAttributes(attribute.Bool("duplicate", true))
+	w.metrics.PublishEphemeralRunnerSetPatchSuppressed()
+	w.logger.Info("Skipping the patch repeating the last one", "replicas", replicas, "ttl", w.config.DuplicatePatchTTL.String())
+}
+
+// splitTarget returns the ephemeral runner set of the migration or the canary, if any,
+// and the share of the runners to patch into it now. A rolled back canary gets no runners.
+func (w *Worker) splitTarget() (name string, percentage int, ok bool) {
+	switch {
+	case w.config.Migration != nil:
+		return w.config.Migration.TargetEphemeralRunnerSetName, w.config.Migration.percentage(w.now()), true
+	case w.config.Canary != nil:
+		if w.canary.isRolledBack() {
+			return w.config.Canary.EphemeralRunnerSetName, 0, true
+		}
+		return w.config.Canary.EphemeralRunnerSetName, w.config.Canary.Percentage, true
+	}
+	return "", 0, false
+}
+
+// replicasPatch creates the merge patch setting the replicas and the patch ID of an ephemeral runner set,
+// along with the reason of the scale decision.
+func (w *Worker) replicasPatch(replicas, patchID int, reason string) ([]byte, error) {
 	original, err := json.Marshal(
 		&v1alpha1.EphemeralRunnerSet{
 			Spec: v1alpha1.EphemeralRunnerSetSpec{
@@ -177,83 +678,177 @@ func (w *Worker) HandleDesiredRunnerCount(ctx context.Context, count, jobsComple
 		},
 	)
 	if err != nil {
-		return 0, fmt.Errorf("failed to marshal empty ephemeral runner set: %w", err)
+		return nil, fmt.Errorf("failed to marshal empty ephemeral runner set: %w", err)
 	}
 
 	patch, err := json.Marshal(
 		&v1alpha1.EphemeralRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					v1alpha1.EphemeralRunnerSetScaleReasonAnnotationKey: reason,
+				},
+			},
 			Spec: v1alpha1.EphemeralRunnerSetSpec{
-				Replicas: w.lastPatch,
+				Replicas: replicas,
 				PatchID:  patchID,
 			},
 		},
 	)
 	if err != nil {
 		w.logger.Error(err, "could not marshal patch ephemeral runner set")
-		return 0, err
+		return nil, err
 	}
 
-	w.logger.Info("Compare", "original", string(original), "patch", string(patch))
+	w.logger.V(1).Info("Compare", "original", string(original), "patch", string(patch))
 	mergePatch, err := jsonpatch.CreateMergePatch(original, patch)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create merge patch json for ephemeral runner set: %w", err)
+		return nil, fmt.Errorf("failed to create merge patch json for ephemeral runner set: %w", err)
 	}
+	return mergePatch, nil
+}
 
-	w.logger.Info("Preparing EphemeralRunnerSet update", "json", string(mergePatch))
+// scaleSplitTarget patches the share of the runners of the migration, the canary, or a topology zone
+// into its ephemeral runner set.
+// The target shares the patch ID of the ephemeral runner set, so both ignore the same stale patches.
+func (w *Worker) scaleSplitTarget(ctx context.Context, name string, replicas, patchID int) error {
+	mergePatch, err := w.replicasPatch(replicas, patchID, w.lastDecision().Reason)
+	if err != nil {
+		return err
+	}
 
-	patchedEphemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{}
-	err = w.clientset.RESTClient().
-		Patch(types.MergePatchType).
-		Prefix("apis", v1alpha1.GroupVersion.Group, v1alpha1.GroupVersion.Version).
-		Namespace(w.config.EphemeralRunnerSetNamespace).
-		Resource("ephemeralrunnersets").
-		Name(w.config.EphemeralRunnerSetName).
-		Body([]byte(mergePatch)).
-		Do(ctx).
-		Into(patchedEphemeralRunnerSet)
+	start := w.now()
+	err = w.patch(ctx, ephemeralRunnerSetsResource, name, mergePatch, nil)
+	w.metrics.PublishEphemeralRunnerSetPatch(w.now().Sub(start), err)
 	if err != nil {
-		return 0, fmt.Errorf("could not patch ephemeral runner set , patch JSON: %s, error: %w", string(mergePatch), err)
+		return errcode.Errorf(errcode.EphemeralRunnerSetPatch, "could not patch split target ephemeral runner set %s, patch JSON: %s, error: %w", name, string(mergePatch), err)
 	}
 
-	w.logger.Info("Ephemeral runner set scaled.",
+	w.logger.Info("Split target ephemeral runner set scaled.",
 		"namespace", w.config.EphemeralRunnerSetNamespace,
-		"name", w.config.EphemeralRunnerSetName,
-		"replicas", patchedEphemeralRunnerSet.Spec.Replicas,
+		"name", name,
+		"replicas", replicas,
 	)
-	return w.lastPatch, nil
+	return nil
 }
 
-// calculateDesiredState calculates the desired state of the worker based on the desired count and the the number of jobs completed.
-func (w *Worker) setDesiredWorkerState(count, jobsCompleted int) int {
+// setDesiredWorkerState calculates the desired state of the worker based on the desired count and the number of jobs completed.
+func (w *Worker) setDesiredWorkerState(count, jobsCompleted int) {
+	w.mu.Lock()
+	assigned := w.trackIdle(count, jobsCompleted)
+	w.mu.Unlock()
+	predicted := w.predict(assigned)
+
 	// Max runners should always be set by the resource builder either to the configured value,
 	// or the maximum int32 (resourcebuilder.newAutoScalingListener()).
-	targetRunnerCount := min(w.config.MinRunners+count, w.config.MaxRunners)
+	weights := w.weighJobs(assigned)
+
+	bounds := w.scalingBounds()
+	bounds.jobPolicy = weights.capped
+	minRunners, maxRunners := bounds.minRunners, bounds.maxRunners
+	weighted, held := w.holdPriorityRunners(weights, minRunners, maxRunners)
+	bounds.priority = held
+	targetRunnerCount := min(minRunners+weighted, maxRunners)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	bounds.budget = w.budgetExhausted(w.now())
 	w.patchSeq++
-	desiredPatchID := w.patchSeq
-
-	if count == 0 && jobsCompleted == 0 { // empty batch
-		targetRunnerCount = max(w.lastPatch, targetRunnerCount)
-		if targetRunnerCount == w.config.MinRunners {
-			// We have an empty batch, and the last patch was the min runners.
-			// Since this is an empty batch, and we are at the min runners, they should all be idle.
-			// If controller created few more pods on accident (during scale down events),
-			// this situation allows the controller to scale down to the min runners.
-			// However, it is important to keep the patch sequence increasing so we don't ignore one batch.
-			desiredPatchID = 0
+
+	// An empty batch re-uses the assigned job count of the last batch, re-evaluated against the current
+	// bounds, since a scheduled override may have started or ended after the last batch.
+	if count != 0 || jobsCompleted != 0 {
+		w.lastAssigned = count
+	}
+	if predicted > weighted {
+		// Runners are provisioned ahead of the demand forecast from the previous days.
+		targetRunnerCount = min(minRunners+predicted, maxRunners)
+	}
+	if w.fallback {
+		bounds.fallback = true
+		if w.config.FallbackReplicas != nil {
+			targetRunnerCount = min(max(*w.config.FallbackReplicas, minRunners), maxRunners)
 		}
 	}
+	if bounds.budget {
+		// Scale-ups are capped at the min runners until the budget is renewed the next day.
+		targetRunnerCount = min(targetRunnerCount, minRunners)
+	}
+	if capped, ok := w.capPendingScaleUp(targetRunnerCount, minRunners); ok {
+		bounds.pending = true
+		targetRunnerCount = capped
+	}
+	if replicas, ok := w.forcedReplicas(w.now()); ok {
+		bounds.forced = true
+		targetRunnerCount = min(replicas, maxRunners)
+	}
+	unlimitedTarget := targetRunnerCount
+	if !bounds.forced {
+		targetRunnerCount = w.limitScaleStep(targetRunnerCount)
+	}
 
 	w.lastPatch = targetRunnerCount
+	w.recordDecision(Decision{
+		Time:          w.now(),
+		AssignedJobs:  count,
+		JobsCompleted: jobsCompleted,
+		Target:        unlimitedTarget,
+		Replicas:      targetRunnerCount,
+		PatchID:       w.patchSeq,
+		Predicted:     predicted,
+		Weighted:      weighted,
+		Reason:        scaleReason(bounds, weighted, predicted, unlimitedTarget, targetRunnerCount),
+		Schedule:      bounds.schedule,
+		Clamps:        scaleClamps(bounds, max(weighted, predicted), unlimitedTarget, targetRunnerCount),
+	})
 
 	w.logger.Info(
 		"Calculated target runner count",
 		"assigned job", count,
 		"decision", targetRunnerCount,
-		"min", w.config.MinRunners,
-		"max", w.config.MaxRunners,
+		"target", unlimitedTarget,
+		"min", minRunners,
+		"max", maxRunners,
 		"currentRunnerCount", w.lastPatch,
 		"jobsCompleted", jobsCompleted,
+		"predicted", predicted,
+		"weighted", weighted,
+		"runningJobs", w.jobCounts.Running,
+		"waitingForRunner", w.jobCounts.WaitingForRunner(),
+		"pendingAssignment", w.jobCounts.Acquired,
 	)
+}
+
+// SetRunnerLimits replaces the min and max runners of the config at runtime. They apply from the next patch,
+// and the scheduled overrides keep precedence over them.
+func (w *Worker) SetRunnerLimits(minRunners, maxRunners int) {
+	w.mu.Lock()
+	changed := w.config.MinRunners != minRunners || w.config.MaxRunners != maxRunners
+	w.config.MinRunners, w.config.MaxRunners = minRunners, maxRunners
+	w.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	w.logger.Info("Runner limits changed", "minRunners", minRunners, "maxRunners", maxRunners)
+	w.metrics.PublishStatic(minRunners, maxRunners)
+}
+
+// limitScaleStep caps the difference between the target runner count and the last patch
+// to the configured MaxScaleUpStep and MaxScaleDownStep.
+// The first patch is not limited since the current replica count is not known yet.
+func (w *Worker) limitScaleStep(targetRunnerCount int) int {
+	if w.lastPatch < 0 {
+		return targetRunnerCount
+	}
+
+	if w.config.MaxScaleUpStep > 0 && targetRunnerCount > w.lastPatch+w.config.MaxScaleUpStep {
+		return w.lastPatch + w.config.MaxScaleUpStep
+	}
+
+	if w.config.MaxScaleDownStep > 0 && targetRunnerCount < w.lastPatch-w.config.MaxScaleDownStep {
+		return w.lastPatch - w.config.MaxScaleDownStep
+	}
 
-	return desiredPatchID
+	return targetRunnerCount
 }
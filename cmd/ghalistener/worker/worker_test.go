@@ -1,9 +1,12 @@
 package worker
 
 import (
+	"context"
 	"math"
 	"testing"
 
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/metrics"
+	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 )
@@ -24,7 +27,8 @@ func TestSetDesiredWorkerState_MinMaxDefaults(t *testing.T) {
 
 	t.Run("init calculate with acquired 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(0, 0)
+		w.setDesiredWorkerState(0, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
 		assert.Equal(t, 0, patchID)
@@ -32,7 +36,8 @@ func TestSetDesiredWorkerState_MinMaxDefaults(t *testing.T) {
 
 	t.Run("init calculate with acquired 1", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(1, 0)
+		w.setDesiredWorkerState(1, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
 		assert.Equal(t, 0, patchID)
@@ -40,9 +45,11 @@ func TestSetDesiredWorkerState_MinMaxDefaults(t *testing.T) {
 
 	t.Run("increment patch when job done", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(1, 0)
+		w.setDesiredWorkerState(1, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 1)
+		w.setDesiredWorkerState(0, 1)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 0, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -50,9 +57,11 @@ func TestSetDesiredWorkerState_MinMaxDefaults(t *testing.T) {
 
 	t.Run("increment patch when called with same parameters", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(1, 0)
+		w.setDesiredWorkerState(1, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(1, 0)
+		w.setDesiredWorkerState(1, 0)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -60,7 +69,8 @@ func TestSetDesiredWorkerState_MinMaxDefaults(t *testing.T) {
 
 	t.Run("calculate desired scale when acquired > 0 and completed > 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(1, 1)
+		w.setDesiredWorkerState(1, 1)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
@@ -68,9 +78,11 @@ func TestSetDesiredWorkerState_MinMaxDefaults(t *testing.T) {
 
 	t.Run("re-use the last state when acquired == 0 and completed == 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(1, 0)
+		w.setDesiredWorkerState(1, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 0)
+		w.setDesiredWorkerState(0, 0)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -78,9 +90,11 @@ func TestSetDesiredWorkerState_MinMaxDefaults(t *testing.T) {
 
 	t.Run("adjust when acquired == 0 and completed == 1", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(1, 1)
+		w.setDesiredWorkerState(1, 1)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 1)
+		w.setDesiredWorkerState(0, 1)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 0, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -103,7 +117,8 @@ func TestSetDesiredWorkerState_MinSet(t *testing.T) {
 
 	t.Run("initial scale when acquired == 0 and completed == 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(0, 0)
+		w.setDesiredWorkerState(0, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
@@ -111,9 +126,11 @@ func TestSetDesiredWorkerState_MinSet(t *testing.T) {
 
 	t.Run("re-use the old state on count == 0 and completed == 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(2, 0)
+		w.setDesiredWorkerState(2, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 0)
+		w.setDesiredWorkerState(0, 0)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 3, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -121,29 +138,35 @@ func TestSetDesiredWorkerState_MinSet(t *testing.T) {
 
 	t.Run("request back to 0 on job done", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(2, 0)
+		w.setDesiredWorkerState(2, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 1)
+		w.setDesiredWorkerState(0, 1)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
 	})
 
-	t.Run("desired patch is 0 but sequence continues on empty batch and min runners", func(t *testing.T) {
+	t.Run("sequence continues on empty batch and min runners", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(3, 0)
+		w.setDesiredWorkerState(3, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 4, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
 
-		patchID = w.setDesiredWorkerState(0, 3)
+		w.setDesiredWorkerState(0, 3)
+
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
 
-		// Empty batch on min runners
-		patchID = w.setDesiredWorkerState(0, 0)
-		assert.Equal(t, 0, patchID) // forcing the state
+		// Empty batch on min runners, resynced by HandleDesiredRunnerCount
+		w.setDesiredWorkerState(0, 0)
+		patchID = w.lastDecision().PatchID
+		assert.Equal(t, 2, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 2, w.patchSeq)
 	})
@@ -166,7 +189,8 @@ func TestSetDesiredWorkerState_MaxSet(t *testing.T) {
 
 	t.Run("initial scale when acquired == 0 and completed == 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(0, 0)
+		w.setDesiredWorkerState(0, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 0, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
@@ -174,9 +198,11 @@ func TestSetDesiredWorkerState_MaxSet(t *testing.T) {
 
 	t.Run("re-use the old state on count == 0 and completed == 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(2, 0)
+		w.setDesiredWorkerState(2, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 0)
+		w.setDesiredWorkerState(0, 0)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 2, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -184,9 +210,11 @@ func TestSetDesiredWorkerState_MaxSet(t *testing.T) {
 
 	t.Run("request back to 0 on job done", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(2, 0)
+		w.setDesiredWorkerState(2, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 1)
+		w.setDesiredWorkerState(0, 1)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 0, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -194,7 +222,8 @@ func TestSetDesiredWorkerState_MaxSet(t *testing.T) {
 
 	t.Run("scale up to max when count > max", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(6, 0)
+		w.setDesiredWorkerState(6, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 5, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
@@ -209,9 +238,11 @@ func TestSetDesiredWorkerState_MaxSet(t *testing.T) {
 
 	t.Run("scale to max when count > max and completed > 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(1, 0)
+		w.setDesiredWorkerState(1, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(6, 1)
+		w.setDesiredWorkerState(6, 1)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 5, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -219,29 +250,35 @@ func TestSetDesiredWorkerState_MaxSet(t *testing.T) {
 
 	t.Run("scale back to 0 when count was > max", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(6, 0)
+		w.setDesiredWorkerState(6, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 1)
+		w.setDesiredWorkerState(0, 1)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 0, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
 	})
 
-	t.Run("force 0 on empty batch and last patch == min runners", func(t *testing.T) {
+	t.Run("sequence continues on empty batch and last patch == min runners", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(3, 0)
+		w.setDesiredWorkerState(3, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 3, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
 
-		patchID = w.setDesiredWorkerState(0, 3)
+		w.setDesiredWorkerState(0, 3)
+
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 0, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
 
-		// Empty batch on min runners
-		patchID = w.setDesiredWorkerState(0, 0)
-		assert.Equal(t, 0, patchID) // forcing the state
+		// Empty batch on min runners, resynced by HandleDesiredRunnerCount
+		w.setDesiredWorkerState(0, 0)
+		patchID = w.lastDecision().PatchID
+		assert.Equal(t, 2, patchID)
 		assert.Equal(t, 0, w.lastPatch)
 		assert.Equal(t, 2, w.patchSeq)
 	})
@@ -263,7 +300,8 @@ func TestSetDesiredWorkerState_MinMaxSet(t *testing.T) {
 
 	t.Run("initial scale when acquired == 0 and completed == 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(0, 0)
+		w.setDesiredWorkerState(0, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
@@ -271,9 +309,11 @@ func TestSetDesiredWorkerState_MinMaxSet(t *testing.T) {
 
 	t.Run("re-use the old state on count == 0 and completed == 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(2, 0)
+		w.setDesiredWorkerState(2, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 0)
+		w.setDesiredWorkerState(0, 0)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 3, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -281,9 +321,11 @@ func TestSetDesiredWorkerState_MinMaxSet(t *testing.T) {
 
 	t.Run("scale to min when count == 0", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(2, 0)
+		w.setDesiredWorkerState(2, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
-		patchID = w.setDesiredWorkerState(0, 1)
+		w.setDesiredWorkerState(0, 1)
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
@@ -291,7 +333,8 @@ func TestSetDesiredWorkerState_MinMaxSet(t *testing.T) {
 
 	t.Run("scale up to max when count > max", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(4, 0)
+		w.setDesiredWorkerState(4, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 3, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
@@ -299,28 +342,152 @@ func TestSetDesiredWorkerState_MinMaxSet(t *testing.T) {
 
 	t.Run("scale to max when count == max", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(3, 0)
+		w.setDesiredWorkerState(3, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 3, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
 	})
 
-	t.Run("force 0 on empty batch and last patch == min runners", func(t *testing.T) {
+	t.Run("sequence continues on empty batch and last patch == min runners", func(t *testing.T) {
 		w := newEmptyWorker()
-		patchID := w.setDesiredWorkerState(3, 0)
+		w.setDesiredWorkerState(3, 0)
+		patchID := w.lastDecision().PatchID
 		assert.Equal(t, 0, patchID)
 		assert.Equal(t, 3, w.lastPatch)
 		assert.Equal(t, 0, w.patchSeq)
 
-		patchID = w.setDesiredWorkerState(0, 3)
+		w.setDesiredWorkerState(0, 3)
+
+		patchID = w.lastDecision().PatchID
 		assert.Equal(t, 1, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 1, w.patchSeq)
 
-		// Empty batch on min runners
-		patchID = w.setDesiredWorkerState(0, 0)
-		assert.Equal(t, 0, patchID) // forcing the state
+		// Empty batch on min runners, resynced by HandleDesiredRunnerCount
+		w.setDesiredWorkerState(0, 0)
+		patchID = w.lastDecision().PatchID
+		assert.Equal(t, 2, patchID)
 		assert.Equal(t, 1, w.lastPatch)
 		assert.Equal(t, 2, w.patchSeq)
 	})
 }
+
+func TestHandleJobCompleted_WithoutGracePeriod(t *testing.T) {
+	logger := logr.Discard()
+	w := &Worker{
+		config: Config{
+			MaxRunners: math.MaxInt32,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+	}
+
+	// The worker has no client, so any attempt to patch the ephemeral runner would panic.
+	err := w.HandleJobCompleted(context.Background(), &actions.JobCompleted{RunnerName: "runner"})
+	assert.NoError(t, err)
+}
+
+func TestSetDesiredWorkerState_ScaleStepLimits(t *testing.T) {
+	logger := logr.Discard()
+	newEmptyWorker := func() *Worker {
+		return &Worker{
+			config: Config{
+				MinRunners:       0,
+				MaxRunners:       math.MaxInt32,
+				MaxScaleUpStep:   5,
+				MaxScaleDownStep: 3,
+			},
+			lastPatch: -1,
+			patchSeq:  -1,
+			logger:    &logger,
+		}
+	}
+
+	t.Run("first patch is not limited", func(t *testing.T) {
+		w := newEmptyWorker()
+		w.setDesiredWorkerState(100, 0)
+		assert.Equal(t, 100, w.lastPatch)
+	})
+
+	t.Run("limit scale up", func(t *testing.T) {
+		w := newEmptyWorker()
+		w.setDesiredWorkerState(0, 0)
+		assert.Equal(t, 0, w.lastPatch)
+		w.setDesiredWorkerState(100, 0)
+		assert.Equal(t, 5, w.lastPatch)
+		assert.Equal(t, 100, w.lastAssigned)
+		assert.Equal(t, ScaleReasonScaleStepLimited, w.lastDecision().Reason)
+	})
+
+	t.Run("keep scaling up towards the target on empty batches", func(t *testing.T) {
+		w := newEmptyWorker()
+		w.setDesiredWorkerState(0, 0)
+		w.setDesiredWorkerState(12, 0)
+		assert.Equal(t, 5, w.lastPatch)
+		w.setDesiredWorkerState(0, 0)
+		assert.Equal(t, 10, w.lastPatch)
+		w.setDesiredWorkerState(0, 0)
+		assert.Equal(t, 12, w.lastPatch)
+		w.setDesiredWorkerState(0, 0)
+		assert.Equal(t, 12, w.lastPatch)
+	})
+
+	t.Run("limit scale down", func(t *testing.T) {
+		w := newEmptyWorker()
+		w.setDesiredWorkerState(10, 0)
+		assert.Equal(t, 10, w.lastPatch)
+		w.setDesiredWorkerState(0, 10)
+		assert.Equal(t, 7, w.lastPatch)
+		w.setDesiredWorkerState(0, 1)
+		assert.Equal(t, 4, w.lastPatch)
+	})
+
+	t.Run("scale down to the min runners in steps", func(t *testing.T) {
+		w := newEmptyWorker()
+		w.setDesiredWorkerState(8, 0)
+		w.setDesiredWorkerState(0, 8)
+		patchID := w.lastDecision().PatchID
+		assert.Equal(t, 5, w.lastPatch)
+		assert.Equal(t, 1, patchID)
+		w.setDesiredWorkerState(0, 0)
+		patchID = w.lastDecision().PatchID
+		assert.Equal(t, 2, w.lastPatch)
+		assert.Equal(t, 2, patchID)
+		w.setDesiredWorkerState(0, 0)
+		patchID = w.lastDecision().PatchID
+		assert.Equal(t, 0, w.lastPatch)
+		assert.Equal(t, 3, patchID)
+	})
+}
+
+func TestSetRunnerLimits(t *testing.T) {
+	logger := logr.Discard()
+	w := &Worker{
+		config: Config{
+			MinRunners: 2,
+			MaxRunners: 10,
+		},
+		lastPatch: -1,
+		patchSeq:  -1,
+		logger:    &logger,
+		metrics:   metrics.Discard,
+	}
+
+	w.setDesiredWorkerState(5, 0)
+	assert.Equal(t, 7, w.lastPatch)
+
+	// Scaling everything down during an incident.
+	w.SetRunnerLimits(0, 0)
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 0, w.lastPatch, "the assigned jobs of the last batch are re-evaluated against the new limits")
+
+	state := w.State()
+	assert.Equal(t, 0, state.MinRunners)
+	assert.Equal(t, 0, state.MaxRunners)
+
+	w.SetRunnerLimits(1, 4)
+	w.setDesiredWorkerState(0, 0)
+	assert.Equal(t, 4, w.lastPatch)
+}
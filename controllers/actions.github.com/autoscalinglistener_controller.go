@@ -17,8 +17,10 @@ limitations under the License.
 package actionsgithubcom
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -47,6 +49,10 @@ const (
 	autoscalingListenerFinalizerName = "autoscalinglistener.actions.github.com/finalizer"
 )
 
+// idleListenerWakeUpInterval is the interval at which the acquirable jobs of the scale set of an idle listener
+// are checked, to recreate the listener pod once there are jobs to run.
+const idleListenerWakeUpInterval = 30 * time.Second
+
 // AutoscalingListenerReconciler reconciles a AutoscalingListener object
 type AutoscalingListenerReconciler struct {
 	client.Client
@@ -210,6 +216,13 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 
 	// TODO: make sure the role binding has the up-to-date role and service account
 
+	if autoscalingListener.Spec.VaultConfig == nil {
+		if err := r.updateListenerCredentials(ctx, autoscalingListener, appConfig, log); err != nil {
+			log.Error(err, "Unable to update the credentials of the listener config secret")
+			return ctrl.Result{}, err
+		}
+	}
+
 	listenerPod := new(corev1.Pod)
 	if err := r.Get(
 		ctx,
@@ -249,6 +262,8 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 	case cs == nil:
 		log.Info("Listener pod is not ready", "namespace", listenerPod.Namespace, "name", listenerPod.Name)
 		return ctrl.Result{}, nil
+	case listenerIdle(cs):
+		return r.wakeIdleListener(ctx, &autoscalingRunnerSet, autoscalingListener, listenerPod, log)
 	case cs.State.Terminated != nil:
 		log.Info(
 			"Listener pod is terminated",
@@ -256,6 +271,7 @@ func (r *AutoscalingListenerReconciler) Reconcile(ctx context.Context, req ctrl.
 			"name", listenerPod.Name,
 			"reason", cs.State.Terminated.Reason,
 			"message", cs.State.Terminated.Message,
+			"exitCode", cs.State.Terminated.ExitCode,
 		)
 
 		return ctrl.Result{}, r.deleteListenerPod(ctx, autoscalingListener, listenerPod, log)
@@ -302,6 +318,73 @@ func (r *AutoscalingListenerReconciler) deleteListenerPod(ctx context.Context, a
 	return nil
 }
 
+// listenerIdle reports whether the listener container exited successfully after the scale set was idle.
+func listenerIdle(cs *corev1.ContainerStatus) bool {
+	terminated := cs.State.Terminated
+	return terminated != nil &&
+		terminated.ExitCode == 0 &&
+		strings.TrimSpace(terminated.Message) == v1alpha1.AutoscalingListenerIdleTerminationMessage
+}
+
+// wakeIdleListener keeps the pod of a listener that exited after the scale set was idle until the scale set
+// has acquirable jobs, then deletes it so that the listener pod is recreated.
+func (r *AutoscalingListenerReconciler) wakeIdleListener(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, autoscalingListener *v1alpha1.AutoscalingListener, listenerPod *corev1.Pod, log logr.Logger) (ctrl.Result, error) {
+	actionsClient, err := r.GetActionsService(ctx, autoscalingRunnerSet)
+	if err != nil {
+		log.Error(err, "Failed to get the actions client to check the acquirable jobs of the idle listener")
+		return ctrl.Result{}, err
+	}
+
+	jobs, err := actionsClient.GetAcquirableJobs(ctx, autoscalingListener.Spec.RunnerScaleSetId)
+	if err != nil {
+		log.Error(err, "Failed to get the acquirable jobs of the idle listener", "runnerScaleSetId", autoscalingListener.Spec.RunnerScaleSetId)
+		return ctrl.Result{}, err
+	}
+
+	if jobs.Count == 0 {
+		if err := r.publishRunningListener(autoscalingListener, false); err != nil {
+			log.Error(err, "Unable to publish runner listener down metric", "namespace", listenerPod.Namespace, "name", listenerPod.Name)
+		}
+		log.Info("Listener is idle, waiting for acquirable jobs", "namespace", listenerPod.Namespace, "name", listenerPod.Name)
+		return ctrl.Result{RequeueAfter: idleListenerWakeUpInterval}, nil
+	}
+
+	log.Info("Jobs are acquirable, recreating the idle listener pod", "namespace", listenerPod.Namespace, "name", listenerPod.Name, "count", jobs.Count)
+	return ctrl.Result{}, r.deleteListenerPod(ctx, autoscalingListener, listenerPod, log)
+}
+
+// updateListenerCredentials updates the GitHub credentials of the listener config secret, if it exists, once they
+// are rotated in the GitHub config secret, which the periodic reconciles of the listener pick up. The listener
+// re-reads them from the files of the mounted secret, so it is not restarted.
+func (r *AutoscalingListenerReconciler) updateListenerCredentials(ctx context.Context, autoscalingListener *v1alpha1.AutoscalingListener, appConfig *appconfig.AppConfig, logger logr.Logger) error {
+	var configSecret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: autoscalingListener.Namespace, Name: scaleSetListenerConfigName(autoscalingListener)}, &configSecret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	_, credentials := scaleSetListenerCredentials(appConfig)
+	updated := false
+	for key, value := range credentials {
+		current, ok := configSecret.Data[key]
+		// A config secret without the key holds another kind of credentials, e.g. a token in place of a
+		// GitHub App, which the config of the listener refers to.
+		if !ok || bytes.Equal(current, value) {
+			continue
+		}
+		configSecret.Data[key] = value
+		updated = true
+	}
+	if !updated {
+		return nil
+	}
+
+	logger.Info("Updating the rotated credentials of the listener config secret", "namespace", configSecret.Namespace, "name", configSecret.Name)
+	if err := r.Update(ctx, &configSecret); err != nil {
+		return fmt.Errorf("failed to update listener config secret: %w", err)
+	}
+	return nil
+}
+
 func (r *AutoscalingListenerReconciler) cleanupResources(ctx context.Context, autoscalingListener *v1alpha1.AutoscalingListener, logger logr.Logger) (requeue bool, err error) {
 	logger.Info("Cleaning up the listener pod")
 	listenerPod := new(corev1.Pod)
@@ -6,15 +6,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	ghalistenerconfig "github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/github/actions/fake"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -1243,3 +1250,79 @@ var _ = Describe("Test GitHub Server TLS configuration", func() {
 		})
 	})
 })
+
+func TestListenerIdle(t *testing.T) {
+	terminated := func(exitCode int32, message string) *corev1.ContainerStatus {
+		return &corev1.ContainerStatus{
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, Message: message},
+			},
+		}
+	}
+
+	assert.True(t, listenerIdle(terminated(0, v1alpha1.AutoscalingListenerIdleTerminationMessage)))
+	assert.True(t, listenerIdle(terminated(0, v1alpha1.AutoscalingListenerIdleTerminationMessage+"\n")))
+	assert.False(t, listenerIdle(terminated(0, "")), "the listener stopped for another reason")
+	assert.False(t, listenerIdle(terminated(1, v1alpha1.AutoscalingListenerIdleTerminationMessage)), "the listener failed")
+	assert.False(t, listenerIdle(&corev1.ContainerStatus{
+		State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+	}))
+}
+
+func TestWakeIdleListener(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-asrs", Namespace: "arc-runners"},
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:    "https://github.com/owner/repo",
+			GitHubConfigSecret: "github-config",
+		},
+	}
+	autoscalingListener := &v1alpha1.AutoscalingListener{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-asl", Namespace: "arc-systems"},
+		Spec: v1alpha1.AutoscalingListenerSpec{
+			GitHubConfigUrl:  "https://github.com/owner/repo",
+			RunnerScaleSetId: 1,
+		},
+	}
+
+	newReconciler := func(jobs *actions.AcquirableJobList) (*AutoscalingListenerReconciler, *corev1.Pod) {
+		listenerPod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: autoscalingListener.Name, Namespace: autoscalingListener.Namespace},
+		}
+		k8sClient := crfake.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithObjects(
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "github-config", Namespace: "arc-runners"},
+					Data:       map[string][]byte{"github_token": []byte("token")},
+				},
+				listenerPod,
+			).
+			Build()
+		multiClient := fake.NewMultiClient(fake.WithDefaultClient(fake.NewFakeClient(fake.WithGetAcquirableJobs(jobs, nil)), nil))
+		return &AutoscalingListenerReconciler{
+			Client:          k8sClient,
+			Log:             logr.Discard(),
+			ResourceBuilder: ResourceBuilder{SecretResolver: NewSecretResolver(k8sClient, multiClient)},
+		}, listenerPod
+	}
+
+	t.Run("no acquirable jobs", func(t *testing.T) {
+		r, listenerPod := newReconciler(&actions.AcquirableJobList{Count: 0})
+
+		result, err := r.wakeIdleListener(context.Background(), autoscalingRunnerSet, autoscalingListener, listenerPod, logr.Discard())
+		require.NoError(t, err)
+		assert.Equal(t, idleListenerWakeUpInterval, result.RequeueAfter)
+		assert.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(listenerPod), new(corev1.Pod)), "the idle listener pod is kept")
+	})
+
+	t.Run("acquirable jobs", func(t *testing.T) {
+		r, listenerPod := newReconciler(&actions.AcquirableJobList{Count: 1, Jobs: []actions.AcquirableJob{{RunnerRequestId: 1}}})
+
+		result, err := r.wakeIdleListener(context.Background(), autoscalingRunnerSet, autoscalingListener, listenerPod, logr.Discard())
+		require.NoError(t, err)
+		assert.Zero(t, result.RequeueAfter)
+		err = r.Get(context.Background(), client.ObjectKeyFromObject(listenerPod), new(corev1.Pod))
+		assert.True(t, kerrors.IsNotFound(err), "the idle listener pod is deleted to be recreated")
+	})
+}
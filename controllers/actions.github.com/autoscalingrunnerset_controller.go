@@ -29,9 +29,11 @@ import (
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -79,6 +81,10 @@ type AutoscalingRunnerSetReconciler struct {
 	DefaultRunnerScaleSetListenerImagePullSecrets []string
 	UpdateStrategy                                UpdateStrategy
 	ActionsClient                                 actions.MultiClient
+	Recorder                                      record.EventRecorder
+
+	runnerGroupChecks runnerGroupChecks
+
 	ResourceBuilder
 }
 
@@ -89,6 +95,7 @@ type AutoscalingRunnerSetReconciler struct {
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalinglisteners,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.github.com,resources=autoscalinglisteners/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile a AutoscalingRunnerSet resource to meet its desired spec.
 func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -195,11 +202,23 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 
 	// Make sure the runner group of the scale set is up to date
 	currentRunnerGroupName, ok := autoscalingRunnerSet.Annotations[AnnotationKeyGitHubRunnerGroupName]
-	if !ok || (len(autoscalingRunnerSet.Spec.RunnerGroup) > 0 && !strings.EqualFold(currentRunnerGroupName, autoscalingRunnerSet.Spec.RunnerGroup)) {
+	if !ok || runnerGroupOutOfDate(autoscalingRunnerSet, currentRunnerGroupName) {
 		log.Info("AutoScalingRunnerSet runner group changed. Updating the runner scale set.")
 		return r.updateRunnerScaleSetRunnerGroup(ctx, autoscalingRunnerSet, log)
 	}
 
+	// Fail over to the secondary runner group when the runner group becomes unavailable, and back
+	if r.hasSecondaryRunnerGroup(autoscalingRunnerSet) && r.runnerGroupChecks.due(autoscalingRunnerSet) {
+		moved, err := r.checkRunnerGroupFailover(ctx, autoscalingRunnerSet, currentRunnerGroupName, log)
+		if err != nil {
+			// Keep reconciling the runner scale set in its current runner group, the check is retried on the next reconcile.
+			log.Error(err, "Failed to check the runner group for failover")
+		}
+		if moved {
+			return ctrl.Result{}, nil
+		}
+	}
+
 	// Make sure the runner scale set name is up to date
 	currentRunnerScaleSetName, ok := autoscalingRunnerSet.Annotations[AnnotationKeyGitHubRunnerScaleSetName]
 	if !ok || (len(autoscalingRunnerSet.Spec.RunnerScaleSetName) > 0 && !strings.EqualFold(currentRunnerScaleSetName, autoscalingRunnerSet.Spec.RunnerScaleSetName)) {
@@ -282,6 +301,17 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		}
 	}
 
+	// The scale down rate is not part of the runner spec hash, so it is applied to the runner set in place.
+	if !equality.Semantic.DeepEqual(latestRunnerSet.Spec.ScaleDownRate, autoscalingRunnerSet.Spec.ScaleDownRate) {
+		log.Info("Updating the scale down rate of the latest runner set", "name", latestRunnerSet.Name)
+		if err := patch(ctx, r.Client, latestRunnerSet, func(obj *v1alpha1.EphemeralRunnerSet) {
+			obj.Spec.ScaleDownRate = autoscalingRunnerSet.Spec.ScaleDownRate.DeepCopy()
+		}); err != nil {
+			log.Error(err, "Failed to update the scale down rate of the latest runner set")
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Make sure the AutoscalingListener is up and running in the controller namespace
 	if !listenerFound {
 		if r.drainingJobs(&latestRunnerSet.Status) {
@@ -305,9 +335,17 @@ func (r *AutoscalingRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl
 		}
 	}
 
+	if r.hasSecondaryRunnerGroup(autoscalingRunnerSet) {
+		return ctrl.Result{RequeueAfter: runnerGroupCheckInterval}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
+func (r *AutoscalingRunnerSetReconciler) hasSecondaryRunnerGroup(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) bool {
+	return len(autoscalingRunnerSet.Spec.RunnerGroup) > 0 && len(autoscalingRunnerSet.Spec.SecondaryRunnerGroup) > 0
+}
+
 // Prevents overprovisioning of runners.
 // We reach this code path when runner scale set has been patched with a new runner spec but there are still running ephemeral runners.
 // The safest approach is to wait for the running ephemeral runners to finish before creating a new runner set.
@@ -403,15 +441,9 @@ func (r *AutoscalingRunnerSetReconciler) createRunnerScaleSet(ctx context.Contex
 		return ctrl.Result{}, err
 	}
 
-	runnerGroupID := 1
-	if len(autoscalingRunnerSet.Spec.RunnerGroup) > 0 {
-		runnerGroup, err := actionsClient.GetRunnerGroupByName(ctx, autoscalingRunnerSet.Spec.RunnerGroup)
-		if err != nil {
-			logger.Error(err, "Failed to get runner group by name", "runnerGroup", autoscalingRunnerSet.Spec.RunnerGroup)
-			return ctrl.Result{}, err
-		}
-
-		runnerGroupID = int(runnerGroup.ID)
+	runnerGroupID, _, failover, err := resolveRunnerGroup(ctx, actionsClient, autoscalingRunnerSet, logger)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
 	runnerScaleSet, err := actionsClient.GetRunnerScaleSet(ctx, runnerGroupID, autoscalingRunnerSet.Spec.RunnerScaleSetName)
@@ -480,6 +512,10 @@ func (r *AutoscalingRunnerSetReconciler) createRunnerScaleSet(ctx context.Contex
 		"id", runnerScaleSet.Id,
 		"name", runnerScaleSet.Name,
 		"runnerGroupName", runnerScaleSet.RunnerGroupName)
+	if r.hasSecondaryRunnerGroup(autoscalingRunnerSet) {
+		r.runnerGroupChecks.checked(autoscalingRunnerSet)
+		r.recordRunnerGroupChange(autoscalingRunnerSet, "", runnerScaleSet.RunnerGroupName, failover)
+	}
 	return ctrl.Result{}, nil
 }
 
@@ -496,15 +532,10 @@ func (r *AutoscalingRunnerSetReconciler) updateRunnerScaleSetRunnerGroup(ctx con
 		return ctrl.Result{}, err
 	}
 
-	runnerGroupID := 1
-	if len(autoscalingRunnerSet.Spec.RunnerGroup) > 0 {
-		runnerGroup, err := actionsClient.GetRunnerGroupByName(ctx, autoscalingRunnerSet.Spec.RunnerGroup)
-		if err != nil {
-			logger.Error(err, "Failed to get runner group by name", "runnerGroup", autoscalingRunnerSet.Spec.RunnerGroup)
-			return ctrl.Result{}, err
-		}
-
-		runnerGroupID = int(runnerGroup.ID)
+	previousRunnerGroupName := autoscalingRunnerSet.Annotations[AnnotationKeyGitHubRunnerGroupName]
+	runnerGroupID, _, failover, err := resolveRunnerGroup(ctx, actionsClient, autoscalingRunnerSet, logger)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
 	updatedRunnerScaleSet, err := actionsClient.UpdateRunnerScaleSet(ctx, runnerScaleSetID, &actions.RunnerScaleSet{RunnerGroupId: runnerGroupID})
@@ -523,6 +554,10 @@ func (r *AutoscalingRunnerSetReconciler) updateRunnerScaleSetRunnerGroup(ctx con
 	}
 
 	logger.Info("Updated runner scale set with match runner group", "runnerGroup", updatedRunnerScaleSet.RunnerGroupName)
+	if r.hasSecondaryRunnerGroup(autoscalingRunnerSet) {
+		r.runnerGroupChecks.checked(autoscalingRunnerSet)
+		r.recordRunnerGroupChange(autoscalingRunnerSet, previousRunnerGroupName, updatedRunnerScaleSet.RunnerGroupName, failover)
+	}
 	return ctrl.Result{}, nil
 }
 
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"testing"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -25,6 +26,11 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/build"
@@ -38,6 +44,119 @@ const (
 	autoscalingRunnerSetTestInterval = time.Millisecond * 250
 )
 
+func TestResolveRunnerGroup(t *testing.T) {
+	ctx := context.Background()
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			RunnerGroup:          "primary",
+			SecondaryRunnerGroup: "secondary",
+		},
+	}
+	notFound := &actions.ActionsError{StatusCode: http.StatusOK, Err: fmt.Errorf("%w with name %q", actions.ErrRunnerGroupNotFound, "primary")}
+
+	t.Run("primary available", func(t *testing.T) {
+		client := actions.NewMockActionsService(t)
+		client.On("GetRunnerGroupByName", mock.Anything, "primary").Return(&actions.RunnerGroup{ID: 2, Name: "primary"}, nil).Once()
+
+		id, name, failover, err := resolveRunnerGroup(ctx, client, autoscalingRunnerSet, logr.Discard())
+		require.NoError(t, err)
+		assert.Equal(t, 2, id)
+		assert.Equal(t, "primary", name)
+		assert.False(t, failover)
+	})
+
+	t.Run("primary removed", func(t *testing.T) {
+		client := actions.NewMockActionsService(t)
+		client.On("GetRunnerGroupByName", mock.Anything, "primary").Return(nil, notFound).Once()
+		client.On("GetRunnerGroupByName", mock.Anything, "secondary").Return(&actions.RunnerGroup{ID: 3, Name: "secondary"}, nil).Once()
+
+		id, name, failover, err := resolveRunnerGroup(ctx, client, autoscalingRunnerSet, logr.Discard())
+		require.NoError(t, err)
+		assert.Equal(t, 3, id)
+		assert.Equal(t, "secondary", name)
+		assert.True(t, failover)
+	})
+
+	t.Run("primary forbidden", func(t *testing.T) {
+		client := actions.NewMockActionsService(t)
+		client.On("GetRunnerGroupByName", mock.Anything, "primary").Return(nil, fmt.Errorf("unexpected status code: %w", &actions.ActionsError{StatusCode: http.StatusForbidden})).Once()
+		client.On("GetRunnerGroupByName", mock.Anything, "secondary").Return(&actions.RunnerGroup{ID: 3, Name: "secondary"}, nil).Once()
+
+		_, _, failover, err := resolveRunnerGroup(ctx, client, autoscalingRunnerSet, logr.Discard())
+		require.NoError(t, err)
+		assert.True(t, failover)
+	})
+
+	t.Run("transient errors do not fail over", func(t *testing.T) {
+		client := actions.NewMockActionsService(t)
+		client.On("GetRunnerGroupByName", mock.Anything, "primary").Return(nil, &actions.ActionsError{StatusCode: http.StatusServiceUnavailable}).Once()
+
+		_, _, _, err := resolveRunnerGroup(ctx, client, autoscalingRunnerSet, logr.Discard())
+		require.Error(t, err)
+	})
+
+	t.Run("without secondary runner group", func(t *testing.T) {
+		client := actions.NewMockActionsService(t)
+		client.On("GetRunnerGroupByName", mock.Anything, "primary").Return(nil, notFound).Once()
+
+		primaryOnly := autoscalingRunnerSet.DeepCopy()
+		primaryOnly.Spec.SecondaryRunnerGroup = ""
+		_, _, _, err := resolveRunnerGroup(ctx, client, primaryOnly, logr.Discard())
+		require.ErrorIs(t, err, actions.ErrRunnerGroupNotFound)
+	})
+}
+
+func TestRunnerGroupOutOfDate(t *testing.T) {
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{RunnerGroup: "primary"},
+	}
+	assert.False(t, runnerGroupOutOfDate(autoscalingRunnerSet, "Primary"))
+	assert.True(t, runnerGroupOutOfDate(autoscalingRunnerSet, "secondary"))
+
+	autoscalingRunnerSet.Spec.SecondaryRunnerGroup = "secondary"
+	assert.False(t, runnerGroupOutOfDate(autoscalingRunnerSet, "secondary"))
+	assert.True(t, runnerGroupOutOfDate(autoscalingRunnerSet, "other"))
+}
+
+func TestRecordRunnerGroupChange(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &AutoscalingRunnerSetReconciler{Recorder: recorder}
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{
+		Spec: v1alpha1.AutoscalingRunnerSetSpec{
+			GitHubConfigUrl:      "https://github.com/owner/repo",
+			RunnerGroup:          "primary",
+			SecondaryRunnerGroup: "secondary",
+		},
+	}
+
+	r.recordRunnerGroupChange(autoscalingRunnerSet, "primary", "secondary", true)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning "+reasonRunnerGroupFailover)
+
+	// already failed over
+	r.recordRunnerGroupChange(autoscalingRunnerSet, "secondary", "secondary", true)
+	assert.Empty(t, recorder.Events)
+
+	r.recordRunnerGroupChange(autoscalingRunnerSet, "secondary", "primary", false)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Normal "+reasonRunnerGroupFailback)
+
+	r.recordRunnerGroupChange(autoscalingRunnerSet, "primary", "primary", false)
+	assert.Empty(t, recorder.Events)
+}
+
+func TestRunnerGroupChecks(t *testing.T) {
+	var checks runnerGroupChecks
+	autoscalingRunnerSet := &v1alpha1.AutoscalingRunnerSet{ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "default"}}
+
+	assert.True(t, checks.due(autoscalingRunnerSet))
+	checks.checked(autoscalingRunnerSet)
+	assert.False(t, checks.due(autoscalingRunnerSet))
+
+	checks.last[client.ObjectKeyFromObject(autoscalingRunnerSet)] = time.Now().Add(-runnerGroupCheckInterval)
+	assert.True(t, checks.due(autoscalingRunnerSet))
+}
+
 var _ = Describe("Test AutoScalingRunnerSet controller", Ordered, func() {
 	var ctx context.Context
 	var mgr ctrl.Manager
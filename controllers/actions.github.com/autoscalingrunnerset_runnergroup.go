@@ -0,0 +1,162 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/controllers/actions.github.com/metrics"
+	"github.com/actions/actions-runner-controller/github/actions"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// runnerGroupCheckInterval is how often the runner group of a runner scale set with a secondary runner group
+// is checked, to fail over when it becomes unavailable and to fail back once it is available again.
+const runnerGroupCheckInterval = 5 * time.Minute
+
+// Event reasons of the runner group failover
+const (
+	reasonRunnerGroupFailover = "RunnerGroupFailover"
+	reasonRunnerGroupFailback = "RunnerGroupFailback"
+)
+
+// runnerGroupUnavailable reports whether err means the runner scale set cannot use the runner group,
+// because it was removed or the access to it is denied.
+func runnerGroupUnavailable(err error) bool {
+	if errors.Is(err, actions.ErrRunnerGroupNotFound) {
+		return true
+	}
+	var actionsErr *actions.ActionsError
+	if errors.As(err, &actionsErr) {
+		return actionsErr.StatusCode == http.StatusForbidden || actionsErr.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// resolveRunnerGroup returns the ID and name of the runner group to place the runner scale set in.
+// When the runner group is unavailable, it fails over to the secondary runner group, if configured.
+//
+// The name is empty when no runner group is configured, and the default runner group is used.
+func resolveRunnerGroup(ctx context.Context, actionsClient actions.ActionsService, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, logger logr.Logger) (id int, name string, failover bool, err error) {
+	if len(autoscalingRunnerSet.Spec.RunnerGroup) == 0 {
+		return 1, "", false, nil
+	}
+
+	runnerGroup, err := actionsClient.GetRunnerGroupByName(ctx, autoscalingRunnerSet.Spec.RunnerGroup)
+	if err == nil {
+		return int(runnerGroup.ID), runnerGroup.Name, false, nil
+	}
+	if len(autoscalingRunnerSet.Spec.SecondaryRunnerGroup) == 0 || !runnerGroupUnavailable(err) {
+		logger.Error(err, "Failed to get runner group by name", "runnerGroup", autoscalingRunnerSet.Spec.RunnerGroup)
+		return 0, "", false, err
+	}
+
+	logger.Info("Runner group is unavailable, failing over to the secondary runner group",
+		"runnerGroup", autoscalingRunnerSet.Spec.RunnerGroup,
+		"secondaryRunnerGroup", autoscalingRunnerSet.Spec.SecondaryRunnerGroup,
+		"reason", err.Error())
+	runnerGroup, err = actionsClient.GetRunnerGroupByName(ctx, autoscalingRunnerSet.Spec.SecondaryRunnerGroup)
+	if err != nil {
+		logger.Error(err, "Failed to get secondary runner group by name", "secondaryRunnerGroup", autoscalingRunnerSet.Spec.SecondaryRunnerGroup)
+		return 0, "", false, err
+	}
+
+	return int(runnerGroup.ID), runnerGroup.Name, true, nil
+}
+
+// runnerGroupOutOfDate reports whether the runner group of the runner scale set does not match the spec.
+// The secondary runner group is up to date as well, failing back is left to the periodic runner group check.
+func runnerGroupOutOfDate(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, currentRunnerGroupName string) bool {
+	if len(autoscalingRunnerSet.Spec.RunnerGroup) == 0 || strings.EqualFold(currentRunnerGroupName, autoscalingRunnerSet.Spec.RunnerGroup) {
+		return false
+	}
+	return len(autoscalingRunnerSet.Spec.SecondaryRunnerGroup) == 0 ||
+		!strings.EqualFold(currentRunnerGroupName, autoscalingRunnerSet.Spec.SecondaryRunnerGroup)
+}
+
+// checkRunnerGroupFailover moves the runner scale set to the secondary runner group when the runner group
+// became unavailable, or back to the runner group once it is available again. It returns whether it was moved.
+func (r *AutoscalingRunnerSetReconciler) checkRunnerGroupFailover(ctx context.Context, autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, currentRunnerGroupName string, logger logr.Logger) (bool, error) {
+	actionsClient, err := r.GetActionsService(ctx, autoscalingRunnerSet)
+	if err != nil {
+		return false, err
+	}
+
+	_, name, _, err := resolveRunnerGroup(ctx, actionsClient, autoscalingRunnerSet, logger)
+	if err != nil {
+		return false, err
+	}
+	r.runnerGroupChecks.checked(autoscalingRunnerSet)
+
+	if strings.EqualFold(name, currentRunnerGroupName) {
+		return false, nil
+	}
+
+	logger.Info("Runner group availability changed. Updating the runner scale set.", "currentRunnerGroup", currentRunnerGroupName, "runnerGroup", name)
+	if _, err := r.updateRunnerScaleSetRunnerGroup(ctx, autoscalingRunnerSet, logger); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// recordRunnerGroupChange emits an event, and counts the failover, when the runner scale set
+// is placed in the secondary runner group or moved back from it.
+func (r *AutoscalingRunnerSetReconciler) recordRunnerGroupChange(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet, previousRunnerGroupName, runnerGroupName string, failover bool) {
+	switch {
+	case failover && !strings.EqualFold(previousRunnerGroupName, runnerGroupName):
+		r.Recorder.Eventf(autoscalingRunnerSet, corev1.EventTypeWarning, reasonRunnerGroupFailover,
+			"Runner group %q is unavailable, the runner scale set failed over to the secondary runner group %q",
+			autoscalingRunnerSet.Spec.RunnerGroup, runnerGroupName)
+
+		commonLabels := metrics.CommonLabels{
+			Name:      autoscalingRunnerSet.Name,
+			Namespace: autoscalingRunnerSet.Namespace,
+		}
+		if parsedURL, err := actions.ParseGitHubConfigFromURL(autoscalingRunnerSet.Spec.GitHubConfigUrl); err == nil {
+			commonLabels.Repository = parsedURL.Repository
+			commonLabels.Organization = parsedURL.Organization
+			commonLabels.Enterprise = parsedURL.Enterprise
+		}
+		metrics.AddRunnerGroupFailover(commonLabels)
+
+	case !failover && len(autoscalingRunnerSet.Spec.SecondaryRunnerGroup) > 0 &&
+		strings.EqualFold(previousRunnerGroupName, autoscalingRunnerSet.Spec.SecondaryRunnerGroup) &&
+		!strings.EqualFold(previousRunnerGroupName, runnerGroupName):
+		r.Recorder.Eventf(autoscalingRunnerSet, corev1.EventTypeNormal, reasonRunnerGroupFailback,
+			"Runner group %q is available again, the runner scale set failed back from the secondary runner group %q",
+			runnerGroupName, previousRunnerGroupName)
+	}
+}
+
+// runnerGroupChecks remembers when the runner group of each runner scale set
+// with a secondary runner group was last checked.
+type runnerGroupChecks struct {
+	mu   sync.Mutex
+	last map[types.NamespacedName]time.Time
+}
+
+// due reports whether the runner group of the runner scale set should be checked again.
+func (c *runnerGroupChecks) due(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last, ok := c.last[client.ObjectKeyFromObject(autoscalingRunnerSet)]
+	return !ok || time.Since(last) >= runnerGroupCheckInterval
+}
+
+func (c *runnerGroupChecks) checked(autoscalingRunnerSet *v1alpha1.AutoscalingRunnerSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last == nil {
+		c.last = make(map[types.NamespacedName]time.Time)
+	}
+	c.last[client.ObjectKeyFromObject(autoscalingRunnerSet)] = time.Now()
+}
@@ -0,0 +1,158 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxNodeJobRepositories is the maximum number of repositories recorded on a node.
+	maxNodeJobRepositories = 10
+
+	// cacheLocalityAffinityWeight is the weight of the preferred node affinity term added to runner pods
+	// for the nodes with warm caches, leaving room for the preferences of the pod template.
+	cacheLocalityAffinityWeight = 50
+)
+
+// splitRepositories splits a comma separated list of repositories, ignoring empty entries.
+func splitRepositories(value string) []string {
+	var repositories []string
+	for _, repository := range strings.Split(value, ",") {
+		if repository = strings.TrimSpace(repository); repository != "" {
+			repositories = append(repositories, repository)
+		}
+	}
+	return repositories
+}
+
+// repositoryHints returns the repositories of the jobs waiting for a runner of the ephemeral runner set,
+// as published by the listener with the scale patch.
+func repositoryHints(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) []string {
+	return splitRepositories(ephemeralRunnerSet.Annotations[v1alpha1.EphemeralRunnerSetRepositoryHintsAnnotationKey])
+}
+
+// withJobRepository returns the repositories recorded on a node with repository moved to the front,
+// keeping at most maxNodeJobRepositories of them.
+func withJobRepository(repositories []string, repository string) []string {
+	result := make([]string, 0, min(len(repositories)+1, maxNodeJobRepositories))
+	result = append(result, repository)
+	for _, r := range repositories {
+		if len(result) == maxNodeJobRepositories {
+			break
+		}
+		if r != repository {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// nodesWithJobRepository returns the names of the nodes that recently ran a job of the repository.
+func (r *EphemeralRunnerReconciler) nodesWithJobRepository(ctx context.Context, repository string) ([]string, error) {
+	nodes := new(corev1.NodeList)
+	if err := r.List(ctx, nodes); err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	var names []string
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		if slices.Contains(splitRepositories(node.Annotations[AnnotationKeyNodeJobRepositories]), repository) {
+			names = append(names, node.Name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// preferNodes adds a preferred node affinity term for the nodes to the pod,
+// in addition to the affinity set by the pod template.
+func preferNodes(pod *corev1.Pod, nodes []string) {
+	// The affinity is shared with the ephemeral runner spec, so it is copied before being modified.
+	affinity := pod.Spec.Affinity.DeepCopy()
+	if affinity == nil {
+		affinity = new(corev1.Affinity)
+	}
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = new(corev1.NodeAffinity)
+	}
+	affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+		affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+		corev1.PreferredSchedulingTerm{
+			Weight: cacheLocalityAffinityWeight,
+			Preference: corev1.NodeSelectorTerm{
+				MatchFields: []corev1.NodeSelectorRequirement{
+					{
+						Key:      "metadata.name",
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   nodes,
+					},
+				},
+			},
+		},
+	)
+	pod.Spec.Affinity = affinity
+}
+
+// applyCacheLocalityHints makes the pod of the ephemeral runner prefer the nodes
+// that recently ran jobs of its preferred repository.
+func (r *EphemeralRunnerReconciler) applyCacheLocalityHints(ctx context.Context, runner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) {
+	repository := runner.Annotations[AnnotationKeyPreferredRepository]
+	if repository == "" {
+		return
+	}
+
+	nodes, err := r.nodesWithJobRepository(ctx, repository)
+	if err != nil {
+		// The hint is only a preference, the pod can be scheduled without it.
+		log.Error(err, "Failed to find the nodes with jobs of the preferred repository")
+		return
+	}
+	if len(nodes) == 0 {
+		return
+	}
+
+	log.Info("Preferring the nodes with jobs of the preferred repository", "repository", repository, "nodes", len(nodes))
+	preferNodes(pod, nodes)
+}
+
+// recordJobRepositoryOnNode records the repository of the job of the ephemeral runner on the node its pod runs on,
+// so the runners created for the same repository later can prefer the node.
+func (r *EphemeralRunnerReconciler) recordJobRepositoryOnNode(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	repository := ephemeralRunner.Status.JobRepositoryName
+	if strings.Trim(repository, "/") == "" || pod.Spec.NodeName == "" {
+		return nil
+	}
+
+	node := new(corev1.Node)
+	if err := r.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	repositories := splitRepositories(node.Annotations[AnnotationKeyNodeJobRepositories])
+	if len(repositories) > 0 && repositories[0] == repository {
+		return nil
+	}
+
+	log.Info("Recording the job repository on the node", "node", node.Name, "repository", repository)
+	if err := patch(ctx, r.Client, node, func(obj *corev1.Node) {
+		if obj.Annotations == nil {
+			obj.Annotations = make(map[string]string)
+		}
+		obj.Annotations[AnnotationKeyNodeJobRepositories] = strings.Join(withJobRepository(repositories, repository), ",")
+	}); err != nil {
+		return fmt.Errorf("failed to patch node annotations: %w", err)
+	}
+
+	return nil
+}
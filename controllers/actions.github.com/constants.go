@@ -63,6 +63,18 @@ const (
 	AnnotationKeyNoPermissionServiceAccountName   = "actions.github.com/cleanup-no-permission-service-account-name"
 )
 
+// AnnotationKeyClusterAutoscalerSafeToEvict is checked by the cluster autoscaler
+// before evicting a pod to remove the node it runs on
+const AnnotationKeyClusterAutoscalerSafeToEvict = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+
+// AnnotationKeyPreferredRepository is set on the ephemeral runners created for the repository hints
+// of the ephemeral runner set, so their pods prefer the nodes that recently ran jobs of the repository
+const AnnotationKeyPreferredRepository = "actions.github.com/preferred-repository"
+
+// AnnotationKeyNodeJobRepositories is set on the nodes to the comma separated repositories
+// of the jobs that recently ran on the node, the most recent first
+const AnnotationKeyNodeJobRepositories = "actions.github.com/job-repositories"
+
 // DefaultScaleSetListenerLogLevel is the default log level applied
 const DefaultScaleSetListenerLogLevel = string(logging.LogLevelDebug)
 
@@ -72,6 +84,9 @@ const DefaultScaleSetListenerLogFormat = string(logging.LogFormatText)
 // ownerKey is field selector matching the owner name of a particular resource
 const resourceOwnerKey = ".metadata.controller"
 
+// nodeNameKey is field selector matching the name of the node a pod is scheduled on
+const nodeNameKey = ".spec.nodeName"
+
 // EphemeralRunner pod creation failure reasons
 const (
 	ReasonTooManyPodFailures = "TooManyPodFailures"
@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"strconv"
 	"strings"
@@ -49,6 +50,15 @@ type EphemeralRunnerReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// ClusterAutoscalerHints annotates runner pods so the cluster autoscaler may evict idle runners
+	// to remove their nodes, but keeps the nodes of runners that are running a job.
+	ClusterAutoscalerHints bool
+
+	// CacheLocalityHints records the repositories of the jobs run on each node, and makes the pods
+	// of runners created for a repository prefer the nodes that recently ran its jobs.
+	CacheLocalityHints bool
+
 	ResourceBuilder
 }
 
@@ -70,6 +80,7 @@ const maxFailures = 5
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners/finalizers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;patch
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=create;get;list;watch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -154,6 +165,13 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, nil
 	}
 
+	if ephemeralRunner.IsTerminationRequested() {
+		if !ephemeralRunner.HasJob() {
+			return ctrl.Result{}, r.terminateEphemeralRunner(ctx, ephemeralRunner, log)
+		}
+		log.Info("Termination is requested, waiting for the job of the ephemeral runner to complete", "jobId", ephemeralRunner.Status.JobID)
+	}
+
 	addFinalizers := !controllerutil.ContainsFinalizer(ephemeralRunner, ephemeralRunnerFinalizerName) || !controllerutil.ContainsFinalizer(ephemeralRunner, ephemeralRunnerActionsFinalizerName)
 	if addFinalizers {
 		log.Info("Adding finalizers")
@@ -356,6 +374,22 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			log.Info("Failed to update ephemeral runner status. Requeue to not miss this event")
 			return ctrl.Result{}, err
 		}
+		if r.ClusterAutoscalerHints {
+			if err := r.updateClusterAutoscalerHints(ctx, ephemeralRunner, pod, log); err != nil {
+				log.Error(err, "Failed to update cluster autoscaler hints on the pod")
+				return ctrl.Result{}, err
+			}
+		}
+		if err := r.updateJobPodAnnotations(ctx, ephemeralRunner, pod, log); err != nil {
+			log.Error(err, "Failed to set the job pod annotations on the pod")
+			return ctrl.Result{}, err
+		}
+		if r.CacheLocalityHints {
+			if err := r.recordJobRepositoryOnNode(ctx, ephemeralRunner, pod, log); err != nil {
+				log.Error(err, "Failed to record the job repository on the node")
+				return ctrl.Result{}, err
+			}
+		}
 		return ctrl.Result{}, nil
 
 	case cs.State.Terminated.ExitCode != 0: // failed
@@ -372,6 +406,18 @@ func (r *EphemeralRunnerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	}
 }
 
+// terminateEphemeralRunner deletes the idle ephemeral runner annotated to be terminated. The finalizer
+// deregisters the runner from the service, and waits for the job if one is assigned to it in the meantime.
+func (r *EphemeralRunnerReconciler) terminateEphemeralRunner(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
+	log.Info("Termination is requested, deleting the idle ephemeral runner", "runnerId", ephemeralRunner.Status.RunnerId)
+	if err := r.Delete(ctx, ephemeralRunner); err != nil && !kerrors.IsNotFound(err) {
+		log.Error(err, "Failed to delete the ephemeral runner requested to be terminated")
+		return err
+	}
+	log.Info("Request to delete the ephemeral runner requested to be terminated has been issued")
+	return nil
+}
+
 func (r *EphemeralRunnerReconciler) deleteEphemeralRunnerOrPod(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
 	if ephemeralRunner.HasJob() {
 		log.Error(
@@ -718,6 +764,14 @@ func (r *EphemeralRunnerReconciler) createPod(ctx context.Context, runner *v1alp
 
 	log.Info("Creating new pod for ephemeral runner")
 	newPod := r.newEphemeralRunnerPod(runner, secret, envs...)
+	if r.ClusterAutoscalerHints {
+		if value, ok := clusterAutoscalerSafeToEvict(runner); ok {
+			newPod.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict] = value
+		}
+	}
+	if r.CacheLocalityHints {
+		r.applyCacheLocalityHints(ctx, runner, newPod, log)
+	}
 
 	if err := ctrl.SetControllerReference(runner, newPod, r.Scheme); err != nil {
 		log.Error(err, "Failed to set controller reference to a new pod")
@@ -804,6 +858,74 @@ func (r *EphemeralRunnerReconciler) updateRunStatusFromPod(ctx context.Context,
 	return nil
 }
 
+// updateClusterAutoscalerHints keeps the safe-to-evict annotation of the pod in line with the job assignment of the runner.
+func (r *EphemeralRunnerReconciler) updateClusterAutoscalerHints(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	value, ok := clusterAutoscalerSafeToEvict(ephemeralRunner)
+	if !ok || pod.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict] == value {
+		return nil
+	}
+
+	log.Info("Updating cluster autoscaler safe-to-evict annotation on the pod", "safeToEvict", value)
+	if err := patch(ctx, r.Client, pod, func(obj *corev1.Pod) {
+		if obj.Annotations == nil {
+			obj.Annotations = make(map[string]string)
+		}
+		obj.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict] = value
+	}); err != nil {
+		return fmt.Errorf("failed to patch pod annotations: %w", err)
+	}
+
+	log.Info("Updated cluster autoscaler safe-to-evict annotation on the pod")
+	return nil
+}
+
+// updateJobPodAnnotations sets the job pod annotations of the ephemeral runner on its pod once the runner
+// is assigned a job. The runner exits with its job, so the annotations are never removed.
+func (r *EphemeralRunnerReconciler) updateJobPodAnnotations(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, pod *corev1.Pod, log logr.Logger) error {
+	if !ephemeralRunner.HasJob() || len(ephemeralRunner.Spec.JobPodAnnotations) == 0 {
+		return nil
+	}
+	outdated := false
+	for k, v := range ephemeralRunner.Spec.JobPodAnnotations {
+		if value, ok := pod.Annotations[k]; !ok || value != v {
+			outdated = true
+			break
+		}
+	}
+	if !outdated {
+		return nil
+	}
+
+	log.Info("Setting the job pod annotations on the pod", "jobId", ephemeralRunner.Status.JobID)
+	if err := patch(ctx, r.Client, pod, func(obj *corev1.Pod) {
+		if obj.Annotations == nil {
+			obj.Annotations = make(map[string]string, len(ephemeralRunner.Spec.JobPodAnnotations))
+		}
+		maps.Copy(obj.Annotations, ephemeralRunner.Spec.JobPodAnnotations)
+	}); err != nil {
+		return fmt.Errorf("failed to patch pod annotations: %w", err)
+	}
+	log.Info("Set the job pod annotations on the pod")
+	return nil
+}
+
+// clusterAutoscalerSafeToEvict returns the safe-to-evict annotation value for the pod of the ephemeral runner.
+// It returns false if the value is set by the pod template, or by the job pod annotations once the runner
+// is assigned a job, in which case it is left untouched.
+func clusterAutoscalerSafeToEvict(ephemeralRunner *v1alpha1.EphemeralRunner) (string, bool) {
+	if _, ok := ephemeralRunner.Spec.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict]; ok {
+		return "", false
+	}
+	if _, ok := ephemeralRunner.Spec.JobPodAnnotations[AnnotationKeyClusterAutoscalerSafeToEvict]; ok && ephemeralRunner.HasJob() {
+		return "", false
+	}
+	// Runners marked with GC priority have already completed their job according to the listener.
+	if ephemeralRunner.HasJob() && !ephemeralRunner.HasGCPriority() {
+		return "false", true
+	}
+	return "true", true
+}
+
 func (r *EphemeralRunnerReconciler) deleteRunnerFromService(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, log logr.Logger) error {
 	client, err := r.GetActionsService(ctx, ephemeralRunner)
 	if err != nil {
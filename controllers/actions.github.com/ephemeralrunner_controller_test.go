@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"testing"
 	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
@@ -20,11 +21,15 @@ import (
 	"github.com/actions/actions-runner-controller/github/actions/testserver"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -34,6 +39,261 @@ const (
 	runnerImage             = "ghcr.io/actions/actions-runner:latest"
 )
 
+func TestClusterAutoscalerSafeToEvict(t *testing.T) {
+	idle := &v1alpha1.EphemeralRunner{}
+	value, ok := clusterAutoscalerSafeToEvict(idle)
+	assert.True(t, ok)
+	assert.Equal(t, "true", value)
+
+	busy := &v1alpha1.EphemeralRunner{Status: v1alpha1.EphemeralRunnerStatus{JobID: "job"}}
+	value, ok = clusterAutoscalerSafeToEvict(busy)
+	assert.True(t, ok)
+	assert.Equal(t, "false", value)
+
+	completed := busy.DeepCopy()
+	completed.Annotations = map[string]string{v1alpha1.EphemeralRunnerGCPriorityAnnotationKey: "true"}
+	value, ok = clusterAutoscalerSafeToEvict(completed)
+	assert.True(t, ok)
+	assert.Equal(t, "true", value)
+
+	overridden := busy.DeepCopy()
+	overridden.Spec.Annotations = map[string]string{AnnotationKeyClusterAutoscalerSafeToEvict: "true"}
+	_, ok = clusterAutoscalerSafeToEvict(overridden)
+	assert.False(t, ok)
+
+	jobAnnotated := completed.DeepCopy()
+	jobAnnotated.Spec.JobPodAnnotations = map[string]string{AnnotationKeyClusterAutoscalerSafeToEvict: "false"}
+	_, ok = clusterAutoscalerSafeToEvict(jobAnnotated)
+	assert.False(t, ok, "the job pod annotations own the value once the runner is assigned a job")
+
+	jobAnnotated.Status.JobID = ""
+	value, ok = clusterAutoscalerSafeToEvict(jobAnnotated)
+	assert.True(t, ok)
+	assert.Equal(t, "true", value)
+}
+
+func TestUpdateClusterAutoscalerHints(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "runner",
+			Namespace:   "default",
+			Annotations: map[string]string{AnnotationKeyClusterAutoscalerSafeToEvict: "true"},
+		},
+	}
+	r := &EphemeralRunnerReconciler{
+		Client: crfake.NewClientBuilder().WithObjects(pod).Build(),
+	}
+
+	runner := &v1alpha1.EphemeralRunner{Status: v1alpha1.EphemeralRunnerStatus{JobID: "job"}}
+	require.NoError(t, r.updateClusterAutoscalerHints(context.Background(), runner, pod, logr.Discard()))
+
+	updated := new(corev1.Pod)
+	require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), updated))
+	assert.Equal(t, "false", updated.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict])
+}
+
+func TestUpdateClusterAutoscalerHintsWithJobPodAnnotations(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "runner",
+			Namespace:   "default",
+			Annotations: map[string]string{AnnotationKeyClusterAutoscalerSafeToEvict: "true"},
+		},
+	}
+	r := &EphemeralRunnerReconciler{
+		Client:                 crfake.NewClientBuilder().WithObjects(pod).Build(),
+		ClusterAutoscalerHints: true,
+	}
+	runner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{v1alpha1.EphemeralRunnerGCPriorityAnnotationKey: "true"},
+		},
+		Spec: v1alpha1.EphemeralRunnerSpec{
+			JobPodAnnotations: map[string]string{AnnotationKeyClusterAutoscalerSafeToEvict: "false"},
+		},
+		Status: v1alpha1.EphemeralRunnerStatus{JobID: "job"},
+	}
+
+	// Every reconcile of the pod applies both, the annotation must settle instead of flipping.
+	updated := new(corev1.Pod)
+	for range 2 {
+		require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), updated))
+		require.NoError(t, r.updateClusterAutoscalerHints(context.Background(), runner, updated, logr.Discard()))
+		require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), updated))
+		require.NoError(t, r.updateJobPodAnnotations(context.Background(), runner, updated, logr.Discard()))
+		require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), updated))
+		assert.Equal(t, "false", updated.Annotations[AnnotationKeyClusterAutoscalerSafeToEvict])
+	}
+	resourceVersion := updated.ResourceVersion
+	require.NoError(t, r.updateClusterAutoscalerHints(context.Background(), runner, updated, logr.Discard()))
+	require.NoError(t, r.updateJobPodAnnotations(context.Background(), runner, updated, logr.Discard()))
+	require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), updated))
+	assert.Equal(t, resourceVersion, updated.ResourceVersion, "the pod is not patched again")
+}
+
+func TestUpdateJobPodAnnotations(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "runner",
+			Namespace:   "default",
+			Annotations: map[string]string{"team": "ci"},
+		},
+	}
+	r := &EphemeralRunnerReconciler{
+		Client: crfake.NewClientBuilder().WithObjects(pod).Build(),
+	}
+	runner := &v1alpha1.EphemeralRunner{
+		Spec: v1alpha1.EphemeralRunnerSpec{
+			JobPodAnnotations: map[string]string{AnnotationKeyClusterAutoscalerSafeToEvict: "false"},
+		},
+	}
+
+	require.NoError(t, r.updateJobPodAnnotations(context.Background(), runner, pod, logr.Discard()))
+	updated := new(corev1.Pod)
+	require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), updated))
+	assert.NotContains(t, updated.Annotations, AnnotationKeyClusterAutoscalerSafeToEvict, "an idle runner is not annotated")
+
+	runner.Status.JobID = "job"
+	require.NoError(t, r.updateJobPodAnnotations(context.Background(), runner, pod, logr.Discard()))
+	require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), updated))
+	assert.Equal(t, map[string]string{"team": "ci", AnnotationKeyClusterAutoscalerSafeToEvict: "false"}, updated.Annotations)
+}
+
+func TestTerminateEphemeralRunner(t *testing.T) {
+	idle := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "idle",
+			Namespace:   "default",
+			Annotations: map[string]string{v1alpha1.EphemeralRunnerTerminateAnnotationKey: "true"},
+		},
+	}
+	busy := idle.DeepCopy()
+	busy.Name = "busy"
+	busy.Status.JobID = "job"
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	r := &EphemeralRunnerReconciler{
+		Client: crfake.NewClientBuilder().WithScheme(scheme).WithObjects(idle).Build(),
+		Log:    logr.Discard(),
+	}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(idle)})
+	require.NoError(t, err)
+	err = r.Get(context.Background(), client.ObjectKeyFromObject(idle), new(v1alpha1.EphemeralRunner))
+	assert.True(t, kerrors.IsNotFound(err), "the idle runner is deleted")
+
+	assert.True(t, busy.IsTerminationRequested())
+	assert.False(t, (&v1alpha1.EphemeralRunner{}).IsTerminationRequested())
+}
+
+func TestWithJobRepository(t *testing.T) {
+	assert.Equal(t, []string{"owner/a"}, withJobRepository(nil, "owner/a"))
+	assert.Equal(t, []string{"owner/b", "owner/a", "owner/c"}, withJobRepository([]string{"owner/a", "owner/b", "owner/c"}, "owner/b"))
+
+	var full []string
+	for i := range maxNodeJobRepositories {
+		full = append(full, fmt.Sprintf("owner/%d", i))
+	}
+	updated := withJobRepository(full, "owner/new")
+	assert.Len(t, updated, maxNodeJobRepositories)
+	assert.Equal(t, "owner/new", updated[0])
+	assert.Equal(t, full[:maxNodeJobRepositories-1], updated[1:])
+}
+
+func TestRecordJobRepositoryOnNode(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node",
+			Annotations: map[string]string{AnnotationKeyNodeJobRepositories: "owner/a,owner/b"},
+		},
+	}
+	r := &EphemeralRunnerReconciler{
+		Client: crfake.NewClientBuilder().WithObjects(node).Build(),
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{NodeName: "node"}}
+
+	runner := &v1alpha1.EphemeralRunner{Status: v1alpha1.EphemeralRunnerStatus{JobRepositoryName: "owner/b"}}
+	require.NoError(t, r.recordJobRepositoryOnNode(context.Background(), runner, pod, logr.Discard()))
+
+	updated := new(corev1.Node)
+	require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(node), updated))
+	assert.Equal(t, "owner/b,owner/a", updated.Annotations[AnnotationKeyNodeJobRepositories])
+
+	// Runners without a job, or with a pod not scheduled yet, are not recorded.
+	require.NoError(t, r.recordJobRepositoryOnNode(context.Background(), &v1alpha1.EphemeralRunner{}, pod, logr.Discard()))
+	require.NoError(t, r.recordJobRepositoryOnNode(context.Background(), runner, &corev1.Pod{}, logr.Discard()))
+
+	// Nodes removed in the meantime are ignored.
+	pod.Spec.NodeName = "removed"
+	require.NoError(t, r.recordJobRepositoryOnNode(context.Background(), runner, pod, logr.Discard()))
+}
+
+func TestApplyCacheLocalityHints(t *testing.T) {
+	nodes := []client.Object{
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "warm",
+				Annotations: map[string]string{AnnotationKeyNodeJobRepositories: "owner/b,owner/a"},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "cordoned",
+				Annotations: map[string]string{AnnotationKeyNodeJobRepositories: "owner/a"},
+			},
+			Spec: corev1.NodeSpec{Unschedulable: true},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "cold",
+				Annotations: map[string]string{AnnotationKeyNodeJobRepositories: "owner/c"},
+			},
+		},
+	}
+	r := &EphemeralRunnerReconciler{
+		Client: crfake.NewClientBuilder().WithObjects(nodes...).Build(),
+	}
+
+	templateAffinity := &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+				{
+					Weight: 10,
+					Preference: corev1.NodeSelectorTerm{
+						MatchExpressions: []corev1.NodeSelectorRequirement{
+							{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"runners"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	runner := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{AnnotationKeyPreferredRepository: "owner/a"},
+		},
+	}
+	runner.Spec.Spec.Affinity = templateAffinity
+
+	pod := &corev1.Pod{Spec: runner.Spec.Spec}
+	r.applyCacheLocalityHints(context.Background(), runner, pod, logr.Discard())
+
+	terms := pod.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	require.Len(t, terms, 2)
+	assert.Equal(t, templateAffinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0], terms[0])
+	assert.Equal(t, int32(cacheLocalityAffinityWeight), terms[1].Weight)
+	assert.Equal(t, []corev1.NodeSelectorRequirement{
+		{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"warm"}},
+	}, terms[1].Preference.MatchFields)
+	assert.Len(t, runner.Spec.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, 1, "runner spec must not be modified")
+
+	// Without nodes for the repository, the pod is left untouched.
+	runner.Annotations[AnnotationKeyPreferredRepository] = "owner/d"
+	pod = &corev1.Pod{}
+	r.applyCacheLocalityHints(context.Background(), runner, pod, logr.Discard())
+	assert.Nil(t, pod.Spec.Affinity)
+}
+
 func newExampleRunner(name, namespace, configSecretName string) *v1alpha1.EphemeralRunner {
 	return &v1alpha1.EphemeralRunner{
 		ObjectMeta: metav1.ObjectMeta{
@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/controllers/actions.github.com/metrics"
@@ -53,14 +54,37 @@ type EphemeralRunnerSetReconciler struct {
 
 	PublishMetrics bool
 
+	// PreferEmptyNodesOnScaleDown makes scale down remove the runners on the nodes with the fewest
+	// other pods first, so nodes become empty and can be released by the cluster autoscaler sooner.
+	PreferEmptyNodesOnScaleDown bool
+
+	// VolumeAwareScaling limits scale up to the runners whose ephemeral volumes can be provisioned,
+	// according to the resource quotas of the namespace, instead of creating pods that would be stuck pending.
+	VolumeAwareScaling bool
+	// TrackPersistentVolumes makes volume aware scaling also account for the available persistent volumes
+	// of statically provisioned storage classes. It requires access to the cluster scoped resources.
+	TrackPersistentVolumes bool
+
+	// CacheLocalityHints assigns the repositories of the jobs waiting for runners, as hinted by the listener,
+	// to the new runners, so their pods can prefer the nodes with warm caches for the repositories.
+	CacheLocalityHints bool
+
+	storageLimited storageLimitedEphemeralRunners
+	scaleDowns     scaleDownWindows
+
 	ResourceBuilder
 }
 
+// storageLimitedRequeueAfter is how long to wait before retrying to create the runners limited by the available storage.
+const storageLimitedRequeueAfter = 30 * time.Second
+
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunnersets/finalizers,verbs=update;patch
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=actions.github.com,resources=ephemeralrunners/status,verbs=get
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims;persistentvolumes;resourcequotas,verbs=get;list;watch
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -107,6 +131,9 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 			return ctrl.Result{}, err
 		}
 
+		r.storageLimited.set(ephemeralRunnerSet, 0)
+		r.scaleDowns.forget(ephemeralRunnerSet)
+
 		log.Info("Successfully removed finalizer after cleanup")
 		return ctrl.Result{}, nil
 	}
@@ -166,23 +193,17 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 		"deleting", len(ephemeralRunnerState.deleting),
 	)
 
+	var commonLabels metrics.CommonLabels
 	if r.PublishMetrics {
-		githubConfigURL := ephemeralRunnerSet.Spec.EphemeralRunnerSpec.GitHubConfigUrl
-		parsedURL, err := actions.ParseGitHubConfigFromURL(githubConfigURL)
+		commonLabels, err = ephemeralRunnerSetMetricsLabels(ephemeralRunnerSet)
 		if err != nil {
-			log.Error(err, "Github Config URL is invalid", "URL", githubConfigURL)
+			log.Error(err, "Github Config URL is invalid", "URL", ephemeralRunnerSet.Spec.EphemeralRunnerSpec.GitHubConfigUrl)
 			// stop reconciling on this object
 			return ctrl.Result{}, nil
 		}
 
 		metrics.SetEphemeralRunnerCountsByStatus(
-			metrics.CommonLabels{
-				Name:         ephemeralRunnerSet.Labels[LabelKeyGitHubScaleSetName],
-				Namespace:    ephemeralRunnerSet.Labels[LabelKeyGitHubScaleSetNamespace],
-				Repository:   parsedURL.Repository,
-				Organization: parsedURL.Organization,
-				Enterprise:   parsedURL.Enterprise,
-			},
+			commonLabels,
 			len(ephemeralRunnerState.pending),
 			len(ephemeralRunnerState.running),
 			len(ephemeralRunnerState.failed),
@@ -190,6 +211,9 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 	}
 
 	total := ephemeralRunnerState.scaleTotal()
+	storageLimited := 0
+	// scaleDownRequeueAfter is set when the scale down rate held back deletions, to the time they can resume.
+	var scaleDownRequeueAfter time.Duration
 	if ephemeralRunnerSet.Spec.PatchID == 0 || ephemeralRunnerSet.Spec.PatchID != ephemeralRunnerState.latestPatchID {
 		defer func() {
 			if err := r.cleanupFinishedEphemeralRunners(ctx, ephemeralRunnerState.finished, log); err != nil {
@@ -200,6 +224,13 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 		switch {
 		case total < ephemeralRunnerSet.Spec.Replicas: // Handle scale up
 			count := ephemeralRunnerSet.Spec.Replicas - total
+			if r.VolumeAwareScaling {
+				count, storageLimited, err = r.limitScaleUpByStorage(ctx, ephemeralRunnerSet, ephemeralRunnerState.pending, count, log)
+				if err != nil {
+					log.Error(err, "failed to compute the storage available for the ephemeral runners")
+					return ctrl.Result{}, err
+				}
+			}
 			log.Info("Creating new ephemeral runners (scale up)", "count", count)
 			if err := r.createEphemeralRunners(ctx, ephemeralRunnerSet, count, log); err != nil {
 				log.Error(err, "failed to make ephemeral runner")
@@ -213,19 +244,51 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 			// on the next batch
 		case ephemeralRunnerSet.Spec.PatchID == 0 && total > ephemeralRunnerSet.Spec.Replicas:
 			count := total - ephemeralRunnerSet.Spec.Replicas
+			now := time.Now()
+			if ephemeralRunnerSet.Spec.ScaleDownRate != nil {
+				if allowed, wait := r.scaleDowns.allowed(ephemeralRunnerSet, now); count > allowed {
+					log.Info("Scale down is limited by the scale down rate", "count", count, "allowed", allowed, "resumeIn", wait.String())
+					count = allowed
+					scaleDownRequeueAfter = wait
+				}
+			}
 			log.Info("Deleting ephemeral runners (scale down)", "count", count)
-			if err := r.deleteIdleEphemeralRunners(
+			deleted, err := r.deleteIdleEphemeralRunners(
 				ctx,
 				ephemeralRunnerSet,
 				ephemeralRunnerState.pending,
 				ephemeralRunnerState.running,
 				count,
 				log,
-			); err != nil {
+			)
+			if ephemeralRunnerSet.Spec.ScaleDownRate != nil {
+				r.scaleDowns.record(ephemeralRunnerSet, now, deleted)
+			}
+			if err != nil {
 				log.Error(err, "failed to delete idle runners")
 				return ctrl.Result{}, err
 			}
 		}
+	} else if r.VolumeAwareScaling {
+		// Create the runners of the current patch which were held back until their storage is available.
+		if count := min(r.storageLimited.get(ephemeralRunnerSet), ephemeralRunnerSet.Spec.Replicas-total); count > 0 {
+			count, storageLimited, err = r.limitScaleUpByStorage(ctx, ephemeralRunnerSet, ephemeralRunnerState.pending, count, log)
+			if err != nil {
+				log.Error(err, "failed to compute the storage available for the ephemeral runners")
+				return ctrl.Result{}, err
+			}
+			if count > 0 {
+				log.Info("Creating storage limited ephemeral runners (scale up)", "count", count)
+				if err := r.createEphemeralRunners(ctx, ephemeralRunnerSet, count, log); err != nil {
+					log.Error(err, "failed to make ephemeral runner")
+					return ctrl.Result{}, err
+				}
+			}
+		}
+	}
+
+	if r.PublishMetrics && r.VolumeAwareScaling {
+		metrics.SetStorageLimitedEphemeralRunners(commonLabels, storageLimited)
 	}
 
 	desiredStatus := v1alpha1.EphemeralRunnerSetStatus{
@@ -233,7 +296,14 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 		PendingEphemeralRunners: len(ephemeralRunnerState.pending),
 		RunningEphemeralRunners: len(ephemeralRunnerState.running),
 		FailedEphemeralRunners:  len(ephemeralRunnerState.failed),
+		LastDesiredReplicas:     ephemeralRunnerSet.Status.LastDesiredReplicas,
+		LastPatchID:             ephemeralRunnerSet.Status.LastPatchID,
+		LastScaleTime:           ephemeralRunnerSet.Status.LastScaleTime,
+		LastScaleReason:         ephemeralRunnerSet.Status.LastScaleReason,
+		RunnerMinutes:           ephemeralRunnerSet.Status.RunnerMinutes,
 	}
+	recordLastScaleDecision(ephemeralRunnerSet, &desiredStatus)
+	countRunnerMinutes(&ephemeralRunnerSet.Status, &desiredStatus, time.Now())
 
 	// Update the status if needed.
 	if ephemeralRunnerSet.Status != desiredStatus {
@@ -246,9 +316,60 @@ func (r *EphemeralRunnerSetReconciler) Reconcile(ctx context.Context, req ctrl.R
 		}
 	}
 
+	if storageLimited > 0 {
+		return ctrl.Result{RequeueAfter: storageLimitedRequeueAfter}, nil
+	}
+	if scaleDownRequeueAfter > 0 {
+		return ctrl.Result{RequeueAfter: scaleDownRequeueAfter}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// recordLastScaleDecision records the scale decision of the spec in the status once the listener
+// patched a new one, so the current scaling intent is visible without reading the listener logs.
+func recordLastScaleDecision(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, status *v1alpha1.EphemeralRunnerSetStatus) {
+	reason := ephemeralRunnerSet.Annotations[v1alpha1.EphemeralRunnerSetScaleReasonAnnotationKey]
+	if status.LastDesiredReplicas == ephemeralRunnerSet.Spec.Replicas &&
+		status.LastPatchID == ephemeralRunnerSet.Spec.PatchID &&
+		status.LastScaleReason == reason {
+		return
+	}
+	now := metav1.Now()
+	status.LastDesiredReplicas = ephemeralRunnerSet.Spec.Replicas
+	status.LastPatchID = ephemeralRunnerSet.Spec.PatchID
+	status.LastScaleTime = &now
+	status.LastScaleReason = reason
+}
+
+// countRunnerMinutes adds the time the running runners of the last status ran since it was counted to the
+// runner time of the day, once the number of running runners changes. In between, the listener counts the
+// running runners of the status since CountedAt itself, so that the status is not patched continuously.
+func countRunnerMinutes(last, status *v1alpha1.EphemeralRunnerSetStatus, now time.Time) {
+	if last.RunningEphemeralRunners == status.RunningEphemeralRunners && last.RunnerMinutes != nil {
+		return
+	}
+
+	now = now.UTC()
+	day := now.Truncate(24 * time.Hour)
+	counted := &v1alpha1.RunnerMinutesStatus{
+		Day:       metav1.NewTime(day),
+		CountedAt: metav1.NewTime(now),
+	}
+	if m := last.RunnerMinutes; m != nil {
+		from := m.CountedAt.Time
+		if m.Day.Time.Equal(day) {
+			counted.Seconds = m.Seconds
+		} else if from.Before(day) {
+			from = day
+		}
+		if now.After(from) {
+			counted.Seconds += int64(last.RunningEphemeralRunners) * int64(now.Sub(from)/time.Second)
+		}
+	}
+	status.RunnerMinutes = counted
+}
+
 func (r *EphemeralRunnerSetReconciler) cleanupFinishedEphemeralRunners(ctx context.Context, finishedEphemeralRunners []*v1alpha1.EphemeralRunner, log logr.Logger) error {
 	// cleanup finished runners and proceed
 	var errs []error
@@ -355,12 +476,36 @@ func (r *EphemeralRunnerSetReconciler) cleanUpEphemeralRunners(ctx context.Conte
 	return false, nil
 }
 
+// limitScaleUpByStorage returns how many of the count runners to create can have their volumes provisioned,
+// and how many are held back until the storage becomes available.
+func (r *EphemeralRunnerSetReconciler) limitScaleUpByStorage(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, pending []*v1alpha1.EphemeralRunner, count int, log logr.Logger) (allowed, limited int, err error) {
+	capacity, err := r.ephemeralRunnerStorageCapacity(ctx, ephemeralRunnerSet, pending)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	allowed = min(count, capacity)
+	limited = count - allowed
+	r.storageLimited.set(ephemeralRunnerSet, limited)
+	if limited > 0 {
+		log.Info("Scale up is limited by the storage available for the ephemeral runner volumes", "requested", count, "allowed", allowed, "limited", limited)
+	}
+	return allowed, limited, nil
+}
+
 // createEphemeralRunners provisions `count` number of v1alpha1.EphemeralRunner resources in the cluster.
 func (r *EphemeralRunnerSetReconciler) createEphemeralRunners(ctx context.Context, runnerSet *v1alpha1.EphemeralRunnerSet, count int, log logr.Logger) error {
 	// Track multiple errors at once and return the bundle.
 	errs := make([]error, 0)
+	var repositories []string
+	if r.CacheLocalityHints {
+		repositories = repositoryHints(runnerSet)
+	}
 	for i := 0; i < count; i++ {
 		ephemeralRunner := r.newEphemeralRunner(runnerSet)
+		if len(repositories) > 0 {
+			ephemeralRunner.Annotations[AnnotationKeyPreferredRepository] = repositories[i%len(repositories)]
+		}
 		if runnerSet.Spec.EphemeralRunnerSpec.Proxy != nil {
 			ephemeralRunner.Spec.ProxySecretRef = proxyEphemeralRunnerSetSecretName(runnerSet)
 		}
@@ -430,20 +575,37 @@ func (r *EphemeralRunnerSetReconciler) createProxySecret(ctx context.Context, ep
 // if there are not enough ephemeral runners that have registered with Actions service.
 // When this happens, the next reconcile loop will try to delete the remaining ephemeral runners
 // after we get notified by any of the `v1alpha1.EphemeralRunner.Status` updates.
-func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners []*v1alpha1.EphemeralRunner, count int, log logr.Logger) error {
+// deleteIdleEphemeralRunners deletes up to count idle ephemeral runners, and returns how many it deleted.
+func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, pendingEphemeralRunners, runningEphemeralRunners []*v1alpha1.EphemeralRunner, count int, log logr.Logger) (int, error) {
 	if count <= 0 {
-		return nil
+		return 0, nil
 	}
-	runners := newEphemeralRunnerStepper(pendingEphemeralRunners, runningEphemeralRunners)
+	gcPriority, pendingEphemeralRunners := splitGCPriority(pendingEphemeralRunners)
+	gcPriorityRunning, runningEphemeralRunners := splitGCPriority(runningEphemeralRunners)
+	gcPriority = append(gcPriority, gcPriorityRunning...)
+
+	runners := newEphemeralRunnerStepper(gcPriority, pendingEphemeralRunners, runningEphemeralRunners)
 	if runners.len() == 0 {
 		log.Info("No pending or running ephemeral runners running at this time for scale down")
-		return nil
+		return 0, nil
+	}
+
+	var placement *runnerPlacement
+	if r.PreferEmptyNodesOnScaleDown {
+		p, err := r.newRunnerPlacement(ctx, runners.items)
+		if err != nil {
+			log.Error(err, "Failed to resolve the nodes of ephemeral runners, scaling down by creation time")
+		} else {
+			placement = p
+			runners = newEphemeralRunnerStepperFunc(placement.less, gcPriority, pendingEphemeralRunners, runningEphemeralRunners)
+		}
 	}
 	actionsClient, err := r.GetActionsService(ctx, ephemeralRunnerSet)
 	if err != nil {
-		return fmt.Errorf("failed to create actions client for ephemeral runner replica set: %w", err)
+		return 0, fmt.Errorf("failed to create actions client for ephemeral runner replica set: %w", err)
 	}
 	var errs []error
+	var deleted []*v1alpha1.EphemeralRunner
 	deletedCount := 0
 	for runners.next() {
 		ephemeralRunner := runners.object()
@@ -453,6 +615,8 @@ func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Co
 			continue
 		}
 
+		// Runners marked with GC priority are removed first, but only once they are done or idle like the others,
+		// the listener marking them from its view of the jobs, which can be wrong.
 		if !isDone && ephemeralRunner.HasJob() {
 			log.Info(
 				"Skipping ephemeral runner since it is running a job",
@@ -472,13 +636,157 @@ func (r *EphemeralRunnerSetReconciler) deleteIdleEphemeralRunners(ctx context.Co
 			continue
 		}
 
+		deleted = append(deleted, ephemeralRunner)
 		deletedCount++
 		if deletedCount == count {
 			break
 		}
 	}
 
-	return multierr.Combine(errs...)
+	if placement != nil {
+		if emptied := placement.emptiedNodes(deleted); emptied > 0 {
+			log.Info("Emptied nodes by removing ephemeral runners", "count", emptied)
+			if r.PublishMetrics {
+				if commonLabels, err := ephemeralRunnerSetMetricsLabels(ephemeralRunnerSet); err == nil {
+					metrics.AddScaleDownEmptiedNodes(commonLabels, emptied)
+				}
+			}
+		}
+	}
+
+	return deletedCount, multierr.Combine(errs...)
+}
+
+// canRemoveOnScaleDown reports whether deleteIdleEphemeralRunners attempts to remove the ephemeral runner.
+func canRemoveOnScaleDown(ephemeralRunner *v1alpha1.EphemeralRunner) bool {
+	if ephemeralRunner.IsDone() {
+		return true
+	}
+	if ephemeralRunner.Status.RunnerId == 0 {
+		return false
+	}
+	return !ephemeralRunner.HasJob()
+}
+
+// runnerPlacement describes how the ephemeral runners considered for scale down are spread across nodes.
+type runnerPlacement struct {
+	// nodes maps the ephemeral runner name to the node its pod is scheduled on.
+	nodes map[string]string
+	// candidates is the number of removable ephemeral runners per node.
+	candidates map[string]int
+	// remaining is the number of pods per node that stay after all candidates are removed.
+	remaining map[string]int
+}
+
+// newRunnerPlacement resolves the nodes of the ephemeral runners from the pods in the cache.
+//
+// Only pods visible to the controller are counted, so pods in namespaces that are not watched
+// are not taken into account. DaemonSet, mirror and terminated pods are ignored,
+// since they do not prevent the cluster autoscaler from removing a node.
+func (r *EphemeralRunnerSetReconciler) newRunnerPlacement(ctx context.Context, runners []*v1alpha1.EphemeralRunner) (*runnerPlacement, error) {
+	p := &runnerPlacement{
+		nodes:      make(map[string]string),
+		candidates: make(map[string]int),
+		remaining:  make(map[string]int),
+	}
+
+	candidatePods := make(map[types.NamespacedName]bool)
+	for _, runner := range runners {
+		pod := new(corev1.Pod)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: runner.Namespace, Name: runner.Name}, pod); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get pod of ephemeral runner %q: %w", runner.Name, err)
+		}
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+
+		p.nodes[runner.Name] = pod.Spec.NodeName
+		if canRemoveOnScaleDown(runner) {
+			p.candidates[pod.Spec.NodeName]++
+			candidatePods[client.ObjectKeyFromObject(pod)] = true
+		}
+	}
+
+	for _, node := range p.nodes {
+		if _, ok := p.remaining[node]; ok {
+			continue
+		}
+
+		podList := new(corev1.PodList)
+		if err := r.List(ctx, podList, client.MatchingFields{nodeNameKey: node}); err != nil {
+			return nil, fmt.Errorf("failed to list pods on node %q: %w", node, err)
+		}
+
+		p.remaining[node] = 0
+		for i := range podList.Items {
+			pod := &podList.Items[i]
+			if candidatePods[client.ObjectKeyFromObject(pod)] || !occupiesNode(pod) {
+				continue
+			}
+			p.remaining[node]++
+		}
+	}
+
+	return p, nil
+}
+
+// less orders the ephemeral runners that are not scheduled first, since they do not hold a node
+// and removing them prevents nodes from being provisioned for them. The rest is ordered by the number of
+// pods staying on their node and then by the number of runners to remove to empty the node.
+// Runners on the same node are kept together and ordered by creation time.
+func (p *runnerPlacement) less(a, b *v1alpha1.EphemeralRunner) bool {
+	nodeA, nodeB := p.nodes[a.Name], p.nodes[b.Name]
+	if nodeA == nodeB {
+		return createdBefore(a, b)
+	}
+	if nodeA == "" || nodeB == "" {
+		return nodeA == ""
+	}
+	if p.remaining[nodeA] != p.remaining[nodeB] {
+		return p.remaining[nodeA] < p.remaining[nodeB]
+	}
+	if p.candidates[nodeA] != p.candidates[nodeB] {
+		return p.candidates[nodeA] < p.candidates[nodeB]
+	}
+	return nodeA < nodeB
+}
+
+// emptiedNodes returns the number of nodes left without pods after removing the deleted ephemeral runners.
+func (p *runnerPlacement) emptiedNodes(deleted []*v1alpha1.EphemeralRunner) int {
+	deletedPerNode := make(map[string]int)
+	for _, runner := range deleted {
+		if node, ok := p.nodes[runner.Name]; ok {
+			deletedPerNode[node]++
+		}
+	}
+
+	emptied := 0
+	for node, count := range deletedPerNode {
+		if p.remaining[node] == 0 && count == p.candidates[node] {
+			emptied++
+		}
+	}
+	return emptied
+}
+
+// occupiesNode reports whether the pod prevents the cluster autoscaler from removing its node.
+func occupiesNode(pod *corev1.Pod) bool {
+	if !pod.DeletionTimestamp.IsZero() {
+		return false
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if owner := metav1.GetControllerOfNoCopy(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return false
+	}
+	return true
 }
 
 func (r *EphemeralRunnerSetReconciler) deleteEphemeralRunnerWithActionsClient(ctx context.Context, ephemeralRunner *v1alpha1.EphemeralRunner, actionsClient actions.ActionsService, log logr.Logger) (bool, error) {
@@ -516,18 +824,35 @@ func (r *EphemeralRunnerSetReconciler) SetupWithManager(mgr ctrl.Manager) error
 		Complete(r)
 }
 
+// splitGCPriority separates the runners that are marked for priority garbage collection from the rest.
+func splitGCPriority(runners []*v1alpha1.EphemeralRunner) (gcPriority, others []*v1alpha1.EphemeralRunner) {
+	for _, r := range runners {
+		if r.HasGCPriority() {
+			gcPriority = append(gcPriority, r)
+			continue
+		}
+		others = append(others, r)
+	}
+	return gcPriority, others
+}
+
 type ephemeralRunnerStepper struct {
 	items []*v1alpha1.EphemeralRunner
 	index int
 }
 
 func newEphemeralRunnerStepper(primary []*v1alpha1.EphemeralRunner, othersOrdered ...[]*v1alpha1.EphemeralRunner) *ephemeralRunnerStepper {
+	return newEphemeralRunnerStepperFunc(createdBefore, primary, othersOrdered...)
+}
+
+// newEphemeralRunnerStepperFunc is like newEphemeralRunnerStepper, but orders the runners within each bucket using less.
+func newEphemeralRunnerStepperFunc(less func(a, b *v1alpha1.EphemeralRunner) bool, primary []*v1alpha1.EphemeralRunner, othersOrdered ...[]*v1alpha1.EphemeralRunner) *ephemeralRunnerStepper {
 	sort.Slice(primary, func(i, j int) bool {
-		return primary[i].GetCreationTimestamp().Time.Before(primary[j].GetCreationTimestamp().Time)
+		return less(primary[i], primary[j])
 	})
 	for _, bucket := range othersOrdered {
 		sort.Slice(bucket, func(i, j int) bool {
-			return bucket[i].GetCreationTimestamp().Time.Before(bucket[j].GetCreationTimestamp().Time)
+			return less(bucket[i], bucket[j])
 		})
 	}
 
@@ -541,6 +866,10 @@ func newEphemeralRunnerStepper(primary []*v1alpha1.EphemeralRunner, othersOrdere
 	}
 }
 
+func createdBefore(a, b *v1alpha1.EphemeralRunner) bool {
+	return a.GetCreationTimestamp().Time.Before(b.GetCreationTimestamp().Time)
+}
+
 func (s *ephemeralRunnerStepper) next() bool {
 	if s.index+1 < len(s.items) {
 		s.index++
@@ -605,3 +934,18 @@ func newEphemeralRunnerState(ephemeralRunnerList *v1alpha1.EphemeralRunnerList)
 func (s *ephemeralRunnerState) scaleTotal() int {
 	return len(s.pending) + len(s.running) + len(s.failed)
 }
+
+func ephemeralRunnerSetMetricsLabels(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) (metrics.CommonLabels, error) {
+	parsedURL, err := actions.ParseGitHubConfigFromURL(ephemeralRunnerSet.Spec.EphemeralRunnerSpec.GitHubConfigUrl)
+	if err != nil {
+		return metrics.CommonLabels{}, err
+	}
+
+	return metrics.CommonLabels{
+		Name:         ephemeralRunnerSet.Labels[LabelKeyGitHubScaleSetName],
+		Namespace:    ephemeralRunnerSet.Labels[LabelKeyGitHubScaleSetNamespace],
+		Repository:   parsedURL.Repository,
+		Organization: parsedURL.Organization,
+		Enterprise:   parsedURL.Enterprise,
+	}, nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -14,9 +15,13 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/go-logr/logr"
@@ -40,6 +45,393 @@ func TestPrecomputedConstants(t *testing.T) {
 	require.Equal(t, len(failedRunnerBackoff), maxFailures+1)
 }
 
+func TestSplitGCPriority(t *testing.T) {
+	marked := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "marked",
+			Annotations: map[string]string{
+				v1alpha1.EphemeralRunnerGCPriorityAnnotationKey: "true",
+			},
+		},
+	}
+	unmarked := &v1alpha1.EphemeralRunner{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "unmarked",
+		},
+	}
+
+	gcPriority, others := splitGCPriority([]*v1alpha1.EphemeralRunner{unmarked, marked})
+	require.Equal(t, []*v1alpha1.EphemeralRunner{marked}, gcPriority)
+	require.Equal(t, []*v1alpha1.EphemeralRunner{unmarked}, others)
+}
+
+func TestCanRemoveOnScaleDown(t *testing.T) {
+	idle := &v1alpha1.EphemeralRunner{Status: v1alpha1.EphemeralRunnerStatus{RunnerId: 1}}
+	require.True(t, canRemoveOnScaleDown(idle))
+
+	busy := idle.DeepCopy()
+	busy.Status.JobID = "job"
+	require.False(t, canRemoveOnScaleDown(busy))
+
+	marked := busy.DeepCopy()
+	marked.Annotations = map[string]string{v1alpha1.EphemeralRunnerGCPriorityAnnotationKey: "true"}
+	require.False(t, canRemoveOnScaleDown(marked), "the runners marked for GC priority are not removed while they have a job")
+
+	marked.Status.Phase = corev1.PodSucceeded
+	require.True(t, canRemoveOnScaleDown(marked))
+}
+
+func TestRecordLastScaleDecision(t *testing.T) {
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				v1alpha1.EphemeralRunnerSetScaleReasonAnnotationKey: "MaxRunners",
+			},
+		},
+		Spec: v1alpha1.EphemeralRunnerSetSpec{
+			Replicas: 5,
+			PatchID:  3,
+		},
+	}
+
+	var status v1alpha1.EphemeralRunnerSetStatus
+	recordLastScaleDecision(ephemeralRunnerSet, &status)
+	require.Equal(t, 5, status.LastDesiredReplicas)
+	require.Equal(t, 3, status.LastPatchID)
+	require.Equal(t, "MaxRunners", status.LastScaleReason)
+	require.NotNil(t, status.LastScaleTime)
+
+	recorded := status.LastScaleTime
+	recordLastScaleDecision(ephemeralRunnerSet, &status)
+	require.Same(t, recorded, status.LastScaleTime, "an unchanged decision is not recorded again")
+
+	ephemeralRunnerSet.Spec.PatchID = 4
+	recordLastScaleDecision(ephemeralRunnerSet, &status)
+	require.Equal(t, 4, status.LastPatchID)
+	require.NotSame(t, recorded, status.LastScaleTime)
+}
+
+func TestCountRunnerMinutes(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := day.Add(10 * time.Hour)
+
+	last := v1alpha1.EphemeralRunnerSetStatus{}
+	status := v1alpha1.EphemeralRunnerSetStatus{RunningEphemeralRunners: 2}
+	countRunnerMinutes(&last, &status, now)
+	require.NotNil(t, status.RunnerMinutes)
+	require.Equal(t, int64(0), status.RunnerMinutes.Seconds, "the runner time is counted from the first status")
+	require.True(t, status.RunnerMinutes.Day.Equal(&metav1.Time{Time: day}))
+
+	last, status = status, v1alpha1.EphemeralRunnerSetStatus{RunningEphemeralRunners: 2, RunnerMinutes: status.RunnerMinutes}
+	countRunnerMinutes(&last, &status, now.Add(time.Minute))
+	require.Same(t, last.RunnerMinutes, status.RunnerMinutes, "the runner time is not counted while the running runners do not change")
+
+	status.RunningEphemeralRunners = 5
+	countRunnerMinutes(&last, &status, now.Add(30*time.Minute))
+	require.Equal(t, int64(2*30*60), status.RunnerMinutes.Seconds, "the runners of the last status ran since it was counted")
+
+	last, status = status, v1alpha1.EphemeralRunnerSetStatus{RunningEphemeralRunners: 0, RunnerMinutes: status.RunnerMinutes}
+	countRunnerMinutes(&last, &status, day.Add(24*time.Hour+time.Hour))
+	require.Equal(t, int64(5*60*60), status.RunnerMinutes.Seconds, "only the runner time of the new day is counted")
+	require.True(t, status.RunnerMinutes.Day.Equal(&metav1.Time{Time: day.Add(24 * time.Hour)}))
+}
+
+func TestRunnerPlacement(t *testing.T) {
+	now := time.Now()
+	var runners []*v1alpha1.EphemeralRunner
+	var objects []client.Object
+	newRunner := func(name, node string, hasJob bool) *v1alpha1.EphemeralRunner {
+		runner := &v1alpha1.EphemeralRunner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(now.Add(time.Duration(len(runners)) * time.Second)),
+			},
+			Status: v1alpha1.EphemeralRunnerStatus{
+				Phase:    corev1.PodRunning,
+				RunnerId: len(runners) + 1,
+			},
+		}
+		if hasJob {
+			runner.Status.JobID = "job"
+		}
+		runners = append(runners, runner)
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: node},
+		})
+		return runner
+	}
+	a1 := newRunner("a1", "node-a", false)
+	b1 := newRunner("b1", "node-b", false)
+	b2 := newRunner("b2", "node-b", false)
+	c1 := newRunner("c1", "node-c", false)
+	d1 := newRunner("d1", "node-d", true)
+	d2 := newRunner("d2", "node-d", false)
+	u1 := newRunner("u1", "", false)
+
+	objects = append(objects,
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "other"},
+			Spec:       corev1.PodSpec{NodeName: "node-a"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "daemon",
+				Namespace: "kube-system",
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "daemon", UID: "uid", Controller: boolPtr(true)},
+				},
+			},
+			Spec: corev1.PodSpec{NodeName: "node-c"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "completed", Namespace: "other"},
+			Spec:       corev1.PodSpec{NodeName: "node-b"},
+			Status:     corev1.PodStatus{Phase: corev1.PodSucceeded},
+		},
+	)
+
+	r := &EphemeralRunnerSetReconciler{
+		Client: crfake.NewClientBuilder().
+			WithObjects(objects...).
+			WithIndex(&corev1.Pod{}, nodeNameKey, podNodeNameIndexer).
+			Build(),
+	}
+
+	placement, err := r.newRunnerPlacement(context.Background(), runners)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"node-a": 1, "node-b": 0, "node-c": 0, "node-d": 1}, placement.remaining)
+	require.Equal(t, map[string]int{"node-a": 1, "node-b": 2, "node-c": 1, "node-d": 1}, placement.candidates)
+
+	stepper := newEphemeralRunnerStepperFunc(placement.less, runners)
+	require.Equal(t, []*v1alpha1.EphemeralRunner{u1, c1, b1, b2, a1, d1, d2}, stepper.items)
+
+	require.Equal(t, 0, placement.emptiedNodes([]*v1alpha1.EphemeralRunner{u1}))
+	require.Equal(t, 1, placement.emptiedNodes([]*v1alpha1.EphemeralRunner{u1, c1, b1}))
+	require.Equal(t, 2, placement.emptiedNodes([]*v1alpha1.EphemeralRunner{u1, c1, b1, b2}))
+	require.Equal(t, 2, placement.emptiedNodes([]*v1alpha1.EphemeralRunner{u1, c1, b1, b2, a1, d2}))
+}
+
+func TestCreateEphemeralRunners_RepositoryHints(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	r := &EphemeralRunnerSetReconciler{
+		Client:             crfake.NewClientBuilder().WithScheme(scheme).Build(),
+		Scheme:             scheme,
+		CacheLocalityHints: true,
+	}
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "EphemeralRunnerSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "set",
+			Namespace: "default",
+			UID:       "uid",
+			Annotations: map[string]string{
+				v1alpha1.EphemeralRunnerSetRepositoryHintsAnnotationKey: "owner/a,owner/b",
+				v1alpha1.EphemeralRunnerSetMessageSessionAnnotationKey:  `{"id":"session"}`,
+				v1alpha1.EphemeralRunnerSetLastMessageIDAnnotationKey:   "7",
+			},
+		},
+	}
+
+	require.NoError(t, r.createEphemeralRunners(context.Background(), ephemeralRunnerSet, 3, logr.Discard()))
+
+	runners := new(v1alpha1.EphemeralRunnerList)
+	require.NoError(t, r.List(context.Background(), runners))
+	require.Len(t, runners.Items, 3)
+
+	preferred := make(map[string]int)
+	for _, runner := range runners.Items {
+		_, ok := runner.Annotations[v1alpha1.EphemeralRunnerSetRepositoryHintsAnnotationKey]
+		require.False(t, ok, "repository hints of the set must not be copied to the runners")
+		_, ok = runner.Annotations[v1alpha1.EphemeralRunnerSetMessageSessionAnnotationKey]
+		require.False(t, ok, "message session of the set must not be copied to the runners")
+		_, ok = runner.Annotations[v1alpha1.EphemeralRunnerSetLastMessageIDAnnotationKey]
+		require.False(t, ok, "last message ID of the set must not be copied to the runners")
+		preferred[runner.Annotations[AnnotationKeyPreferredRepository]]++
+	}
+	require.Equal(t, map[string]int{"owner/a": 2, "owner/b": 1}, preferred)
+}
+
+func TestEphemeralRunnerStorageCapacity(t *testing.T) {
+	ephemeralVolume := func(name, storageClassName, storage string) corev1.Volume {
+		return corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				Ephemeral: &corev1.EphemeralVolumeSource{
+					VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+						Spec: corev1.PersistentVolumeClaimSpec{
+							StorageClassName: &storageClassName,
+							Resources: corev1.VolumeResourceRequirements{
+								Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(storage)},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "default"},
+		Spec: v1alpha1.EphemeralRunnerSetSpec{
+			EphemeralRunnerSpec: v1alpha1.EphemeralRunnerSpec{
+				PodTemplateSpec: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Volumes: []corev1.Volume{
+							{Name: "config", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+							ephemeralVolume("work", "standard", "10Gi"),
+							ephemeralVolume("cache", "local", "5Gi"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	pending := []*v1alpha1.EphemeralRunner{
+		{ObjectMeta: metav1.ObjectMeta{Name: "p1", Namespace: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "p2", Namespace: "default"}},
+	}
+
+	persistentVolume := func(name, storage string, bound bool) *corev1.PersistentVolume {
+		pv := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PersistentVolumeSpec{
+				StorageClassName: "local",
+				Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(storage)},
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeAvailable},
+		}
+		if bound {
+			pv.Spec.ClaimRef = &corev1.ObjectReference{Namespace: "default", Name: "p2-cache"}
+			pv.Status.Phase = corev1.VolumeBound
+		}
+		return pv
+	}
+
+	objects := []client.Object{
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, Provisioner: "ebs.csi.aws.com"},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "local"}, Provisioner: noProvisioner},
+		&corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "storage", Namespace: "default"},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{
+					corev1.ResourceRequestsStorage:        resource.MustParse("100Gi"),
+					corev1.ResourcePersistentVolumeClaims: resource.MustParse("20"),
+				},
+				Used: corev1.ResourceList{
+					corev1.ResourceRequestsStorage:        resource.MustParse("40Gi"),
+					corev1.ResourcePersistentVolumeClaims: resource.MustParse("4"),
+				},
+			},
+		},
+		&corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "other"},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{corev1.ResourceRequestsStorage: resource.MustParse("1Gi")},
+			},
+		},
+		// p1 has no claims yet, p2 has its work claim bound and its cache claim pending
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "p2-work", Namespace: "default"},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "p2-cache", Namespace: "default"},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+		},
+		persistentVolume("pv-1", "5Gi", false),
+		persistentVolume("pv-2", "10Gi", false),
+		persistentVolume("pv-3", "5Gi", false),
+		persistentVolume("pv-4", "5Gi", false),
+		persistentVolume("pv-small", "1Gi", false),
+		persistentVolume("pv-bound", "5Gi", true),
+	}
+
+	r := &EphemeralRunnerSetReconciler{
+		Client: crfake.NewClientBuilder().WithObjects(objects...).Build(),
+	}
+
+	// requests.storage: (100Gi - 40Gi - 15Gi reserved for p1) / 15Gi = 3
+	// persistentvolumeclaims: (20 - 4 - 2 reserved for p1) / 2 = 7
+	capacity, err := r.ephemeralRunnerStorageCapacity(context.Background(), ephemeralRunnerSet, pending)
+	require.NoError(t, err)
+	require.Equal(t, 3, capacity)
+
+	// local volumes: (4 available - 2 claims of p1 and p2 not bound yet) / 1 = 2
+	r.TrackPersistentVolumes = true
+	capacity, err = r.ephemeralRunnerStorageCapacity(context.Background(), ephemeralRunnerSet, pending)
+	require.NoError(t, err)
+	require.Equal(t, 2, capacity)
+
+	ephemeralRunnerSet.Spec.EphemeralRunnerSpec.Spec.Volumes = nil
+	capacity, err = r.ephemeralRunnerStorageCapacity(context.Background(), ephemeralRunnerSet, pending)
+	require.NoError(t, err)
+	require.Equal(t, math.MaxInt, capacity)
+}
+
+func TestStorageLimitedEphemeralRunners(t *testing.T) {
+	var limited storageLimitedEphemeralRunners
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "default"},
+		Spec:       v1alpha1.EphemeralRunnerSetSpec{PatchID: 1},
+	}
+
+	require.Equal(t, 0, limited.get(ephemeralRunnerSet))
+
+	limited.set(ephemeralRunnerSet, 3)
+	require.Equal(t, 3, limited.get(ephemeralRunnerSet))
+
+	// the runners held back for a previous patch are superseded by the new patch
+	ephemeralRunnerSet.Spec.PatchID = 2
+	require.Equal(t, 0, limited.get(ephemeralRunnerSet))
+
+	limited.set(ephemeralRunnerSet, 1)
+	require.Equal(t, 1, limited.get(ephemeralRunnerSet))
+
+	limited.set(ephemeralRunnerSet, 0)
+	require.Equal(t, 0, limited.get(ephemeralRunnerSet))
+}
+
+func TestScaleDownWindows(t *testing.T) {
+	var windows scaleDownWindows
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "default"},
+		Spec: v1alpha1.EphemeralRunnerSetSpec{
+			ScaleDownRate: &v1alpha1.ScaleDownRate{MaxDeletions: 5, Interval: &metav1.Duration{Duration: time.Minute}},
+		},
+	}
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+
+	allowed, wait := windows.allowed(ephemeralRunnerSet, now)
+	require.Equal(t, 5, allowed)
+	require.Equal(t, time.Minute, wait)
+
+	windows.record(ephemeralRunnerSet, now, 3)
+	allowed, wait = windows.allowed(ephemeralRunnerSet, now.Add(20*time.Second))
+	require.Equal(t, 2, allowed, "the deletions of the interval are counted")
+	require.Equal(t, 40*time.Second, wait)
+
+	windows.record(ephemeralRunnerSet, now.Add(20*time.Second), 2)
+	allowed, _ = windows.allowed(ephemeralRunnerSet, now.Add(30*time.Second))
+	require.Equal(t, 0, allowed)
+
+	allowed, _ = windows.allowed(ephemeralRunnerSet, now.Add(time.Minute))
+	require.Equal(t, 5, allowed, "the deletions resume with the next interval")
+
+	windows.forget(ephemeralRunnerSet)
+	allowed, _ = windows.allowed(ephemeralRunnerSet, now.Add(30*time.Second))
+	require.Equal(t, 5, allowed)
+
+	ephemeralRunnerSet.Spec.ScaleDownRate.Interval = nil
+	windows.record(ephemeralRunnerSet, now, 1)
+	_, wait = windows.allowed(ephemeralRunnerSet, now)
+	require.Equal(t, v1alpha1.DefaultScaleDownRateInterval, wait)
+}
+
 var _ = Describe("Test EphemeralRunnerSet controller", func() {
 	var ctx context.Context
 	var mgr ctrl.Manager
@@ -1017,7 +1409,7 @@ var _ = Describe("Test EphemeralRunnerSet controller", func() {
 					if err != nil {
 						return v1alpha1.EphemeralRunnerSetStatus{}, err
 					}
-					return updated.Status, nil
+					return ephemeralRunnerCounts(updated.Status), nil
 				},
 				ephemeralRunnerSetTestTimeout,
 				ephemeralRunnerSetTestInterval,
@@ -1060,7 +1452,7 @@ var _ = Describe("Test EphemeralRunnerSet controller", func() {
 					if err != nil {
 						return v1alpha1.EphemeralRunnerSetStatus{}, err
 					}
-					return updated.Status, nil
+					return ephemeralRunnerCounts(updated.Status), nil
 				},
 				ephemeralRunnerSetTestTimeout,
 				ephemeralRunnerSetTestInterval,
@@ -1077,7 +1469,7 @@ var _ = Describe("Test EphemeralRunnerSet controller", func() {
 					if err != nil {
 						return v1alpha1.EphemeralRunnerSetStatus{}, err
 					}
-					return updated.Status, nil
+					return ephemeralRunnerCounts(updated.Status), nil
 				},
 				ephemeralRunnerSetTestTimeout,
 				ephemeralRunnerSetTestInterval,
@@ -1555,3 +1947,13 @@ func listEphemeralRunnersAndRemoveFinalizers(ctx context.Context, k8sClient clie
 	list.Items = liveItems
 	return nil
 }
+
+// ephemeralRunnerCounts returns the runner counts of the status, without the last scale decision.
+func ephemeralRunnerCounts(status v1alpha1.EphemeralRunnerSetStatus) v1alpha1.EphemeralRunnerSetStatus {
+	return v1alpha1.EphemeralRunnerSetStatus{
+		CurrentReplicas:         status.CurrentReplicas,
+		PendingEphemeralRunners: status.PendingEphemeralRunners,
+		RunningEphemeralRunners: status.RunningEphemeralRunners,
+		FailedEphemeralRunners:  status.FailedEphemeralRunners,
+	}
+}
@@ -0,0 +1,69 @@
+package actionsgithubcom
+
+import (
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// scaleDownWindows remembers how many idle runners each ephemeral runner set deleted within the current
+// interval of its scale down rate. The deletions trigger reconciles of their own well within the interval,
+// so the rate cannot be enforced per reconcile.
+type scaleDownWindows struct {
+	mu      sync.Mutex
+	windows map[types.NamespacedName]scaleDownWindow
+}
+
+type scaleDownWindow struct {
+	start   time.Time
+	deleted int
+}
+
+// allowed returns how many idle runners the ephemeral runner set can still delete within the current interval
+// of its scale down rate, and the time left until the next interval.
+func (w *scaleDownWindows) allowed(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, now time.Time) (int, time.Duration) {
+	rate := ephemeralRunnerSet.Spec.ScaleDownRate
+	interval := rate.IntervalOrDefault()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	window, ok := w.windows[client.ObjectKeyFromObject(ephemeralRunnerSet)]
+	if !ok || !now.Before(window.start.Add(interval)) {
+		return rate.MaxDeletions, interval
+	}
+	return max(rate.MaxDeletions-window.deleted, 0), window.start.Add(interval).Sub(now)
+}
+
+// record adds the deleted runners to the current interval of the ephemeral runner set,
+// and starts a new interval if the last one is over.
+func (w *scaleDownWindows) record(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, now time.Time, deleted int) {
+	if deleted <= 0 {
+		return
+	}
+	interval := ephemeralRunnerSet.Spec.ScaleDownRate.IntervalOrDefault()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := client.ObjectKeyFromObject(ephemeralRunnerSet)
+	if w.windows == nil {
+		w.windows = make(map[types.NamespacedName]scaleDownWindow)
+	}
+	window, ok := w.windows[key]
+	if !ok || !now.Before(window.start.Add(interval)) {
+		window = scaleDownWindow{start: now}
+	}
+	window.deleted += deleted
+	w.windows[key] = window
+}
+
+func (w *scaleDownWindows) forget(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.windows, client.ObjectKeyFromObject(ephemeralRunnerSet))
+}
@@ -0,0 +1,228 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// noProvisioner is the provisioner of the storage classes of statically provisioned volumes,
+	// where claims can only be bound to already existing persistent volumes.
+	noProvisioner = "kubernetes.io/no-provisioner"
+
+	annotationKeyDefaultStorageClass = "storageclass.kubernetes.io/is-default-class"
+)
+
+// runnerVolumeClaim is a persistent volume claim created for each runner pod
+// by an ephemeral volume of the runner pod template.
+type runnerVolumeClaim struct {
+	volumeName       string
+	storageClassName string
+	storage          resource.Quantity
+}
+
+// runnerVolumeClaims returns the claims created for the ephemeral volumes of the runner pods.
+//
+// Claims without a storage class are assigned defaultStorageClassName, which is empty when unknown.
+func runnerVolumeClaims(spec *corev1.PodSpec, defaultStorageClassName string) []runnerVolumeClaim {
+	var claims []runnerVolumeClaim
+	for _, volume := range spec.Volumes {
+		if volume.Ephemeral == nil || volume.Ephemeral.VolumeClaimTemplate == nil {
+			continue
+		}
+		claimSpec := volume.Ephemeral.VolumeClaimTemplate.Spec
+		claim := runnerVolumeClaim{
+			volumeName:       volume.Name,
+			storageClassName: defaultStorageClassName,
+			storage:          claimSpec.Resources.Requests[corev1.ResourceStorage],
+		}
+		if claimSpec.StorageClassName != nil {
+			claim.storageClassName = *claimSpec.StorageClassName
+		}
+		claims = append(claims, claim)
+	}
+	return claims
+}
+
+// storageDemand is the amount of each resource quota consumed for the volumes of the runners,
+// in number of claims or in bytes.
+type storageDemand map[corev1.ResourceName]int64
+
+func (d storageDemand) add(claim runnerVolumeClaim) {
+	d[corev1.ResourcePersistentVolumeClaims]++
+	d[corev1.ResourceRequestsStorage] += claim.storage.Value()
+	if claim.storageClassName != "" {
+		d[corev1.ResourceName(claim.storageClassName+".storageclass.storage.k8s.io/"+string(corev1.ResourcePersistentVolumeClaims))]++
+		d[corev1.ResourceName(claim.storageClassName+".storageclass.storage.k8s.io/"+string(corev1.ResourceRequestsStorage))] += claim.storage.Value()
+	}
+}
+
+// fitting returns how many runners using perRunner fit in the available amount.
+func fitting(available, perRunner int64) int {
+	if perRunner <= 0 {
+		return math.MaxInt
+	}
+	if available <= 0 {
+		return 0
+	}
+	return int(min(available/perRunner, math.MaxInt32))
+}
+
+// ephemeralRunnerStorageCapacity returns how many new runners of the ephemeral runner set can be
+// provisioned with the storage available for their volumes, or math.MaxInt when it is not limited.
+//
+// The storage is limited by the resource quotas of the namespace and, when r.TrackPersistentVolumes is set,
+// by the available persistent volumes of the statically provisioned storage classes.
+// The claims of the pending runners that are not created, or not bound yet, are deducted from the available storage.
+func (r *EphemeralRunnerSetReconciler) ephemeralRunnerStorageCapacity(ctx context.Context, ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, pending []*v1alpha1.EphemeralRunner) (int, error) {
+	var storageClasses map[string]*storagev1.StorageClass
+	var defaultStorageClassName string
+	if r.TrackPersistentVolumes {
+		list := new(storagev1.StorageClassList)
+		if err := r.List(ctx, list); err != nil {
+			return 0, fmt.Errorf("failed to list storage classes: %w", err)
+		}
+		storageClasses = make(map[string]*storagev1.StorageClass, len(list.Items))
+		for i := range list.Items {
+			sc := &list.Items[i]
+			storageClasses[sc.Name] = sc
+			if sc.Annotations[annotationKeyDefaultStorageClass] == "true" {
+				defaultStorageClassName = sc.Name
+			}
+		}
+	}
+
+	claims := runnerVolumeClaims(&ephemeralRunnerSet.Spec.EphemeralRunnerSpec.Spec, defaultStorageClassName)
+	if len(claims) == 0 {
+		return math.MaxInt, nil
+	}
+
+	perRunner := storageDemand{}
+	for _, claim := range claims {
+		perRunner.add(claim)
+	}
+
+	// Storage requested by the pending runners but not yet accounted for.
+	reserved := storageDemand{}
+	unbound := make(map[string]int)
+	for _, runner := range pending {
+		for _, claim := range claims {
+			pvc := new(corev1.PersistentVolumeClaim)
+			// The claims of ephemeral volumes are named after the pod and the volume.
+			key := types.NamespacedName{Namespace: runner.Namespace, Name: runner.Name + "-" + claim.volumeName}
+			if err := r.Get(ctx, key, pvc); err != nil {
+				if client.IgnoreNotFound(err) != nil {
+					return 0, fmt.Errorf("failed to get persistent volume claim %s: %w", key, err)
+				}
+				reserved.add(claim)
+				unbound[claim.storageClassName]++
+				continue
+			}
+			if pvc.Status.Phase != corev1.ClaimBound {
+				unbound[claim.storageClassName]++
+			}
+		}
+	}
+
+	capacity := math.MaxInt
+
+	quotas := new(corev1.ResourceQuotaList)
+	if err := r.List(ctx, quotas, client.InNamespace(ephemeralRunnerSet.Namespace)); err != nil {
+		return 0, fmt.Errorf("failed to list resource quotas: %w", err)
+	}
+	for _, quota := range quotas.Items {
+		for name, demand := range perRunner {
+			hard, ok := quota.Status.Hard[name]
+			if !ok {
+				continue
+			}
+			used := quota.Status.Used[name]
+			capacity = min(capacity, fitting(hard.Value()-used.Value()-reserved[name], demand))
+		}
+	}
+
+	if r.TrackPersistentVolumes {
+		staticClaims := make(map[string][]runnerVolumeClaim)
+		for _, claim := range claims {
+			if sc, ok := storageClasses[claim.storageClassName]; ok && sc.Provisioner == noProvisioner {
+				staticClaims[sc.Name] = append(staticClaims[sc.Name], claim)
+			}
+		}
+
+		if len(staticClaims) > 0 {
+			volumes := new(corev1.PersistentVolumeList)
+			if err := r.List(ctx, volumes); err != nil {
+				return 0, fmt.Errorf("failed to list persistent volumes: %w", err)
+			}
+			for storageClassName, claims := range staticClaims {
+				var request resource.Quantity
+				for _, claim := range claims {
+					if claim.storage.Cmp(request) > 0 {
+						request = claim.storage
+					}
+				}
+				available := 0
+				for _, pv := range volumes.Items {
+					if pv.Spec.StorageClassName != storageClassName ||
+						pv.Spec.ClaimRef != nil ||
+						pv.Status.Phase != corev1.VolumeAvailable ||
+						pv.Spec.Capacity.Storage().Cmp(request) < 0 {
+						continue
+					}
+					available++
+				}
+				capacity = min(capacity, fitting(int64(available-unbound[storageClassName]), int64(len(claims))))
+			}
+		}
+	}
+
+	return capacity, nil
+}
+
+// storageLimitedEphemeralRunners remembers how many runners of each ephemeral runner set could
+// not be created for its latest patch, so they are created once the storage becomes available.
+type storageLimitedEphemeralRunners struct {
+	mu      sync.Mutex
+	pending map[types.NamespacedName]storageLimitedPatch
+}
+
+type storageLimitedPatch struct {
+	patchID int
+	count   int
+}
+
+func (s *storageLimitedEphemeralRunners) set(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := client.ObjectKeyFromObject(ephemeralRunnerSet)
+	if count <= 0 {
+		delete(s.pending, key)
+		return
+	}
+	if s.pending == nil {
+		s.pending = make(map[types.NamespacedName]storageLimitedPatch)
+	}
+	s.pending[key] = storageLimitedPatch{patchID: ephemeralRunnerSet.Spec.PatchID, count: count}
+}
+
+// get returns the number of runners that are still to be created for the current patch of the ephemeral runner set.
+func (s *storageLimitedEphemeralRunners) get(ephemeralRunnerSet *v1alpha1.EphemeralRunnerSet) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pending[client.ObjectKeyFromObject(ephemeralRunnerSet)]
+	if !ok || p.patchID != ephemeralRunnerSet.Spec.PatchID {
+		return 0
+	}
+	return p.count
+}
@@ -21,6 +21,15 @@ func SetupIndexers(mgr ctrl.Manager) error {
 		return err
 	}
 
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&corev1.Pod{},
+		nodeNameKey,
+		podNodeNameIndexer,
+	); err != nil {
+		return err
+	}
+
 	if err := mgr.GetFieldIndexer().IndexField(
 		context.Background(),
 		&corev1.ServiceAccount{},
@@ -69,3 +78,11 @@ func newGroupVersionOwnerKindIndexer(ownerKind string, otherOwnerKinds ...string
 		return []string{owner.Name}
 	}
 }
+
+func podNodeNameIndexer(o client.Object) []string {
+	pod, ok := o.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil
+	}
+	return []string{pod.Spec.NodeName}
+}
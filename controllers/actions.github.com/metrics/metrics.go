@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
@@ -66,14 +68,68 @@ var (
 		},
 		labels,
 	)
+	storageLimitedEphemeralRunners = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: githubScaleSetControllerSubsystem,
+			Name:      "storage_limited_ephemeral_runners",
+			Help:      "Number of ephemeral runners not created because the storage for their volumes is not available.",
+		},
+		labels,
+	)
+	scaleDownEmptiedNodes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: githubScaleSetControllerSubsystem,
+			Name:      "scale_down_emptied_nodes_total",
+			Help:      "Number of nodes left without workload pods by removing ephemeral runners on scale down.",
+		},
+		labels,
+	)
+	runnerGroupFailovers = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: githubScaleSetControllerSubsystem,
+			Name:      "runner_group_failovers_total",
+			Help:      "Number of times a runner scale set failed over to its secondary runner group.",
+		},
+		labels,
+	)
+	vaultRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: githubScaleSetControllerSubsystem,
+			Name:      "vault_requests_total",
+			Help:      "Number of requests made to the vaults resolving the GitHub credentials, per vault type, operation and result.",
+		},
+		vaultLabels,
+	)
+	vaultRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: githubScaleSetControllerSubsystem,
+			Name:      "vault_request_duration_seconds",
+			Help:      "Time spent on the requests made to the vaults resolving the GitHub credentials, per vault type, operation and result (in seconds).",
+			Buckets:   prometheus.DefBuckets,
+		},
+		vaultLabels,
+	)
 )
 
+// vaultLabels are the labels of the vault requests, which are not made for a single scale set:
+// the clients are shared by the scale sets using the same credentials.
+var vaultLabels = []string{
+	"vault_type",
+	"operation",
+	"result",
+}
+
 func RegisterMetrics() {
 	metrics.Registry.MustRegister(
 		pendingEphemeralRunners,
 		runningEphemeralRunners,
 		failedEphemeralRunners,
 		runningListeners,
+		storageLimitedEphemeralRunners,
+		scaleDownEmptiedNodes,
+		runnerGroupFailovers,
+		vaultRequests,
+		vaultRequestDuration,
 	)
 }
 
@@ -90,3 +146,25 @@ func AddRunningListener(commonLabels CommonLabels) {
 func SubRunningListener(commonLabels CommonLabels) {
 	runningListeners.With(commonLabels.labels()).Set(0)
 }
+
+func SetStorageLimitedEphemeralRunners(commonLabels CommonLabels, count int) {
+	storageLimitedEphemeralRunners.With(commonLabels.labels()).Set(float64(count))
+}
+
+func AddScaleDownEmptiedNodes(commonLabels CommonLabels, count int) {
+	scaleDownEmptiedNodes.With(commonLabels.labels()).Add(float64(count))
+}
+
+func AddRunnerGroupFailover(commonLabels CommonLabels) {
+	runnerGroupFailovers.With(commonLabels.labels()).Inc()
+}
+
+func ObserveVaultRequest(vaultType, operation, result string, duration time.Duration) {
+	l := prometheus.Labels{
+		"vault_type": vaultType,
+		"operation":  operation,
+		"result":     result,
+	}
+	vaultRequests.With(l).Inc()
+	vaultRequestDuration.With(l).Observe(duration.Seconds())
+}
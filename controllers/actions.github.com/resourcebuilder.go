@@ -7,6 +7,7 @@ import (
 	"maps"
 	"math"
 	"net"
+	"path"
 	"strconv"
 	"strings"
 
@@ -28,6 +29,16 @@ const (
 	jitTokenKey = "jitToken"
 )
 
+// The listener config secret is mounted at scaleSetListenerConfigDir. Besides the config, it holds the GitHub
+// credentials the listener reads from the files of its AppConfig, so that the credentials rotated in the
+// secret are picked up without encoding them into the config or restarting the listener.
+const (
+	scaleSetListenerConfigDir     = "/etc/gha-listener"
+	scaleSetListenerConfigKey     = "config.json"
+	scaleSetListenerAppPrivateKey = "github_app_private_key"
+	scaleSetListenerToken         = "github_token"
+)
+
 var commonLabelKeys = [...]string{
 	LabelKeyKubernetesPartOf,
 	LabelKeyKubernetesComponent,
@@ -95,6 +106,10 @@ func (b *ResourceBuilder) newAutoScalingListener(autoscalingRunnerSet *v1alpha1.
 	if autoscalingRunnerSet.Spec.MinRunners != nil {
 		effectiveMinRunners = *autoscalingRunnerSet.Spec.MinRunners
 	}
+	maxRunnerMinutesPerDay := 0
+	if autoscalingRunnerSet.Spec.MaxRunnerMinutesPerDay != nil {
+		maxRunnerMinutesPerDay = *autoscalingRunnerSet.Spec.MaxRunnerMinutesPerDay
+	}
 
 	labels := b.mergeLabels(autoscalingRunnerSet.Labels, map[string]string{
 		LabelKeyGitHubScaleSetNamespace: autoscalingRunnerSet.Namespace,
@@ -130,6 +145,7 @@ func (b *ResourceBuilder) newAutoScalingListener(autoscalingRunnerSet *v1alpha1.
 			EphemeralRunnerSetName:        ephemeralRunnerSet.Name,
 			MinRunners:                    effectiveMinRunners,
 			MaxRunners:                    effectiveMaxRunners,
+			MaxRunnerMinutesPerDay:        maxRunnerMinutesPerDay,
 			Image:                         image,
 			ImagePullSecrets:              imagePullSecrets,
 			Proxy:                         autoscalingRunnerSet.Spec.Proxy,
@@ -173,12 +189,15 @@ func (b *ResourceBuilder) newScaleSetListenerConfig(autoscalingListener *v1alpha
 		metricsEndpoint = metricsConfig.endpoint
 	}
 
+	// Only the settings of the AutoscalingListener are rendered, the other options of the listener are left to
+	// standalone listeners, since the role of the listener does not grant the access they need.
 	config := ghalistenerconfig.Config{
 		ConfigureUrl:                autoscalingListener.Spec.GitHubConfigUrl,
 		EphemeralRunnerSetNamespace: autoscalingListener.Spec.AutoscalingRunnerSetNamespace,
 		EphemeralRunnerSetName:      autoscalingListener.Spec.EphemeralRunnerSetName,
 		MaxRunners:                  autoscalingListener.Spec.MaxRunners,
 		MinRunners:                  autoscalingListener.Spec.MinRunners,
+		MaxRunnerMinutesPerDay:      autoscalingListener.Spec.MaxRunnerMinutesPerDay,
 		RunnerScaleSetId:            autoscalingListener.Spec.RunnerScaleSetId,
 		RunnerScaleSetName:          autoscalingListener.Spec.AutoscalingRunnerSetName,
 		ServerRootCA:                cert,
@@ -189,17 +208,38 @@ func (b *ResourceBuilder) newScaleSetListenerConfig(autoscalingListener *v1alpha
 		Metrics:                     autoscalingListener.Spec.Metrics,
 	}
 
+	if tls := autoscalingListener.Spec.GitHubServerTLS; tls != nil && tls.Revocation != nil {
+		config.ServerCertRevocation = &ghalistenerconfig.ServerCertRevocation{
+			CRLDistributionPoints: tls.Revocation.CRLDistributionPoints,
+			OCSP:                  tls.Revocation.OCSP,
+			SoftFail:              tls.Revocation.SoftFail,
+			Timeout:               tls.Revocation.Timeout,
+		}
+	}
+
+	var credentials map[string][]byte
 	vault := autoscalingListener.Spec.VaultConfig
 	if vault == nil {
-		config.AppConfig = appConfig
+		config.AppConfig, credentials = scaleSetListenerCredentials(appConfig)
 	} else {
 		config.VaultType = vault.Type
 		config.VaultLookupKey = autoscalingListener.Spec.GitHubConfigSecret
-		config.AzureKeyVaultConfig = &azurekeyvault.Config{
-			TenantID:        vault.AzureKeyVault.TenantID,
-			ClientID:        vault.AzureKeyVault.ClientID,
-			URL:             vault.AzureKeyVault.URL,
-			CertificatePath: vault.AzureKeyVault.CertificatePath,
+		if vault.AzureKeyVault != nil {
+			config.AzureKeyVaultConfig = &azurekeyvault.Config{
+				TenantID:        vault.AzureKeyVault.TenantID,
+				ClientID:        vault.AzureKeyVault.ClientID,
+				URL:             vault.AzureKeyVault.URL,
+				CertificatePath: vault.AzureKeyVault.CertificatePath,
+				CertificateName: vault.AzureKeyVault.CertificateName,
+				SigningKeyURL:   vault.AzureKeyVault.SigningKeyURL,
+			}
+		}
+		if vault.Exec != nil {
+			execConfig, err := b.execVaultConfig(vault)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get exec vault config: %w", err)
+			}
+			config.ExecVaultConfig = &execConfig
 		}
 	}
 
@@ -212,22 +252,50 @@ func (b *ResourceBuilder) newScaleSetListenerConfig(autoscalingListener *v1alpha
 		return nil, fmt.Errorf("failed to encode config: %w", err)
 	}
 
+	data := map[string][]byte{
+		scaleSetListenerConfigKey: buf.Bytes(),
+	}
+	maps.Copy(data, credentials)
+
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      scaleSetListenerConfigName(autoscalingListener),
 			Namespace: autoscalingListener.Namespace,
 		},
-		Data: map[string][]byte{
-			"config.json": buf.Bytes(),
-		},
+		Data: data,
 	}, nil
 }
 
+// scaleSetListenerCredentials returns the AppConfig of the listener config, whose credentials are read from
+// the files of the listener config secret, and the data of the secret holding them.
+func scaleSetListenerCredentials(appConfig *appconfig.AppConfig) (*appconfig.AppConfig, map[string][]byte) {
+	switch {
+	case appConfig == nil:
+		return nil, nil
+	case appConfig.Token != "":
+		return &appconfig.AppConfig{
+			TokenFile: path.Join(scaleSetListenerConfigDir, scaleSetListenerToken),
+		}, map[string][]byte{
+			scaleSetListenerToken: []byte(appConfig.Token),
+		}
+	case appConfig.AppPrivateKey != "":
+		return &appconfig.AppConfig{
+			AppID:             appConfig.AppID,
+			AppInstallationID: appConfig.AppInstallationID,
+			AppPrivateKeyFile: path.Join(scaleSetListenerConfigDir, scaleSetListenerAppPrivateKey),
+		}, map[string][]byte{
+			scaleSetListenerAppPrivateKey: []byte(appConfig.AppPrivateKey),
+		}
+	default:
+		return appConfig, nil
+	}
+}
+
 func (b *ResourceBuilder) newScaleSetListenerPod(autoscalingListener *v1alpha1.AutoscalingListener, podConfig *corev1.Secret, serviceAccount *corev1.ServiceAccount, metricsConfig *listenerMetricsServerConfig, envs ...corev1.EnvVar) (*corev1.Pod, error) {
 	listenerEnv := []corev1.EnvVar{
 		{
 			Name:  "LISTENER_CONFIG_PATH",
-			Value: "/etc/gha-listener/config.json",
+			Value: path.Join(scaleSetListenerConfigDir, scaleSetListenerConfigKey),
 		},
 	}
 	listenerEnv = append(listenerEnv, envs...)
@@ -256,7 +324,7 @@ func (b *ResourceBuilder) newScaleSetListenerPod(autoscalingListener *v1alpha1.A
 				VolumeMounts: []corev1.VolumeMount{
 					{
 						Name:      "listener-config",
-						MountPath: "/etc/gha-listener",
+						MountPath: scaleSetListenerConfigDir,
 						ReadOnly:  true,
 					},
 				},
@@ -547,7 +615,9 @@ func (b *ResourceBuilder) newEphemeralRunnerSet(autoscalingRunnerSet *v1alpha1.A
 				GitHubServerTLS:    autoscalingRunnerSet.Spec.GitHubServerTLS,
 				PodTemplateSpec:    autoscalingRunnerSet.Spec.Template,
 				VaultConfig:        autoscalingRunnerSet.VaultConfig(),
+				JobPodAnnotations:  maps.Clone(autoscalingRunnerSet.Spec.JobPodAnnotations),
 			},
+			ScaleDownRate: autoscalingRunnerSet.Spec.ScaleDownRate.DeepCopy(),
 		},
 	}
 
@@ -561,6 +631,14 @@ func (b *ResourceBuilder) newEphemeralRunner(ephemeralRunnerSet *v1alpha1.Epheme
 
 	annotations := make(map[string]string, len(ephemeralRunnerSet.Annotations)+1)
 	maps.Copy(annotations, ephemeralRunnerSet.Annotations)
+	// The repository hints are maintained by the listener for the set, and assigned to each runner separately.
+	delete(annotations, v1alpha1.EphemeralRunnerSetRepositoryHintsAnnotationKey)
+	// The message session state of the listener only applies to the set.
+	delete(annotations, v1alpha1.EphemeralRunnerSetMessageSessionAnnotationKey)
+	delete(annotations, v1alpha1.EphemeralRunnerSetLastMessageIDAnnotationKey)
+	// The scale decisions of the listener describe the set, not the runners it creates.
+	delete(annotations, v1alpha1.EphemeralRunnerSetScaleReasonAnnotationKey)
+	delete(annotations, v1alpha1.EphemeralRunnerSetCanaryRolledBackAnnotationKey)
 	annotations[AnnotationKeyPatchID] = strconv.Itoa(ephemeralRunnerSet.Spec.PatchID)
 	return &v1alpha1.EphemeralRunner{
 		ObjectMeta: metav1.ObjectMeta{
@@ -712,13 +790,34 @@ func rulesForListenerRole(resourceNames []string) []rbacv1.PolicyRule {
 			APIGroups:     []string{"actions.github.com"},
 			Resources:     []string{"ephemeralrunnersets"},
 			ResourceNames: resourceNames,
-			Verbs:         []string{"patch"},
+			Verbs:         []string{"get", "patch"},
 		},
 		{
 			APIGroups: []string{"actions.github.com"},
 			Resources: []string{"ephemeralrunners", "ephemeralrunners/status"},
 			Verbs:     []string{"patch"},
 		},
+		{
+			// The listener lists the ephemeral runners on start to backfill its scaling state, periodically
+			// to count the runners stuck pending when configured, and watches them when its ephemeral runner
+			// cache is enabled.
+			APIGroups: []string{"actions.github.com"},
+			Resources: []string{"ephemeralrunners"},
+			Verbs:     []string{"list", "watch"},
+		},
+		{
+			// The listener lists the pods of the ephemeral runners with the ephemeral runners stuck pending, to not
+			// count the runners whose pod is scheduled but does not start.
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+			Verbs:     []string{"list"},
+		},
+		{
+			// The listener records an event on the ephemeral runner set when its runner minutes budget is exhausted.
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create"},
+		},
 	}
 }
 
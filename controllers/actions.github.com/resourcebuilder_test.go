@@ -1,15 +1,26 @@
 package actionsgithubcom
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	ghalistenerconfig "github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/actions/actions-runner-controller/vault"
+	"github.com/actions/actions-runner-controller/vault/execvault"
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestLabelPropagation(t *testing.T) {
@@ -109,6 +120,31 @@ func TestLabelPropagation(t *testing.T) {
 	}
 }
 
+func TestNewEphemeralRunnerSetOnlyAnnotations(t *testing.T) {
+	ephemeralRunnerSet := &v1alpha1.EphemeralRunnerSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-scale-set",
+			Namespace: "test-ns",
+			Annotations: map[string]string{
+				AnnotationKeyGitHubRunnerGroupName:                       "test-group",
+				v1alpha1.EphemeralRunnerSetRepositoryHintsAnnotationKey:  "owner/a",
+				v1alpha1.EphemeralRunnerSetMessageSessionAnnotationKey:   `{"id":"session"}`,
+				v1alpha1.EphemeralRunnerSetLastMessageIDAnnotationKey:    "7",
+				v1alpha1.EphemeralRunnerSetScaleReasonAnnotationKey:      "jobs",
+				v1alpha1.EphemeralRunnerSetCanaryRolledBackAnnotationKey: "true",
+			},
+		},
+		Spec: v1alpha1.EphemeralRunnerSetSpec{PatchID: 3},
+	}
+
+	var b ResourceBuilder
+	ephemeralRunner := b.newEphemeralRunner(ephemeralRunnerSet)
+	assert.Equal(t, map[string]string{
+		AnnotationKeyGitHubRunnerGroupName: "test-group",
+		AnnotationKeyPatchID:               "3",
+	}, ephemeralRunner.Annotations)
+}
+
 func TestGitHubURLTrimLabelValues(t *testing.T) {
 	enterprise := strings.Repeat("a", 64)
 	organization := strings.Repeat("b", 64)
@@ -242,3 +278,184 @@ func TestOwnershipRelationships(t *testing.T) {
 	assert.Equal(t, true, *ownerRef.Controller, "Controller flag should be true")
 	assert.Equal(t, true, *ownerRef.BlockOwnerDeletion, "BlockOwnerDeletion flag should be true")
 }
+
+func TestScaleSetListenerConfigExecVault(t *testing.T) {
+	b := ResourceBuilder{
+		SecretResolver: &SecretResolver{
+			execPlugins: execvault.Plugins{
+				"store": {Command: "/opt/vault-plugin/get-secret", Args: []string{"--store", "prod"}},
+			},
+		},
+	}
+
+	newListener := func(plugin string) *v1alpha1.AutoscalingListener {
+		return &v1alpha1.AutoscalingListener{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-listener",
+				Namespace: "arc-systems",
+			},
+			Spec: v1alpha1.AutoscalingListenerSpec{
+				GitHubConfigUrl:               "https://github.com/org/repo",
+				GitHubConfigSecret:            "github-app",
+				RunnerScaleSetId:              1,
+				AutoscalingRunnerSetNamespace: "arc-runners",
+				AutoscalingRunnerSetName:      "test-asrs",
+				EphemeralRunnerSetName:        "test-ers",
+				MaxRunners:                    10,
+				VaultConfig: &v1alpha1.VaultConfig{
+					Type: vault.VaultTypeExec,
+					Exec: &v1alpha1.ExecVaultConfig{Plugin: plugin},
+				},
+			},
+		}
+	}
+
+	t.Run("configured plugin", func(t *testing.T) {
+		secret, err := b.newScaleSetListenerConfig(newListener("store"), nil, nil, "")
+		require.NoError(t, err)
+
+		var config ghalistenerconfig.Config
+		require.NoError(t, json.Unmarshal(secret.Data["config.json"], &config))
+		require.NotNil(t, config.ExecVaultConfig)
+		assert.Equal(t, "/opt/vault-plugin/get-secret", config.ExecVaultConfig.Command)
+		assert.Equal(t, []string{"--store", "prod"}, config.ExecVaultConfig.Args)
+	})
+
+	t.Run("unknown plugin", func(t *testing.T) {
+		_, err := b.newScaleSetListenerConfig(newListener("/bin/sh"), nil, nil, "")
+		assert.ErrorContains(t, err, `plugin "/bin/sh" is not one of the plugins the controller is configured with`)
+	})
+}
+
+func TestScaleSetListenerConfigRevocation(t *testing.T) {
+	b := ResourceBuilder{}
+	listener := &v1alpha1.AutoscalingListener{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-listener",
+			Namespace: "arc-systems",
+		},
+		Spec: v1alpha1.AutoscalingListenerSpec{
+			GitHubConfigUrl:               "https://ghes.example.com/org/repo",
+			GitHubConfigSecret:            "github-app",
+			RunnerScaleSetId:              1,
+			AutoscalingRunnerSetNamespace: "arc-runners",
+			AutoscalingRunnerSetName:      "test-asrs",
+			EphemeralRunnerSetName:        "test-ers",
+			MaxRunners:                    10,
+			GitHubServerTLS: &v1alpha1.TLSConfig{
+				Revocation: &v1alpha1.CertificateRevocationConfig{OCSP: true, SoftFail: true},
+			},
+		},
+	}
+
+	secret, err := b.newScaleSetListenerConfig(listener, &appconfig.AppConfig{Token: "token"}, nil, "")
+	require.NoError(t, err)
+
+	var config ghalistenerconfig.Config
+	require.NoError(t, json.Unmarshal(secret.Data["config.json"], &config))
+	assert.Equal(t, &ghalistenerconfig.ServerCertRevocation{OCSP: true, SoftFail: true}, config.ServerCertRevocation)
+
+	_, err = revocationClientOption(listener.Spec.GitHubConfigUrl, listener.Spec.GitHubServerTLS.Revocation, nil)
+	assert.NoError(t, err)
+	_, err = revocationClientOption("https://github.com/org/repo", listener.Spec.GitHubServerTLS.Revocation, nil)
+	assert.ErrorContains(t, err, "only supported for GitHub Enterprise Server")
+}
+
+func TestScaleSetListenerConfigRunnerMinutesBudget(t *testing.T) {
+	b := ResourceBuilder{}
+	listener := &v1alpha1.AutoscalingListener{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-listener",
+			Namespace: "arc-systems",
+		},
+		Spec: v1alpha1.AutoscalingListenerSpec{
+			GitHubConfigUrl:               "https://github.com/org/repo",
+			GitHubConfigSecret:            "github-app",
+			RunnerScaleSetId:              1,
+			AutoscalingRunnerSetNamespace: "arc-runners",
+			AutoscalingRunnerSetName:      "test-asrs",
+			EphemeralRunnerSetName:        "test-ers",
+			MaxRunners:                    10,
+			MaxRunnerMinutesPerDay:        600,
+		},
+	}
+
+	secret, err := b.newScaleSetListenerConfig(listener, &appconfig.AppConfig{Token: "token"}, nil, "")
+	require.NoError(t, err)
+
+	var config ghalistenerconfig.Config
+	require.NoError(t, json.Unmarshal(secret.Data["config.json"], &config))
+	assert.Equal(t, 600, config.MaxRunnerMinutesPerDay)
+}
+
+func TestScaleSetListenerConfigCredentials(t *testing.T) {
+	b := ResourceBuilder{}
+	listener := &v1alpha1.AutoscalingListener{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-listener",
+			Namespace: "arc-systems",
+		},
+		Spec: v1alpha1.AutoscalingListenerSpec{
+			GitHubConfigUrl:               "https://github.com/org/repo",
+			GitHubConfigSecret:            "github-app",
+			RunnerScaleSetId:              1,
+			AutoscalingRunnerSetNamespace: "arc-runners",
+			AutoscalingRunnerSetName:      "test-asrs",
+			EphemeralRunnerSetName:        "test-ers",
+			MaxRunners:                    10,
+		},
+	}
+
+	t.Run("GitHub App", func(t *testing.T) {
+		secret, err := b.newScaleSetListenerConfig(listener, &appconfig.AppConfig{
+			AppID:             "1",
+			AppInstallationID: 2,
+			AppPrivateKey:     "private key",
+		}, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, "private key", string(secret.Data["github_app_private_key"]))
+		assert.NotContains(t, string(secret.Data["config.json"]), "private key", "the private key is not encoded into the config")
+
+		var config ghalistenerconfig.Config
+		require.NoError(t, json.Unmarshal(secret.Data["config.json"], &config))
+		assert.Equal(t, &appconfig.AppConfig{
+			AppID:             "1",
+			AppInstallationID: 2,
+			AppPrivateKeyFile: "/etc/gha-listener/github_app_private_key",
+		}, config.AppConfig)
+	})
+
+	t.Run("token", func(t *testing.T) {
+		secret, err := b.newScaleSetListenerConfig(listener, &appconfig.AppConfig{Token: "token"}, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, "token", string(secret.Data["github_token"]))
+
+		var config ghalistenerconfig.Config
+		require.NoError(t, json.Unmarshal(secret.Data["config.json"], &config))
+		assert.Equal(t, &appconfig.AppConfig{TokenFile: "/etc/gha-listener/github_token"}, config.AppConfig)
+	})
+
+	t.Run("rotated", func(t *testing.T) {
+		secret, err := b.newScaleSetListenerConfig(listener, &appconfig.AppConfig{Token: "token"}, nil, "")
+		require.NoError(t, err)
+		s := runtime.NewScheme()
+		require.NoError(t, scheme.AddToScheme(s))
+		r := &AutoscalingListenerReconciler{
+			Client: clientfake.NewClientBuilder().WithScheme(s).WithObjects(secret).Build(),
+		}
+
+		require.NoError(t, r.updateListenerCredentials(context.Background(), listener, &appconfig.AppConfig{Token: "rotated"}, logr.Discard()))
+		var updated corev1.Secret
+		require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(secret), &updated))
+		assert.Equal(t, "rotated", string(updated.Data["github_token"]))
+		assert.Equal(t, secret.Data["config.json"], updated.Data["config.json"])
+
+		require.NoError(t, r.updateListenerCredentials(context.Background(), listener, &appconfig.AppConfig{
+			AppID:             "1",
+			AppInstallationID: 2,
+			AppPrivateKey:     "private key",
+		}, logr.Discard()))
+		require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(secret), &updated))
+		assert.NotContains(t, updated.Data, "github_app_private_key", "the other kind of credentials is not added to the config the listener reads")
+	})
+}
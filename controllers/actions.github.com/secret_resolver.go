@@ -2,17 +2,25 @@ package actionsgithubcom
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/revocation"
+	"github.com/actions/actions-runner-controller/controllers/actions.github.com/metrics"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/vault"
 	"github.com/actions/actions-runner-controller/vault/azurekeyvault"
+	"github.com/actions/actions-runner-controller/vault/execvault"
+	"github.com/actions/actions-runner-controller/vault/kubernetesvault"
 	"golang.org/x/net/http/httpproxy"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -22,10 +30,36 @@ import (
 type SecretResolver struct {
 	k8sClient   client.Client
 	multiClient actions.MultiClient
+	execPlugins execvault.Plugins
+
+	// vaultCache configures the caches of the vaults, which are not cached if it is nil.
+	vaultCache *vault.CacheOptions
+	// vaultCaches are the caches of the vaults, by the ID of the vault config. They are shared by all the
+	// resources of a scale set, so that its vault is not read on every reconcile.
+	vaultCachesMu sync.Mutex
+	vaultCaches   map[string]*vault.Cache
 }
 
 type SecretResolverOption func(*SecretResolver)
 
+// WithExecVaultPlugins sets the plugins the resources of the exec vault type can reference by name.
+func WithExecVaultPlugins(plugins execvault.Plugins) SecretResolverOption {
+	return func(sr *SecretResolver) {
+		sr.execPlugins = plugins
+	}
+}
+
+// WithVaultCache caches the secrets read from the vaults, so that the vaults are not read on every reconcile.
+// The caches are kept in memory, and are not persisted.
+func WithVaultCache(options vault.CacheOptions) SecretResolverOption {
+	return func(sr *SecretResolver) {
+		options.Store = nil
+		options.Key = nil
+		sr.vaultCache = &options
+		sr.vaultCaches = make(map[string]*vault.Cache)
+	}
+}
+
 func NewSecretResolver(k8sClient client.Client, multiClient actions.MultiClient, opts ...SecretResolverOption) *SecretResolver {
 	if k8sClient == nil {
 		panic("k8sClient must not be nil")
@@ -79,6 +113,7 @@ func (sr *SecretResolver) GetActionsService(ctx context.Context, obj ActionsGitH
 	}
 
 	var clientOptions []actions.ClientOption
+	var revocationProxy revocation.ProxyFunc
 	if proxy := obj.GitHubProxy(); proxy != nil {
 		config := &httpproxy.Config{
 			NoProxy: strings.Join(proxy.NoProxy, ","),
@@ -119,6 +154,7 @@ func (sr *SecretResolver) GetActionsService(ctx context.Context, obj ActionsGitH
 		proxyFunc := func(req *http.Request) (*url.URL, error) {
 			return config.ProxyFunc()(req.URL)
 		}
+		revocationProxy = config.ProxyFunc()
 
 		clientOptions = append(clientOptions, actions.WithProxy(proxyFunc))
 	}
@@ -148,6 +184,14 @@ func (sr *SecretResolver) GetActionsService(ctx context.Context, obj ActionsGitH
 		clientOptions = append(clientOptions, actions.WithRootCAs(pool))
 	}
 
+	if tlsConfig != nil && tlsConfig.Revocation != nil {
+		option, err := revocationClientOption(obj.GitHubConfigUrl(), tlsConfig.Revocation, revocationProxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get certificate revocation checker: %w", err)
+		}
+		clientOptions = append(clientOptions, option)
+	}
+
 	return sr.multiClient.GetClientFor(
 		ctx,
 		obj.GitHubConfigUrl(),
@@ -157,6 +201,42 @@ func (sr *SecretResolver) GetActionsService(ctx context.Context, obj ActionsGitH
 	)
 }
 
+// revocationClientOption returns the option checking the revocation of the certificates of the GitHub Enterprise
+// Server of the config URL, the connections to the other hosts not being checked.
+func revocationClientOption(githubConfigURL string, config *v1alpha1.CertificateRevocationConfig, proxy revocation.ProxyFunc) (actions.ClientOption, error) {
+	ghConfig, err := actions.ParseGitHubConfigFromURL(githubConfigURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub config URL: %w", err)
+	}
+	if ghConfig.IsHosted {
+		return nil, fmt.Errorf("certificate revocation checks are only supported for GitHub Enterprise Server, not %q", githubConfigURL)
+	}
+	if !config.CRLDistributionPoints && !config.OCSP {
+		return nil, fmt.Errorf("certificate revocation checks require crlDistributionPoints or ocsp to be set")
+	}
+
+	options := revocation.Options{
+		Hosts:                 []string{ghConfig.ConfigURL.Hostname()},
+		CRLDistributionPoints: config.CRLDistributionPoints,
+		OCSP:                  config.OCSP,
+		SoftFail:              config.SoftFail,
+		Proxy:                 proxy,
+	}
+	if config.Timeout != nil {
+		options.Timeout = config.Timeout.Duration
+	}
+	checker, err := revocation.New(options)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode certificate revocation config: %w", err)
+	}
+	return actions.WithVerifyConnection(string(id), checker.VerifyConnection), nil
+}
+
 func (sr *SecretResolver) resolverForObject(ctx context.Context, obj ActionsGitHubObject) (resolver, error) {
 	vaultConfig := obj.VaultConfig()
 	if vaultConfig == nil || vaultConfig.Type == "" {
@@ -182,6 +262,17 @@ func (sr *SecretResolver) resolverForObject(ctx context.Context, obj ActionsGitH
 		proxy = p
 	}
 
+	v, err := sr.cachedVault(obj.GetNamespace(), vaultConfig, proxy, func() (vault.Vault, error) {
+		return sr.newVault(obj, vaultConfig, proxy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &vaultResolver{vault: v}, nil
+}
+
+// newVault creates the vault of the vault config, instrumented with the vault metrics.
+func (sr *SecretResolver) newVault(obj ActionsGitHubObject, vaultConfig *v1alpha1.VaultConfig, proxy *httpproxy.Config) (vault.Vault, error) {
 	switch vaultConfig.Type {
 	case vault.VaultTypeAzureKeyVault:
 		akv, err := azurekeyvault.New(azurekeyvault.Config{
@@ -189,20 +280,86 @@ func (sr *SecretResolver) resolverForObject(ctx context.Context, obj ActionsGitH
 			ClientID:        vaultConfig.AzureKeyVault.ClientID,
 			URL:             vaultConfig.AzureKeyVault.URL,
 			CertificatePath: vaultConfig.AzureKeyVault.CertificatePath,
+			CertificateName: vaultConfig.AzureKeyVault.CertificateName,
+			SigningKeyURL:   vaultConfig.AzureKeyVault.SigningKeyURL,
 			Proxy:           proxy,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Azure Key Vault client: %v", err)
 		}
-		return &vaultResolver{
-			vault: akv,
-		}, nil
+		return vault.Instrument(akv, vault.VaultTypeAzureKeyVault, vaultObserver), nil
+
+	case vault.VaultTypeKubernetes:
+		return vault.Instrument(kubernetesvault.New(sr.k8sClient, obj.GetNamespace()), vault.VaultTypeKubernetes, vaultObserver), nil
+
+	case vault.VaultTypeExec:
+		cfg, err := sr.execVaultConfig(vaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		ev, err := execvault.New(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create exec vault: %v", err)
+		}
+		return vault.Instrument(ev, vault.VaultTypeExec, vaultObserver), nil
 
 	default:
 		return nil, fmt.Errorf("unknown vault type %q", vaultConfig.Type)
 	}
 }
 
+// cachedVault returns the cache of the vault of the vault config in the namespace, creating the vault if it is
+// not cached yet. The vault is created on every call if the vaults are not cached.
+//
+// The caches are identified by the namespace, the vault config, and its resolved proxy, so that a vault
+// whose config or proxy credentials changed is created again.
+func (sr *SecretResolver) cachedVault(namespace string, vaultConfig *v1alpha1.VaultConfig, proxy *httpproxy.Config, create func() (vault.Vault, error)) (vault.Vault, error) {
+	if sr.vaultCache == nil {
+		return create()
+	}
+
+	config, err := json.Marshal(vaultConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vault config: %w", err)
+	}
+	h := sha256.New()
+	for _, part := range []string{namespace, string(config)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if proxy != nil {
+		for _, part := range []string{proxy.HTTPProxy, proxy.HTTPSProxy, proxy.NoProxy} {
+			h.Write([]byte(part))
+			h.Write([]byte{0})
+		}
+	}
+	id := hex.EncodeToString(h.Sum(nil))
+
+	sr.vaultCachesMu.Lock()
+	defer sr.vaultCachesMu.Unlock()
+	if cache, ok := sr.vaultCaches[id]; ok {
+		return cache, nil
+	}
+	v, err := create()
+	if err != nil {
+		return nil, err
+	}
+	cache, err := vault.NewCache(v, *sr.vaultCache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault cache: %w", err)
+	}
+	sr.vaultCaches[id] = cache
+	return cache, nil
+}
+
+// execVaultConfig returns the config of the plugin the vault config references.
+func (sr *SecretResolver) execVaultConfig(vaultConfig *v1alpha1.VaultConfig) (execvault.Config, error) {
+	if vaultConfig.Exec == nil {
+		return execvault.Config{}, fmt.Errorf("exec vault config is not set")
+	}
+	return sr.execPlugins.Config(vaultConfig.Exec.Plugin)
+}
+
 type resolver interface {
 	appConfig(ctx context.Context, key string) (*appconfig.AppConfig, error)
 	proxyCredentials(ctx context.Context, key string) (*url.Userinfo, error)
@@ -247,6 +404,11 @@ func (r *k8sResolver) proxyCredentials(ctx context.Context, key string) (*url.Us
 	), nil
 }
 
+// vaultObserver exports the metrics of the requests made to the vaults.
+var vaultObserver = vault.ObserverFunc(func(vaultType vault.VaultType, operation string, duration time.Duration, err error) {
+	metrics.ObserveVaultRequest(vaultType.String(), operation, vault.RequestResult(err), duration)
+})
+
 type vaultResolver struct {
 	vault vault.Vault
 }
@@ -257,7 +419,7 @@ func (r *vaultResolver) appConfig(ctx context.Context, key string) (*appconfig.A
 		return nil, fmt.Errorf("failed to resolve secret: %v", err)
 	}
 
-	return appconfig.FromJSONString(val)
+	return vault.ResolveAppConfig(ctx, r.vault, val)
 }
 
 func (r *vaultResolver) proxyCredentials(ctx context.Context, key string) (*url.Userinfo, error) {
@@ -0,0 +1,72 @@
+package actionsgithubcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1"
+	"github.com/actions/actions-runner-controller/github/actions/fake"
+	"github.com/actions/actions-runner-controller/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestSecretResolverVaultCache(t *testing.T) {
+	s := runtime.NewScheme()
+	require.NoError(t, scheme.AddToScheme(s))
+	gets := 0
+	k8sClient := clientfake.NewClientBuilder().
+		WithScheme(s).
+		WithObjects(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "github-config", Namespace: "arc-runners"},
+			Data:       map[string][]byte{"github_token": []byte("token")},
+		}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				gets++
+				return c.Get(ctx, key, obj, opts...)
+			},
+		}).
+		Build()
+
+	newRunnerSet := func(name string) *v1alpha1.AutoscalingRunnerSet {
+		return &v1alpha1.AutoscalingRunnerSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "arc-runners"},
+			Spec: v1alpha1.AutoscalingRunnerSetSpec{
+				GitHubConfigUrl:    "https://github.com/org/repo",
+				GitHubConfigSecret: "arc-runners/github-config",
+				VaultConfig:        &v1alpha1.VaultConfig{Type: vault.VaultTypeKubernetes},
+			},
+		}
+	}
+
+	t.Run("uncached", func(t *testing.T) {
+		gets = 0
+		sr := NewSecretResolver(k8sClient, fake.NewMultiClient())
+		for range 2 {
+			appConfig, err := sr.GetAppConfig(context.Background(), newRunnerSet("set"))
+			require.NoError(t, err)
+			assert.Equal(t, "token", appConfig.Token)
+		}
+		assert.Equal(t, 2, gets, "the vault is read on every call")
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		gets = 0
+		sr := NewSecretResolver(k8sClient, fake.NewMultiClient(), WithVaultCache(vault.CacheOptions{TTL: time.Hour}))
+		for _, name := range []string{"set", "set", "other-set"} {
+			appConfig, err := sr.GetAppConfig(context.Background(), newRunnerSet(name))
+			require.NoError(t, err)
+			assert.Equal(t, "token", appConfig.Token)
+		}
+		assert.Equal(t, 1, gets, "the cache is shared by the resources with the same vault config")
+	})
+}
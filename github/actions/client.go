@@ -3,6 +3,8 @@ package actions
 import (
 	"bytes"
 	"context"
+	"crypto"
+	crand "crypto/rand"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
@@ -23,6 +25,10 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-retryablehttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -31,6 +37,8 @@ const (
 	apiVersionQueryParam = "api-version=6.0-preview"
 )
 
+var tracer = otel.Tracer("github.com/actions/actions-runner-controller/github/actions")
+
 // Header used to propagate capacity information to the back-end
 const HeaderScaleSetMaxCapacity = "X-ScaleSetMaxCapacity"
 
@@ -98,6 +106,11 @@ type Client struct {
 	retryMax     int
 	retryWaitMax time.Duration
 
+	timeout             time.Duration
+	tlsHandshakeTimeout time.Duration
+	maxIdleConns        int
+	longPollTimeout     time.Duration
+
 	creds     *ActionsAuth
 	config    *GitHubConfig
 	logger    logr.Logger
@@ -105,8 +118,12 @@ type Client struct {
 
 	rootCAs               *x509.CertPool
 	tlsInsecureSkipVerify bool
+	verifyConnection      func(tls.ConnectionState) error
+	verifyConnectionID    string
 
 	proxyFunc ProxyFunc
+
+	wrapTransport func(http.RoundTripper) http.RoundTripper
 }
 
 var _ ActionsService = &Client{}
@@ -167,6 +184,16 @@ func WithRootCAs(rootCAs *x509.CertPool) ClientOption {
 	}
 }
 
+// WithVerifyConnection sets a check of the TLS connections run after the certificate chains are verified,
+// e.g. of the revocation of the certificates. The id identifies the configuration of the check, so that
+// the clients of the MultiClient are not reused once it changes.
+func WithVerifyConnection(id string, verify func(tls.ConnectionState) error) ClientOption {
+	return func(c *Client) {
+		c.verifyConnection = verify
+		c.verifyConnectionID = id
+	}
+}
+
 func WithoutTLSVerify() ClientOption {
 	return func(c *Client) {
 		c.tlsInsecureSkipVerify = true
@@ -179,6 +206,44 @@ func WithProxy(proxyFunc ProxyFunc) ClientOption {
 	}
 }
 
+// WithTimeout sets the time limit of every attempt of a request, including reading the response body.
+// It must be longer than the long poll of GetMessage. Defaults to 5 minutes.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithTLSHandshakeTimeout sets the time limit of the TLS handshakes. Defaults to 10 seconds.
+func WithTLSHandshakeTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.tlsHandshakeTimeout = timeout
+	}
+}
+
+// WithMaxIdleConns sets the number of idle connections kept alive, per host as well as in total.
+func WithMaxIdleConns(maxIdleConns int) ClientOption {
+	return func(c *Client) {
+		c.maxIdleConns = maxIdleConns
+	}
+}
+
+// WithLongPollTimeout bounds the time GetMessage waits for a message. A long poll ending at this bound
+// returns no message, like a long poll ended by the server. It is unbounded by default.
+func WithLongPollTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.longPollTimeout = timeout
+	}
+}
+
+// WithTransport wraps the transport of the client, which sends every attempt of a request,
+// including the retries.
+func WithTransport(wrap func(http.RoundTripper) http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.wrapTransport = wrap
+	}
+}
+
 func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOption) (*Client, error) {
 	config, err := ParseGitHubConfigFromURL(githubConfigURL)
 	if err != nil {
@@ -193,6 +258,7 @@ func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOpti
 		// retryablehttp defaults
 		retryMax:     4,
 		retryWaitMax: 30 * time.Second,
+		timeout:      5 * time.Minute, // timeout must be > 1m to accomodate long polling
 		userAgent: UserAgentInfo{
 			Version:    build.Version,
 			CommitSHA:  build.CommitSHA,
@@ -210,7 +276,7 @@ func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOpti
 	retryClient.RetryMax = ac.retryMax
 	retryClient.RetryWaitMax = ac.retryWaitMax
 
-	retryClient.HTTPClient.Timeout = 5 * time.Minute // timeout must be > 1m to accomodate long polling
+	retryClient.HTTPClient.Timeout = ac.timeout
 
 	transport, ok := retryClient.HTTPClient.Transport.(*http.Transport)
 	if !ok {
@@ -230,9 +296,24 @@ func NewClient(githubConfigURL string, creds *ActionsAuth, options ...ClientOpti
 		transport.TLSClientConfig.InsecureSkipVerify = true
 	}
 
+	if ac.verifyConnection != nil {
+		transport.TLSClientConfig.VerifyConnection = ac.verifyConnection
+	}
+
 	transport.Proxy = ac.proxyFunc
 
+	if ac.tlsHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = ac.tlsHandshakeTimeout
+	}
+	if ac.maxIdleConns > 0 {
+		transport.MaxIdleConns = ac.maxIdleConns
+		transport.MaxIdleConnsPerHost = ac.maxIdleConns
+	}
+
 	retryClient.HTTPClient.Transport = transport
+	if ac.wrapTransport != nil {
+		retryClient.HTTPClient.Transport = ac.wrapTransport(transport)
+	}
 	ac.Client = retryClient.StandardClient()
 
 	return ac, nil
@@ -260,6 +341,9 @@ func (c *Client) Identifier() string {
 			c.creds.AppCreds.AppInstallationID,
 			c.creds.AppCreds.AppPrivateKey,
 		)
+		if signer, ok := c.creds.AppCreds.AppPrivateKeySigner.(fmt.Stringer); ok {
+			identifier += fmt.Sprintf(",signer:%q", signer.String())
+		}
 	}
 
 	if c.rootCAs != nil {
@@ -268,11 +352,34 @@ func (c *Client) Identifier() string {
 		identifier += fmt.Sprintf("rootCAs:%q", c.rootCAs.Subjects())
 	}
 
+	if c.verifyConnection != nil {
+		identifier += fmt.Sprintf("verifyConnection:%q", c.verifyConnectionID)
+	}
+
 	return uuid.NewHash(sha256.New(), uuid.NameSpaceOID, []byte(identifier), 6).String()
 }
 
-func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	resp, err := c.Client.Do(req)
+func (c *Client) Do(req *http.Request) (_ *http.Response, err error) {
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	resp, err := c.Client.Do(req.WithContext(ctx))
+	if resp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
 	if err != nil {
 		// If we have a response even with an error, include the status code
 		if resp != nil {
@@ -413,6 +520,9 @@ func (c *Client) GetRunnerScaleSetById(ctx context.Context, runnerScaleSetId int
 	return runnerScaleSet, nil
 }
 
+// ErrRunnerGroupNotFound is returned by GetRunnerGroupByName when no runner group exists with the name.
+var ErrRunnerGroupNotFound = errors.New("no runner group found")
+
 func (c *Client) GetRunnerGroupByName(ctx context.Context, runnerGroup string) (*RunnerGroup, error) {
 	path := fmt.Sprintf("/_apis/runtime/runnergroups/?groupName=%s", runnerGroup)
 	req, err := c.NewActionsServiceRequest(ctx, http.MethodGet, path, nil)
@@ -455,7 +565,7 @@ func (c *Client) GetRunnerGroupByName(ctx context.Context, runnerGroup string) (
 		return nil, &ActionsError{
 			StatusCode: resp.StatusCode,
 			ActivityID: resp.Header.Get(HeaderActionsActivityID),
-			Err:        fmt.Errorf("no runner group found with name %q", runnerGroup),
+			Err:        fmt.Errorf("%w with name %q", ErrRunnerGroupNotFound, runnerGroup),
 		}
 	}
 
@@ -569,7 +679,14 @@ func (c *Client) GetMessage(ctx context.Context, messageQueueUrl, messageQueueAc
 		return nil, fmt.Errorf("maxCapacity must be greater than or equal to 0")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	pollCtx := ctx
+	if c.longPollTimeout > 0 {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, c.longPollTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(pollCtx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new request with context: %w", err)
 	}
@@ -581,6 +698,10 @@ func (c *Client) GetMessage(ctx context.Context, messageQueueUrl, messageQueueAc
 
 	resp, err := c.Do(req)
 	if err != nil {
+		if ctx.Err() == nil && errors.Is(pollCtx.Err(), context.DeadlineExceeded) {
+			// The long poll timed out on the client side, there is no message yet.
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to issue the request: %w", err)
 	}
 
@@ -1227,6 +1348,10 @@ func createJWTForGitHubApp(appAuth *GitHubAppAuth) (string, error) {
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 
+	if appAuth.AppPrivateKeySigner != nil {
+		return signJWT(token, appAuth.AppPrivateKeySigner)
+	}
+
 	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(appAuth.AppPrivateKey))
 	if err != nil {
 		return "", fmt.Errorf("failed to parse RSA private key from PEM: %w", err)
@@ -1235,6 +1360,20 @@ func createJWTForGitHubApp(appAuth *GitHubAppAuth) (string, error) {
 	return token.SignedString(privateKey)
 }
 
+// signJWT signs the RS256 token with the signer, which holds the private key of the GitHub App.
+func signJWT(token *jwt.Token, signer crypto.Signer) (string, error) {
+	signingString, err := token.SigningString()
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(signingString))
+	signature, err := signer.Sign(crand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signingString + "." + jwt.EncodeSegment(signature), nil
+}
+
 // Returns slice of body without utf-8 byte order mark.
 // If BOM does not exist body is returned unchanged.
 func trimByteOrderMark(body []byte) []byte {
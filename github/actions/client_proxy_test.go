@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/github/actions/testserver"
@@ -37,3 +38,43 @@ func TestClientProxy(t *testing.T) {
 
 	assert.True(t, serverCalled)
 }
+
+func TestClientTransport(t *testing.T) {
+	attempts := 0
+	server := testserver.New(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var statusCodes []int
+	wrap := func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if resp != nil {
+				statusCodes = append(statusCodes, resp.StatusCode)
+			}
+			return resp, err
+		})
+	}
+
+	c, err := actions.NewClient("http://github.com/org/repo", nil, actions.WithTransport(wrap), actions.WithRetryWaitMax(time.Millisecond))
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	_, err = c.Do(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{http.StatusServiceUnavailable, http.StatusOK}, statusCodes, "the transport sees every attempt")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
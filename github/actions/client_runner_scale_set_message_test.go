@@ -152,6 +152,42 @@ func TestGetMessage(t *testing.T) {
 		assert.ErrorAs(t, err, &expectedErr)
 		assert.Equal(t, http.StatusBadRequest, expectedErr.StatusCode)
 	})
+
+	t.Run("Long poll timeout returns no message", func(t *testing.T) {
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth, actions.WithLongPollTimeout(50*time.Millisecond))
+		require.NoError(t, err)
+
+		got, err := client.GetMessage(ctx, server.URL, token, 0, 10)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = client.GetMessage(cancelledCtx, server.URL, token, 0, 10)
+		assert.ErrorIs(t, err, context.Canceled, "a cancelled context is not a long poll timeout")
+	})
+
+	t.Run("Timeout bounds every attempt", func(t *testing.T) {
+		server := newActionsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+
+		client, err := actions.NewClient(server.configURLForOrg("my-org"), auth, actions.WithTimeout(50*time.Millisecond), actions.WithRetryMax(0))
+		require.NoError(t, err)
+
+		_, err = client.GetMessage(ctx, server.URL, token, 0, 10)
+		assert.Error(t, err)
+	})
 }
 
 func TestDeleteMessage(t *testing.T) {
@@ -213,6 +213,7 @@ func TestGetRunnerGroupByName(t *testing.T) {
 
 		got, err := client.GetRunnerGroupByName(ctx, runnerGroupName)
 		assert.ErrorContains(t, err, "no runner group found with name")
+		assert.ErrorIs(t, err, actions.ErrRunnerGroupNotFound)
 		assert.Nil(t, got)
 	})
 }
@@ -45,6 +45,13 @@ func WithUpdateRunnerScaleSet(scaleSet *actions.RunnerScaleSet, err error) Optio
 	}
 }
 
+func WithGetAcquirableJobs(jobs *actions.AcquirableJobList, err error) Option {
+	return func(f *FakeClient) {
+		f.getAcquirableJobsResult.AcquirableJobList = jobs
+		f.getAcquirableJobsResult.err = err
+	}
+}
+
 var defaultRunnerScaleSet = &actions.RunnerScaleSet{
 	Id:                 1,
 	Name:               "testset",
@@ -2,6 +2,7 @@ package actions
 
 import (
 	"context"
+	"crypto"
 	"fmt"
 	"sync"
 
@@ -26,6 +27,8 @@ type GitHubAppAuth struct {
 	AppID             string
 	AppInstallationID int64
 	AppPrivateKey     string
+	// AppPrivateKeySigner signs the JWTs in place of AppPrivateKey, if set.
+	AppPrivateKeySigner crypto.Signer
 }
 
 type ActionsAuth struct {
@@ -61,9 +64,10 @@ func (m *multiClient) GetClientFor(ctx context.Context, githubConfigURL string,
 		creds.Token = appConfig.Token
 	} else {
 		creds.AppCreds = &GitHubAppAuth{
-			AppID:             appConfig.AppID,
-			AppInstallationID: appConfig.AppInstallationID,
-			AppPrivateKey:     appConfig.AppPrivateKey,
+			AppID:               appConfig.AppID,
+			AppInstallationID:   appConfig.AppInstallationID,
+			AppPrivateKey:       appConfig.AppPrivateKey,
+			AppPrivateKeySigner: appConfig.AppPrivateKeySigner,
 		}
 	}
 
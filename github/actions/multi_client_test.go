@@ -2,11 +2,14 @@ package actions
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"fmt"
 	"testing"
 
 	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
 	"github.com/go-logr/logr"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -129,3 +132,22 @@ etFcaQuTHEZyRhhJ4BU=
 	}
 	fmt.Println(jwt)
 }
+
+func TestCreateJWTWithSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	auth := &GitHubAppAuth{
+		AppID:               "123",
+		AppPrivateKeySigner: key,
+	}
+	token, err := createJWTForGitHubApp(auth)
+	require.NoError(t, err)
+
+	claims := &jwt.RegisteredClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return &key.PublicKey, nil
+	})
+	require.NoError(t, err, "the JWT is signed by the signer")
+	assert.Equal(t, "123", claims.Issuer)
+}
@@ -35,7 +35,25 @@ var (
 	}
 )
 
-func NewLogger(logLevel string, logFormat string) (logr.Logger, error) {
+// Option configures the logger created by NewLogger.
+type Option func(*zap.Options)
+
+// WithSampling samples the entries of the same level and message: within every tick, the first entries are logged,
+// then every thereafter-th entry. If thereafter is zero, the entries after the first ones are dropped, which
+// rate-limits them. The entries are not sampled at the levels more verbose than debug, which the sampler does not support.
+func WithSampling(tick time.Duration, first, thereafter int) Option {
+	return func(o *zap.Options) {
+		if o.Level.Enabled(zapcore.Level(-2)) {
+			return
+		}
+		o.ZapOpts = append(o.ZapOpts, zaplib.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, tick, first, thereafter)
+		}))
+	}
+}
+
+// NewLogger creates a logger of the level and format.
+func NewLogger(logLevel string, logFormat string, options ...Option) (logr.Logger, error) {
 
 	if !validLogFormat(logFormat) {
 		return logr.Logger{}, errors.New("invalid log format specified")
@@ -76,6 +94,9 @@ func NewLogger(logLevel string, logFormat string) (logr.Logger, error) {
 		atomicLevel := zaplib.NewAtomicLevelAt(level)
 		o.Level = &atomicLevel
 	}
+	for _, option := range options {
+		option(&o)
+	}
 	return zap.New(zap.UseFlagOptions(&o)), nil
 }
 
@@ -0,0 +1,302 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/go-logr/logr"
+)
+
+// Redacted replaces the secrets removed from the logged values.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the keys, fields and headers whose values are secrets, once lowercased and stripped
+// of separators: "github_token", "AppPrivateKey" and "Proxy-Authorization" are sensitive, "bearer_token_file",
+// "registrationTokenURL" and "secretName" are not.
+var sensitiveKeys = map[string]bool{
+	"token":                   true,
+	"githubtoken":             true,
+	"accesstoken":             true,
+	"refreshtoken":            true,
+	"bearertoken":             true,
+	"registrationtoken":       true,
+	"messagequeueaccesstoken": true,
+	"encodedjitconfig":        true,
+	"password":                true,
+	"proxypassword":           true,
+	"clientsecret":            true,
+	"webhooksecret":           true,
+	"privatekey":              true,
+	"appprivatekey":           true,
+	"githubappprivatekey":     true,
+	"apikey":                  true,
+	"jwt":                     true,
+	"authorization":           true,
+	"proxyauthorization":      true,
+	"cookie":                  true,
+	"setcookie":               true,
+}
+
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`), Redacted},
+	{regexp.MustCompile(`\b(?:gh[opsur]_[A-Za-z0-9]{16,}|github_pat_[A-Za-z0-9_]{22,})`), Redacted},
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), Redacted},
+	{regexp.MustCompile(`\b(Bearer|Basic|RemoteAuth|bearer)\s+[A-Za-z0-9\-._~+/]{16,}=*`), "$1 " + Redacted},
+	{regexp.MustCompile(`(?i)\b(token)\s+[A-Za-z0-9_]{20,}`), "$1 " + Redacted},
+	{regexp.MustCompile(`(://[^/\s:@]+):[^/\s@]+@`), "$1:xxxxx@"},
+	{regexp.MustCompile(`(?i)([?&][a-z_]*(?:token|secret|password|signature)=)[^&#\s]+`), "${1}" + Redacted},
+}
+
+// Redact wraps the logger, so that the secrets are removed from the messages, errors and values it logs:
+// the tokens, private keys and credentials found in strings, e.g. the JSON bodies of the debug logs of the
+// HTTP clients, the values of the sensitive keys, the sensitive headers of http.Header values, the passwords
+// of URLs, and the credentials of app configs. The other structured values are logged as they are, hence
+// they must not hold secrets. The listener wraps its loggers, since it logs the GitHub API traffic.
+func Redact(logger logr.Logger) logr.Logger {
+	sink := logger.GetSink()
+	if sink == nil {
+		return logger
+	}
+	if _, ok := sink.(*redactingSink); ok {
+		return logger
+	}
+	// The wrapped sink is one frame further away from the caller.
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(1)
+	}
+	return logr.New(&redactingSink{sink: sink})
+}
+
+// redactingSink redacts the messages, errors and values before passing them to the wrapped sink.
+type redactingSink struct {
+	sink logr.LogSink
+}
+
+var _ logr.CallDepthLogSink = (*redactingSink)(nil)
+
+// Init does nothing, the wrapped sink was initialized by its own logger.
+func (s *redactingSink) Init(info logr.RuntimeInfo) {}
+
+func (s *redactingSink) Enabled(level int) bool {
+	return s.sink.Enabled(level)
+}
+
+func (s *redactingSink) Info(level int, msg string, keysAndValues ...any) {
+	s.sink.Info(level, RedactString(msg), redactKeysAndValues(keysAndValues)...)
+}
+
+func (s *redactingSink) Error(err error, msg string, keysAndValues ...any) {
+	s.sink.Error(redactError(err), RedactString(msg), redactKeysAndValues(keysAndValues)...)
+}
+
+func (s *redactingSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return &redactingSink{sink: s.sink.WithValues(redactKeysAndValues(keysAndValues)...)}
+}
+
+func (s *redactingSink) WithName(name string) logr.LogSink {
+	return &redactingSink{sink: s.sink.WithName(name)}
+}
+
+func (s *redactingSink) WithCallDepth(depth int) logr.LogSink {
+	sink, ok := s.sink.(logr.CallDepthLogSink)
+	if !ok {
+		return s
+	}
+	return &redactingSink{sink: sink.WithCallDepth(depth)}
+}
+
+// RedactString removes the tokens, private keys and credentials found in the string.
+// The JSON objects and arrays are redacted field by field.
+func RedactString(s string) string {
+	if trimmed := strings.TrimSpace(s); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		if v, err := decodeJSON([]byte(trimmed)); err == nil {
+			if redacted, changed := redactJSON(v); changed {
+				if b, err := json.Marshal(redacted); err == nil {
+					return string(b)
+				}
+			}
+			return s
+		}
+	}
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+var keySeparators = strings.NewReplacer("_", "", "-", "", ".", "")
+
+func sensitiveKey(key string) bool {
+	return sensitiveKeys[keySeparators.Replace(strings.ToLower(key))]
+}
+
+func redactKeysAndValues(keysAndValues []any) []any {
+	if len(keysAndValues) == 0 {
+		return keysAndValues
+	}
+	redacted := make([]any, len(keysAndValues))
+	copy(redacted, keysAndValues)
+	for i := 1; i < len(redacted); i += 2 {
+		if key, ok := redacted[i-1].(string); ok && sensitiveKey(key) && !emptyValue(redacted[i]) {
+			redacted[i] = Redacted
+			continue
+		}
+		redacted[i] = redactValue(redacted[i])
+	}
+	return redacted
+}
+
+// emptyValue reports whether the value of a sensitive key can be logged, as it cannot hold a secret.
+func emptyValue(v any) bool {
+	switch v := v.(type) {
+	case nil, bool:
+		return true
+	case string:
+		return v == ""
+	case []byte:
+		return len(v) == 0
+	}
+	return false
+}
+
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if redacted := RedactString(msg); redacted != msg {
+		return errors.New(redacted)
+	}
+	return err
+}
+
+func redactValue(v any) any {
+	if m, ok := v.(logr.Marshaler); ok {
+		v = m.MarshalLog()
+	}
+	switch v := v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case string:
+		return RedactString(v)
+	case []byte:
+		if s, redacted := string(v), RedactString(string(v)); redacted != s {
+			return redacted
+		}
+		return v
+	case error:
+		return redactError(v)
+	case http.Header:
+		return redactHeader(v)
+	case *url.URL:
+		if v == nil {
+			return v
+		}
+		return RedactString(v.Redacted())
+	case url.URL:
+		return RedactString(v.Redacted())
+	case *appconfig.AppConfig:
+		if v == nil {
+			return v
+		}
+		return redactAppConfig(*v)
+	case appconfig.AppConfig:
+		return redactAppConfig(v)
+	}
+	return v
+}
+
+// redactAppConfig returns a copy of the app config without its credentials.
+func redactAppConfig(c appconfig.AppConfig) appconfig.AppConfig {
+	if c.AppPrivateKey != "" {
+		c.AppPrivateKey = Redacted
+	}
+	if c.Token != "" {
+		c.Token = Redacted
+	}
+	return c
+}
+
+func redactHeader(h http.Header) http.Header {
+	if h == nil {
+		return h
+	}
+	redacted := make(http.Header, len(h))
+	for name, values := range h {
+		if sensitiveKey(name) {
+			redacted[name] = []string{Redacted}
+			continue
+		}
+		redacted[name] = make([]string, len(values))
+		for i, value := range values {
+			redacted[name][i] = RedactString(value)
+		}
+	}
+	return redacted
+}
+
+// decodeJSON decodes the numbers as json.Number, so that they are encoded again as they were.
+func decodeJSON(b []byte) (any, error) {
+	var v any
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+// redactJSON redacts the value decoded from JSON, and reports whether anything was redacted.
+func redactJSON(v any) (any, bool) {
+	switch v := v.(type) {
+	case string:
+		redacted := RedactString(v)
+		return redacted, redacted != v
+	case map[string]any:
+		changed := false
+		for key, value := range v {
+			if sensitiveKey(key) && !emptyJSON(value) {
+				v[key] = Redacted
+				changed = true
+				continue
+			}
+			if redacted, ok := redactJSON(value); ok {
+				v[key] = redacted
+				changed = true
+			}
+		}
+		return v, changed
+	case []any:
+		changed := false
+		for i, value := range v {
+			if redacted, ok := redactJSON(value); ok {
+				v[i] = redacted
+				changed = true
+			}
+		}
+		return v, changed
+	}
+	return v, false
+}
+
+func emptyJSON(v any) bool {
+	switch v := v.(type) {
+	case nil, bool, json.Number:
+		return true
+	case string:
+		return v == ""
+	}
+	return false
+}
@@ -32,6 +32,8 @@ import (
 	"github.com/actions/actions-runner-controller/github"
 	"github.com/actions/actions-runner-controller/github/actions"
 	"github.com/actions/actions-runner-controller/logging"
+	"github.com/actions/actions-runner-controller/vault"
+	"github.com/actions/actions-runner-controller/vault/execvault"
 	"github.com/kelseyhightower/envconfig"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -108,6 +110,14 @@ func main() {
 
 		k8sClientRateLimiterQPS   int
 		k8sClientRateLimiterBurst int
+
+		preferEmptyNodesOnScaleDown bool
+		clusterAutoscalerHints      bool
+		volumeAwareScaling          bool
+		cacheLocalityHints          bool
+
+		vaultExecPlugins string
+		vaultCacheTTL    time.Duration
 	)
 	var c github.Config
 	err = envconfig.Process("github", &c)
@@ -153,6 +163,12 @@ func main() {
 	flag.Var(&autoScalerImagePullSecrets, "auto-scaler-image-pull-secrets", "The default image-pull secret name for auto-scaler listener container.")
 	flag.IntVar(&k8sClientRateLimiterQPS, "k8s-client-rate-limiter-qps", 20, "The QPS value of the K8s client rate limiter.")
 	flag.IntVar(&k8sClientRateLimiterBurst, "k8s-client-rate-limiter-burst", 30, "The burst value of the K8s client rate limiter.")
+	flag.BoolVar(&preferEmptyNodesOnScaleDown, "prefer-empty-nodes-on-scale-down", false, "Remove the ephemeral runners on the nodes with the fewest other pods first when scaling down, so that the cluster autoscaler can release nodes sooner.")
+	flag.BoolVar(&clusterAutoscalerHints, "cluster-autoscaler-hints", false, "Annotate runner pods with cluster-autoscaler safe-to-evict hints, so that nodes of idle runners can be removed while nodes of runners with jobs are kept.")
+	flag.BoolVar(&volumeAwareScaling, "volume-aware-scaling", false, "Limit scale up to the ephemeral runners whose ephemeral volumes can be provisioned according to the resource quotas and, unless watching a single namespace, the available persistent volumes of statically provisioned storage classes.")
+	flag.BoolVar(&cacheLocalityHints, "cache-locality-hints", false, "Record the repositories of the jobs run on each node and make the pods of new runners prefer the nodes that recently ran jobs of the repositories waiting for a runner. Ignored when watching a single namespace.")
+	flag.DurationVar(&vaultCacheTTL, "vault-cache-ttl", 0, "The time the secrets read from the vaults are cached for, e.g. 5m. Stale secrets are served while the vault is unavailable. Set to 0 to read the vaults on every reconcile.")
+	flag.StringVar(&vaultExecPlugins, "vault-exec-plugins", "", "The path of a JSON file of the plugins the resources of the exec vault type can reference by name, e.g. {\"store\":{\"command\":\"/opt/vault-plugin/get-secret\",\"timeout\":\"10s\"}}.")
 	flag.Parse()
 
 	runnerPodDefaults.RunnerImagePullSecrets = runnerImagePullSecrets
@@ -274,9 +290,28 @@ func main() {
 			log.WithName("actions-clients"),
 		)
 
+		var secretResolverOptions []actionsgithubcom.SecretResolverOption
+		if vaultExecPlugins != "" {
+			plugins, err := execvault.LoadPlugins(vaultExecPlugins)
+			if err != nil {
+				log.Error(err, "unable to load exec vault plugins")
+				os.Exit(1)
+			}
+			secretResolverOptions = append(secretResolverOptions, actionsgithubcom.WithExecVaultPlugins(plugins))
+		}
+		if vaultCacheTTL > 0 {
+			secretResolverOptions = append(secretResolverOptions, actionsgithubcom.WithVaultCache(vault.CacheOptions{
+				TTL:         vaultCacheTTL,
+				StaleTTL:    vault.DefaultCacheStaleTTL,
+				NegativeTTL: vault.DefaultCacheNegativeTTL,
+				Logger:      log.WithName("vault-cache"),
+			}))
+		}
+
 		secretResolver := actionsgithubcom.NewSecretResolver(
 			mgr.GetClient(),
 			actionsMultiClient,
+			secretResolverOptions...,
 		)
 
 		rb := actionsgithubcom.ResourceBuilder{
@@ -293,6 +328,7 @@ func main() {
 			ControllerNamespace:                managerNamespace,
 			DefaultRunnerScaleSetListenerImage: managerImage,
 			ActionsClient:                      actionsMultiClient,
+			Recorder:                           mgr.GetEventRecorderFor("autoscalingrunnerset-controller"),
 			UpdateStrategy:                     actionsgithubcom.UpdateStrategy(updateStrategy),
 			DefaultRunnerScaleSetListenerImagePullSecrets: autoScalerImagePullSecrets,
 			ResourceBuilder: rb,
@@ -302,21 +338,27 @@ func main() {
 		}
 
 		if err = (&actionsgithubcom.EphemeralRunnerReconciler{
-			Client:          mgr.GetClient(),
-			Log:             log.WithName("EphemeralRunner").WithValues("version", build.Version),
-			Scheme:          mgr.GetScheme(),
-			ResourceBuilder: rb,
+			Client:                 mgr.GetClient(),
+			Log:                    log.WithName("EphemeralRunner").WithValues("version", build.Version),
+			Scheme:                 mgr.GetScheme(),
+			ClusterAutoscalerHints: clusterAutoscalerHints,
+			CacheLocalityHints:     cacheLocalityHints && watchSingleNamespace == "",
+			ResourceBuilder:        rb,
 		}).SetupWithManager(mgr, actionsgithubcom.WithMaxConcurrentReconciles(opts.RunnerMaxConcurrentReconciles)); err != nil {
 			log.Error(err, "unable to create controller", "controller", "EphemeralRunner")
 			os.Exit(1)
 		}
 
 		if err = (&actionsgithubcom.EphemeralRunnerSetReconciler{
-			Client:          mgr.GetClient(),
-			Log:             log.WithName("EphemeralRunnerSet").WithValues("version", build.Version),
-			Scheme:          mgr.GetScheme(),
-			PublishMetrics:  metricsAddr != "0",
-			ResourceBuilder: rb,
+			Client:                      mgr.GetClient(),
+			Log:                         log.WithName("EphemeralRunnerSet").WithValues("version", build.Version),
+			Scheme:                      mgr.GetScheme(),
+			PublishMetrics:              metricsAddr != "0",
+			PreferEmptyNodesOnScaleDown: preferEmptyNodesOnScaleDown,
+			VolumeAwareScaling:          volumeAwareScaling,
+			TrackPersistentVolumes:      volumeAwareScaling && watchSingleNamespace == "",
+			CacheLocalityHints:          cacheLocalityHints && watchSingleNamespace == "",
+			ResourceBuilder:             rb,
 		}).SetupWithManager(mgr); err != nil {
 			log.Error(err, "unable to create controller", "controller", "EphemeralRunnerSet")
 			os.Exit(1)
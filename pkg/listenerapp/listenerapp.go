@@ -0,0 +1,127 @@
+// Package listenerapp embeds the listener of a gha-runner-scale-set into another binary, e.g. an operator
+// running the listeners of its scale sets in process rather than as listener pods:
+//
+//	app, err := listenerapp.New(listenerapp.Config{...}, listenerapp.WithLogger(logger))
+//	...
+//	err = app.Run(ctx)
+//
+// Config only has the settings of a scale set, which are the API of the package. The other settings of the
+// listener pods, e.g. the vault or the scaling policies, are only read from the config file the controller
+// renders, with NewFromFile. The packages of cmd/ghalistener are the implementation of the listener, and
+// are not part of the API.
+package listenerapp
+
+import (
+	"context"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/app"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/config"
+	"github.com/go-logr/logr"
+	"k8s.io/client-go/rest"
+)
+
+// Config is the configuration of the listener of a runner scale set.
+type Config struct {
+	// ConfigureURL is the URL of the repository, organization or enterprise the scale set is registered to.
+	ConfigureURL string
+	// AppConfig are the GitHub credentials of the listener, either a GitHub App or a personal access token.
+	AppConfig *appconfig.AppConfig
+	// ScaleSetID and ScaleSetName are the ID and the name of the scale set.
+	ScaleSetID   int
+	ScaleSetName string
+	// EphemeralRunnerSetNamespace and EphemeralRunnerSetName are the ephemeral runner set the listener scales.
+	EphemeralRunnerSetNamespace string
+	EphemeralRunnerSetName      string
+	// MinRunners and MaxRunners bound the number of runners of the scale set.
+	MinRunners int
+	MaxRunners int
+	// ServerRootCA is the PEM bundle of root certificates the GitHub server is verified with, in addition to the
+	// system ones.
+	ServerRootCA string
+	// LogLevel and LogFormat are the settings of the logger of the listener, unless WithLogger is set.
+	LogLevel  string
+	LogFormat string
+	// MetricsAddr and MetricsEndpoint are the address and the path the metrics are served on.
+	// If MetricsAddr is not set, the metrics are not served.
+	MetricsAddr     string
+	MetricsEndpoint string
+}
+
+func (c *Config) listenerConfig() config.Config {
+	return config.Config{
+		ConfigureUrl:                c.ConfigureURL,
+		AppConfig:                   c.AppConfig,
+		RunnerScaleSetId:            c.ScaleSetID,
+		RunnerScaleSetName:          c.ScaleSetName,
+		EphemeralRunnerSetNamespace: c.EphemeralRunnerSetNamespace,
+		EphemeralRunnerSetName:      c.EphemeralRunnerSetName,
+		MinRunners:                  c.MinRunners,
+		MaxRunners:                  c.MaxRunners,
+		ServerRootCA:                c.ServerRootCA,
+		LogLevel:                    c.LogLevel,
+		LogFormat:                   c.LogFormat,
+		MetricsAddr:                 c.MetricsAddr,
+		MetricsEndpoint:             c.MetricsEndpoint,
+	}
+}
+
+// Option configures the App.
+type Option func(*options)
+
+type options struct {
+	app []app.Option
+}
+
+// WithLogger sets the logger of the listener in place of the one of the log level and format of the config.
+func WithLogger(logger logr.Logger) Option {
+	return func(o *options) {
+		o.app = append(o.app, app.WithLogger(logger))
+	}
+}
+
+// WithKubernetesConfig sets the config of the Kubernetes clients of the listener in place of the in-cluster config,
+// e.g. to run the listener out of the cluster of its scale set.
+func WithKubernetesConfig(conf *rest.Config) Option {
+	return func(o *options) {
+		o.app = append(o.app, app.WithKubernetesConfig(conf))
+	}
+}
+
+// App is a listener of a runner scale set.
+type App struct {
+	app *app.App
+}
+
+// New creates the listener of the config. It returns an error if the config is invalid.
+func New(config Config, opts ...Option) (*App, error) {
+	return newApp(config.listenerConfig(), opts)
+}
+
+// NewFromFile creates the listener of the config file the controller renders for the listener pod, resolving
+// the GitHub credentials from the vault if configured. It returns an error if the config is invalid.
+func NewFromFile(ctx context.Context, path string, opts ...Option) (*App, error) {
+	config, err := config.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return newApp(*config, opts)
+}
+
+func newApp(config config.Config, opts []Option) (*App, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	a, err := app.New(config, o.app...)
+	if err != nil {
+		return nil, err
+	}
+	return &App{app: a}, nil
+}
+
+// Run runs the listener until the context is cancelled or it fails. The message session of the scale set
+// is deleted on the way out, so that another listener can take over.
+func (a *App) Run(ctx context.Context) error {
+	return a.app.Run(ctx)
+}
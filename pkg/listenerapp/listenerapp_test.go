@@ -0,0 +1,48 @@
+package listenerapp_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/actions/actions-runner-controller/cmd/ghalistener/errcode"
+	"github.com/actions/actions-runner-controller/pkg/listenerapp"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func TestNew(t *testing.T) {
+	_, err := listenerapp.New(listenerapp.Config{
+		ConfigureURL:                "https://github.com/org",
+		AppConfig:                   &appconfig.AppConfig{Token: "token"},
+		ScaleSetID:                  1,
+		EphemeralRunnerSetNamespace: "namespace",
+		EphemeralRunnerSetName:      "deployment",
+		MaxRunners:                  10,
+	}, listenerapp.WithLogger(logr.Discard()), listenerapp.WithKubernetesConfig(&rest.Config{Host: "https://127.0.0.1:1"}))
+	require.Error(t, err, "the cluster is not reachable")
+	assert.NotEqual(t, errcode.ExitConfig, errcode.ExitCode(err), "the config is valid")
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	_, err := listenerapp.New(listenerapp.Config{}, listenerapp.WithLogger(logr.Discard()))
+	assert.Error(t, err)
+}
+
+func TestNewFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"configure_url": "https://github.com/org",
+		"ephemeral_runner_set_namespace": "namespace",
+		"ephemeral_runner_set_name": "deployment",
+		"runner_scale_set_id": 1,
+		"max_runners": 10
+	}`), 0o600))
+
+	_, err := listenerapp.NewFromFile(context.Background(), path)
+	assert.ErrorContains(t, err, "AppConfig validation failed", "the credentials are required")
+}
@@ -2,14 +2,23 @@ package azurekeyvault
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
 )
 
 // AzureKeyVault is a struct that holds the Azure Key Vault client.
 type AzureKeyVault struct {
-	client *azsecrets.Client
+	client          *azsecrets.Client
+	certificateName string
+	// keys signs the GitHub App JWTs, if a signing key is configured.
+	keys *keyClient
 }
 
 func New(cfg Config) (*AzureKeyVault, error) {
@@ -22,7 +31,16 @@ func New(cfg Config) (*AzureKeyVault, error) {
 		return nil, fmt.Errorf("failed to create azsecrets client from config: %v", err)
 	}
 
-	return &AzureKeyVault{client: client}, nil
+	v := &AzureKeyVault{client: client, certificateName: cfg.CertificateName}
+	if cfg.SigningKeyURL != "" {
+		keys, err := cfg.keyClient()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create keys client from config: %v", err)
+		}
+		v.keys = keys
+	}
+
+	return v, nil
 }
 
 // GetSecret retrieves a secret from Azure Key Vault.
@@ -37,3 +55,69 @@ func (v *AzureKeyVault) GetSecret(ctx context.Context, name string) (string, err
 
 	return *secret.Value, nil
 }
+
+// GetCertificatePrivateKey retrieves the private key of a certificate from Azure Key Vault, PEM encoded.
+// The key is read from the secret Key Vault backs the certificate with, so it must be exportable.
+func (v *AzureKeyVault) GetCertificatePrivateKey(ctx context.Context, name string) (string, error) {
+	secret, err := v.client.GetSecret(ctx, name, "", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get certificate secret: %w", err)
+	}
+	if secret.Value == nil {
+		return "", fmt.Errorf("certificate secret value is nil")
+	}
+
+	var contentType string
+	if secret.ContentType != nil {
+		contentType = *secret.ContentType
+	}
+	return certificatePrivateKey(*secret.Value, contentType)
+}
+
+// ResolveAppKey sets the private key of the GitHub App from the certificate, or the signer of the signing key,
+// if configured.
+func (v *AzureKeyVault) ResolveAppKey(ctx context.Context, appConfig *appconfig.AppConfig) error {
+	switch {
+	case v.certificateName != "":
+		key, err := v.GetCertificatePrivateKey(ctx, v.certificateName)
+		if err != nil {
+			return err
+		}
+		appConfig.AppPrivateKey = key
+	case v.keys != nil:
+		signer, err := v.keys.signer(ctx)
+		if err != nil {
+			return err
+		}
+		appConfig.AppPrivateKey = ""
+		appConfig.AppPrivateKeySigner = signer
+	}
+	return nil
+}
+
+// certificatePrivateKey returns the RSA private key of the certificate secret value, which is PEM encoded
+// or a base64 encoded PKCS #12 archive depending on the content type of the certificate.
+func certificatePrivateKey(value, contentType string) (string, error) {
+	data := []byte(value)
+	if contentType == "application/x-pkcs12" {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode PKCS #12 certificate: %w", err)
+		}
+		data = decoded
+	}
+
+	_, key, err := azidentity.ParseCertificates(data, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("certificate key is a %T, the GitHub App private key is an RSA key", key)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+	})), nil
+}
@@ -0,0 +1,35 @@
+package azurekeyvault
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificatePrivateKey(t *testing.T) {
+	certPEM, err := os.ReadFile("testdata/server.crt")
+	require.NoError(t, err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	got, err := certificatePrivateKey(string(keyPEM)+string(certPEM), "application/x-pem-file")
+	require.NoError(t, err)
+	block, _ := pem.Decode([]byte(got))
+	require.NotNil(t, block)
+	parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(parsed))
+
+	_, err = certificatePrivateKey(string(certPEM), "application/x-pem-file")
+	assert.Error(t, err, "the certificate has no private key")
+}
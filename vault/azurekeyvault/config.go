@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
@@ -22,6 +23,12 @@ type Config struct {
 	URL             string            `json:"url"`
 	CertificatePath string            `json:"certificate_path"`
 	Proxy           *httpproxy.Config `json:"proxy,omitempty"`
+	// CertificateName is the name of the Key Vault certificate the private key of the GitHub App is read from,
+	// in place of the github_app_private_key of the app config secret. The key of the certificate must be exportable.
+	CertificateName string `json:"certificate_name,omitempty"`
+	// SigningKeyURL is the URL of the RSA key of a Key Vault or Managed HSM signing the GitHub App JWTs,
+	// e.g. https://my-hsm.managedhsm.azure.net/keys/github-app. The private key never leaves the vault.
+	SigningKeyURL string `json:"signing_key_url,omitempty"`
 }
 
 func (c *Config) Validate() error {
@@ -43,6 +50,20 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("cert path %q does not exist: %v", c.CertificatePath, err)
 	}
 
+	if c.CertificateName != "" && c.SigningKeyURL != "" {
+		return errors.New("certificate_name and signing_key_url cannot both be set")
+	}
+
+	if c.SigningKeyURL != "" {
+		u, err := url.ParseRequestURI(c.SigningKeyURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse signing_key_url: %v", err)
+		}
+		if !strings.HasPrefix(u.Path, "/keys/") {
+			return fmt.Errorf("signing_key_url %q is not the URL of a key", c.SigningKeyURL)
+		}
+	}
+
 	if c.Proxy != nil {
 		if c.Proxy.HTTPProxy == "" && c.Proxy.HTTPSProxy == "" && c.Proxy.NoProxy == "" {
 			return errors.New("proxy configuration is empty, at least one proxy must be set")
@@ -58,21 +79,54 @@ func (c *Config) Client() (*azsecrets.Client, error) {
 }
 
 func (c *Config) certClient() (*azsecrets.Client, error) {
-	data, err := os.ReadFile(c.CertificatePath)
+	httpClient, err := c.httpClient()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read cert file from path %q: %v", c.CertificatePath, err)
+		return nil, fmt.Errorf("failed to instantiate http client: %v", err)
 	}
 
-	certs, key, err := azidentity.ParseCertificates(data, nil)
+	cred, err := c.credential(httpClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse certificates: %w", err)
+		return nil, err
 	}
 
+	client, err := azsecrets.NewClient(c.URL, cred, &azsecrets.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Transport: httpClient,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate client for azsecrets: %v", err)
+	}
+
+	return client, nil
+}
+
+// keyClient creates the client of the keys of the SigningKeyURL.
+func (c *Config) keyClient() (*keyClient, error) {
 	httpClient, err := c.httpClient()
 	if err != nil {
 		return nil, fmt.Errorf("failed to instantiate http client: %v", err)
 	}
 
+	cred, err := c.credential(httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKeyClient(c.SigningKeyURL, cred, httpClient)
+}
+
+func (c *Config) credential(httpClient *http.Client) (*azidentity.ClientCertificateCredential, error) {
+	data, err := os.ReadFile(c.CertificatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cert file from path %q: %v", c.CertificatePath, err)
+	}
+
+	certs, key, err := azidentity.ParseCertificates(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificates: %w", err)
+	}
+
 	cred, err := azidentity.NewClientCertificateCredential(
 		c.TenantID,
 		c.ClientID,
@@ -88,16 +142,7 @@ func (c *Config) certClient() (*azsecrets.Client, error) {
 		return nil, fmt.Errorf("failed to create client certificate credential: %v", err)
 	}
 
-	client, err := azsecrets.NewClient(c.URL, cred, &azsecrets.ClientOptions{
-		ClientOptions: policy.ClientOptions{
-			Transport: httpClient,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to instantiate client for azsecrets: %v", err)
-	}
-
-	return client, nil
+	return cred, nil
 }
 
 func (c *Config) httpClient() (*http.Client, error) {
@@ -50,6 +50,21 @@ func TestConfigValidate_invalid(t *testing.T) {
 			URL:             url,
 			CertificatePath: "",
 		},
+		"certificate name and signing key url": {
+			TenantID:        tenantID,
+			ClientID:        clientID,
+			URL:             url,
+			CertificatePath: certPath,
+			CertificateName: "github-app",
+			SigningKeyURL:   "https://my-hsm.managedhsm.azure.net/keys/github-app",
+		},
+		"signing key url not of a key": {
+			TenantID:        tenantID,
+			ClientID:        clientID,
+			URL:             url,
+			CertificatePath: certPath,
+			SigningKeyURL:   "https://my-hsm.managedhsm.azure.net/secrets/github-app",
+		},
 		"invalid proxy": {
 			TenantID:        tenantID,
 			ClientID:        clientID,
@@ -82,6 +97,13 @@ func TestValidate_valid(t *testing.T) {
 			URL:             url,
 			CertificatePath: certPath,
 		},
+		"with signing key": {
+			TenantID:        tenantID,
+			ClientID:        clientID,
+			URL:             url,
+			CertificatePath: certPath,
+			SigningKeyURL:   "https://my-hsm.managedhsm.azure.net/keys/github-app",
+		},
 		"without proxy": {
 			TenantID:        tenantID,
 			ClientID:        clientID,
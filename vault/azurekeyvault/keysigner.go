@@ -0,0 +1,173 @@
+package azurekeyvault
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// keysAPIVersion is the version of the keys REST API, served by both the Key Vaults and the Managed HSMs.
+const keysAPIVersion = "7.4"
+
+// signTimeout bounds the sign operation, which crypto.Signer does not pass a context to.
+const signTimeout = 30 * time.Second
+
+// keyClient calls the keys REST API of a Key Vault or a Managed HSM.
+type keyClient struct {
+	keyURL   string
+	pipeline runtime.Pipeline
+}
+
+func newKeyClient(keyURL string, cred azcore.TokenCredential, transport policy.Transporter) (*keyClient, error) {
+	u, err := url.Parse(keyURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key url: %v", err)
+	}
+	// The scope is the one of the service of the vault, e.g. https://managedhsm.azure.net/.default
+	// for https://my-hsm.managedhsm.azure.net.
+	_, service, ok := strings.Cut(u.Hostname(), ".")
+	if !ok {
+		return nil, fmt.Errorf("signing key url %q is not the url of a Key Vault or Managed HSM", keyURL)
+	}
+	scope := fmt.Sprintf("https://%s/.default", service)
+
+	pipeline := runtime.NewPipeline("azurekeyvault", "v1.0.0", runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(cred, []string{scope}, nil)},
+	}, &policy.ClientOptions{Transport: transport})
+
+	return &keyClient{keyURL: strings.TrimSuffix(keyURL, "/"), pipeline: pipeline}, nil
+}
+
+type jsonWebKey struct {
+	KID string `json:"kid"`
+	KTY string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// signer gets the current version of the key, which the returned signer signs with.
+func (c *keyClient) signer(ctx context.Context) (*KeySigner, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, c.keyURL+"?api-version="+keysAPIVersion)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return nil, fmt.Errorf("failed to get signing key: %w", runtime.NewResponseError(resp))
+	}
+	var body struct {
+		Key jsonWebKey `json:"key"`
+	}
+	if err := runtime.UnmarshalAsJSON(resp, &body); err != nil {
+		return nil, fmt.Errorf("failed to decode signing key: %w", err)
+	}
+
+	public, err := body.Key.rsaPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return &KeySigner{client: c, kid: body.Key.KID, public: public}, nil
+}
+
+func (k *jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	if k.KTY != "RSA" && k.KTY != "RSA-HSM" {
+		return nil, fmt.Errorf("signing key %q is a %s key, the GitHub App JWTs are signed with RSA keys", k.KID, k.KTY)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signing key modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signing key exponent: %w", err)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+// KeySigner signs with a key of a Key Vault or Managed HSM, which performs the sign operation:
+// the private key is never exported from it.
+type KeySigner struct {
+	client *keyClient
+	kid    string
+	public *rsa.PublicKey
+}
+
+var _ crypto.Signer = (*KeySigner)(nil)
+
+func (s *KeySigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// String returns the ID of the version of the key, so that the clients signing with it are told apart
+// when the key is rotated.
+func (s *KeySigner) String() string {
+	return s.kid
+}
+
+// Sign signs the digest with PKCS #1 v1.5, or PSS if opts is a *rsa.PSSOptions.
+func (s *KeySigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	alg, err := signAlgorithm(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+
+	req, err := runtime.NewRequest(ctx, http.MethodPost, s.kid+"/sign?api-version="+keysAPIVersion)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.MarshalAsJSON(req, map[string]string{
+		"alg":   alg,
+		"value": base64.RawURLEncoding.EncodeToString(digest),
+	}); err != nil {
+		return nil, err
+	}
+	resp, err := s.client.pipeline.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with key %q: %w", s.kid, err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return nil, fmt.Errorf("failed to sign with key %q: %w", s.kid, runtime.NewResponseError(resp))
+	}
+	var result struct {
+		Value string `json:"value"`
+	}
+	if err := runtime.UnmarshalAsJSON(resp, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+	return base64.RawURLEncoding.DecodeString(result.Value)
+}
+
+func signAlgorithm(opts crypto.SignerOpts) (string, error) {
+	prefix := "RS"
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		prefix = "PS"
+	}
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		return prefix + "256", nil
+	case crypto.SHA384:
+		return prefix + "384", nil
+	case crypto.SHA512:
+		return prefix + "512", nil
+	}
+	return "", errors.New("unsupported hash function, the key signs SHA-256, SHA-384 and SHA-512 digests")
+}
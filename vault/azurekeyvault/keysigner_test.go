@@ -0,0 +1,77 @@
+package azurekeyvault
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestKeySigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, keysAPIVersion, r.URL.Query().Get("api-version"))
+		switch r.URL.Path {
+		case "/keys/github-app":
+			_ = json.NewEncoder(w).Encode(map[string]any{"key": map[string]string{
+				"kid": server.URL + "/keys/github-app/v2",
+				"kty": "RSA-HSM",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}})
+		case "/keys/github-app/v2/sign":
+			var body struct {
+				Alg   string `json:"alg"`
+				Value string `json:"value"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "RS256", body.Alg)
+			digest, err := base64.RawURLEncoding.DecodeString(body.Value)
+			require.NoError(t, err)
+			signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+			require.NoError(t, err)
+			_ = json.NewEncoder(w).Encode(map[string]string{"value": base64.RawURLEncoding.EncodeToString(signature)})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := newKeyClient(server.URL+"/keys/github-app", fakeCredential{}, server.Client())
+	require.NoError(t, err)
+	signer, err := client.signer(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/keys/github-app/v2", signer.String())
+	assert.True(t, key.PublicKey.Equal(signer.Public()))
+
+	digest := sha256.Sum256([]byte("header.claims"))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA1)
+	assert.Error(t, err)
+}
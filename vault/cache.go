@@ -0,0 +1,299 @@
+package vault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/go-logr/logr"
+	"k8s.io/utils/clock"
+)
+
+// cacheRefreshTimeout bounds the reads of the stale secrets revalidated in the background.
+const cacheRefreshTimeout = time.Minute
+
+// The defaults of the cache options used by the controller and the listener.
+const (
+	DefaultCacheStaleTTL    = 24 * time.Hour
+	DefaultCacheNegativeTTL = 30 * time.Second
+)
+
+// The encryption of the persisted cache: its key is derived from the key of the options with HKDF-SHA256
+// and a random salt stored along with every save.
+const (
+	// MinCacheKeySize is the minimum size of the key of the options, which must be random, not a passphrase.
+	MinCacheKeySize = 32
+	cacheSaltSize   = 32
+	cacheKDFInfo    = "actions-runner-controller vault cache"
+)
+
+// CacheStore persists the secrets of a Cache, e.g. in a file, so that they survive the restarts of the process.
+type CacheStore interface {
+	// Load returns the saved cache, or nil if none was saved.
+	Load() ([]byte, error)
+	Save(data []byte) error
+}
+
+// CacheOptions configures a Cache.
+type CacheOptions struct {
+	// TTL is how long a secret is served from the cache before it is read from the vault again.
+	TTL time.Duration
+	// StaleTTL is how long past its TTL a secret is still served: it is revalidated in the background,
+	// and served while the vault fails to be read.
+	StaleTTL time.Duration
+	// NegativeTTL is how long a failure to read a secret is cached, so that an unavailable vault
+	// is not read again on every lookup.
+	NegativeTTL time.Duration
+	// Store persists the cache, encrypted with a key derived from Key, if set.
+	// Key must hold at least MinCacheKeySize random bytes.
+	Store CacheStore
+	Key   []byte
+	// Logger logs the failures to persist the cache and to revalidate the stale secrets.
+	Logger logr.Logger
+	Clock  clock.PassiveClock
+}
+
+// Cache is a Vault caching the secrets of another.
+type Cache struct {
+	vault   Vault
+	options CacheOptions
+
+	mu         sync.Mutex
+	loaded     bool
+	entries    map[string]*cacheEntry
+	refreshing map[string]bool
+}
+
+type cacheEntry struct {
+	Value     string    `json:"value"`
+	FetchedAt time.Time `json:"fetched_at"`
+
+	err      error
+	failedAt time.Time
+}
+
+func (e *cacheEntry) hasValue() bool {
+	return !e.FetchedAt.IsZero()
+}
+
+var (
+	_ Vault          = (*Cache)(nil)
+	_ AppKeyResolver = (*Cache)(nil)
+)
+
+// NewCache returns the cache of the vault.
+func NewCache(v Vault, options CacheOptions) (*Cache, error) {
+	if options.Clock == nil {
+		options.Clock = clock.RealClock{}
+	}
+	if options.Logger.GetSink() == nil {
+		options.Logger = logr.Discard()
+	}
+
+	c := &Cache{
+		vault:      v,
+		options:    options,
+		entries:    make(map[string]*cacheEntry),
+		refreshing: make(map[string]bool),
+	}
+	if options.Store != nil && len(options.Key) < MinCacheKeySize {
+		return nil, fmt.Errorf("the key of the cache store must hold at least %d bytes, got %d", MinCacheKeySize, len(options.Key))
+	}
+	return c, nil
+}
+
+// cipher returns the AEAD of the persisted cache, keyed with the key derived from the key of the options and the salt.
+func (c *Cache) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, c.options.Key, salt, cacheKDFInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive cache key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// GetSecret returns the cached secret while its TTL runs, or reads it from the vault. A stale secret is served
+// while it is revalidated in the background, or while the vault fails to be read.
+func (c *Cache) GetSecret(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	c.load()
+	entry, ok := c.entries[name]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[name] = entry
+	}
+	now := c.options.Clock.Now()
+	cached, cachedErr := entry.Value, entry.err
+	fresh := entry.hasValue() && now.Before(entry.FetchedAt.Add(c.options.TTL))
+	stale := entry.hasValue() && now.Before(entry.FetchedAt.Add(c.options.TTL+c.options.StaleTTL))
+	failed := cachedErr != nil && now.Before(entry.failedAt.Add(c.options.NegativeTTL))
+
+	switch {
+	case fresh:
+		c.mu.Unlock()
+		return cached, nil
+	case failed:
+		c.mu.Unlock()
+		if stale {
+			return cached, nil
+		}
+		return "", cachedErr
+	case stale:
+		if !c.refreshing[name] {
+			c.refreshing[name] = true
+			go c.refresh(name)
+		}
+		c.mu.Unlock()
+		return cached, nil
+	}
+	c.mu.Unlock()
+
+	value, err := c.vault.GetSecret(ctx, name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update(name, value, err)
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+func (c *Cache) refresh(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheRefreshTimeout)
+	defer cancel()
+
+	value, err := c.vault.GetSecret(ctx, name)
+	if err != nil {
+		c.options.Logger.Error(err, "Failed to revalidate the stale secret, serving it until it expires", "name", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, name)
+	c.update(name, value, err)
+}
+
+// update records the read of the secret, c.mu being held.
+func (c *Cache) update(name, value string, err error) {
+	entry, ok := c.entries[name]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[name] = entry
+	}
+	if err != nil {
+		entry.err = err
+		entry.failedAt = c.options.Clock.Now()
+		return
+	}
+	entry.Value = value
+	entry.FetchedAt = c.options.Clock.Now()
+	entry.err = nil
+	c.save()
+}
+
+// ResolveAppKey resolves the private key of the GitHub App from the vault, if it implements AppKeyResolver.
+// The keys are not cached, the signers reaching out to the vault anyway.
+func (c *Cache) ResolveAppKey(ctx context.Context, appConfig *appconfig.AppConfig) error {
+	if r, ok := c.vault.(AppKeyResolver); ok {
+		return r.ResolveAppKey(ctx, appConfig)
+	}
+	return nil
+}
+
+// load loads the saved cache once, c.mu being held. A cache which cannot be loaded is discarded.
+func (c *Cache) load() {
+	if c.loaded || c.options.Store == nil {
+		return
+	}
+	c.loaded = true
+
+	data, err := c.options.Store.Load()
+	if err != nil {
+		c.options.Logger.Error(err, "Failed to load the vault cache, starting empty")
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	if len(data) < cacheSaltSize {
+		c.options.Logger.Error(errors.New("the cache is truncated"), "Failed to load the vault cache, starting empty")
+		return
+	}
+	aead, err := c.cipher(data[:cacheSaltSize])
+	if err != nil {
+		c.options.Logger.Error(err, "Failed to decrypt the vault cache, starting empty")
+		return
+	}
+	data = data[cacheSaltSize:]
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
+		c.options.Logger.Error(errors.New("the cache is truncated"), "Failed to load the vault cache, starting empty")
+		return
+	}
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		c.options.Logger.Error(err, "Failed to decrypt the vault cache, starting empty")
+		return
+	}
+	var entries map[string]*cacheEntry
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		c.options.Logger.Error(err, "Failed to decode the vault cache, starting empty")
+		return
+	}
+	for name, entry := range entries {
+		if _, ok := c.entries[name]; !ok && entry != nil {
+			c.entries[name] = entry
+		}
+	}
+}
+
+// save saves the cached secrets, c.mu being held.
+func (c *Cache) save() {
+	if c.options.Store == nil {
+		return
+	}
+	entries := make(map[string]*cacheEntry, len(c.entries))
+	for name, entry := range c.entries {
+		if entry.hasValue() {
+			entries[name] = entry
+		}
+	}
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		c.options.Logger.Error(err, "Failed to encode the vault cache")
+		return
+	}
+	salt := make([]byte, cacheSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		c.options.Logger.Error(err, "Failed to encrypt the vault cache")
+		return
+	}
+	aead, err := c.cipher(salt)
+	if err != nil {
+		c.options.Logger.Error(err, "Failed to encrypt the vault cache")
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		c.options.Logger.Error(err, "Failed to encrypt the vault cache")
+		return
+	}
+	data := append(salt, nonce...)
+	if err := c.options.Store.Save(aead.Seal(data, nonce, plaintext, nil)); err != nil {
+		c.options.Logger.Error(err, "Failed to save the vault cache")
+	}
+}
@@ -0,0 +1,141 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+type fakeVault struct {
+	mu     sync.Mutex
+	secret string
+	err    error
+	reads  int
+}
+
+func (v *fakeVault) GetSecret(ctx context.Context, name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.reads++
+	return v.secret, v.err
+}
+
+func (v *fakeVault) set(secret string, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secret, v.err = secret, err
+}
+
+func (v *fakeVault) readCount() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.reads
+}
+
+type memoryStore struct {
+	data []byte
+}
+
+func (s *memoryStore) Load() ([]byte, error) {
+	return s.data, nil
+}
+
+func (s *memoryStore) Save(data []byte) error {
+	s.data = data
+	return nil
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktesting.NewFakeClock(time.Now())
+	v := &fakeVault{secret: "v1"}
+	cache, err := NewCache(v, CacheOptions{
+		TTL:         time.Minute,
+		StaleTTL:    time.Hour,
+		NegativeTTL: 10 * time.Second,
+		Clock:       clock,
+	})
+	require.NoError(t, err)
+
+	got, err := cache.GetSecret(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", got)
+
+	v.set("v2", nil)
+	got, err = cache.GetSecret(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", got, "the fresh secret is served from the cache")
+	assert.Equal(t, 1, v.readCount())
+
+	clock.Step(2 * time.Minute)
+	got, err = cache.GetSecret(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", got, "the stale secret is served while it is revalidated")
+	require.Eventually(t, func() bool {
+		got, err := cache.GetSecret(ctx, "key")
+		return err == nil && got == "v2"
+	}, time.Second, 10*time.Millisecond)
+
+	v.set("", errors.New("vault unavailable"))
+	clock.Step(30 * time.Minute)
+	got, err = cache.GetSecret(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", got, "the stale secret is served while the vault fails")
+
+	clock.Step(2 * time.Hour)
+	_, err = cache.GetSecret(ctx, "key")
+	assert.EqualError(t, err, "vault unavailable")
+	reads := v.readCount()
+	_, err = cache.GetSecret(ctx, "key")
+	assert.EqualError(t, err, "vault unavailable")
+	assert.Equal(t, reads, v.readCount(), "the failure is cached")
+
+	clock.Step(time.Minute)
+	v.set("v3", nil)
+	got, err = cache.GetSecret(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "v3", got)
+}
+
+func TestCache_Store(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktesting.NewFakeClock(time.Now())
+	store := &memoryStore{}
+	options := CacheOptions{
+		TTL:      time.Minute,
+		StaleTTL: time.Hour,
+		Store:    store,
+		Key:      bytes.Repeat([]byte("k"), MinCacheKeySize),
+		Clock:    clock,
+	}
+
+	cache, err := NewCache(&fakeVault{secret: "secret"}, options)
+	require.NoError(t, err)
+	_, err = cache.GetSecret(ctx, "key")
+	require.NoError(t, err)
+	assert.NotContains(t, string(store.data), "secret", "the cache is encrypted")
+
+	unavailable := &fakeVault{err: errors.New("vault unavailable")}
+	restarted, err := NewCache(unavailable, options)
+	require.NoError(t, err)
+	got, err := restarted.GetSecret(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", got, "the saved secret is served after a restart")
+
+	options.Key = bytes.Repeat([]byte("a"), MinCacheKeySize)
+	rekeyed, err := NewCache(unavailable, options)
+	require.NoError(t, err)
+	_, err = rekeyed.GetSecret(ctx, "key")
+	assert.Error(t, err, "the cache encrypted with another key is discarded")
+
+	options.Key = []byte("passphrase")
+	_, err = NewCache(unavailable, options)
+	assert.ErrorContains(t, err, "must hold at least 32 bytes", "a short key is rejected")
+}
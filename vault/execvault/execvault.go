@@ -0,0 +1,233 @@
+// Package execvault reads the secrets from a plugin binary, so that the secret stores without a vault
+// integration can be used without forking the controller.
+//
+// The plugin is executed for every secret, credential helper style: it is passed a Request as JSON on its stdin,
+// and writes a Response as JSON on its stdout before exiting with 0. It exits with another code, a message on
+// its stderr, if the secret cannot be read. The plugin does not inherit the environment of the process executing
+// it, only the configured one, and it is killed once the timeout elapses.
+//
+// The plugins are configured by the operator of the controller, the resources only referencing one by name,
+// so that the users allowed to create resources cannot make the controller execute arbitrary binaries.
+package execvault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// APIVersion is the version of the protocol between the vault and the plugins.
+const APIVersion = "actions.github.com/vault/v1"
+
+// DefaultTimeout is the default time a plugin has to write the secret.
+const DefaultTimeout = 10 * time.Second
+
+const (
+	// maxOutputSize bounds the stdout of the plugins, the secrets being app configs of a few kilobytes.
+	maxOutputSize = 1 << 20
+	// maxErrorSize bounds the stderr of the plugins reported in the errors.
+	maxErrorSize = 1 << 10
+	// waitDelay is the time the plugins have to close their outputs once killed.
+	waitDelay = time.Second
+)
+
+// Config configures the plugin.
+type Config struct {
+	// Command is the absolute path of the plugin binary. The plugins are not looked up in the PATH,
+	// which the plugins do not inherit.
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Env is the environment of the plugin, the only one it gets. It is rendered in the listener config,
+	// so it must not hold credentials, which the plugins read from files mounted in the containers.
+	Env map[string]string `json:"env,omitempty"`
+	// Timeout bounds every execution of the plugin. Defaults to DefaultTimeout.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c.Command == "" {
+		return errors.New("command is not set")
+	}
+	if !filepath.IsAbs(c.Command) {
+		return fmt.Errorf("command %q must be an absolute path", c.Command)
+	}
+	for name := range c.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("env name %q is invalid", name)
+		}
+	}
+	if c.Timeout != nil && c.Timeout.Duration <= 0 {
+		return fmt.Errorf("timeout %q must be positive", c.Timeout.Duration)
+	}
+	return nil
+}
+
+// Plugins are the plugins the resources can reference, by name.
+type Plugins map[string]Config
+
+// LoadPlugins reads the plugins of the file, a JSON object of the plugin configs by name.
+func LoadPlugins(path string) (Plugins, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins file: %w", err)
+	}
+
+	var plugins Plugins
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&plugins); err != nil {
+		return nil, fmt.Errorf("failed to decode plugins file %s: %w", path, err)
+	}
+
+	for name, cfg := range plugins {
+		if name == "" {
+			return nil, errors.New("plugin name is not set")
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid plugin %q: %w", name, err)
+		}
+	}
+	return plugins, nil
+}
+
+// Config returns the config of the plugin, which must be one of the plugins.
+func (p Plugins) Config(name string) (Config, error) {
+	cfg, ok := p[name]
+	if !ok {
+		return Config{}, fmt.Errorf("plugin %q is not one of the plugins the controller is configured with", name)
+	}
+	return cfg, nil
+}
+
+// Request is the request written to the stdin of the plugin.
+type Request struct {
+	APIVersion string `json:"api_version"`
+	// Name is the name of the secret, i.e. the vault lookup key or the proxy credentials secret.
+	Name string `json:"name"`
+}
+
+// Response is the response the plugin writes to its stdout.
+type Response struct {
+	APIVersion string `json:"api_version"`
+	// Secret is the value of the secret, e.g. the app config as a JSON object encoded in a string.
+	Secret string `json:"secret"`
+}
+
+// ExecVault reads the secrets from a plugin.
+type ExecVault struct {
+	command string
+	args    []string
+	env     []string
+	timeout time.Duration
+}
+
+func New(cfg Config) (*ExecVault, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate config: %v", err)
+	}
+
+	// A non-nil empty environment, a nil one being inherited from the process.
+	env := make([]string, 0, len(cfg.Env))
+	for name, value := range cfg.Env {
+		env = append(env, name+"="+value)
+	}
+	slices.Sort(env)
+
+	timeout := DefaultTimeout
+	if cfg.Timeout != nil {
+		timeout = cfg.Timeout.Duration
+	}
+
+	return &ExecVault{
+		command: cfg.Command,
+		args:    slices.Clone(cfg.Args),
+		env:     env,
+		timeout: timeout,
+	}, nil
+}
+
+// GetSecret executes the plugin to read the secret.
+func (v *ExecVault) GetSecret(ctx context.Context, name string) (string, error) {
+	request, err := json.Marshal(Request{APIVersion: APIVersion, Name: name})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: maxOutputSize}
+	stderr := &limitedBuffer{limit: maxErrorSize}
+	cmd := exec.CommandContext(ctx, v.command, v.args...)
+	cmd.Env = v.env
+	cmd.Dir = "/"
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("plugin %s did not write secret %q within %s", v.command, name, v.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("plugin %s failed to read secret %q: %w: %s", v.command, name, err, msg)
+		}
+		return "", fmt.Errorf("plugin %s failed to read secret %q: %w", v.command, name, err)
+	}
+	if stdout.truncated {
+		return "", fmt.Errorf("plugin %s wrote more than %d bytes for secret %q", v.command, maxOutputSize, name)
+	}
+
+	var response Response
+	decoder := json.NewDecoder(bytes.NewReader(stdout.Bytes()))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&response); err != nil {
+		return "", fmt.Errorf("failed to decode the response of plugin %s for secret %q: %w", v.command, name, err)
+	}
+	if response.APIVersion != APIVersion {
+		return "", fmt.Errorf("plugin %s responded with api_version %q, expected %q", v.command, response.APIVersion, APIVersion)
+	}
+	if response.Secret == "" {
+		return "", fmt.Errorf("plugin %s responded with an empty secret %q", v.command, name)
+	}
+
+	return response.Secret, nil
+}
+
+// limitedBuffer is a buffer discarding the writes past its limit, so that a plugin writing
+// endlessly is not buffered in memory. It does not embed the bytes.Buffer, whose ReadFrom
+// would bypass the limit.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if remaining := b.limit - b.buf.Len(); len(p) > remaining {
+		p = p[:max(remaining, 0)]
+		b.truncated = true
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
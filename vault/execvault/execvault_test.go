@@ -0,0 +1,159 @@
+package execvault
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// plugin writes the shell script to a plugin binary.
+func plugin(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o700))
+	return path
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		config  Config
+		wantErr string
+	}{
+		"valid": {
+			config: Config{Command: "/usr/local/bin/plugin", Env: map[string]string{"STORE": "prod"}},
+		},
+		"no command": {
+			config:  Config{},
+			wantErr: "command is not set",
+		},
+		"relative command": {
+			config:  Config{Command: "plugin"},
+			wantErr: `command "plugin" must be an absolute path`,
+		},
+		"invalid env": {
+			config:  Config{Command: "/plugin", Env: map[string]string{"A=B": "c"}},
+			wantErr: `env name "A=B" is invalid`,
+		},
+		"negative timeout": {
+			config:  Config{Command: "/plugin", Timeout: &metav1.Duration{Duration: -time.Second}},
+			wantErr: `timeout "-1s" must be positive`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestGetSecret(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		t.Setenv("ARC_INHERITED", "inherited")
+		v, err := New(Config{
+			Command: plugin(t, `
+read -r request
+case "$request" in
+*'"name":"github-app"'*) ;;
+*) echo "unexpected request $request" >&2; exit 1 ;;
+esac
+printf '{"api_version":"actions.github.com/vault/v1","secret":"%s%s"}' "$STORE" "$ARC_INHERITED"
+`),
+			Env: map[string]string{"STORE": "prod"},
+		})
+		require.NoError(t, err)
+
+		secret, err := v.GetSecret(ctx, "github-app")
+		require.NoError(t, err)
+		assert.Equal(t, "prod", secret, "the plugin does not inherit the environment")
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		v, err := New(Config{Command: plugin(t, "echo 'secret not found' >&2\nexit 3\n")})
+		require.NoError(t, err)
+
+		_, err = v.GetSecret(ctx, "github-app")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exit status 3: secret not found")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		v, err := New(Config{
+			Command: plugin(t, "exec sleep 10\n"),
+			Timeout: &metav1.Duration{Duration: 100 * time.Millisecond},
+		})
+		require.NoError(t, err)
+
+		start := time.Now()
+		_, err = v.GetSecret(ctx, "github-app")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "did not write secret \"github-app\" within 100ms")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("invalid responses", func(t *testing.T) {
+		for name, output := range map[string]string{
+			"not json":        "secret",
+			"unknown field":   `{"api_version":"actions.github.com/vault/v1","secret":"s","extra":1}`,
+			"unknown version": `{"api_version":"v2","secret":"s"}`,
+			"empty secret":    `{"api_version":"actions.github.com/vault/v1"}`,
+		} {
+			v, err := New(Config{Command: plugin(t, "printf '%s' '"+output+"'\n")})
+			require.NoError(t, err)
+
+			_, err = v.GetSecret(ctx, "github-app")
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("output too large", func(t *testing.T) {
+		v, err := New(Config{Command: plugin(t, "head -c 2000000 /dev/zero\n")})
+		require.NoError(t, err)
+
+		_, err = v.GetSecret(ctx, "github-app")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "wrote more than")
+	})
+}
+
+func TestLoadPlugins(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "plugins.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		plugins, err := LoadPlugins(write(t, `{"store":{"command":"/opt/plugin","args":["get"],"timeout":"5s"}}`))
+		require.NoError(t, err)
+
+		cfg, err := plugins.Config("store")
+		require.NoError(t, err)
+		assert.Equal(t, Config{Command: "/opt/plugin", Args: []string{"get"}, Timeout: &metav1.Duration{Duration: 5 * time.Second}}, cfg)
+
+		_, err = plugins.Config("other")
+		assert.EqualError(t, err, `plugin "other" is not one of the plugins the controller is configured with`)
+	})
+
+	t.Run("invalid plugin", func(t *testing.T) {
+		_, err := LoadPlugins(write(t, `{"store":{"command":"plugin"}}`))
+		assert.EqualError(t, err, `invalid plugin "store": command "plugin" must be an absolute path`)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := LoadPlugins(write(t, `{"store":{"path":"/opt/plugin"}}`))
+		assert.ErrorContains(t, err, "failed to decode plugins file")
+	})
+}
@@ -0,0 +1,196 @@
+package vault
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// The operations of the vault requests reported to the Observer.
+const (
+	OperationGetSecret     = "get_secret"
+	OperationResolveAppKey = "resolve_app_key"
+	OperationSign          = "sign"
+)
+
+// The results of the vault requests, see RequestResult.
+const (
+	ResultSuccess   = "success"
+	ResultError     = "error"
+	ResultThrottled = "throttled"
+)
+
+// Observer is notified of every request made to a vault, e.g. to export its metrics.
+type Observer interface {
+	ObserveVaultRequest(vaultType VaultType, operation string, duration time.Duration, err error)
+}
+
+// ObserverFunc is an Observer calling the function.
+type ObserverFunc func(vaultType VaultType, operation string, duration time.Duration, err error)
+
+func (f ObserverFunc) ObserveVaultRequest(vaultType VaultType, operation string, duration time.Duration, err error) {
+	f(vaultType, operation, duration, err)
+}
+
+// BufferedObserver holds the requests of a vault until the observer is set, e.g. the requests made while
+// reading the config of the listener before its metrics are exported, and then notifies the observer.
+type BufferedObserver struct {
+	mu       sync.Mutex
+	observer Observer
+	buffered []observation
+}
+
+type observation struct {
+	vaultType VaultType
+	operation string
+	duration  time.Duration
+	err       error
+}
+
+func (b *BufferedObserver) ObserveVaultRequest(vaultType VaultType, operation string, duration time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.observer == nil {
+		b.buffered = append(b.buffered, observation{vaultType, operation, duration, err})
+		return
+	}
+	b.observer.ObserveVaultRequest(vaultType, operation, duration, err)
+}
+
+// SetObserver notifies the observer of the buffered requests, and then of the following ones.
+func (b *BufferedObserver) SetObserver(observer Observer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, o := range b.buffered {
+		observer.ObserveVaultRequest(o.vaultType, o.operation, o.duration, o.err)
+	}
+	b.buffered = nil
+	b.observer = observer
+}
+
+// Instrument returns the vault notifying the observer of its requests. It returns the vault itself
+// if the observer is nil.
+func Instrument(v Vault, vaultType VaultType, observer Observer) Vault {
+	if observer == nil {
+		return v
+	}
+	return &instrumented{vault: v, vaultType: vaultType, observer: observer, now: time.Now}
+}
+
+type instrumented struct {
+	vault     Vault
+	vaultType VaultType
+	observer  Observer
+	now       func() time.Time
+}
+
+var (
+	_ Vault          = (*instrumented)(nil)
+	_ AppKeyResolver = (*instrumented)(nil)
+)
+
+func (v *instrumented) GetSecret(ctx context.Context, name string) (string, error) {
+	start := v.now()
+	secret, err := v.vault.GetSecret(ctx, name)
+	v.observer.ObserveVaultRequest(v.vaultType, OperationGetSecret, v.now().Sub(start), err)
+	return secret, err
+}
+
+// ResolveAppKey resolves the private key of the GitHub App from the vault, if it implements AppKeyResolver.
+// Only the vaults resolving the key are observed. If the vault resolves a signer of the key, e.g. a Managed HSM
+// key, its signatures are observed as well.
+func (v *instrumented) ResolveAppKey(ctx context.Context, appConfig *appconfig.AppConfig) error {
+	r, ok := v.vault.(AppKeyResolver)
+	if !ok {
+		return nil
+	}
+	start := v.now()
+	err := r.ResolveAppKey(ctx, appConfig)
+	v.observer.ObserveVaultRequest(v.vaultType, OperationResolveAppKey, v.now().Sub(start), err)
+	if err == nil && appConfig.AppPrivateKeySigner != nil {
+		appConfig.AppPrivateKeySigner = &instrumentedSigner{signer: appConfig.AppPrivateKeySigner, vault: v}
+	}
+	return err
+}
+
+// instrumentedSigner is a signer of the vault notifying its observer of the signatures.
+type instrumentedSigner struct {
+	signer crypto.Signer
+	vault  *instrumented
+}
+
+var _ crypto.Signer = (*instrumentedSigner)(nil)
+
+func (s *instrumentedSigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+func (s *instrumentedSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	start := s.vault.now()
+	signature, err := s.signer.Sign(rand, digest, opts)
+	s.vault.observer.ObserveVaultRequest(s.vault.vaultType, OperationSign, s.vault.now().Sub(start), err)
+	return signature, err
+}
+
+// String returns the ID of the signer, so that the signers of the rotated keys are still told apart.
+func (s *instrumentedSigner) String() string {
+	if stringer, ok := s.signer.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%p", s.signer)
+}
+
+// IsThrottled reports whether the error is the vault rejecting a request for exceeding its rate limits.
+func IsThrottled(err error) bool {
+	if err == nil {
+		return false
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) && respErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return apierrors.IsTooManyRequests(err)
+}
+
+// IsTransient reports whether the error is a failure of the vault which may go away by retrying: the vault
+// throttling the requests, failing with a server error, or being unreachable, e.g. a network error or a timeout.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsThrottled(err) {
+		return true
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode >= http.StatusInternalServerError
+	}
+	if apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// RequestResult returns the result of a vault request failing with the error, if any:
+// ResultSuccess, ResultThrottled or ResultError.
+func RequestResult(err error) string {
+	switch {
+	case err == nil:
+		return ResultSuccess
+	case IsThrottled(err):
+		return ResultThrottled
+	default:
+		return ResultError
+	}
+}
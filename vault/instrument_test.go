@@ -0,0 +1,199 @@
+package vault
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type keyResolvingVault struct {
+	fakeVault
+	signer crypto.Signer
+	err    error
+}
+
+func (v *keyResolvingVault) ResolveAppKey(ctx context.Context, appConfig *appconfig.AppConfig) error {
+	if v.signer != nil {
+		appConfig.AppPrivateKey = ""
+		appConfig.AppPrivateKeySigner = v.signer
+	}
+	return v.err
+}
+
+type fakeSigner struct {
+	err error
+}
+
+func (s *fakeSigner) Public() crypto.PublicKey { return nil }
+
+func (s *fakeSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return []byte("signature"), s.err
+}
+
+func (s *fakeSigner) String() string { return "key/1" }
+
+func TestInstrument(t *testing.T) {
+	ctx := context.Background()
+	var observed []observation
+	observer := ObserverFunc(func(vaultType VaultType, operation string, duration time.Duration, err error) {
+		observed = append(observed, observation{vaultType, operation, duration, err})
+	})
+
+	t.Run("nil observer", func(t *testing.T) {
+		v := &fakeVault{}
+		assert.Same(t, v, Instrument(v, VaultTypeKubernetes, nil))
+	})
+
+	t.Run("get secret", func(t *testing.T) {
+		observed = nil
+		errUnavailable := errors.New("vault unavailable")
+		v := &fakeVault{secret: "secret"}
+		iv := Instrument(v, VaultTypeKubernetes, observer)
+		iv.(*instrumented).now = steppingClock(time.Second)
+
+		got, err := iv.GetSecret(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", got)
+
+		v.set("", errUnavailable)
+		_, err = iv.GetSecret(ctx, "key")
+		assert.ErrorIs(t, err, errUnavailable)
+
+		assert.Equal(t, []observation{
+			{VaultTypeKubernetes, OperationGetSecret, time.Second, nil},
+			{VaultTypeKubernetes, OperationGetSecret, time.Second, errUnavailable},
+		}, observed)
+	})
+
+	t.Run("resolve app key", func(t *testing.T) {
+		observed = nil
+		errSign := errors.New("sign failed")
+		iv := Instrument(&keyResolvingVault{err: errSign}, VaultTypeAzureKeyVault, observer)
+		iv.(*instrumented).now = steppingClock(time.Second)
+
+		_, err := ResolveAppConfig(ctx, iv, `{"github_token":"token"}`)
+		assert.ErrorIs(t, err, errSign)
+		assert.Equal(t, []observation{
+			{VaultTypeAzureKeyVault, OperationResolveAppKey, time.Second, errSign},
+		}, observed)
+	})
+
+	t.Run("sign", func(t *testing.T) {
+		observed = nil
+		errSign := errors.New("sign failed")
+		signer := &fakeSigner{}
+		iv := Instrument(&keyResolvingVault{signer: signer}, VaultTypeAzureKeyVault, observer)
+		iv.(*instrumented).now = steppingClock(time.Second)
+
+		appConfig, err := ResolveAppConfig(ctx, iv, `{"github_app_id":"1","github_app_installation_id":2}`)
+		require.NoError(t, err)
+		require.NotNil(t, appConfig.AppPrivateKeySigner)
+		assert.Equal(t, "key/1", appConfig.AppPrivateKeySigner.(fmt.Stringer).String(), "the signer is still identified by its key")
+
+		_, err = appConfig.AppPrivateKeySigner.Sign(nil, []byte("digest"), crypto.SHA256)
+		require.NoError(t, err)
+		signer.err = errSign
+		_, err = appConfig.AppPrivateKeySigner.Sign(nil, []byte("digest"), crypto.SHA256)
+		assert.ErrorIs(t, err, errSign)
+
+		assert.Equal(t, []observation{
+			{VaultTypeAzureKeyVault, OperationResolveAppKey, time.Second, nil},
+			{VaultTypeAzureKeyVault, OperationSign, time.Second, nil},
+			{VaultTypeAzureKeyVault, OperationSign, time.Second, errSign},
+		}, observed)
+	})
+
+	t.Run("vault not resolving the app key", func(t *testing.T) {
+		observed = nil
+		iv := Instrument(&fakeVault{}, VaultTypeKubernetes, observer)
+
+		_, err := ResolveAppConfig(ctx, iv, `{"github_token":"token"}`)
+		require.NoError(t, err)
+		assert.Empty(t, observed)
+	})
+}
+
+func TestBufferedObserver(t *testing.T) {
+	errUnavailable := errors.New("vault unavailable")
+	var b BufferedObserver
+	b.ObserveVaultRequest(VaultTypeKubernetes, OperationGetSecret, time.Second, nil)
+	b.ObserveVaultRequest(VaultTypeKubernetes, OperationGetSecret, time.Second, errUnavailable)
+
+	var observed []observation
+	b.SetObserver(ObserverFunc(func(vaultType VaultType, operation string, duration time.Duration, err error) {
+		observed = append(observed, observation{vaultType, operation, duration, err})
+	}))
+	assert.Equal(t, []observation{
+		{VaultTypeKubernetes, OperationGetSecret, time.Second, nil},
+		{VaultTypeKubernetes, OperationGetSecret, time.Second, errUnavailable},
+	}, observed, "the buffered requests are replayed")
+
+	b.ObserveVaultRequest(VaultTypeKubernetes, OperationGetSecret, 2*time.Second, nil)
+	assert.Len(t, observed, 3)
+	assert.Equal(t, observation{VaultTypeKubernetes, OperationGetSecret, 2 * time.Second, nil}, observed[2])
+}
+
+// steppingClock returns the function advancing the time by step on every call.
+func steppingClock(step time.Duration) func() time.Time {
+	now := time.Now()
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+func TestRequestResult(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want string
+	}{
+		"success":       {err: nil, want: ResultSuccess},
+		"error":         {err: errors.New("boom"), want: ResultError},
+		"azure 429":     {err: fmt.Errorf("failed to get secret: %w", &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}), want: ResultThrottled},
+		"azure 403":     {err: &azcore.ResponseError{StatusCode: http.StatusForbidden}, want: ResultError},
+		"kubernetes":    {err: fmt.Errorf("failed to get secret: %w", apierrors.NewTooManyRequests("slow down", 1)), want: ResultThrottled},
+		"not found":     {err: apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "secret"), want: ResultError},
+		"wrapped twice": {err: fmt.Errorf("outer: %w", fmt.Errorf("inner: %w", apierrors.NewTooManyRequests("slow down", 1))), want: ResultThrottled},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RequestResult(tt.err))
+			assert.Equal(t, tt.want == ResultThrottled, IsThrottled(tt.err))
+		})
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":               {err: nil, want: false},
+		"error":             {err: errors.New("boom"), want: false},
+		"throttled":         {err: apierrors.NewTooManyRequests("slow down", 1), want: true},
+		"azure 503":         {err: fmt.Errorf("failed to get secret: %w", &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}), want: true},
+		"azure 403":         {err: &azcore.ResponseError{StatusCode: http.StatusForbidden}, want: false},
+		"kubernetes 503":    {err: apierrors.NewServiceUnavailable("unavailable"), want: true},
+		"not found":         {err: apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "secret"), want: false},
+		"network error":     {err: fmt.Errorf("failed to get secret: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}), want: true},
+		"deadline exceeded": {err: fmt.Errorf("plugin timed out: %w", context.DeadlineExceeded), want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsTransient(tt.err))
+		})
+	}
+}
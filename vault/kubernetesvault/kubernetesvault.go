@@ -0,0 +1,88 @@
+package kubernetesvault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AllowedNamespacesAnnotationKey is the annotation of the Secrets listing, comma separated, the namespaces of the
+// scale sets allowed to read them. The Secrets of another namespace than the one of a scale set are not read
+// without it, so that the users allowed to create scale sets cannot read every Secret the controller can get.
+const AllowedNamespacesAnnotationKey = "actions.github.com/vault-allowed-namespaces"
+
+// KubernetesVault reads the secrets from Kubernetes Secrets of another namespace, e.g. a central secrets namespace
+// the listeners are granted to get the Secrets of, rather than the Secrets being copied to the namespaces of the
+// scale sets.
+type KubernetesVault struct {
+	client    client.Reader
+	namespace string
+}
+
+// New returns a vault reading the Secrets for the scale sets of the namespace, the Secrets of the namespace
+// and the ones allowing it in their AllowedNamespacesAnnotationKey annotation.
+func New(client client.Reader, namespace string) *KubernetesVault {
+	return &KubernetesVault{client: client, namespace: namespace}
+}
+
+// ParseLookupKey returns the namespace and the name of the Secret of the lookup key, namespace/name.
+func ParseLookupKey(key string) (types.NamespacedName, error) {
+	namespace, name, ok := strings.Cut(key, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return types.NamespacedName{}, fmt.Errorf("lookup key %q is not of the form namespace/name", key)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// GetSecret retrieves the Secret of the name, namespace/name, as a JSON object of its keys, so that it is read
+// like the secrets of the other vaults. The github_app_installation_id is a number, as in the app config.
+func (v *KubernetesVault) GetSecret(ctx context.Context, name string) (string, error) {
+	key, err := ParseLookupKey(name)
+	if err != nil {
+		return "", err
+	}
+
+	var secret corev1.Secret
+	if err := v.client.Get(ctx, key, &secret); err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", key, err)
+	}
+	if !v.allowed(&secret) {
+		return "", fmt.Errorf("secret %s does not allow namespace %q in its %s annotation", key, v.namespace, AllowedNamespacesAnnotationKey)
+	}
+
+	values := make(map[string]any, len(secret.Data))
+	for k, data := range secret.Data {
+		values[k] = string(data)
+	}
+	if id, ok := secret.Data["github_app_installation_id"]; ok {
+		installationID, err := strconv.ParseInt(string(id), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse github_app_installation_id of secret %s: %w", key, err)
+		}
+		values["github_app_installation_id"] = installationID
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode secret %s: %w", key, err)
+	}
+	return string(b), nil
+}
+
+func (v *KubernetesVault) allowed(secret *corev1.Secret) bool {
+	if secret.Namespace == v.namespace {
+		return true
+	}
+	namespaces := strings.Split(secret.Annotations[AllowedNamespacesAnnotationKey], ",")
+	for i := range namespaces {
+		namespaces[i] = strings.TrimSpace(namespaces[i])
+	}
+	return slices.Contains(namespaces, v.namespace)
+}
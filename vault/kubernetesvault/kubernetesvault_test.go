@@ -0,0 +1,62 @@
+package kubernetesvault
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseLookupKey(t *testing.T) {
+	key, err := ParseLookupKey("arc-secrets/github-app")
+	require.NoError(t, err)
+	assert.Equal(t, "arc-secrets", key.Namespace)
+	assert.Equal(t, "github-app", key.Name)
+
+	for _, invalid := range []string{"github-app", "/github-app", "arc-secrets/", "arc-secrets/github-app/key"} {
+		_, err := ParseLookupKey(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestGetSecret(t *testing.T) {
+	client := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "arc-secrets",
+			Name:        "github-app",
+			Annotations: map[string]string{AllowedNamespacesAnnotationKey: "team-a, team-b"},
+		},
+		Data: map[string][]byte{
+			"github_app_id":              []byte("123"),
+			"github_app_installation_id": []byte("456"),
+			"github_app_private_key":     []byte("private key"),
+		},
+	}, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "arc-secrets", Name: "other"},
+		Data:       map[string][]byte{"github_token": []byte("token")},
+	}).Build()
+
+	v := New(client, "team-b")
+	secret, err := v.GetSecret(context.Background(), "arc-secrets/github-app")
+	require.NoError(t, err)
+	appConfig, err := appconfig.FromJSONString(secret)
+	require.NoError(t, err)
+	assert.Equal(t, &appconfig.AppConfig{AppID: "123", AppInstallationID: 456, AppPrivateKey: "private key"}, appConfig)
+
+	_, err = v.GetSecret(context.Background(), "arc-secrets/missing")
+	assert.Error(t, err)
+
+	_, err = v.GetSecret(context.Background(), "arc-secrets/other")
+	assert.EqualError(t, err, `secret arc-secrets/other does not allow namespace "team-b" in its actions.github.com/vault-allowed-namespaces annotation`)
+
+	_, err = New(client, "team-c").GetSecret(context.Background(), "arc-secrets/github-app")
+	assert.Error(t, err)
+
+	_, err = New(client, "arc-secrets").GetSecret(context.Background(), "arc-secrets/other")
+	assert.NoError(t, err, "the secrets of the namespace of the scale set are allowed")
+}
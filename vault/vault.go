@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/actions/actions-runner-controller/apis/actions.github.com/v1alpha1/appconfig"
 	"github.com/actions/actions-runner-controller/vault/azurekeyvault"
+	"github.com/actions/actions-runner-controller/vault/execvault"
+	"github.com/actions/actions-runner-controller/vault/kubernetesvault"
 )
 
 // Vault is the interface every vault implementation needs to adhere to
@@ -12,6 +15,24 @@ type Vault interface {
 	GetSecret(ctx context.Context, name string) (string, error)
 }
 
+// AppKeyResolver is implemented by the vaults resolving the private key of the GitHub App from their own
+// certificates or keys, in place of the one of the app config secret.
+type AppKeyResolver interface {
+	ResolveAppKey(ctx context.Context, appConfig *appconfig.AppConfig) error
+}
+
+// ResolveAppConfig decodes the app config secret, resolving the private key of the GitHub App from the vault
+// if it implements AppKeyResolver.
+func ResolveAppConfig(ctx context.Context, v Vault, secret string) (*appconfig.AppConfig, error) {
+	r, ok := v.(AppKeyResolver)
+	if !ok {
+		return appconfig.FromJSONString(secret)
+	}
+	return appconfig.FromJSONString(secret, func(appConfig *appconfig.AppConfig) error {
+		return r.ResolveAppKey(ctx, appConfig)
+	})
+}
+
 // VaultType represents the type of vault that can be used in the application.
 // It is used to identify which vault integration should be used to resolve secrets.
 type VaultType string
@@ -19,6 +40,10 @@ type VaultType string
 // VaultType is the type of vault supported
 const (
 	VaultTypeAzureKeyVault VaultType = "azure_key_vault"
+	// VaultTypeKubernetes reads the secrets from Kubernetes Secrets, the lookup keys being namespace/name.
+	VaultTypeKubernetes VaultType = "kubernetes"
+	// VaultTypeExec reads the secrets from a plugin binary, see the execvault package for its protocol.
+	VaultTypeExec VaultType = "exec"
 )
 
 func (t VaultType) String() string {
@@ -27,7 +52,7 @@ func (t VaultType) String() string {
 
 func (t VaultType) Validate() error {
 	switch t {
-	case VaultTypeAzureKeyVault:
+	case VaultTypeAzureKeyVault, VaultTypeKubernetes, VaultTypeExec:
 		return nil
 	default:
 		return fmt.Errorf("unknown vault type: %q", t)
@@ -35,4 +60,9 @@ func (t VaultType) Validate() error {
 }
 
 // Compile-time checks
-var _ Vault = (*azurekeyvault.AzureKeyVault)(nil)
+var (
+	_ Vault          = (*azurekeyvault.AzureKeyVault)(nil)
+	_ AppKeyResolver = (*azurekeyvault.AzureKeyVault)(nil)
+	_ Vault          = (*kubernetesvault.KubernetesVault)(nil)
+	_ Vault          = (*execvault.ExecVault)(nil)
+)